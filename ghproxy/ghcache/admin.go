@@ -0,0 +1,401 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// invalidatedPathsCounter provides the 'ghcache_invalidated_paths' counter
+// that keeps track of the paths invalidated through the admin API.
+var invalidatedPathsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ghcache_invalidated_paths",
+		Help: "How many paths and path prefixes were invalidated.",
+	},
+	[]string{"kind"},
+)
+
+func init() {
+	prometheus.MustRegister(invalidatedPathsCounter)
+}
+
+var (
+	errNotInspectable = errors.New("cache backend can't list its entries")
+
+	// partitionKeyRegex matches the partition keys created by
+	// getCachePartition.
+	partitionKeyRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// PartitionInfo describes a cache partition.
+type PartitionInfo struct {
+	// Name is the partition key, the sha256 sum of the Authorization header.
+	Name string `json:"name"`
+	// Loaded is whether the partition served requests since ghproxy started.
+	Loaded bool `json:"loaded"`
+	// Entries is the number of cached responses, or -1 if the backend
+	// can't count them.
+	Entries int `json:"entries"`
+	// SizeBytes is the size of the cached responses.
+	SizeBytes int64 `json:"size_bytes"`
+	// LastUsed is when the partition last served or stored a response.
+	LastUsed time.Time `json:"last_used"`
+}
+
+// partitionStore holds partitions which outlive the ghproxy process.
+type partitionStore interface {
+	// list returns the usage of every stored partition.
+	list() (map[string]directoryUsage, error)
+	// open returns the cache of a stored partition without loading it.
+	open(partitionKey string) httpcache.Cache
+	// remove deletes a stored partition.
+	remove(partitionKey string) error
+}
+
+// diskPartitionStore stores each partition in its own directory below
+// $cacheDir/data, as done by NewDiskCache.
+type diskPartitionStore struct {
+	cacheDir string
+	newCache func(partitionKey string) httpcache.Cache
+}
+
+func (s diskPartitionStore) list() (map[string]directoryUsage, error) {
+	dirs, err := ioutil.ReadDir(path.Join(s.cacheDir, "data"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	partitions := map[string]directoryUsage{}
+	for _, dir := range dirs {
+		if !dir.IsDir() || !partitionKeyRegex.MatchString(dir.Name()) {
+			continue
+		}
+		usage, err := diskUsage(path.Join(s.cacheDir, "data", dir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read partition %s: %v", dir.Name(), err)
+		}
+		partitions[dir.Name()] = usage
+	}
+	return partitions, nil
+}
+
+func (s diskPartitionStore) open(partitionKey string) httpcache.Cache {
+	return s.newCache(partitionKey)
+}
+
+func (s diskPartitionStore) remove(partitionKey string) error {
+	if err := os.RemoveAll(path.Join(s.cacheDir, "data", partitionKey)); err != nil {
+		return err
+	}
+	return os.RemoveAll(path.Join(s.cacheDir, "temp", partitionKey))
+}
+
+func cacheOf(roundTripper http.RoundTripper) httpcache.Cache {
+	if pt, ok := roundTripper.(*partitionTransport); ok {
+		return pt.cache
+	}
+	return nil
+}
+
+// partitions describes the loaded and stored partitions, sorted by name.
+func (prt *partitioningRoundTripper) partitions() ([]PartitionInfo, error) {
+	infos := map[string]*PartitionInfo{}
+	if prt.store != nil {
+		stored, err := prt.store.list()
+		if err != nil {
+			return nil, err
+		}
+		for key, usage := range stored {
+			infos[key] = &PartitionInfo{Name: key, Entries: usage.entries, SizeBytes: usage.size, LastUsed: usage.modTime}
+		}
+	}
+
+	prt.lock.Lock()
+	caches := map[string]httpcache.Cache{}
+	lastUsed := map[string]time.Time{}
+	for key, roundTripper := range prt.roundTrippers {
+		caches[key] = cacheOf(roundTripper)
+		lastUsed[key] = prt.lastUsed[key]
+	}
+	prt.lock.Unlock()
+
+	for key, cache := range caches {
+		info, stored := infos[key]
+		if !stored {
+			info = &PartitionInfo{Name: key, Entries: -1}
+			infos[key] = info
+			if cache, ok := cache.(InspectableCache); ok {
+				if keys, err := cache.Keys(); err == nil {
+					info.Entries = len(keys)
+				}
+				if size, err := cache.Size(); err == nil {
+					info.SizeBytes = size
+				}
+			}
+		}
+		info.Loaded = true
+		if lastUsed[key].After(info.LastUsed) {
+			info.LastUsed = lastUsed[key]
+		}
+	}
+
+	partitions := make([]PartitionInfo, 0, len(infos))
+	for _, info := range infos {
+		partitions = append(partitions, *info)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Name < partitions[j].Name })
+	return partitions, nil
+}
+
+// removePartition drops a partition and deletes its stored responses, unless
+// it was used after notUsedSince. A zero notUsedSince always removes it.
+func (prt *partitioningRoundTripper) removePartition(partitionKey string, notUsedSince time.Time) (bool, error) {
+	prt.lock.Lock()
+	if lastUsed, ok := prt.lastUsed[partitionKey]; ok && !notUsedSince.IsZero() && lastUsed.After(notUsedSince) {
+		prt.lock.Unlock()
+		return false, nil
+	}
+	delete(prt.roundTrippers, partitionKey)
+	delete(prt.lastUsed, partitionKey)
+	prt.lock.Unlock()
+
+	if prt.store != nil {
+		if err := prt.store.remove(partitionKey); err != nil {
+			return false, fmt.Errorf("failed to remove partition %s: %v", partitionKey, err)
+		}
+	}
+	logrus.WithField("cache-partition-key", partitionKey).Info("Removed cache partition.")
+	return true, nil
+}
+
+// keyHasPathPrefix returns whether the URL in a httpcache key has a path
+// starting with prefix. Keys are the URL, preceded by the method for methods
+// other than GET.
+func keyHasPathPrefix(key, prefix string) bool {
+	fields := strings.Fields(key)
+	if len(fields) == 0 {
+		return false
+	}
+	u, err := url.Parse(fields[len(fields)-1])
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(u.Path, prefix)
+}
+
+// purgePathPrefix deletes the responses to requests whose path starts with
+// prefix, from the given partition or from all of them if it is empty.
+func (prt *partitioningRoundTripper) purgePathPrefix(partitionKey, prefix string) (int, error) {
+	caches := map[string]httpcache.Cache{}
+	prt.lock.Lock()
+	for key, roundTripper := range prt.roundTrippers {
+		if partitionKey == "" || key == partitionKey {
+			caches[key] = cacheOf(roundTripper)
+		}
+	}
+	prt.lock.Unlock()
+	if prt.store != nil {
+		stored, err := prt.store.list()
+		if err != nil {
+			return 0, err
+		}
+		for key := range stored {
+			if _, loaded := caches[key]; !loaded && (partitionKey == "" || key == partitionKey) {
+				caches[key] = prt.store.open(key)
+			}
+		}
+	}
+
+	purged := 0
+	for key, cache := range caches {
+		inspectable, ok := cache.(InspectableCache)
+		if !ok {
+			return purged, fmt.Errorf("partition %s: %v", key, errNotInspectable)
+		}
+		keys, err := inspectable.Keys()
+		if err != nil {
+			return purged, fmt.Errorf("failed to list entries of partition %s: %v", key, err)
+		}
+		for _, cacheKey := range keys {
+			if keyHasPathPrefix(cacheKey, prefix) {
+				inspectable.Delete(cacheKey)
+				purged++
+			}
+		}
+	}
+	return purged, nil
+}
+
+// collectGarbage removes the partitions which were not used since
+// notUsedSince.
+func (prt *partitioningRoundTripper) collectGarbage(notUsedSince time.Time) ([]string, error) {
+	partitions, err := prt.partitions()
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	for _, partition := range partitions {
+		if !partition.LastUsed.Before(notUsedSince) {
+			continue
+		}
+		ok, err := prt.removePartition(partition.Name, notUsedSince)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, partition.Name)
+		}
+	}
+	return removed, nil
+}
+
+// Admin inspects and invalidates the partitions of a GitHub cache.
+type Admin struct {
+	prt *partitioningRoundTripper
+}
+
+// NewAdmin creates an Admin for a cache created by this package.
+func NewAdmin(cache http.RoundTripper) (*Admin, error) {
+	prt, ok := cache.(*partitioningRoundTripper)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a partitioned GitHub cache", cache)
+	}
+	return &Admin{prt: prt}, nil
+}
+
+// Partitions describes the partitions of the cache, including the ones
+// persisted by earlier runs of ghproxy.
+func (a *Admin) Partitions() ([]PartitionInfo, error) {
+	return a.prt.partitions()
+}
+
+// PurgePartition removes a partition and all its responses.
+func (a *Admin) PurgePartition(partitionKey string) error {
+	if !partitionKeyRegex.MatchString(partitionKey) {
+		return fmt.Errorf("invalid partition %q", partitionKey)
+	}
+	_, err := a.prt.removePartition(partitionKey, time.Time{})
+	return err
+}
+
+// PurgePathPrefix deletes the responses to requests whose path starts with
+// prefix from a partition, or from all partitions if partitionKey is empty.
+// It returns the number of deleted responses.
+func (a *Admin) PurgePathPrefix(partitionKey, prefix string) (int, error) {
+	if partitionKey != "" && !partitionKeyRegex.MatchString(partitionKey) {
+		return 0, fmt.Errorf("invalid partition %q", partitionKey)
+	}
+	return a.prt.purgePathPrefix(partitionKey, prefix)
+}
+
+// CollectGarbage removes the partitions unused for longer than unusedFor and
+// returns their names.
+func (a *Admin) CollectGarbage(unusedFor time.Duration) ([]string, error) {
+	return a.prt.collectGarbage(time.Now().Add(-unusedFor))
+}
+
+// Invalidate marks the cached responses for the paths in the request as
+// stale, so that they are revalidated when they are next requested even if
+// they are still within the fresh window.
+func (a *Admin) Invalidate(request InvalidationRequest) {
+	a.prt.invalidations.invalidate(request, time.Now())
+	invalidatedPathsCounter.WithLabelValues("path").Add(float64(len(request.Paths)))
+	invalidatedPathsCounter.WithLabelValues("prefix").Add(float64(len(request.Prefixes)))
+}
+
+// ServeHTTP serves the admin API:
+//
+//	GET  /partitions                            lists the partitions
+//	POST /purge?partition=<key>                 removes a partition
+//	POST /purge?prefix=<path>[&partition=<key>] deletes responses by path prefix
+//	POST /gc?days=<n>                           removes partitions unused for n days
+//	POST /invalidate                            marks the paths in the InvalidationRequest body as stale
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var result interface{}
+	var err error
+	status := http.StatusBadRequest
+	switch {
+	case r.URL.Path == "/partitions" && r.Method == http.MethodGet:
+		result, err = a.Partitions()
+		status = http.StatusInternalServerError
+	case r.URL.Path == "/purge" && r.Method == http.MethodPost:
+		partition, prefix := r.URL.Query().Get("partition"), r.URL.Query().Get("prefix")
+		switch {
+		case prefix != "":
+			var purged int
+			if purged, err = a.PurgePathPrefix(partition, prefix); err == nil {
+				result = map[string]int{"purged_entries": purged}
+			}
+		case partition != "":
+			if err = a.PurgePartition(partition); err == nil {
+				result = map[string][]string{"purged_partitions": {partition}}
+			}
+		default:
+			err = errors.New("either partition or prefix must be set")
+		}
+	case r.URL.Path == "/gc" && r.Method == http.MethodPost:
+		// Partitions in use may not have been used for some hours, so
+		// at least a day is required.
+		days, parseErr := strconv.Atoi(r.URL.Query().Get("days"))
+		if parseErr != nil || days < 1 {
+			err = fmt.Errorf("days must be a positive number, got %q", r.URL.Query().Get("days"))
+			break
+		}
+		status = http.StatusInternalServerError
+		var removed []string
+		if removed, err = a.CollectGarbage(time.Duration(days) * 24 * time.Hour); err == nil {
+			result = map[string][]string{"purged_partitions": removed}
+		}
+	case r.URL.Path == "/invalidate" && r.Method == http.MethodPost:
+		var request InvalidationRequest
+		if err = json.NewDecoder(r.Body).Decode(&request); err == nil {
+			a.Invalidate(request)
+			result = map[string]int{"invalidated": len(request.Paths) + len(request.Prefixes)}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("path", r.URL.Path).Warn("Cache admin request failed.")
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logrus.WithError(err).Warn("Failed to write cache admin response.")
+	}
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/peterbourgon/diskv"
+)
+
+func partitionKey(authorization string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(authorization)))
+}
+
+// newTestAdmin creates an Admin for a partitioned disk cache in dir whose
+// partitions don't send any requests.
+func newTestAdmin(dir string) *Admin {
+	partitionCache := func(partitionKey string) httpcache.Cache {
+		return NewDiskCacheWithDiskv(diskv.New(diskv.Options{
+			BasePath: path.Join(dir, "data", partitionKey),
+			TempDir:  path.Join(dir, "temp", partitionKey),
+		}))
+	}
+	prt := newPartitioningRoundTripper(func(partitionKey string) http.RoundTripper {
+		return &partitionTransport{RoundTripper: &fakeRoundTripper{lock: &sync.Mutex{}}, cache: partitionCache(partitionKey)}
+	})
+	prt.store = diskPartitionStore{cacheDir: dir, newCache: partitionCache}
+	return &Admin{prt: prt}
+}
+
+// age sets the modification time of all files below dir.
+func age(t *testing.T, dir string, modTime time.Time) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, modTime, modTime)
+	})
+	if err != nil {
+		t.Fatalf("failed to age %s: %v", dir, err)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghcache")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	admin := newTestAdmin(dir)
+	old, current := partitionKey("token old"), partitionKey("token current")
+
+	// A partition of a rotated token, left behind by an earlier run.
+	oldCache := admin.prt.store.open(old)
+	oldCache.Set("https://api.github.com/repos/org/repo/pulls/1", []byte("pr"))
+	age(t, path.Join(dir, "data", old), time.Now().Add(-10*24*time.Hour))
+
+	req := &http.Request{Header: http.Header{"Authorization": {"token current"}}}
+	if _, err := admin.prt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	currentCache := cacheOf(admin.prt.roundTrippers[current])
+	currentCache.Set("https://api.github.com/repos/org/repo/pulls/2", []byte("pr"))
+	currentCache.Set("https://api.github.com/repos/org/other", []byte("repo"))
+
+	partitions, err := admin.Partitions()
+	if err != nil {
+		t.Fatalf("failed to list partitions: %v", err)
+	}
+	if len(partitions) != 2 {
+		t.Fatalf("expected two partitions, got %+v", partitions)
+	}
+	for _, p := range partitions {
+		switch p.Name {
+		case old:
+			if p.Loaded || p.Entries != 1 || p.SizeBytes == 0 || time.Since(p.LastUsed) < 9*24*time.Hour {
+				t.Errorf("unexpected old partition %+v", p)
+			}
+		case current:
+			if !p.Loaded || p.Entries != 2 || time.Since(p.LastUsed) > time.Hour {
+				t.Errorf("unexpected current partition %+v", p)
+			}
+		default:
+			t.Errorf("unexpected partition %+v", p)
+		}
+	}
+
+	purged, err := admin.PurgePathPrefix("", "/repos/org/repo/pulls")
+	if err != nil {
+		t.Fatalf("failed to purge by prefix: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("expected a response to be purged from each partition, got %d", purged)
+	}
+	if _, ok := currentCache.Get("https://api.github.com/repos/org/other"); !ok {
+		t.Error("expected responses outside of the prefix to be kept")
+	}
+
+	removed, err := admin.CollectGarbage(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("failed to collect garbage: %v", err)
+	}
+	if expected := []string{old}; !reflect.DeepEqual(expected, removed) {
+		t.Errorf("expected partitions %v to be removed, got %v", expected, removed)
+	}
+	if _, err := os.Stat(path.Join(dir, "data", old)); !os.IsNotExist(err) {
+		t.Errorf("expected the old partition to be deleted from disk, got %v", err)
+	}
+
+	if err := admin.PurgePartition(current); err != nil {
+		t.Fatalf("failed to purge partition: %v", err)
+	}
+	if partitions, err := admin.Partitions(); err != nil || len(partitions) != 0 {
+		t.Errorf("expected no partitions left, got %+v, %v", partitions, err)
+	}
+	if err := admin.PurgePartition("../data"); err == nil {
+		t.Error("expected invalid partition names to be rejected")
+	}
+}
+
+func TestAdminHTTP(t *testing.T) {
+	admin, err := NewAdmin(NewMemCache(nil, 1, false, SchedulingOptions{}, 0))
+	if err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+	prt := admin.prt
+	key := partitionKey("token a")
+	prt.roundTrippers[key] = &partitionTransport{RoundTripper: &fakeRoundTripper{lock: &sync.Mutex{}}, cache: NewMemoryCache(0)}
+	prt.lastUsed[key] = time.Now().Add(-48 * time.Hour)
+	cacheOf(prt.roundTrippers[key]).Set("https://api.github.com/user", []byte("user"))
+
+	cases := []struct {
+		name     string
+		method   string
+		target   string
+		status   int
+		expected string
+	}{
+		{
+			name:     "list partitions",
+			method:   http.MethodGet,
+			target:   "/partitions",
+			status:   http.StatusOK,
+			expected: fmt.Sprintf(`[{"name":%q,"loaded":true,"entries":1,"size_bytes":4,"last_used":%q}]`, key, prt.lastUsed[key].Format(time.RFC3339Nano)),
+		},
+		{
+			name:   "purge without partition or prefix",
+			method: http.MethodPost,
+			target: "/purge",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "purge by prefix",
+			method:   http.MethodPost,
+			target:   "/purge?prefix=/user",
+			status:   http.StatusOK,
+			expected: `{"purged_entries":1}`,
+		},
+		{
+			name:   "gc with invalid days",
+			method: http.MethodPost,
+			target: "/gc?days=soon",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "gc without a day of unuse",
+			method: http.MethodPost,
+			target: "/gc?days=0",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "gc keeps recently used partitions",
+			method:   http.MethodPost,
+			target:   "/gc?days=3",
+			status:   http.StatusOK,
+			expected: `{"purged_partitions":[]}`,
+		},
+		{
+			name:     "gc removes unused partitions",
+			method:   http.MethodPost,
+			target:   "/gc?days=1",
+			status:   http.StatusOK,
+			expected: fmt.Sprintf(`{"purged_partitions":[%q]}`, key),
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodGet,
+			target: "/gc?days=1",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			admin.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, nil))
+			if rr.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rr.Code, rr.Body.String())
+			}
+			if tc.expected == "" {
+				return
+			}
+			var expected, actual interface{}
+			if err := json.Unmarshal([]byte(tc.expected), &expected); err != nil {
+				t.Fatalf("invalid expected response: %v", err)
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("invalid response %q: %v", rr.Body.String(), err)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("expected response %s, got %s", tc.expected, rr.Body.String())
+			}
+		})
+	}
+
+	if _, err := NewAdmin(http.DefaultTransport); err == nil {
+		t.Error("expected an error for a round tripper which isn't a GitHub cache")
+	}
+}
+
+// failingStore fails to remove partitions.
+type failingStore struct{}
+
+func (failingStore) list() (map[string]directoryUsage, error) {
+	return map[string]directoryUsage{"old": {modTime: time.Now().Add(-48 * time.Hour)}}, nil
+}
+
+func (failingStore) open(partitionKey string) httpcache.Cache {
+	return NewMemoryCache(0)
+}
+
+func (failingStore) remove(partitionKey string) error {
+	return errors.New("injected failure")
+}
+
+func TestAdminHTTPRemovalFailure(t *testing.T) {
+	admin, err := NewAdmin(NewMemCache(nil, 1, false, SchedulingOptions{}, 0))
+	if err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+	admin.prt.store = failingStore{}
+
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/gc?days=1", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d: %s", http.StatusInternalServerError, rr.Code, rr.Body.String())
+	}
+}
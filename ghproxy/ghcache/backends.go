@@ -0,0 +1,301 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/peterbourgon/diskv"
+	"github.com/sirupsen/logrus"
+)
+
+// InspectableCache is a httpcache.Cache whose entries can be listed. Cache
+// backends implementing it can be purged by path prefix through the admin
+// API, and report their size.
+type InspectableCache interface {
+	httpcache.Cache
+	// Keys returns the keys of all entries in the cache.
+	Keys() ([]string, error)
+	// Size returns the size of the cache in bytes.
+	Size() (int64, error)
+}
+
+// MemoryCache is an in-memory httpcache.Cache which evicts the least recently
+// used entries once it grows past its maximum size.
+type MemoryCache struct {
+	lock     sync.Mutex
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	// lru holds *memoryEntry values, most recently used first.
+	lru *list.List
+}
+
+type memoryEntry struct {
+	key   string
+	value []byte
+}
+
+var _ InspectableCache = &MemoryCache{}
+
+// NewMemoryCache creates a MemoryCache holding up to maxBytes of responses.
+// A maxBytes of zero or less means the cache is unbounded.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Get returns the response stored under key, if any.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*memoryEntry).value, true
+}
+
+// Set stores the response under key, evicting the least recently used
+// entries if the cache grows too big. Responses bigger than the whole cache
+// are not stored.
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.remove(key)
+	if c.maxBytes > 0 && int64(len(value)) > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&memoryEntry{key: key, value: value})
+	c.size += int64(len(value))
+	for c.maxBytes > 0 && c.size > c.maxBytes {
+		c.remove(c.lru.Back().Value.(*memoryEntry).key)
+	}
+}
+
+// Delete removes the response stored under key.
+func (c *MemoryCache) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.remove(key)
+}
+
+func (c *MemoryCache) remove(key string) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, key)
+	c.size -= int64(len(elem.Value.(*memoryEntry).value))
+}
+
+// Keys returns the keys of all entries in the cache.
+func (c *MemoryCache) Keys() ([]string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Size returns the total size of the responses in the cache.
+func (c *MemoryCache) Size() (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.size, nil
+}
+
+// keyIndexPrefix prefixes the files recording the key of each disk cache
+// entry, which are otherwise only known by their hash.
+const keyIndexPrefix = "key-"
+
+// DiskCache is a httpcache.Cache backed by diskv. It stores entries in the
+// same layout as httpcache's diskcache, so existing caches can be reused, and
+// additionally records the key of each entry so that they can be listed.
+// Entries written by diskcache are not listed, but are still served.
+type DiskCache struct {
+	d *diskv.Diskv
+}
+
+var _ InspectableCache = &DiskCache{}
+
+// NewDiskCacheWithDiskv creates a DiskCache storing its entries in d.
+func NewDiskCacheWithDiskv(d *diskv.Diskv) *DiskCache {
+	return &DiskCache{d: d}
+}
+
+func keyToFilename(key string) string {
+	h := md5.New()
+	h.Write([]byte(key))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the response stored under key, if any.
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	value, err := c.d.Read(keyToFilename(key))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Set stores the response under key.
+func (c *DiskCache) Set(key string, value []byte) {
+	filename := keyToFilename(key)
+	if err := c.d.WriteStream(filename, bytes.NewReader(value), true); err != nil {
+		logrus.WithField("cache-key", key).WithError(err).Warn("Failed to write disk cache entry.")
+		return
+	}
+	if err := c.d.Write(keyIndexPrefix+filename, []byte(key)); err != nil {
+		logrus.WithField("cache-key", key).WithError(err).Warn("Failed to index disk cache entry.")
+	}
+}
+
+// Delete removes the response stored under key.
+func (c *DiskCache) Delete(key string) {
+	filename := keyToFilename(key)
+	c.d.Erase(filename)
+	c.d.Erase(keyIndexPrefix + filename)
+}
+
+// Keys returns the keys of the entries written by a DiskCache.
+func (c *DiskCache) Keys() ([]string, error) {
+	var keys []string
+	for indexKey := range c.d.KeysPrefix(keyIndexPrefix, nil) {
+		key, err := c.d.Read(indexKey)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Deleted concurrently.
+				continue
+			}
+			return nil, err
+		}
+		keys = append(keys, string(key))
+	}
+	return keys, nil
+}
+
+// Size returns the size of the cache directory in bytes.
+func (c *DiskCache) Size() (int64, error) {
+	usage, err := diskUsage(c.d.BasePath)
+	return usage.size, err
+}
+
+// directoryUsage summarizes the files in a disk cache directory.
+type directoryUsage struct {
+	size    int64
+	entries int
+	// modTime is the newest modification time of the files.
+	modTime time.Time
+}
+
+// diskUsage walks a disk cache directory. Missing directories are empty.
+func diskUsage(dir string) (directoryUsage, error) {
+	var usage directoryUsage
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		usage.size += info.Size()
+		if !strings.HasPrefix(info.Name(), keyIndexPrefix) {
+			usage.entries++
+		}
+		if info.ModTime().After(usage.modTime) {
+			usage.modTime = info.ModTime()
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// TieredCache is a httpcache.Cache which serves entries from a fast front
+// cache, falling back to a bigger back cache. Entries found in the back
+// cache are copied to the front cache.
+type TieredCache struct {
+	front httpcache.Cache
+	back  httpcache.Cache
+}
+
+var _ InspectableCache = &TieredCache{}
+
+// NewTieredCache creates a TieredCache, e.g. with a bounded MemoryCache in
+// front of a DiskCache.
+func NewTieredCache(front, back httpcache.Cache) *TieredCache {
+	return &TieredCache{front: front, back: back}
+}
+
+// Get returns the response stored under key, if any.
+func (c *TieredCache) Get(key string) ([]byte, bool) {
+	if value, ok := c.front.Get(key); ok {
+		return value, true
+	}
+	value, ok := c.back.Get(key)
+	if ok {
+		c.front.Set(key, value)
+	}
+	return value, ok
+}
+
+// Set stores the response under key in both tiers.
+func (c *TieredCache) Set(key string, value []byte) {
+	c.back.Set(key, value)
+	c.front.Set(key, value)
+}
+
+// Delete removes the response stored under key from both tiers.
+func (c *TieredCache) Delete(key string) {
+	c.front.Delete(key)
+	c.back.Delete(key)
+}
+
+// Keys returns the keys of the back cache, which holds all entries.
+func (c *TieredCache) Keys() ([]string, error) {
+	if back, ok := c.back.(InspectableCache); ok {
+		return back.Keys()
+	}
+	return nil, errNotInspectable
+}
+
+// Size returns the size of the back cache.
+func (c *TieredCache) Size() (int64, error) {
+	if back, ok := c.back.(InspectableCache); ok {
+		return back.Size()
+	}
+	return 0, errNotInspectable
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gregjones/httpcache/diskcache"
+	"github.com/peterbourgon/diskv"
+)
+
+func sortedKeys(t *testing.T, cache InspectableCache) []string {
+	keys, err := cache.Keys()
+	if err != nil {
+		t.Fatalf("failed to list keys: %v", err)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	cache := NewMemoryCache(10)
+	cache.Set("a", []byte("1234"))
+	cache.Set("b", []byte("1234"))
+	// Using a makes b the least recently used entry.
+	if value, ok := cache.Get("a"); !ok || string(value) != "1234" {
+		t.Fatalf("expected a to be cached, got %q, %t", value, ok)
+	}
+	cache.Set("c", []byte("1234"))
+
+	if expected, actual := []string{"a", "c"}, sortedKeys(t, cache); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected keys %v, got %v", expected, actual)
+	}
+	if size, _ := cache.Size(); size != 8 {
+		t.Errorf("expected size 8, got %d", size)
+	}
+
+	cache.Set("big", []byte("12345678901"))
+	if _, ok := cache.Get("big"); ok {
+		t.Error("expected responses bigger than the cache not to be stored")
+	}
+	cache.Delete("a")
+	if size, _ := cache.Size(); size != 4 {
+		t.Errorf("expected size 4 after deleting a, got %d", size)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghcache")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	d := diskv.New(diskv.Options{BasePath: path.Join(dir, "data"), TempDir: path.Join(dir, "temp")})
+
+	// Entries written by httpcache's diskcache are still served.
+	diskcache.NewWithDiskv(d).Set("https://api.github.com/legacy", []byte("legacy"))
+	cache := NewDiskCacheWithDiskv(d)
+	if value, ok := cache.Get("https://api.github.com/legacy"); !ok || string(value) != "legacy" {
+		t.Errorf("expected legacy entry to be served, got %q, %t", value, ok)
+	}
+
+	cache.Set("https://api.github.com/repos/org/repo", []byte("repo"))
+	cache.Set("https://api.github.com/user", []byte("user"))
+	if expected, actual := []string{"https://api.github.com/repos/org/repo", "https://api.github.com/user"}, sortedKeys(t, cache); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected keys %v, got %v", expected, actual)
+	}
+
+	cache.Delete("https://api.github.com/user")
+	if _, ok := cache.Get("https://api.github.com/user"); ok {
+		t.Error("expected deleted entry not to be served")
+	}
+	if expected, actual := []string{"https://api.github.com/repos/org/repo"}, sortedKeys(t, cache); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected keys %v after delete, got %v", expected, actual)
+	}
+
+	usage, err := diskUsage(path.Join(dir, "data"))
+	if err != nil {
+		t.Fatalf("failed to compute disk usage: %v", err)
+	}
+	if usage.entries != 2 {
+		t.Errorf("expected the legacy and the indexed entry to be counted, got %d", usage.entries)
+	}
+	if size, _ := cache.Size(); size != usage.size || size == 0 {
+		t.Errorf("expected size %d, got %d", usage.size, size)
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	front, back := NewMemoryCache(0), NewMemoryCache(0)
+	cache := NewTieredCache(front, back)
+
+	back.Set("old", []byte("old"))
+	if value, ok := cache.Get("old"); !ok || string(value) != "old" {
+		t.Errorf("expected entry from the back cache, got %q, %t", value, ok)
+	}
+	if _, ok := front.Get("old"); !ok {
+		t.Error("expected entry from the back cache to be promoted to the front cache")
+	}
+
+	cache.Set("new", []byte("new"))
+	for name, tier := range map[string]*MemoryCache{"front": front, "back": back} {
+		if _, ok := tier.Get("new"); !ok {
+			t.Errorf("expected entry to be stored in the %s cache", name)
+		}
+	}
+
+	cache.Delete("old")
+	for name, tier := range map[string]*MemoryCache{"front": front, "back": back} {
+		if _, ok := tier.Get("old"); ok {
+			t.Errorf("expected entry to be deleted from the %s cache", name)
+		}
+	}
+	if expected, actual := []string{"new"}, sortedKeys(t, cache); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected keys %v, got %v", expected, actual)
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -40,6 +39,9 @@ type requestCoalescer struct {
 	delegate http.RoundTripper
 
 	hasher ghmetrics.Hasher
+
+	// coalesceGraphQL enables coalescing identical GraphQL queries.
+	coalesceGraphQL bool
 }
 
 type responseWaiter struct {
@@ -52,16 +54,18 @@ type responseWaiter struct {
 
 // RoundTrip coalesces concurrent GET requests for the same URI by blocking
 // the later requests until the first request returns and then sharing the
-// response between all requests.
+// response between all requests. If enabled, concurrent GraphQL queries with
+// the same body are coalesced the same way.
 //
 // Notes: Deadlock shouldn't be possible because the map lock is always
 // acquired before responseWaiter lock if both locks are to be held and we
 // never hold multiple responseWaiter locks.
 func (r *requestCoalescer) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Only coalesce GET requests
-	if req.Method != http.MethodGet {
+	key, coalesce := r.coalescingKey(req)
+	// Only coalesce GET requests and GraphQL queries
+	if !coalesce {
 		resp, err := r.delegate.RoundTrip(req)
-		if strings.HasPrefix(req.URL.Path, "graphql") || strings.HasPrefix(req.URL.Path, "/graphql") {
+		if isGraphQL(req.URL.Path) {
 			var tokenBudgetName string
 			if val := req.Header.Get(TokenBudgetIdentifierHeader); val != "" {
 				tokenBudgetName = val
@@ -75,7 +79,6 @@ func (r *requestCoalescer) RoundTrip(req *http.Request) (*http.Response, error)
 
 	var cacheMode = ModeError
 	resp, err := func() (*http.Response, error) {
-		key := req.URL.String()
 		r.Lock()
 		waiter, ok := r.keys[key]
 		if ok {
@@ -155,6 +158,18 @@ func (r *requestCoalescer) RoundTrip(req *http.Request) (*http.Response, error)
 	return resp, err
 }
 
+// coalescingKey returns the key under which concurrent requests are coalesced
+// and whether the request may be coalesced at all.
+func (r *requestCoalescer) coalescingKey(req *http.Request) (string, bool) {
+	if req.Method == http.MethodGet {
+		return req.URL.String(), true
+	}
+	if r.coalesceGraphQL && req.Method == http.MethodPost && isGraphQL(req.URL.Path) {
+		return graphQLCoalescingKey(req)
+	}
+	return "", false
+}
+
 func collectMetrics(cacheMode CacheResponseMode, req *http.Request, resp *http.Response, tokenBudgetName string) {
 	ghmetrics.CollectCacheRequestMetrics(string(cacheMode), req.URL.Path, req.Header.Get("User-Agent"), tokenBudgetName)
 	if resp != nil {
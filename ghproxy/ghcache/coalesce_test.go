@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"k8s.io/test-infra/ghproxy/ghmetrics"
 	"net/http"
@@ -219,12 +220,104 @@ func TestCacheModeHeader(t *testing.T) {
 	}
 }
 
+func TestRoundTripGraphQL(t *testing.T) {
+	t.Parallel()
+	const (
+		query       = `{"query":"query($q:String!){search(query:$q){issueCount}}","variables":{"q":"is:pr"}}`
+		otherQuery  = `{"query":"query($q:String!){search(query:$q){issueCount}}","variables":{"q":"is:issue"}}`
+		mutation    = `{"query":"mutation{addComment(input:{}){clientMutationId}}"}`
+		invalidBody = `not json`
+	)
+	testCases := []struct {
+		name            string
+		coalesceGraphQL bool
+		bodies          []string
+		expectedHits    int
+	}{
+		{
+			name:            "identical queries are coalesced",
+			coalesceGraphQL: true,
+			bodies:          []string{query, query, query},
+			expectedHits:    1,
+		},
+		{
+			name:            "queries with different variables are not coalesced",
+			coalesceGraphQL: true,
+			bodies:          []string{query, otherQuery, query},
+			expectedHits:    2,
+		},
+		{
+			name:            "mutations are not coalesced",
+			coalesceGraphQL: true,
+			bodies:          []string{mutation, mutation},
+			expectedHits:    2,
+		},
+		{
+			name:            "unparseable requests are not coalesced",
+			coalesceGraphQL: true,
+			bodies:          []string{invalidBody, invalidBody},
+			expectedHits:    2,
+		},
+		{
+			name:         "queries are not coalesced unless enabled",
+			bodies:       []string{query, query},
+			expectedHits: 2,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			delegate := &testDelegate{
+				hits:            make(map[string]int),
+				beginResponding: sync.NewCond(&sync.Mutex{}),
+			}
+			coalesce := &requestCoalescer{
+				keys:            make(map[string]*responseWaiter),
+				delegate:        delegate,
+				hasher:          ghmetrics.NewCachingHasher(),
+				coalesceGraphQL: tc.coalesceGraphQL,
+			}
+			wg := sync.WaitGroup{}
+			wg.Add(len(tc.bodies))
+			for _, body := range tc.bodies {
+				body := body
+				go func() {
+					if _, err := runPostRequest(coalesce, "/graphql", body, false); err != nil {
+						t.Errorf("Failed to run request: %v.", err)
+					}
+					wg.Done()
+				}()
+			}
+			// Same race as in TestRoundTrip: wait for all requests to reach the
+			// coalescer before letting upstream respond.
+			time.Sleep(time.Second * 3)
+			delegate.beginResponding.Broadcast()
+			wg.Wait()
+
+			if hits := delegate.hits["/graphql"]; hits != tc.expectedHits {
+				t.Errorf("Expected %d upstream requests, got %d.", tc.expectedHits, hits)
+			}
+		})
+	}
+}
+
 func runRequest(rt http.RoundTripper, uri string, immediate bool) (*http.Response, error) {
+	return runPostRequest(rt, uri, "", immediate)
+}
+
+// runPostRequest runs a POST request with the given body, or a GET request
+// if the body is empty.
+func runPostRequest(rt http.RoundTripper, uri, body string, immediate bool) (*http.Response, error) {
 	u, err := url.Parse("http://foo.com" + uri)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	method, reqBody := http.MethodGet, io.Reader(nil)
+	if body != "" {
+		method, reqBody = http.MethodPost, bytes.NewBufferString(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
 	if err != nil {
 		return nil, err
 	}
@@ -21,12 +21,13 @@ limitations under the License.
 // with a conditional request to upstream regardless of cache entry freshness
 // because conditional requests for unchanged resources don't cost any API
 // tokens!!! See: https://developer.github.com/v3/#conditional-requests
+// Optionally, repository resources are instead served without revalidation
+// within a short fresh window unless a webhook event invalidated them.
 //
 // It also provides request coalescing and prometheus instrumentation.
 package ghcache
 
 import (
-	"context"
 	"crypto/sha256"
 	"fmt"
 	"net/http"
@@ -37,12 +38,10 @@ import (
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/gregjones/httpcache"
-	"github.com/gregjones/httpcache/diskcache"
 	rediscache "github.com/gregjones/httpcache/redis"
 	"github.com/peterbourgon/diskv"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/sync/semaphore"
 	"k8s.io/test-infra/ghproxy/ghmetrics"
 )
 
@@ -60,6 +59,7 @@ const (
 	// free (no API tokens used).
 	ModeCoalesced   CacheResponseMode = "COALESCED"   // coalesced request, this is a copied response
 	ModeRevalidated CacheResponseMode = "REVALIDATED" // cached value revalidated and returned
+	ModeFresh       CacheResponseMode = "FRESH"       // cached value returned without revalidation within the fresh window
 
 	// cacheEntryCreationDateHeader contains the creation date of the cache entry
 	cacheEntryCreationDateHeader = "X-PROW-REQUEST-DATE"
@@ -76,6 +76,8 @@ func CacheModeIsFree(mode CacheResponseMode) bool {
 		return true
 	case ModeRevalidated:
 		return true
+	case ModeFresh:
+		return true
 	case ModeError:
 		// In this case we did not successfully communicate with the GH API, so no
 		// token is used, but we also don't return a response, so ModeError won't
@@ -115,6 +117,9 @@ func init() {
 }
 
 func cacheResponseMode(headers http.Header) CacheResponseMode {
+	if headers.Get(CacheModeHeader) == string(ModeFresh) {
+		return ModeFresh
+	}
 	if strings.Contains(headers.Get("Cache-Control"), "no-store") {
 		return ModeNoStore
 	}
@@ -127,23 +132,38 @@ func cacheResponseMode(headers http.Header) CacheResponseMode {
 	return ModeMiss
 }
 
-func newThrottlingTransport(maxConcurrency int, delegate http.RoundTripper) http.RoundTripper {
-	return &throttlingTransport{sem: semaphore.NewWeighted(int64(maxConcurrency)), delegate: delegate}
+func newThrottlingTransport(sem *prioritySemaphore, scheduling SchedulingOptions, budgets *tokenBudgets, hasher ghmetrics.Hasher, delegate http.RoundTripper) http.RoundTripper {
+	return &throttlingTransport{sem: sem, scheduling: scheduling, budgets: budgets, hasher: hasher, delegate: delegate}
 }
 
-// throttlingTransport throttles outbound concurrency from the proxy
+// throttlingTransport throttles outbound concurrency from the proxy, giving
+// precedence to higher priority requests, and holds back lower priority
+// requests when their token budget runs low.
 type throttlingTransport struct {
-	sem      *semaphore.Weighted
-	delegate http.RoundTripper
+	sem        *prioritySemaphore
+	scheduling SchedulingOptions
+	budgets    *tokenBudgets
+	hasher     ghmetrics.Hasher
+	delegate   http.RoundTripper
 }
 
 func (c *throttlingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	priority := c.scheduling.priorityFor(req)
+	retryAfter, err := c.holdForBudget(req, tokenBudgetName(req, c.hasher), priority)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return budgetExhaustedResponse(req, retryAfter, priority), nil
+	}
+
 	pendingOutboundConnectionsGauge.Inc()
-	if err := c.sem.Acquire(context.Background(), 1); err != nil {
-		logrus.WithField("cache-key", req.URL.String()).WithError(err).Error("Internal error acquiring semaphore.")
+	if err := c.sem.acquire(req.Context(), priority); err != nil {
+		pendingOutboundConnectionsGauge.Dec()
+		logrus.WithField("cache-key", req.URL.String()).WithError(err).Warn("Request cancelled while waiting for the concurrency limit.")
 		return nil, err
 	}
-	defer c.sem.Release(1)
+	defer c.sem.release()
 	pendingOutboundConnectionsGauge.Dec()
 	outboundConcurrencyGauge.Inc()
 	defer outboundConcurrencyGauge.Dec()
@@ -160,20 +180,29 @@ func (c *throttlingTransport) RoundTrip(req *http.Request) (*http.Response, erro
 // modified times so this RoundTripper overrides response headers to:
 //    Cache-Control: no-cache
 // This instructs the cache to store the response, but always consider it stale.
+// If freshWindow is set, responses for the repository resources matched by
+// freshPaths are instead considered fresh for that long:
+//    Cache-Control: max-age=<freshWindow>
+// unless they are invalidated, see freshnessTransport.
 type upstreamTransport struct {
-	delegate http.RoundTripper
-	hasher   ghmetrics.Hasher
+	delegate    http.RoundTripper
+	hasher      ghmetrics.Hasher
+	budgets     *tokenBudgets
+	freshWindow time.Duration
 }
 
 func (u upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	etag := req.Header.Get("if-none-match")
-	var tokenBudgetName string
-	if val := req.Header.Get(TokenBudgetIdentifierHeader); val != "" {
-		tokenBudgetName = val
-	} else {
-		tokenBudgetName = u.hasher.Hash(req)
+	tokenBudgetName := tokenBudgetName(req, u.hasher)
+
+	queryName := unknownQueryName
+	if isGraphQL(req.URL.Path) {
+		if gql, _, err := readGraphQLRequest(req); err == nil {
+			queryName = gql.name()
+		}
 	}
 
+	markUpstreamCalled(req)
 	reqStartTime := time.Now()
 	// Don't modify request, just pass to delegate.
 	resp, err := u.delegate.RoundTrip(req)
@@ -189,7 +218,11 @@ func (u upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error)
 		// Don't store errors. They can't be revalidated to save API tokens.
 		resp.Header.Set("Cache-Control", "no-store")
 	} else {
-		resp.Header.Set("Cache-Control", "no-cache")
+		if u.freshWindow > 0 && req.Method == http.MethodGet && isFreshPath(req.URL.Path) {
+			resp.Header.Set("Cache-Control", fmt.Sprintf("max-age=%d", int(u.freshWindow.Seconds())))
+		} else {
+			resp.Header.Set("Cache-Control", "no-cache")
+		}
 		if resp.StatusCode != http.StatusNotModified {
 			// Used for metrics about the age of cached requests
 			resp.Header.Set(cacheEntryCreationDateHeader, strconv.Itoa(int(time.Now().Unix())))
@@ -199,18 +232,28 @@ func (u upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error)
 		resp.Header.Set("X-Conditional-Request", etag)
 	}
 
-	apiVersion := "v3"
-	if strings.HasPrefix(req.URL.Path, "graphql") || strings.HasPrefix(req.URL.Path, "/graphql") {
+	apiVersion := apiVersion(req.URL.Path)
+	if isGraphQL(req.URL.Path) {
 		resp.Header.Set("Cache-Control", "no-store")
-		apiVersion = "v4"
+		cost, costReported := graphQLCost(resp)
+		ghmetrics.CollectGraphQLQueryMetrics(tokenBudgetName, queryName, req.Header.Get("User-Agent"), cost, costReported)
 	}
 
+	u.budgets.update(tokenBudgetName, apiVersion, resp.Header)
 	ghmetrics.CollectGitHubTokenMetrics(tokenBudgetName, apiVersion, resp.Header, reqStartTime, responseTime)
 	ghmetrics.CollectGitHubRequestMetrics(tokenBudgetName, req.URL.Path, strconv.Itoa(resp.StatusCode), req.Header.Get("User-Agent"), roundTripTime.Seconds())
 
 	return resp, nil
 }
 
+// tokenBudgetName identifies the token budget used by the request.
+func tokenBudgetName(req *http.Request, hasher ghmetrics.Hasher) string {
+	if val := req.Header.Get(TokenBudgetIdentifierHeader); val != "" {
+		return val
+	}
+	return hasher.Hash(req)
+}
+
 func authHeaderHash(req *http.Request) string {
 	// get authorization header to convert to sha256
 	authHeader := req.Header.Get("Authorization")
@@ -226,9 +269,17 @@ const LogMessageWithDiskPartitionFields = "Not using a partitioned cache because
 // NewDiskCache creates a GitHub cache RoundTripper that is backed by a disk
 // cache.
 // It supports a partitioned cache.
-func NewDiskCache(delegate http.RoundTripper, cacheDir string, cacheSizeGB, maxConcurrency int, legacyDisablePartitioningByAuthHeader bool) http.RoundTripper {
+// If memoryTierSizeMB is positive, each partition keeps up to that many MB of
+// its most recently used responses in memory in front of the disk cache.
+func NewDiskCache(delegate http.RoundTripper, cacheDir string, cacheSizeGB, memoryTierSizeMB, maxConcurrency int, legacyDisablePartitioningByAuthHeader, coalesceGraphQL bool, scheduling SchedulingOptions, freshWindow time.Duration) http.RoundTripper {
+	tiered := func(cache httpcache.Cache) httpcache.Cache {
+		if memoryTierSizeMB <= 0 {
+			return cache
+		}
+		return NewTieredCache(NewMemoryCache(int64(memoryTierSizeMB)*1000000), cache) // convert M to B
+	}
 	if legacyDisablePartitioningByAuthHeader {
-		diskCache := diskcache.NewWithDiskv(
+		diskCache := NewDiskCacheWithDiskv(
 			diskv.New(diskv.Options{
 				BasePath:     path.Join(cacheDir, "data"),
 				TempDir:      path.Join(cacheDir, "temp"),
@@ -239,49 +290,88 @@ func NewDiskCache(delegate http.RoundTripper, cacheDir string, cacheSizeGB, maxC
 				logrus.WithField("cache-base-path", path.Join(cacheDir, "data", partitionKey)).
 					WithField("cache-temp-path", path.Join(cacheDir, "temp", partitionKey)).
 					Warning(LogMessageWithDiskPartitionFields)
-				return diskCache
+				return tiered(diskCache)
 			},
 			maxConcurrency,
+			coalesceGraphQL,
+			scheduling,
+			freshWindow,
 		)
 	}
-	return NewFromCache(delegate,
+	partitionCache := func(partitionKey string) httpcache.Cache {
+		return NewDiskCacheWithDiskv(
+			diskv.New(diskv.Options{
+				BasePath:     path.Join(cacheDir, "data", partitionKey),
+				TempDir:      path.Join(cacheDir, "temp", partitionKey),
+				CacheSizeMax: uint64(cacheSizeGB) * uint64(1000000000), // convert G to B
+			}))
+	}
+	return newFromCache(delegate,
 		func(partitionKey string) httpcache.Cache {
-			return diskcache.NewWithDiskv(
-				diskv.New(diskv.Options{
-					BasePath:     path.Join(cacheDir, "data", partitionKey),
-					TempDir:      path.Join(cacheDir, "temp", partitionKey),
-					CacheSizeMax: uint64(cacheSizeGB) * uint64(1000000000), // convert G to B
-				}))
+			return tiered(partitionCache(partitionKey))
 		},
+		diskPartitionStore{cacheDir: cacheDir, newCache: partitionCache},
 		maxConcurrency,
+		coalesceGraphQL,
+		scheduling,
+		freshWindow,
 	)
 }
 
 // NewMemCache creates a GitHub cache RoundTripper that is backed by a memory
 // cache.
 // It supports a partitioned cache.
-func NewMemCache(delegate http.RoundTripper, maxConcurrency int) http.RoundTripper {
+func NewMemCache(delegate http.RoundTripper, maxConcurrency int, coalesceGraphQL bool, scheduling SchedulingOptions, freshWindow time.Duration) http.RoundTripper {
 	return NewFromCache(delegate,
-		func(_ string) httpcache.Cache { return httpcache.NewMemoryCache() },
-		maxConcurrency)
+		func(_ string) httpcache.Cache { return NewMemoryCache(0) },
+		maxConcurrency,
+		coalesceGraphQL,
+		scheduling,
+		freshWindow)
 }
 
-// CachePartitionCreator creates a new cache partition using the given key
+// CachePartitionCreator creates a new cache partition using the given key.
+// Partitions implementing InspectableCache can be purged by path prefix
+// through the Admin.
 type CachePartitionCreator func(partitionKey string) httpcache.Cache
 
 // NewFromCache creates a GitHub cache RoundTripper that is backed by the
 // specified httpcache.Cache implementation.
-func NewFromCache(delegate http.RoundTripper, cache CachePartitionCreator, maxConcurrency int) http.RoundTripper {
+// If coalesceGraphQL is set, concurrent identical GraphQL queries share a
+// single upstream request, like concurrent GET requests do.
+// The scheduling options control the order in which requests exceeding
+// maxConcurrency are sent, and which requests are held back when their token
+// budget runs low.
+// If freshWindow is set, responses for the repository resources invalidated by
+// webhook events are served without revalidation for that long, unless they are
+// invalidated through the Admin or by a write through the cache.
+func NewFromCache(delegate http.RoundTripper, cache CachePartitionCreator, maxConcurrency int, coalesceGraphQL bool, scheduling SchedulingOptions, freshWindow time.Duration) http.RoundTripper {
+	return newFromCache(delegate, cache, nil, maxConcurrency, coalesceGraphQL, scheduling, freshWindow)
+}
+
+// newFromCache is NewFromCache for caches whose partitions are persisted in
+// the store, if it is set.
+func newFromCache(delegate http.RoundTripper, cache CachePartitionCreator, store partitionStore, maxConcurrency int, coalesceGraphQL bool, scheduling SchedulingOptions, freshWindow time.Duration) http.RoundTripper {
 	hasher := ghmetrics.NewCachingHasher()
-	return newPartitioningRoundTripper(func(partitionKey string) http.RoundTripper {
-		cacheTransport := httpcache.NewTransport(cache(partitionKey))
-		cacheTransport.Transport = newThrottlingTransport(maxConcurrency, upstreamTransport{delegate: delegate, hasher: hasher})
-		return &requestCoalescer{
-			keys:     make(map[string]*responseWaiter),
-			delegate: cacheTransport,
-			hasher:   hasher,
+	budgets := newTokenBudgets()
+	invalidations := newInvalidations(freshWindow)
+	prt := newPartitioningRoundTripper(func(partitionKey string) http.RoundTripper {
+		partitionCache := cache(partitionKey)
+		cacheTransport := httpcache.NewTransport(partitionCache)
+		cacheTransport.Transport = newThrottlingTransport(newPrioritySemaphore(maxConcurrency), scheduling, budgets, hasher, upstreamTransport{delegate: delegate, hasher: hasher, budgets: budgets, freshWindow: freshWindow})
+		return &partitionTransport{
+			RoundTripper: &requestCoalescer{
+				keys:            make(map[string]*responseWaiter),
+				delegate:        newFreshnessTransport(invalidations, cacheTransport),
+				hasher:          hasher,
+				coalesceGraphQL: coalesceGraphQL,
+			},
+			cache: partitionCache,
 		}
 	})
+	prt.store = store
+	prt.invalidations = invalidations
+	return prt
 }
 
 // NewRedisCache creates a GitHub cache RoundTripper that is backed by a Redis
@@ -289,7 +379,7 @@ func NewFromCache(delegate http.RoundTripper, cache CachePartitionCreator, maxCo
 // Important note: The redis implementation does not support partitioning the cache
 // which means that requests to the same path from different tokens will invalidate
 // each other.
-func NewRedisCache(delegate http.RoundTripper, redisAddress string, maxConcurrency int) http.RoundTripper {
+func NewRedisCache(delegate http.RoundTripper, redisAddress string, maxConcurrency int, coalesceGraphQL bool, scheduling SchedulingOptions, freshWindow time.Duration) http.RoundTripper {
 	conn, err := redis.Dial("tcp", redisAddress)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error connecting to Redis")
@@ -297,5 +387,8 @@ func NewRedisCache(delegate http.RoundTripper, redisAddress string, maxConcurren
 	redisCache := rediscache.NewWithClient(conn)
 	return NewFromCache(delegate,
 		func(_ string) httpcache.Cache { return redisCache },
-		maxConcurrency)
+		maxConcurrency,
+		coalesceGraphQL,
+		scheduling,
+		freshWindow)
 }
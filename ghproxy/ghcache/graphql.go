@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// unknownQueryName is used for GraphQL requests that can't be parsed.
+const unknownQueryName = "unknown"
+
+// operationRegex matches the operation type and optional name at the start
+// of a GraphQL document.
+var operationRegex = regexp.MustCompile(`^(query|mutation|subscription)\b\s*([_A-Za-z][_0-9A-Za-z]*)?`)
+
+// graphQLRequest is the body of a GraphQL request.
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+}
+
+// graphQLRateLimit is the part of a GraphQL response reporting the rate
+// limit points spent on the query, if the query selected them.
+type graphQLRateLimit struct {
+	Data struct {
+		RateLimit *struct {
+			Cost int `json:"cost"`
+		} `json:"rateLimit"`
+	} `json:"data"`
+}
+
+func isGraphQL(path string) bool {
+	return strings.HasPrefix(path, "graphql") || strings.HasPrefix(path, "/graphql")
+}
+
+// readGraphQLRequest parses the GraphQL request in the body of req. The body
+// is restored so that the request can still be sent. The raw body is returned
+// alongside the parsed request.
+func readGraphQLRequest(req *http.Request) (*graphQLRequest, []byte, error) {
+	if req.Body == nil {
+		return nil, nil, fmt.Errorf("request has no body")
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	var gql graphQLRequest
+	if err := json.Unmarshal(body, &gql); err != nil {
+		return nil, body, err
+	}
+	return &gql, body, nil
+}
+
+// operationType returns the type of the operation in the request, which is
+// "query" for the shorthand syntax.
+func (g *graphQLRequest) operationType() string {
+	query := strings.TrimSpace(g.Query)
+	if strings.HasPrefix(query, "{") {
+		return "query"
+	}
+	if match := operationRegex.FindStringSubmatch(query); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// name returns a name identifying the query for metrics. It is the operation
+// name if there is one, otherwise the first top-level field selected other
+// than rateLimit, since most clients, e.g. shurcooL/githubv4, send anonymous
+// queries.
+func (g *graphQLRequest) name() string {
+	if g.OperationName != "" {
+		return g.OperationName
+	}
+	query := strings.TrimSpace(g.Query)
+	if match := operationRegex.FindStringSubmatch(query); match != nil && match[2] != "" {
+		return match[2]
+	}
+	for _, field := range topLevelFields(query) {
+		if field != "rateLimit" {
+			return field
+		}
+	}
+	return unknownQueryName
+}
+
+// topLevelFields returns the fields of the top-level selection set of a
+// GraphQL document, in order. Aliases are resolved to the field they alias.
+func topLevelFields(query string) []string {
+	var fields []string
+	depth, parens := 0, 0
+	inString := false
+	aliased := false
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inString:
+			if r == '\\' {
+				i++
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			inString = true
+		case r == '(':
+			parens++
+		case r == ')':
+			parens--
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case depth == 1 && parens == 0 && r == ':':
+			aliased = true
+		case depth == 1 && parens == 0 && (r == '_' || unicode.IsLetter(r)):
+			start := i
+			for i+1 < len(runes) && (runes[i+1] == '_' || unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])) {
+				i++
+			}
+			word := string(runes[start : i+1])
+			if (start >= 3 && string(runes[start-3:start]) == "...") || word == "on" {
+				// Fragment spreads and inline fragments are not fields.
+				continue
+			}
+			if aliased && len(fields) > 0 {
+				fields[len(fields)-1] = word
+				aliased = false
+				continue
+			}
+			fields = append(fields, word)
+		}
+	}
+	return fields
+}
+
+// graphQLCoalescingKey returns the key under which identical GraphQL queries are
+// coalesced. Mutations must always reach GitHub, so they are never coalesced.
+func graphQLCoalescingKey(req *http.Request) (string, bool) {
+	gql, body, err := readGraphQLRequest(req)
+	if err != nil || gql.operationType() != "query" {
+		return "", false
+	}
+	return fmt.Sprintf("%s %x", req.URL.String(), sha256.Sum256(body)), true
+}
+
+// graphQLCost reads the rate limit cost reported in the body of a GraphQL
+// response. The body is restored so that it can still be returned.
+func graphQLCost(resp *http.Response) (int, bool) {
+	if resp.Body == nil {
+		return 0, false
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, false
+	}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return 0, false
+		}
+		if body, err = ioutil.ReadAll(zr); err != nil {
+			return 0, false
+		}
+	}
+	var rateLimit graphQLRateLimit
+	if err := json.Unmarshal(body, &rateLimit); err != nil || rateLimit.Data.RateLimit == nil {
+		return 0, false
+	}
+	return rateLimit.Data.RateLimit.Cost, true
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestGraphQLRequest(t *testing.T) {
+	testCases := []struct {
+		name          string
+		request       graphQLRequest
+		expectedType  string
+		expectedName  string
+		expectedError bool
+	}{
+		{
+			name:         "anonymous query from githubv4",
+			request:      graphQLRequest{Query: `query($query:String!$searchCursor:String){rateLimit{cost,remaining},search(type: ISSUE, first: 37, after: $searchCursor, query: $query){pageInfo{hasNextPage,endCursor}}}`},
+			expectedType: "query",
+			expectedName: "search",
+		},
+		{
+			name:         "named query",
+			request:      graphQLRequest{Query: "query Tide($q: String!) {\n  search(query: $q) { issueCount }\n}"},
+			expectedType: "query",
+			expectedName: "Tide",
+		},
+		{
+			name:         "operation name takes precedence",
+			request:      graphQLRequest{Query: "query Tide { search { issueCount } }", OperationName: "TideSearch"},
+			expectedType: "query",
+			expectedName: "TideSearch",
+		},
+		{
+			name:         "shorthand query",
+			request:      graphQLRequest{Query: `{ viewer { login } }`},
+			expectedType: "query",
+			expectedName: "viewer",
+		},
+		{
+			name:         "aliased field",
+			request:      graphQLRequest{Query: `{ me: viewer { login } }`},
+			expectedType: "query",
+			expectedName: "viewer",
+		},
+		{
+			name:         "string arguments are skipped",
+			request:      graphQLRequest{Query: `{ repository(owner: "a{b", name: "c") { id } }`},
+			expectedType: "query",
+			expectedName: "repository",
+		},
+		{
+			name:         "mutation",
+			request:      graphQLRequest{Query: `mutation($input: AddCommentInput!){addComment(input: $input){clientMutationId}}`},
+			expectedType: "mutation",
+			expectedName: "addComment",
+		},
+		{
+			name:         "only rate limit",
+			request:      graphQLRequest{Query: `{ rateLimit { remaining } }`},
+			expectedType: "query",
+			expectedName: unknownQueryName,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.request.operationType(); actual != tc.expectedType {
+				t.Errorf("expected operation type %q, got %q", tc.expectedType, actual)
+			}
+			if actual := tc.request.name(); actual != tc.expectedName {
+				t.Errorf("expected name %q, got %q", tc.expectedName, actual)
+			}
+		})
+	}
+}
+
+func TestReadGraphQLRequest(t *testing.T) {
+	body := `{"query":"{ viewer { login } }","variables":{"a":1}}`
+	req, err := http.NewRequest(http.MethodPost, "https://api.github.com/graphql", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	gql, raw, err := readGraphQLRequest(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gql.Query != "{ viewer { login } }" || string(raw) != body {
+		t.Errorf("unexpected request %+v with body %q", gql, raw)
+	}
+	restored, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("failed to read restored body: %v", err)
+	}
+	if string(restored) != body {
+		t.Errorf("expected restored body %q, got %q", body, restored)
+	}
+}
+
+func TestGraphQLCost(t *testing.T) {
+	gzipped := func(s string) string {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		w.Write([]byte(s))
+		w.Close()
+		return b.String()
+	}
+	testCases := []struct {
+		name             string
+		body             string
+		gzip             bool
+		expectedCost     int
+		expectedReported bool
+	}{
+		{
+			name:             "cost reported",
+			body:             `{"data":{"rateLimit":{"cost":3,"remaining":4997},"search":{}}}`,
+			expectedCost:     3,
+			expectedReported: true,
+		},
+		{
+			name:             "gzipped response",
+			body:             `{"data":{"rateLimit":{"cost":1}}}`,
+			gzip:             true,
+			expectedCost:     1,
+			expectedReported: true,
+		},
+		{
+			name: "rate limit not selected",
+			body: `{"data":{"viewer":{"login":"bot"}}}`,
+		},
+		{
+			name: "error response",
+			body: `{"errors":[{"message":"boom"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := tc.body
+			resp := &http.Response{Header: http.Header{}}
+			if tc.gzip {
+				body = gzipped(body)
+				resp.Header.Set("Content-Encoding", "gzip")
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewBufferString(body))
+			cost, reported := graphQLCost(resp)
+			if cost != tc.expectedCost || reported != tc.expectedReported {
+				t.Errorf("expected cost %d (reported: %t), got %d (reported: %t)", tc.expectedCost, tc.expectedReported, cost, reported)
+			}
+			restored, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read restored body: %v", err)
+			}
+			if string(restored) != body {
+				t.Errorf("expected the response body to be restored")
+			}
+		})
+	}
+}
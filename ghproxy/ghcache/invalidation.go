@@ -0,0 +1,222 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gregjones/httpcache"
+)
+
+// freshPaths matches the paths served without revalidation within the fresh
+// window. These are the repository resources invalidated by the events the
+// ghproxy-invalidator plugin forwards. Everything else, like collaborators
+// and their permission, hooks, deploy keys, comments fetched by ID or the
+// pull requests of a commit, is always revalidated since no event would
+// invalidate it.
+var freshPaths = regexp.MustCompile(`^/repos/[^/]+/[^/]+/(` + strings.Join([]string{
+	`pulls(/[0-9]+(/.*)?)?`,
+	`issues(/[0-9]+(/.*)?)?`,
+	`branches(/[^/]+)?`,
+	`commits(/[^/]+(/(status|statuses|check-runs|check-suites))?)?`,
+	`statuses/[^/]+`,
+	`git/refs?(/.*)?`,
+	`tags`,
+	`contents(/.*)?`,
+	`compare/.+`,
+	`labels(/.*)?`,
+}, "|") + `)/?$`)
+
+// isFreshPath checks if responses for the path may be served without
+// revalidation within the fresh window.
+func isFreshPath(path string) bool {
+	return freshPaths.MatchString(path)
+}
+
+// InvalidationRequest lists the API paths whose cached responses are
+// definitely stale, e.g. because a webhook event reported a change. Paths
+// are matched case-insensitively, like GitHub matches owners and repos.
+type InvalidationRequest struct {
+	// Paths are invalidated exactly, ignoring the query, e.g. a list of
+	// resources like /repos/org/repo/pulls.
+	Paths []string `json:"paths,omitempty"`
+	// Prefixes invalidate the path and every path below it, e.g.
+	// /repos/org/repo/pulls/1 also invalidates /repos/org/repo/pulls/1/files.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// invalidations records when paths were invalidated. Invalidations older
+// than the fresh window are forgotten, because every response is revalidated
+// at least once per window anyway.
+type invalidations struct {
+	window   time.Duration
+	lock     sync.Mutex
+	paths    map[string]time.Time
+	prefixes map[string]time.Time
+}
+
+func newInvalidations(window time.Duration) *invalidations {
+	return &invalidations{
+		window:   window,
+		paths:    map[string]time.Time{},
+		prefixes: map[string]time.Time{},
+	}
+}
+
+func (i *invalidations) invalidate(request InvalidationRequest, now time.Time) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for _, recorded := range []map[string]time.Time{i.paths, i.prefixes} {
+		for path, at := range recorded {
+			if now.Sub(at) > i.window {
+				delete(recorded, path)
+			}
+		}
+	}
+	for _, path := range request.Paths {
+		i.paths[normalizePath(path)] = now
+	}
+	for _, prefix := range request.Prefixes {
+		i.prefixes[normalizePath(prefix)] = now
+	}
+}
+
+// normalizePath lowercases the path, because GitHub serves the same resource
+// whatever the case of the owner and repo in its path, and trims any trailing
+// slash.
+func normalizePath(path string) string {
+	return strings.TrimSuffix(strings.ToLower(path), "/")
+}
+
+// writeInvalidation returns the paths changed by a successful write to path:
+// the resource and everything below it, and the collection it belongs to.
+func writeInvalidation(path string) InvalidationRequest {
+	path = normalizePath(path)
+	request := InvalidationRequest{Prefixes: []string{path}}
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		request.Paths = []string{path[:i]}
+	}
+	return request
+}
+
+// invalidatedAt returns when the path was last invalidated, either exactly or
+// by a prefix ending at a path segment boundary.
+func (i *invalidations) invalidatedAt(path string) (time.Time, bool) {
+	path = normalizePath(path)
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	latest, found := i.paths[path]
+	for prefix := path; ; prefix = prefix[:strings.LastIndex(prefix, "/")] {
+		if at, ok := i.prefixes[prefix]; ok && (!found || at.After(latest)) {
+			latest, found = at, true
+		}
+		if strings.LastIndex(prefix, "/") <= 0 {
+			break
+		}
+	}
+	return latest, found
+}
+
+// upstreamCalledKey is the context key of the flag set by upstreamTransport
+// when a request is sent to GitHub.
+type upstreamCalledKey struct{}
+
+func markUpstreamCalled(req *http.Request) {
+	if called, ok := req.Context().Value(upstreamCalledKey{}).(*int32); ok {
+		atomic.StoreInt32(called, 1)
+	}
+}
+
+// freshnessTransport sits in front of the httpcache layer of a partition. It
+// forces the revalidation of invalidated responses, once per invalidation and
+// cached response, and marks the responses served without revalidation
+// within the fresh window. Successful writes through ghproxy invalidate the
+// resources they changed, so that they are read back fresh.
+type freshnessTransport struct {
+	invalidations *invalidations
+	lock          sync.Mutex
+	// revalidated records when a revalidation was last forced per request
+	// URL, which is what httpcache keys the cached responses by. Every page
+	// and query of an invalidated path is thus revalidated.
+	revalidated map[string]time.Time
+	delegate    http.RoundTripper
+}
+
+func newFreshnessTransport(invalidations *invalidations, delegate http.RoundTripper) *freshnessTransport {
+	return &freshnessTransport{invalidations: invalidations, revalidated: map[string]time.Time{}, delegate: delegate}
+}
+
+// mustRevalidate returns whether the path of the URL was invalidated since
+// the revalidation of the URL was last forced, and records the revalidation
+// if so.
+func (t *freshnessTransport) mustRevalidate(u *url.URL, now time.Time) bool {
+	invalidatedAt, ok := t.invalidations.invalidatedAt(u.Path)
+	if !ok {
+		return false
+	}
+	key := u.String()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if revalidatedAt, ok := t.revalidated[key]; ok && revalidatedAt.After(invalidatedAt) {
+		return false
+	}
+	for k, at := range t.revalidated {
+		if now.Sub(at) > t.invalidations.window {
+			delete(t.revalidated, k)
+		}
+	}
+	t.revalidated[key] = now
+	return true
+}
+
+func (t *freshnessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodHead {
+		return t.delegate.RoundTrip(req)
+	}
+	if req.Method != http.MethodGet {
+		resp, err := t.delegate.RoundTrip(req)
+		if err == nil && resp.StatusCode < 400 && !isGraphQL(req.URL.Path) {
+			t.invalidations.invalidate(writeInvalidation(req.URL.Path), time.Now())
+		}
+		return resp, err
+	}
+	called := new(int32)
+	req = req.WithContext(context.WithValue(req.Context(), upstreamCalledKey{}, called))
+	if t.mustRevalidate(req.URL, time.Now()) {
+		// A request max-age of zero makes httpcache consider the cached
+		// response stale, so it is revalidated with a conditional request.
+		req.Header = req.Header.Clone()
+		req.Header.Set("Cache-Control", "max-age=0")
+	}
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// Cached responses carry the mode of the request which stored them.
+	resp.Header.Del(CacheModeHeader)
+	if atomic.LoadInt32(called) == 0 && resp.Header.Get(httpcache.XFromCache) != "" {
+		resp.Header.Set(CacheModeHeader, string(ModeFresh))
+	}
+	return resp, nil
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInvalidatedAt(t *testing.T) {
+	start := time.Now()
+	i := newInvalidations(time.Minute)
+	i.invalidate(InvalidationRequest{
+		Paths:    []string{"/repos/org/repo/pulls"},
+		Prefixes: []string{"/repos/org/repo/pulls/1/"},
+	}, start)
+	i.invalidate(InvalidationRequest{Prefixes: []string{"/repos/org/repo/pulls/1/files"}}, start.Add(time.Second))
+
+	cases := []struct {
+		path     string
+		expected time.Time
+		found    bool
+	}{
+		{path: "/repos/org/repo/pulls", expected: start, found: true},
+		{path: "/repos/org/repo/pulls/1", expected: start, found: true},
+		{path: "/repos/org/repo/pulls/1/commits", expected: start, found: true},
+		{path: "/repos/org/repo/pulls/1/files", expected: start.Add(time.Second), found: true},
+		{path: "/repos/Org/Repo/pulls/1", expected: start, found: true},
+		{path: "/repos/org/repo/pulls/10"},
+		{path: "/repos/org/repo/pulls/2/files"},
+		{path: "/repos/org/repo"},
+		{path: "repos"},
+	}
+	for _, tc := range cases {
+		at, found := i.invalidatedAt(tc.path)
+		if found != tc.found || !at.Equal(tc.expected) {
+			t.Errorf("%s: expected invalidation at %v (%t), got %v (%t)", tc.path, tc.expected, tc.found, at, found)
+		}
+	}
+
+	i.invalidate(InvalidationRequest{}, start.Add(2*time.Minute))
+	if _, found := i.invalidatedAt("/repos/org/repo/pulls/1"); found {
+		t.Error("expected invalidations older than the window to be forgotten")
+	}
+}
+
+func TestIsFreshPath(t *testing.T) {
+	fresh := []string{
+		"/repos/org/repo/pulls",
+		"/repos/org/repo/pulls/1/files",
+		"/repos/Org/Repo/issues/1/comments",
+		"/repos/org/repo/branches/master",
+		"/repos/org/repo/commits/abc/status",
+		"/repos/org/repo/git/refs/heads/master",
+		"/repos/org/repo/contents/OWNERS",
+		"/repos/org/repo/labels",
+	}
+	for _, path := range fresh {
+		if !isFreshPath(path) {
+			t.Errorf("expected %s to be served fresh", path)
+		}
+	}
+	revalidated := []string{
+		"/user",
+		"/repos/org/repo",
+		"/repos/org/repo/collaborators",
+		"/repos/org/repo/collaborators/alice/permission",
+		"/repos/org/repo/issues/comments/1",
+		"/repos/org/repo/pulls/comments/1",
+		"/repos/org/repo/commits/abc/pulls",
+		"/repos/org/repo/branches/master/protection",
+		"/repos/org/repo/hooks",
+		"/repos/org/repo/keys",
+	}
+	for _, path := range revalidated {
+		if isFreshPath(path) {
+			t.Errorf("expected %s to always be revalidated", path)
+		}
+	}
+}
+
+func TestFreshWindow(t *testing.T) {
+	var lock sync.Mutex
+	var requests, conditional int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests++
+		if r.Header.Get("If-None-Match") == `"etag"` {
+			conditional++
+			// Like GitHub, which is how revalidations are recognized.
+			w.Header().Set("Status", "304 Not Modified")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Write([]byte("response"))
+	}))
+	defer upstream.Close()
+
+	cache := NewMemCache(http.DefaultTransport, 1, false, SchedulingOptions{}, time.Minute)
+	admin, err := NewAdmin(cache)
+	if err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+
+	steps := []struct {
+		name          string
+		invalidate    *InvalidationRequest
+		method        string
+		path          string
+		expectedMode  CacheResponseMode
+		expectedCalls int
+	}{
+		{
+			name:          "first request is a miss",
+			path:          "/repos/org/repo/pulls/1",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:         "repository resources are fresh within the window",
+			path:         "/repos/org/repo/pulls/1",
+			expectedMode: ModeFresh,
+		},
+		{
+			name:          "other resources are always revalidated",
+			path:          "/user",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:          "other resources are always revalidated again",
+			path:          "/user",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:         "invalidating other paths keeps the response fresh",
+			invalidate:   &InvalidationRequest{Paths: []string{"/repos/org/repo/pulls"}, Prefixes: []string{"/repos/org/repo/pulls/10"}},
+			path:         "/repos/org/repo/pulls/1",
+			expectedMode: ModeFresh,
+		},
+		{
+			name:          "invalidated response is revalidated",
+			invalidate:    &InvalidationRequest{Prefixes: []string{"/repos/org/repo/pulls/1"}},
+			path:          "/repos/org/repo/pulls/1",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:         "revalidated response is fresh again",
+			path:         "/repos/org/repo/pulls/1",
+			expectedMode: ModeFresh,
+		},
+		{
+			name:          "first page is a miss",
+			path:          "/repos/org/repo/pulls?page=1",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:          "second page is a miss",
+			path:          "/repos/org/repo/pulls?page=2",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:          "first page of an invalidated path is revalidated",
+			invalidate:    &InvalidationRequest{Paths: []string{"/repos/Org/Repo/pulls"}},
+			path:          "/repos/org/repo/pulls?page=1",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:          "second page of an invalidated path is revalidated",
+			path:          "/repos/org/repo/pulls?page=2",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:         "revalidated pages are fresh again",
+			path:         "/repos/org/repo/pulls?page=2",
+			expectedMode: ModeFresh,
+		},
+		{
+			name:          "collaborator permissions are a miss",
+			path:          "/repos/org/repo/collaborators/alice/permission",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:          "collaborator permissions are always revalidated",
+			path:          "/repos/org/repo/collaborators/alice/permission",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:          "comments by ID are a miss",
+			path:          "/repos/org/repo/issues/comments/1",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:          "comments by ID are always revalidated",
+			path:          "/repos/org/repo/issues/comments/1",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:          "labels of an issue are a miss",
+			path:          "/repos/org/repo/issues/1/labels",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:          "issue is a miss",
+			path:          "/repos/org/repo/issues/1",
+			expectedMode:  ModeMiss,
+			expectedCalls: 1,
+		},
+		{
+			name:          "adding a label is sent to GitHub",
+			method:        http.MethodPost,
+			path:          "/repos/org/repo/issues/1/labels",
+			expectedCalls: 1,
+		},
+		{
+			name:          "written path is revalidated",
+			path:          "/repos/org/repo/issues/1/labels",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:          "collection of the written path is revalidated",
+			path:          "/repos/org/repo/issues/1",
+			expectedMode:  ModeRevalidated,
+			expectedCalls: 1,
+		},
+		{
+			name:         "other resources stay fresh after a write",
+			path:         "/repos/org/repo/pulls/1",
+			expectedMode: ModeFresh,
+		},
+	}
+	for _, step := range steps {
+		if step.invalidate != nil {
+			admin.Invalidate(*step.invalidate)
+		}
+		lock.Lock()
+		before := requests
+		lock.Unlock()
+
+		method := step.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req, err := http.NewRequest(method, upstream.URL+step.path, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", step.name, err)
+		}
+		req.Header.Set("Authorization", "token a")
+		resp, err := cache.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: RoundTrip: %v", step.name, err)
+		}
+		// Responses are only cached once their body was read.
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != "response" {
+			t.Errorf("%s: expected the response body, got %q, %v", step.name, body, err)
+		}
+
+		if mode := CacheResponseMode(resp.Header.Get(CacheModeHeader)); mode != step.expectedMode {
+			t.Errorf("%s: expected mode %s, got %s", step.name, step.expectedMode, mode)
+		}
+		lock.Lock()
+		if calls := requests - before; calls != step.expectedCalls {
+			t.Errorf("%s: expected %d requests to GitHub, got %d", step.name, step.expectedCalls, calls)
+		}
+		lock.Unlock()
+	}
+	if conditional != 8 {
+		t.Errorf("expected the revalidations to be conditional requests, got %d", conditional)
+	}
+}
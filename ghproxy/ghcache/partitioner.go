@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/gregjones/httpcache"
 	"github.com/sirupsen/logrus"
 )
 
@@ -35,6 +37,7 @@ func newPartitioningRoundTripper(rtc roundTripperCreator) *partitioningRoundTrip
 		roundTripperCreator: rtc,
 		lock:                &sync.Mutex{},
 		roundTrippers:       map[string]http.RoundTripper{},
+		lastUsed:            map[string]time.Time{},
 	}
 }
 
@@ -42,6 +45,19 @@ type partitioningRoundTripper struct {
 	roundTripperCreator roundTripperCreator
 	lock                *sync.Mutex
 	roundTrippers       map[string]http.RoundTripper
+	// lastUsed records when each partition last served a request.
+	lastUsed map[string]time.Time
+	// store holds the partitions persisted across restarts, if any.
+	store partitionStore
+	// invalidations are shared by all partitions.
+	invalidations *invalidations
+}
+
+// partitionTransport is the http.RoundTripper of a partition, which exposes
+// the cache backing it to the admin API.
+type partitionTransport struct {
+	http.RoundTripper
+	cache httpcache.Cache
 }
 
 func getCachePartition(r *http.Request) string {
@@ -60,6 +76,7 @@ func (prt *partitioningRoundTripper) RoundTrip(r *http.Request) (*http.Response,
 		prt.roundTrippers[cachePartition] = prt.roundTripperCreator(cachePartition)
 		roundTripper = prt.roundTrippers[cachePartition]
 	}
+	prt.lastUsed[cachePartition] = time.Now()
 	prt.lock.Unlock()
 
 	return roundTripper.RoundTrip(r)
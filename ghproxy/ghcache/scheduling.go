@@ -0,0 +1,282 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priority is the priority class of a request. When the proxy is at its
+// concurrency limit, requests of higher priority are sent first. When a token
+// budget runs low, only requests of high enough priority are still sent.
+type Priority int
+
+// Priority classes, from lowest to highest.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+)
+
+// PriorityHeader may be set by clients to choose the priority class of their
+// requests. It takes precedence over the priority configured for the
+// User-Agent of the client.
+const PriorityHeader = "X-PROW-GHPROXY-PRIORITY"
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return strconv.Itoa(int(p))
+}
+
+// ParsePriority parses the name of a priority class.
+func ParsePriority(name string) (Priority, error) {
+	for p := PriorityLow; p <= PriorityHigh; p++ {
+		if strings.EqualFold(name, p.String()) {
+			return p, nil
+		}
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %q, must be one of low, normal or high", name)
+}
+
+// SchedulingOptions configures how requests are scheduled depending on their
+// priority class and the remaining token budget. The zero value schedules all
+// requests with normal priority and never holds any of them back.
+type SchedulingOptions struct {
+	// UserAgentPriorities maps clients to the priority class of their
+	// requests. Clients are identified by their User-Agent without the
+	// version, e.g. "hook.lgtm", or by only the component, e.g. "hook", as
+	// set by Prow's GitHub client. Unknown clients get normal priority.
+	UserAgentPriorities map[string]Priority
+	// Reserves holds back requests of a priority class while fewer than that
+	// many API tokens remain in the budget used for them. This reserves the
+	// remaining tokens for higher priority classes.
+	Reserves map[Priority]int
+	// MaxQueueWait is how long held back requests may wait for their token
+	// budget to reset. Requests that would have to wait longer are rejected
+	// with a 429 status and a Retry-After header.
+	MaxQueueWait time.Duration
+}
+
+// priorityFor returns the priority class of the request.
+func (o SchedulingOptions) priorityFor(req *http.Request) Priority {
+	if p, err := ParsePriority(req.Header.Get(PriorityHeader)); err == nil {
+		return p
+	}
+	client := strings.SplitN(req.Header.Get("User-Agent"), "/", 2)[0]
+	if p, ok := o.UserAgentPriorities[client]; ok {
+		return p
+	}
+	if p, ok := o.UserAgentPriorities[strings.SplitN(client, ".", 2)[0]]; ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+// heldRequestsCounter provides the 'ghproxy_held_requests' counter that
+// keeps track of the requests held back to reserve the token budget.
+var heldRequestsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ghproxy_held_requests",
+		Help: "How many requests were queued or rejected to reserve the token budget for higher priority requests.",
+	},
+	[]string{"token_hash", "priority", "action"},
+)
+
+func init() {
+	prometheus.MustRegister(heldRequestsCounter)
+}
+
+// tokenBudget is the last observed state of the rate limit of a token.
+type tokenBudget struct {
+	remaining int
+	reset     time.Time
+}
+
+// tokenBudgets keeps track of the rate limits reported by GitHub for each
+// token budget and API version.
+type tokenBudgets struct {
+	lock    sync.Mutex
+	budgets map[string]tokenBudget
+}
+
+func newTokenBudgets() *tokenBudgets {
+	return &tokenBudgets{budgets: map[string]tokenBudget{}}
+}
+
+func apiVersion(path string) string {
+	if isGraphQL(path) {
+		return "v4"
+	}
+	return "v3"
+}
+
+// update records the rate limit reported in the headers of a response.
+func (b *tokenBudgets) update(tokenBudgetName, apiVersion string, headers http.Header) {
+	remaining, err := strconv.Atoi(headers.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(headers.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.budgets[tokenBudgetName+" "+apiVersion] = tokenBudget{remaining: remaining, reset: time.Unix(reset, 0)}
+}
+
+// get returns the budget of the token, if it is known and has not been reset
+// since it was observed.
+func (b *tokenBudgets) get(tokenBudgetName, apiVersion string, now time.Time) (tokenBudget, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	budget, ok := b.budgets[tokenBudgetName+" "+apiVersion]
+	if !ok || !budget.reset.After(now) {
+		return tokenBudget{}, false
+	}
+	return budget, true
+}
+
+// prioritySemaphore is a counting semaphore which grants waiting acquirers of
+// higher priority first.
+type prioritySemaphore struct {
+	lock      sync.Mutex
+	available int
+	waiters   [numPriorities][]chan struct{}
+}
+
+func newPrioritySemaphore(size int) *prioritySemaphore {
+	return &prioritySemaphore{available: size}
+}
+
+func (s *prioritySemaphore) acquire(ctx context.Context, p Priority) error {
+	s.lock.Lock()
+	if s.available > 0 {
+		s.available--
+		s.lock.Unlock()
+		return nil
+	}
+	granted := make(chan struct{})
+	s.waiters[p] = append(s.waiters[p], granted)
+	s.lock.Unlock()
+
+	select {
+	case <-granted:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		for i, waiter := range s.waiters[p] {
+			if waiter == granted {
+				s.waiters[p] = append(s.waiters[p][:i], s.waiters[p][i+1:]...)
+				s.lock.Unlock()
+				return ctx.Err()
+			}
+		}
+		s.lock.Unlock()
+		// We were granted in the meantime, pass it on.
+		s.release()
+		return ctx.Err()
+	}
+}
+
+func (s *prioritySemaphore) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for p := numPriorities - 1; p >= 0; p-- {
+		if len(s.waiters[p]) > 0 {
+			close(s.waiters[p][0])
+			s.waiters[p] = s.waiters[p][1:]
+			return
+		}
+	}
+	s.available++
+}
+
+// holdForBudget waits until the token budget allows sending a request of the
+// given priority. It returns how long the client should wait before retrying
+// if the request must be rejected instead.
+func (c *throttlingTransport) holdForBudget(req *http.Request, tokenBudgetName string, priority Priority) (time.Duration, error) {
+	reserve := c.scheduling.Reserves[priority]
+	// Conditional requests are free unless the resource changed, so they
+	// are never held back.
+	if reserve <= 0 || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return 0, nil
+	}
+	version := apiVersion(req.URL.Path)
+	queued := false
+	for {
+		now := time.Now()
+		budget, known := c.budgets.get(tokenBudgetName, version, now)
+		if !known || budget.remaining >= reserve {
+			return 0, nil
+		}
+		wait := budget.reset.Sub(now)
+		if wait > c.scheduling.MaxQueueWait {
+			heldRequestsCounter.WithLabelValues(tokenBudgetName, priority.String(), "rejected").Inc()
+			return wait, nil
+		}
+		if !queued {
+			heldRequestsCounter.WithLabelValues(tokenBudgetName, priority.String(), "queued").Inc()
+			queued = true
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return 0, req.Context().Err()
+		}
+	}
+}
+
+// budgetExhaustedResponse is returned for requests rejected to reserve the
+// token budget for higher priority requests.
+func budgetExhaustedResponse(req *http.Request, retryAfter time.Duration, priority Priority) *http.Response {
+	body := fmt.Sprintf("ghproxy: token budget reserved for requests with priority higher than %s, retry after %v", priority, retryAfter)
+	header := http.Header{}
+	header.Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	header.Set("Cache-Control", "no-store")
+	header.Set("Content-Type", "text/plain")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests)),
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghcache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/test-infra/ghproxy/ghmetrics"
+)
+
+func TestPriorityFor(t *testing.T) {
+	opts := SchedulingOptions{UserAgentPriorities: map[string]Priority{
+		"tide":             PriorityHigh,
+		"hook":             PriorityHigh,
+		"hook.blunderbuss": PriorityLow,
+		"label_sync":       PriorityLow,
+	}}
+	testCases := []struct {
+		name      string
+		userAgent string
+		header    string
+		expected  Priority
+	}{
+		{
+			name:      "component",
+			userAgent: "tide/v20210301-abcdef",
+			expected:  PriorityHigh,
+		},
+		{
+			name:      "component with identifier falls back to component",
+			userAgent: "hook.lgtm/v20210301-abcdef",
+			expected:  PriorityHigh,
+		},
+		{
+			name:      "component with identifier",
+			userAgent: "hook.blunderbuss/v20210301-abcdef",
+			expected:  PriorityLow,
+		},
+		{
+			name:      "unknown client",
+			userAgent: "branchprotector/v20210301-abcdef",
+			expected:  PriorityNormal,
+		},
+		{
+			name:     "no user agent",
+			expected: PriorityNormal,
+		},
+		{
+			name:      "header takes precedence",
+			userAgent: "label_sync/v20210301-abcdef",
+			header:    "High",
+			expected:  PriorityHigh,
+		},
+		{
+			name:      "invalid header is ignored",
+			userAgent: "label_sync/v20210301-abcdef",
+			header:    "urgent",
+			expected:  PriorityLow,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos/org/repo", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			if tc.userAgent != "" {
+				req.Header.Set("User-Agent", tc.userAgent)
+			}
+			if tc.header != "" {
+				req.Header.Set(PriorityHeader, tc.header)
+			}
+			if actual := opts.priorityFor(req); actual != tc.expected {
+				t.Errorf("expected priority %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTokenBudgets(t *testing.T) {
+	now := time.Now()
+	budgets := newTokenBudgets()
+	headers := http.Header{}
+	headers.Set("X-RateLimit-Remaining", "42")
+	headers.Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(time.Hour).Unix(), 10))
+	budgets.update("token", "v3", headers)
+	budgets.update("token", "v4", http.Header{})
+
+	if budget, ok := budgets.get("token", "v3", now); !ok || budget.remaining != 42 {
+		t.Errorf("expected 42 remaining tokens, got %+v (known: %t)", budget, ok)
+	}
+	if _, ok := budgets.get("token", "v4", now); ok {
+		t.Error("expected budget without rate limit headers to be unknown")
+	}
+	if _, ok := budgets.get("token", "v3", now.Add(2*time.Hour)); ok {
+		t.Error("expected budget to be unknown after its reset")
+	}
+}
+
+func TestPrioritySemaphore(t *testing.T) {
+	sem := newPrioritySemaphore(1)
+	if err := sem.acquire(context.Background(), PriorityNormal); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A waiter that gives up must not be granted later.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() { cancelled <- sem.acquire(ctx, PriorityHigh) }()
+	waitForWaiters(t, sem, 1)
+	cancel()
+	if err := <-cancelled; err == nil {
+		t.Error("expected an error for a cancelled acquire")
+	}
+
+	var lock sync.Mutex
+	var order []Priority
+	wg := sync.WaitGroup{}
+	for i, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityNormal} {
+		wg.Add(1)
+		go func(p Priority) {
+			defer wg.Done()
+			if err := sem.acquire(context.Background(), p); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			lock.Lock()
+			order = append(order, p)
+			lock.Unlock()
+			sem.release()
+		}(p)
+		waitForWaiters(t, sem, i+1)
+	}
+	sem.release()
+	wg.Wait()
+
+	expected := []Priority{PriorityHigh, PriorityNormal, PriorityNormal, PriorityLow}
+	if !reflect.DeepEqual(expected, order) {
+		t.Errorf("expected acquire order %v, got %v", expected, order)
+	}
+	if sem.available != 1 {
+		t.Errorf("expected the semaphore to be available again, got %d", sem.available)
+	}
+}
+
+func waitForWaiters(t *testing.T, sem *prioritySemaphore, n int) {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		sem.lock.Lock()
+		waiting := 0
+		for _, waiters := range sem.waiters {
+			waiting += len(waiters)
+		}
+		sem.lock.Unlock()
+		if waiting == n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d waiters", n)
+}
+
+// countingDelegate counts the requests reaching upstream.
+type countingDelegate struct {
+	lock sync.Mutex
+	hits int
+}
+
+func (c *countingDelegate) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.lock.Lock()
+	c.hits++
+	c.lock.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewBufferString("Response"))}, nil
+}
+
+func TestThrottlingTransportBudget(t *testing.T) {
+	testCases := []struct {
+		name         string
+		remaining    int
+		resetIn      time.Duration
+		userAgent    string
+		conditional  bool
+		maxQueueWait time.Duration
+
+		expectedStatus int
+		expectQueued   bool
+	}{
+		{
+			name:           "enough budget left",
+			remaining:      1000,
+			resetIn:        time.Hour,
+			userAgent:      "label_sync/v1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "low priority request rejected",
+			remaining:      100,
+			resetIn:        time.Hour,
+			userAgent:      "label_sync/v1",
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:           "high priority request sent",
+			remaining:      100,
+			resetIn:        time.Hour,
+			userAgent:      "tide/v1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "conditional request sent",
+			remaining:      100,
+			resetIn:        time.Hour,
+			userAgent:      "label_sync/v1",
+			conditional:    true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "low priority request queued until reset",
+			remaining:      100,
+			resetIn:        2 * time.Second,
+			userAgent:      "label_sync/v1",
+			maxQueueWait:   5 * time.Second,
+			expectedStatus: http.StatusOK,
+			expectQueued:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			budgets := newTokenBudgets()
+			headers := http.Header{}
+			headers.Set("X-RateLimit-Remaining", strconv.Itoa(tc.remaining))
+			headers.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(tc.resetIn).Unix(), 10))
+			budgets.update("token", "v3", headers)
+
+			delegate := &countingDelegate{}
+			transport := newThrottlingTransport(newPrioritySemaphore(1), SchedulingOptions{
+				UserAgentPriorities: map[string]Priority{"tide": PriorityHigh, "label_sync": PriorityLow},
+				Reserves:            map[Priority]int{PriorityLow: 500, PriorityNormal: 50},
+				MaxQueueWait:        tc.maxQueueWait,
+			}, budgets, ghmetrics.NewCachingHasher(), delegate)
+
+			req, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos/org/repo", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			req.Header.Set("User-Agent", tc.userAgent)
+			req.Header.Set(TokenBudgetIdentifierHeader, "token")
+			if tc.conditional {
+				req.Header.Set("If-None-Match", "etag")
+			}
+
+			start := time.Now()
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+			if tc.expectedStatus == http.StatusTooManyRequests {
+				if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retryAfter <= 0 {
+					t.Errorf("expected a positive Retry-After header, got %q", resp.Header.Get("Retry-After"))
+				}
+				if delegate.hits != 0 {
+					t.Errorf("expected rejected request not to reach upstream")
+				}
+			} else if delegate.hits != 1 {
+				t.Errorf("expected request to reach upstream once, got %d", delegate.hits)
+			}
+			if queued := time.Since(start) > 100*time.Millisecond; queued != tc.expectQueued {
+				t.Errorf("expected queued: %t, but request took %v", tc.expectQueued, time.Since(start))
+			}
+		})
+	}
+}
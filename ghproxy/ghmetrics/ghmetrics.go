@@ -90,6 +90,26 @@ var cacheEntryAge = prometheus.NewHistogramVec(
 	[]string{"token_hash", "path", "user_agent"},
 )
 
+// graphQLQueries provides the 'github_graphql_queries' counter that keeps
+// track of the GraphQL queries sent to GitHub by query name.
+var graphQLQueries = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "github_graphql_queries",
+		Help: "How many GraphQL queries were sent to GitHub by query name.",
+	},
+	[]string{"token_hash", "query_name", "user_agent"},
+)
+
+// graphQLCost provides the 'github_graphql_cost' counter that keeps track
+// of the rate limit points spent on GraphQL queries by query name.
+var graphQLCost = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "github_graphql_cost",
+		Help: "How many rate limit points were spent on GraphQL queries by query name.",
+	},
+	[]string{"token_hash", "query_name", "user_agent"},
+)
+
 var muxTokenUsage, muxRequestMetrics sync.Mutex
 var lastGitHubResponse time.Time
 
@@ -100,6 +120,8 @@ func init() {
 	prometheus.MustRegister(cacheCounter)
 	prometheus.MustRegister(timeoutDuration)
 	prometheus.MustRegister(cacheEntryAge)
+	prometheus.MustRegister(graphQLQueries)
+	prometheus.MustRegister(graphQLCost)
 }
 
 // CollectGitHubTokenMetrics publishes the rate limits of the github api to
@@ -175,3 +197,14 @@ func CollectCacheEntryAgeMetrics(age float64, path, userAgent, tokenHash string)
 func CollectRequestTimeoutMetrics(tokenHash, path, userAgent string, reqStartTime, responseTime time.Time) {
 	timeoutDuration.With(prometheus.Labels{"token_hash": tokenHash, "path": simplifier.Simplify(path), "user_agent": userAgentWithoutVersion(userAgent)}).Observe(float64(responseTime.Sub(reqStartTime).Seconds()))
 }
+
+// CollectGraphQLQueryMetrics records a GraphQL query sent to GitHub to
+// 'github_graphql_queries' and, if GitHub reported it, the rate limit cost
+// of the query to 'github_graphql_cost' on prometheus.
+func CollectGraphQLQueryMetrics(tokenHash, queryName, userAgent string, cost int, costReported bool) {
+	labels := prometheus.Labels{"token_hash": tokenHash, "query_name": queryName, "user_agent": userAgentWithoutVersion(userAgent)}
+	graphQLQueries.With(labels).Inc()
+	if costReported {
+		graphQLCost.With(labels).Add(float64(cost))
+	}
+}
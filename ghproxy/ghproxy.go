@@ -24,6 +24,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -67,6 +68,7 @@ func init() {
 //  v ^ reverse proxy
 //  v ^ ghcache: downstreamTransport (coalescing, instrumentation)
 //  v ^ ghcache: httpcache layer
+//  v ^ ghcache: throttlingTransport (concurrency, priorities, token budget reservation)
 //  v ^ ghcache: upstreamTransport (cache-control, instrumentation)
 //  v ^ apptokenequalizer: Make sure all clients get the same app installation token so they can share a cache
 //  v ^ http.DefaultTransport
@@ -75,6 +77,7 @@ func init() {
 type options struct {
 	dir                                    string
 	sizeGB                                 int
+	memoryTierSizeMB                       int
 	diskCacheDisableAuthHeaderPartitioning bool
 
 	redisAddress string
@@ -85,6 +88,19 @@ type options struct {
 
 	maxConcurrency int
 
+	coalesceGraphQL bool
+
+	adminPort              int
+	gcUnusedPartitionsDays int
+
+	freshWindow time.Duration
+
+	priorities          flagutil.Strings
+	budgetReserveNormal int
+	budgetReserveLow    int
+	maxQueueWait        time.Duration
+	scheduling          ghcache.SchedulingOptions
+
 	// pushGateway fields are used to configure pushing prometheus metrics.
 	pushGateway         string
 	pushGatewayInterval time.Duration
@@ -106,11 +122,43 @@ func (o *options) validate() error {
 	if (o.dir == "") != (o.sizeGB == 0) {
 		return errors.New("--cache-dir and --cache-sizeGB must be specified together to enable the disk cache (otherwise a memory cache is used)")
 	}
+	if o.memoryTierSizeMB != 0 && o.dir == "" {
+		return errors.New("--memory-tier-sizeMB can only be used with a disk cache")
+	}
+	if o.freshWindow < 0 {
+		return errors.New("--fresh-window cannot be negative")
+	}
+	if o.gcUnusedPartitionsDays < 0 {
+		return errors.New("--gc-unused-partitions-days cannot be negative")
+	}
 	upstreamURL, err := url.Parse(o.upstream)
 	if err != nil {
 		return fmt.Errorf("failed to parse upstream URL: %v", err)
 	}
 	o.upstreamParsed = upstreamURL
+
+	if o.budgetReserveNormal < 0 || o.budgetReserveLow < 0 {
+		return errors.New("--budget-reserve-normal and --budget-reserve-low cannot be negative")
+	}
+	o.scheduling = ghcache.SchedulingOptions{
+		UserAgentPriorities: map[string]ghcache.Priority{},
+		Reserves: map[ghcache.Priority]int{
+			ghcache.PriorityNormal: o.budgetReserveNormal,
+			ghcache.PriorityLow:    o.budgetReserveLow,
+		},
+		MaxQueueWait: o.maxQueueWait,
+	}
+	for _, priority := range o.priorities.Strings() {
+		parts := strings.SplitN(priority, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("--priority must be of the form client=priority, got %q", priority)
+		}
+		p, err := ghcache.ParsePriority(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid --priority %q: %v", priority, err)
+		}
+		o.scheduling.UserAgentPriorities[parts[0]] = p
+	}
 	return nil
 }
 
@@ -118,11 +166,20 @@ func flagOptions() *options {
 	o := &options{}
 	flag.StringVar(&o.dir, "cache-dir", "", "Directory to cache to if using a disk cache.")
 	flag.IntVar(&o.sizeGB, "cache-sizeGB", 0, "Cache size in GB per unique token if using a disk cache.")
+	flag.IntVar(&o.memoryTierSizeMB, "memory-tier-sizeMB", 0, "If using a disk cache, keep up to this many MB of the most recently used responses of each partition in memory in front of the disk.")
 	flag.BoolVar(&o.diskCacheDisableAuthHeaderPartitioning, "legacy-disable-disk-cache-partitions-by-auth-header", true, "Whether to disable partitioning a disk cache by auth header. Disabling this will start a new cache at $cache_dir/$sha256sum_of_authorization_header for each unique authorization header. Bigger setups are advise to manually warm this up from an existing cache. This option will be removed and set to `false` in the future")
 	flag.StringVar(&o.redisAddress, "redis-address", "", "Redis address if using a redis cache e.g. localhost:6379.")
 	flag.IntVar(&o.port, "port", 8888, "Port to listen on.")
 	flag.StringVar(&o.upstream, "upstream", "https://api.github.com", "Scheme, host, and base path of reverse proxy upstream.")
 	flag.IntVar(&o.maxConcurrency, "concurrency", 25, "Maximum number of concurrent in-flight requests to GitHub.")
+	flag.BoolVar(&o.coalesceGraphQL, "coalesce-graphql", false, "Whether concurrent identical GraphQL queries should share a single request to GitHub.")
+	flag.Var(&o.priorities, "priority", "Priority class (low, normal or high) of the requests of a client, in the form client=priority, e.g. tide=high. The client is the User-Agent without the version, e.g. hook.lgtm, or only its component, e.g. hook. Can be passed multiple times. Clients can also set the "+ghcache.PriorityHeader+" header.")
+	flag.IntVar(&o.budgetReserveNormal, "budget-reserve-normal", 0, "Hold back normal priority requests while fewer than this many API tokens remain, reserving them for high priority requests.")
+	flag.IntVar(&o.budgetReserveLow, "budget-reserve-low", 0, "Hold back low priority requests while fewer than this many API tokens remain, reserving them for higher priority requests.")
+	flag.DurationVar(&o.maxQueueWait, "max-queue-wait", 0, "How long held back requests may wait for their token budget to reset. Requests that would have to wait longer are rejected with a 429 status and a Retry-After header.")
+	flag.IntVar(&o.adminPort, "admin-port", 0, "Port to serve the cache admin API on, which lists, purges and garbage-collects cache partitions. Must not be exposed to clients. 0 disables the admin API.")
+	flag.IntVar(&o.gcUnusedPartitionsDays, "gc-unused-partitions-days", 0, "Remove cache partitions which were not used for this many days, e.g. those of rotated tokens. 0 disables garbage collection.")
+	flag.DurationVar(&o.freshWindow, "fresh-window", 0, "Serve cached responses for repository resources without revalidating them for this long, unless they are invalidated through the admin API, e.g. by ghproxy-invalidator. 0 revalidates every response.")
 	flag.StringVar(&o.pushGateway, "push-gateway", "", "If specified, push prometheus metrics to this endpoint.")
 	flag.DurationVar(&o.pushGatewayInterval, "push-gateway-interval", time.Minute, "Interval at which prometheus metrics are pushed.")
 	flag.StringVar(&o.logLevel, "log-level", "debug", fmt.Sprintf("Log level is one of %v.", logrus.AllLevels))
@@ -146,11 +203,11 @@ func main() {
 
 	var cache http.RoundTripper
 	if o.redisAddress != "" {
-		cache = ghcache.NewRedisCache(apptokenequalizer.New(http.DefaultTransport), o.redisAddress, o.maxConcurrency)
+		cache = ghcache.NewRedisCache(apptokenequalizer.New(http.DefaultTransport), o.redisAddress, o.maxConcurrency, o.coalesceGraphQL, o.scheduling, o.freshWindow)
 	} else if o.dir == "" {
-		cache = ghcache.NewMemCache(apptokenequalizer.New(http.DefaultTransport), o.maxConcurrency)
+		cache = ghcache.NewMemCache(apptokenequalizer.New(http.DefaultTransport), o.maxConcurrency, o.coalesceGraphQL, o.scheduling, o.freshWindow)
 	} else {
-		cache = ghcache.NewDiskCache(apptokenequalizer.New(http.DefaultTransport), o.dir, o.sizeGB, o.maxConcurrency, o.diskCacheDisableAuthHeaderPartitioning)
+		cache = ghcache.NewDiskCache(apptokenequalizer.New(http.DefaultTransport), o.dir, o.sizeGB, o.memoryTierSizeMB, o.maxConcurrency, o.diskCacheDisableAuthHeaderPartitioning, o.coalesceGraphQL, o.scheduling, o.freshWindow)
 		go diskMonitor(o.pushGatewayInterval, o.dir)
 	}
 
@@ -164,6 +221,26 @@ func main() {
 		ServeMetrics: o.serveMetrics,
 	}, o.instrumentationOptions.MetricsPort)
 
+	if o.adminPort != 0 || o.gcUnusedPartitionsDays != 0 {
+		admin, err := ghcache.NewAdmin(cache)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create cache admin.")
+		}
+		if o.adminPort != 0 {
+			interrupts.ListenAndServe(&http.Server{Addr: ":" + strconv.Itoa(o.adminPort), Handler: admin}, 5*time.Second)
+		}
+		if o.gcUnusedPartitionsDays != 0 {
+			unusedFor := time.Duration(o.gcUnusedPartitionsDays) * 24 * time.Hour
+			interrupts.TickLiteral(func() {
+				removed, err := admin.CollectGarbage(unusedFor)
+				if err != nil {
+					logrus.WithError(err).Error("Failed to garbage-collect cache partitions.")
+				}
+				logrus.WithField("partitions", removed).Infof("Removed %d cache partitions unused for %d days.", len(removed), o.gcUnusedPartitionsDays)
+			}, time.Hour)
+		}
+	}
+
 	proxy := newReverseProxy(o.upstreamParsed, cache, 30*time.Second)
 	server := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: proxy}
 
@@ -26,7 +26,8 @@ import (
 )
 
 type flags struct {
-	OutputFile string
+	OutputFile   string
+	OutputFormat string
 }
 
 // MakeCommand returns a `diff` command.
@@ -34,18 +35,21 @@ func MakeCommand() *cobra.Command {
 	flags := &flags{}
 	cmd := &cobra.Command{
 		Use:   "diff [first] [second]",
-		Short: "Diffs two Go coverage files.",
-		Long: `Takes the difference between two Go coverage files, producing another Go coverage file
+		Short: "Diffs two coverage files.",
+		Long: `Takes the difference between two coverage files, producing another coverage file
 showing only what was covered between the two files being generated. This works best when using
 files generated in "count" or "atomic" mode; "set" may drastically underreport.
 
 It is assumed that both files came from the same execution, and so all values in the second file are
-at least equal to those in the first file.`,
+at least equal to those in the first file.
+
+The files may be Go coverage profiles, LCOV tracefiles or Cobertura XML reports.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			run(flags, cmd, args)
 		},
 	}
 	cmd.Flags().StringVarP(&flags.OutputFile, "output", "o", "-", "output file")
+	cmd.Flags().StringVar(&flags.OutputFormat, "output-format", string(cov.FormatGo), "output format: go, lcov or cobertura")
 	return cmd
 }
 
@@ -56,6 +60,12 @@ func run(flags *flags, cmd *cobra.Command, args []string) {
 		os.Exit(2)
 	}
 
+	format, err := cov.ParseFormat(flags.OutputFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	before, err := util.LoadProfile(args[0])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Couldn't load %s: %v.", args[0], err)
@@ -74,7 +84,7 @@ func run(flags *flags, cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if err := util.DumpProfile(flags.OutputFile, diff); err != nil {
+	if err := util.DumpProfileAs(flags.OutputFile, format, diff); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
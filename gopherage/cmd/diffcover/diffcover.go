@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diffcover
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/test-infra/gopherage/pkg/cov"
+	"k8s.io/test-infra/gopherage/pkg/util"
+)
+
+type flags struct {
+	outputFile   string
+	threshold    float64
+	importPrefix string
+}
+
+// MakeCommand returns a `diffcover` command.
+func MakeCommand() *cobra.Command {
+	flags := &flags{}
+	cmd := &cobra.Command{
+		Use:   "diffcover [profile] [diff]",
+		Short: "Reports the coverage of the lines changed by a diff.",
+		Long: `Reports the coverage of the lines added or modified by a unified diff, as produced by git diff,
+per file and in total, along with the changed lines that weren't covered. Either file may be "-" to
+read it from stdin.
+
+Lines are matched to the profile by path: profile file names are import paths, so diff paths are
+matched by suffix unless --import-prefix gives the import path of the repository root.
+
+If the coverage of the changed lines is below --threshold, the command fails after printing the
+report.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			run(flags, cmd, args)
+		},
+	}
+	cmd.Flags().StringVarP(&flags.outputFile, "output", "o", "-", "output file")
+	cmd.Flags().Float64VarP(&flags.threshold, "threshold", "t", 0, "minimum coverage of the changed lines, between 0 and 1")
+	cmd.Flags().StringVar(&flags.importPrefix, "import-prefix", "", "import path of the repository root, e.g. k8s.io/test-infra")
+	return cmd
+}
+
+func run(flags *flags, cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Expected a coverage profile and a diff.")
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if args[0] == "-" && args[1] == "-" {
+		fmt.Fprintln(os.Stderr, "Only one of the profile and the diff can be read from stdin.")
+		os.Exit(2)
+	}
+	if flags.threshold < 0 || flags.threshold > 1 {
+		fmt.Fprintln(os.Stderr, "coverage threshold must be a float number between 0 to 1, inclusively")
+		os.Exit(1)
+	}
+
+	profiles, err := util.LoadProfile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't load %s: %v.\n", args[0], err)
+		os.Exit(1)
+	}
+
+	changes, err := loadDiff(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't load %s: %v.\n", args[1], err)
+		os.Exit(1)
+	}
+
+	coverage := cov.CoverChanges(profiles, changes, strings.TrimSuffix(flags.importPrefix, "/"))
+
+	var output io.Writer = os.Stdout
+	if flags.outputFile != "-" {
+		f, err := os.Create(flags.outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create file: %v.\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		output = f
+	}
+	if err := writeReport(output, coverage); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write report: %v.\n", err)
+		os.Exit(1)
+	}
+
+	if coverage.Ratio() < flags.threshold {
+		fmt.Fprintf(os.Stderr, "Coverage of changed lines %.1f%% is below the threshold of %.1f%%.\n", 100*coverage.Ratio(), 100*flags.threshold)
+		os.Exit(1)
+	}
+}
+
+func loadDiff(origin string) (cov.ChangedLines, error) {
+	if origin == "-" {
+		return cov.ParseUnifiedDiff(os.Stdin)
+	}
+	f, err := os.Open(origin)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return cov.ParseUnifiedDiff(f)
+}
+
+func writeReport(output io.Writer, coverage cov.ChangeCoverage) error {
+	w := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tCHANGED\tCOVERED\tCOVERAGE\tUNCOVERED LINES")
+	for _, file := range coverage.Files {
+		var hunks []string
+		for _, hunk := range file.UncoveredHunks {
+			hunks = append(hunks, hunk.String())
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\t%s\n", file.FileName, file.ChangedLines, file.CoveredLines, 100*file.Ratio(), strings.Join(hunks, ", "))
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%.1f%%\t\n", coverage.ChangedLines, coverage.CoveredLines, 100*coverage.Ratio())
+	return w.Flush()
+}
@@ -28,6 +28,7 @@ import (
 
 type flags struct {
 	OutputFile   string
+	OutputFormat string
 	IncludePaths []string
 	ExcludePaths []string
 }
@@ -37,13 +38,15 @@ func MakeCommand() *cobra.Command {
 	flags := &flags{}
 	cmd := &cobra.Command{
 		Use:   "filter [file]",
-		Short: "Filters a Go coverage file.",
-		Long:  `Filters a Go coverage file, removing entries that do not match the given flags.`,
+		Short: "Filters a coverage file.",
+		Long: `Filters a coverage file, removing entries that do not match the given flags.
+The file may be a Go coverage profile, an LCOV tracefile or a Cobertura XML report.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			run(flags, cmd, args)
 		},
 	}
 	cmd.Flags().StringVarP(&flags.OutputFile, "output", "o", "-", "output file")
+	cmd.Flags().StringVar(&flags.OutputFormat, "output-format", string(cov.FormatGo), "output format: go, lcov or cobertura")
 	cmd.Flags().StringSliceVar(&flags.IncludePaths, "include-path", nil, "If specified at least once, only files with paths matching one of these regexes are included.")
 	cmd.Flags().StringSliceVar(&flags.ExcludePaths, "exclude-path", nil, "Files with paths matching one of these regexes are excluded. Can be used repeatedly.")
 	return cmd
@@ -56,6 +59,12 @@ func run(flags *flags, cmd *cobra.Command, args []string) {
 		os.Exit(2)
 	}
 
+	format, err := cov.ParseFormat(flags.OutputFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	input, err := util.LoadProfile(args[0])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Couldn't load %s: %v.", args[0], err)
@@ -77,7 +86,7 @@ func run(flags *flags, cmd *cobra.Command, args []string) {
 		}
 	}
 
-	if err := util.DumpProfile(flags.OutputFile, output); err != nil {
+	if err := util.DumpProfileAs(flags.OutputFile, format, output); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -39,7 +39,8 @@ func MakeCommand() *cobra.Command {
 		Short: "Summarize coverage profile and produce the result in junit xml format.",
 		Long: `Summarize coverage profile and produce the result in junit xml format.
 Summary done at per-file and per-package level. Any coverage below coverage-threshold will be marked
-with a <failure> tag in the xml produced.`,
+with a <failure> tag in the xml produced.
+The profile may be a Go coverage profile, an LCOV tracefile or a Cobertura XML report.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			run(flags, cmd, args)
 		},
@@ -27,7 +27,8 @@ import (
 )
 
 type flags struct {
-	OutputFile string
+	OutputFile   string
+	OutputFormat string
 }
 
 // MakeCommand returns a `merge` command.
@@ -35,8 +36,12 @@ func MakeCommand() *cobra.Command {
 	flags := &flags{}
 	cmd := &cobra.Command{
 		Use:   "merge [files...]",
-		Short: "Merge multiple coherent Go coverage files into a single file.",
-		Long: `merge will merge multiple Go coverage files into a single coverage file.
+		Short: "Merge multiple coherent coverage files into a single file.",
+		Long: `merge will merge multiple coverage files into a single coverage file.
+The files may be Go coverage profiles, LCOV tracefiles or Cobertura XML reports, and may be mixed.
+Files covered by LCOV or Cobertura reports are merged line by line, so a file also covered by a Go
+profile loses its statement blocks, and a line is only covered by the Go profile if all its
+statements ran.
 merge requires that the files are 'coherent', meaning that if they both contain references to the
 same paths, then the contents of those source files were identical for the binary that generated
 each file.
@@ -47,6 +52,7 @@ Merging a single file is a no-op, but is supported for convenience when shell sc
 		},
 	}
 	cmd.Flags().StringVarP(&flags.OutputFile, "output", "o", "-", "output file")
+	cmd.Flags().StringVar(&flags.OutputFormat, "output-format", string(cov.FormatGo), "output format: go, lcov or cobertura")
 	return cmd
 }
 
@@ -57,6 +63,12 @@ func run(flags *flags, cmd *cobra.Command, args []string) {
 		os.Exit(2)
 	}
 
+	format, err := cov.ParseFormat(flags.OutputFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	profiles := make([][]*cover.Profile, len(args))
 	for _, path := range args {
 		profile, err := util.LoadProfile(path)
@@ -73,7 +85,7 @@ func run(flags *flags, cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if err := util.DumpProfile(flags.OutputFile, merged); err != nil {
+	if err := util.DumpProfileAs(flags.OutputFile, format, merged); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/test-infra/gopherage/cmd/aggregate"
 	"k8s.io/test-infra/gopherage/cmd/diff"
+	"k8s.io/test-infra/gopherage/cmd/diffcover"
 	"k8s.io/test-infra/gopherage/cmd/filter"
 	"k8s.io/test-infra/gopherage/cmd/html"
 	"k8s.io/test-infra/gopherage/cmd/junit"
@@ -36,6 +37,7 @@ var rootCommand = &cobra.Command{
 func run() error {
 	rootCommand.AddCommand(aggregate.MakeCommand())
 	rootCommand.AddCommand(diff.MakeCommand())
+	rootCommand.AddCommand(diffcover.MakeCommand())
 	rootCommand.AddCommand(filter.MakeCommand())
 	rootCommand.AddCommand(html.MakeCommand())
 	rootCommand.AddCommand(junit.MakeCommand())
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cov
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+
+	"golang.org/x/tools/cover"
+)
+
+// The subset of the Cobertura XML format needed for line coverage.
+// See http://cobertura.sourceforge.net/xml/coverage-04.dtd.
+type coberturaCoverage struct {
+	XMLName      xml.Name           `xml:"coverage"`
+	LineRate     string             `xml:"line-rate,attr"`
+	BranchRate   string             `xml:"branch-rate,attr"`
+	LinesCovered int                `xml:"lines-covered,attr"`
+	LinesValid   int                `xml:"lines-valid,attr"`
+	Version      string             `xml:"version,attr"`
+	Packages     []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   string           `xml:"line-rate,attr"`
+	BranchRate string           `xml:"branch-rate,attr"`
+	Complexity string           `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	FileName   string          `xml:"filename,attr"`
+	LineRate   string          `xml:"line-rate,attr"`
+	BranchRate string          `xml:"branch-rate,attr"`
+	Complexity string          `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int    `xml:"number,attr"`
+	Hits   string `xml:"hits,attr"`
+}
+
+// ParseCobertura parses a Cobertura XML report into profiles with a block per line.
+// Only line coverage is kept, and files are named by the class filename attribute, which is
+// usually relative to one of the report's sources. If a file is split across several classes, each
+// line gets its highest hit count.
+func ParseCobertura(reader io.Reader) ([]*cover.Profile, error) {
+	report := coberturaCoverage{}
+	if err := xml.NewDecoder(reader).Decode(&report); err != nil {
+		return nil, fmt.Errorf("failed to parse Cobertura XML: %v", err)
+	}
+	files := map[string]lineCoverage{}
+	for _, pkg := range report.Packages {
+		for _, class := range pkg.Classes {
+			lines := files[class.FileName]
+			if lines == nil {
+				lines = lineCoverage{}
+				files[class.FileName] = lines
+			}
+			for _, line := range class.Lines {
+				// Hits may be reported as floats, e.g. by coverage tools for .NET.
+				hits, err := strconv.ParseFloat(line.Hits, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid hits %q for line %d of %s", line.Hits, line.Number, class.FileName)
+				}
+				if count, ok := lines[line.Number]; !ok || int(hits) > count {
+					lines[line.Number] = int(hits)
+				}
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no classes in Cobertura XML")
+	}
+	return lineProfiles(files), nil
+}
+
+func rate(covered, total int) string {
+	if total == 0 {
+		return "1"
+	}
+	return strconv.FormatFloat(float64(covered)/float64(total), 'f', 4, 64)
+}
+
+// DumpCobertura dumps the profiles given to writer as a Cobertura XML report, with a package per
+// directory and a class per file. Lines shared by several blocks get the count of the least
+// covered block. Branch coverage isn't available, so it's always reported as 0.
+func DumpCobertura(profiles []*cover.Profile, writer io.Writer) error {
+	if len(profiles) == 0 {
+		return errors.New("can't write an empty profile")
+	}
+	report := coberturaCoverage{BranchRate: "0", Version: "gopherage"}
+	packages := map[string]int{}
+	packageLines := map[string][2]int{}
+	for _, profile := range profiles {
+		lines := lineCounts(profile)
+		class := coberturaClass{
+			Name:       path.Base(profile.FileName),
+			FileName:   profile.FileName,
+			LineRate:   rate(lines.covered(), len(lines)),
+			BranchRate: "0",
+			Complexity: "0",
+		}
+		for _, line := range lines.sortedLines() {
+			class.Lines = append(class.Lines, coberturaLine{Number: line, Hits: strconv.Itoa(lines[line])})
+		}
+
+		dir := path.Dir(profile.FileName)
+		i, ok := packages[dir]
+		if !ok {
+			i = len(report.Packages)
+			packages[dir] = i
+			report.Packages = append(report.Packages, coberturaPackage{Name: dir, BranchRate: "0", Complexity: "0"})
+		}
+		report.Packages[i].Classes = append(report.Packages[i].Classes, class)
+		counts := packageLines[dir]
+		packageLines[dir] = [2]int{counts[0] + lines.covered(), counts[1] + len(lines)}
+		report.LinesCovered += lines.covered()
+		report.LinesValid += len(lines)
+	}
+	for i, pkg := range report.Packages {
+		counts := packageLines[pkg.Name]
+		report.Packages[i].LineRate = rate(counts[0], counts[1])
+	}
+	report.LineRate = rate(report.LinesCovered, report.LinesValid)
+
+	if _, err := io.WriteString(writer, xml.Header+`<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">`+"\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cov
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// ChangedLines maps file paths, relative to the repository root, to the sorted line numbers
+// added or modified in the new version of each file.
+type ChangedLines map[string][]int
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseUnifiedDiff returns the lines changed by a unified diff, as produced by git diff.
+// Deleted files and removed lines are ignored, since they can't be covered.
+func ParseUnifiedDiff(r io.Reader) (ChangedLines, error) {
+	changes := ChangedLines{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	var file string
+	// line is the line number in the new file of the next line of the hunk.
+	var line, remainingOld, remainingNew int
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if remainingOld > 0 || remainingNew > 0 {
+			switch {
+			case strings.HasPrefix(text, "+"):
+				if file != "" {
+					changes[file] = append(changes[file], line)
+				}
+				line++
+				remainingNew--
+			case strings.HasPrefix(text, "-"):
+				remainingOld--
+			case strings.HasPrefix(text, `\`):
+				// "\ No newline at end of file"
+			default:
+				// Context lines are usually prefixed with a space, which some tools strip from
+				// empty lines.
+				line++
+				remainingOld--
+				remainingNew--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			file = parseDiffPath(strings.TrimPrefix(text, "+++ "))
+		case strings.HasPrefix(text, "@@ "):
+			match := hunkHeaderRegex.FindStringSubmatch(text)
+			if match == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", n, text)
+			}
+			remainingOld = atoiOr(match[1], 1)
+			line = atoiOr(match[2], 0)
+			remainingNew = atoiOr(match[3], 1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if remainingOld > 0 || remainingNew > 0 {
+		return nil, fmt.Errorf("diff ends in the middle of a hunk of %s", file)
+	}
+	return changes, nil
+}
+
+// parseDiffPath returns the path of the new file in a "+++" line, or "" if the file was deleted.
+func parseDiffPath(path string) string {
+	// Some tools add a timestamp after a tab.
+	if i := strings.Index(path, "\t"); i >= 0 {
+		path = path[:i]
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	return strings.TrimPrefix(path, "b/")
+}
+
+func atoiOr(s string, fallback int) int {
+	if s == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+// LineRange is an inclusive range of line numbers.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// FileChangeCoverage is the coverage of the changed lines of a file.
+type FileChangeCoverage struct {
+	// FileName is the path of the file in the diff.
+	FileName string
+	// ChangedLines is the number of changed lines containing statements.
+	ChangedLines int
+	// CoveredLines is the number of changed lines whose statements all ran.
+	CoveredLines int
+	// UncoveredHunks are the runs of consecutive changed lines that weren't covered.
+	UncoveredHunks []LineRange
+}
+
+// ChangeCoverage is the coverage of the lines changed by a diff.
+type ChangeCoverage struct {
+	// Files holds the changed files with statements, sorted by name.
+	Files        []FileChangeCoverage
+	ChangedLines int
+	CoveredLines int
+}
+
+// Ratio returns the fraction of changed lines that are covered, or 1 if no line containing
+// statements was changed.
+func (c ChangeCoverage) Ratio() float64 {
+	return ratio(c.CoveredLines, c.ChangedLines)
+}
+
+// Ratio returns the fraction of changed lines that are covered, or 1 if no line containing
+// statements was changed.
+func (c FileChangeCoverage) Ratio() float64 {
+	return ratio(c.CoveredLines, c.ChangedLines)
+}
+
+func ratio(covered, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(covered) / float64(total)
+}
+
+// findProfile returns the profile of the file at path in the diff.
+// Profiles name files by import path, so if importPrefix (the import path of the repository root)
+// is empty the shortest profile whose file name ends with path is used.
+func findProfile(profiles []*cover.Profile, path, importPrefix string) *cover.Profile {
+	var found *cover.Profile
+	for _, p := range profiles {
+		if importPrefix != "" {
+			if p.FileName == importPrefix+"/"+path {
+				return p
+			}
+			continue
+		}
+		if p.FileName != path && !strings.HasSuffix(p.FileName, "/"+path) {
+			continue
+		}
+		if found == nil || len(p.FileName) < len(found.FileName) {
+			found = p
+		}
+	}
+	return found
+}
+
+// CoverChanges returns the coverage of the changed lines in profiles.
+// A changed line is covered if every statement block it belongs to ran; changed lines outside of
+// any block, like comments and declarations, are ignored. Changed files missing from the profiles
+// are left out.
+func CoverChanges(profiles []*cover.Profile, changes ChangedLines, importPrefix string) ChangeCoverage {
+	var paths []string
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	result := ChangeCoverage{}
+	for _, path := range paths {
+		profile := findProfile(profiles, path, importPrefix)
+		if profile == nil {
+			continue
+		}
+		file := coverFileChanges(profile, changes[path])
+		if file.ChangedLines == 0 {
+			continue
+		}
+		file.FileName = path
+		result.Files = append(result.Files, file)
+		result.ChangedLines += file.ChangedLines
+		result.CoveredLines += file.CoveredLines
+	}
+	return result
+}
+
+func coverFileChanges(profile *cover.Profile, lines []int) FileChangeCoverage {
+	result := FileChangeCoverage{}
+	counts := lineCounts(profile)
+	var hunk *LineRange
+	previous := -1
+	for _, line := range lines {
+		if line != previous+1 {
+			hunk = nil
+		}
+		previous = line
+
+		count, coverable := counts[line]
+		if !coverable {
+			// Keep the hunk open, so that e.g. comments don't split it.
+			continue
+		}
+		result.ChangedLines++
+		if count > 0 {
+			result.CoveredLines++
+			hunk = nil
+			continue
+		}
+		if hunk == nil {
+			result.UncoveredHunks = append(result.UncoveredHunks, LineRange{Start: line})
+			hunk = &result.UncoveredHunks[len(result.UncoveredHunks)-1]
+		}
+		hunk.End = line
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cov_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+	"k8s.io/test-infra/gopherage/pkg/cov"
+)
+
+const testDiff = `diff --git a/pkg/a.go b/pkg/a.go
+index 1234567..89abcde 100644
+--- a/pkg/a.go
++++ b/pkg/a.go
+@@ -2,4 +2,6 @@ package a
+ func A() {
+-	old()
++	first()
++	second()
+
++	// third
+ }
+@@ -20 +22,2 @@ func B() {
+-	x := 1
++	x := 2
++	y := 3
+diff --git a/pkg/deleted.go b/pkg/deleted.go
+deleted file mode 100644
+--- a/pkg/deleted.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package a
+-
+diff --git a/README.md b/README.md
+new file mode 100644
+--- /dev/null
++++ b/README.md
+@@ -0,0 +1 @@
++# readme
+\ No newline at end of file
+`
+
+func TestParseUnifiedDiff(t *testing.T) {
+	changes, err := cov.ParseUnifiedDiff(strings.NewReader(testDiff))
+	if err != nil {
+		t.Fatalf("ParseUnifiedDiff failed: %v", err)
+	}
+	expected := cov.ChangedLines{
+		"pkg/a.go":  {3, 4, 6, 22, 23},
+		"README.md": {1},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changed lines %v, got %v", expected, changes)
+	}
+}
+
+func TestParseUnifiedDiffTruncated(t *testing.T) {
+	if _, err := cov.ParseUnifiedDiff(strings.NewReader("+++ b/a.go\n@@ -1,2 +1,3 @@\n+a\n")); err == nil {
+		t.Fatal("expected ParseUnifiedDiff to fail on a truncated hunk")
+	}
+}
+
+func TestCoverChanges(t *testing.T) {
+	profiles := []*cover.Profile{
+		{
+			FileName: "k8s.io/repo/vendor/example.com/pkg/a.go",
+			Mode:     "set",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 1, StartCol: 1, EndLine: 30, EndCol: 1, NumStmt: 20, Count: 1},
+			},
+		},
+		{
+			FileName: "k8s.io/repo/pkg/a.go",
+			Mode:     "set",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 2, StartCol: 10, EndLine: 4, EndCol: 2, NumStmt: 2, Count: 1},
+				{StartLine: 4, StartCol: 10, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 0},
+				{StartLine: 10, StartCol: 10, EndLine: 11, EndCol: 2, NumStmt: 2, Count: 0},
+				{StartLine: 13, StartCol: 1, EndLine: 13, EndCol: 2, NumStmt: 1, Count: 0},
+				{StartLine: 15, StartCol: 10, EndLine: 16, EndCol: 2, NumStmt: 1, Count: 0},
+			},
+		},
+		{
+			FileName: "k8s.io/repo/pkg/b.go",
+			Mode:     "set",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 2, StartCol: 10, EndLine: 4, EndCol: 2, NumStmt: 2, Count: 1},
+			},
+		},
+	}
+	changes := cov.ChangedLines{
+		// 3 is covered, 4 is shared with an uncovered block, 7 has no statements, and the
+		// comment on 12 doesn't split the uncovered hunk.
+		"pkg/a.go": {3, 4, 7, 10, 11, 12, 13, 16},
+		"pkg/b.go": {8},
+		"pkg/c.go": {1},
+	}
+	expected := cov.ChangeCoverage{
+		Files: []cov.FileChangeCoverage{
+			{
+				FileName:       "pkg/a.go",
+				ChangedLines:   6,
+				CoveredLines:   1,
+				UncoveredHunks: []cov.LineRange{{Start: 4, End: 4}, {Start: 10, End: 13}, {Start: 16, End: 16}},
+			},
+		},
+		ChangedLines: 6,
+		CoveredLines: 1,
+	}
+
+	for _, prefix := range []string{"", "k8s.io/repo"} {
+		result := cov.CoverChanges(profiles, changes, prefix)
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("with import prefix %q: expected %+v, got %+v", prefix, expected, result)
+		}
+	}
+	if ratio := expected.Ratio(); ratio != 1.0/6 {
+		t.Errorf("expected ratio 1/6, got %v", ratio)
+	}
+	if ratio := (cov.ChangeCoverage{}).Ratio(); ratio != 1 {
+		t.Errorf("expected ratio 1 without changed statements, got %v", ratio)
+	}
+}
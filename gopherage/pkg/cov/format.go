@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cov
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+// Format is a coverage file format.
+type Format string
+
+const (
+	// FormatGo is the Go coverage profile format.
+	FormatGo Format = "go"
+	// FormatLCOV is the LCOV tracefile format, produced by e.g. istanbul and coverage.py.
+	FormatLCOV Format = "lcov"
+	// FormatCobertura is the Cobertura XML format.
+	FormatCobertura Format = "cobertura"
+)
+
+// ParseFormat returns the Format named name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case FormatGo, FormatLCOV, FormatCobertura:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown coverage format %q (expected %s, %s or %s)", name, FormatGo, FormatLCOV, FormatCobertura)
+}
+
+// DetectFormat guesses the format of a coverage file from its first bytes.
+// Anything that isn't recognizably LCOV or Cobertura is assumed to be a Go profile.
+func DetectFormat(head []byte) Format {
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(head, []byte("mode:")):
+		return FormatGo
+	case bytes.HasPrefix(head, []byte("<")):
+		return FormatCobertura
+	case bytes.HasPrefix(head, []byte("TN:")), bytes.HasPrefix(head, []byte("SF:")):
+		return FormatLCOV
+	}
+	return FormatGo
+}
+
+// DumpProfileAs dumps the profiles given to writer in the given format.
+func DumpProfileAs(profiles []*cover.Profile, format Format, writer io.Writer) error {
+	switch format {
+	case FormatLCOV:
+		return DumpLCOV(profiles, writer)
+	case FormatCobertura:
+		return DumpCobertura(profiles, writer)
+	}
+	return DumpProfile(profiles, writer)
+}
+
+// lineBlockEndCol is the end column of blocks converted from line coverage, which has no columns.
+// Such blocks span their whole line.
+const lineBlockEndCol = 1 << 16
+
+// lineCoverage is the hit count of each line of a file.
+type lineCoverage map[int]int
+
+// lineCounts returns the hit count of each line with statements in profile.
+// Go coverage is recorded per block, and a line may belong to several blocks, e.g. the end of an
+// if statement and the start of its else branch. The line is only as covered as its least covered
+// block, so it only counts as covered if all its statements ran.
+func lineCounts(profile *cover.Profile) lineCoverage {
+	lines := lineCoverage{}
+	for _, block := range profile.Blocks {
+		for line := block.StartLine; line <= block.EndLine; line++ {
+			if count, ok := lines[line]; !ok || block.Count < count {
+				lines[line] = block.Count
+			}
+		}
+	}
+	return lines
+}
+
+// sortedLines returns the line numbers of lines in order.
+func (lines lineCoverage) sortedLines() []int {
+	numbers := make([]int, 0, len(lines))
+	for line := range lines {
+		numbers = append(numbers, line)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// covered returns the number of lines that were hit.
+func (lines lineCoverage) covered() int {
+	covered := 0
+	for _, count := range lines {
+		if count > 0 {
+			covered++
+		}
+	}
+	return covered
+}
+
+// lineProfiles converts line coverage to profiles with one single-statement block per line.
+// The profiles are sorted by file name, as Go profiles are.
+func lineProfiles(files map[string]lineCoverage) []*cover.Profile {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	profiles := make([]*cover.Profile, 0, len(names))
+	for _, name := range names {
+		lines := files[name]
+		profile := &cover.Profile{FileName: name, Mode: "count"}
+		for _, line := range lines.sortedLines() {
+			profile.Blocks = append(profile.Blocks, cover.ProfileBlock{
+				StartLine: line,
+				StartCol:  1,
+				EndLine:   line,
+				EndCol:    lineBlockEndCol,
+				NumStmt:   1,
+				Count:     lines[line],
+			})
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
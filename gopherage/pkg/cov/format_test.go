@@ -0,0 +1,223 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cov_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+	"k8s.io/test-infra/gopherage/pkg/cov"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     string
+		expected cov.Format
+	}{
+		{name: "go profile", head: "mode: count\na.go:1.1,2.2 1 1\n", expected: cov.FormatGo},
+		{name: "lcov with test name", head: "TN:\nSF:a.js\n", expected: cov.FormatLCOV},
+		{name: "lcov without test name", head: "SF:a.js\nDA:1,1\n", expected: cov.FormatLCOV},
+		{name: "cobertura", head: `<?xml version="1.0" ?>` + "\n<coverage>", expected: cov.FormatCobertura},
+		{name: "cobertura with BOM and leading space", head: "\xef\xbb\xbf\n  <coverage>", expected: cov.FormatCobertura},
+		{name: "unknown defaults to go", head: "", expected: cov.FormatGo},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if format := cov.DetectFormat([]byte(tc.head)); format != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, format)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"go", "lcov", "cobertura"} {
+		if format, err := cov.ParseFormat(name); err != nil || string(format) != name {
+			t.Errorf("ParseFormat(%q) = %q, %v", name, format, err)
+		}
+	}
+	if _, err := cov.ParseFormat("html"); err == nil {
+		t.Error("expected ParseFormat to reject an unknown format")
+	}
+}
+
+// testLineProfiles is the result of parsing line coverage for a.go (lines 1 and 2) and b/c.go
+// (line 5).
+var testLineProfiles = []*cover.Profile{
+	{
+		FileName: "a.go",
+		Mode:     "count",
+		Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 1, EndCol: 1 << 16, NumStmt: 1, Count: 3},
+			{StartLine: 2, StartCol: 1, EndLine: 2, EndCol: 1 << 16, NumStmt: 1, Count: 0},
+		},
+	},
+	{
+		FileName: "b/c.go",
+		Mode:     "count",
+		Blocks: []cover.ProfileBlock{
+			{StartLine: 5, StartCol: 1, EndLine: 5, EndCol: 1 << 16, NumStmt: 1, Count: 1},
+		},
+	},
+}
+
+func TestParseLCOV(t *testing.T) {
+	// b/c.go is split across two records, which are summed.
+	tracefile := `TN:
+SF:b/c.go
+DA:5,1
+end_of_record
+SF:a.go
+FN:1,main
+DA:1,2
+DA:2,0
+LF:2
+LH:1
+end_of_record
+SF:a.go
+DA:1,1
+DA:2,-1
+end_of_record
+SF:b/c.go
+end_of_record
+`
+	profiles, err := cov.ParseLCOV(strings.NewReader(tracefile))
+	if err != nil {
+		t.Fatalf("ParseLCOV failed: %v", err)
+	}
+	if !reflect.DeepEqual(profiles, testLineProfiles) {
+		t.Errorf("expected %+v, got %+v", testLineProfiles, profiles)
+	}
+}
+
+func TestParseLCOVInvalid(t *testing.T) {
+	for _, tracefile := range []string{"", "TN:\n", "DA:1,1\n", "SF:a.go\nDA:1\n", "SF:a.go\nDA:x,1\n"} {
+		if _, err := cov.ParseLCOV(strings.NewReader(tracefile)); err == nil {
+			t.Errorf("expected ParseLCOV to fail on %q", tracefile)
+		}
+	}
+}
+
+func TestParseCobertura(t *testing.T) {
+	// a.go is split across two classes, and each line keeps its highest count.
+	report := `<?xml version="1.0" ?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+<coverage line-rate="0.5" branch-rate="0" version="1">
+  <sources><source>/src</source></sources>
+  <packages>
+    <package name="a">
+      <classes>
+        <class name="A" filename="a.go">
+          <lines>
+            <line number="1" hits="3"/>
+            <line number="2" hits="0" branch="true" condition-coverage="0% (0/2)"/>
+          </lines>
+        </class>
+        <class name="A$1" filename="a.go">
+          <lines><line number="1" hits="1.0"/></lines>
+        </class>
+      </classes>
+    </package>
+    <package name="b">
+      <classes>
+        <class name="C" filename="b/c.go">
+          <lines><line number="5" hits="1"/></lines>
+        </class>
+      </classes>
+    </package>
+  </packages>
+</coverage>
+`
+	profiles, err := cov.ParseCobertura(strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("ParseCobertura failed: %v", err)
+	}
+	if !reflect.DeepEqual(profiles, testLineProfiles) {
+		t.Errorf("expected %+v, got %+v", testLineProfiles, profiles)
+	}
+}
+
+func TestParseCoberturaInvalid(t *testing.T) {
+	for _, report := range []string{"", "<coverage/>", `<coverage><packages><package><classes><class filename="a.go"><lines><line number="1" hits="x"/></lines></class></classes></package></packages></coverage>`} {
+		if _, err := cov.ParseCobertura(strings.NewReader(report)); err == nil {
+			t.Errorf("expected ParseCobertura to fail on %q", report)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		format cov.Format
+		parse  func(*bytes.Buffer) ([]*cover.Profile, error)
+	}{
+		{format: cov.FormatLCOV, parse: func(b *bytes.Buffer) ([]*cover.Profile, error) { return cov.ParseLCOV(b) }},
+		{format: cov.FormatCobertura, parse: func(b *bytes.Buffer) ([]*cover.Profile, error) { return cov.ParseCobertura(b) }},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := cov.DumpProfileAs(testLineProfiles, tc.format, buf); err != nil {
+				t.Fatalf("DumpProfileAs failed: %v", err)
+			}
+			if format := cov.DetectFormat(buf.Bytes()); format != tc.format {
+				t.Errorf("expected output to be detected as %q, got %q", tc.format, format)
+			}
+			profiles, err := tc.parse(buf)
+			if err != nil {
+				t.Fatalf("failed to parse output: %v", err)
+			}
+			if !reflect.DeepEqual(profiles, testLineProfiles) {
+				t.Errorf("expected %+v, got %+v", testLineProfiles, profiles)
+			}
+		})
+	}
+}
+
+func TestDumpLCOVFromGoBlocks(t *testing.T) {
+	// Line 4 ends a covered block and starts an uncovered one, so not all of it ran.
+	profiles := []*cover.Profile{
+		{
+			FileName: "k8s.io/repo/a.go",
+			Mode:     "count",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 2, StartCol: 10, EndLine: 4, EndCol: 2, NumStmt: 2, Count: 5},
+				{StartLine: 4, StartCol: 10, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 0},
+			},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := cov.DumpLCOV(profiles, buf); err != nil {
+		t.Fatalf("DumpLCOV failed: %v", err)
+	}
+	expected := `TN:
+SF:k8s.io/repo/a.go
+DA:2,5
+DA:3,5
+DA:4,0
+DA:5,0
+LF:4
+LH:2
+end_of_record
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
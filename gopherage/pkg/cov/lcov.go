@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cov
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// ParseLCOV parses an LCOV tracefile into profiles with a block per line.
+// Only line coverage (DA records) is kept. Records for the same file, e.g. from concatenated
+// tracefiles, are summed.
+func ParseLCOV(reader io.Reader) ([]*cover.Profile, error) {
+	files := map[string]lineCoverage{}
+	var lines lineCoverage
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		record := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(record, "SF:"):
+			name := strings.TrimPrefix(record, "SF:")
+			if files[name] == nil {
+				files[name] = lineCoverage{}
+			}
+			lines = files[name]
+		case strings.HasPrefix(record, "DA:"):
+			if lines == nil {
+				return nil, fmt.Errorf("line %d: DA record outside of a file", n)
+			}
+			// DA:<line>,<hits>[,<checksum>]
+			fields := strings.Split(strings.TrimPrefix(record, "DA:"), ",")
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: invalid DA record %q", n, record)
+			}
+			line, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid line number in %q", n, record)
+			}
+			// Some tools report hits as floats, or negative for unknown.
+			hits, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid hit count in %q", n, record)
+			}
+			if hits < 0 {
+				hits = 0
+			}
+			lines[line] += int(hits)
+		case record == "end_of_record":
+			lines = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no files in LCOV tracefile")
+	}
+	return lineProfiles(files), nil
+}
+
+// DumpLCOV dumps the profiles given to writer as an LCOV tracefile, with the line coverage of each
+// file. Lines shared by several blocks get the count of the least covered block.
+func DumpLCOV(profiles []*cover.Profile, writer io.Writer) error {
+	if len(profiles) == 0 {
+		return errors.New("can't write an empty profile")
+	}
+	w := bufio.NewWriter(writer)
+	fmt.Fprintln(w, "TN:")
+	for _, profile := range profiles {
+		lines := lineCounts(profile)
+		fmt.Fprintf(w, "SF:%s\n", profile.FileName)
+		for _, line := range lines.sortedLines() {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, lines[line])
+		}
+		fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(lines), lines.covered())
+	}
+	return w.Flush()
+}
@@ -31,6 +31,8 @@ import (
 // MergeProfiles expects its arguments to be sorted: Profiles in alphabetical order,
 // and lines in files in the order those lines appear. These are standard constraints for
 // Go coverage profiles. The resulting profile will also obey these constraints.
+// Files converted from line coverage, e.g. LCOV or Cobertura, are merged line by line,
+// since their blocks never match those of a Go profile for the same file.
 func MergeProfiles(a []*cover.Profile, b []*cover.Profile) ([]*cover.Profile, error) {
 	var result []*cover.Profile
 	files := make(map[string]*cover.Profile, len(a))
@@ -44,7 +46,9 @@ func MergeProfiles(a []*cover.Profile, b []*cover.Profile) ([]*cover.Profile, er
 	// Now merge b into the result
 	for _, profile := range b {
 		dest, ok := files[profile.FileName]
-		if ok {
+		if ok && (isLineProfile(profile) || isLineProfile(dest)) {
+			*dest = *mergeLines(dest, profile)
+		} else if ok {
 			if err := ensureProfilesMatch(profile, dest); err != nil {
 				return nil, fmt.Errorf("error merging %s: %v", profile.FileName, err)
 			}
@@ -83,3 +87,26 @@ func MergeMultipleProfiles(profiles [][]*cover.Profile) ([]*cover.Profile, error
 	}
 	return result, nil
 }
+
+// isLineProfile checks if the profile was converted from line coverage, which only has
+// single-statement blocks spanning a whole line.
+func isLineProfile(profile *cover.Profile) bool {
+	if len(profile.Blocks) == 0 {
+		return false
+	}
+	for _, block := range profile.Blocks {
+		if block.StartLine != block.EndLine || block.StartCol != 1 || block.EndCol != lineBlockEndCol || block.NumStmt != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLines merges the hit counts of each line of two profiles of the same file.
+func mergeLines(a, b *cover.Profile) *cover.Profile {
+	lines := lineCounts(a)
+	for line, count := range lineCounts(b) {
+		lines[line] += count
+	}
+	return lineProfiles(map[string]lineCoverage{a.FileName: lines})[0]
+}
@@ -17,9 +17,11 @@ limitations under the License.
 package cov_test
 
 import (
+	"bytes"
 	"golang.org/x/tools/cover"
 	"k8s.io/test-infra/gopherage/pkg/cov"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -265,3 +267,35 @@ func TestMergeProfilesConflictingFileContents(t *testing.T) {
 		t.Fatalf("expected merging conflicting profiles to fail: %+v", result[0])
 	}
 }
+
+func TestMergeProfilesMixedFormats(t *testing.T) {
+	a := []*cover.Profile{
+		{
+			FileName: "a.go",
+			Mode:     "set",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 1, StartCol: 14, EndLine: 3, EndCol: 2, NumStmt: 2, Count: 1},
+				{StartLine: 3, StartCol: 8, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 0},
+			},
+		},
+	}
+	b, err := cov.ParseLCOV(strings.NewReader("SF:a.go\nDA:2,3\nDA:4,2\nDA:5,1\nend_of_record\n"))
+	if err != nil {
+		t.Fatalf("error parsing LCOV: %v", err)
+	}
+
+	result, err := cov.MergeProfiles(a, b)
+	if err != nil {
+		t.Fatalf("error merging profiles: %v", err)
+	}
+	var lcov bytes.Buffer
+	if err := cov.DumpLCOV(result, &lcov); err != nil {
+		t.Fatalf("error dumping LCOV: %v", err)
+	}
+	// Line 3 ends a covered block and starts an uncovered one, so it isn't covered by the
+	// Go profile.
+	expected := "TN:\nSF:a.go\nDA:1,1\nDA:2,4\nDA:3,0\nDA:4,2\nDA:5,1\nLF:5\nLH:4\nend_of_record\n"
+	if lcov.String() != expected {
+		t.Errorf("expected merged coverage\n%s\ngot\n%s", expected, lcov.String())
+	}
+}
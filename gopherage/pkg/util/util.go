@@ -17,6 +17,7 @@ limitations under the License.
 package util
 
 import (
+	"bufio"
 	"fmt"
 	"golang.org/x/tools/cover"
 	"io"
@@ -28,6 +29,12 @@ import (
 // DumpProfile dumps the profile to the given file destination.
 // If the destination is "-", it instead writes to stdout.
 func DumpProfile(destination string, profile []*cover.Profile) error {
+	return DumpProfileAs(destination, cov.FormatGo, profile)
+}
+
+// DumpProfileAs dumps the profile to the given file destination in the given format.
+// If the destination is "-", it instead writes to stdout.
+func DumpProfileAs(destination string, format cov.Format, profile []*cover.Profile) error {
 	var output io.Writer
 	if destination == "-" {
 		output = os.Stdout
@@ -39,14 +46,15 @@ func DumpProfile(destination string, profile []*cover.Profile) error {
 		defer f.Close()
 		output = f
 	}
-	err := cov.DumpProfile(profile, output)
+	err := cov.DumpProfileAs(profile, format, output)
 	if err != nil {
 		return fmt.Errorf("failed to dump profile: %v", err)
 	}
 	return nil
 }
 
-// LoadProfile loads a profile from the given filename.
+// LoadProfile loads a profile from the given filename, which may be a Go coverage profile, an
+// LCOV tracefile or a Cobertura XML report.
 // If the filename is "-", it instead reads from stdin.
 func LoadProfile(origin string) ([]*cover.Profile, error) {
 	filename := origin
@@ -65,5 +73,20 @@ func LoadProfile(origin string) ([]*cover.Profile, error) {
 		}
 		filename = tf.Name()
 	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	// Peek returns what it could read along with an error for short files.
+	head, _ := reader.Peek(512)
+	switch cov.DetectFormat(head) {
+	case cov.FormatLCOV:
+		return cov.ParseLCOV(reader)
+	case cov.FormatCobertura:
+		return cov.ParseCobertura(reader)
+	}
 	return cover.ParseProfiles(filename)
 }
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskcache
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	pio "k8s.io/test-infra/prow/io"
+)
+
+const (
+	// backingUploadWorkers is the number of concurrent uploads
+	backingUploadWorkers = 8
+	// backingQueueSize is the number of entries waiting for upload, beyond
+	// which new entries are only kept on disk
+	backingQueueSize = 10000
+)
+
+// backing is a second, slower tier of cache storage in GCS or S3
+//
+// entries are uploaded in the background once they are written to the disk,
+// and restored from object storage when they are missing from the disk,
+// e.g. because they were evicted, or because the disk was replaced
+type backing struct {
+	opener      pio.Opener
+	storagePath string
+	uploads     chan string
+	// pending counts the queued and in-progress uploads
+	pending sync.WaitGroup
+}
+
+// NewCacheWithBacking returns a new Cache like NewCache, which also keeps
+// entries under storagePath (e.g. gs://bucket/greenhouse) through opener
+func NewCacheWithBacking(diskRoot string, opener pio.Opener, storagePath string) *Cache {
+	c := NewCache(diskRoot)
+	c.backing = &backing{
+		opener:      opener,
+		storagePath: strings.TrimSuffix(storagePath, "/"),
+		uploads:     make(chan string, backingQueueSize),
+	}
+	for i := 0; i < backingUploadWorkers; i++ {
+		go c.uploadLoop()
+	}
+	return c
+}
+
+// path returns the object storage path of key
+func (b *backing) path(key string) string {
+	return b.storagePath + "/" + strings.TrimPrefix(key, "/")
+}
+
+func (b *backing) enqueue(key string) {
+	b.pending.Add(1)
+	select {
+	case b.uploads <- key:
+	default:
+		b.pending.Done()
+		logrus.WithField("key", key).Warn("Upload queue is full, not uploading entry to backing store")
+	}
+}
+
+// casHash returns the hash of content addressed entries, whose keys end in
+// cas/<hash> like in the HTTP caching protocol, or "" for other entries
+func casHash(key string) string {
+	dir, hash := path.Split(key)
+	if path.Base(dir) != "cas" {
+		return ""
+	}
+	return hash
+}
+
+func (c *Cache) uploadLoop() {
+	for key := range c.backing.uploads {
+		if err := c.upload(key); err != nil {
+			logrus.WithError(err).WithField("key", key).Error("Failed to upload entry to backing store")
+		}
+		c.backing.pending.Done()
+	}
+}
+
+// upload copies the entry at key to object storage
+func (c *Cache) upload(key string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remote := c.backing.path(key)
+	// content addressed entries never change, so they only need to be
+	// uploaded once
+	if casHash(key) != "" {
+		if _, err := c.backing.opener.Attributes(ctx, remote); err == nil {
+			return nil
+		}
+	}
+
+	f, err := os.Open(c.KeyToPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// already evicted
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	w, err := c.backing.opener.Writer(ctx, remote)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		// canceling before closing aborts the upload
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// restore copies the entry at key from object storage to the disk, returning
+// false if it isn't in object storage either
+// content addressed entries are verified like when they were first written
+func (c *Cache) restore(key string) (bool, error) {
+	r, err := c.backing.opener.Reader(context.Background(), c.backing.path(key))
+	if err != nil {
+		if pio.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer r.Close()
+	if err := c.put(key, r, casHash(key)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	pio "k8s.io/test-infra/prow/io"
+)
+
+// fakeOpener keeps objects in memory
+type fakeOpener struct {
+	pio.Opener
+	lock    sync.Mutex
+	objects map[string][]byte
+	writes  int
+}
+
+type fakeWriter struct {
+	bytes.Buffer
+	opener *fakeOpener
+	path   string
+}
+
+func (w *fakeWriter) Close() error {
+	w.opener.lock.Lock()
+	defer w.opener.lock.Unlock()
+	w.opener.objects[w.path] = w.Bytes()
+	w.opener.writes++
+	return nil
+}
+
+func (o *fakeOpener) Reader(_ context.Context, path string) (io.ReadCloser, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	content, ok := o.objects[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (o *fakeOpener) Writer(_ context.Context, path string, _ ...pio.WriterOptions) (io.WriteCloser, error) {
+	return &fakeWriter{opener: o, path: path}, nil
+}
+
+func (o *fakeOpener) Attributes(_ context.Context, path string) (pio.Attributes, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	content, ok := o.objects[path]
+	if !ok {
+		return pio.Attributes{}, os.ErrNotExist
+	}
+	return pio.Attributes{Size: int64(len(content))}, nil
+}
+
+func TestCacheWithBacking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-tests")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir for tests! %v", err)
+	}
+	defer os.RemoveAll(dir)
+	opener := &fakeOpener{objects: map[string][]byte{}}
+	cache := NewCacheWithBacking(dir, opener, "gs://bucket/greenhouse/")
+
+	blob := []byte{1, 3, 3, 7}
+	casKey := "/workspace/cas/" + hashBytes(blob)
+	acKey := "/workspace/ac/" + hashBytes([]byte("action"))
+	for _, key := range []string{casKey, acKey} {
+		if err := cache.Put(key, bytes.NewReader(blob), casHash(key)); err != nil {
+			t.Fatalf("Failed to put %s: %v", key, err)
+		}
+	}
+	// uploading the same blob again is skipped
+	if err := cache.Put(casKey, bytes.NewReader(blob), casHash(casKey)); err != nil {
+		t.Fatalf("Failed to put %s: %v", casKey, err)
+	}
+	cache.backing.pending.Wait()
+	if !bytes.Equal(opener.objects["gs://bucket/greenhouse"+casKey], blob) {
+		t.Errorf("Expected %s to be uploaded, got objects %v", casKey, opener.objects)
+	}
+	if opener.writes != 2 {
+		t.Errorf("Expected 2 uploads, got %d", opener.writes)
+	}
+
+	// corrupt the CAS entry in object storage, evict everything, and restore
+	opener.objects["gs://bucket/greenhouse"+casKey] = []byte{1, 3, 3, 8}
+	for _, key := range []string{casKey, acKey} {
+		if err := cache.Delete(key); err != nil {
+			t.Fatalf("Failed to delete %s: %v", key, err)
+		}
+	}
+	testCases := []struct {
+		Name   string
+		Key    string
+		Exists bool
+	}{
+		{
+			Name:   "Restored action",
+			Key:    acKey,
+			Exists: true,
+		},
+		{
+			Name: "Corrupted blob",
+			Key:  casKey,
+		},
+		{
+			Name: "Missing blob",
+			Key:  "/workspace/cas/" + hashBytes([]byte("missing")),
+		},
+	}
+	for _, tc := range testCases {
+		err := cache.Get(tc.Key, func(exists bool, contents io.ReadSeeker) error {
+			if exists != tc.Exists {
+				t.Errorf("Expected exists to be %t for test case '%s'", tc.Exists, tc.Name)
+			}
+			if !exists {
+				return nil
+			}
+			read, err := ioutil.ReadAll(contents)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(read, blob) {
+				t.Errorf("Contents did not match expected for test case '%s' (got: %v expected: %v)", tc.Name, read, blob)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Got unexpected error getting cache key for test case '%s': %v", tc.Name, err)
+		}
+	}
+
+	// restored entries are not uploaded again
+	cache.backing.pending.Wait()
+	if opener.writes != 2 {
+		t.Errorf("Expected no uploads of restored entries, got %d uploads", opener.writes)
+	}
+}
+
+func TestPutHashMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-tests")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir for tests! %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cache := NewCache(dir)
+	err = cache.Put("foo", bytes.NewReader([]byte{1}), hashBytes([]byte{2}))
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("Expected ErrHashMismatch, got %v", err)
+	}
+}
@@ -20,6 +20,7 @@ package diskcache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -36,10 +37,16 @@ import (
 // ReadHandler should be implemented by cache users for use with Cache.Get
 type ReadHandler func(exists bool, contents io.ReadSeeker) error
 
+// ErrHashMismatch is returned by Put when the content doesn't match the
+// expected SHA256
+var ErrHashMismatch = errors.New("hashes did not match")
+
 // Cache implements disk backed cache storage
 type Cache struct {
 	diskRoot string
 	logger   *logrus.Entry
+	// backing is nil unless entries are also kept in object storage
+	backing *backing
 }
 
 // NewCache returns a new Cache given the root directory that should be used
@@ -89,8 +96,19 @@ func removeTemp(path string) {
 
 // Put copies the content reader until the end into the cache at key
 // if contentSHA256 is not "" then the contents will only be stored in the
-// cache if the content's hex string SHA256 matches
+// cache if the content's hex string SHA256 matches, otherwise an
+// ErrHashMismatch error is returned
 func (c *Cache) Put(key string, content io.Reader, contentSHA256 string) error {
+	if err := c.put(key, content, contentSHA256); err != nil {
+		return err
+	}
+	if c.backing != nil {
+		c.backing.enqueue(key)
+	}
+	return nil
+}
+
+func (c *Cache) put(key string, content io.Reader, contentSHA256 string) error {
 	// make sure directory exists
 	path := c.KeyToPath(key)
 	dir := filepath.Dir(path)
@@ -124,8 +142,8 @@ func (c *Cache) Put(key string, content io.Reader, contentSHA256 string) error {
 		if actualContentSHA256 != contentSHA256 {
 			removeTemp(temp.Name())
 			return fmt.Errorf(
-				"hashes did not match for '%s', given: '%s' actual: '%s",
-				key, contentSHA256, actualContentSHA256)
+				"%w for '%s', given: '%s' actual: '%s",
+				ErrHashMismatch, key, contentSHA256, actualContentSHA256)
 		}
 	}
 
@@ -145,15 +163,26 @@ func (c *Cache) Put(key string, content io.Reader, contentSHA256 string) error {
 }
 
 // Get provides your readHandler with the contents at key
+// entries missing from the disk are first restored from object storage, if
+// the cache has a backing store
 func (c *Cache) Get(key string, readHandler ReadHandler) error {
 	path := c.KeyToPath(key)
 	f, err := os.Open(path)
+	if os.IsNotExist(err) && c.backing != nil {
+		restored, restoreErr := c.restore(key)
+		if restoreErr != nil {
+			logrus.WithError(restoreErr).WithField("key", key).Error("Failed to restore entry from backing store")
+		} else if restored {
+			f, err = os.Open(path)
+		}
+	}
 	if err != nil {
 		if os.IsNotExist(err) {
 			return readHandler(false, nil)
 		}
 		return fmt.Errorf("failed to get key: %v", err)
 	}
+	defer f.Close()
 	return readHandler(true, f)
 }
 
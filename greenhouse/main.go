@@ -23,22 +23,31 @@ limitations under the License.
 //
 // nursery assumes you are using SHA256
 //
+// if --grpc-port is set, the same cache is also served with the cache services
+// of the remote execution API [3], see the reapi package.
+//
 // [1] https://docs.bazel.build/versions/master/remote-caching.html
 // [2] https://docs.bazel.build/versions/master/remote-caching.html#http-caching-protocol
+// [3] https://github.com/bazelbuild/remote-apis
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
 	"k8s.io/test-infra/greenhouse/diskcache"
 	"k8s.io/test-infra/greenhouse/diskutil"
+	"k8s.io/test-infra/greenhouse/reapi"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/logrusutil"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -48,6 +57,7 @@ import (
 var dir = flag.String("dir", "", "location to store cache entries on disk")
 var host = flag.String("host", "", "host address to listen on")
 var cachePort = flag.Int("cache-port", 8080, "port to listen on for cache requests")
+var grpcPort = flag.Int("grpc-port", 0, "port to listen on for remote execution API gRPC cache requests, disabled if 0")
 var metricsPort = flag.Int("metrics-port", 9090, "port to listen on for prometheus metrics scraping")
 var metricsUpdateInterval = flag.Duration("metrics-update-interval", time.Second*10,
 	"interval between updating disk metrics")
@@ -60,6 +70,11 @@ var evictUntilPercentBlocksFree = flag.Float64("evict-until-percent-blocks-free"
 var diskCheckInterval = flag.Duration("disk-check-interval", time.Second*10,
 	"interval between checking disk usage (and potentially evicting entries)")
 
+// optional object storage tier
+var backingStoragePath = flag.String("backing-storage-path", "",
+	"if set, entries are also uploaded to this GCS or S3 path (e.g. gs://bucket/greenhouse), and restored from there when missing from --dir")
+var storageOptions prowflagutil.StorageClientOptions
+
 // global metrics object, see prometheus.go
 var promMetrics *prometheusMetrics
 
@@ -68,6 +83,7 @@ func init() {
 
 	logrus.SetOutput(os.Stdout)
 	promMetrics = initMetrics()
+	storageOptions.AddFlags(flag.CommandLine)
 }
 
 func main() {
@@ -76,7 +92,16 @@ func main() {
 		logrus.Fatal("--dir must be set!")
 	}
 
-	cache := diskcache.NewCache(*dir)
+	var cache *diskcache.Cache
+	if *backingStoragePath != "" {
+		opener, err := storageOptions.StorageClient(context.Background())
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create storage client")
+		}
+		cache = diskcache.NewCacheWithBacking(*dir, opener, *backingStoragePath)
+	} else {
+		cache = diskcache.NewCache(*dir)
+	}
 	go monitorDiskAndEvict(
 		cache, *diskCheckInterval,
 		*minPercentBlocksFree, *evictUntilPercentBlocksFree,
@@ -95,6 +120,27 @@ func main() {
 		).Fatal("ListenAndServe returned.")
 	}()
 
+	// listen for gRPC cache requests
+	if *grpcPort != 0 {
+		grpcAddr := fmt.Sprintf("%s:%d", *host, *grpcPort)
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to listen for gRPC requests.")
+		}
+		grpcServer := reapi.NewGRPCServer(cache, reapi.Metrics{
+			ActionCacheHits:   promMetrics.ActionCacheHits,
+			ActionCacheMisses: promMetrics.ActionCacheMisses,
+			CASHits:           promMetrics.CASHits,
+			CASMisses:         promMetrics.CASMisses,
+		})
+		go func() {
+			logrus.Infof("gRPC Cache Listening on: %s", grpcAddr)
+			logrus.WithField("server", "grpc").WithError(
+				grpcServer.Serve(listener),
+			).Fatal("Serve returned.")
+		}()
+	}
+
 	// listen for cache requests
 	cacheMux := http.NewServeMux()
 	cacheMux.Handle("/", cacheHandler(cache))
@@ -108,6 +154,9 @@ func main() {
 // file not found error, used below
 var errNotFound = errors.New("entry not found")
 
+// CAS keys must be SHA256 hashes
+var hashRegex = regexp.MustCompile("^[0-9a-f]{64}$")
+
 func cacheHandler(cache *diskcache.Cache) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger := logrus.WithFields(logrus.Fields{
@@ -173,8 +222,17 @@ func cacheHandler(cache *diskcache.Cache) http.Handler {
 			// the CAS is well, a CAS, which we can hash...
 			if requestingAction {
 				hash = ""
+			} else if !hashRegex.MatchString(hash) {
+				logger.Warn("received an upload with an invalid hash")
+				http.Error(w, "invalid SHA256 hash", http.StatusBadRequest)
+				return
 			}
 			err := cache.Put(r.URL.Path, r.Body, hash)
+			if errors.Is(err, diskcache.ErrHashMismatch) {
+				logger.WithError(err).Warn("rejected upload with mismatched content")
+				http.Error(w, "content does not match hash", http.StatusBadRequest)
+				return
+			}
 			if err != nil {
 				logger.WithError(err).Errorf("Failed to put: %v", r.URL.Path)
 				http.Error(w, "failed to put in cache", http.StatusInternalServerError)
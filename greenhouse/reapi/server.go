@@ -0,0 +1,524 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reapi serves the cache services of the Bazel Remote Execution API
+// v2 [1] from a greenhouse diskcache.Cache.
+//
+// Entries are stored under the same keys as the HTTP caching protocol, with
+// the instance name in place of the workspace: a blob uploaded over gRPC
+// with instance name "foo" can be downloaded from /foo/cas/<hash> and vice
+// versa.
+//
+// [1] https://github.com/bazelbuild/remote-apis
+package reapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	repb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"github.com/bazelbuild/remote-apis/build/bazel/semver"
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/bytestream"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/test-infra/greenhouse/diskcache"
+)
+
+// MaxBatchTotalSizeBytes is the maximum size of the blobs in a batch request.
+const MaxBatchTotalSizeBytes = 4 << 20
+
+// maxMessageSize leaves room for the encoding overhead of a full batch.
+const maxMessageSize = MaxBatchTotalSizeBytes + 1<<20
+
+// readChunkSize is the size of the responses of ByteStream reads.
+const readChunkSize = 64 << 10
+
+// emptyHash is the SHA256 of the empty blob, which is always available.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+var hashRegex = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// Metrics are the cache hit and miss counters, shared with the HTTP protocol.
+type Metrics struct {
+	ActionCacheHits   prometheus.Counter
+	ActionCacheMisses prometheus.Counter
+	CASHits           prometheus.Counter
+	CASMisses         prometheus.Counter
+}
+
+// Server implements the ContentAddressableStorage, ActionCache, Capabilities
+// and ByteStream services. Methods greenhouse doesn't implement, like
+// GetTree, are answered with Unimplemented.
+type Server struct {
+	repb.UnimplementedContentAddressableStorageServer
+
+	cache   *diskcache.Cache
+	metrics Metrics
+}
+
+// NewServer returns a Server storing entries in cache.
+func NewServer(cache *diskcache.Cache, metrics Metrics) *Server {
+	return &Server{cache: cache, metrics: metrics}
+}
+
+// NewGRPCServer returns a gRPC server serving the cache services from cache.
+func NewGRPCServer(cache *diskcache.Cache, metrics Metrics) *grpc.Server {
+	g := grpc.NewServer(grpc.MaxRecvMsgSize(maxMessageSize))
+	NewServer(cache, metrics).Register(g)
+	return g
+}
+
+// Register registers the services of s on g.
+func (s *Server) Register(g *grpc.Server) {
+	repb.RegisterContentAddressableStorageServer(g, s)
+	repb.RegisterActionCacheServer(g, s)
+	repb.RegisterCapabilitiesServer(g, s)
+	bytestream.RegisterByteStreamServer(g, s)
+}
+
+// key returns the cache key of an entry, where kind is "ac" or "cas".
+func key(instanceName, kind, hash string) (string, error) {
+	if !hashRegex.MatchString(hash) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid SHA256 hash %q", hash)
+	}
+	if instanceName == "" {
+		return "/" + kind + "/" + hash, nil
+	}
+	for _, part := range strings.Split(instanceName, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", status.Errorf(codes.InvalidArgument, "invalid instance name %q", instanceName)
+		}
+	}
+	return "/" + instanceName + "/" + kind + "/" + hash, nil
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) record(kind string, hit bool) {
+	switch {
+	case kind == "ac" && hit:
+		s.metrics.ActionCacheHits.Inc()
+	case kind == "ac":
+		s.metrics.ActionCacheMisses.Inc()
+	case hit:
+		s.metrics.CASHits.Inc()
+	default:
+		s.metrics.CASMisses.Inc()
+	}
+}
+
+// read returns the contents of key, and whether key is in the cache.
+func (s *Server) read(key string) ([]byte, bool, error) {
+	var data []byte
+	var found bool
+	err := s.cache.Get(key, func(exists bool, contents io.ReadSeeker) error {
+		if !exists {
+			return nil
+		}
+		found = true
+		var err error
+		data, err = ioutil.ReadAll(contents)
+		return err
+	})
+	return data, found, err
+}
+
+// exists returns whether key is in the cache.
+func (s *Server) exists(key string) (bool, error) {
+	var found bool
+	err := s.cache.Get(key, func(exists bool, contents io.ReadSeeker) error {
+		found = exists
+		if !exists {
+			return nil
+		}
+		// Reading updates the access time, so that entries the client was
+		// told about aren't evicted first.
+		_, err := contents.Read(make([]byte, 1))
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	})
+	return found, err
+}
+
+func internalError(logger *logrus.Entry, err error, message string) error {
+	logger.WithError(err).Error(message)
+	return status.Errorf(codes.Internal, "%s: %v", message, err)
+}
+
+// FindMissingBlobs returns the blobs missing from the CAS.
+func (s *Server) FindMissingBlobs(ctx context.Context, req *repb.FindMissingBlobsRequest) (*repb.FindMissingBlobsResponse, error) {
+	resp := &repb.FindMissingBlobsResponse{}
+	for _, digest := range req.BlobDigests {
+		if digest.GetHash() == emptyHash {
+			continue
+		}
+		k, err := key(req.InstanceName, "cas", digest.GetHash())
+		if err != nil {
+			return nil, err
+		}
+		found, err := s.exists(k)
+		if err != nil {
+			return nil, internalError(logrus.WithField("key", k), err, "failed to check blob")
+		}
+		if !found {
+			resp.MissingBlobDigests = append(resp.MissingBlobDigests, digest)
+		}
+	}
+	return resp, nil
+}
+
+// BatchUpdateBlobs uploads blobs to the CAS, reporting the result of each
+// upload.
+func (s *Server) BatchUpdateBlobs(ctx context.Context, req *repb.BatchUpdateBlobsRequest) (*repb.BatchUpdateBlobsResponse, error) {
+	var total int64
+	for _, r := range req.Requests {
+		total += int64(len(r.Data))
+	}
+	if total > MaxBatchTotalSizeBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d bytes exceeds the limit of %d bytes", total, MaxBatchTotalSizeBytes)
+	}
+
+	resp := &repb.BatchUpdateBlobsResponse{}
+	for _, r := range req.Requests {
+		resp.Responses = append(resp.Responses, &repb.BatchUpdateBlobsResponse_Response{
+			Digest: r.Digest,
+			Status: s.updateBlob(req.InstanceName, r),
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) updateBlob(instanceName string, r *repb.BatchUpdateBlobsRequest_Request) *rpcstatus.Status {
+	digest := r.GetDigest()
+	k, err := key(instanceName, "cas", digest.GetHash())
+	if err != nil {
+		return status.Convert(err).Proto()
+	}
+	if size := int64(len(r.Data)); size != digest.GetSizeBytes() {
+		return status.Newf(codes.InvalidArgument, "blob has %d bytes, digest has %d bytes", size, digest.GetSizeBytes()).Proto()
+	}
+	if hash := hashOf(r.Data); hash != digest.GetHash() {
+		return status.Newf(codes.InvalidArgument, "blob has hash %s", hash).Proto()
+	}
+	if err := s.cache.Put(k, bytes.NewReader(r.Data), digest.GetHash()); err != nil {
+		logrus.WithField("key", k).WithError(err).Error("Failed to put blob.")
+		return status.New(codes.Internal, err.Error()).Proto()
+	}
+	return status.New(codes.OK, "").Proto()
+}
+
+// BatchReadBlobs downloads blobs from the CAS, reporting the result of each
+// download.
+func (s *Server) BatchReadBlobs(ctx context.Context, req *repb.BatchReadBlobsRequest) (*repb.BatchReadBlobsResponse, error) {
+	var total int64
+	for _, digest := range req.Digests {
+		total += digest.GetSizeBytes()
+	}
+	if total > MaxBatchTotalSizeBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d bytes exceeds the limit of %d bytes", total, MaxBatchTotalSizeBytes)
+	}
+
+	resp := &repb.BatchReadBlobsResponse{}
+	for _, digest := range req.Digests {
+		r := &repb.BatchReadBlobsResponse_Response{Digest: digest, Status: status.New(codes.OK, "").Proto()}
+		if digest.GetHash() != emptyHash {
+			r.Data, r.Status = s.readBlob(req.InstanceName, digest)
+		}
+		resp.Responses = append(resp.Responses, r)
+	}
+	return resp, nil
+}
+
+func (s *Server) readBlob(instanceName string, digest *repb.Digest) ([]byte, *rpcstatus.Status) {
+	k, err := key(instanceName, "cas", digest.GetHash())
+	if err != nil {
+		return nil, status.Convert(err).Proto()
+	}
+	data, found, err := s.read(k)
+	if err != nil {
+		logrus.WithField("key", k).WithError(err).Error("Failed to get blob.")
+		return nil, status.New(codes.Internal, err.Error()).Proto()
+	}
+	s.record("cas", found)
+	if !found {
+		return nil, status.New(codes.NotFound, "blob not found").Proto()
+	}
+	return data, status.New(codes.OK, "").Proto()
+}
+
+// GetActionResult returns a cached action result.
+func (s *Server) GetActionResult(ctx context.Context, req *repb.GetActionResultRequest) (*repb.ActionResult, error) {
+	k, err := key(req.InstanceName, "ac", req.GetActionDigest().GetHash())
+	if err != nil {
+		return nil, err
+	}
+	data, found, err := s.read(k)
+	if err != nil {
+		return nil, internalError(logrus.WithField("key", k), err, "failed to get action result")
+	}
+	s.record("ac", found)
+	if !found {
+		return nil, status.Error(codes.NotFound, "action result not found")
+	}
+	result := &repb.ActionResult{}
+	if err := proto.Unmarshal(data, result); err != nil {
+		return nil, internalError(logrus.WithField("key", k), err, "failed to decode action result")
+	}
+	return result, nil
+}
+
+// UpdateActionResult stores an action result, encoded like the HTTP protocol
+// stores them. Like the HTTP protocol, greenhouse doesn't check that the
+// outputs it refers to are in the CAS.
+func (s *Server) UpdateActionResult(ctx context.Context, req *repb.UpdateActionResultRequest) (*repb.ActionResult, error) {
+	k, err := key(req.InstanceName, "ac", req.GetActionDigest().GetHash())
+	if err != nil {
+		return nil, err
+	}
+	result := req.GetActionResult()
+	if result == nil {
+		result = &repb.ActionResult{}
+	}
+	data, err := proto.Marshal(result)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid action result: %v", err)
+	}
+	if err := s.cache.Put(k, bytes.NewReader(data), ""); err != nil {
+		return nil, internalError(logrus.WithField("key", k), err, "failed to put action result")
+	}
+	return result, nil
+}
+
+// GetCapabilities returns the cache capabilities of greenhouse.
+func (s *Server) GetCapabilities(ctx context.Context, req *repb.GetCapabilitiesRequest) (*repb.ServerCapabilities, error) {
+	return &repb.ServerCapabilities{
+		CacheCapabilities: &repb.CacheCapabilities{
+			DigestFunction:                []repb.DigestFunction_Value{repb.DigestFunction_SHA256},
+			ActionCacheUpdateCapabilities: &repb.ActionCacheUpdateCapabilities{UpdateEnabled: true},
+			MaxBatchTotalSizeBytes:        MaxBatchTotalSizeBytes,
+		},
+		LowApiVersion:  &semver.SemVer{Major: 2},
+		HighApiVersion: &semver.SemVer{Major: 2},
+	}, nil
+}
+
+// parseDigest parses the "{hash}/{size}" part of a resource name.
+func parseDigest(hash, size string) (*repb.Digest, error) {
+	sizeBytes, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || sizeBytes < 0 {
+		return nil, fmt.Errorf("invalid size %q", size)
+	}
+	return &repb.Digest{Hash: hash, SizeBytes: sizeBytes}, nil
+}
+
+// parseReadResourceName parses "{instance_name}/blobs/{hash}/{size}".
+func parseReadResourceName(name string) (string, *repb.Digest, error) {
+	parts := strings.Split(name, "/")
+	n := len(parts)
+	if n < 3 || parts[n-3] != "blobs" {
+		return "", nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q", name)
+	}
+	digest, err := parseDigest(parts[n-2], parts[n-1])
+	if err != nil {
+		return "", nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q: %v", name, err)
+	}
+	return strings.Join(parts[:n-3], "/"), digest, nil
+}
+
+// parseWriteResourceName parses
+// "{instance_name}/uploads/{uuid}/blobs/{hash}/{size}[/{metadata}]".
+func parseWriteResourceName(name string) (string, *repb.Digest, error) {
+	parts := strings.Split(name, "/")
+	for i := 0; i+4 < len(parts); i++ {
+		if parts[i] != "uploads" || parts[i+2] != "blobs" {
+			continue
+		}
+		digest, err := parseDigest(parts[i+3], parts[i+4])
+		if err != nil {
+			return "", nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q: %v", name, err)
+		}
+		return strings.Join(parts[:i], "/"), digest, nil
+	}
+	return "", nil, status.Errorf(codes.InvalidArgument, "invalid resource name %q", name)
+}
+
+// Read streams a blob from the CAS.
+func (s *Server) Read(req *bytestream.ReadRequest, stream bytestream.ByteStream_ReadServer) error {
+	instanceName, digest, err := parseReadResourceName(req.ResourceName)
+	if err != nil {
+		return err
+	}
+	if req.ReadOffset < 0 || req.ReadOffset > digest.SizeBytes {
+		return status.Errorf(codes.OutOfRange, "invalid read offset %d", req.ReadOffset)
+	}
+	if req.ReadLimit < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid read limit %d", req.ReadLimit)
+	}
+	if digest.Hash == emptyHash {
+		return nil
+	}
+	k, err := key(instanceName, "cas", digest.Hash)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	err = s.cache.Get(k, func(exists bool, contents io.ReadSeeker) error {
+		found = exists
+		if !exists {
+			return nil
+		}
+		if _, err := contents.Seek(req.ReadOffset, io.SeekStart); err != nil {
+			return err
+		}
+		var reader io.Reader = contents
+		if req.ReadLimit > 0 {
+			reader = io.LimitReader(contents, req.ReadLimit)
+		}
+		buffer := make([]byte, readChunkSize)
+		for {
+			n, err := reader.Read(buffer)
+			if n > 0 {
+				if err := stream.Send(&bytestream.ReadResponse{Data: buffer[:n]}); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return internalError(logrus.WithField("key", k), err, "failed to read blob")
+	}
+	s.record("cas", found)
+	if !found {
+		return status.Error(codes.NotFound, "blob not found")
+	}
+	return nil
+}
+
+// Write streams a blob to the CAS. The blob is only stored once it was
+// completely written and its hash verified.
+func (s *Server) Write(stream bytestream.ByteStream_WriteServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	instanceName, digest, err := parseWriteResourceName(req.ResourceName)
+	if err != nil {
+		return err
+	}
+	k, err := key(instanceName, "cas", digest.Hash)
+	if err != nil {
+		return err
+	}
+
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := s.cache.Put(k, reader, digest.Hash)
+		reader.CloseWithError(err)
+		done <- err
+	}()
+	// abort makes Put fail, so that nothing is stored.
+	abort := func(err error) error {
+		writer.CloseWithError(err)
+		<-done
+		return err
+	}
+
+	hasher := sha256.New()
+	var committed int64
+	for {
+		if req.WriteOffset != committed {
+			return abort(status.Errorf(codes.InvalidArgument, "write offset %d doesn't match the %d bytes written", req.WriteOffset, committed))
+		}
+		if _, err := writer.Write(req.Data); err != nil {
+			return abort(internalError(logrus.WithField("key", k), err, "failed to write blob"))
+		}
+		hasher.Write(req.Data)
+		committed += int64(len(req.Data))
+		if req.FinishWrite {
+			break
+		}
+		if req, err = stream.Recv(); err != nil {
+			if err == io.EOF {
+				err = status.Error(codes.InvalidArgument, "write was not finished")
+			}
+			return abort(err)
+		}
+	}
+	if committed != digest.SizeBytes {
+		return abort(status.Errorf(codes.InvalidArgument, "blob has %d bytes, digest has %d bytes", committed, digest.SizeBytes))
+	}
+	if hash := hex.EncodeToString(hasher.Sum(nil)); hash != digest.Hash {
+		return abort(status.Errorf(codes.InvalidArgument, "blob has hash %s", hash))
+	}
+	writer.Close()
+	if err := <-done; err != nil {
+		return internalError(logrus.WithField("key", k), err, "failed to put blob")
+	}
+	return stream.SendAndClose(&bytestream.WriteResponse{CommittedSize: committed})
+}
+
+// QueryWriteStatus reports whether a blob was written. Partial writes are
+// discarded, so they can't be resumed.
+func (s *Server) QueryWriteStatus(ctx context.Context, req *bytestream.QueryWriteStatusRequest) (*bytestream.QueryWriteStatusResponse, error) {
+	instanceName, digest, err := parseWriteResourceName(req.ResourceName)
+	if err != nil {
+		return nil, err
+	}
+	if digest.Hash != emptyHash {
+		k, err := key(instanceName, "cas", digest.Hash)
+		if err != nil {
+			return nil, err
+		}
+		found, err := s.exists(k)
+		if err != nil {
+			return nil, internalError(logrus.WithField("key", k), err, "failed to check blob")
+		}
+		if !found {
+			return nil, status.Error(codes.NotFound, "blob not found")
+		}
+	}
+	return &bytestream.QueryWriteStatusResponse{CommittedSize: digest.SizeBytes, Complete: true}, nil
+}
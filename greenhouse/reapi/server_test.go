@@ -0,0 +1,365 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+
+	repb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"github.com/bazelbuild/remote-apis/build/bazel/semver"
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/genproto/googleapis/bytestream"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/test-infra/greenhouse/diskcache"
+)
+
+func newMetrics() Metrics {
+	return Metrics{
+		ActionCacheHits:   prometheus.NewCounter(prometheus.CounterOpts{Name: "ac_hits"}),
+		ActionCacheMisses: prometheus.NewCounter(prometheus.CounterOpts{Name: "ac_misses"}),
+		CASHits:           prometheus.NewCounter(prometheus.CounterOpts{Name: "cas_hits"}),
+		CASMisses:         prometheus.NewCounter(prometheus.CounterOpts{Name: "cas_misses"}),
+	}
+}
+
+// serve starts a server and returns a client connection to it.
+func serve(t *testing.T, cache *diskcache.Cache, metrics Metrics) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := NewGRPCServer(cache, metrics)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newCache(t *testing.T) *diskcache.Cache {
+	dir, err := ioutil.TempDir("", "reapi")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return diskcache.NewCache(dir)
+}
+
+func digestOf(data string) *repb.Digest {
+	return &repb.Digest{Hash: hashOf([]byte(data)), SizeBytes: int64(len(data))}
+}
+
+// equalDigests compares digests by value, since decoded messages carry
+// internal state.
+func equalDigests(a, b []*repb.Digest) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestContentAddressableStorage(t *testing.T) {
+	cache := newCache(t)
+	metrics := newMetrics()
+	client := repb.NewContentAddressableStorageClient(serve(t, cache, metrics))
+	ctx := context.Background()
+
+	stored, other, missing := digestOf("stored"), digestOf("other"), digestOf("missing")
+	update := &repb.BatchUpdateBlobsRequest{
+		InstanceName: "workspace",
+		Requests: []*repb.BatchUpdateBlobsRequest_Request{
+			{Digest: stored, Data: []byte("stored")},
+			{Digest: other, Data: []byte("not other")},
+			{Digest: &repb.Digest{Hash: "../../etc", SizeBytes: 1}, Data: []byte("x")},
+		},
+	}
+	updated, err := client.BatchUpdateBlobs(ctx, update)
+	if err != nil {
+		t.Fatalf("BatchUpdateBlobs: %v", err)
+	}
+	var codesByHash []codes.Code
+	for _, r := range updated.Responses {
+		codesByHash = append(codesByHash, codes.Code(r.GetStatus().GetCode()))
+	}
+	if expected := []codes.Code{codes.OK, codes.InvalidArgument, codes.InvalidArgument}; !reflect.DeepEqual(expected, codesByHash) {
+		t.Errorf("expected upload results %v, got %v", expected, codesByHash)
+	}
+
+	// Blobs are shared with the HTTP protocol.
+	err = cache.Get("/workspace/cas/"+stored.Hash, func(exists bool, contents io.ReadSeeker) error {
+		if !exists {
+			t.Error("expected the blob to be stored under the HTTP protocol key")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("failed to get blob: %v", err)
+	}
+
+	find := &repb.FindMissingBlobsRequest{InstanceName: "workspace", BlobDigests: []*repb.Digest{stored, other, missing, digestOf("")}}
+	found, err := client.FindMissingBlobs(ctx, find)
+	if err != nil {
+		t.Fatalf("FindMissingBlobs: %v", err)
+	}
+	if expected := []*repb.Digest{other, missing}; !equalDigests(expected, found.MissingBlobDigests) {
+		t.Errorf("expected missing blobs %v, got %v", expected, found.MissingBlobDigests)
+	}
+
+	// Instances don't share blobs.
+	find.InstanceName = ""
+	if found, err = client.FindMissingBlobs(ctx, find); err != nil {
+		t.Fatalf("FindMissingBlobs: %v", err)
+	}
+	if expected := []*repb.Digest{stored, other, missing}; !equalDigests(expected, found.MissingBlobDigests) {
+		t.Errorf("expected missing blobs %v for another instance, got %v", expected, found.MissingBlobDigests)
+	}
+
+	read := &repb.BatchReadBlobsRequest{InstanceName: "workspace", Digests: []*repb.Digest{stored, missing}}
+	blobs, err := client.BatchReadBlobs(ctx, read)
+	if err != nil {
+		t.Fatalf("BatchReadBlobs: %v", err)
+	}
+	expected := &repb.BatchReadBlobsResponse{
+		Responses: []*repb.BatchReadBlobsResponse_Response{
+			{Digest: stored, Data: []byte("stored"), Status: &rpcstatus.Status{}},
+			{Digest: missing, Status: &rpcstatus.Status{Code: int32(codes.NotFound), Message: "blob not found"}},
+		},
+	}
+	if !proto.Equal(expected, blobs) {
+		t.Errorf("expected blobs %v, got %v", expected, blobs)
+	}
+	if hits, misses := testutil.ToFloat64(metrics.CASHits), testutil.ToFloat64(metrics.CASMisses); hits != 1 || misses != 1 {
+		t.Errorf("expected 1 CAS hit and 1 miss, got %v and %v", hits, misses)
+	}
+
+	tooLarge := &repb.BatchReadBlobsRequest{Digests: []*repb.Digest{{Hash: stored.Hash, SizeBytes: MaxBatchTotalSizeBytes + 1}}}
+	if _, err := client.BatchReadBlobs(ctx, tooLarge); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a batch over the limit, got %v", err)
+	}
+	tree, err := client.GetTree(ctx, &repb.GetTreeRequest{RootDigest: stored})
+	if err == nil {
+		_, err = tree.Recv()
+	}
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected GetTree to be unimplemented, got %v", err)
+	}
+}
+
+func TestActionCache(t *testing.T) {
+	metrics := newMetrics()
+	cache := newCache(t)
+	client := repb.NewActionCacheClient(serve(t, cache, metrics))
+	ctx := context.Background()
+	action := digestOf("action")
+
+	get := &repb.GetActionResultRequest{InstanceName: "workspace", ActionDigest: action}
+	if _, err := client.GetActionResult(ctx, get); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for a missing action result, got %v", err)
+	}
+	update := &repb.UpdateActionResultRequest{
+		InstanceName: "workspace",
+		ActionDigest: action,
+		ActionResult: &repb.ActionResult{
+			ExitCode:    1,
+			OutputFiles: []*repb.OutputFile{{Path: "out", Digest: digestOf("out")}},
+		},
+	}
+	if _, err := client.UpdateActionResult(ctx, update); err != nil {
+		t.Fatalf("UpdateActionResult: %v", err)
+	}
+	result, err := client.GetActionResult(ctx, get)
+	if err != nil {
+		t.Fatalf("GetActionResult: %v", err)
+	}
+	if !proto.Equal(update.ActionResult, result) {
+		t.Errorf("expected action result %v, got %v", update.ActionResult, result)
+	}
+
+	// Action results are stored like the HTTP protocol stores them.
+	err = cache.Get("/workspace/ac/"+action.Hash, func(exists bool, contents io.ReadSeeker) error {
+		if !exists {
+			t.Fatal("expected the action result to be stored under the HTTP protocol key")
+		}
+		data, err := ioutil.ReadAll(contents)
+		if err != nil {
+			return err
+		}
+		stored := &repb.ActionResult{}
+		if err := proto.Unmarshal(data, stored); err != nil {
+			return err
+		}
+		if !proto.Equal(update.ActionResult, stored) {
+			t.Errorf("expected stored action result %v, got %v", update.ActionResult, stored)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("failed to get action result: %v", err)
+	}
+	if hits, misses := testutil.ToFloat64(metrics.ActionCacheHits), testutil.ToFloat64(metrics.ActionCacheMisses); hits != 1 || misses != 1 {
+		t.Errorf("expected 1 action cache hit and 1 miss, got %v and %v", hits, misses)
+	}
+}
+
+func TestGetCapabilities(t *testing.T) {
+	client := repb.NewCapabilitiesClient(serve(t, newCache(t), newMetrics()))
+	capabilities, err := client.GetCapabilities(context.Background(), &repb.GetCapabilitiesRequest{})
+	if err != nil {
+		t.Fatalf("GetCapabilities: %v", err)
+	}
+	expected := &repb.ServerCapabilities{
+		CacheCapabilities: &repb.CacheCapabilities{
+			DigestFunction:                []repb.DigestFunction_Value{repb.DigestFunction_SHA256},
+			ActionCacheUpdateCapabilities: &repb.ActionCacheUpdateCapabilities{UpdateEnabled: true},
+			MaxBatchTotalSizeBytes:        MaxBatchTotalSizeBytes,
+		},
+		LowApiVersion:  &semver.SemVer{Major: 2},
+		HighApiVersion: &semver.SemVer{Major: 2},
+	}
+	if !proto.Equal(expected, capabilities) {
+		t.Errorf("expected capabilities %v, got %v", expected, capabilities)
+	}
+}
+
+func write(client bytestream.ByteStreamClient, resourceName string, chunks ...string) error {
+	stream, err := client.Write(context.Background())
+	if err != nil {
+		return err
+	}
+	var offset int64
+	for i, chunk := range chunks {
+		req := &bytestream.WriteRequest{WriteOffset: offset, Data: []byte(chunk), FinishWrite: i == len(chunks)-1}
+		if i == 0 {
+			req.ResourceName = resourceName
+		}
+		if err := stream.Send(req); err != nil {
+			break
+		}
+		offset += int64(len(chunk))
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+func read(client bytestream.ByteStreamClient, req *bytestream.ReadRequest) (string, error) {
+	stream, err := client.Read(context.Background(), req)
+	if err != nil {
+		return "", err
+	}
+	var data []byte
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return string(data), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		data = append(data, resp.Data...)
+	}
+}
+
+func TestByteStream(t *testing.T) {
+	conn := serve(t, newCache(t), newMetrics())
+	client := bytestream.NewByteStreamClient(conn)
+	blob := digestOf("hello world")
+	resourceName := func(kind string, d *repb.Digest) string {
+		return fmt.Sprintf("a/workspace/%s/%s/%d", kind, d.Hash, d.SizeBytes)
+	}
+	upload := resourceName("uploads/uuid/blobs", blob) + "/metadata"
+
+	if err := write(client, upload, "hello", " world"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := write(client, resourceName("uploads/uuid/blobs", digestOf("other")), "not other"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument when the size doesn't match, got %v", err)
+	}
+	if err := write(client, resourceName("uploads/uuid/blobs", digestOf("abcd")), "dcba"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument when the hash doesn't match, got %v", err)
+	}
+
+	written, err := client.QueryWriteStatus(context.Background(), &bytestream.QueryWriteStatusRequest{ResourceName: upload})
+	if err != nil || !written.Complete || written.CommittedSize != blob.SizeBytes {
+		t.Errorf("expected the write to be complete, got %v, %v", written, err)
+	}
+
+	cases := []struct {
+		name     string
+		req      *bytestream.ReadRequest
+		expected string
+		code     codes.Code
+	}{
+		{
+			name:     "whole blob",
+			req:      &bytestream.ReadRequest{ResourceName: resourceName("blobs", blob)},
+			expected: "hello world",
+		},
+		{
+			name:     "offset and limit",
+			req:      &bytestream.ReadRequest{ResourceName: resourceName("blobs", blob), ReadOffset: 6, ReadLimit: 3},
+			expected: "wor",
+		},
+		{
+			name: "offset past the end",
+			req:  &bytestream.ReadRequest{ResourceName: resourceName("blobs", blob), ReadOffset: 12},
+			code: codes.OutOfRange,
+		},
+		{
+			name: "missing blob",
+			req:  &bytestream.ReadRequest{ResourceName: resourceName("blobs", digestOf("missing"))},
+			code: codes.NotFound,
+		},
+		{
+			name: "invalid resource name",
+			req:  &bytestream.ReadRequest{ResourceName: "workspace/blobs/" + blob.Hash},
+			code: codes.InvalidArgument,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := read(client, tc.req)
+			if code := status.Code(err); code != tc.code {
+				t.Fatalf("expected code %v, got %v", tc.code, err)
+			}
+			if data != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, data)
+			}
+		})
+	}
+}
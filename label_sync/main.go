@@ -126,6 +126,10 @@ type options struct {
 	cssOutput       string
 	docsTemplate    string
 	docsOutput      string
+	pluginConfig    string
+	prowConfig      string
+	reportMarkdown  string
+	reportCSV       string
 	tokens          int
 	tokenBurst      int
 }
@@ -143,11 +147,15 @@ func gatherOptions() options {
 	fs.StringVar(&o.orgs, "orgs", "", "Comma separated list of orgs to sync")
 	fs.StringVar(&o.skipRepos, "skip", "", "Comma separated list of org/repos to skip syncing")
 	fs.StringVar(&o.token, "token", "", "Path to github oauth secret")
-	fs.StringVar(&o.action, "action", "sync", "One of: sync, docs")
+	fs.StringVar(&o.action, "action", "sync", "One of: sync, docs, css, report")
 	fs.StringVar(&o.cssTemplate, "css-template", "", "Path to template file for label css")
 	fs.StringVar(&o.cssOutput, "css-output", "", "Path to output file for css")
 	fs.StringVar(&o.docsTemplate, "docs-template", "", "Path to template file for label docs")
 	fs.StringVar(&o.docsOutput, "docs-output", "", "Path to output file for docs")
+	fs.StringVar(&o.pluginConfig, "plugin-config", "", "Path to plugins.yaml, used by the report action to find labels referenced by plugins")
+	fs.StringVar(&o.prowConfig, "prow-config", "", "Path to config.yaml, used by the report action to find labels referenced by tide queries")
+	fs.StringVar(&o.reportMarkdown, "report-markdown", "", "Path to output file for the Markdown usage report")
+	fs.StringVar(&o.reportCSV, "report-csv", "", "Path to output file for the CSV usage report")
 	fs.IntVar(&o.tokens, "tokens", defaultTokens, "Throttle hourly token consumption (0 to disable)")
 	fs.IntVar(&o.tokenBurst, "token-burst", defaultBurst, "Allow consuming a subset of hourly tokens in a short burst")
 	fs.Parse(os.Args[1:])
@@ -659,6 +667,7 @@ type client interface {
 	AddLabel(org, repo string, number int, label string) error
 	RemoveLabel(org, repo string, number int, label string) error
 	FindIssues(query, order string, ascending bool) ([]github.Issue, error)
+	CountIssues(query string) (int, error)
 	GetRepos(org string, isUser bool) ([]github.Repo, error)
 	GetRepoLabels(string, string) ([]github.Label, error)
 }
@@ -771,6 +780,14 @@ func main() {
 				logrus.WithError(err).Fatalf("failed to update %s", org)
 			}
 		}
+	case o.action == "report":
+		githubClient, err := newClient(o.token, o.tokens, o.tokenBurst, true, o.graphqlEndpoint, o.endpoint.Strings()...)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to create client")
+		}
+		if err := reportOrgs(o, githubClient, *config); err != nil {
+			logrus.WithError(err).Fatal("failed to report label usage")
+		}
 	default:
 		logrus.Fatalf("unrecognized action: %s", o.action)
 	}
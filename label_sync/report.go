@@ -0,0 +1,371 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// Statuses assigned to labels in the usage report.
+const (
+	// statusOK labels are configured and either used or referenced.
+	statusOK = "ok"
+	// statusUnused labels are configured but neither used by any issue or
+	// PR nor referenced by any plugin or tide query. For a previous name
+	// this means it can be dropped from the label's history.
+	statusUnused = "unused"
+	// statusDangling labels are referenced by a plugin or tide query but
+	// are not configured in labels.yaml.
+	statusDangling = "dangling"
+	// statusRenamed labels are previous names which are still applied to
+	// issues or PRs, or still referenced by a plugin or tide query.
+	statusRenamed = "renamed"
+)
+
+// issueCounter counts the issues and PRs matching a search query.
+type issueCounter interface {
+	CountIssues(query string) (int, error)
+}
+
+// reportEntry is a single row in the label usage report.
+type reportEntry struct {
+	// Name of the label
+	Name string
+	// Current is the current name of the label if Name is a previous name
+	Current string
+	// Open and Closed count the issues and PRs carrying the label
+	Open   int
+	Closed int
+	// References lists the plugins and tide queries using the label
+	References []string
+	// Status is one of the status* constants
+	Status string
+}
+
+// labelReferences returns the sources referencing each label, keyed by the
+// lower-cased label name. A label is referenced when its labels.yaml entry
+// names a prow plugin, or when the plugin or tide configuration use it.
+// Either configuration may be nil.
+func labelReferences(labels []Label, pc *plugins.Configuration, cfg *config.Config) map[string]sets.String {
+	refs := map[string]sets.String{}
+	add := func(label, source string) {
+		if label == "" {
+			return
+		}
+		name := strings.ToLower(label)
+		if _, ok := refs[name]; !ok {
+			refs[name] = sets.NewString()
+		}
+		refs[name].Insert(source)
+	}
+
+	for _, l := range labels {
+		if l.ProwPlugin != "" {
+			add(l.Name, l.ProwPlugin)
+		}
+	}
+	if pc != nil {
+		for _, l := range pc.Label.AdditionalLabels {
+			add(l, "label")
+		}
+		for _, r := range pc.RequireMatchingLabel {
+			add(r.MissingLabel, "require-matching-label")
+			if r.Re == nil {
+				continue
+			}
+			for _, l := range labels {
+				if r.Re.MatchString(l.Name) {
+					add(l.Name, "require-matching-label")
+				}
+			}
+		}
+		for _, orgRepo := range pc.ProjectManager.OrgRepos {
+			for _, project := range orgRepo.Projects {
+				for _, column := range project.Columns {
+					for _, l := range column.Labels {
+						add(l, "project-manager")
+					}
+				}
+			}
+		}
+	}
+	if cfg != nil {
+		for _, q := range cfg.Tide.Queries {
+			for _, l := range q.Labels {
+				add(l, "tide")
+			}
+			for _, l := range q.MissingLabels {
+				add(l, "tide")
+			}
+		}
+	}
+	return refs
+}
+
+// reportScopes returns the search qualifiers selecting the repos to report on,
+// using the same --only, --orgs and --skip semantics as the sync action.
+func reportScopes(only, orgs, skip string) ([]string, error) {
+	if only != "" {
+		repos, err := parseCommaDelimitedList(only)
+		if err != nil {
+			return nil, err
+		}
+		var scopes []string
+		for org, names := range repos {
+			for _, repo := range names {
+				scopes = append(scopes, fmt.Sprintf("repo:%s/%s", org, repo))
+			}
+		}
+		sort.Strings(scopes)
+		return scopes, nil
+	}
+
+	skipped := map[string][]string{}
+	if skip != "" {
+		var err error
+		if skipped, err = parseCommaDelimitedList(skip); err != nil {
+			return nil, err
+		}
+	}
+	var scopes []string
+	for _, org := range strings.Split(orgs, ",") {
+		org = strings.TrimSpace(org)
+		if org == "" {
+			continue
+		}
+		name, isUser := GetOrg(org)
+		qualifier := "org"
+		if isUser {
+			qualifier = "user"
+		}
+		scope := fmt.Sprintf("%s:%s", qualifier, name)
+		repos := append([]string(nil), skipped[org]...)
+		sort.Strings(repos)
+		for _, repo := range repos {
+			scope += fmt.Sprintf(" -repo:%s/%s", name, repo)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("either --only or --orgs must be set")
+	}
+	return scopes, nil
+}
+
+// countLabel returns the number of open and closed issues and PRs carrying
+// the label across all scopes.
+func countLabel(gc issueCounter, scopes []string, label string) (int, int, error) {
+	var open, closed int
+	for _, scope := range scopes {
+		n, err := gc.CountIssues(fmt.Sprintf("%s label:%q is:open", scope, label))
+		if err != nil {
+			return 0, 0, err
+		}
+		open += n
+		if n, err = gc.CountIssues(fmt.Sprintf("%s label:%q is:closed", scope, label)); err != nil {
+			return 0, 0, err
+		}
+		closed += n
+	}
+	return open, closed, nil
+}
+
+// buildReport counts the usage of every configured label and its previous
+// names, and cross-references them with refs. Labels which are referenced
+// but not configured are reported as dangling.
+func buildReport(gc issueCounter, scopes []string, labels []Label, refs map[string]sets.String) ([]reportEntry, error) {
+	var entries []reportEntry
+	configured := sets.NewString()
+	entry := func(name, current string) (reportEntry, error) {
+		configured.Insert(strings.ToLower(name))
+		open, closed, err := countLabel(gc, scopes, name)
+		if err != nil {
+			return reportEntry{}, fmt.Errorf("failed to count issues labeled %q: %v", name, err)
+		}
+		e := reportEntry{Name: name, Current: current, Open: open, Closed: closed}
+		if r, ok := refs[strings.ToLower(name)]; ok {
+			e.References = r.List()
+		}
+		switch {
+		case current != "" && (open+closed > 0 || len(e.References) > 0):
+			e.Status = statusRenamed
+		case open+closed == 0 && len(e.References) == 0:
+			e.Status = statusUnused
+		default:
+			e.Status = statusOK
+		}
+		return e, nil
+	}
+
+	for _, l := range labels {
+		e, err := entry(l.Name, "")
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+		for _, p := range l.Previously {
+			e, err := entry(p.Name, l.Name)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	var dangling []string
+	for name := range refs {
+		if !configured.Has(name) {
+			dangling = append(dangling, name)
+		}
+	}
+	sort.Strings(dangling)
+	for _, name := range dangling {
+		open, closed, err := countLabel(gc, scopes, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count issues labeled %q: %v", name, err)
+		}
+		entries = append(entries, reportEntry{
+			Name:       name,
+			Open:       open,
+			Closed:     closed,
+			References: refs[name].List(),
+			Status:     statusDangling,
+		})
+	}
+	return entries, nil
+}
+
+// writeReportMarkdown renders the report as a Markdown table followed by the
+// labels which need attention.
+func writeReportMarkdown(w io.Writer, entries []reportEntry) error {
+	var b strings.Builder
+	b.WriteString("# Label usage report\n\n")
+	b.WriteString("| Label | Previous name of | Open | Closed | Referenced by | Status |\n")
+	b.WriteString("| --- | --- | ---: | ---: | --- | --- |\n")
+	flagged := map[string][]string{}
+	for _, e := range entries {
+		current := ""
+		if e.Current != "" {
+			current = fmt.Sprintf("`%s`", e.Current)
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %d | %d | %s | %s |\n", e.Name, current, e.Open, e.Closed, strings.Join(e.References, ", "), e.Status)
+		if e.Status != statusOK {
+			flagged[e.Status] = append(flagged[e.Status], e.Name)
+		}
+	}
+	for _, section := range []struct{ status, title string }{
+		{statusDangling, "Dangling labels (referenced but not configured)"},
+		{statusUnused, "Unused labels (no issues, PRs or references)"},
+		{statusRenamed, "Renamed labels still in use"},
+	} {
+		if len(flagged[section.status]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", section.title)
+		for _, name := range flagged[section.status] {
+			fmt.Fprintf(&b, "- `%s`\n", name)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeReportCSV renders the report as CSV with a header row.
+func writeReportCSV(w io.Writer, entries []reportEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"label", "previous_of", "open", "closed", "referenced_by", "status"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := cw.Write([]string{e.Name, e.Current, strconv.Itoa(e.Open), strconv.Itoa(e.Closed), strings.Join(e.References, ";"), e.Status}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeReportFile writes the report to path using the given renderer.
+func writeReportFile(path string, entries []reportEntry, render func(io.Writer, []reportEntry) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := render(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// reportOrgs builds the label usage report for the configured repos and
+// writes it to the requested outputs.
+func reportOrgs(o options, gc issueCounter, labels Configuration) error {
+	scopes, err := reportScopes(o.onlyRepos, o.orgs, o.skipRepos)
+	if err != nil {
+		return err
+	}
+
+	var pc *plugins.Configuration
+	if o.pluginConfig != "" {
+		pa := &plugins.ConfigAgent{}
+		if err := pa.Load(o.pluginConfig, false); err != nil {
+			return fmt.Errorf("failed to load --plugin-config=%s: %v", o.pluginConfig, err)
+		}
+		pc = pa.Config()
+	}
+	var cfg *config.Config
+	if o.prowConfig != "" {
+		if cfg, err = config.Load(o.prowConfig, ""); err != nil {
+			return fmt.Errorf("failed to load --prow-config=%s: %v", o.prowConfig, err)
+		}
+	}
+
+	all := labels.Labels()
+	logrus.WithField("scopes", scopes).Infof("Counting usage of %d labels", len(all))
+	entries, err := buildReport(gc, scopes, all, labelReferences(all, pc, cfg))
+	if err != nil {
+		return err
+	}
+
+	if o.reportMarkdown != "" {
+		if err := writeReportFile(o.reportMarkdown, entries, writeReportMarkdown); err != nil {
+			return fmt.Errorf("failed to write --report-markdown=%s: %v", o.reportMarkdown, err)
+		}
+	}
+	if o.reportCSV != "" {
+		if err := writeReportFile(o.reportCSV, entries, writeReportCSV); err != nil {
+			return fmt.Errorf("failed to write --report-csv=%s: %v", o.reportCSV, err)
+		}
+	}
+	if o.reportMarkdown == "" && o.reportCSV == "" {
+		return writeReportMarkdown(os.Stdout, entries)
+	}
+	return nil
+}
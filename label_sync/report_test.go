@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+type fakeCounter struct {
+	counts  map[string]int
+	queries []string
+	err     error
+}
+
+func (f *fakeCounter) CountIssues(query string) (int, error) {
+	f.queries = append(f.queries, query)
+	return f.counts[query], f.err
+}
+
+func TestLabelReferences(t *testing.T) {
+	labels := []Label{
+		{Name: "lgtm", ProwPlugin: "lgtm"},
+		{Name: "kind/bug"},
+		{Name: "kind/feature"},
+		{Name: "priority/P0"},
+	}
+	pc := &plugins.Configuration{
+		Label: plugins.Label{AdditionalLabels: []string{"api-review"}},
+		RequireMatchingLabel: []plugins.RequireMatchingLabel{{
+			MissingLabel: "needs-kind",
+			Re:           regexp.MustCompile(`^kind/`),
+		}},
+		ProjectManager: plugins.ProjectManager{OrgRepos: map[string]plugins.ManagedOrgRepo{
+			"org": {Projects: map[string]plugins.ManagedProject{
+				"board": {Columns: []plugins.ManagedColumn{{Labels: []string{"priority/P0"}}}},
+			}},
+		}},
+	}
+	cfg := &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{Queries: config.TideQueries{{
+		Labels:        []string{"lgtm", "approved"},
+		MissingLabels: []string{"do-not-merge/hold"},
+	}}}}}
+
+	expected := map[string][]string{
+		"lgtm":              {"lgtm", "tide"},
+		"kind/bug":          {"require-matching-label"},
+		"kind/feature":      {"require-matching-label"},
+		"needs-kind":        {"require-matching-label"},
+		"api-review":        {"label"},
+		"priority/p0":       {"project-manager"},
+		"approved":          {"tide"},
+		"do-not-merge/hold": {"tide"},
+	}
+	refs := labelReferences(labels, pc, cfg)
+	actual := map[string][]string{}
+	for name, sources := range refs {
+		actual[name] = sources.List()
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected references %v, got %v", expected, actual)
+	}
+
+	if refs := labelReferences(labels, nil, nil); len(refs) != 1 {
+		t.Errorf("expected only the prowPlugin reference without configs, got %v", refs)
+	}
+}
+
+func TestReportScopes(t *testing.T) {
+	cases := []struct {
+		name     string
+		only     string
+		orgs     string
+		skip     string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "only repos",
+			only:     "org/b,org/a,other/c",
+			expected: []string{"repo:org/a", "repo:org/b", "repo:other/c"},
+		},
+		{
+			name:     "orgs and users",
+			orgs:     "org, user:someone",
+			expected: []string{"org:org", "user:someone"},
+		},
+		{
+			name:     "orgs with skipped repos",
+			orgs:     "org,other",
+			skip:     "org/z,org/a",
+			expected: []string{"org:org -repo:org/a -repo:org/z", "org:other"},
+		},
+		{
+			name: "nothing to report on",
+			err:  true,
+		},
+		{
+			name: "invalid only",
+			only: "org",
+			err:  true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			scopes, err := reportScopes(tc.only, tc.orgs, tc.skip)
+			if err != nil != tc.err {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(tc.expected, scopes) {
+				t.Errorf("expected scopes %v, got %v", tc.expected, scopes)
+			}
+		})
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	labels := []Label{
+		{Name: "kind/bug", Previously: []Label{{Name: "bug"}, {Name: "defect"}}},
+		{Name: "lgtm"},
+		{Name: "stale-label"},
+	}
+	refs := map[string]sets.String{
+		"lgtm":              sets.NewString("lgtm", "tide"),
+		"defect":            sets.NewString("tide"),
+		"do-not-merge/hold": sets.NewString("tide"),
+	}
+	gc := &fakeCounter{counts: map[string]int{
+		`org:org label:"kind/bug" is:open`:          3,
+		`org:org label:"kind/bug" is:closed`:        7,
+		`org:org label:"bug" is:closed`:             1,
+		`org:org label:"do-not-merge/hold" is:open`: 2,
+	}}
+
+	entries, err := buildReport(gc, []string{"org:org"}, labels, refs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []reportEntry{
+		{Name: "kind/bug", Open: 3, Closed: 7, Status: statusOK},
+		{Name: "bug", Current: "kind/bug", Closed: 1, Status: statusRenamed},
+		{Name: "defect", Current: "kind/bug", References: []string{"tide"}, Status: statusRenamed},
+		{Name: "lgtm", References: []string{"lgtm", "tide"}, Status: statusOK},
+		{Name: "stale-label", Status: statusUnused},
+		{Name: "do-not-merge/hold", Open: 2, References: []string{"tide"}, Status: statusDangling},
+	}
+	if !reflect.DeepEqual(expected, entries) {
+		t.Errorf("expected entries %+v, got %+v", expected, entries)
+	}
+	if len(gc.queries) != 12 {
+		t.Errorf("expected an open and a closed search per label, got %v", gc.queries)
+	}
+
+	if _, err := buildReport(&fakeCounter{err: errors.New("rate limited")}, []string{"org:org"}, labels, refs); err == nil {
+		t.Error("expected search errors to be returned")
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	entries := []reportEntry{
+		{Name: "kind/bug", Open: 3, Closed: 7, Status: statusOK},
+		{Name: "bug", Current: "kind/bug", Closed: 1, Status: statusRenamed},
+		{Name: "stale-label", Status: statusUnused},
+		{Name: "do-not-merge/hold", Open: 2, References: []string{"lgtm", "tide"}, Status: statusDangling},
+	}
+
+	var md bytes.Buffer
+	if err := writeReportMarkdown(&md, entries); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"| `kind/bug` |  | 3 | 7 |  | ok |",
+		"| `bug` | `kind/bug` | 0 | 1 |  | renamed |",
+		"| `do-not-merge/hold` |  | 2 | 0 | lgtm, tide | dangling |",
+		"## Dangling labels (referenced but not configured)\n\n- `do-not-merge/hold`\n",
+		"## Unused labels (no issues, PRs or references)\n\n- `stale-label`\n",
+		"## Renamed labels still in use\n\n- `bug`\n",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, md.String())
+		}
+	}
+
+	var csv bytes.Buffer
+	if err := writeReportCSV(&csv, entries); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `label,previous_of,open,closed,referenced_by,status
+kind/bug,,3,7,,ok
+bug,kind/bug,0,1,,renamed
+stale-label,,0,0,,unused
+do-not-merge/hold,,2,0,lgtm;tide,dangling
+`
+	if csv.String() != expected {
+		t.Errorf("expected csv:\n%s\ngot:\n%s", expected, csv.String())
+	}
+}
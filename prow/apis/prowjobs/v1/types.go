@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"mime"
 	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -367,6 +369,54 @@ type DecorationConfig struct {
 	// OauthTokenSecret is a Kubernetes secret that contains the OAuth token,
 	// which is going to be used for fetching a private repository.
 	OauthTokenSecret *OauthTokenSecret `json:"oauth_token_secret,omitempty"`
+	// CloneCache configures a git object cache that is shared across
+	// clonerefs runs and used as a reference when fetching refs.
+	CloneCache *CloneCache `json:"clone_cache,omitempty"`
+	// Steps runs the test containers as named steps when set. Each step
+	// gets its own build log, its own artifacts directory for junit and
+	// other files, and its own result in finished.json. Steps may depend
+	// on other steps, in which case they only start once all of their
+	// dependencies have passed. Steps name the job's own containers, so
+	// they are never defaulted and must be set on each job.
+	Steps []Step `json:"steps,omitempty"`
+	// CensorSecrets enables censoring the values of all secrets given to
+	// the test containers, as secret or projected volumes or through their
+	// environment, from the build logs and text artifacts before they are
+	// uploaded.
+	CensorSecrets *bool `json:"censor_secrets,omitempty"`
+	// CensoringOptions selects which artifacts are censored.
+	CensoringOptions *CensoringOptions `json:"censoring_options,omitempty"`
+}
+
+// CensoringOptions selects the artifacts that are censored of secrets.
+// Build logs are always censored, as are text artifacts selected here.
+type CensoringOptions struct {
+	// IncludeDirectories are globs matched against paths relative to the
+	// artifacts directory. If set, only matching artifacts are censored.
+	IncludeDirectories []string `json:"include_directories,omitempty"`
+	// ExcludeDirectories are globs matched against paths relative to the
+	// artifacts directory. Matching artifacts are never censored.
+	ExcludeDirectories []string `json:"exclude_directories,omitempty"`
+}
+
+// Validate ensures that all the globs are well-formed.
+func (c *CensoringOptions) Validate() error {
+	for _, glob := range append(append([]string{}, c.IncludeDirectories...), c.ExcludeDirectories...) {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %v", glob, err)
+		}
+	}
+	return nil
+}
+
+// Step configures how a single test container runs as part of a job.
+type Step struct {
+	// Name is the name of the test container this step runs.
+	Name string `json:"name"`
+	// DependsOn lists the names of the steps that must pass
+	// before this step starts. A step whose dependency fails
+	// is skipped.
+	DependsOn []string `json:"depends_on,omitempty"`
 }
 
 // Resources holds resource requests and limits for
@@ -412,6 +462,28 @@ type OauthTokenSecret struct {
 	Key string `json:"key,omitempty"`
 }
 
+// CloneCache holds the location of a git object cache shared by clonerefs.
+// The cache holds one bare repository per cloned repo and is expected to be
+// kept up to date by clonerefs running in cache maintenance mode.
+// Exactly one of HostPath or PersistentVolumeClaim must be set.
+type CloneCache struct {
+	// HostPath is a directory on the node that holds the cache.
+	HostPath string `json:"host_path,omitempty"`
+	// PersistentVolumeClaim is the name of a PVC that holds the cache.
+	PersistentVolumeClaim string `json:"persistent_volume_claim,omitempty"`
+}
+
+// Validate ensures the CloneCache points at exactly one location.
+func (c *CloneCache) Validate() error {
+	if c.HostPath == "" && c.PersistentVolumeClaim == "" {
+		return errors.New("one of host_path or persistent_volume_claim must be set")
+	}
+	if c.HostPath != "" && c.PersistentVolumeClaim != "" {
+		return errors.New("host_path and persistent_volume_claim are mutually exclusive")
+	}
+	return nil
+}
+
 // ApplyDefault applies the defaults for the ProwJob decoration. If a field has a zero value, it
 // replaces that with the value set in def.
 func (d *DecorationConfig) ApplyDefault(def *DecorationConfig) *DecorationConfig {
@@ -461,6 +533,15 @@ func (d *DecorationConfig) ApplyDefault(def *DecorationConfig) *DecorationConfig
 	if merged.OauthTokenSecret == nil {
 		merged.OauthTokenSecret = def.OauthTokenSecret
 	}
+	if merged.CloneCache == nil {
+		merged.CloneCache = def.CloneCache
+	}
+	if merged.CensorSecrets == nil {
+		merged.CensorSecrets = def.CensorSecrets
+	}
+	if merged.CensoringOptions == nil {
+		merged.CensoringOptions = def.CensoringOptions
+	}
 
 	return &merged
 }
@@ -500,6 +581,65 @@ func (d *DecorationConfig) Validate() error {
 	if d.OauthTokenSecret != nil && len(d.SSHKeySecrets) > 0 {
 		return errors.New("both OAuth token and SSH key secrets are specified")
 	}
+	if d.CloneCache != nil {
+		if err := d.CloneCache.Validate(); err != nil {
+			return fmt.Errorf("clone cache is invalid: %v", err)
+		}
+	}
+	if err := validateSteps(d.Steps); err != nil {
+		return fmt.Errorf("steps are invalid: %v", err)
+	}
+	if d.CensoringOptions != nil {
+		if err := d.CensoringOptions.Validate(); err != nil {
+			return fmt.Errorf("censoring options are invalid: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateSteps ensures step names are unique and that dependencies
+// refer to known steps without forming a cycle.
+func validateSteps(steps []Step) error {
+	deps := map[string][]string{}
+	for _, step := range steps {
+		if step.Name == "" {
+			return errors.New("step has no name")
+		}
+		if _, seen := deps[step.Name]; seen {
+			return fmt.Errorf("step %q is defined more than once", step.Name)
+		}
+		deps[step.Name] = step.DependsOn
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("step %q depends on itself", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("step %q depends on unknown step %q", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, step := range steps {
+		if err := visit(step.Name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -587,6 +727,57 @@ type GCSConfiguration struct {
 	// LocalOutputDir specifies a directory where files should be copied INSTEAD of uploading to blob storage.
 	// This option is useful for testing jobs that use the pod-utilities without actually uploading.
 	LocalOutputDir string `json:"local_output_dir,omitempty"`
+
+	// Retention specifies how long uploaded objects are kept before the
+	// reaper deletes them. started.json, finished.json and build logs are
+	// always kept so that the job history stays intact.
+	Retention *Retention `json:"retention,omitempty"`
+}
+
+// Retention holds how long each class of uploaded objects is kept. Values
+// are durations like 36h, and may also be given in days, e.g. 90d. Objects
+// in a class without a value are kept forever.
+type Retention struct {
+	// Logs is how long logs and metadata outside the artifacts
+	// directory are kept, e.g. clone-log.txt or podinfo.json.
+	Logs string `json:"logs,omitempty"`
+	// Artifacts is how long files in the artifacts directory are kept.
+	Artifacts string `json:"artifacts,omitempty"`
+}
+
+// ParseRetention parses a retention duration, which is either a
+// duration understood by time.ParseDuration or a number of days like 90d.
+func ParseRetention(value string) (time.Duration, error) {
+	var d time.Duration
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days %q: %v", value, err)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("retention %q must be positive", value)
+	}
+	return d, nil
+}
+
+// Validate ensures that all the retention durations can be parsed.
+func (r *Retention) Validate() error {
+	for class, value := range map[string]string{"logs": r.Logs, "artifacts": r.Artifacts} {
+		if value == "" {
+			continue
+		}
+		if _, err := ParseRetention(value); err != nil {
+			return fmt.Errorf("invalid %s retention: %v", class, err)
+		}
+	}
+	return nil
 }
 
 // ApplyDefault applies the defaults for GCSConfiguration decorations. If a field has a zero value,
@@ -636,6 +827,10 @@ func (g *GCSConfiguration) ApplyDefault(def *GCSConfiguration) *GCSConfiguration
 	if merged.LocalOutputDir == "" {
 		merged.LocalOutputDir = def.LocalOutputDir
 	}
+
+	if merged.Retention == nil {
+		merged.Retention = def.Retention.DeepCopy()
+	}
 	return &merged
 }
 
@@ -655,6 +850,11 @@ func (g *GCSConfiguration) Validate() error {
 	if g.PathStrategy != PathStrategyExplicit && (g.DefaultOrg == "" || g.DefaultRepo == "") {
 		return fmt.Errorf("default org and repo must be provided for GCS strategy %q", g.PathStrategy)
 	}
+	if g.Retention != nil {
+		if err := g.Retention.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -807,6 +1007,14 @@ type Refs struct {
 	// Multiheaded repos may need to not make this call.
 	// The git fetch <remote> <BaseRef> call occurs regardless.
 	SkipFetchHead bool `json:"skip_fetch_head,omitempty"`
+	// Filter is a git object filter, such as blob:none, that turns
+	// the clone into a partial clone. Filtered objects are fetched
+	// lazily from the remote when git needs them.
+	Filter string `json:"filter,omitempty"`
+	// SparseCheckout lists the directories to check out, in cone mode.
+	// All files at the root of the repository are always checked out.
+	// An empty list checks out the whole repository.
+	SparseCheckout []string `json:"sparse_checkout,omitempty"`
 }
 
 func (r Refs) String() string {
@@ -268,6 +268,8 @@ func TestApplyDefaultsAppliesDefaultsForAllFields(t *testing.T) {
 			if def.GCSConfiguration == nil {
 				def.GCSConfiguration = &GCSConfiguration{}
 			}
+			// Steps are specific to each job and never defaulted.
+			def.Steps = nil
 			defaulted := toDefault.ApplyDefault(def)
 
 			if diff := cmp.Diff(def, defaulted); diff != "" {
@@ -340,6 +342,81 @@ func TestRefsToString(t *testing.T) {
 	}
 }
 
+func TestValidateSteps(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		steps       []Step
+		errExpected bool
+	}{
+		{
+			name: "no steps",
+		},
+		{
+			name:  "independent steps",
+			steps: []Step{{Name: "lint"}, {Name: "test"}},
+		},
+		{
+			name:  "dependent steps",
+			steps: []Step{{Name: "publish", DependsOn: []string{"build", "test"}}, {Name: "build"}, {Name: "test", DependsOn: []string{"build"}}},
+		},
+		{
+			name:        "unnamed step",
+			steps:       []Step{{DependsOn: []string{"build"}}, {Name: "build"}},
+			errExpected: true,
+		},
+		{
+			name:        "duplicate step",
+			steps:       []Step{{Name: "build"}, {Name: "build"}},
+			errExpected: true,
+		},
+		{
+			name:        "unknown dependency",
+			steps:       []Step{{Name: "test", DependsOn: []string{"build"}}},
+			errExpected: true,
+		},
+		{
+			name:        "dependency cycle",
+			steps:       []Step{{Name: "a", DependsOn: []string{"c"}}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c", DependsOn: []string{"b"}}},
+			errExpected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateSteps(tc.steps); (err != nil) != tc.errExpected {
+				t.Errorf("Expected error %v, got %v", tc.errExpected, err)
+			}
+		})
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	var testCases = []struct {
+		value       string
+		expected    time.Duration
+		errExpected bool
+	}{
+		{value: "36h", expected: 36 * time.Hour},
+		{value: "90d", expected: 90 * 24 * time.Hour},
+		{value: "1.5d", errExpected: true},
+		{value: "0d", errExpected: true},
+		{value: "-1h", errExpected: true},
+		{value: "forever", errExpected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := ParseRetention(tc.value)
+			if (err != nil) != tc.errExpected {
+				t.Fatalf("Expected error %v, got %v", tc.errExpected, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
 func TestRerunAuthConfigValidate(t *testing.T) {
 	var testCases = []struct {
 		name        string
@@ -28,6 +28,48 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CensoringOptions) DeepCopyInto(out *CensoringOptions) {
+	*out = *in
+	if in.IncludeDirectories != nil {
+		in, out := &in.IncludeDirectories, &out.IncludeDirectories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeDirectories != nil {
+		in, out := &in.ExcludeDirectories, &out.ExcludeDirectories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CensoringOptions.
+func (in *CensoringOptions) DeepCopy() *CensoringOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CensoringOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneCache) DeepCopyInto(out *CloneCache) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneCache.
+func (in *CloneCache) DeepCopy() *CloneCache {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DecorationConfig) DeepCopyInto(out *DecorationConfig) {
 	*out = *in
@@ -91,6 +133,28 @@ func (in *DecorationConfig) DeepCopyInto(out *DecorationConfig) {
 		*out = new(OauthTokenSecret)
 		**out = **in
 	}
+	if in.CloneCache != nil {
+		in, out := &in.CloneCache, &out.CloneCache
+		*out = new(CloneCache)
+		**out = **in
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]Step, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CensorSecrets != nil {
+		in, out := &in.CensorSecrets, &out.CensorSecrets
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CensoringOptions != nil {
+		in, out := &in.CensoringOptions, &out.CensoringOptions
+		*out = new(CensoringOptions)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -130,6 +194,11 @@ func (in *GCSConfiguration) DeepCopyInto(out *GCSConfiguration) {
 			(*out)[key] = val
 		}
 	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(Retention)
+		**out = **in
+	}
 	return
 }
 
@@ -387,6 +456,11 @@ func (in *Refs) DeepCopyInto(out *Refs) {
 		*out = make([]Pull, len(*in))
 		copy(*out, *in)
 	}
+	if in.SparseCheckout != nil {
+		in, out := &in.SparseCheckout, &out.SparseCheckout
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -493,6 +567,22 @@ func (in *Resources) DeepCopy() *Resources {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Retention) DeepCopyInto(out *Retention) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Retention.
+func (in *Retention) DeepCopy() *Retention {
+	if in == nil {
+		return nil
+	}
+	out := new(Retention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SlackReporterConfig) DeepCopyInto(out *SlackReporterConfig) {
 	*out = *in
@@ -514,6 +604,27 @@ func (in *SlackReporterConfig) DeepCopy() *SlackReporterConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Step) DeepCopyInto(out *Step) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Step.
+func (in *Step) DeepCopy() *Step {
+	if in == nil {
+		return nil
+	}
+	out := new(Step)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UtilityImages) DeepCopyInto(out *UtilityImages) {
 	*out = *in
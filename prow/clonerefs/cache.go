@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clonerefs
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/pod-utils/clone"
+)
+
+var updateCacheFunc = clone.UpdateCache
+
+// MaintainCache keeps the cached repositories for the configured refs
+// fresh, refreshing them every CacheRefresh until stop is closed. It is
+// meant to run as a DaemonSet on nodes whose jobs mount the cache.
+func (o Options) MaintainCache(stop <-chan struct{}) error {
+	var oauthToken string
+	if o.OauthTokenFile != "" {
+		token, _, err := readOauthToken(o.OauthTokenFile)
+		if err != nil {
+			return err
+		}
+		oauthToken = token
+	}
+
+	ticker := time.NewTicker(o.CacheRefresh)
+	defer ticker.Stop()
+	for {
+		o.refreshCache(oauthToken)
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o Options) refreshCache(oauthToken string) {
+	for _, ref := range o.GitRefs {
+		log := logrus.WithFields(logrus.Fields{"org": ref.Org, "repo": ref.Repo})
+		start := time.Now()
+		if record := updateCacheFunc(ref, o.CacheDir, nil, oauthToken); record.Failed {
+			log.Warn("Failed to refresh cache")
+			continue
+		}
+		log.WithField("duration", time.Since(start)).Info("Refreshed cache")
+	}
+}
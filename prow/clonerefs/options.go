@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"strings"
 	"text/template"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
@@ -66,6 +67,15 @@ type Options struct {
 
 	Fail bool `json:"fail,omitempty"`
 
+	// CacheDir is a directory holding a git object cache shared
+	// across clonerefs runs, with one bare repository per repo.
+	// Objects found in the cache are not fetched again.
+	CacheDir string `json:"cache_dir,omitempty"`
+	// CacheRefresh switches clonerefs into cache maintenance mode
+	// when set: rather than cloning, the cached repositories for
+	// GitRefs under CacheDir are refreshed on this interval.
+	CacheRefresh time.Duration `json:"cache_refresh,omitempty"`
+
 	// used to hold flag values
 	refs       gitRefs
 	clonePath  orgRepoFormat
@@ -76,12 +86,18 @@ type Options struct {
 
 // Validate ensures that the configuration options are valid
 func (o *Options) Validate() error {
-	if o.SrcRoot == "" {
-		return errors.New("no source root specified")
-	}
+	if o.CacheRefresh > 0 {
+		if o.CacheDir == "" {
+			return errors.New("cache maintenance requires a cache directory")
+		}
+	} else {
+		if o.SrcRoot == "" {
+			return errors.New("no source root specified")
+		}
 
-	if o.Log == "" {
-		return errors.New("no log file specified")
+		if o.Log == "" {
+			return errors.New("no log file specified")
+		}
 	}
 
 	if len(o.GitRefs) == 0 {
@@ -159,6 +175,8 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 	fs.IntVar(&o.MaxParallelWorkers, "max-workers", 0, "Maximum number of parallel workers, unset for unlimited.")
 	fs.StringVar(&o.CookiePath, "cookiefile", "", "Path to git http.cookiefile")
 	fs.BoolVar(&o.Fail, "fail", false, "Exit with failure if any of the refs can't be fetched.")
+	fs.StringVar(&o.CacheDir, "cache-dir", "", "Directory holding a git object cache shared across runs")
+	fs.DurationVar(&o.CacheRefresh, "cache-refresh", 0, "If set, keep the cache for the refs under --cache-dir fresh on this interval instead of cloning")
 }
 
 type gitRefs struct {
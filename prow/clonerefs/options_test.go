@@ -18,6 +18,7 @@ package clonerefs
 
 import (
 	"testing"
+	"time"
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 )
@@ -112,6 +113,33 @@ func TestOptions_Validate(t *testing.T) {
 			},
 			expectedErr: true,
 		},
+		{
+			name: "cache maintenance without src root or log",
+			input: Options{
+				CacheDir:     "/cache",
+				CacheRefresh: time.Minute,
+				GitRefs: []prowapi.Refs{
+					{
+						Repo: "repo",
+						Org:  "org",
+					},
+				},
+			},
+			expectedErr: false,
+		},
+		{
+			name: "cache maintenance without cache dir",
+			input: Options{
+				CacheRefresh: time.Minute,
+				GitRefs: []prowapi.Refs{
+					{
+						Repo: "repo",
+						Org:  "org",
+					},
+				},
+			},
+			expectedErr: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -62,7 +62,7 @@ func TestRun(t *testing.T) {
 	var recordedClones []cloneRec
 	var lock sync.Mutex
 	cloneFuncOld := cloneFunc
-	cloneFunc = func(refs prowapi.Refs, root, user, email, cookiePath string, env []string, oauthToken string) clone.Record {
+	cloneFunc = func(refs prowapi.Refs, root, user, email, cookiePath string, env []string, oauthToken, cacheDir string) clone.Record {
 		lock.Lock()
 		defer lock.Unlock()
 		recordedClones = append(recordedClones, cloneRec{
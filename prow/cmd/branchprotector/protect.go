@@ -17,11 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"net/url"
 	"os"
+	"reflect"
 	"regexp"
 	"sort"
 	"strings"
@@ -35,6 +37,7 @@ import (
 	"k8s.io/test-infra/prow/config/secret"
 	"k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/plan"
 	"k8s.io/test-infra/prow/logrusutil"
 )
 
@@ -51,12 +54,16 @@ type options struct {
 	tokens             int
 	tokenBurst         int
 	github             flagutil.GitHubOptions
+	plan               plan.Options
 }
 
 func (o *options) Validate() error {
 	if err := o.github.Validate(!o.confirm); err != nil {
 		return err
 	}
+	if err := o.plan.Validate(!o.confirm); err != nil {
+		return err
+	}
 
 	if o.config == "" {
 		return errors.New("empty --config-path")
@@ -75,6 +82,7 @@ func gatherOptions() options {
 	fs.IntVar(&o.tokens, "tokens", defaultTokens, "Throttle hourly token consumption (0 to disable)")
 	fs.IntVar(&o.tokenBurst, "token-burst", defaultBurst, "Allow consuming a subset of hourly tokens in a short burst")
 	o.github.AddFlags(fs)
+	o.plan.AddFlags(fs)
 	fs.Parse(os.Args[1:])
 	return o
 }
@@ -84,6 +92,11 @@ type requirements struct {
 	Repo    string
 	Branch  string
 	Request *github.BranchProtectionRequest
+	// RequiredSignatures requires signed commits, which is set apart from the
+	// rest of the protection.
+	RequiredSignatures bool
+	// Current is the protection of the branch before the update, if any.
+	Current *github.BranchProtection
 }
 
 // Errors holds a list of errors, including a method to concurrently append.
@@ -132,12 +145,16 @@ func main() {
 		completedRepos:     make(map[string]bool),
 		done:               make(chan []error),
 		verifyRestrictions: o.verifyRestrictions,
+		plan:               &plan.Plan{},
 	}
 
 	go p.configureBranches()
 	p.protect()
 	close(p.updates)
 	errors := <-p.done
+	if err := o.plan.Write(p.plan); err != nil {
+		errors = append(errors, err)
+	}
 	if n := len(errors); n > 0 {
 		for i, err := range errors {
 			logrus.WithError(err).Error(i)
@@ -155,6 +172,12 @@ type client interface {
 	GetRepos(org string, user bool) ([]github.Repo, error)
 	ListCollaborators(org, repo string) ([]github.User, error)
 	ListRepoTeams(org, repo string) ([]github.Team, error)
+	UpdateRequiredSignatures(org, repo, branch string, required bool) error
+	ListRepoRulesets(org, repo string) ([]github.Ruleset, error)
+	GetRepoRuleset(org, repo string, id int) (*github.Ruleset, error)
+	CreateRepoRuleset(org, repo string, ruleset github.Ruleset) (*github.Ruleset, error)
+	UpdateRepoRuleset(org, repo string, id int, ruleset github.Ruleset) (*github.Ruleset, error)
+	DeleteRepoRuleset(org, repo string, id int) error
 }
 
 type protector struct {
@@ -165,20 +188,38 @@ type protector struct {
 	completedRepos     map[string]bool
 	done               chan []error
 	verifyRestrictions bool
+	plan               *plan.Plan
 }
 
 func (p *protector) configureBranches() {
 	for u := range p.updates {
+		change := plan.Change{Kind: "branch protection", Org: u.Org, Target: u.Repo, Name: u.Branch}
 		if u.Request == nil {
 			if err := p.client.RemoveBranchProtection(u.Org, u.Repo, u.Branch); err != nil {
 				p.errors.add(fmt.Errorf("remove %s/%s=%s protection failed: %v", u.Org, u.Repo, u.Branch, err))
+				continue
 			}
+			change.Action = plan.Remove
+			p.plan.Record(change)
 			continue
 		}
 
 		if err := p.client.UpdateBranchProtection(u.Org, u.Repo, u.Branch, *u.Request); err != nil {
 			p.errors.add(fmt.Errorf("update %s/%s=%s protection to %v failed: %v", u.Org, u.Repo, u.Branch, *u.Request, err))
+			continue
 		}
+		if u.RequiredSignatures != requiresSignatures(u.Current) {
+			if err := p.client.UpdateRequiredSignatures(u.Org, u.Repo, u.Branch, u.RequiredSignatures); err != nil {
+				p.errors.add(fmt.Errorf("update %s/%s=%s required signatures to %t failed: %v", u.Org, u.Repo, u.Branch, u.RequiredSignatures, err))
+				continue
+			}
+		}
+		change.Action = plan.Update
+		if u.Current == nil {
+			change.Action = plan.Add
+		}
+		change.Details = protectionChanges(u)
+		p.plan.Record(change)
 	}
 	p.done <- p.errors.errs
 }
@@ -325,9 +366,129 @@ func (p *protector) UpdateRepo(orgName string, repoName string, repo config.Repo
 		}
 	}
 
+	if repo.Rulesets != nil {
+		if err := p.UpdateRulesets(orgName, repoName, repo.Rulesets); err != nil {
+			errs = append(errs, fmt.Errorf("update rulesets: %v", err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// UpdateRulesets creates, updates and deletes the rulesets of the repo to match
+// the configured ones. Rulesets the repo inherits from its org are left alone.
+func (p *protector) UpdateRulesets(orgName, repoName string, rulesets map[string]config.Ruleset) error {
+	current, err := p.client.ListRepoRulesets(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("list rulesets: %v", err)
+	}
+
+	var errs []error
+	existing := map[string]github.Ruleset{}
+	for _, ruleset := range current {
+		if ruleset.SourceType != "" && ruleset.SourceType != "Repository" {
+			continue
+		}
+		if _, configured := rulesets[ruleset.Name]; configured {
+			if _, duplicate := existing[ruleset.Name]; !duplicate {
+				existing[ruleset.Name] = ruleset
+				continue
+			}
+		}
+		if err := p.client.DeleteRepoRuleset(orgName, repoName, ruleset.ID); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %v", ruleset.Name, err))
+			continue
+		}
+		p.plan.Record(plan.Change{Action: plan.Remove, Kind: "ruleset", Org: orgName, Target: repoName, Name: ruleset.Name})
+	}
+
+	names := make([]string, 0, len(rulesets))
+	for name := range rulesets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want := makeRuleset(name, rulesets[name])
+		have, ok := existing[name]
+		if !ok {
+			if _, err := p.client.CreateRepoRuleset(orgName, repoName, want); err != nil {
+				errs = append(errs, fmt.Errorf("create %s: %v", name, err))
+				continue
+			}
+			p.plan.Record(plan.Change{Action: plan.Add, Kind: "ruleset", Org: orgName, Target: repoName, Name: name, Details: rulesetDetails(want)})
+			continue
+		}
+		// Listed rulesets do not include their rules
+		full, err := p.client.GetRepoRuleset(orgName, repoName, have.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get %s: %v", name, err))
+			continue
+		}
+		changes := rulesetChanges(*full, want)
+		if len(changes) == 0 {
+			logrus.Debugf("%s/%s: ruleset %s matches policy, skipping", orgName, repoName, name)
+			continue
+		}
+		if _, err := p.client.UpdateRepoRuleset(orgName, repoName, have.ID, want); err != nil {
+			errs = append(errs, fmt.Errorf("update %s: %v", name, err))
+			continue
+		}
+		p.plan.Record(plan.Change{Action: plan.Update, Kind: "ruleset", Org: orgName, Target: repoName, Name: name, Details: changes})
+	}
+
 	return utilerrors.NewAggregate(errs)
 }
 
+// rulesetDetails describes the settings of a ruleset, one per line, in a
+// stable order so rulesets can be compared line by line.
+func rulesetDetails(ruleset github.Ruleset) []string {
+	details := []string{
+		"target: " + ruleset.Target,
+		"enforcement: " + ruleset.Enforcement,
+	}
+	if ruleset.Conditions != nil {
+		include := append([]string{}, ruleset.Conditions.RefName.Include...)
+		exclude := append([]string{}, ruleset.Conditions.RefName.Exclude...)
+		sort.Strings(include)
+		sort.Strings(exclude)
+		details = append(details, "include: "+strings.Join(include, ", "))
+		if len(exclude) > 0 {
+			details = append(details, "exclude: "+strings.Join(exclude, ", "))
+		}
+	}
+	var rules []string
+	for _, rule := range ruleset.Rules {
+		if rule.Parameters == nil || reflect.DeepEqual(*rule.Parameters, github.RulesetRuleParameters{}) {
+			rules = append(rules, "rule: "+rule.Type)
+			continue
+		}
+		params := *rule.Parameters
+		params.RequiredStatusChecks = append([]github.RulesetStatusCheck{}, params.RequiredStatusChecks...)
+		sort.Slice(params.RequiredStatusChecks, func(i, j int) bool {
+			return params.RequiredStatusChecks[i].Context < params.RequiredStatusChecks[j].Context
+		})
+		params.RequiredDeploymentEnvironments = append([]string{}, params.RequiredDeploymentEnvironments...)
+		sort.Strings(params.RequiredDeploymentEnvironments)
+		rules = append(rules, fmt.Sprintf("rule: %s %s", rule.Type, describe(&params)))
+	}
+	sort.Strings(rules)
+	return append(details, rules...)
+}
+
+// rulesetChanges lists the settings which are added (+) or removed (-) when
+// replacing the current ruleset with the wanted one.
+func rulesetChanges(current, want github.Ruleset) []string {
+	have, wanted := sets.NewString(rulesetDetails(current)...), sets.NewString(rulesetDetails(want)...)
+	var changes []string
+	for _, detail := range have.Difference(wanted).List() {
+		changes = append(changes, "- "+detail)
+	}
+	for _, detail := range wanted.Difference(have).List() {
+		changes = append(changes, "+ "+detail)
+	}
+	return changes
+}
+
 // authorizedCollaborators returns the list of Logins for users that are
 // authorized to write to a repository.
 func (p *protector) authorizedCollaborators(org, repo string) ([]string, error) {
@@ -394,9 +555,11 @@ func (p *protector) UpdateBranch(orgName, repo string, branchName string, branch
 	}
 
 	var req *github.BranchProtectionRequest
+	var signatures bool
 	if *bp.Protect {
 		r := makeRequest(*bp)
 		req = &r
+		signatures = makeBool(bp.RequiredSignatures)
 	}
 
 	if p.verifyRestrictions {
@@ -422,16 +585,18 @@ func (p *protector) UpdateBranch(orgName, repo string, branchName string, branch
 		return fmt.Errorf("get current branch protection: %v", err)
 	}
 
-	if equalBranchProtections(currentBP, req) {
+	if equalBranchProtections(currentBP, req) && (req == nil || signatures == requiresSignatures(currentBP)) {
 		logrus.Debugf("%s/%s=%s: current branch protection matches policy, skipping", orgName, repo, branchName)
 		return nil
 	}
 
 	p.updates <- requirements{
-		Org:     orgName,
-		Repo:    repo,
-		Branch:  branchName,
-		Request: req,
+		Org:                orgName,
+		Repo:               repo,
+		Branch:             branchName,
+		Request:            req,
+		RequiredSignatures: signatures,
+		Current:            currentBP,
 	}
 	return nil
 }
@@ -444,12 +609,79 @@ func equalBranchProtections(state *github.BranchProtection, request *github.Bran
 		return equalRequiredStatusChecks(state.RequiredStatusChecks, request.RequiredStatusChecks) &&
 			equalAdminEnforcement(state.EnforceAdmins, request.EnforceAdmins) &&
 			equalRequiredPullRequestReviews(state.RequiredPullRequestReviews, request.RequiredPullRequestReviews) &&
-			equalRestrictions(state.Restrictions, request.Restrictions)
+			equalRestrictions(state.Restrictions, request.Restrictions) &&
+			enabled(state.RequiredConversationResolution) == request.RequiredConversationResolution
 	default:
 		return false
 	}
 }
 
+// protectionChanges describes how the update changes the current protection
+// of a branch, which is nil when the branch is not protected yet.
+func protectionChanges(u requirements) []string {
+	state, request := u.Current, u.Request
+	if state == nil {
+		state = &github.BranchProtection{}
+	}
+	var changes []string
+	if !equalRequiredStatusChecks(state.RequiredStatusChecks, request.RequiredStatusChecks) {
+		var have, want github.RequiredStatusChecks
+		if state.RequiredStatusChecks != nil {
+			have = *state.RequiredStatusChecks
+		}
+		if request.RequiredStatusChecks != nil {
+			want = *request.RequiredStatusChecks
+		}
+		if added := sets.NewString(want.Contexts...).Difference(sets.NewString(have.Contexts...)); added.Len() > 0 {
+			changes = append(changes, fmt.Sprintf("add required contexts: %s", strings.Join(added.List(), ", ")))
+		}
+		if removed := sets.NewString(have.Contexts...).Difference(sets.NewString(want.Contexts...)); removed.Len() > 0 {
+			changes = append(changes, fmt.Sprintf("remove required contexts: %s", strings.Join(removed.List(), ", ")))
+		}
+		if have.Strict != want.Strict {
+			changes = append(changes, fmt.Sprintf("strict: %t → %t", have.Strict, want.Strict))
+		}
+	}
+	if !equalAdminEnforcement(state.EnforceAdmins, request.EnforceAdmins) {
+		changes = append(changes, fmt.Sprintf("enforce_admins: %t → %t", state.EnforceAdmins.Enabled, !state.EnforceAdmins.Enabled))
+	}
+	if !equalRequiredPullRequestReviews(state.RequiredPullRequestReviews, request.RequiredPullRequestReviews) {
+		changes = append(changes, "required_pull_request_reviews: "+describe(request.RequiredPullRequestReviews))
+	}
+	if !equalRestrictions(state.Restrictions, request.Restrictions) {
+		changes = append(changes, "restrictions: "+describe(request.Restrictions))
+	}
+	if have := enabled(state.RequiredConversationResolution); have != request.RequiredConversationResolution {
+		changes = append(changes, fmt.Sprintf("required_conversation_resolution: %t → %t", have, request.RequiredConversationResolution))
+	}
+	if have := requiresSignatures(u.Current); have != u.RequiredSignatures {
+		changes = append(changes, fmt.Sprintf("required_signatures: %t → %t", have, u.RequiredSignatures))
+	}
+	return changes
+}
+
+// describe formats a part of a request, which is none when it is nil.
+func describe(v interface{}) string {
+	if reflect.ValueOf(v).IsNil() {
+		return "none"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(b)
+}
+
+// enabled returns whether the setting is reported and enabled.
+func enabled(setting *github.ProtectionSetting) bool {
+	return setting != nil && setting.Enabled
+}
+
+// requiresSignatures returns whether the branch protection requires signed commits.
+func requiresSignatures(state *github.BranchProtection) bool {
+	return state != nil && enabled(state.RequiredSignatures)
+}
+
 func equalRequiredStatusChecks(state, request *github.RequiredStatusChecks) bool {
 	switch {
 	case state == request:
@@ -24,12 +24,14 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"k8s.io/apimachinery/pkg/util/diff"
 	"sigs.k8s.io/yaml"
 
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/plan"
 )
 
 func TestOptions_Validate(t *testing.T) {
@@ -91,6 +93,11 @@ type fakeClient struct {
 	branchProtections map[string]github.BranchProtection
 	collaborators     []github.User
 	teams             []github.Team
+	signatures        map[string]bool
+	rulesets          map[string][]github.Ruleset
+	createdRulesets   []github.Ruleset
+	updatedRulesets   map[int]github.Ruleset
+	deletedRulesets   []int
 }
 
 func (c fakeClient) GetRepo(org string, repo string) (github.FullRepo, error) {
@@ -178,6 +185,52 @@ func (c *fakeClient) ListRepoTeams(org, repo string) ([]github.Team, error) {
 	return c.teams, nil
 }
 
+func (c *fakeClient) UpdateRequiredSignatures(org, repo, branch string, required bool) error {
+	if c.signatures == nil {
+		c.signatures = map[string]bool{}
+	}
+	c.signatures[org+"/"+repo+"="+branch] = required
+	return nil
+}
+
+func (c *fakeClient) ListRepoRulesets(org, repo string) ([]github.Ruleset, error) {
+	var listed []github.Ruleset
+	for _, ruleset := range c.rulesets[org+"/"+repo] {
+		// Listed rulesets do not include their rules
+		ruleset.Conditions = nil
+		ruleset.Rules = nil
+		listed = append(listed, ruleset)
+	}
+	return listed, nil
+}
+
+func (c *fakeClient) GetRepoRuleset(org, repo string, id int) (*github.Ruleset, error) {
+	for _, ruleset := range c.rulesets[org+"/"+repo] {
+		if ruleset.ID == id {
+			return &ruleset, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown ruleset %d", id)
+}
+
+func (c *fakeClient) CreateRepoRuleset(org, repo string, ruleset github.Ruleset) (*github.Ruleset, error) {
+	c.createdRulesets = append(c.createdRulesets, ruleset)
+	return &ruleset, nil
+}
+
+func (c *fakeClient) UpdateRepoRuleset(org, repo string, id int, ruleset github.Ruleset) (*github.Ruleset, error) {
+	if c.updatedRulesets == nil {
+		c.updatedRulesets = map[int]github.Ruleset{}
+	}
+	c.updatedRulesets[id] = ruleset
+	return &ruleset, nil
+}
+
+func (c *fakeClient) DeleteRepoRuleset(org, repo string, id int) error {
+	c.deletedRulesets = append(c.deletedRulesets, id)
+	return nil
+}
+
 func TestConfigureBranches(t *testing.T) {
 	yes := true
 
@@ -187,11 +240,13 @@ func TestConfigureBranches(t *testing.T) {
 	}
 
 	cases := []struct {
-		name    string
-		updates []requirements
-		deletes map[string]bool
-		sets    map[string]github.BranchProtectionRequest
-		errors  int
+		name       string
+		updates    []requirements
+		deletes    map[string]bool
+		sets       map[string]github.BranchProtectionRequest
+		signatures map[string]bool
+		errors     int
+		changes    []plan.Change
 	}{
 		{
 			name: "remove-protection",
@@ -205,6 +260,11 @@ func TestConfigureBranches(t *testing.T) {
 				"one/1=remove": true,
 				"two/2=remove": true,
 			},
+			changes: []plan.Change{
+				{Action: plan.Remove, Kind: "branch protection", Org: "one", Target: "1", Name: "delete"},
+				{Action: plan.Remove, Kind: "branch protection", Org: "one", Target: "1", Name: "remove"},
+				{Action: plan.Remove, Kind: "branch protection", Org: "two", Target: "2", Name: "remove"},
+			},
 		},
 		{
 			name: "error-remove-protection",
@@ -227,12 +287,61 @@ func TestConfigureBranches(t *testing.T) {
 					Repo:    "1",
 					Branch:  "other",
 					Request: &diffprot,
+					Current: &github.BranchProtection{
+						RequiredStatusChecks: &github.RequiredStatusChecks{Contexts: []string{"old"}},
+					},
 				},
 			},
 			sets: map[string]github.BranchProtectionRequest{
 				"one/1=master": prot,
 				"one/1=other":  diffprot,
 			},
+			changes: []plan.Change{
+				{Action: plan.Add, Kind: "branch protection", Org: "one", Target: "1", Name: "master"},
+				{
+					Action:  plan.Update,
+					Kind:    "branch protection",
+					Org:     "one",
+					Target:  "1",
+					Name:    "other",
+					Details: []string{"remove required contexts: old", "enforce_admins: false → true"},
+				},
+			},
+		},
+		{
+			name: "required-signatures",
+			updates: []requirements{
+				{Org: "one", Repo: "1", Branch: "new", Request: &prot, RequiredSignatures: true},
+				{
+					Org:     "one",
+					Repo:    "1",
+					Branch:  "signed",
+					Request: &prot,
+					Current: &github.BranchProtection{RequiredSignatures: &github.ProtectionSetting{Enabled: true}},
+				},
+				{
+					Org:                "one",
+					Repo:               "1",
+					Branch:             "unchanged",
+					Request:            &diffprot,
+					RequiredSignatures: true,
+					Current:            &github.BranchProtection{RequiredSignatures: &github.ProtectionSetting{Enabled: true}},
+				},
+			},
+			sets: map[string]github.BranchProtectionRequest{
+				"one/1=new":       prot,
+				"one/1=signed":    prot,
+				"one/1=unchanged": diffprot,
+			},
+			signatures: map[string]bool{
+				"one/1=new":    true,
+				"one/1=signed": false,
+			},
+			changes: []plan.Change{
+				{Action: plan.Add, Kind: "branch protection", Org: "one", Target: "1", Name: "new", Details: []string{"required_signatures: false → true"}},
+				{Action: plan.Update, Kind: "branch protection", Org: "one", Target: "1", Name: "signed", Details: []string{"required_signatures: true → false"}},
+				{Action: plan.Update, Kind: "branch protection", Org: "one", Target: "1", Name: "unchanged", Details: []string{"enforce_admins: false → true"}},
+			},
 		},
 		{
 			name: "complex",
@@ -249,6 +358,10 @@ func TestConfigureBranches(t *testing.T) {
 			sets: map[string]github.BranchProtectionRequest{
 				"update/1=master": prot,
 			},
+			changes: []plan.Change{
+				{Action: plan.Remove, Kind: "branch protection", Org: "remove", Target: "3", Name: "master"},
+				{Action: plan.Add, Kind: "branch protection", Org: "update", Target: "1", Name: "master"},
+			},
 		},
 	}
 
@@ -258,6 +371,7 @@ func TestConfigureBranches(t *testing.T) {
 			client:  &fc,
 			updates: make(chan requirements),
 			done:    make(chan []error),
+			plan:    &plan.Plan{},
 		}
 		go p.configureBranches()
 		for _, u := range tc.updates {
@@ -274,6 +388,12 @@ func TestConfigureBranches(t *testing.T) {
 		if !reflect.DeepEqual(fc.updated, tc.sets) {
 			t.Errorf("%s: updates %v != expected %v", tc.name, fc.updated, tc.sets)
 		}
+		if !reflect.DeepEqual(fc.signatures, tc.signatures) {
+			t.Errorf("%s: signatures %v != expected %v", tc.name, fc.signatures, tc.signatures)
+		}
+		if changes := p.plan.Changes(); !reflect.DeepEqual(changes, tc.changes) {
+			t.Errorf("%s: changes %v != expected %v", tc.name, changes, tc.changes)
+		}
 
 	}
 }
@@ -1245,7 +1365,12 @@ branch-protection:
 }
 
 func fixup(r *requirements) {
-	if r == nil || r.Request == nil {
+	if r == nil {
+		return
+	}
+	// The current protection is only used to describe the update.
+	r.Current = nil
+	if r.Request == nil {
 		return
 	}
 	req := r.Request
@@ -1380,6 +1505,69 @@ branch-protection:
 	}
 }
 
+func TestUpdateRulesets(t *testing.T) {
+	releases := config.Ruleset{Include: []string{"release-*"}, RestrictDeletions: true}
+	tags := config.Ruleset{Target: github.RulesetTargetTag, Include: []string{"v*"}, RequiredSignatures: true}
+	matching := makeRuleset("releases", releases)
+	matching.ID = 1
+	matching.SourceType = "Repository"
+	changed := makeRuleset("tags", config.Ruleset{Target: github.RulesetTargetTag, Include: []string{"v*"}, BlockForcePushes: true})
+	changed.ID = 2
+	changed.SourceType = "Repository"
+
+	fc := fakeClient{
+		rulesets: map[string][]github.Ruleset{
+			"org/repo": {
+				matching,
+				changed,
+				{ID: 3, Name: "stale", SourceType: "Repository", Enforcement: github.RulesetEnforcementActive},
+				{ID: 4, Name: "inherited", SourceType: "Organization", Enforcement: github.RulesetEnforcementActive},
+			},
+		},
+	}
+	p := protector{client: &fc, plan: &plan.Plan{}}
+	err := p.UpdateRulesets("org", "repo", map[string]config.Ruleset{
+		"releases":    releases,
+		"tags":        tags,
+		"deployments": {Include: []string{"main"}, RequiredDeployments: []string{"staging"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff([]github.Ruleset{makeRuleset("deployments", config.Ruleset{Include: []string{"main"}, RequiredDeployments: []string{"staging"}})}, fc.createdRulesets); diff != "" {
+		t.Errorf("created rulesets differ from expected (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[int]github.Ruleset{2: makeRuleset("tags", tags)}, fc.updatedRulesets); diff != "" {
+		t.Errorf("updated rulesets differ from expected (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{3}, fc.deletedRulesets); diff != "" {
+		t.Errorf("deleted rulesets differ from expected (-want +got):\n%s", diff)
+	}
+	expected := []plan.Change{
+		{
+			Action:  plan.Add,
+			Kind:    "ruleset",
+			Org:     "org",
+			Target:  "repo",
+			Name:    "deployments",
+			Details: []string{"target: branch", "enforcement: active", "include: refs/heads/main", `rule: required_deployments {"required_deployment_environments":["staging"]}`},
+		},
+		{Action: plan.Remove, Kind: "ruleset", Org: "org", Target: "repo", Name: "stale"},
+		{
+			Action:  plan.Update,
+			Kind:    "ruleset",
+			Org:     "org",
+			Target:  "repo",
+			Name:    "tags",
+			Details: []string{"- rule: non_fast_forward", "+ rule: required_signatures"},
+		},
+	}
+	if diff := cmp.Diff(expected, p.plan.Changes()); diff != "" {
+		t.Errorf("changes differ from expected (-want +got):\n%s", diff)
+	}
+}
+
 func TestEqualBranchProtection(t *testing.T) {
 	yes := true
 	var testCases = []struct {
@@ -1448,6 +1636,18 @@ func TestEqualBranchProtection(t *testing.T) {
 			},
 			expected: true,
 		},
+		{
+			name:     "conversation resolution not reported is disabled",
+			state:    &github.BranchProtection{},
+			request:  &github.BranchProtectionRequest{RequiredConversationResolution: true},
+			expected: false,
+		},
+		{
+			name:     "matching conversation resolution",
+			state:    &github.BranchProtection{RequiredConversationResolution: &github.ProtectionSetting{Enabled: true}},
+			request:  &github.BranchProtectionRequest{RequiredConversationResolution: true},
+			expected: true,
+		},
 	}
 
 	for _, testCase := range testCases {
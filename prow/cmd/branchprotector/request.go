@@ -17,6 +17,8 @@ limitations under the License.
 package main
 
 import (
+	"strings"
+
 	branchprotection "k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 
@@ -27,13 +29,14 @@ import (
 // makeRequest renders a branch protection policy into the corresponding GitHub api request.
 func makeRequest(policy branchprotection.Policy) github.BranchProtectionRequest {
 	return github.BranchProtectionRequest{
-		EnforceAdmins:              makeAdmins(policy.Admins),
-		RequiredPullRequestReviews: makeReviews(policy.RequiredPullRequestReviews),
-		RequiredStatusChecks:       makeChecks(policy.RequiredStatusChecks),
-		Restrictions:               makeRestrictions(policy.Restrictions),
-		RequiredLinearHistory:      makeBool(policy.RequiredLinearHistory),
-		AllowForcePushes:           makeBool(policy.AllowForcePushes),
-		AllowDeletions:             makeBool(policy.AllowDeletions),
+		EnforceAdmins:                  makeAdmins(policy.Admins),
+		RequiredPullRequestReviews:     makeReviews(policy.RequiredPullRequestReviews),
+		RequiredStatusChecks:           makeChecks(policy.RequiredStatusChecks),
+		Restrictions:                   makeRestrictions(policy.Restrictions),
+		RequiredLinearHistory:          makeBool(policy.RequiredLinearHistory),
+		AllowForcePushes:               makeBool(policy.AllowForcePushes),
+		AllowDeletions:                 makeBool(policy.AllowDeletions),
+		RequiredConversationResolution: makeBool(policy.RequiredConversationResolution),
 	}
 
 }
@@ -108,3 +111,102 @@ func makeReviews(rp *branchprotection.ReviewPolicy) *github.RequiredPullRequestR
 	}
 	return &rprr
 }
+
+// makeRuleset renders a ruleset into the corresponding GitHub api object.
+//
+// Names are turned into refs of the target, and rules are listed in a fixed
+// order with sorted parameters so rulesets can be compared.
+func makeRuleset(name string, rs branchprotection.Ruleset) github.Ruleset {
+	target := rs.Target
+	if target == "" {
+		target = github.RulesetTargetBranch
+	}
+	enforcement := rs.Enforcement
+	if enforcement == "" {
+		enforcement = github.RulesetEnforcementActive
+	}
+	ruleset := github.Ruleset{
+		Name:        name,
+		Target:      target,
+		Enforcement: enforcement,
+		Conditions: &github.RulesetConditions{
+			RefName: github.RulesetRefName{
+				Include: makeRefs(target, rs.Include),
+				Exclude: makeRefs(target, rs.Exclude),
+			},
+		},
+	}
+	for _, rule := range []struct {
+		enabled  bool
+		ruleType string
+	}{
+		{rs.RestrictCreations, github.RuleCreation},
+		{rs.RestrictUpdates, github.RuleUpdate},
+		{rs.RestrictDeletions, github.RuleDeletion},
+		{rs.BlockForcePushes, github.RuleNonFastForward},
+		{rs.RequiredLinearHistory, github.RuleRequiredLinearHistory},
+		{rs.RequiredSignatures, github.RuleRequiredSignatures},
+	} {
+		if rule.enabled {
+			ruleset.Rules = append(ruleset.Rules, github.RulesetRule{Type: rule.ruleType})
+		}
+	}
+	if rp := rs.RequiredPullRequestReviews; rp != nil {
+		approvals := rp.Approvals
+		ruleset.Rules = append(ruleset.Rules, github.RulesetRule{
+			Type: github.RulePullRequest,
+			Parameters: &github.RulesetRuleParameters{
+				RequiredApprovingReviewCount:   &approvals,
+				DismissStaleReviewsOnPush:      makeBoolPtr(rp.DismissStale),
+				RequireCodeOwnerReview:         makeBoolPtr(rp.RequireOwners),
+				RequireLastPushApproval:        makeBoolPtr(rp.RequireLastPushApproval),
+				RequiredReviewThreadResolution: makeBoolPtr(rp.RequiredConversationResolution),
+			},
+		})
+	}
+	if cp := rs.RequiredStatusChecks; cp != nil {
+		var checks []github.RulesetStatusCheck
+		for _, context := range sets.NewString(cp.Contexts...).List() {
+			checks = append(checks, github.RulesetStatusCheck{Context: context})
+		}
+		ruleset.Rules = append(ruleset.Rules, github.RulesetRule{
+			Type: github.RuleRequiredStatusChecks,
+			Parameters: &github.RulesetRuleParameters{
+				RequiredStatusChecks:             checks,
+				StrictRequiredStatusChecksPolicy: makeBoolPtr(makeBool(cp.Strict)),
+			},
+		})
+	}
+	if len(rs.RequiredDeployments) > 0 {
+		ruleset.Rules = append(ruleset.Rules, github.RulesetRule{
+			Type: github.RuleRequiredDeployments,
+			Parameters: &github.RulesetRuleParameters{
+				RequiredDeploymentEnvironments: sets.NewString(rs.RequiredDeployments...).List(),
+			},
+		})
+	}
+	return ruleset
+}
+
+// makeRefs turns branch or tag name patterns into sorted ref patterns,
+// keeping refs and special values like ~DEFAULT_BRANCH as they are.
+func makeRefs(target string, names []string) []string {
+	prefix := "refs/heads/"
+	if target == github.RulesetTargetTag {
+		prefix = "refs/tags/"
+	}
+	refs := sets.NewString()
+	for _, name := range names {
+		if strings.HasPrefix(name, "~") || strings.HasPrefix(name, "refs/") {
+			refs.Insert(name)
+		} else {
+			refs.Insert(prefix + name)
+		}
+	}
+	return refs.List()
+}
+
+// makeBoolPtr returns a pointer to val
+func makeBoolPtr(val bool) *bool {
+	return &val
+}
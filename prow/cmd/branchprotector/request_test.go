@@ -20,6 +20,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	branchprotection "k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 )
@@ -160,6 +162,16 @@ func TestMakeRequest(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "conversation resolution",
+			policy: branchprotection.Policy{
+				RequiredConversationResolution: &yes,
+			},
+			expected: github.BranchProtectionRequest{
+				EnforceAdmins:                  &no,
+				RequiredConversationResolution: true,
+			},
+		},
 		{
 			name: "Strict => Contexts != nil",
 			policy: branchprotection.Policy{
@@ -186,3 +198,108 @@ func TestMakeRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeRuleset(t *testing.T) {
+	yes := true
+	no := false
+	two := 2
+	cases := []struct {
+		name     string
+		ruleset  branchprotection.Ruleset
+		expected github.Ruleset
+	}{
+		{
+			name:    "defaults to an active branch ruleset",
+			ruleset: branchprotection.Ruleset{Include: []string{"release-*", "~DEFAULT_BRANCH"}},
+			expected: github.Ruleset{
+				Name:        "rules",
+				Target:      github.RulesetTargetBranch,
+				Enforcement: github.RulesetEnforcementActive,
+				Conditions: &github.RulesetConditions{RefName: github.RulesetRefName{
+					Include: []string{"refs/heads/release-*", "~DEFAULT_BRANCH"},
+					Exclude: []string{},
+				}},
+			},
+		},
+		{
+			name: "tag rules",
+			ruleset: branchprotection.Ruleset{
+				Target:             github.RulesetTargetTag,
+				Enforcement:        github.RulesetEnforcementEvaluate,
+				Include:            []string{"v*"},
+				Exclude:            []string{"refs/tags/v0.*"},
+				RestrictDeletions:  true,
+				BlockForcePushes:   true,
+				RequiredSignatures: true,
+			},
+			expected: github.Ruleset{
+				Name:        "rules",
+				Target:      github.RulesetTargetTag,
+				Enforcement: github.RulesetEnforcementEvaluate,
+				Conditions: &github.RulesetConditions{RefName: github.RulesetRefName{
+					Include: []string{"refs/tags/v*"},
+					Exclude: []string{"refs/tags/v0.*"},
+				}},
+				Rules: []github.RulesetRule{
+					{Type: github.RuleDeletion},
+					{Type: github.RuleNonFastForward},
+					{Type: github.RuleRequiredSignatures},
+				},
+			},
+		},
+		{
+			name: "pull requests, status checks and deployments",
+			ruleset: branchprotection.Ruleset{
+				Include: []string{"main"},
+				RequiredPullRequestReviews: &branchprotection.RulesetReviewPolicy{
+					Approvals:                      2,
+					RequireOwners:                  true,
+					RequiredConversationResolution: true,
+				},
+				RequiredStatusChecks: &branchprotection.ContextPolicy{Contexts: []string{"unit", "lint", "unit"}},
+				RequiredDeployments:  []string{"staging"},
+			},
+			expected: github.Ruleset{
+				Name:        "rules",
+				Target:      github.RulesetTargetBranch,
+				Enforcement: github.RulesetEnforcementActive,
+				Conditions: &github.RulesetConditions{RefName: github.RulesetRefName{
+					Include: []string{"refs/heads/main"},
+					Exclude: []string{},
+				}},
+				Rules: []github.RulesetRule{
+					{
+						Type: github.RulePullRequest,
+						Parameters: &github.RulesetRuleParameters{
+							RequiredApprovingReviewCount:   &two,
+							DismissStaleReviewsOnPush:      &no,
+							RequireCodeOwnerReview:         &yes,
+							RequireLastPushApproval:        &no,
+							RequiredReviewThreadResolution: &yes,
+						},
+					},
+					{
+						Type: github.RuleRequiredStatusChecks,
+						Parameters: &github.RulesetRuleParameters{
+							RequiredStatusChecks:             []github.RulesetStatusCheck{{Context: "lint"}, {Context: "unit"}},
+							StrictRequiredStatusChecksPolicy: &no,
+						},
+					},
+					{
+						Type: github.RuleRequiredDeployments,
+						Parameters: &github.RulesetRuleParameters{
+							RequiredDeploymentEnvironments: []string{"staging"},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, makeRuleset("rules", tc.ruleset)); diff != "" {
+				t.Errorf("ruleset differs from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
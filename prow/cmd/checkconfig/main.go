@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -104,6 +105,7 @@ const (
 	unknownFieldsWarning         = "unknown-fields"
 	verifyOwnersFilePresence     = "verify-owners-presence"
 	validateClusterFieldWarning  = "validate-cluster-field"
+	validRulesetsWarning         = "valid-rulesets"
 )
 
 var defaultWarnings = []string{
@@ -121,6 +123,7 @@ var defaultWarnings = []string{
 	validateURLsWarning,
 	unknownFieldsWarning,
 	validateClusterFieldWarning,
+	validRulesetsWarning,
 }
 
 var expensiveWarnings = []string{
@@ -362,6 +365,11 @@ func validate(o options) error {
 			errs = append(errs, err)
 		}
 	}
+	if o.warningEnabled(validRulesetsWarning) {
+		if err := validateRulesets(cfg.BranchProtection); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
 	return utilerrors.NewAggregate(errs)
 }
@@ -453,6 +461,67 @@ func validateStrictBranches(c config.ProwConfig) error {
 	)
 }
 
+// validateRulesets validates the rulesets of the branch protection config
+// before branchprotector sends them to GitHub.
+func validateRulesets(bp config.BranchProtection) error {
+	var errs []error
+	for orgName, org := range bp.Orgs {
+		for name, ruleset := range org.Rulesets {
+			if err := validateRuleset(ruleset); err != nil {
+				errs = append(errs, fmt.Errorf("%s: ruleset %s: %w", orgName, name, err))
+			}
+		}
+		for repoName, repo := range org.Repos {
+			for name, ruleset := range repo.Rulesets {
+				if err := validateRuleset(ruleset); err != nil {
+					errs = append(errs, fmt.Errorf("%s/%s: ruleset %s: %w", orgName, repoName, name, err))
+				}
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func validateRuleset(ruleset config.Ruleset) error {
+	var errs []error
+	switch ruleset.Target {
+	case "", github.RulesetTargetBranch, github.RulesetTargetTag:
+	default:
+		errs = append(errs, fmt.Errorf("target must be %s or %s, not %q", github.RulesetTargetBranch, github.RulesetTargetTag, ruleset.Target))
+	}
+	switch ruleset.Enforcement {
+	case "", github.RulesetEnforcementActive, github.RulesetEnforcementEvaluate, github.RulesetEnforcementDisabled:
+	default:
+		errs = append(errs, fmt.Errorf("enforcement must be %s, %s or %s, not %q", github.RulesetEnforcementActive, github.RulesetEnforcementEvaluate, github.RulesetEnforcementDisabled, ruleset.Enforcement))
+	}
+	if len(ruleset.Include) == 0 {
+		errs = append(errs, errors.New("include must list at least one pattern"))
+	}
+	for _, pattern := range append(append([]string{}, ruleset.Include...), ruleset.Exclude...) {
+		if strings.HasPrefix(pattern, "~") {
+			if pattern != "~DEFAULT_BRANCH" && pattern != "~ALL" {
+				errs = append(errs, fmt.Errorf("unknown pattern %s, only ~DEFAULT_BRANCH and ~ALL are supported", pattern))
+			}
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid pattern %q: %w", pattern, err))
+		}
+	}
+	if ruleset.Target == github.RulesetTargetTag {
+		if ruleset.RequiredPullRequestReviews != nil || ruleset.RequiredStatusChecks != nil || len(ruleset.RequiredDeployments) > 0 {
+			errs = append(errs, errors.New("required_pull_request_reviews, required_status_checks and required_deployments only apply to branches"))
+		}
+	}
+	if rp := ruleset.RequiredPullRequestReviews; rp != nil && (rp.Approvals < 0 || rp.Approvals > 10) {
+		errs = append(errs, fmt.Errorf("required_approving_review_count must be between 0 and 10, not %d", rp.Approvals))
+	}
+	if cp := ruleset.RequiredStatusChecks; cp != nil && len(cp.Contexts) == 0 {
+		errs = append(errs, errors.New("required_status_checks must list at least one context"))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 func validateURLs(c config.ProwConfig) error {
 	var validationErrs []error
 
@@ -1580,3 +1580,97 @@ func TestValidateClusterField(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRulesets(t *testing.T) {
+	testCases := []struct {
+		name          string
+		bp            config.BranchProtection
+		expectedError string
+	}{
+		{
+			name: "valid rulesets",
+			bp: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"org": {
+						Rulesets: map[string]config.Ruleset{
+							"default": {Include: []string{"~DEFAULT_BRANCH"}, RequiredSignatures: true},
+						},
+						Repos: map[string]config.Repo{
+							"repo": {
+								Rulesets: map[string]config.Ruleset{
+									"releases": {
+										Include:                    []string{"release-*"},
+										Exclude:                    []string{"release-0.*"},
+										RequiredPullRequestReviews: &config.RulesetReviewPolicy{Approvals: 1},
+										RequiredStatusChecks:       &config.ContextPolicy{Contexts: []string{"unit"}},
+										RequiredDeployments:        []string{"staging"},
+									},
+									"tags": {Target: "tag", Enforcement: "evaluate", Include: []string{"v*"}, RestrictDeletions: true},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid org ruleset",
+			bp: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"org": {
+						Rulesets: map[string]config.Ruleset{
+							"bad": {Target: "commit", Include: []string{"~MAIN"}},
+						},
+					},
+				},
+			},
+			expectedError: `org: ruleset bad: [target must be branch or tag, not "commit", unknown pattern ~MAIN, only ~DEFAULT_BRANCH and ~ALL are supported]`,
+		},
+		{
+			name: "invalid repo ruleset",
+			bp: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"org": {
+						Repos: map[string]config.Repo{
+							"repo": {
+								Rulesets: map[string]config.Ruleset{
+									"tags": {
+										Target:               "tag",
+										Include:              []string{"v["},
+										RequiredStatusChecks: &config.ContextPolicy{},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedError: `org/repo: ruleset tags: [invalid pattern "v[": syntax error in pattern, required_pull_request_reviews, required_status_checks and required_deployments only apply to branches, required_status_checks must list at least one context]`,
+		},
+		{
+			name: "missing include",
+			bp: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"org": {
+						Rulesets: map[string]config.Ruleset{
+							"empty": {RequiredPullRequestReviews: &config.RulesetReviewPolicy{Approvals: 11}},
+						},
+					},
+				},
+			},
+			expectedError: "org: ruleset empty: [include must list at least one pattern, required_approving_review_count must be between 0 and 10, not 11]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errMsg string
+			if err := validateRulesets(tc.bp); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.expectedError {
+				t.Errorf("expected error %q, got %q", tc.expectedError, errMsg)
+			}
+		})
+	}
+}
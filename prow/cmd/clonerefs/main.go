@@ -17,6 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+
 	"k8s.io/test-infra/prow/clonerefs"
 	"k8s.io/test-infra/prow/logrusutil"
 	"k8s.io/test-infra/prow/pod-utils/options"
@@ -36,6 +40,21 @@ func main() {
 		logrus.Fatalf("Invalid options: %v", err)
 	}
 
+	if o.CacheRefresh > 0 {
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+		if err := o.MaintainCache(stop); err != nil {
+			logrus.WithError(err).Fatal("Failed to maintain clone cache")
+		}
+		logrus.Info("Stopped maintaining clone cache")
+		return
+	}
+
 	if err := o.Run(); err != nil {
 		logrus.WithError(err).Fatal("Failed to clone refs")
 	}
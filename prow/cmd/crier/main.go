@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"os"
 
 	"github.com/sirupsen/logrus"
@@ -33,6 +34,7 @@ import (
 	k8sgcsreporter "k8s.io/test-infra/prow/crier/reporters/gcs/kubernetes"
 	gerritreporter "k8s.io/test-infra/prow/crier/reporters/gerrit"
 	githubreporter "k8s.io/test-infra/prow/crier/reporters/github"
+	gitlabreporter "k8s.io/test-infra/prow/crier/reporters/gitlab"
 	pubsubreporter "k8s.io/test-infra/prow/crier/reporters/pubsub"
 	slackreporter "k8s.io/test-infra/prow/crier/reporters/slack"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
@@ -45,11 +47,14 @@ import (
 )
 
 type options struct {
-	client           prowflagutil.KubernetesOptions
-	cookiefilePath   string
-	gerritProjects   gerritclient.ProjectsFlag
-	github           prowflagutil.GitHubOptions
-	githubEnablement prowflagutil.GitHubEnablementOptions
+	client         prowflagutil.KubernetesOptions
+	cookiefilePath string
+	gerritProjects gerritclient.ProjectsFlag
+	// gerritChecksInstances report to the checks plugin rather than through reviews.
+	gerritChecksInstances prowflagutil.Strings
+	github                prowflagutil.GitHubOptions
+	githubEnablement      prowflagutil.GitHubEnablementOptions
+	gitlab                prowflagutil.GitLabOptions
 
 	configPath    string
 	jobConfigPath string
@@ -57,6 +62,7 @@ type options struct {
 	gerritWorkers         int
 	pubsubWorkers         int
 	githubWorkers         int
+	gitlabWorkers         int
 	slackWorkers          int
 	gcsWorkers            int
 	k8sGCSWorkers         int
@@ -87,7 +93,7 @@ func (o *options) validate() error {
 		o.gerritWorkers = 1
 	}
 
-	if o.gerritWorkers+o.pubsubWorkers+o.githubWorkers+o.slackWorkers+o.gcsWorkers+o.k8sGCSWorkers+o.blobStorageWorkers+o.k8sBlobStorageWorkers <= 0 {
+	if o.gerritWorkers+o.pubsubWorkers+o.githubWorkers+o.gitlabWorkers+o.slackWorkers+o.gcsWorkers+o.k8sGCSWorkers+o.blobStorageWorkers+o.k8sBlobStorageWorkers <= 0 {
 		return errors.New("crier need to have at least one report worker to start")
 	}
 
@@ -103,6 +109,12 @@ func (o *options) validate() error {
 		if o.cookiefilePath == "" {
 			logrus.Info("--cookiefile is not set, using anonymous authentication")
 		}
+
+		for _, instance := range o.gerritChecksInstances.Strings() {
+			if _, ok := o.gerritProjects[instance]; !ok {
+				return fmt.Errorf("--gerrit-checks-instances=%s is not in --gerrit-projects", instance)
+			}
+		}
 	}
 
 	if o.githubWorkers > 0 {
@@ -111,6 +123,12 @@ func (o *options) validate() error {
 		}
 	}
 
+	if o.gitlabWorkers > 0 {
+		if err := o.gitlab.Validate(o.dryrun); err != nil {
+			return err
+		}
+	}
+
 	if o.slackWorkers > 0 {
 		if o.slackTokenFile == "" {
 			return errors.New("--slack-token-file must be set")
@@ -151,9 +169,11 @@ func (o *options) parseArgs(fs *flag.FlagSet, args []string) error {
 
 	fs.StringVar(&o.cookiefilePath, "cookiefile", "", "Path to git http.cookiefile, leave empty for anonymous")
 	fs.Var(&o.gerritProjects, "gerrit-projects", "Set of gerrit repos to monitor on a host example: --gerrit-host=https://android.googlesource.com=platform/build,toolchain/llvm, repeat flag for each host")
+	fs.Var(&o.gerritChecksInstances, "gerrit-checks-instances", "Gerrit instance to report jobs to as check runs of the checks plugin, rather than through review messages and votes, repeat flag for each host")
 	fs.IntVar(&o.gerritWorkers, "gerrit-workers", 0, "Number of gerrit report workers (0 means disabled)")
 	fs.IntVar(&o.pubsubWorkers, "pubsub-workers", 0, "Number of pubsub report workers (0 means disabled)")
 	fs.IntVar(&o.githubWorkers, "github-workers", 0, "Number of github report workers (0 means disabled)")
+	fs.IntVar(&o.gitlabWorkers, "gitlab-workers", 0, "Number of gitlab report workers (0 means disabled)")
 	fs.IntVar(&o.slackWorkers, "slack-workers", 0, "Number of Slack report workers (0 means disabled)")
 	fs.IntVar(&o.gcsWorkers, "gcs-workers", 0, "Number of GCS report workers (0 means disabled)")
 	fs.IntVar(&o.k8sGCSWorkers, "kubernetes-gcs-workers", 0, "Number of Kubernetes-specific GCS report workers (0 means disabled)")
@@ -167,9 +187,10 @@ func (o *options) parseArgs(fs *flag.FlagSet, args []string) error {
 	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
 
 	// TODO(krzyzacy): implement dryrun for gerrit/pubsub
-	fs.BoolVar(&o.dryrun, "dry-run", false, "Run in dry-run mode, not doing actual report (effective for github and Slack only)")
+	fs.BoolVar(&o.dryrun, "dry-run", false, "Run in dry-run mode, not doing actual report (effective for github, gitlab and Slack only)")
 
 	o.github.AddFlags(fs)
+	o.gitlab.AddFlags(fs)
 	o.client.AddFlags(fs)
 	o.storage.AddFlags(fs)
 	o.instrumentationOptions.AddFlags(fs)
@@ -250,7 +271,7 @@ func main() {
 	}
 
 	if o.gerritWorkers > 0 {
-		gerritReporter, err := gerritreporter.NewReporter(o.cookiefilePath, o.gerritProjects, mgr.GetCache())
+		gerritReporter, err := gerritreporter.NewReporter(o.cookiefilePath, o.gerritProjects, o.gerritChecksInstances.Strings(), mgr.GetCache())
 		if err != nil {
 			logrus.WithError(err).Fatal("Error starting gerrit reporter")
 		}
@@ -287,6 +308,18 @@ func main() {
 		}
 	}
 
+	if o.gitlabWorkers > 0 {
+		gitlabClient, err := o.gitlab.GitLabClient(secretAgent, o.dryrun)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error getting GitLab client.")
+		}
+
+		hasReporter = true
+		if err := crier.New(mgr, gitlabreporter.NewReporter(gitlabClient, cfg), o.gitlabWorkers, o.githubEnablement.EnablementChecker()); err != nil {
+			logrus.WithError(err).Fatal("failed to construct gitlab reporter controller")
+		}
+	}
+
 	if o.blobStorageWorkers > 0 || o.k8sBlobStorageWorkers > 0 {
 		opener, err := io.NewOpener(context.Background(), o.storage.GCSCredentialsFile, o.storage.S3CredentialsFile)
 		if err != nil {
@@ -27,6 +27,14 @@ import (
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 )
 
+func gerritChecksInstances(instances ...string) flagutil.Strings {
+	var flag flagutil.Strings
+	for _, instance := range instances {
+		flag.Set(instance)
+	}
+	return flag
+}
+
 func TestOptions(t *testing.T) {
 
 	var defaultGitHubOptions flagutil.GitHubOptions
@@ -87,6 +95,25 @@ func TestOptions(t *testing.T) {
 				instrumentationOptions: defaultInstrumentationOptions,
 			},
 		},
+		{
+			name: "gerrit checks instances",
+			args: []string{"--gerrit-workers=1", "--gerrit-projects=foo=bar", "--gerrit-checks-instances=foo", "--config-path=foo"},
+			expected: &options{
+				gerritWorkers: 1,
+				gerritProjects: map[string][]string{
+					"foo": {"bar"},
+				},
+				gerritChecksInstances:  gerritChecksInstances("foo"),
+				configPath:             "foo",
+				github:                 defaultGitHubOptions,
+				k8sReportFraction:      1.0,
+				instrumentationOptions: defaultInstrumentationOptions,
+			},
+		},
+		{
+			name: "gerrit checks instance without projects, reject",
+			args: []string{"--gerrit-workers=1", "--gerrit-projects=foo=bar", "--gerrit-checks-instances=other", "--config-path=foo"},
+		},
 		//PubSub Reporter
 		{
 			name: "pubsub workers, sets workers",
@@ -104,6 +131,27 @@ func TestOptions(t *testing.T) {
 			name: "pubsub workers set to negative, rejects",
 			args: []string{"--pubsub-workers=-3", "--config-path=foo"},
 		},
+		//GitLab Reporter
+		{
+			name: "gitlab workers, sets workers",
+			args: []string{"--gitlab-workers=2", "--gitlab-endpoint=https://gitlab.example.com", "--gitlab-token-path=/etc/gitlab/token", "--config-path=foo"},
+			expected: &options{
+				gitlabWorkers: 2,
+				gitlab: prowflagutil.GitLabOptions{
+					Endpoint:  "https://gitlab.example.com",
+					TokenPath: "/etc/gitlab/token",
+				},
+				configPath:             "foo",
+				github:                 defaultGitHubOptions,
+				gerritProjects:         defaultGerritProjects,
+				k8sReportFraction:      1.0,
+				instrumentationOptions: defaultInstrumentationOptions,
+			},
+		},
+		{
+			name: "gitlab missing --gitlab-endpoint, rejects",
+			args: []string{"--gitlab-workers=1", "--gitlab-token-path=/etc/gitlab/token", "--config-path=foo"},
+		},
 		//Slack Reporter
 		{
 			name: "slack workers, sets workers",
@@ -23,6 +23,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -32,6 +33,7 @@ import (
 
 	"k8s.io/test-infra/pkg/flagutil"
 	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/gerrit/adapter"
 	"k8s.io/test-infra/prow/gerrit/client"
@@ -49,7 +51,13 @@ type options struct {
 	projects          client.ProjectsFlag
 	// lastSyncFallback is the path to sync the latest timestamp
 	// Can be /local/path, gs://path/to/object or s3://path/to/object.
-	lastSyncFallback       string
+	lastSyncFallback string
+	// eventsPort serves events posted by the gerrit webhooks plugin, when set.
+	eventsPort int
+	// eventsTokenFile holds the token the posted events must carry.
+	eventsTokenFile string
+	// streamEvents maps instances to the ssh targets to run stream-events against.
+	streamEvents           prowflagutil.Strings
 	dryRun                 bool
 	kubernetes             prowflagutil.KubernetesOptions
 	storage                prowflagutil.StorageClientOptions
@@ -76,6 +84,14 @@ func (o *options) validate() error {
 		return errors.New("--last-sync-fallback must be set")
 	}
 
+	if _, err := o.eventSources(); err != nil {
+		return err
+	}
+
+	if o.eventsPort != 0 && o.eventsTokenFile == "" {
+		return errors.New("--events-token-file must be set along with --events-port")
+	}
+
 	if strings.HasPrefix(o.lastSyncFallback, "gs://") && !o.storage.HasGCSCredentials() {
 		logrus.WithField("last-sync-fallback", o.lastSyncFallback).Info("--gcs-credentials-file unset, will try and access with a default service account")
 	}
@@ -93,6 +109,9 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.StringVar(&o.cookiefilePath, "cookiefile", "", "Path to git http.cookiefile, leave empty for anonymous")
 	fs.Var(&o.projects, "gerrit-projects", "Set of gerrit repos to monitor on a host example: --gerrit-host=https://android.googlesource.com=platform/build,toolchain/llvm, repeat fs for each host")
 	fs.StringVar(&o.lastSyncFallback, "last-sync-fallback", "", "The /local/path, gs://path/to/object or s3://path/to/object to sync the latest timestamp")
+	fs.IntVar(&o.eventsPort, "events-port", 0, "Port to receive events from the gerrit webhooks plugin on, 0 to not listen for them.")
+	fs.StringVar(&o.eventsTokenFile, "events-token-file", "", "Path to the file containing the token events received on --events-port must carry.")
+	fs.Var(&o.streamEvents, "stream-events", "Run gerrit stream-events over ssh to receive events from a host, example: --stream-events=https://android.googlesource.com=prow@android.googlesource.com:29418, repeat for each host")
 	fs.BoolVar(&o.dryRun, "dry-run", false, "Run in dry-run mode, performing no modifying actions.")
 	fs.StringVar(&o.tokenPathOverride, "token-path", "", "Force the use of the token in this path, use with gcloud auth print-access-token")
 	for _, group := range []flagutil.OptionGroup{&o.kubernetes, &o.storage, &o.instrumentationOptions} {
@@ -102,6 +121,28 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	return o
 }
 
+// eventSources returns the stream-events sources configured by --stream-events.
+func (o *options) eventSources() ([]adapter.EventSource, error) {
+	var sources []adapter.EventSource
+	for _, value := range o.streamEvents.Strings() {
+		// Instances are URLs themselves, so split on the last =
+		idx := strings.LastIndex(value, "=")
+		if idx == -1 {
+			return nil, fmt.Errorf("--stream-events=%q must be formatted as instance=[user@]host[:port]", value)
+		}
+		instance, target := value[:idx], value[idx+1:]
+		if _, ok := o.projects[instance]; !ok {
+			return nil, fmt.Errorf("--stream-events=%q: %s is not in --gerrit-projects", value, instance)
+		}
+		source, err := adapter.NewSSHStreamEventSource(instance, target)
+		if err != nil {
+			return nil, fmt.Errorf("--stream-events=%q: %v", value, err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
 // opener has methods to read and write paths
 type opener interface {
 	Reader(ctx context.Context, path string) (io.ReadCloser, error)
@@ -277,6 +318,36 @@ func main() {
 	logrus.Infof("Starting gerrit fetcher")
 
 	defer interrupts.WaitForGracefulShutdown()
+
+	sources, err := o.eventSources()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating event sources.")
+	}
+	receivesEvents := len(sources) > 0 || o.eventsPort != 0
+	if receivesEvents {
+		events := make(chan adapter.InstanceEvent, 100)
+		for _, source := range sources {
+			source := source
+			interrupts.Run(func(ctx context.Context) {
+				if err := source.Run(ctx, events); err != nil {
+					logrus.WithError(err).Error("Event source failed.")
+				}
+			})
+		}
+		if o.eventsPort != 0 {
+			secretAgent := &secret.Agent{}
+			if err := secretAgent.Start([]string{o.eventsTokenFile}); err != nil {
+				logrus.WithError(err).Fatal("Error loading events token.")
+			}
+			handler := adapter.NewEventHandler(events, secretAgent.GetTokenGenerator(o.eventsTokenFile))
+			server := &http.Server{Addr: fmt.Sprintf(":%d", o.eventsPort), Handler: handler}
+			interrupts.ListenAndServe(server, 5*time.Second)
+		}
+		interrupts.Run(func(ctx context.Context) {
+			c.ProcessEvents(ctx, events)
+		})
+	}
+
 	interrupts.Tick(func() {
 		start := time.Now()
 		if err := c.Sync(); err != nil {
@@ -284,6 +355,9 @@ func main() {
 		}
 		logrus.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Info("Synced")
 	}, func() time.Duration {
+		if receivesEvents {
+			return cfg().Gerrit.EventsPollInterval.Duration
+		}
 		return cfg().Gerrit.TickInterval.Duration
 	})
 }
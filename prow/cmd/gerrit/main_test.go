@@ -98,6 +98,41 @@ func TestFlags(t *testing.T) {
 				o.storage.S3CredentialsFile = "/creds"
 			},
 		},
+		{
+			name: "events are received",
+			args: map[string]string{
+				"--events-port":       "8888",
+				"--events-token-file": "/etc/events/token",
+				"--stream-events":     "foo=prow@foo:29418",
+			},
+			expected: func(o *options) {
+				o.eventsPort = 8888
+				o.eventsTokenFile = "/etc/events/token"
+				o.streamEvents = flagutil.Strings{}
+				o.streamEvents.Set("foo=prow@foo:29418")
+			},
+		},
+		{
+			name: "events port without a token",
+			args: map[string]string{
+				"--events-port": "8888",
+			},
+			err: true,
+		},
+		{
+			name: "stream events from unmonitored host",
+			args: map[string]string{
+				"--stream-events": "other=prow@other",
+			},
+			err: true,
+		},
+		{
+			name: "stream events without a target",
+			args: map[string]string{
+				"--stream-events": "foo",
+			},
+			err: true,
+		},
 	}
 
 	for _, tc := range cases {
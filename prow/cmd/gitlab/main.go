@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/secret"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/gitlab/adapter"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/metrics"
+	"k8s.io/test-infra/prow/pjutil"
+)
+
+type options struct {
+	port int
+
+	configPath    string
+	jobConfigPath string
+
+	dryRun      bool
+	gracePeriod time.Duration
+
+	kubernetes             prowflagutil.KubernetesOptions
+	gitlab                 prowflagutil.GitLabOptions
+	instrumentationOptions prowflagutil.InstrumentationOptions
+
+	webhookSecretFile string
+}
+
+func (o *options) Validate() error {
+	if o.configPath == "" {
+		return errors.New("--config-path must be set")
+	}
+	for _, group := range []flagutil.OptionGroup{&o.kubernetes, &o.gitlab} {
+		if err := group.Validate(o.dryRun); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.IntVar(&o.port, "port", 8888, "Port to listen on.")
+
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
+	fs.DurationVar(&o.gracePeriod, "grace-period", 180*time.Second, "On shutdown, try to handle remaining events for the specified duration. ")
+	for _, group := range []flagutil.OptionGroup{&o.kubernetes, &o.gitlab, &o.instrumentationOptions} {
+		group.AddFlags(fs)
+	}
+
+	fs.StringVar(&o.webhookSecretFile, "webhook-secret-file", "/etc/webhook/token", "Path to the file containing the secret token of the GitLab webhooks.")
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	configAgent := &config.Agent{}
+	if err := configAgent.Start(o.configPath, o.jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
+	}
+	cfg := configAgent.Config
+
+	secretAgent := &secret.Agent{}
+	if err := secretAgent.Start([]string{o.webhookSecretFile}); err != nil {
+		logrus.WithError(err).Fatal("Error starting secrets agent.")
+	}
+
+	gitlabClient, err := o.gitlab.GitLabClient(secretAgent, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting GitLab client.")
+	}
+
+	prowJobClient, err := o.kubernetes.ProwJobClient(cfg().ProwJobNamespace, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting ProwJob client for infrastructure cluster.")
+	}
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	metrics.ExposeMetrics("gitlab", cfg().PushGateway, o.instrumentationOptions.MetricsPort)
+	pjutil.ServePProf(o.instrumentationOptions.PProfPort)
+
+	server := adapter.NewServer(cfg, gitlabClient, prowJobClient, secretAgent.GetTokenGenerator(o.webhookSecretFile))
+	interrupts.OnInterrupt(server.GracefulShutdown)
+
+	health := pjutil.NewHealthOnPort(o.instrumentationOptions.HealthPort)
+
+	mux := http.NewServeMux()
+	mux.Handle("/hook", server)
+	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+
+	health.ServeReady()
+
+	interrupts.ListenAndServe(httpServer, o.gracePeriod)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		err  bool
+	}{
+		{
+			name: "minimal flags work",
+			args: []string{"--config-path=config.yaml", "--gitlab-endpoint=https://gitlab.example.com", "--gitlab-token-path=/etc/gitlab/token"},
+		},
+		{
+			name: "--config-path is required",
+			args: []string{"--gitlab-endpoint=https://gitlab.example.com", "--gitlab-token-path=/etc/gitlab/token"},
+			err:  true,
+		},
+		{
+			name: "--gitlab-endpoint is required",
+			args: []string{"--config-path=config.yaml", "--gitlab-token-path=/etc/gitlab/token"},
+			err:  true,
+		},
+		{
+			name: "--gitlab-endpoint must be a URL",
+			args: []string{"--config-path=config.yaml", "--gitlab-endpoint=gitlab", "--gitlab-token-path=/etc/gitlab/token"},
+			err:  true,
+		},
+		{
+			name: "--gitlab-token-path is required",
+			args: []string{"--config-path=config.yaml", "--gitlab-endpoint=https://gitlab.example.com"},
+			err:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"--deck-url=http://whatever"}, tc.args...)
+			o := gatherOptions(flag.NewFlagSet("gitlab", flag.PanicOnError), args...)
+			if err := o.Validate(); (err != nil) != tc.err {
+				t.Errorf("Validate() = %v, want error %t", err, tc.err)
+			}
+		})
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"k8s.io/test-infra/prow/config/secret"
 	"k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/plan"
 	"k8s.io/test-infra/prow/logrusutil"
 )
 
@@ -45,27 +46,32 @@ const (
 )
 
 type options struct {
-	config            string
-	confirm           bool
-	dump              string
-	dumpFull          bool
-	maximumDelta      float64
-	minAdmins         int
-	requireSelf       bool
-	requiredAdmins    flagutil.Strings
-	fixOrg            bool
-	fixOrgMembers     bool
-	fixTeamMembers    bool
-	fixTeams          bool
-	fixTeamRepos      bool
-	fixRepos          bool
-	ignoreSecretTeams bool
-	allowRepoArchival bool
-	allowRepoPublish  bool
-	github            flagutil.GitHubOptions
-	tokenBurst        int
-	tokensPerHour     int
-	logLevel          string
+	config               string
+	confirm              bool
+	dump                 string
+	dumpFull             bool
+	maximumDelta         float64
+	minAdmins            int
+	requireSelf          bool
+	requiredAdmins       flagutil.Strings
+	fixOrg               bool
+	fixOrgMembers        bool
+	fixTeamMembers       bool
+	fixTeams             bool
+	fixTeamRepos         bool
+	fixRepos             bool
+	fixRepoCollaborators bool
+	fixRepoWebhooks      bool
+	fixRepoDeployKeys    bool
+	fixRepoLabels        bool
+	ignoreSecretTeams    bool
+	allowRepoArchival    bool
+	allowRepoPublish     bool
+	github               flagutil.GitHubOptions
+	tokenBurst           int
+	tokensPerHour        int
+	logLevel             string
+	plan                 plan.Options
 }
 
 func parseOptions() options {
@@ -95,16 +101,24 @@ func (o *options) parseArgs(flags *flag.FlagSet, args []string) error {
 	flags.BoolVar(&o.fixTeamMembers, "fix-team-members", false, "Add/remove team members if set")
 	flags.BoolVar(&o.fixTeamRepos, "fix-team-repos", false, "Add/remove team permissions on repos if set")
 	flags.BoolVar(&o.fixRepos, "fix-repos", false, "Create/update repositories if set")
+	flags.BoolVar(&o.fixRepoCollaborators, "fix-repo-collaborators", false, "Add/remove direct collaborators of repositories if set")
+	flags.BoolVar(&o.fixRepoWebhooks, "fix-repo-webhooks", false, "Create/update/delete webhooks of repositories if set")
+	flags.BoolVar(&o.fixRepoDeployKeys, "fix-repo-deploy-keys", false, "Add/remove deploy keys of repositories if set")
+	flags.BoolVar(&o.fixRepoLabels, "fix-repo-labels", false, "Create/update/delete labels of repositories if set")
 	flags.BoolVar(&o.allowRepoArchival, "allow-repo-archival", false, "If set, archiving repos is allowed while updating repos")
 	flags.BoolVar(&o.allowRepoPublish, "allow-repo-publish", false, "If set, making private repos public is allowed while updating repos")
 	flags.StringVar(&o.logLevel, "log-level", logrus.InfoLevel.String(), fmt.Sprintf("Logging level, one of %v", logrus.AllLevels))
 	o.github.AddFlags(flags)
+	o.plan.AddFlags(flags)
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
 	if err := o.github.Validate(!o.confirm); err != nil {
 		return err
 	}
+	if err := o.plan.Validate(!o.confirm); err != nil {
+		return err
+	}
 	if o.tokensPerHour > 0 && o.tokenBurst >= o.tokensPerHour {
 		return fmt.Errorf("--tokens=%d must exceed --token-burst=%d", o.tokensPerHour, o.tokenBurst)
 	}
@@ -138,6 +152,17 @@ func (o *options) parseArgs(flags *flag.FlagSet, args []string) error {
 		return fmt.Errorf("--fix-team-repos requires --fix-teams")
 	}
 
+	for flag, set := range map[string]bool{
+		"--fix-repo-collaborators": o.fixRepoCollaborators,
+		"--fix-repo-webhooks":      o.fixRepoWebhooks,
+		"--fix-repo-deploy-keys":   o.fixRepoDeployKeys,
+		"--fix-repo-labels":        o.fixRepoLabels,
+	} {
+		if set && !o.fixRepos {
+			return fmt.Errorf("%s requires --fix-repos", flag)
+		}
+	}
+
 	level, err := logrus.ParseLevel(o.logLevel)
 	if err != nil {
 		return fmt.Errorf("--log-level invalid: %v", err)
@@ -197,11 +222,19 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to load configuration")
 	}
 
+	changes := &plan.Plan{}
+	client := newPlanClient(githubClient, changes)
 	for name, orgcfg := range cfg.Orgs {
-		if err := configureOrg(o, githubClient, name, orgcfg); err != nil {
+		if err := configureOrg(o, client, name, orgcfg); err != nil {
+			if err := o.plan.Write(changes); err != nil {
+				logrus.WithError(err).Error("Failed to write plan.")
+			}
 			logrus.Fatalf("Configuration failed: %v", err)
 		}
 	}
+	if err := o.plan.Write(changes); err != nil {
+		logrus.WithError(err).Fatal("Failed to write plan.")
+	}
 	logrus.Info("Finished syncing configuration.")
 }
 
@@ -214,6 +247,11 @@ type dumpClient interface {
 	GetRepo(owner, name string) (github.FullRepo, error)
 	GetRepos(org string, isUser bool) ([]github.Repo, error)
 	BotUser() (*github.UserData, error)
+	ListDirectCollaborators(org, repo string) ([]github.User, error)
+	ListRepoInvitations(org, repo string) ([]github.RepoInvitation, error)
+	ListRepoHooks(org, repo string) ([]github.Hook, error)
+	ListDeployKeys(org, repo string) ([]github.DeployKey, error)
+	GetRepoLabels(org, repo string) ([]github.Label, error)
 }
 
 func dumpOrgConfig(client dumpClient, orgName string, ignoreSecretTeams bool) (*org.Config, error) {
@@ -364,7 +402,7 @@ func dumpOrgConfig(client dumpClient, orgName string, ignoreSecretTeams bool) (*
 			return nil, fmt.Errorf("failed to get repo: %v", err)
 		}
 		logrus.WithField("repo", full.FullName).Debug("Recording repo.")
-		out.Repos[full.Name] = org.PruneRepoDefaults(org.Repo{
+		repoConfig := org.PruneRepoDefaults(org.Repo{
 			Description:      &full.Description,
 			HomePage:         &full.Homepage,
 			Private:          &full.Private,
@@ -377,11 +415,85 @@ func dumpOrgConfig(client dumpClient, orgName string, ignoreSecretTeams bool) (*
 			Archived:         &full.Archived,
 			DefaultBranch:    &full.DefaultBranch,
 		})
+		if err := dumpRepoAccess(client, orgName, full.Name, &repoConfig); err != nil {
+			return nil, fmt.Errorf("failed to dump repo %s: %v", full.Name, err)
+		}
+		out.Repos[full.Name] = repoConfig
 	}
 
 	return &out, nil
 }
 
+// dumpRepoAccess records the collaborators, pending invitees included, as well
+// as the webhooks, deploy keys and labels of the repo. Webhook secrets cannot
+// be read back from GitHub.
+func dumpRepoAccess(client dumpClient, orgName, repoName string, repo *org.Repo) error {
+	users, err := client.ListDirectCollaborators(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list collaborators: %v", err)
+	}
+	invitations, err := client.ListRepoInvitations(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list invitations: %v", err)
+	}
+	if len(users)+len(invitations) > 0 {
+		repo.Collaborators = map[string]github.RepoPermissionLevel{}
+	}
+	for _, user := range users {
+		repo.Collaborators[user.Login] = github.LevelFromPermissions(user.Permissions)
+	}
+	for _, invitation := range invitations {
+		if invitation.Invitee.Login == "" {
+			continue
+		}
+		repo.Collaborators[invitation.Invitee.Login] = github.RepoPermissionLevel(invitation.Permissions)
+	}
+
+	hooks, err := client.ListRepoHooks(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %v", err)
+	}
+	if len(hooks) > 0 {
+		repo.Webhooks = map[string]org.Webhook{}
+	}
+	for _, hook := range hooks {
+		active := hook.Active
+		repo.Webhooks[hook.Config.URL] = org.Webhook{
+			Events:      hook.Events,
+			ContentType: hook.Config.ContentType,
+			Active:      &active,
+		}
+	}
+
+	keys, err := client.ListDeployKeys(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list deploy keys: %v", err)
+	}
+	if len(keys) > 0 {
+		repo.DeployKeys = map[string]org.DeployKey{}
+	}
+	for _, key := range keys {
+		repo.DeployKeys[key.Title] = org.DeployKey{Key: key.Key, ReadOnly: key.ReadOnly}
+	}
+
+	labels, err := client.GetRepoLabels(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list labels: %v", err)
+	}
+	if len(labels) > 0 {
+		repo.Labels = map[string]org.Label{}
+	}
+	for _, label := range labels {
+		l := org.Label{Color: label.Color}
+		if label.Description != "" {
+			description := label.Description
+			l.Description = &description
+		}
+		repo.Labels[label.Name] = l
+	}
+	return nil
+}
+
 type orgClient interface {
 	BotUser() (*github.UserData, error)
 	ListOrgMembers(org, role string) ([]github.TeamMember, error)
@@ -840,6 +952,13 @@ func configureOrg(opt options, client github.Client, orgName string, orgConfig o
 		return fmt.Errorf("failed to configure %s repos: %v", orgName, err)
 	}
 
+	// Configure the collaborators, webhooks, deploy keys and labels of repositories
+	if !opt.fixRepoCollaborators && !opt.fixRepoWebhooks && !opt.fixRepoDeployKeys && !opt.fixRepoLabels {
+		logrus.Info("Skipping repository collaborators, webhooks, deploy keys and labels configuration")
+	} else if err := configureRepoAccess(opt, client, orgName, orgConfig); err != nil {
+		return fmt.Errorf("failed to configure %s repo collaborators, webhooks, deploy keys and labels: %v", orgName, err)
+	}
+
 	if !opt.fixTeams {
 		logrus.Infof("Skipping team and team member configuration")
 		return nil
@@ -1066,6 +1185,427 @@ func configureRepos(opt options, client repoClient, orgName string, orgConfig or
 	return utilerrors.NewAggregate(allErrors)
 }
 
+type repoAccessClient interface {
+	GetRepos(orgName string, isUser bool) ([]github.Repo, error)
+	repoCollaboratorClient
+	repoWebhookClient
+	deployKeyClient
+	repoLabelClient
+}
+
+// configureRepoAccess configures the collaborators, webhooks, deploy keys and
+// labels of the existing repos which declare them.
+func configureRepoAccess(opt options, client repoAccessClient, orgName string, orgConfig org.Config) error {
+	repoList, err := client.GetRepos(orgName, false)
+	if err != nil {
+		return fmt.Errorf("failed to get repos: %v", err)
+	}
+	byName := make(map[string]github.Repo, len(repoList))
+	for _, repo := range repoList {
+		byName[strings.ToLower(repo.Name)] = repo
+	}
+
+	var allErrors []error
+	for wantName, wantRepo := range orgConfig.Repos {
+		repoLogger := logrus.WithField("repo", wantName)
+		var repo *github.Repo
+		for _, possibleName := range append([]string{wantName}, wantRepo.Previously...) {
+			if r, exists := byName[strings.ToLower(possibleName)]; exists {
+				repo = &r
+				break
+			}
+		}
+		switch {
+		case repo == nil:
+			repoLogger.Info("repo does not exist, skipping its collaborators, webhooks, deploy keys and labels")
+			continue
+		case repo.Archived:
+			repoLogger.Info("repo is archived, skipping its collaborators, webhooks, deploy keys and labels")
+			continue
+		}
+
+		if opt.fixRepoCollaborators && wantRepo.Collaborators != nil {
+			if err := configureRepoCollaborators(client, orgName, repo.Name, wantRepo.Collaborators, opt.maximumDelta); err != nil {
+				allErrors = append(allErrors, fmt.Errorf("failed to configure %s collaborators: %v", repo.Name, err))
+			}
+		}
+		if opt.fixRepoWebhooks && wantRepo.Webhooks != nil {
+			if err := configureRepoWebhooks(client, orgName, repo.Name, wantRepo.Webhooks, opt.maximumDelta); err != nil {
+				allErrors = append(allErrors, fmt.Errorf("failed to configure %s webhooks: %v", repo.Name, err))
+			}
+		}
+		if opt.fixRepoDeployKeys && wantRepo.DeployKeys != nil {
+			if err := configureDeployKeys(client, orgName, repo.Name, wantRepo.DeployKeys, opt.maximumDelta); err != nil {
+				allErrors = append(allErrors, fmt.Errorf("failed to configure %s deploy keys: %v", repo.Name, err))
+			}
+		}
+		if opt.fixRepoLabels && wantRepo.Labels != nil {
+			if err := configureRepoLabels(client, orgName, repo.Name, wantRepo.Labels, opt.maximumDelta); err != nil {
+				allErrors = append(allErrors, fmt.Errorf("failed to configure %s labels: %v", repo.Name, err))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(allErrors)
+}
+
+// checkRemovalDelta returns an error when removing remove out of have items
+// exceeds maxDelta.
+func checkRemovalDelta(kind string, remove, have int, maxDelta float64) error {
+	if have == 0 {
+		return nil
+	}
+	if d := float64(remove) / float64(have); d > maxDelta {
+		return fmt.Errorf("cannot delete %d %s or %.3f of them (exceeds limit of %.3f)", remove, kind, d, maxDelta)
+	}
+	return nil
+}
+
+type repoCollaboratorClient interface {
+	ListDirectCollaborators(org, repo string) ([]github.User, error)
+	ListRepoInvitations(org, repo string) ([]github.RepoInvitation, error)
+	AddCollaborator(org, repo, user string, permission github.RepoPermissionLevel) error
+	RemoveCollaborator(org, repo, user string) error
+	DeleteRepoInvitation(org, repo string, id int) error
+}
+
+// configureRepoCollaborators gives the declared users direct access to the repo,
+// inviting them when necessary, and removes any other direct collaborator.
+func configureRepoCollaborators(client repoCollaboratorClient, orgName, repoName string, want map[string]github.RepoPermissionLevel, maxDelta float64) error {
+	wantLevels := map[string]github.RepoPermissionLevel{}
+	for user, permission := range want {
+		if permission == github.None {
+			return fmt.Errorf("collaborator %s must have read, write or admin permission", user)
+		}
+		wantLevels[github.NormLogin(user)] = permission
+	}
+
+	have := map[string]github.RepoPermissionLevel{}
+	users, err := client.ListDirectCollaborators(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list collaborators: %v", err)
+	}
+	for _, user := range users {
+		have[github.NormLogin(user.Login)] = github.LevelFromPermissions(user.Permissions)
+	}
+	invitations, err := client.ListRepoInvitations(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list invitations: %v", err)
+	}
+	invited := map[string]github.RepoInvitation{}
+	for _, invitation := range invitations {
+		if invitation.Invitee.Login == "" {
+			continue
+		}
+		invited[github.NormLogin(invitation.Invitee.Login)] = invitation
+	}
+
+	var remove []string
+	for user := range have {
+		if _, ok := wantLevels[user]; !ok {
+			remove = append(remove, user)
+		}
+	}
+	var cancel []github.RepoInvitation
+	for user, invitation := range invited {
+		if _, ok := wantLevels[user]; !ok {
+			cancel = append(cancel, invitation)
+		}
+	}
+	if err := checkRemovalDelta("collaborators", len(remove)+len(cancel), len(have)+len(invited), maxDelta); err != nil {
+		return err
+	}
+
+	var errs []error
+	for user, permission := range wantLevels {
+		if have[user] == permission {
+			continue
+		}
+		if invitation, ok := invited[user]; ok && github.RepoPermissionLevel(invitation.Permissions) == permission {
+			logrus.Infof("Waiting for %s to accept invitation to %s/%s", user, orgName, repoName)
+			continue
+		}
+		if err := client.AddCollaborator(orgName, repoName, user, permission); err != nil {
+			errs = append(errs, fmt.Errorf("failed to add %s as a %s collaborator: %v", user, permission, err))
+			continue
+		}
+		logrus.Infof("Set %s as a %s collaborator of %s/%s", user, permission, orgName, repoName)
+	}
+	for _, user := range remove {
+		if err := client.RemoveCollaborator(orgName, repoName, user); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove collaborator %s: %v", user, err))
+			continue
+		}
+		logrus.Infof("Removed collaborator %s from %s/%s", user, orgName, repoName)
+	}
+	for _, invitation := range cancel {
+		if err := client.DeleteRepoInvitation(orgName, repoName, invitation.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to cancel invitation of %s: %v", invitation.Invitee.Login, err))
+			continue
+		}
+		logrus.Infof("Canceled invitation of %s to %s/%s", invitation.Invitee.Login, orgName, repoName)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+type repoWebhookClient interface {
+	ListRepoHooks(org, repo string) ([]github.Hook, error)
+	CreateRepoHook(org, repo string, req github.HookRequest) (int, error)
+	EditRepoHook(org, repo string, id int, req github.HookRequest) error
+	DeleteRepoHook(org, repo string, id int, req github.HookRequest) error
+}
+
+// readSecret is overridden in tests.
+var readSecret = func(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// webhookConfig returns the config of the webhook with its secret, when it has one.
+func webhookConfig(url string, hook org.Webhook) (*github.HookConfig, error) {
+	config := &github.HookConfig{URL: url, ContentType: hook.ContentType}
+	if hook.SecretFile != nil {
+		secret, err := readSecret(*hook.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret of webhook %s: %v", url, err)
+		}
+		config.Secret = &secret
+	}
+	return config, nil
+}
+
+// configureRepoWebhooks creates, updates and deletes the webhooks of the repo,
+// identified by their payload URL.
+func configureRepoWebhooks(client repoWebhookClient, orgName, repoName string, want map[string]org.Webhook, maxDelta float64) error {
+	hooks, err := client.ListRepoHooks(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %v", err)
+	}
+	have := map[string]github.Hook{}
+	var remove []github.Hook
+	for _, hook := range hooks {
+		_, wanted := want[hook.Config.URL]
+		if _, dup := have[hook.Config.URL]; dup || !wanted {
+			remove = append(remove, hook)
+			continue
+		}
+		have[hook.Config.URL] = hook
+	}
+	if err := checkRemovalDelta("webhooks", len(remove), len(hooks), maxDelta); err != nil {
+		return err
+	}
+
+	var errs []error
+	for url, wantHook := range want {
+		hook, exists := have[url]
+		if !exists {
+			config, err := webhookConfig(url, wantHook)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			req := github.HookRequest{Name: "web", Active: wantHook.Active, Config: config, Events: wantHook.Events}
+			if _, err := client.CreateRepoHook(orgName, repoName, req); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create webhook %s: %v", url, err))
+				continue
+			}
+			logrus.Infof("Created webhook %s of %s/%s", url, orgName, repoName)
+			continue
+		}
+
+		var req github.HookRequest
+		change := false
+		if wantHook.Events != nil && !sets.NewString(wantHook.Events...).Equal(sets.NewString(hook.Events...)) {
+			req.Events = wantHook.Events
+			change = true
+		}
+		if wantHook.Active != nil && *wantHook.Active != hook.Active {
+			req.Active = wantHook.Active
+			change = true
+		}
+		if wantHook.ContentType != nil && (hook.Config.ContentType == nil || *hook.Config.ContentType != *wantHook.ContentType) {
+			// The config is replaced as a whole, so it needs the secret too.
+			// GitHub only tells us whether the webhook has one.
+			if wantHook.SecretFile == nil && hook.Config.Secret != nil {
+				errs = append(errs, fmt.Errorf("cannot change the content type of webhook %s without its secret_file, it would remove the secret", url))
+			} else {
+				config, err := webhookConfig(url, wantHook)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				req.Config = config
+				change = true
+			}
+		}
+		if !change {
+			continue
+		}
+		if err := client.EditRepoHook(orgName, repoName, hook.ID, req); err != nil {
+			errs = append(errs, fmt.Errorf("failed to edit webhook %s: %v", url, err))
+			continue
+		}
+		logrus.Infof("Updated webhook %s of %s/%s", url, orgName, repoName)
+	}
+	for _, hook := range remove {
+		if err := client.DeleteRepoHook(orgName, repoName, hook.ID, github.HookRequest{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete webhook %d(%s): %v", hook.ID, hook.Config.URL, err))
+			continue
+		}
+		logrus.Infof("Deleted webhook %d(%s) of %s/%s", hook.ID, hook.Config.URL, orgName, repoName)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+type deployKeyClient interface {
+	ListDeployKeys(org, repo string) ([]github.DeployKey, error)
+	CreateDeployKey(org, repo string, key github.DeployKey) error
+	DeleteDeployKey(org, repo string, id int) error
+}
+
+// sameKey compares the type and data of public keys, ignoring their comments.
+func sameKey(a, b string) bool {
+	fa, fb := strings.Fields(a), strings.Fields(b)
+	if len(fa) < 2 || len(fb) < 2 {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+	return fa[0] == fb[0] && fa[1] == fb[1]
+}
+
+// configureDeployKeys adds and removes the deploy keys of the repo, identified
+// by their title. Deploy keys cannot be edited, so changed keys are replaced.
+func configureDeployKeys(client deployKeyClient, orgName, repoName string, want map[string]org.DeployKey, maxDelta float64) error {
+	keys, err := client.ListDeployKeys(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list deploy keys: %v", err)
+	}
+	have := sets.NewString()
+	var remove []github.DeployKey
+	for _, key := range keys {
+		wantKey, wanted := want[key.Title]
+		if !wanted || have.Has(key.Title) || !sameKey(key.Key, wantKey.Key) || key.ReadOnly != wantKey.ReadOnly {
+			remove = append(remove, key)
+			continue
+		}
+		have.Insert(key.Title)
+	}
+	if err := checkRemovalDelta("deploy keys", len(remove), len(keys), maxDelta); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range remove {
+		if err := client.DeleteDeployKey(orgName, repoName, key.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete deploy key %d(%s): %v", key.ID, key.Title, err))
+			continue
+		}
+		logrus.Infof("Deleted deploy key %d(%s) of %s/%s", key.ID, key.Title, orgName, repoName)
+	}
+	for title, wantKey := range want {
+		if have.Has(title) {
+			continue
+		}
+		if err := client.CreateDeployKey(orgName, repoName, github.DeployKey{Title: title, Key: wantKey.Key, ReadOnly: wantKey.ReadOnly}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create deploy key %s: %v", title, err))
+			continue
+		}
+		logrus.Infof("Created deploy key %s of %s/%s", title, orgName, repoName)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+type repoLabelClient interface {
+	GetRepoLabels(org, repo string) ([]github.Label, error)
+	AddRepoLabel(org, repo, label, description, color string) error
+	UpdateRepoLabel(org, repo, label, newName, description, color string) error
+	DeleteRepoLabel(org, repo, label string) error
+}
+
+func normalizeColor(color string) string {
+	return strings.ToLower(strings.TrimPrefix(color, "#"))
+}
+
+// configureRepoLabels creates, renames, updates and deletes the labels of the repo.
+func configureRepoLabels(client repoLabelClient, orgName, repoName string, want map[string]org.Label, maxDelta float64) error {
+	seen := sets.NewString()
+	for name, label := range want {
+		for _, n := range append([]string{name}, label.Previously...) {
+			if seen.Has(strings.ToLower(n)) {
+				return fmt.Errorf("label names must be unique (including previous names, ignoring case): %s", n)
+			}
+			seen.Insert(strings.ToLower(n))
+		}
+	}
+
+	labels, err := client.GetRepoLabels(orgName, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to list labels: %v", err)
+	}
+	have := map[string]github.Label{}
+	for _, label := range labels {
+		have[strings.ToLower(label.Name)] = label
+	}
+
+	used := sets.NewString()
+	matches := map[string]github.Label{}
+	for name, label := range want {
+		for _, n := range append([]string{name}, label.Previously...) {
+			if l, ok := have[strings.ToLower(n)]; ok {
+				matches[name] = l
+				used.Insert(strings.ToLower(l.Name))
+				break
+			}
+		}
+	}
+	var remove []string
+	for _, label := range labels {
+		if !used.Has(strings.ToLower(label.Name)) {
+			remove = append(remove, label.Name)
+		}
+	}
+	if err := checkRemovalDelta("labels", len(remove), len(labels), maxDelta); err != nil {
+		return err
+	}
+
+	var errs []error
+	for name, wantLabel := range want {
+		color := normalizeColor(wantLabel.Color)
+		current, exists := matches[name]
+		if !exists {
+			var description string
+			if wantLabel.Description != nil {
+				description = *wantLabel.Description
+			}
+			if err := client.AddRepoLabel(orgName, repoName, name, description, color); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create label %s: %v", name, err))
+				continue
+			}
+			logrus.Infof("Created label %s of %s/%s", name, orgName, repoName)
+			continue
+		}
+		description := current.Description
+		updateString(&description, wantLabel.Description)
+		if current.Name == name && normalizeColor(current.Color) == color && current.Description == description {
+			continue
+		}
+		if err := client.UpdateRepoLabel(orgName, repoName, current.Name, name, description, color); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update label %s: %v", current.Name, err))
+			continue
+		}
+		logrus.Infof("Updated label %s of %s/%s to %s", current.Name, orgName, repoName, name)
+	}
+	for _, name := range remove {
+		if err := client.DeleteRepoLabel(orgName, repoName, name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete label %s: %v", name, err))
+			continue
+		}
+		logrus.Infof("Deleted label %s of %s/%s", name, orgName, repoName)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 func configureTeamAndMembers(opt options, client github.Client, githubTeams map[string]github.Team, name, orgName string, team org.Team, parent *int) error {
 	gt, ok := githubTeams[name]
 	if !ok { // configureTeams is buggy if this is the case
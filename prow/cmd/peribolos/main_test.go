@@ -25,11 +25,13 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"k8s.io/apimachinery/pkg/util/diff"
 
 	"k8s.io/test-infra/prow/config/org"
 	"k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/plan"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/yaml"
@@ -120,6 +122,50 @@ func TestOptions(t *testing.T) {
 			name: "reject --fix-team-members without --fix-teams",
 			args: []string{"--config-path=foo", "--fix-team-members"},
 		},
+		{
+			name: "reject --fix-repo-collaborators without --fix-repos",
+			args: []string{"--config-path=foo", "--fix-repo-collaborators"},
+		},
+		{
+			name: "reject --fix-repo-labels without --fix-repos",
+			args: []string{"--config-path=foo", "--fix-repo-labels"},
+		},
+		{
+			name: "allow repo access fixes with --fix-repos",
+			args: []string{"--config-path=foo", "--fix-repos", "--fix-repo-collaborators", "--fix-repo-webhooks", "--fix-repo-deploy-keys", "--fix-repo-labels"},
+			expected: &options{
+				config:               "foo",
+				minAdmins:            defaultMinAdmins,
+				requireSelf:          true,
+				maximumDelta:         defaultDelta,
+				tokensPerHour:        defaultTokens,
+				tokenBurst:           defaultBurst,
+				fixRepos:             true,
+				fixRepoCollaborators: true,
+				fixRepoWebhooks:      true,
+				fixRepoDeployKeys:    true,
+				fixRepoLabels:        true,
+				logLevel:             "info",
+			},
+		},
+		{
+			name: "reject --drift with --confirm",
+			args: []string{"--config-path=foo", "--confirm", "--drift"},
+		},
+		{
+			name: "allow drift report",
+			args: []string{"--config-path=foo", "--drift", "--plan-markdown=plan.md"},
+			expected: &options{
+				config:        "foo",
+				minAdmins:     defaultMinAdmins,
+				requireSelf:   true,
+				maximumDelta:  defaultDelta,
+				tokensPerHour: defaultTokens,
+				tokenBurst:    defaultBurst,
+				logLevel:      "info",
+				plan:          plan.Options{MarkdownPath: "plan.md", Drift: true},
+			},
+		},
 		{
 			name: "allow disabled throttle",
 			args: []string{"--config-path=foo", "--tokens=0"},
@@ -2074,6 +2120,11 @@ type fakeDumpClient struct {
 	maintainers     map[int][]string
 	repoPermissions map[int][]github.Repo
 	repos           []github.FullRepo
+	collaborators   []github.User
+	invitations     []github.RepoInvitation
+	hooks           []github.Hook
+	deployKeys      []github.DeployKey
+	labels          []github.Label
 }
 
 func (c fakeDumpClient) GetOrg(name string) (*github.Organization, error) {
@@ -2178,6 +2229,52 @@ func (c fakeDumpClient) BotUser() (*github.UserData, error) {
 	return &github.UserData{Login: "admin"}, nil
 }
 
+func (c fakeDumpClient) ListDirectCollaborators(org, repo string) ([]github.User, error) {
+	return c.collaborators, nil
+}
+
+func (c fakeDumpClient) ListRepoInvitations(org, repo string) ([]github.RepoInvitation, error) {
+	return c.invitations, nil
+}
+
+func (c fakeDumpClient) ListRepoHooks(org, repo string) ([]github.Hook, error) {
+	return c.hooks, nil
+}
+
+func (c fakeDumpClient) ListDeployKeys(org, repo string) ([]github.DeployKey, error) {
+	return c.deployKeys, nil
+}
+
+func (c fakeDumpClient) GetRepoLabels(org, repo string) ([]github.Label, error) {
+	return c.labels, nil
+}
+
+func TestDumpRepoAccess(t *testing.T) {
+	json := "json"
+	yes := true
+	bug := "Something is broken"
+	fc := fakeDumpClient{
+		collaborators: []github.User{{Login: "outsider", Permissions: github.RepoPermissions{Pull: true, Push: true}}},
+		invitations:   []github.RepoInvitation{{ID: 1, Invitee: github.TeamMember{Login: "newcomer"}, Permissions: "read"}},
+		hooks:         []github.Hook{{ID: 2, Events: []string{"push"}, Active: true, Config: github.HookConfig{URL: "https://hook.example.com", ContentType: &json}}},
+		deployKeys:    []github.DeployKey{{ID: 3, Title: "deploy", Key: "ssh-ed25519 AAAA", ReadOnly: true}},
+		labels:        []github.Label{{Name: "bug", Color: "ee0701", Description: bug}, {Name: "docs", Color: "0000ff"}},
+	}
+	var actual org.Repo
+	if err := dumpRepoAccess(fc, "org", "repo", &actual); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := org.Repo{
+		Collaborators: map[string]github.RepoPermissionLevel{"outsider": github.Write, "newcomer": github.Read},
+		Webhooks:      map[string]org.Webhook{"https://hook.example.com": {Events: []string{"push"}, ContentType: &json, Active: &yes}},
+		DeployKeys:    map[string]org.DeployKey{"deploy": {Key: "ssh-ed25519 AAAA", ReadOnly: true}},
+		Labels:        map[string]org.Label{"bug": {Color: "ee0701", Description: &bug}, "docs": {Color: "0000ff"}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("dumped repo differs from expected: %s", diff)
+	}
+}
+
 func fixup(ret *org.Config) {
 	if ret == nil {
 		return
@@ -3107,3 +3204,430 @@ func TestNewRepoUpdateRequest(t *testing.T) {
 		})
 	}
 }
+
+type fakeRepoAccessClient struct {
+	repos         []github.Repo
+	collaborators map[string]github.RepoPermissionLevel
+	invitations   []github.RepoInvitation
+	hooks         []github.Hook
+	deployKeys    []github.DeployKey
+	labels        []github.Label
+	nextID        int
+	// calls records mutations as "verb repo/subject".
+	calls []string
+}
+
+func (c *fakeRepoAccessClient) record(format string, args ...interface{}) {
+	c.calls = append(c.calls, fmt.Sprintf(format, args...))
+}
+
+func (c *fakeRepoAccessClient) GetRepos(orgName string, isUser bool) ([]github.Repo, error) {
+	return c.repos, nil
+}
+
+func (c *fakeRepoAccessClient) ListDirectCollaborators(org, repo string) ([]github.User, error) {
+	var users []github.User
+	for login, level := range c.collaborators {
+		var permissions github.RepoPermissions
+		switch level {
+		case github.Admin:
+			permissions.Admin = true
+			fallthrough
+		case github.Write:
+			permissions.Push = true
+			fallthrough
+		case github.Read:
+			permissions.Pull = true
+		}
+		users = append(users, github.User{Login: login, Permissions: permissions})
+	}
+	return users, nil
+}
+
+func (c *fakeRepoAccessClient) ListRepoInvitations(org, repo string) ([]github.RepoInvitation, error) {
+	return c.invitations, nil
+}
+
+func (c *fakeRepoAccessClient) AddCollaborator(org, repo, user string, permission github.RepoPermissionLevel) error {
+	c.record("add-collaborator %s/%s=%s", repo, user, permission)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) RemoveCollaborator(org, repo, user string) error {
+	c.record("remove-collaborator %s/%s", repo, user)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) DeleteRepoInvitation(org, repo string, id int) error {
+	c.record("delete-invitation %s/%d", repo, id)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) ListRepoHooks(org, repo string) ([]github.Hook, error) {
+	return c.hooks, nil
+}
+
+func (c *fakeRepoAccessClient) CreateRepoHook(org, repo string, req github.HookRequest) (int, error) {
+	secret := ""
+	if req.Config.Secret != nil {
+		secret = *req.Config.Secret
+	}
+	c.record("create-hook %s/%s events=%v secret=%s", repo, req.Config.URL, req.Events, secret)
+	c.nextID++
+	return c.nextID, nil
+}
+
+func (c *fakeRepoAccessClient) EditRepoHook(org, repo string, id int, req github.HookRequest) error {
+	c.record("edit-hook %s/%d events=%v config=%t", repo, id, req.Events, req.Config != nil)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) DeleteRepoHook(org, repo string, id int, req github.HookRequest) error {
+	c.record("delete-hook %s/%d", repo, id)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) ListDeployKeys(org, repo string) ([]github.DeployKey, error) {
+	return c.deployKeys, nil
+}
+
+func (c *fakeRepoAccessClient) CreateDeployKey(org, repo string, key github.DeployKey) error {
+	c.record("create-key %s/%s read-only=%t", repo, key.Title, key.ReadOnly)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) DeleteDeployKey(org, repo string, id int) error {
+	c.record("delete-key %s/%d", repo, id)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) GetRepoLabels(org, repo string) ([]github.Label, error) {
+	return c.labels, nil
+}
+
+func (c *fakeRepoAccessClient) AddRepoLabel(org, repo, label, description, color string) error {
+	c.record("add-label %s/%s color=%s description=%s", repo, label, color, description)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) UpdateRepoLabel(org, repo, label, newName, description, color string) error {
+	c.record("update-label %s/%s name=%s color=%s description=%s", repo, label, newName, color, description)
+	return nil
+}
+
+func (c *fakeRepoAccessClient) DeleteRepoLabel(org, repo, label string) error {
+	c.record("delete-label %s/%s", repo, label)
+	return nil
+}
+
+func checkCalls(t *testing.T, client *fakeRepoAccessClient, expected []string) {
+	t.Helper()
+	sort.Strings(client.calls)
+	sort.Strings(expected)
+	if diff := cmp.Diff(expected, client.calls, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("calls differ from expected: %s", diff)
+	}
+}
+
+func TestConfigureRepoCollaborators(t *testing.T) {
+	testCases := []struct {
+		name          string
+		collaborators map[string]github.RepoPermissionLevel
+		invitations   []github.RepoInvitation
+		want          map[string]github.RepoPermissionLevel
+		maxDelta      float64
+		expected      []string
+		expectedErr   bool
+	}{
+		{
+			name:          "nothing to do",
+			collaborators: map[string]github.RepoPermissionLevel{"alice": github.Write},
+			want:          map[string]github.RepoPermissionLevel{"Alice": github.Write},
+			maxDelta:      1,
+		},
+		{
+			name:          "adds, updates and removes collaborators",
+			collaborators: map[string]github.RepoPermissionLevel{"alice": github.Read, "bob": github.Admin},
+			want:          map[string]github.RepoPermissionLevel{"alice": github.Write, "carol": github.Read},
+			maxDelta:      1,
+			expected: []string{
+				"add-collaborator repo/alice=write",
+				"add-collaborator repo/carol=read",
+				"remove-collaborator repo/bob",
+			},
+		},
+		{
+			name:        "waits for invitations and cancels undeclared ones",
+			invitations: []github.RepoInvitation{{ID: 1, Invitee: github.TeamMember{Login: "dave"}, Permissions: "write"}, {ID: 2, Invitee: github.TeamMember{Login: "eve"}, Permissions: "read"}},
+			want:        map[string]github.RepoPermissionLevel{"dave": github.Write},
+			maxDelta:    1,
+			expected:    []string{"delete-invitation repo/2"},
+		},
+		{
+			name:          "refuses to remove too many collaborators",
+			collaborators: map[string]github.RepoPermissionLevel{"alice": github.Read, "bob": github.Admin},
+			want:          map[string]github.RepoPermissionLevel{},
+			maxDelta:      0.25,
+			expectedErr:   true,
+		},
+		{
+			name:        "rejects none permission",
+			want:        map[string]github.RepoPermissionLevel{"alice": github.None},
+			maxDelta:    1,
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeRepoAccessClient{collaborators: tc.collaborators, invitations: tc.invitations}
+			err := configureRepoCollaborators(client, "org", "repo", tc.want, tc.maxDelta)
+			if err != nil != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			checkCalls(t, client, tc.expected)
+		})
+	}
+}
+
+func TestConfigureRepoWebhooks(t *testing.T) {
+	json := "json"
+	form := "form"
+	no := false
+	masked := "********"
+	secretFile := "/etc/webhook/hmac"
+	oldReadSecret := readSecret
+	defer func() { readSecret = oldReadSecret }()
+	readSecret = func(path string) (string, error) {
+		if path != secretFile {
+			return "", fmt.Errorf("unexpected secret file %s", path)
+		}
+		return "hunter2", nil
+	}
+
+	hook := func(id int, url string, events ...string) github.Hook {
+		return github.Hook{ID: id, Events: events, Active: true, Config: github.HookConfig{URL: url, ContentType: &json}}
+	}
+	testCases := []struct {
+		name        string
+		hooks       []github.Hook
+		want        map[string]org.Webhook
+		maxDelta    float64
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "nothing to do",
+			hooks:    []github.Hook{hook(1, "https://a", "push", "pull_request")},
+			want:     map[string]org.Webhook{"https://a": {Events: []string{"pull_request", "push"}, ContentType: &json}},
+			maxDelta: 1,
+		},
+		{
+			name:     "creates webhooks with their secret",
+			want:     map[string]org.Webhook{"https://a": {Events: []string{"*"}, SecretFile: &secretFile}},
+			maxDelta: 1,
+			expected: []string{"create-hook repo/https://a events=[*] secret=hunter2"},
+		},
+		{
+			name:  "updates events, activity and content type",
+			hooks: []github.Hook{hook(1, "https://a", "push"), hook(2, "https://b", "push")},
+			want: map[string]org.Webhook{
+				"https://a": {Events: []string{"issues"}},
+				"https://b": {ContentType: &form, Active: &no, SecretFile: &secretFile},
+			},
+			maxDelta: 1,
+			expected: []string{
+				"edit-hook repo/1 events=[issues] config=false",
+				"edit-hook repo/2 events=[] config=true",
+			},
+		},
+		{
+			name: "keeps the secret of webhooks without a secret file",
+			hooks: []github.Hook{
+				{ID: 1, Events: []string{"push"}, Active: true, Config: github.HookConfig{URL: "https://a", ContentType: &json, Secret: &masked}},
+				{ID: 2, Events: []string{"push"}, Active: true, Config: github.HookConfig{URL: "https://b", ContentType: &json}},
+			},
+			want: map[string]org.Webhook{
+				"https://a": {ContentType: &form, Active: &no},
+				"https://b": {ContentType: &form},
+			},
+			maxDelta: 1,
+			expected: []string{
+				"edit-hook repo/1 events=[] config=false",
+				"edit-hook repo/2 events=[] config=true",
+			},
+			expectedErr: true,
+		},
+		{
+			name:     "deletes undeclared and duplicated webhooks",
+			hooks:    []github.Hook{hook(1, "https://a", "push"), hook(2, "https://a", "push"), hook(3, "https://b", "push")},
+			want:     map[string]org.Webhook{"https://a": {}},
+			maxDelta: 1,
+			expected: []string{"delete-hook repo/2", "delete-hook repo/3"},
+		},
+		{
+			name:        "refuses to delete too many webhooks",
+			hooks:       []github.Hook{hook(1, "https://a", "push")},
+			want:        map[string]org.Webhook{},
+			maxDelta:    0.25,
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeRepoAccessClient{hooks: tc.hooks}
+			err := configureRepoWebhooks(client, "org", "repo", tc.want, tc.maxDelta)
+			if err != nil != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			checkCalls(t, client, tc.expected)
+		})
+	}
+}
+
+func TestConfigureDeployKeys(t *testing.T) {
+	testCases := []struct {
+		name        string
+		keys        []github.DeployKey
+		want        map[string]org.DeployKey
+		maxDelta    float64
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "keys are compared without their comment",
+			keys:     []github.DeployKey{{ID: 1, Title: "ci", Key: "ssh-ed25519 AAAA"}},
+			want:     map[string]org.DeployKey{"ci": {Key: "ssh-ed25519 AAAA ci@example.com"}},
+			maxDelta: 1,
+		},
+		{
+			name: "changed keys are replaced",
+			keys: []github.DeployKey{{ID: 1, Title: "ci", Key: "ssh-ed25519 AAAA"}, {ID: 2, Title: "docs", Key: "ssh-ed25519 BBBB"}},
+			want: map[string]org.DeployKey{
+				"ci":   {Key: "ssh-ed25519 CCCC"},
+				"docs": {Key: "ssh-ed25519 BBBB", ReadOnly: true},
+			},
+			maxDelta: 1,
+			expected: []string{
+				"delete-key repo/1",
+				"delete-key repo/2",
+				"create-key repo/ci read-only=false",
+				"create-key repo/docs read-only=true",
+			},
+		},
+		{
+			name:     "creates and deletes keys",
+			keys:     []github.DeployKey{{ID: 1, Title: "old", Key: "ssh-ed25519 AAAA"}},
+			want:     map[string]org.DeployKey{"new": {Key: "ssh-ed25519 BBBB", ReadOnly: true}},
+			maxDelta: 1,
+			expected: []string{"delete-key repo/1", "create-key repo/new read-only=true"},
+		},
+		{
+			name:        "refuses to delete too many keys",
+			keys:        []github.DeployKey{{ID: 1, Title: "old", Key: "ssh-ed25519 AAAA"}},
+			want:        map[string]org.DeployKey{},
+			maxDelta:    0.25,
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeRepoAccessClient{deployKeys: tc.keys}
+			err := configureDeployKeys(client, "org", "repo", tc.want, tc.maxDelta)
+			if err != nil != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			checkCalls(t, client, tc.expected)
+		})
+	}
+}
+
+func TestConfigureRepoLabels(t *testing.T) {
+	bug := "Something is broken"
+	testCases := []struct {
+		name        string
+		labels      []github.Label
+		want        map[string]org.Label
+		maxDelta    float64
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "nothing to do",
+			labels:   []github.Label{{Name: "bug", Color: "EE0701", Description: bug}},
+			want:     map[string]org.Label{"bug": {Color: "#ee0701"}},
+			maxDelta: 1,
+		},
+		{
+			name:   "creates, renames and updates labels",
+			labels: []github.Label{{Name: "bug", Color: "ee0701"}, {Name: "documentation", Color: "0000ff", Description: "Docs"}},
+			want: map[string]org.Label{
+				"bug":       {Color: "ff0000", Description: &bug},
+				"kind/docs": {Color: "0000ff", Previously: []string{"documentation"}},
+				"flake":     {Color: "cccccc"},
+			},
+			maxDelta: 1,
+			expected: []string{
+				"update-label repo/bug name=bug color=ff0000 description=Something is broken",
+				"update-label repo/documentation name=kind/docs color=0000ff description=Docs",
+				"add-label repo/flake color=cccccc description=",
+			},
+		},
+		{
+			name:     "deletes undeclared labels",
+			labels:   []github.Label{{Name: "bug", Color: "ee0701"}, {Name: "wontfix", Color: "ffffff"}},
+			want:     map[string]org.Label{"bug": {Color: "ee0701"}},
+			maxDelta: 1,
+			expected: []string{"delete-label repo/wontfix"},
+		},
+		{
+			name:        "refuses to delete too many labels",
+			labels:      []github.Label{{Name: "bug", Color: "ee0701"}, {Name: "wontfix", Color: "ffffff"}},
+			want:        map[string]org.Label{},
+			maxDelta:    0.25,
+			expectedErr: true,
+		},
+		{
+			name: "rejects duplicated names",
+			want: map[string]org.Label{
+				"bug":   {Color: "ee0701"},
+				"issue": {Color: "ee0701", Previously: []string{"Bug"}},
+			},
+			maxDelta:    1,
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeRepoAccessClient{labels: tc.labels}
+			err := configureRepoLabels(client, "org", "repo", tc.want, tc.maxDelta)
+			if err != nil != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			checkCalls(t, client, tc.expected)
+		})
+	}
+}
+
+func TestConfigureRepoAccess(t *testing.T) {
+	orgConfig := org.Config{Repos: map[string]org.Repo{
+		"renamed": {
+			Previously:    []string{"original"},
+			Collaborators: map[string]github.RepoPermissionLevel{"alice": github.Read},
+			Labels:        map[string]org.Label{"bug": {Color: "ee0701"}},
+		},
+		"archived":  {Collaborators: map[string]github.RepoPermissionLevel{"alice": github.Read}},
+		"missing":   {Collaborators: map[string]github.RepoPermissionLevel{"alice": github.Read}},
+		"unmanaged": {},
+	}}
+	client := &fakeRepoAccessClient{repos: []github.Repo{
+		{Name: "original"},
+		{Name: "archived", Archived: true},
+		{Name: "unmanaged"},
+	}}
+	opt := options{fixRepoCollaborators: true, maximumDelta: 1}
+	if err := configureRepoAccess(opt, client, "org", orgConfig); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Labels are not fixed, and only the existing repo that is not archived is configured.
+	checkCalls(t, client, []string{"add-collaborator original/alice=read"})
+}
@@ -0,0 +1,364 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/plan"
+)
+
+// planClient records the changes made through the client in a plan. It
+// remembers what it lists, to name the teams, webhooks, deploy keys and
+// invitations changed by ID and to tell additions from updates.
+type planClient struct {
+	github.Client
+	plan *plan.Plan
+
+	teams         map[int]string
+	orgMembers    map[string]sets.String
+	teamMembers   map[int]sets.String
+	collaborators map[string]sets.String
+	hooks         map[int]string
+	deployKeys    map[int]string
+	invitations   map[int]string
+}
+
+func newPlanClient(client github.Client, p *plan.Plan) *planClient {
+	return &planClient{
+		Client:        client,
+		plan:          p,
+		teams:         map[int]string{},
+		orgMembers:    map[string]sets.String{},
+		teamMembers:   map[int]sets.String{},
+		collaborators: map[string]sets.String{},
+		hooks:         map[int]string{},
+		deployKeys:    map[int]string{},
+		invitations:   map[int]string{},
+	}
+}
+
+func (c *planClient) teamName(id int) string {
+	if name, ok := c.teams[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", id)
+}
+
+func addOrUpdate(known sets.String, name string) plan.Action {
+	if known.Has(github.NormLogin(name)) {
+		return plan.Update
+	}
+	return plan.Add
+}
+
+func (c *planClient) EditOrg(name string, config github.Organization) (*github.Organization, error) {
+	current, err := c.Client.GetOrg(name)
+	if err != nil {
+		return nil, err
+	}
+	org, err := c.Client.EditOrg(name, config)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Update, Kind: "org", Org: name, Details: plan.Diff(current, config)})
+	}
+	return org, err
+}
+
+func (c *planClient) ListOrgMembers(org, role string) ([]github.TeamMember, error) {
+	members, err := c.Client.ListOrgMembers(org, role)
+	if c.orgMembers[org] == nil {
+		c.orgMembers[org] = sets.NewString()
+	}
+	for _, m := range members {
+		c.orgMembers[org].Insert(github.NormLogin(m.Login))
+	}
+	return members, err
+}
+
+func (c *planClient) UpdateOrgMembership(org, user string, admin bool) (*github.OrgMembership, error) {
+	membership, err := c.Client.UpdateOrgMembership(org, user, admin)
+	if err == nil {
+		role := github.RoleMember
+		if admin {
+			role = github.RoleAdmin
+		}
+		c.plan.Record(plan.Change{Action: addOrUpdate(c.orgMembers[org], user), Kind: "org member", Org: org, Name: user, Details: []string{"role: " + role}})
+	}
+	return membership, err
+}
+
+func (c *planClient) RemoveOrgMembership(org, user string) error {
+	err := c.Client.RemoveOrgMembership(org, user)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "org member", Org: org, Name: user})
+	}
+	return err
+}
+
+func (c *planClient) ListTeams(org string) ([]github.Team, error) {
+	teams, err := c.Client.ListTeams(org)
+	for _, t := range teams {
+		c.teams[t.ID] = t.Name
+	}
+	return teams, err
+}
+
+func (c *planClient) CreateTeam(org string, team github.Team) (*github.Team, error) {
+	created, err := c.Client.CreateTeam(org, team)
+	if err == nil {
+		if created != nil {
+			c.teams[created.ID] = created.Name
+		}
+		c.plan.Record(plan.Change{Action: plan.Add, Kind: "team", Org: org, Target: team.Name, Details: plan.Fields(github.Team{Description: team.Description, Privacy: team.Privacy})})
+	}
+	return created, err
+}
+
+func (c *planClient) EditTeam(org string, team github.Team) (*github.Team, error) {
+	edited, err := c.Client.EditTeam(org, team)
+	if err == nil {
+		details := plan.Fields(github.Team{Name: team.Name, Description: team.Description, Privacy: team.Privacy, ParentTeamID: team.ParentTeamID})
+		c.plan.Record(plan.Change{Action: plan.Update, Kind: "team", Org: org, Target: c.teamName(team.ID), Details: details})
+		c.teams[team.ID] = team.Name
+	}
+	return edited, err
+}
+
+func (c *planClient) DeleteTeam(org string, id int) error {
+	err := c.Client.DeleteTeam(org, id)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "team", Org: org, Target: c.teamName(id)})
+	}
+	return err
+}
+
+func (c *planClient) ListTeamMembers(org string, id int, role string) ([]github.TeamMember, error) {
+	members, err := c.Client.ListTeamMembers(org, id, role)
+	if c.teamMembers[id] == nil {
+		c.teamMembers[id] = sets.NewString()
+	}
+	for _, m := range members {
+		c.teamMembers[id].Insert(github.NormLogin(m.Login))
+	}
+	return members, err
+}
+
+func (c *planClient) UpdateTeamMembership(org string, id int, user string, maintainer bool) (*github.TeamMembership, error) {
+	membership, err := c.Client.UpdateTeamMembership(org, id, user, maintainer)
+	if err == nil {
+		role := github.RoleMember
+		if maintainer {
+			role = github.RoleMaintainer
+		}
+		c.plan.Record(plan.Change{Action: addOrUpdate(c.teamMembers[id], user), Kind: "team member", Org: org, Target: c.teamName(id), Name: user, Details: []string{"role: " + role}})
+	}
+	return membership, err
+}
+
+func (c *planClient) RemoveTeamMembership(org string, id int, user string) error {
+	err := c.Client.RemoveTeamMembership(org, id, user)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "team member", Org: org, Target: c.teamName(id), Name: user})
+	}
+	return err
+}
+
+func (c *planClient) UpdateTeamRepo(id int, org, repo string, permission github.TeamPermission) error {
+	err := c.Client.UpdateTeamRepo(id, org, repo, permission)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Update, Kind: "team repo", Org: org, Target: c.teamName(id), Name: repo, Details: []string{"permission: " + string(permission)}})
+	}
+	return err
+}
+
+func (c *planClient) RemoveTeamRepo(id int, org, repo string) error {
+	err := c.Client.RemoveTeamRepo(id, org, repo)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "team repo", Org: org, Target: c.teamName(id), Name: repo})
+	}
+	return err
+}
+
+func (c *planClient) CreateRepo(owner string, isUser bool, repo github.RepoCreateRequest) (*github.FullRepo, error) {
+	created, err := c.Client.CreateRepo(owner, isUser, repo)
+	if err == nil {
+		name := ""
+		if repo.Name != nil {
+			name = *repo.Name
+		}
+		c.plan.Record(plan.Change{Action: plan.Add, Kind: "repo", Org: owner, Target: name, Details: plan.Fields(repo)})
+	}
+	return created, err
+}
+
+func (c *planClient) UpdateRepo(owner, name string, repo github.RepoUpdateRequest) (*github.FullRepo, error) {
+	updated, err := c.Client.UpdateRepo(owner, name, repo)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Update, Kind: "repo", Org: owner, Target: name, Details: plan.Fields(repo)})
+	}
+	return updated, err
+}
+
+func (c *planClient) ListDirectCollaborators(org, repo string) ([]github.User, error) {
+	users, err := c.Client.ListDirectCollaborators(org, repo)
+	known := sets.NewString()
+	for _, u := range users {
+		known.Insert(github.NormLogin(u.Login))
+	}
+	c.collaborators[org+"/"+repo] = known
+	return users, err
+}
+
+func (c *planClient) ListRepoInvitations(org, repo string) ([]github.RepoInvitation, error) {
+	invitations, err := c.Client.ListRepoInvitations(org, repo)
+	for _, i := range invitations {
+		c.invitations[i.ID] = i.Invitee.Login
+	}
+	return invitations, err
+}
+
+func (c *planClient) AddCollaborator(org, repo, user string, permission github.RepoPermissionLevel) error {
+	err := c.Client.AddCollaborator(org, repo, user, permission)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: addOrUpdate(c.collaborators[org+"/"+repo], user), Kind: "collaborator", Org: org, Target: repo, Name: user, Details: []string{"permission: " + string(permission)}})
+	}
+	return err
+}
+
+func (c *planClient) RemoveCollaborator(org, repo, user string) error {
+	err := c.Client.RemoveCollaborator(org, repo, user)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "collaborator", Org: org, Target: repo, Name: user})
+	}
+	return err
+}
+
+func (c *planClient) DeleteRepoInvitation(org, repo string, id int) error {
+	err := c.Client.DeleteRepoInvitation(org, repo, id)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "collaborator invitation", Org: org, Target: repo, Name: c.invitations[id]})
+	}
+	return err
+}
+
+// hookDetails describes a webhook request, leaving out its secret.
+func hookDetails(req github.HookRequest) []string {
+	var details []string
+	if req.Events != nil {
+		events := append([]string{}, req.Events...)
+		sort.Strings(events)
+		details = append(details, fmt.Sprintf("events: %v", events))
+	}
+	if req.Active != nil {
+		details = append(details, fmt.Sprintf("active: %t", *req.Active))
+	}
+	if req.Config != nil && req.Config.ContentType != nil {
+		details = append(details, "content_type: "+*req.Config.ContentType)
+	}
+	if req.Config != nil && req.Config.Secret != nil {
+		details = append(details, "secret: (redacted)")
+	}
+	return details
+}
+
+func (c *planClient) ListRepoHooks(org, repo string) ([]github.Hook, error) {
+	hooks, err := c.Client.ListRepoHooks(org, repo)
+	for _, h := range hooks {
+		c.hooks[h.ID] = h.Config.URL
+	}
+	return hooks, err
+}
+
+func (c *planClient) CreateRepoHook(org, repo string, req github.HookRequest) (int, error) {
+	id, err := c.Client.CreateRepoHook(org, repo, req)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Add, Kind: "webhook", Org: org, Target: repo, Name: req.Config.URL, Details: hookDetails(req)})
+	}
+	return id, err
+}
+
+func (c *planClient) EditRepoHook(org, repo string, id int, req github.HookRequest) error {
+	err := c.Client.EditRepoHook(org, repo, id, req)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Update, Kind: "webhook", Org: org, Target: repo, Name: c.hooks[id], Details: hookDetails(req)})
+	}
+	return err
+}
+
+func (c *planClient) DeleteRepoHook(org, repo string, id int, req github.HookRequest) error {
+	err := c.Client.DeleteRepoHook(org, repo, id, req)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "webhook", Org: org, Target: repo, Name: c.hooks[id]})
+	}
+	return err
+}
+
+func (c *planClient) ListDeployKeys(org, repo string) ([]github.DeployKey, error) {
+	keys, err := c.Client.ListDeployKeys(org, repo)
+	for _, k := range keys {
+		c.deployKeys[k.ID] = k.Title
+	}
+	return keys, err
+}
+
+func (c *planClient) CreateDeployKey(org, repo string, key github.DeployKey) error {
+	err := c.Client.CreateDeployKey(org, repo, key)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Add, Kind: "deploy key", Org: org, Target: repo, Name: key.Title, Details: []string{fmt.Sprintf("read_only: %t", key.ReadOnly)}})
+	}
+	return err
+}
+
+func (c *planClient) DeleteDeployKey(org, repo string, id int) error {
+	err := c.Client.DeleteDeployKey(org, repo, id)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "deploy key", Org: org, Target: repo, Name: c.deployKeys[id]})
+	}
+	return err
+}
+
+func (c *planClient) AddRepoLabel(org, repo, label, description, color string) error {
+	err := c.Client.AddRepoLabel(org, repo, label, description, color)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Add, Kind: "label", Org: org, Target: repo, Name: label, Details: []string{"color: " + color, "description: " + description}})
+	}
+	return err
+}
+
+func (c *planClient) UpdateRepoLabel(org, repo, label, newName, description, color string) error {
+	err := c.Client.UpdateRepoLabel(org, repo, label, newName, description, color)
+	if err == nil {
+		details := []string{"color: " + color, "description: " + description}
+		if newName != label {
+			details = append([]string{fmt.Sprintf("name: %s → %s", label, newName)}, details...)
+		}
+		c.plan.Record(plan.Change{Action: plan.Update, Kind: "label", Org: org, Target: repo, Name: label, Details: details})
+	}
+	return err
+}
+
+func (c *planClient) DeleteRepoLabel(org, repo, label string) error {
+	err := c.Client.DeleteRepoLabel(org, repo, label)
+	if err == nil {
+		c.plan.Record(plan.Change{Action: plan.Remove, Kind: "label", Org: org, Target: repo, Name: label})
+	}
+	return err
+}
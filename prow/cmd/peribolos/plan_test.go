@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/plan"
+)
+
+// fakePlanGitHub implements the calls planClient wraps in TestPlanClient.
+type fakePlanGitHub struct {
+	github.Client
+}
+
+func (fakePlanGitHub) ListTeams(org string) ([]github.Team, error) {
+	return []github.Team{{ID: 1, Name: "team"}}, nil
+}
+
+func (fakePlanGitHub) ListTeamMembers(org string, id int, role string) ([]github.TeamMember, error) {
+	return []github.TeamMember{{Login: "Member"}}, nil
+}
+
+func (fakePlanGitHub) UpdateTeamMembership(org string, id int, user string, maintainer bool) (*github.TeamMembership, error) {
+	if user == "fail" {
+		return nil, errors.New("injected failure")
+	}
+	return &github.TeamMembership{}, nil
+}
+
+func (fakePlanGitHub) DeleteTeam(org string, id int) error {
+	return nil
+}
+
+func (fakePlanGitHub) ListRepoHooks(org, repo string) ([]github.Hook, error) {
+	return []github.Hook{{ID: 2, Config: github.HookConfig{URL: "https://hook.example.com"}}}, nil
+}
+
+func (fakePlanGitHub) EditRepoHook(org, repo string, id int, req github.HookRequest) error {
+	return nil
+}
+
+func TestPlanClient(t *testing.T) {
+	p := &plan.Plan{}
+	c := newPlanClient(fakePlanGitHub{}, p)
+
+	if _, err := c.ListTeams("org"); err != nil {
+		t.Fatalf("ListTeams: %v", err)
+	}
+	if _, err := c.ListTeamMembers("org", 1, github.RoleAll); err != nil {
+		t.Fatalf("ListTeamMembers: %v", err)
+	}
+	if _, err := c.UpdateTeamMembership("org", 1, "member", true); err != nil {
+		t.Fatalf("UpdateTeamMembership: %v", err)
+	}
+	if _, err := c.UpdateTeamMembership("org", 1, "new", false); err != nil {
+		t.Fatalf("UpdateTeamMembership: %v", err)
+	}
+	if _, err := c.UpdateTeamMembership("org", 1, "fail", false); err == nil {
+		t.Error("UpdateTeamMembership: expected an error")
+	}
+	if err := c.DeleteTeam("org", 1); err != nil {
+		t.Fatalf("DeleteTeam: %v", err)
+	}
+	if _, err := c.ListRepoHooks("org", "repo"); err != nil {
+		t.Fatalf("ListRepoHooks: %v", err)
+	}
+	secret := "hunter2"
+	active := true
+	if err := c.EditRepoHook("org", "repo", 2, github.HookRequest{Active: &active, Config: &github.HookConfig{Secret: &secret}}); err != nil {
+		t.Fatalf("EditRepoHook: %v", err)
+	}
+
+	expected := []plan.Change{
+		{Action: plan.Update, Kind: "webhook", Org: "org", Target: "repo", Name: "https://hook.example.com", Details: []string{"active: true", "secret: (redacted)"}},
+		{Action: plan.Remove, Kind: "team", Org: "org", Target: "team"},
+		{Action: plan.Update, Kind: "team member", Org: "org", Target: "team", Name: "member", Details: []string{"role: maintainer"}},
+		{Action: plan.Add, Kind: "team member", Org: "org", Target: "team", Name: "new", Details: []string{"role: member"}},
+	}
+	if diff := cmp.Diff(expected, p.Changes()); diff != "" {
+		t.Errorf("changes differ from expected (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Reaper deletes job logs and artifacts from blob storage once the
+// expiration recorded by gcsupload according to the retention policy
+// in the decoration config has passed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/interrupts"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/metrics"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+)
+
+type options struct {
+	runOnce                bool
+	configPath             string
+	jobConfigPath          string
+	dryRun                 bool
+	resyncPeriod           time.Duration
+	storage                flagutil.StorageClientOptions
+	instrumentationOptions flagutil.InstrumentationOptions
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	o := options{}
+	fs.BoolVar(&o.runOnce, "run-once", false, "If true, run only once then quit.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether or not to delete expired objects.")
+	fs.DurationVar(&o.resyncPeriod, "resync-period", time.Hour, "How often to walk the buckets for expired objects.")
+
+	o.storage.AddFlags(fs)
+	o.instrumentationOptions.AddFlags(fs)
+	fs.Parse(args)
+	return o
+}
+
+func (o *options) Validate() error {
+	if o.configPath == "" {
+		return errors.New("--config-path is required")
+	}
+	if o.resyncPeriod <= 0 {
+		return errors.New("--resync-period must be positive")
+	}
+	return nil
+}
+
+var reaperMetrics = struct {
+	objectsDeleted  *prometheus.CounterVec
+	deletionErrors  *prometheus.CounterVec
+	objectsExamined *prometheus.CounterVec
+}{
+	objectsDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reaper_objects_deleted",
+		Help: "Number of expired objects deleted from each bucket.",
+	}, []string{"bucket"}),
+	deletionErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reaper_deletion_errors",
+		Help: "Number of errors which occurred examining or deleting objects in each bucket.",
+	}, []string{"bucket"}),
+	objectsExamined: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reaper_objects_examined",
+		Help: "Number of objects examined in each bucket.",
+	}, []string{"bucket"}),
+}
+
+func init() {
+	prometheus.MustRegister(reaperMetrics.objectsDeleted)
+	prometheus.MustRegister(reaperMetrics.deletionErrors)
+	prometheus.MustRegister(reaperMetrics.objectsExamined)
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	pjutil.ServePProf(o.instrumentationOptions.PProfPort)
+
+	configAgent := &config.Agent{}
+	if err := configAgent.Start(o.configPath, o.jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
+	}
+	cfg := configAgent.Config
+
+	metrics.ExposeMetrics("reaper", cfg().PushGateway, o.instrumentationOptions.MetricsPort)
+
+	opener, err := o.storage.StorageClient(interrupts.Context())
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating storage client.")
+	}
+
+	r := reaper{
+		opener: opener,
+		config: cfg,
+		dryRun: o.dryRun,
+		now:    time.Now,
+	}
+	if o.runOnce {
+		r.reap(interrupts.Context())
+		return
+	}
+	interrupts.TickLiteral(func() {
+		r.reap(interrupts.Context())
+	}, o.resyncPeriod)
+}
+
+type reaper struct {
+	opener pkgio.Opener
+	config config.Getter
+	dryRun bool
+	now    func() time.Time
+}
+
+// reap walks every bucket jobs upload to and deletes expired objects.
+func (r *reaper) reap(ctx context.Context) {
+	start := time.Now()
+	for _, bucket := range storageBuckets(r.config()) {
+		log := logrus.WithField("bucket", bucket)
+		if err := r.reapBucket(ctx, bucket, log); err != nil {
+			log.WithError(err).Error("Failed to walk bucket")
+		}
+	}
+	logrus.WithField("duration", time.Since(start).String()).Info("Finished reaping expired objects")
+}
+
+// storageBuckets returns the normalized URLs of all buckets jobs upload to.
+func storageBuckets(cfg *config.Config) []string {
+	buckets := sets.NewString()
+	for _, bucket := range cfg.Deck.AllKnownStorageBuckets.List() {
+		if bucket == "" {
+			continue
+		}
+		parsed, err := prowapi.ParsePath(bucket)
+		if err != nil {
+			logrus.WithError(err).WithField("bucket", bucket).Warn("Skipping invalid bucket")
+			continue
+		}
+		buckets.Insert(fmt.Sprintf("%s://%s", parsed.StorageProvider(), parsed.Bucket()))
+	}
+	return buckets.List()
+}
+
+func (r *reaper) reapBucket(ctx context.Context, bucket string, log *logrus.Entry) error {
+	iterator, err := r.opener.Iterator(ctx, bucket, "")
+	if err != nil {
+		return err
+	}
+	now := r.now()
+	for {
+		attr, err := iterator.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if attr.IsDir || gcs.PreservedFromRetention(attr.Name) {
+			continue
+		}
+		reaperMetrics.objectsExamined.WithLabelValues(bucket).Inc()
+
+		path := fmt.Sprintf("%s/%s", bucket, attr.Name)
+		if err := r.reapObject(ctx, bucket, path, attr, now); err != nil {
+			log.WithError(err).WithField("path", path).Warn("Failed to reap object")
+			reaperMetrics.deletionErrors.WithLabelValues(bucket).Inc()
+		}
+	}
+}
+
+// reapObject deletes the object if it expired. The metadata listed along
+// with the object is used when the provider supports it, so that walking
+// a bucket doesn't need a request per object.
+func (r *reaper) reapObject(ctx context.Context, bucket, path string, attr pkgio.ObjectAttributes, now time.Time) error {
+	metadata := attr.Metadata
+	if !attr.MetadataListed {
+		attrs, err := r.opener.Attributes(ctx, path)
+		if err != nil {
+			if pkgio.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("get attributes: %w", err)
+		}
+		metadata = attrs.Metadata
+	}
+	expiration, ok := gcs.ExpirationFromMetadata(metadata)
+	if !ok || now.Before(expiration) {
+		return nil
+	}
+	log := logrus.WithFields(logrus.Fields{"path": path, "expiration": expiration})
+	if r.dryRun {
+		log.Info("Would delete expired object")
+		return nil
+	}
+	if err := r.opener.Delete(ctx, path); err != nil && !pkgio.IsNotExist(err) {
+		return fmt.Errorf("delete: %w", err)
+	}
+	log.Info("Deleted expired object")
+	reaperMetrics.objectsDeleted.WithLabelValues(bucket).Inc()
+	return nil
+}
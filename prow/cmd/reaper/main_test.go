@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+)
+
+func TestStorageBuckets(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Deck.AllKnownStorageBuckets = sets.NewString("", "kubernetes-jenkins", "gs://kubernetes-jenkins", "s3://prow-logs")
+	expected := []string{"gs://kubernetes-jenkins", "s3://prow-logs"}
+	if diff := cmp.Diff(expected, storageBuckets(cfg)); diff != "" {
+		t.Errorf("Unexpected buckets (-want +got):\n%s", diff)
+	}
+}
+
+func TestReapBucket(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	expired := map[string]string{gcs.ExpirationMetadataKey: now.Add(-time.Hour).Format(time.RFC3339)}
+	fresh := map[string]string{gcs.ExpirationMetadataKey: now.Add(time.Hour).Format(time.RFC3339)}
+	const prefix = "pr-logs/pull/org_repo/1/job/1/"
+
+	var testCases = []struct {
+		name      string
+		dryRun    bool
+		remaining []string
+	}{
+		{
+			name: "expired objects are deleted",
+			remaining: []string{
+				prefix + "artifacts/fresh.xml",
+				prefix + "build-log.txt",
+				prefix + "finished.json",
+				prefix + "started.json",
+				prefix + "unlabeled.txt",
+			},
+		},
+		{
+			name:   "nothing is deleted in dry run",
+			dryRun: true,
+			remaining: []string{
+				prefix + "artifacts/expired.xml",
+				prefix + "artifacts/fresh.xml",
+				prefix + "build-log.txt",
+				prefix + "clone-log.txt",
+				prefix + "finished.json",
+				prefix + "started.json",
+				prefix + "unlabeled.txt",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeGCSServer := fakestorage.NewServer([]fakestorage.Object{
+				{BucketName: "bucket", Name: prefix + "started.json", Metadata: expired},
+				{BucketName: "bucket", Name: prefix + "finished.json", Metadata: expired},
+				{BucketName: "bucket", Name: prefix + "build-log.txt", Metadata: expired},
+				{BucketName: "bucket", Name: prefix + "clone-log.txt", Metadata: expired},
+				{BucketName: "bucket", Name: prefix + "unlabeled.txt"},
+				{BucketName: "bucket", Name: prefix + "artifacts/expired.xml", Metadata: expired},
+				{BucketName: "bucket", Name: prefix + "artifacts/fresh.xml", Metadata: fresh},
+			})
+			defer fakeGCSServer.Stop()
+			opener := pkgio.NewGCSOpener(fakeGCSServer.Client())
+			counting := &attributesCountingOpener{Opener: opener}
+
+			r := reaper{
+				opener: counting,
+				dryRun: tc.dryRun,
+				now:    func() time.Time { return now },
+			}
+			if err := r.reapBucket(context.Background(), "gs://bucket", logrus.WithField("test", tc.name)); err != nil {
+				t.Fatalf("Failed to reap bucket: %v", err)
+			}
+			if counting.calls != 0 {
+				t.Errorf("Expected the listed metadata to be used, got %d attribute requests", counting.calls)
+			}
+
+			iterator, err := opener.Iterator(context.Background(), "gs://bucket", "")
+			if err != nil {
+				t.Fatalf("Failed to list bucket: %v", err)
+			}
+			var remaining []string
+			for {
+				attr, err := iterator.Next(context.Background())
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to list bucket: %v", err)
+				}
+				remaining = append(remaining, attr.Name)
+			}
+			if diff := cmp.Diff(tc.remaining, remaining); diff != "" {
+				t.Errorf("Unexpected remaining objects (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// attributesCountingOpener counts the requests for the attributes of single objects.
+type attributesCountingOpener struct {
+	pkgio.Opener
+	calls int
+}
+
+func (o *attributesCountingOpener) Attributes(ctx context.Context, path string) (pkgio.Attributes, error) {
+	o.calls++
+	return o.Opener.Attributes(ctx, path)
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
@@ -32,17 +33,27 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/gerrit/client"
 	"k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/logrusutil"
 	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/tide"
 )
 
+const (
+	githubProvider = "github"
+	gerritProvider = "gerrit"
+)
+
 type options struct {
 	port int
 
+	// provider is the code review host Tide merges changes on, github or gerrit.
+	provider string
+
 	configPath    string
 	jobConfigPath string
 
@@ -70,10 +81,29 @@ type options struct {
 	// a) the gcs credentials can write to this bucket
 	// b) the default acls do not expose any private info
 	statusURI string
+
+	// Gerrit only options.
+	cookiefilePath    string
+	tokenPathOverride string
+	gerritProjects    client.ProjectsFlag
 }
 
 func (o *options) Validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.kubernetes, &o.github, &o.storage} {
+	groups := []flagutil.OptionGroup{&o.kubernetes, &o.storage}
+	switch o.provider {
+	case githubProvider:
+		groups = append(groups, &o.github)
+	case gerritProvider:
+		if len(o.gerritProjects) == 0 {
+			return errors.New("--gerrit-projects must be set when --provider=gerrit")
+		}
+		if o.cookiefilePath != "" && o.tokenPathOverride != "" {
+			return fmt.Errorf("only one of --cookiefile=%q --token-path=%q allowed, not both", o.cookiefilePath, o.tokenPathOverride)
+		}
+	default:
+		return fmt.Errorf("--provider must be one of %q or %q, not %q", githubProvider, gerritProvider, o.provider)
+	}
+	for idx, group := range groups {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -82,8 +112,9 @@ func (o *options) Validate() error {
 }
 
 func gatherOptions(fs *flag.FlagSet, args ...string) options {
-	var o options
+	o := options{gerritProjects: client.ProjectsFlag{}}
 	fs.IntVar(&o.port, "port", 8888, "Port to listen on.")
+	fs.StringVar(&o.provider, "provider", githubProvider, "The code review host whose changes Tide merges, github or gerrit.")
 	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
 	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether to mutate any real-world state.")
@@ -96,6 +127,9 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.IntVar(&o.maxRecordsPerPool, "max-records-per-pool", 1000, "The maximum number of history records stored for an individual Tide pool.")
 	fs.StringVar(&o.historyURI, "history-uri", "", "The /local/path,gs://path/to/object or s3://path/to/object to store tide action history. GCS writes will use the default object ACL for the bucket")
 	fs.StringVar(&o.statusURI, "status-path", "", "The /local/path, gs://path/to/object or s3://path/to/object to store status controller state. GCS writes will use the default object ACL for the bucket.")
+	fs.StringVar(&o.cookiefilePath, "cookiefile", "", "Path to git http.cookiefile for gerrit, leave empty for anonymous.")
+	fs.StringVar(&o.tokenPathOverride, "token-path", "", "Force the use of the token in this path for gerrit, use with gcloud auth print-access-token.")
+	fs.Var(&o.gerritProjects, "gerrit-projects", "Set of gerrit repos to merge changes in on a host, example: --gerrit-projects=https://android.googlesource.com=platform/build,toolchain/llvm, repeat the flag for each host.")
 
 	fs.Parse(args)
 	return o
@@ -124,41 +158,6 @@ func main() {
 	}
 	cfg := configAgent.Config
 
-	secretAgent := &secret.Agent{}
-	var token string
-	if o.github.TokenPath != "" {
-		token = o.github.TokenPath
-	} else {
-		token = o.github.AppPrivateKeyPath
-	}
-	if err := secretAgent.Start([]string{token}); err != nil {
-		logrus.WithError(err).Fatal("Error starting secrets agent.")
-	}
-
-	githubSync, err := o.github.GitHubClientWithLogFields(secretAgent, o.dryRun, logrus.Fields{"controller": "sync"})
-	if err != nil {
-		logrus.WithError(err).Fatal("Error getting GitHub client for sync.")
-	}
-
-	githubStatus, err := o.github.GitHubClientWithLogFields(secretAgent, o.dryRun, logrus.Fields{"controller": "status-update"})
-	if err != nil {
-		logrus.WithError(err).Fatal("Error getting GitHub client for status.")
-	}
-
-	// The sync loop should be allowed more tokens than the status loop because
-	// it has to list all PRs in the pool every loop while the status loop only
-	// has to list changed PRs every loop.
-	// The sync loop should have a much lower burst allowance than the status
-	// loop which may need to update many statuses upon restarting Tide after
-	// changing the context format or starting Tide on a new repo.
-	githubSync.Throttle(o.syncThrottle, 3*tokensPerIteration(o.syncThrottle, cfg().Tide.SyncPeriod.Duration))
-	githubStatus.Throttle(o.statusThrottle, o.statusThrottle/2)
-
-	gitClient, err := o.github.GitClient(secretAgent, o.dryRun)
-	if err != nil {
-		logrus.WithError(err).Fatal("Error getting Git client.")
-	}
-
 	kubeCfg, err := o.kubernetes.InfrastructureClusterConfig(o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting kubeconfig.")
@@ -169,9 +168,13 @@ func main() {
 	if err != nil {
 		logrus.WithError(err).Fatal("Error constructing mgr.")
 	}
-	c, err := tide.NewController(githubSync, githubStatus, mgr, cfg, git.ClientFactoryFrom(gitClient), o.maxRecordsPerPool, opener, o.historyURI, o.statusURI, nil, o.github.AppPrivateKeyPath != "")
-	if err != nil {
-		logrus.WithError(err).Fatal("Error creating Tide controller.")
+	var c *tide.Controller
+	var gitClient git.ClientFactory
+	switch o.provider {
+	case gerritProvider:
+		c, gitClient = gerritController(o, mgr, cfg, opener)
+	default:
+		c, gitClient = githubController(o, mgr, cfg, opener)
 	}
 	interrupts.Run(func(ctx context.Context) {
 		if err := mgr.Start(ctx); err != nil {
@@ -225,6 +228,65 @@ func main() {
 	})
 }
 
+func githubController(o options, mgr manager.Manager, cfg config.Getter, opener io.Opener) (*tide.Controller, git.ClientFactory) {
+	secretAgent := &secret.Agent{}
+	var token string
+	if o.github.TokenPath != "" {
+		token = o.github.TokenPath
+	} else {
+		token = o.github.AppPrivateKeyPath
+	}
+	if err := secretAgent.Start([]string{token}); err != nil {
+		logrus.WithError(err).Fatal("Error starting secrets agent.")
+	}
+
+	githubSync, err := o.github.GitHubClientWithLogFields(secretAgent, o.dryRun, logrus.Fields{"controller": "sync"})
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting GitHub client for sync.")
+	}
+
+	githubStatus, err := o.github.GitHubClientWithLogFields(secretAgent, o.dryRun, logrus.Fields{"controller": "status-update"})
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting GitHub client for status.")
+	}
+
+	// The sync loop should be allowed more tokens than the status loop because
+	// it has to list all PRs in the pool every loop while the status loop only
+	// has to list changed PRs every loop.
+	// The sync loop should have a much lower burst allowance than the status
+	// loop which may need to update many statuses upon restarting Tide after
+	// changing the context format or starting Tide on a new repo.
+	githubSync.Throttle(o.syncThrottle, 3*tokensPerIteration(o.syncThrottle, cfg().Tide.SyncPeriod.Duration))
+	githubStatus.Throttle(o.statusThrottle, o.statusThrottle/2)
+
+	v1GitClient, err := o.github.GitClient(secretAgent, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting Git client.")
+	}
+
+	gitClient := git.ClientFactoryFrom(v1GitClient)
+	c, err := tide.NewController(githubSync, githubStatus, mgr, cfg, gitClient, o.maxRecordsPerPool, opener, o.historyURI, o.statusURI, nil, o.github.AppPrivateKeyPath != "")
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating Tide controller.")
+	}
+	return c, gitClient
+}
+
+func gerritController(o options, mgr manager.Manager, cfg config.Getter, opener io.Opener) (*tide.Controller, git.ClientFactory) {
+	gerritClient, err := client.NewClient(o.gerritProjects)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating gerrit client.")
+	}
+	gerritClient.Authenticate(o.cookiefilePath, o.tokenPathOverride)
+
+	gitClient := tide.NewGerritGitClientFactory()
+	c, err := tide.NewGerritController(mgr, cfg, gerritClient, gitClient, o.gerritProjects, o.dryRun, o.maxRecordsPerPool, opener, o.historyURI, nil)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating Tide controller.")
+	}
+	return c, gitClient
+}
+
 func sync(c *tide.Controller) {
 	if err := c.Sync(); err != nil {
 		logrus.WithError(err).Error("Error syncing.")
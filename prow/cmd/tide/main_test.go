@@ -24,6 +24,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/gerrit/client"
 )
 
 func Test_gatherOptions(t *testing.T) {
@@ -85,12 +86,41 @@ func Test_gatherOptions(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "gerrit provider does not need github flags",
+			args: map[string]string{
+				"--provider":          "gerrit",
+				"--gerrit-projects":   "https://gerrit.example.com=platform/build",
+				"--github-token-path": "",
+			},
+			expected: func(o *options) {
+				o.provider = "gerrit"
+				o.gerritProjects = client.ProjectsFlag{"https://gerrit.example.com": {"platform/build"}}
+				o.github.TokenPath = ""
+			},
+		},
+		{
+			name: "gerrit provider requires --gerrit-projects",
+			args: map[string]string{
+				"--provider": "gerrit",
+			},
+			err: true,
+		},
+		{
+			name: "unknown provider is rejected",
+			args: map[string]string{
+				"--provider": "gitlab",
+			},
+			err: true,
+		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			expected := &options{
 				port:              8888,
+				provider:          "github",
+				gerritProjects:    client.ProjectsFlag{},
 				configPath:        "yo",
 				jobConfigPath:     "",
 				dryRun:            true,
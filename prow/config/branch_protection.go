@@ -44,6 +44,10 @@ type Policy struct {
 	AllowForcePushes *bool `json:"allow_force_pushes,omitempty"`
 	// AllowDeletions allows deletion of the protected branch by anyone with write access to the repository.
 	AllowDeletions *bool `json:"allow_deletions,omitempty"`
+	// RequiredSignatures requires commits pushed to the branch to be signed.
+	RequiredSignatures *bool `json:"required_signatures,omitempty"`
+	// RequiredConversationResolution requires all conversations on code to be resolved before merging.
+	RequiredConversationResolution *bool `json:"required_conversation_resolution,omitempty"`
 	// Exclude specifies a set of regular expressions which identify branches
 	// that should be excluded from the protection policy
 	Exclude []string `json:"exclude,omitempty"`
@@ -51,7 +55,8 @@ type Policy struct {
 
 func (p Policy) defined() bool {
 	return p.Protect != nil || p.RequiredStatusChecks != nil || p.Admins != nil || p.Restrictions != nil || p.RequiredPullRequestReviews != nil ||
-		p.RequiredLinearHistory != nil || p.AllowForcePushes != nil || p.AllowDeletions != nil
+		p.RequiredLinearHistory != nil || p.AllowForcePushes != nil || p.AllowDeletions != nil ||
+		p.RequiredSignatures != nil || p.RequiredConversationResolution != nil
 }
 
 // ContextPolicy configures required github contexts.
@@ -85,6 +90,71 @@ type Restrictions struct {
 	Teams []string `json:"teams,omitempty"`
 }
 
+// Ruleset applies rules to the branches or tags of a repo whose names match
+// its patterns, instead of protecting branches one by one.
+type Ruleset struct {
+	// Target is what the ruleset applies to, branch (the default) or tag.
+	Target string `json:"target,omitempty"`
+	// Enforcement is active (the default), evaluate or disabled.
+	Enforcement string `json:"enforcement,omitempty"`
+	// Include lists glob patterns of the branch or tag names the ruleset applies to,
+	// like release-*. ~DEFAULT_BRANCH selects the default branch and ~ALL every name.
+	Include []string `json:"include"`
+	// Exclude lists glob patterns of names the ruleset does not apply to.
+	Exclude []string `json:"exclude,omitempty"`
+	// RestrictCreations only allows users bypassing the ruleset to create matching refs.
+	RestrictCreations bool `json:"restrict_creations,omitempty"`
+	// RestrictUpdates only allows users bypassing the ruleset to push to matching refs.
+	RestrictUpdates bool `json:"restrict_updates,omitempty"`
+	// RestrictDeletions only allows users bypassing the ruleset to delete matching refs.
+	RestrictDeletions bool `json:"restrict_deletions,omitempty"`
+	// BlockForcePushes prevents force pushes to matching refs.
+	BlockForcePushes bool `json:"block_force_pushes,omitempty"`
+	// RequiredLinearHistory prevents pushing merge commits to matching refs.
+	RequiredLinearHistory bool `json:"required_linear_history,omitempty"`
+	// RequiredSignatures requires commits pushed to matching refs to be signed.
+	RequiredSignatures bool `json:"required_signatures,omitempty"`
+	// RequiredPullRequestReviews requires changes to matching branches to be made through pull requests.
+	RequiredPullRequestReviews *RulesetReviewPolicy `json:"required_pull_request_reviews,omitempty"`
+	// RequiredStatusChecks requires these contexts to pass before merging into matching branches.
+	RequiredStatusChecks *ContextPolicy `json:"required_status_checks,omitempty"`
+	// RequiredDeployments requires deploying to these environments before merging into matching branches.
+	RequiredDeployments []string `json:"required_deployments,omitempty"`
+}
+
+// RulesetReviewPolicy specifies the pull request requirements of a ruleset.
+type RulesetReviewPolicy struct {
+	// Approvals is the number of approvals required.
+	Approvals int `json:"required_approving_review_count,omitempty"`
+	// DismissStale dismisses approvals when new commits are pushed.
+	DismissStale bool `json:"dismiss_stale_reviews,omitempty"`
+	// RequireOwners requires an approval from CODEOWNERS.
+	RequireOwners bool `json:"require_code_owner_reviews,omitempty"`
+	// RequireLastPushApproval requires the last push to be approved by someone else.
+	RequireLastPushApproval bool `json:"require_last_push_approval,omitempty"`
+	// RequiredConversationResolution requires all conversations on code to be resolved.
+	RequiredConversationResolution bool `json:"required_conversation_resolution,omitempty"`
+}
+
+// mergeRulesets returns the parent rulesets, replacing those the child
+// defines with the same name.
+func mergeRulesets(parent, child map[string]Ruleset) map[string]Ruleset {
+	if child == nil {
+		return parent
+	}
+	if parent == nil {
+		return child
+	}
+	merged := make(map[string]Ruleset, len(parent)+len(child))
+	for name, ruleset := range parent {
+		merged[name] = ruleset
+	}
+	for name, ruleset := range child {
+		merged[name] = ruleset
+	}
+	return merged
+}
+
 // selectInt returns the child if set, else parent
 func selectInt(parent, child *int) *int {
 	if child != nil {
@@ -158,15 +228,17 @@ func mergeRestrictions(parent, child *Restrictions) *Restrictions {
 // Apply returns a policy that merges the child into the parent
 func (p Policy) Apply(child Policy) Policy {
 	return Policy{
-		Protect:                    selectBool(p.Protect, child.Protect),
-		RequiredStatusChecks:       mergeContextPolicy(p.RequiredStatusChecks, child.RequiredStatusChecks),
-		Admins:                     selectBool(p.Admins, child.Admins),
-		RequiredLinearHistory:      selectBool(p.RequiredLinearHistory, child.RequiredLinearHistory),
-		AllowForcePushes:           selectBool(p.AllowForcePushes, child.AllowForcePushes),
-		AllowDeletions:             selectBool(p.AllowDeletions, child.AllowDeletions),
-		Restrictions:               mergeRestrictions(p.Restrictions, child.Restrictions),
-		RequiredPullRequestReviews: mergeReviewPolicy(p.RequiredPullRequestReviews, child.RequiredPullRequestReviews),
-		Exclude:                    unionStrings(p.Exclude, child.Exclude),
+		Protect:                        selectBool(p.Protect, child.Protect),
+		RequiredStatusChecks:           mergeContextPolicy(p.RequiredStatusChecks, child.RequiredStatusChecks),
+		Admins:                         selectBool(p.Admins, child.Admins),
+		RequiredLinearHistory:          selectBool(p.RequiredLinearHistory, child.RequiredLinearHistory),
+		AllowForcePushes:               selectBool(p.AllowForcePushes, child.AllowForcePushes),
+		AllowDeletions:                 selectBool(p.AllowDeletions, child.AllowDeletions),
+		RequiredSignatures:             selectBool(p.RequiredSignatures, child.RequiredSignatures),
+		RequiredConversationResolution: selectBool(p.RequiredConversationResolution, child.RequiredConversationResolution),
+		Restrictions:                   mergeRestrictions(p.Restrictions, child.Restrictions),
+		RequiredPullRequestReviews:     mergeReviewPolicy(p.RequiredPullRequestReviews, child.RequiredPullRequestReviews),
+		Exclude:                        unionStrings(p.Exclude, child.Exclude),
 	}
 }
 
@@ -202,6 +274,9 @@ func (bp BranchProtection) GetOrg(name string) *Org {
 type Org struct {
 	Policy `json:",inline"`
 	Repos  map[string]Repo `json:"repos,omitempty"`
+	// Rulesets are applied to every repo of the org which is configured.
+	// Repos override the rulesets with the same name.
+	Rulesets map[string]Ruleset `json:"rulesets,omitempty"`
 }
 
 // GetRepo returns the repo config after merging in any org policies.
@@ -212,6 +287,7 @@ func (o Org) GetRepo(name string) *Repo {
 	} else {
 		r.Policy = o.Policy
 	}
+	r.Rulesets = mergeRulesets(o.Rulesets, r.Rulesets)
 	return &r
 }
 
@@ -219,6 +295,9 @@ func (o Org) GetRepo(name string) *Repo {
 type Repo struct {
 	Policy   `json:",inline"`
 	Branches map[string]Branch `json:"branches,omitempty"`
+	// Rulesets are keyed by name. Rulesets of the repo which are not
+	// configured are deleted, unless neither the repo nor its org set any.
+	Rulesets map[string]Ruleset `json:"rulesets,omitempty"`
 }
 
 // GetBranch returns the branch config after merging in any repo policies.
@@ -554,6 +554,11 @@ func (p Plank) getJobURLPrefix(pj *prowapi.ProwJob) string {
 type Gerrit struct {
 	// TickInterval is how often we do a sync with binded gerrit instance
 	TickInterval *metav1.Duration `json:"tick_interval,omitempty"`
+	// EventsPollInterval is how often we sync with binded gerrit instances
+	// when the adapter also receives events, which trigger jobs right away.
+	// Syncing then only recovers changes for which an event was missed.
+	// Defaults to 10m.
+	EventsPollInterval *metav1.Duration `json:"events_poll_interval,omitempty"`
 	// RateLimit defines how many changes to query per gerrit API call
 	// default is 5
 	RateLimit int `json:"ratelimit,omitempty"`
@@ -1329,6 +1334,9 @@ func (c *Config) finalizeJobConfig() error {
 		}
 
 		for key, valCfg := range c.Plank.DefaultDecorationConfigs {
+			if len(valCfg.Steps) > 0 {
+				return fmt.Errorf("default_decoration_configs[%q]: steps must be set on each job", key)
+			}
 			if err := valCfg.ApplyDefault(def).Validate(); err != nil {
 				return fmt.Errorf("default_decoration_configs[%q]: validation error: %v", key, err)
 			}
@@ -1455,7 +1463,7 @@ func validateJobBase(v JobBase, jobType prowapi.ProwJobType, podNamespace string
 			return err
 		}
 	}
-	return nil
+	return validateSteps(v.Spec.Containers, v.DecorationConfig)
 }
 
 // validatePresubmits validates the presubmits for one repo
@@ -1637,6 +1645,10 @@ func parseProwConfig(c *Config) error {
 		c.Gerrit.TickInterval = &metav1.Duration{Duration: time.Minute}
 	}
 
+	if c.Gerrit.EventsPollInterval == nil {
+		c.Gerrit.EventsPollInterval = &metav1.Duration{Duration: 10 * time.Minute}
+	}
+
 	if c.Gerrit.RateLimit == 0 {
 		c.Gerrit.RateLimit = 5
 	}
@@ -1789,6 +1801,10 @@ func parseProwConfig(c *Config) error {
 	if c.Tide.MaxGoroutines == 0 {
 		c.Tide.MaxGoroutines = 20
 	}
+
+	if c.Tide.Gerrit.Query == "" {
+		c.Tide.Gerrit.Query = "status:open is:submittable is:mergeable -is:wip"
+	}
 	if c.Tide.MaxGoroutines <= 0 {
 		return fmt.Errorf("tide has invalid max_goroutines (%d), it needs to be a positive number", c.Tide.MaxGoroutines)
 	}
@@ -1991,6 +2007,28 @@ func validateDecoration(container v1.Container, config *prowapi.DecorationConfig
 	return nil
 }
 
+// validateSteps ensures that steps and test containers match up one to one.
+func validateSteps(containers []v1.Container, config *prowapi.DecorationConfig) error {
+	if config == nil || len(config.Steps) == 0 {
+		return nil
+	}
+	steps := sets.NewString()
+	for _, step := range config.Steps {
+		steps.Insert(step.Name)
+	}
+	names := sets.NewString()
+	for _, container := range containers {
+		names.Insert(container.Name)
+	}
+	if missing := names.Difference(steps); missing.Len() > 0 {
+		return fmt.Errorf("containers %v are not configured as steps", missing.List())
+	}
+	if unknown := steps.Difference(names); unknown.Len() > 0 {
+		return fmt.Errorf("steps %v do not match any container", unknown.List())
+	}
+	return nil
+}
+
 func resolvePresets(name string, labels map[string]string, spec *v1.PodSpec, presets []Preset) error {
 	for _, preset := range presets {
 		if spec != nil {
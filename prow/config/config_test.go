@@ -390,6 +390,41 @@ periodics:
   spec:
     containers:
     - image: golang:latest
+      args:
+      - "test"
+      - "./..."`,
+			expectError: true,
+		},
+		{
+			name: "with default steps",
+			rawConfig: `
+plank:
+  default_decoration_configs:
+    '*':
+      timeout: 2h
+      grace_period: 15s
+      utility_images:
+        clonerefs: "clonerefs:default"
+        initupload: "initupload:default"
+        entrypoint: "entrypoint:default"
+        sidecar: "sidecar:default"
+      gcs_configuration:
+        bucket: "default-bucket"
+        path_strategy: "legacy"
+        default_org: "kubernetes"
+        default_repo: "kubernetes"
+      gcs_credentials_secret: "default-service-account"
+      steps:
+      - name: test
+
+periodics:
+- name: kubernetes-defaulted-decoration
+  interval: 1h
+  decorate: true
+  spec:
+    containers:
+    - name: test
+      image: golang:latest
       args:
       - "test"
       - "./..."`,
@@ -1184,6 +1219,54 @@ func TestValidateDecoration(t *testing.T) {
 	}
 }
 
+func TestValidateSteps(t *testing.T) {
+	containers := []v1.Container{{Name: "build"}, {Name: "test"}}
+	cases := []struct {
+		name   string
+		config *prowapi.DecorationConfig
+		pass   bool
+	}{
+		{
+			name: "allow no decoration",
+			pass: true,
+		},
+		{
+			name:   "allow no steps",
+			config: &prowapi.DecorationConfig{},
+			pass:   true,
+		},
+		{
+			name: "happy case with every container as a step",
+			config: &prowapi.DecorationConfig{
+				Steps: []prowapi.Step{{Name: "build"}, {Name: "test", DependsOn: []string{"build"}}},
+			},
+			pass: true,
+		},
+		{
+			name: "reject container without a step",
+			config: &prowapi.DecorationConfig{
+				Steps: []prowapi.Step{{Name: "build"}},
+			},
+		},
+		{
+			name: "reject step without a container",
+			config: &prowapi.DecorationConfig{
+				Steps: []prowapi.Step{{Name: "build"}, {Name: "test"}, {Name: "publish"}},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			switch err := validateSteps(containers, tc.config); {
+			case err == nil && !tc.pass:
+				t.Error("validation failed to raise an error")
+			case err != nil && tc.pass:
+				t.Errorf("validation should have passed, got: %v", err)
+			}
+		})
+	}
+}
+
 func TestValidateLabels(t *testing.T) {
 	cases := []struct {
 		name   string
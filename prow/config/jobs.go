@@ -426,6 +426,12 @@ type UtilityConfig struct {
 	// SkipFetchHead tells prow to avoid a git fetch <remote> call.
 	// The git fetch <remote> <BaseRef> call occurs regardless.
 	SkipFetchHead bool `json:"skip_fetch_head,omitempty"`
+	// Filter is a git object filter, such as blob:none, that turns
+	// the clone into a partial clone.
+	Filter string `json:"filter,omitempty"`
+	// SparseCheckout lists the directories to check out, in cone mode.
+	// An empty list checks out the whole repository.
+	SparseCheckout []string `json:"sparse_checkout,omitempty"`
 
 	// ExtraRefs are auxiliary repositories that
 	// need to be cloned, determined from config
@@ -461,16 +467,33 @@ func (u *UtilityConfig) Validate() error {
 	if err := cloneURIValidate(u.CloneURI); err != nil {
 		return err
 	}
+	if err := validateSparseCheckout(u.SparseCheckout); err != nil {
+		return err
+	}
 
 	for i, ref := range u.ExtraRefs {
 		if err := cloneURIValidate(ref.CloneURI); err != nil {
 			return fmt.Errorf("extra_ref[%d]: %v", i, err)
 		}
+		if err := validateSparseCheckout(ref.SparseCheckout); err != nil {
+			return fmt.Errorf("extra_ref[%d]: %v", i, err)
+		}
 	}
 
 	return nil
 }
 
+// validateSparseCheckout ensures sparse checkout paths can be used in cone mode,
+// which only accepts directories relative to the root of the repository.
+func validateSparseCheckout(paths []string) error {
+	for _, p := range paths {
+		if p == "" || strings.HasPrefix(p, "/") || strings.HasPrefix(p, "!") || strings.ContainsAny(p, "*?[") {
+			return fmt.Errorf("sparse_checkout path %q must be a directory relative to the repository root", p)
+		}
+	}
+	return nil
+}
+
 // SetPresubmits updates c.PresubmitStatic to jobs, after compiling and validating their regexes.
 func (c *JobConfig) SetPresubmits(jobs map[string][]Presubmit) error {
 	nj := map[string][]Presubmit{}
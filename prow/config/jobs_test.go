@@ -1157,6 +1157,38 @@ func TestUtilityConfigValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			id: "sparse checkout of directories, no error",
+			uc: UtilityConfig{
+				SparseCheckout: []string{"cmd", "pkg/util"},
+				ExtraRefs: []prowapi.Refs{
+					{
+						Org:            "org1",
+						Repo:           "repo1",
+						SparseCheckout: []string{"docs"},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			id: "sparse checkout of absolute path, error",
+			uc: UtilityConfig{
+				SparseCheckout: []string{"/cmd"},
+			},
+		},
+		{
+			id: "sparse checkout of glob in extra ref, error",
+			uc: UtilityConfig{
+				ExtraRefs: []prowapi.Refs{
+					{
+						Org:            "org1",
+						Repo:           "repo1",
+						SparseCheckout: []string{"pkg/*.go"},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
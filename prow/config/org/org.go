@@ -72,6 +72,53 @@ type Repo struct {
 	Previously []string `json:"previously,omitempty"`
 
 	OnCreate *RepoCreateOptions `json:"on_create,omitempty"`
+
+	// The following are only managed when set, so an empty value removes
+	// every collaborator, webhook, deploy key or label of the repo while
+	// leaving them unset keeps the current ones.
+
+	// Collaborators maps users with direct access to the repo, such as
+	// outside collaborators, to their permission.
+	Collaborators map[string]github.RepoPermissionLevel `json:"collaborators,omitempty"`
+	// Webhooks maps the payload URL of each webhook to its settings.
+	Webhooks map[string]Webhook `json:"webhooks,omitempty"`
+	// DeployKeys maps the title of each deploy key to the key.
+	DeployKeys map[string]DeployKey `json:"deploy_keys,omitempty"`
+	// Labels maps the name of each label to its settings.
+	Labels map[string]Label `json:"labels,omitempty"`
+}
+
+// Webhook declares a repository webhook.
+//
+// See https://developer.github.com/v3/repos/hooks/#create-a-hook
+type Webhook struct {
+	Events      []string `json:"events,omitempty"`
+	ContentType *string  `json:"content_type,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	// SecretFile is the path of the file holding the secret of the webhook.
+	// GitHub does not return secrets, so it is only set when the webhook is
+	// created or its content type changes, rotating it is left to the hmac
+	// tool. The content type of a webhook with a secret is never changed
+	// without it, since that would remove the secret.
+	SecretFile *string `json:"secret_file,omitempty"`
+}
+
+// DeployKey declares an SSH key granting access to the repository.
+//
+// See https://developer.github.com/v3/repos/keys/#create-a-deploy-key
+type DeployKey struct {
+	Key      string `json:"key"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// Label declares a repository label.
+//
+// See https://developer.github.com/v3/issues/labels/#create-a-label
+type Label struct {
+	Color       string  `json:"color"`
+	Description *string `json:"description,omitempty"`
+
+	Previously []string `json:"previously,omitempty"`
 }
 
 // Config declares org metadata as well as its people and teams.
@@ -78,6 +78,14 @@ type TidePriority struct {
 	Labels []string `json:"labels,omitempty"`
 }
 
+// TideGerritConfig is config for the tide pool of Gerrit changes.
+type TideGerritConfig struct {
+	// Query is added to the query of each Gerrit project to select the
+	// changes that meet merge requirements.
+	// Defaults to "status:open is:submittable is:mergeable -is:wip".
+	Query string `json:"query,omitempty"`
+}
+
 // Tide is config for the tide pool.
 type Tide struct {
 	// SyncPeriod specifies how often Tide will sync jobs with GitHub. Defaults to 1m.
@@ -155,6 +163,10 @@ type Tide struct {
 	// Priority is an ordered list of labels that would be prioritized before other PRs
 	// PRs should match all labels contained in a list to be prioritized
 	Priority []TidePriority `json:"priority,omitempty"`
+
+	// Gerrit configures the pool of Gerrit changes, used when Tide runs
+	// against Gerrit instead of GitHub.
+	Gerrit TideGerritConfig `json:"gerrit,omitempty"`
 }
 
 func (t *Tide) BatchSizeLimit(repo OrgRepo) int {
@@ -19,11 +19,15 @@ package gerrit
 
 import (
 	"context"
+	"crypto/sha1"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/andygrunwald/go-gerrit"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -49,6 +53,9 @@ const (
 	lztm = "0"
 	// codeReview is the default gerrit code review label
 	codeReview = client.CodeReview
+
+	// checkerScheme prefixes the UUID of the checkers prow reports to.
+	checkerScheme = "prow"
 )
 
 var (
@@ -59,16 +66,34 @@ var (
 		v1.FailureState:   cross,
 		v1.AbortedState:   prohibited,
 	}
+
+	checkState = map[v1.ProwJobState]string{
+		v1.TriggeredState: client.CheckScheduled,
+		v1.PendingState:   client.CheckRunning,
+		v1.SuccessState:   client.CheckSuccessful,
+		v1.FailureState:   client.CheckFailed,
+		v1.ErrorState:     client.CheckFailed,
+		v1.AbortedState:   client.CheckNotRelevant,
+	}
 )
 
 type gerritClient interface {
 	SetReview(instance, id, revision, message string, labels map[string]string) error
+	SetCheck(instance, id, revision string, check client.CheckInput) error
+	EnsureChecker(instance string, checker client.CheckerInput) error
 }
 
 // Client is a gerrit reporter client
 type Client struct {
 	gc     gerritClient
 	lister ctrlruntimeclient.Reader
+	// checksInstances report each job as a check run rather than
+	// through review messages and votes.
+	checksInstances sets.String
+
+	// checkers holds the checkers known to exist, by instance.
+	checkers     map[string]sets.String
+	checkersLock sync.Mutex
 }
 
 // Job is the view of a prowjob scoped for a report
@@ -88,16 +113,19 @@ type JobReport struct {
 	Header  string
 }
 
-// NewReporter returns a reporter client
-func NewReporter(cookiefilePath string, projects map[string][]string, lister ctrlruntimeclient.Reader) (*Client, error) {
+// NewReporter returns a reporter client. Jobs on the checksInstances are
+// reported through the gerrit checks plugin.
+func NewReporter(cookiefilePath string, projects map[string][]string, checksInstances []string, lister ctrlruntimeclient.Reader) (*Client, error) {
 	gc, err := client.NewClient(projects)
 	if err != nil {
 		return nil, err
 	}
 	gc.Authenticate(cookiefilePath, "")
 	return &Client{
-		gc:     gc,
-		lister: lister,
+		gc:              gc,
+		lister:          lister,
+		checksInstances: sets.NewString(checksInstances...),
+		checkers:        map[string]sets.String{},
 	}, nil
 }
 
@@ -111,6 +139,15 @@ func (c *Client) ShouldReport(ctx context.Context, log *logrus.Entry, pj *v1.Pro
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	if c.checksInstances.Has(pj.ObjectMeta.Annotations[client.GerritInstance]) {
+		// every state change updates the check run of the job
+		if !isGerritJob(pj) {
+			log.Info("Not a gerrit job")
+			return false
+		}
+		return true
+	}
+
 	if pj.Status.State == v1.TriggeredState || pj.Status.State == v1.PendingState {
 		// not done yet
 		log.Info("PJ not finished")
@@ -123,10 +160,7 @@ func (c *Client) ShouldReport(ctx context.Context, log *logrus.Entry, pj *v1.Pro
 		return false
 	}
 
-	// has gerrit metadata (scheduled by gerrit adapter)
-	if pj.ObjectMeta.Annotations[client.GerritID] == "" ||
-		pj.ObjectMeta.Annotations[client.GerritInstance] == "" ||
-		pj.ObjectMeta.Labels[client.GerritRevision] == "" {
+	if !isGerritJob(pj) {
 		log.Info("Not a gerrit job")
 		return false
 	}
@@ -160,11 +194,27 @@ func (c *Client) ShouldReport(ctx context.Context, log *logrus.Entry, pj *v1.Pro
 	return true
 }
 
-// Report will send the current prowjob status as a gerrit review
+// isGerritJob determines whether the job has gerrit metadata,
+// as it does when scheduled by the gerrit adapter.
+func isGerritJob(pj *v1.ProwJob) bool {
+	return pj.ObjectMeta.Annotations[client.GerritID] != "" &&
+		pj.ObjectMeta.Annotations[client.GerritInstance] != "" &&
+		pj.ObjectMeta.Labels[client.GerritRevision] != ""
+}
+
+// Report will send the current prowjob status as a gerrit review,
+// or as a check run on instances using the checks plugin.
 func (c *Client) Report(ctx context.Context, logger *logrus.Entry, pj *v1.ProwJob) ([]*v1.ProwJob, *reconcile.Result, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	if c.checksInstances.Has(pj.ObjectMeta.Annotations[client.GerritInstance]) {
+		if err := c.reportCheck(logger, pj); err != nil {
+			return nil, nil, err
+		}
+		return []*v1.ProwJob{pj}, nil, nil
+	}
+
 	clientGerritRevision := client.GerritRevision
 	clientGerritID := client.GerritID
 	clientGerritInstance := client.GerritInstance
@@ -257,6 +307,82 @@ func (c *Client) Report(ctx context.Context, logger *logrus.Entry, pj *v1.ProwJo
 	return toReportJobs, nil, nil
 }
 
+// reportCheck publishes the job as a check run on its revision, creating
+// the checker of the job on first use.
+func (c *Client) reportCheck(logger *logrus.Entry, pj *v1.ProwJob) error {
+	instance := pj.ObjectMeta.Annotations[client.GerritInstance]
+	id := pj.ObjectMeta.Annotations[client.GerritID]
+	revision := pj.ObjectMeta.Labels[client.GerritRevision]
+
+	var project string
+	if pj.Spec.Refs != nil {
+		project = pj.Spec.Refs.Repo
+	}
+	checker := client.CheckerInput{
+		UUID:        checkerUUID(project, pj.Spec.Job),
+		Name:        pj.Spec.Job,
+		Description: fmt.Sprintf("Prow job %s", pj.Spec.Job),
+		Repository:  project,
+		Status:      "ENABLED",
+	}
+	if err := c.ensureChecker(instance, checker); err != nil {
+		return err
+	}
+
+	check := client.CheckInput{
+		CheckerUUID: checker.UUID,
+		State:       checkState[pj.Status.State],
+		Message:     pj.Status.Description,
+		URL:         pj.Status.URL,
+	}
+	if check.State == "" {
+		check.State = client.CheckFailed
+	}
+	if !pj.Status.StartTime.IsZero() {
+		check.Started = &gerrit.Timestamp{Time: pj.Status.StartTime.Time}
+	}
+	if pj.Status.CompletionTime != nil {
+		check.Finished = &gerrit.Timestamp{Time: pj.Status.CompletionTime.Time}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"checker": checker.UUID,
+		"state":   check.State,
+	}).Infof("Reporting check to instance %s on id %s", instance, id)
+	if err := c.gc.SetCheck(instance, id, revision, check); err != nil {
+		logger.WithError(err).Errorf("fail to set check on change ID %s", id)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) ensureChecker(instance string, checker client.CheckerInput) error {
+	c.checkersLock.Lock()
+	defer c.checkersLock.Unlock()
+	if c.checkers[instance].Has(checker.UUID) {
+		return nil
+	}
+	if err := c.gc.EnsureChecker(instance, checker); err != nil {
+		return err
+	}
+	if c.checkers == nil {
+		c.checkers = map[string]sets.String{}
+	}
+	if c.checkers[instance] == nil {
+		c.checkers[instance] = sets.NewString()
+	}
+	c.checkers[instance].Insert(checker.UUID)
+	return nil
+}
+
+// checkerUUID identifies the checker of a job in a project.
+//
+// Checkers are scoped to a single repository, while jobs may run
+// against several, so both go into the UUID.
+func checkerUUID(project, job string) string {
+	return fmt.Sprintf("%s:%x", checkerScheme, sha1.Sum([]byte(project+"/"+job)))
+}
+
 func statusIcon(state v1.ProwJobState) string {
 	icon, ok := stateIcon[state]
 	if !ok {
@@ -30,6 +30,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/diff"
+	"k8s.io/apimachinery/pkg/util/sets"
 	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
@@ -48,6 +49,24 @@ type fgc struct {
 	reportMessage string
 	reportLabel   map[string]string
 	instance      string
+	checks        []client.CheckInput
+	checkers      []client.CheckerInput
+}
+
+func (f *fgc) SetCheck(instance, id, revision string, check client.CheckInput) error {
+	if instance != f.instance {
+		return fmt.Errorf("wrong instance: %s", instance)
+	}
+	f.checks = append(f.checks, check)
+	return nil
+}
+
+func (f *fgc) EnsureChecker(instance string, checker client.CheckerInput) error {
+	if instance != f.instance {
+		return fmt.Errorf("wrong instance: %s", instance)
+	}
+	f.checkers = append(f.checkers, checker)
+	return nil
 }
 
 func (f *fgc) SetReview(instance, id, revision, message string, labels map[string]string) error {
@@ -1164,3 +1183,149 @@ func TestReportStability(t *testing.T) {
 		t.Errorf(diff.ObjectReflectDiff(&expected, actual))
 	}
 }
+
+func TestReportCheck(t *testing.T) {
+	started := metav1.NewTime(timeNow)
+	completed := metav1.NewTime(timeNow.Add(time.Minute))
+	newJob := func(name string, state v1.ProwJobState) *v1.ProwJob {
+		pj := &v1.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					client.GerritRevision:    "abc",
+					kube.ProwJobTypeLabel:    presubmit,
+					client.GerritReportLabel: "Code-Review",
+				},
+				Annotations: map[string]string{
+					client.GerritID:       "123-abc",
+					client.GerritInstance: "gerrit",
+				},
+			},
+			Spec: v1.ProwJobSpec{
+				Type: v1.PresubmitJob,
+				Job:  "ci-foo",
+				Refs: &v1.Refs{Repo: "platform/build"},
+			},
+			Status: v1.ProwJobStatus{
+				State:       state,
+				StartTime:   started,
+				URL:         "https://prow/view/gs/bucket/ci-foo/1",
+				Description: "Job " + string(state),
+			},
+		}
+		if state != v1.TriggeredState && state != v1.PendingState {
+			pj.Status.CompletionTime = &completed
+		}
+		return pj
+	}
+
+	var testcases = []struct {
+		name          string
+		pj            *v1.ProwJob
+		expectReport  bool
+		expectedState string
+	}{
+		{
+			name:          "triggered job is scheduled",
+			pj:            newJob("triggered", v1.TriggeredState),
+			expectReport:  true,
+			expectedState: client.CheckScheduled,
+		},
+		{
+			name:          "pending job is running",
+			pj:            newJob("pending", v1.PendingState),
+			expectReport:  true,
+			expectedState: client.CheckRunning,
+		},
+		{
+			name:          "successful job",
+			pj:            newJob("success", v1.SuccessState),
+			expectReport:  true,
+			expectedState: client.CheckSuccessful,
+		},
+		{
+			name:          "errored job failed",
+			pj:            newJob("error", v1.ErrorState),
+			expectReport:  true,
+			expectedState: client.CheckFailed,
+		},
+		{
+			name:          "aborted job is no longer relevant",
+			pj:            newJob("aborted", v1.AbortedState),
+			expectReport:  true,
+			expectedState: client.CheckNotRelevant,
+		},
+		{
+			name: "non gerrit job is not reported",
+			pj: func() *v1.ProwJob {
+				pj := newJob("other", v1.SuccessState)
+				delete(pj.ObjectMeta.Annotations, client.GerritID)
+				return pj
+			}(),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fgc := &fgc{instance: "gerrit"}
+			reporter := &Client{
+				gc:              fgc,
+				lister:          fakectrlruntimeclient.NewFakeClient(tc.pj),
+				checksInstances: sets.NewString("gerrit"),
+			}
+			logger := logrus.NewEntry(logrus.StandardLogger())
+			if shouldReport := reporter.ShouldReport(context.Background(), logger, tc.pj); shouldReport != tc.expectReport {
+				t.Fatalf("shouldReport: %v, expectReport: %v", shouldReport, tc.expectReport)
+			}
+			if !tc.expectReport {
+				return
+			}
+			reported, _, err := reporter.Report(context.Background(), logger, tc.pj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(reported) != 1 || reported[0] != tc.pj {
+				t.Errorf("expected to report only the job, got %v", reported)
+			}
+			if fgc.reportMessage != "" {
+				t.Errorf("expected no review, got %q", fgc.reportMessage)
+			}
+			if len(fgc.checks) != 1 {
+				t.Fatalf("expected a single check, got %v", fgc.checks)
+			}
+			check := fgc.checks[0]
+			if check.State != tc.expectedState {
+				t.Errorf("expected state %s, got %s", tc.expectedState, check.State)
+			}
+			if check.URL != tc.pj.Status.URL {
+				t.Errorf("expected url %s, got %s", tc.pj.Status.URL, check.URL)
+			}
+			if check.CheckerUUID != checkerUUID("platform/build", "ci-foo") || !strings.HasPrefix(check.CheckerUUID, "prow:") {
+				t.Errorf("unexpected checker %s", check.CheckerUUID)
+			}
+			if check.Started == nil || !check.Started.Time.Equal(timeNow) {
+				t.Errorf("expected to start at %v, got %v", timeNow, check.Started)
+			}
+			if finished := tc.pj.Status.CompletionTime != nil; finished != (check.Finished != nil) {
+				t.Errorf("expected finished %t, got %v", finished, check.Finished)
+			}
+
+			// the checker is only ensured once
+			if _, _, err := reporter.Report(context.Background(), logger, tc.pj); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(fgc.checkers) != 1 {
+				t.Errorf("expected the checker to be ensured once, got %v", fgc.checkers)
+			}
+		})
+	}
+}
+
+func TestCheckerUUID(t *testing.T) {
+	if checkerUUID("foo", "job") == checkerUUID("bar", "job") {
+		t.Error("checkers of a job in different projects must differ")
+	}
+	if checkerUUID("foo", "job") != checkerUUID("foo", "job") {
+		t.Error("checkers must be stable")
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/gerrit/client"
 	"k8s.io/test-infra/prow/github/report"
+	"k8s.io/test-infra/prow/gitlab"
 )
 
 const (
@@ -124,6 +125,8 @@ func (c *Client) ShouldReport(_ context.Context, _ *logrus.Entry, pj *v1.ProwJob
 	switch {
 	case pj.Labels[client.GerritReportLabel] != "":
 		return false // TODO(fejta): opt-in to github reporting
+	case pj.Annotations[gitlab.ProjectAnnotation] != "":
+		return false // Reported by the gitlab reporter
 	case pj.Spec.Type != v1.PresubmitJob && pj.Spec.Type != v1.PostsubmitJob:
 		return false // Report presubmit and postsubmit github jobs for github reporter
 	case c.reportAgent != "" && pj.Spec.Agent != c.reportAgent:
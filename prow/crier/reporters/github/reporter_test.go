@@ -29,6 +29,7 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/gerrit/client"
 	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/gitlab"
 )
 
 func TestShouldReport(t *testing.T) {
@@ -58,6 +59,19 @@ func TestShouldReport(t *testing.T) {
 			},
 			report: true,
 		},
+		{
+			name: "should not report gitlab job",
+			pj: v1.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{gitlab.ProjectAnnotation: "group/project"},
+				},
+				Spec: v1.ProwJobSpec{
+					Type:   v1.PresubmitJob,
+					Report: true,
+				},
+			},
+			report: false,
+		},
 		{
 			name: "should not report batch job",
 			pj: v1.ProwJob{
@@ -0,0 +1,223 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gitlab reports prowjobs to GitLab as commit statuses, summarizing
+// the failed presubmits of a merge request in a note.
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/gitlab"
+)
+
+const (
+	// GitLabReporterName is the name for gitlab reporter
+	GitLabReporterName = "gitlab-reporter"
+
+	// noteMarker identifies the note summarizing failed jobs.
+	noteMarker = "<!-- prow-gitlab-report -->"
+
+	// maxDescriptionLength is the longest description GitLab accepts.
+	maxDescriptionLength = 255
+)
+
+type gitlabClient interface {
+	BotUser() (*gitlab.User, error)
+	ListCommitStatuses(project, sha string) ([]gitlab.CommitStatus, error)
+	SetCommitStatus(project, sha string, status gitlab.CommitStatus) error
+	ListMergeRequestNotes(project string, iid int) ([]gitlab.Note, error)
+	CreateMergeRequestNote(project string, iid int, body string) error
+	EditMergeRequestNote(project string, iid, noteID int, body string) error
+	DeleteMergeRequestNote(project string, iid, noteID int) error
+	Instance() string
+}
+
+// Client is a gitlab reporter client
+type Client struct {
+	gc     gitlabClient
+	config config.Getter
+	// noteLock serializes updates to the notes of merge requests, which
+	// summarize the results of every job.
+	noteLock sync.Mutex
+}
+
+// NewReporter returns a reporter client
+func NewReporter(gc gitlabClient, cfg config.Getter) *Client {
+	return &Client{gc: gc, config: cfg}
+}
+
+// GetName returns the name of the reporter
+func (c *Client) GetName() string {
+	return GitLabReporterName
+}
+
+// ShouldReport returns if this prowjob was triggered for the GitLab instance of the reporter
+func (c *Client) ShouldReport(_ context.Context, _ *logrus.Entry, pj *v1.ProwJob) bool {
+	switch {
+	case pj.Annotations[gitlab.ProjectAnnotation] == "":
+		return false
+	case pj.Annotations[gitlab.InstanceAnnotation] != c.gc.Instance():
+		return false
+	case pj.Spec.Type != v1.PresubmitJob && pj.Spec.Type != v1.PostsubmitJob:
+		return false
+	case pj.Spec.Refs == nil:
+		return false
+	}
+	return pj.Spec.Report
+}
+
+// Report sets the commit status of the job, then updates the note listing
+// the failed jobs of its merge request once a presubmit completes.
+func (c *Client) Report(_ context.Context, log *logrus.Entry, pj *v1.ProwJob) ([]*v1.ProwJob, *reconcile.Result, error) {
+	project := pj.Annotations[gitlab.ProjectAnnotation]
+	refs := pj.Spec.Refs
+	sha, ref := refs.BaseSHA, refs.BaseRef
+	if pj.Spec.Type == v1.PresubmitJob {
+		if len(refs.Pulls) != 1 {
+			return nil, nil, fmt.Errorf("presubmit has %d pulls, not one", len(refs.Pulls))
+		}
+		sha, ref = refs.Pulls[0].SHA, ""
+	}
+
+	status := gitlab.CommitStatus{
+		Name:        contextName(pj),
+		Status:      stateToStatus(pj.Status.State),
+		Ref:         ref,
+		TargetURL:   pj.Status.URL,
+		Description: truncate(pj.Status.Description, maxDescriptionLength),
+	}
+	if err := c.gc.SetCommitStatus(project, sha, status); err != nil {
+		return nil, nil, fmt.Errorf("set status %s of %s: %w", status.Name, sha, err)
+	}
+
+	if pj.Spec.Type == v1.PresubmitJob && pj.Complete() {
+		c.noteLock.Lock()
+		defer c.noteLock.Unlock()
+		if err := c.updateNote(project, refs.Pulls[0].Number, sha); err != nil {
+			return nil, nil, fmt.Errorf("update note: %w", err)
+		}
+	}
+	log.WithField("status", status.Status).Debug("Reported to GitLab.")
+	return []*v1.ProwJob{pj}, nil, nil
+}
+
+// updateNote creates, edits or deletes the note listing the failed jobs of
+// the merge request at its tested commit.
+func (c *Client) updateNote(project string, iid int, sha string) error {
+	bot, err := c.gc.BotUser()
+	if err != nil {
+		return err
+	}
+	statuses, err := c.gc.ListCommitStatuses(project, sha)
+	if err != nil {
+		return err
+	}
+	var failed []gitlab.CommitStatus
+	for _, status := range statuses {
+		if status.Author.ID == bot.ID && (status.Status == gitlab.StatusFailed || status.Status == gitlab.StatusCanceled) {
+			failed = append(failed, status)
+		}
+	}
+
+	notes, err := c.gc.ListMergeRequestNotes(project, iid)
+	if err != nil {
+		return err
+	}
+	existing := -1
+	for _, note := range notes {
+		if note.Author.ID == bot.ID && strings.Contains(note.Body, noteMarker) {
+			existing = note.ID
+		}
+	}
+
+	switch {
+	case len(failed) == 0 && existing < 0:
+		return nil
+	case len(failed) == 0:
+		return c.gc.DeleteMergeRequestNote(project, iid, existing)
+	}
+	body := c.noteBody(project, sha, failed)
+	if existing < 0 {
+		return c.gc.CreateMergeRequestNote(project, iid, body)
+	}
+	return c.gc.EditMergeRequestNote(project, iid, existing, body)
+}
+
+func (c *Client) noteBody(project, sha string, failed []gitlab.CommitStatus) string {
+	rerunCommands := map[string]string{}
+	for _, presubmit := range c.config().PresubmitsStatic[project] {
+		rerunCommands[presubmit.Context] = presubmit.RerunCommand
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Name < failed[j].Name })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The following jobs failed on commit %s:\n\n", sha)
+	b.WriteString("| Job | Result | Details | Rerun command |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, status := range failed {
+		details := ""
+		if status.TargetURL != "" {
+			details = fmt.Sprintf("[link](%s)", status.TargetURL)
+		}
+		rerun := ""
+		if cmd := rerunCommands[status.Name]; cmd != "" {
+			rerun = "`" + cmd + "`"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", status.Name, status.Status, details, rerun)
+	}
+	b.WriteString("\nComment `/retest` to rerun all failed jobs.\n\n")
+	b.WriteString(noteMarker)
+	return b.String()
+}
+
+func contextName(pj *v1.ProwJob) string {
+	if pj.Spec.Context != "" {
+		return pj.Spec.Context
+	}
+	return pj.Spec.Job
+}
+
+func stateToStatus(state v1.ProwJobState) string {
+	switch state {
+	case v1.PendingState:
+		return gitlab.StatusRunning
+	case v1.SuccessState:
+		return gitlab.StatusSuccess
+	case v1.FailureState, v1.ErrorState:
+		return gitlab.StatusFailed
+	case v1.AbortedState:
+		return gitlab.StatusCanceled
+	default:
+		return gitlab.StatusPending
+	}
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/gitlab"
+	"k8s.io/test-infra/prow/gitlab/fakegitlab"
+)
+
+const project = "group/project"
+
+func newReporter(t *testing.T) (*Client, *fakegitlab.Server, string) {
+	fake := fakegitlab.NewServer()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	gc, err := gitlab.NewClient(server.URL, nil, false)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	cfg := &config.Config{JobConfig: config.JobConfig{PresubmitsStatic: map[string][]config.Presubmit{
+		project: {{JobBase: config.JobBase{Name: "unit"}, Reporter: config.Reporter{Context: "unit"}, RerunCommand: "/test unit"}},
+	}}}
+	return NewReporter(gc, func() *config.Config { return cfg }), fake, gc.Instance()
+}
+
+func presubmit(instance, context string, state v1.ProwJobState) *v1.ProwJob {
+	pj := &v1.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: context,
+			Annotations: map[string]string{
+				gitlab.ProjectAnnotation:  project,
+				gitlab.InstanceAnnotation: instance,
+			},
+		},
+		Spec: v1.ProwJobSpec{
+			Type:    v1.PresubmitJob,
+			Job:     context,
+			Context: context,
+			Report:  true,
+			Refs: &v1.Refs{
+				Org:     "group",
+				Repo:    "project",
+				BaseRef: "main",
+				BaseSHA: "base",
+				Pulls:   []v1.Pull{{Number: 3, SHA: "head"}},
+			},
+		},
+		Status: v1.ProwJobStatus{State: state, URL: "https://prow/" + context, Description: "Job " + string(state)},
+	}
+	if state != v1.PendingState && state != v1.TriggeredState {
+		now := metav1.Now()
+		pj.Status.CompletionTime = &now
+	}
+	return pj
+}
+
+func TestShouldReport(t *testing.T) {
+	c, _, instance := newReporter(t)
+	testCases := []struct {
+		name     string
+		mutate   func(*v1.ProwJob)
+		expected bool
+	}{
+		{
+			name:     "gitlab presubmit is reported",
+			mutate:   func(*v1.ProwJob) {},
+			expected: true,
+		},
+		{
+			name:   "github presubmit is not reported",
+			mutate: func(pj *v1.ProwJob) { pj.Annotations = nil },
+		},
+		{
+			name:   "presubmit of another instance is not reported",
+			mutate: func(pj *v1.ProwJob) { pj.Annotations[gitlab.InstanceAnnotation] = "https://other.example.com" },
+		},
+		{
+			name:   "silent presubmit is not reported",
+			mutate: func(pj *v1.ProwJob) { pj.Spec.Report = false },
+		},
+		{
+			name:   "periodic is not reported",
+			mutate: func(pj *v1.ProwJob) { pj.Spec.Type = v1.PeriodicJob },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pj := presubmit(instance, "unit", v1.PendingState)
+			tc.mutate(pj)
+			if got := c.ShouldReport(context.Background(), logrus.NewEntry(logrus.New()), pj); got != tc.expected {
+				t.Errorf("ShouldReport() = %t, want %t", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	c, fake, instance := newReporter(t)
+	log := logrus.NewEntry(logrus.New())
+	report := func(pj *v1.ProwJob) {
+		t.Helper()
+		if _, _, err := c.Report(context.Background(), log, pj); err != nil {
+			t.Fatalf("Report: %v", err)
+		}
+	}
+	status := func(name string) string {
+		for _, s := range fake.Statuses[project]["head"] {
+			if s.Name == name {
+				return s.Status
+			}
+		}
+		return ""
+	}
+
+	report(presubmit(instance, "unit", v1.PendingState))
+	if got := status("unit"); got != gitlab.StatusRunning {
+		t.Errorf("pending job has status %q", got)
+	}
+	if notes := fake.NoteBodies(project, 3); len(notes) != 0 {
+		t.Errorf("pending job created notes: %v", notes)
+	}
+
+	report(presubmit(instance, "unit", v1.FailureState))
+	report(presubmit(instance, "lint", v1.FailureState))
+	if got := status("unit"); got != gitlab.StatusFailed {
+		t.Errorf("failed job has status %q", got)
+	}
+	notes := fake.NoteBodies(project, 3)
+	if len(notes) != 1 {
+		t.Fatalf("expected one note, got %v", notes)
+	}
+	for _, want := range []string{"| lint | failed | [link](https://prow/lint) |  |", "| unit | failed | [link](https://prow/unit) | `/test unit` |", noteMarker} {
+		if !strings.Contains(notes[0], want) {
+			t.Errorf("note lacks %q:\n%s", want, notes[0])
+		}
+	}
+
+	report(presubmit(instance, "lint", v1.SuccessState))
+	notes = fake.NoteBodies(project, 3)
+	if len(notes) != 1 || strings.Contains(notes[0], "lint") || !strings.Contains(notes[0], "unit") {
+		t.Errorf("expected the note to only list unit, got %v", notes)
+	}
+
+	report(presubmit(instance, "unit", v1.SuccessState))
+	if notes := fake.NoteBodies(project, 3); len(notes) != 0 {
+		t.Errorf("expected the note to be deleted once all jobs pass, got %v", notes)
+	}
+}
+
+func TestReportPostsubmit(t *testing.T) {
+	c, fake, instance := newReporter(t)
+	pj := presubmit(instance, "publish", v1.SuccessState)
+	pj.Spec.Type = v1.PostsubmitJob
+	pj.Spec.Refs.Pulls = nil
+	if _, _, err := c.Report(context.Background(), logrus.NewEntry(logrus.New()), pj); err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	statuses := fake.Statuses[project]["base"]
+	if len(statuses) != 1 || statuses[0].Status != gitlab.StatusSuccess || statuses[0].Ref != "main" {
+		t.Errorf("unexpected statuses %#v", statuses)
+	}
+}
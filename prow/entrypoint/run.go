@@ -89,6 +89,14 @@ func (o Options) Run() int {
 	return code
 }
 
+func (o Options) previousMarkers() []string {
+	var markers []string
+	if o.PreviousMarker != "" {
+		markers = append(markers, o.PreviousMarker)
+	}
+	return append(markers, o.PreviousMarkers...)
+}
+
 // ExecuteProcess creates the artifact directory then executes the process as
 // configured, writing the output to the process log.
 func (o Options) ExecuteProcess() (int, error) {
@@ -112,7 +120,7 @@ func (o Options) ExecuteProcess() (int, error) {
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 
-	if o.PreviousMarker != "" {
+	if previousMarkers := o.previousMarkers(); len(previousMarkers) > 0 {
 		ctx, cancel := context.WithCancel(context.Background())
 		go func() {
 			select {
@@ -122,15 +130,18 @@ func (o Options) ExecuteProcess() (int, error) {
 			case <-ctx.Done():
 			}
 		}()
-		prevMarkerResult := wrapper.WaitForMarkers(ctx, o.PreviousMarker)[o.PreviousMarker]
-		code, err := prevMarkerResult.ReturnCode, prevMarkerResult.Err
+		prevMarkerResults := wrapper.WaitForMarkers(ctx, previousMarkers...)
 		cancel() // end previous go-routine when not interrupted
-		if err != nil {
-			return InternalErrorCode, fmt.Errorf("wait for previous marker %s: %v", o.PreviousMarker, err)
-		}
-		if code != 0 {
-			logrus.Infof("Skipping as previous step exited %d", code)
-			return PreviousErrorCode, nil
+		for _, previousMarker := range previousMarkers {
+			prevMarkerResult := prevMarkerResults[previousMarker]
+			code, err := prevMarkerResult.ReturnCode, prevMarkerResult.Err
+			if err != nil {
+				return InternalErrorCode, fmt.Errorf("wait for previous marker %s: %v", previousMarker, err)
+			}
+			if code != 0 {
+				logrus.Infof("Skipping as previous step exited %d", code)
+				return PreviousErrorCode, nil
+			}
 		}
 	}
 
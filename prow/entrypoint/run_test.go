@@ -17,6 +17,7 @@ limitations under the License.
 package entrypoint
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
@@ -30,16 +31,17 @@ import (
 
 func TestOptions_Run(t *testing.T) {
 	var testCases = []struct {
-		name           string
-		args           []string
-		alwaysZero     bool
-		invalidMarker  bool
-		previousMarker string
-		timeout        time.Duration
-		gracePeriod    time.Duration
-		expectedLog    string
-		expectedMarker string
-		expectedCode   int
+		name            string
+		args            []string
+		alwaysZero      bool
+		invalidMarker   bool
+		previousMarker  string
+		previousMarkers []string
+		timeout         time.Duration
+		gracePeriod     time.Duration
+		expectedLog     string
+		expectedMarker  string
+		expectedCode    int
 	}{
 		{
 			name:           "successful command",
@@ -135,6 +137,21 @@ func TestOptions_Run(t *testing.T) {
 			expectedMarker: "4",
 			expectedCode:   4,
 		},
+		{
+			name:            "return PreviousErrorCode without running anything if any previous marker failed",
+			previousMarkers: []string{"0", "3"},
+			args:            []string{"echo", "test"},
+			expectedLog:     "level=info msg=\"Skipping as previous step exited 3\"\n",
+			expectedCode:    PreviousErrorCode,
+			expectedMarker:  strconv.Itoa(PreviousErrorCode),
+		},
+		{
+			name:            "run command as normal if all previous markers passed",
+			previousMarkers: []string{"0", "0"},
+			args:            []string{"sh", "-c", "exit 5"},
+			expectedMarker:  "5",
+			expectedCode:    5,
+		},
 		{
 			name:           "start error is written to log",
 			args:           []string{"./this-command-does-not-exist"},
@@ -179,6 +196,14 @@ func TestOptions_Run(t *testing.T) {
 				}
 			}
 
+			for i, marker := range testCase.previousMarkers {
+				p := path.Join(tmpDir, fmt.Sprintf("previous-marker-%d.txt", i))
+				options.PreviousMarkers = append(options.PreviousMarkers, p)
+				if err := ioutil.WriteFile(p, []byte(marker), 0600); err != nil {
+					t.Fatalf("could not create previous marker: %v", err)
+				}
+			}
+
 			if testCase.invalidMarker {
 				options.MarkerFile = "/this/had/better/not/be/a/real/file!@!#$%#$^#%&*&&*()*"
 			}
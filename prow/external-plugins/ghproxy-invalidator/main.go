@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// ghproxy-invalidator forwards GitHub events to ghproxy to invalidate the
+// cached responses they affect.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config/secret"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
+)
+
+type options struct {
+	port int
+
+	ghproxyAdminURL        string
+	instrumentationOptions prowflagutil.InstrumentationOptions
+
+	webhookSecretFile string
+}
+
+func (o *options) Validate() error {
+	if o.ghproxyAdminURL == "" {
+		return errors.New("--ghproxy-admin-url is required")
+	}
+	if _, err := url.ParseRequestURI(o.ghproxyAdminURL); err != nil {
+		return fmt.Errorf("invalid --ghproxy-admin-url URI: %q", o.ghproxyAdminURL)
+	}
+	return nil
+}
+
+func gatherOptions() options {
+	o := options{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.IntVar(&o.port, "port", 8888, "Port to listen on.")
+	fs.StringVar(&o.ghproxyAdminURL, "ghproxy-admin-url", "", "URL of the ghproxy admin API, served on its --admin-port, e.g. http://ghproxy:8889.")
+	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file", "/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
+	for _, group := range []flagutil.OptionGroup{&o.instrumentationOptions} {
+		group.AddFlags(fs)
+	}
+	fs.Parse(os.Args[1:])
+	return o
+}
+
+func main() {
+	o := gatherOptions()
+	if err := o.Validate(); err != nil {
+		logrus.Fatalf("Invalid options: %v", err)
+	}
+
+	logrusutil.ComponentInit()
+	log := logrus.StandardLogger().WithField("plugin", pluginName)
+
+	secretAgent := &secret.Agent{}
+	if err := secretAgent.Start([]string{o.webhookSecretFile}); err != nil {
+		logrus.WithError(err).Fatal("Error starting secrets agent.")
+	}
+
+	serv := &server{
+		tokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
+		invalidateURL:  strings.TrimSuffix(o.ghproxyAdminURL, "/") + "/invalidate",
+		client:         &http.Client{Timeout: 10 * time.Second},
+		log:            log,
+	}
+
+	health := pjutil.NewHealthOnPort(o.instrumentationOptions.HealthPort)
+	health.ServeReady()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", serv)
+	externalplugins.ServeExternalPluginHelp(mux, log, helpProvider)
+	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	defer interrupts.WaitForGracefulShutdown()
+	interrupts.ListenAndServe(httpServer, 5*time.Second)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/ghproxy/ghcache"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+)
+
+const pluginName = "ghproxy-invalidator"
+
+func helpProvider(_ []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	return &pluginhelp.PluginHelp{
+		Description: `The ghproxy-invalidator plugin forwards the changes reported by GitHub events to ghproxy, so that ghproxy revalidates the affected cached responses instead of serving them within its fresh window.`,
+	}, nil
+}
+
+// eventPayload holds the fields of the GitHub event payloads needed to find
+// the API paths affected by an event.
+type eventPayload struct {
+	Repo struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	// Number is set for pull_request events.
+	Number int `json:"number"`
+	Issue  *struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	// Ref is the full ref for push events, and the short ref for create
+	// and delete events.
+	Ref     string `json:"ref"`
+	RefType string `json:"ref_type"`
+	// SHA and Branches are set for status events.
+	SHA      string `json:"sha"`
+	Branches []struct {
+		Name string `json:"name"`
+	} `json:"branches"`
+	CheckRun *struct {
+		HeadSHA string `json:"head_sha"`
+	} `json:"check_run"`
+	CheckSuite *struct {
+		HeadSHA string `json:"head_sha"`
+	} `json:"check_suite"`
+}
+
+func (p eventPayload) number() int {
+	switch {
+	case p.PullRequest != nil:
+		return p.PullRequest.Number
+	case p.Issue != nil:
+		return p.Issue.Number
+	}
+	return p.Number
+}
+
+// invalidationFor returns the API paths affected by an event, or nil if the
+// event doesn't change any cached resource. ghproxy only serves the resources
+// invalidated here without revalidation, so its fresh paths must be updated
+// along with the events handled here.
+func invalidationFor(eventType string, payload []byte) (*ghcache.InvalidationRequest, error) {
+	var p eventPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	if p.Repo.FullName == "" {
+		return nil, nil
+	}
+	// GitHub matches owners and repos case-insensitively.
+	repo := "/repos/" + strings.ToLower(p.Repo.FullName)
+	request := &ghcache.InvalidationRequest{}
+	paths := func(paths ...string) {
+		for _, path := range paths {
+			request.Paths = append(request.Paths, repo+path)
+		}
+	}
+	prefixes := func(prefixes ...string) {
+		for _, prefix := range prefixes {
+			request.Prefixes = append(request.Prefixes, repo+prefix)
+		}
+	}
+
+	switch eventType {
+	case "pull_request", "pull_request_review", "pull_request_review_comment", "issues", "issue_comment":
+		n := p.number()
+		if n == 0 {
+			return nil, nil
+		}
+		// Pull requests are issues too, so both APIs return them.
+		paths("/pulls", "/issues")
+		prefixes(fmt.Sprintf("/pulls/%d", n), fmt.Sprintf("/issues/%d", n))
+	case "push":
+		ref := strings.TrimPrefix(p.Ref, "refs/")
+		prefixes("/git/refs/"+ref, "/git/ref/"+ref, "/contents", "/compare")
+		if branch := strings.TrimPrefix(ref, "heads/"); branch != ref {
+			// The head of the pull requests from the branch changed.
+			paths("/branches", "/commits", "/pulls", "/git/refs", "/git/refs/heads")
+			prefixes("/branches/"+branch, "/commits/"+branch)
+		} else {
+			paths("/tags", "/git/refs", "/git/refs/tags")
+		}
+	case "create", "delete":
+		switch p.RefType {
+		case "branch":
+			paths("/branches", "/git/refs", "/git/refs/heads")
+			prefixes("/branches/"+p.Ref, "/git/refs/heads/"+p.Ref, "/git/ref/heads/"+p.Ref)
+		case "tag":
+			paths("/tags", "/git/refs", "/git/refs/tags")
+			prefixes("/git/refs/tags/"+p.Ref, "/git/ref/tags/"+p.Ref)
+		default:
+			return nil, nil
+		}
+	case "status":
+		if p.SHA == "" {
+			return nil, nil
+		}
+		prefixes("/commits/"+p.SHA, "/statuses/"+p.SHA)
+		for _, branch := range p.Branches {
+			prefixes("/commits/" + branch.Name)
+		}
+	case "check_run", "check_suite":
+		var sha string
+		if p.CheckRun != nil {
+			sha = p.CheckRun.HeadSHA
+		} else if p.CheckSuite != nil {
+			sha = p.CheckSuite.HeadSHA
+		}
+		if sha == "" {
+			return nil, nil
+		}
+		prefixes("/commits/" + sha)
+	case "label":
+		prefixes("/labels")
+	default:
+		return nil, nil
+	}
+	return request, nil
+}
+
+type server struct {
+	tokenGenerator func() []byte
+	// invalidateURL is the invalidation endpoint of the ghproxy admin API.
+	invalidateURL string
+	client        *http.Client
+	log           *logrus.Entry
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	eventType, eventGUID, payload, ok, _ := github.ValidateWebhook(w, r, s.tokenGenerator)
+	if !ok {
+		return
+	}
+	fmt.Fprint(w, "Event received. Have a nice day.")
+
+	if err := s.handleEvent(eventType, eventGUID, payload); err != nil {
+		s.log.WithError(err).WithFields(logrus.Fields{"event-type": eventType, github.EventGUID: eventGUID}).Error("Error forwarding event.")
+	}
+}
+
+func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error {
+	request, err := invalidationFor(eventType, payload)
+	if err != nil || request == nil {
+		return err
+	}
+	s.log.WithFields(logrus.Fields{
+		"event-type":     eventType,
+		github.EventGUID: eventGUID,
+		"paths":          request.Paths,
+		"prefixes":       request.Prefixes,
+	}).Debug("Invalidating cached responses.")
+	return s.invalidate(*request)
+}
+
+// invalidate sends the invalidation request to ghproxy.
+func (s *server) invalidate(request ghcache.InvalidationRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.invalidateURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send invalidation to ghproxy: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("ghproxy rejected invalidation with status %d: %s", resp.StatusCode, message)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/ghproxy/ghcache"
+)
+
+func TestInvalidationFor(t *testing.T) {
+	cases := []struct {
+		name      string
+		eventType string
+		payload   string
+		expected  *ghcache.InvalidationRequest
+	}{
+		{
+			name:      "pull request",
+			eventType: "pull_request",
+			payload:   `{"number": 5, "pull_request": {"number": 5}, "repository": {"full_name": "Org/Repo"}}`,
+			expected: &ghcache.InvalidationRequest{
+				Paths:    []string{"/repos/org/repo/pulls", "/repos/org/repo/issues"},
+				Prefixes: []string{"/repos/org/repo/pulls/5", "/repos/org/repo/issues/5"},
+			},
+		},
+		{
+			name:      "issue comment",
+			eventType: "issue_comment",
+			payload:   `{"issue": {"number": 7}, "repository": {"full_name": "org/repo"}}`,
+			expected: &ghcache.InvalidationRequest{
+				Paths:    []string{"/repos/org/repo/pulls", "/repos/org/repo/issues"},
+				Prefixes: []string{"/repos/org/repo/pulls/7", "/repos/org/repo/issues/7"},
+			},
+		},
+		{
+			name:      "push to a branch",
+			eventType: "push",
+			payload:   `{"ref": "refs/heads/main", "repository": {"full_name": "org/repo"}}`,
+			expected: &ghcache.InvalidationRequest{
+				Paths: []string{"/repos/org/repo/branches", "/repos/org/repo/commits", "/repos/org/repo/pulls", "/repos/org/repo/git/refs", "/repos/org/repo/git/refs/heads"},
+				Prefixes: []string{
+					"/repos/org/repo/git/refs/heads/main", "/repos/org/repo/git/ref/heads/main", "/repos/org/repo/contents", "/repos/org/repo/compare",
+					"/repos/org/repo/branches/main", "/repos/org/repo/commits/main",
+				},
+			},
+		},
+		{
+			name:      "push of a tag",
+			eventType: "push",
+			payload:   `{"ref": "refs/tags/v1", "repository": {"full_name": "org/repo"}}`,
+			expected: &ghcache.InvalidationRequest{
+				Paths:    []string{"/repos/org/repo/tags", "/repos/org/repo/git/refs", "/repos/org/repo/git/refs/tags"},
+				Prefixes: []string{"/repos/org/repo/git/refs/tags/v1", "/repos/org/repo/git/ref/tags/v1", "/repos/org/repo/contents", "/repos/org/repo/compare"},
+			},
+		},
+		{
+			name:      "branch deleted",
+			eventType: "delete",
+			payload:   `{"ref": "feature", "ref_type": "branch", "repository": {"full_name": "org/repo"}}`,
+			expected: &ghcache.InvalidationRequest{
+				Paths:    []string{"/repos/org/repo/branches", "/repos/org/repo/git/refs", "/repos/org/repo/git/refs/heads"},
+				Prefixes: []string{"/repos/org/repo/branches/feature", "/repos/org/repo/git/refs/heads/feature", "/repos/org/repo/git/ref/heads/feature"},
+			},
+		},
+		{
+			name:      "status",
+			eventType: "status",
+			payload:   `{"sha": "abc", "branches": [{"name": "main"}], "repository": {"full_name": "org/repo"}}`,
+			expected: &ghcache.InvalidationRequest{
+				Prefixes: []string{"/repos/org/repo/commits/abc", "/repos/org/repo/statuses/abc", "/repos/org/repo/commits/main"},
+			},
+		},
+		{
+			name:      "check run",
+			eventType: "check_run",
+			payload:   `{"check_run": {"head_sha": "abc"}, "repository": {"full_name": "org/repo"}}`,
+			expected:  &ghcache.InvalidationRequest{Prefixes: []string{"/repos/org/repo/commits/abc"}},
+		},
+		{
+			name:      "label",
+			eventType: "label",
+			payload:   `{"repository": {"full_name": "org/repo"}}`,
+			expected:  &ghcache.InvalidationRequest{Prefixes: []string{"/repos/org/repo/labels"}},
+		},
+		{
+			name:      "unrelated event",
+			eventType: "membership",
+			payload:   `{"repository": {"full_name": "org/repo"}}`,
+		},
+		{
+			name:      "event without repository",
+			eventType: "pull_request",
+			payload:   `{"number": 5}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := invalidationFor(tc.eventType, []byte(tc.payload))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+
+	if _, err := invalidationFor("push", []byte("not json")); err == nil {
+		t.Error("expected an error for an invalid payload")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	var received ghcache.InvalidationRequest
+	status := http.StatusOK
+	ghproxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/invalidate" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer ghproxy.Close()
+
+	s := &server{invalidateURL: ghproxy.URL + "/invalidate", client: ghproxy.Client(), log: logrus.WithField("plugin", pluginName)}
+	payload := []byte(`{"number": 5, "repository": {"full_name": "org/repo"}}`)
+	if err := s.handleEvent("pull_request", "guid", payload); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"/repos/org/repo/pulls/5", "/repos/org/repo/issues/5"}; !reflect.DeepEqual(expected, received.Prefixes) {
+		t.Errorf("expected prefixes %v to be invalidated, got %v", expected, received.Prefixes)
+	}
+
+	status = http.StatusBadRequest
+	if err := s.handleEvent("pull_request", "guid", payload); err == nil {
+		t.Error("expected an error when ghproxy rejects the invalidation")
+	}
+}
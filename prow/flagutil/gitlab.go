@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flagutil
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+
+	"k8s.io/test-infra/prow/config/secret"
+	"k8s.io/test-infra/prow/gitlab"
+)
+
+// GitLabOptions holds options for interacting with a GitLab instance.
+type GitLabOptions struct {
+	Endpoint  string
+	TokenPath string
+}
+
+// AddFlags injects GitLab options into the given FlagSet.
+func (o *GitLabOptions) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Endpoint, "gitlab-endpoint", "", "URL of the GitLab instance, like https://gitlab.example.com.")
+	fs.StringVar(&o.TokenPath, "gitlab-token-path", "", "Path to the file containing the GitLab access token, which needs the api scope.")
+}
+
+// Validate validates GitLab options.
+func (o *GitLabOptions) Validate(_ bool) error {
+	if o.Endpoint == "" {
+		return errors.New("--gitlab-endpoint must be set")
+	}
+	if u, err := url.ParseRequestURI(o.Endpoint); err != nil || u.Host == "" {
+		return fmt.Errorf("--gitlab-endpoint %q is invalid", o.Endpoint)
+	}
+	if o.TokenPath == "" {
+		return errors.New("--gitlab-token-path must be set")
+	}
+	return nil
+}
+
+// GitLabClient returns a GitLab client authenticating with the token,
+// which the secret agent reloads when it changes.
+func (o *GitLabOptions) GitLabClient(secretAgent *secret.Agent, dryRun bool) (gitlab.Client, error) {
+	if err := secretAgent.Add(o.TokenPath); err != nil {
+		return nil, fmt.Errorf("failed to get --gitlab-token-path: %w", err)
+	}
+	return gitlab.NewClient(o.Endpoint, secretAgent.GetTokenGenerator(o.TokenPath), dryRun)
+}
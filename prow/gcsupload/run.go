@@ -24,6 +24,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -132,9 +133,31 @@ func (o Options) assembleTargets(spec *downwardapi.JobSpec, extra map[string]gcs
 		uploadTargets[path.Join(blobStoragePath, destination)] = upload
 	}
 
+	if o.LocalOutputDir == "" {
+		applyRetention(o.GCSConfiguration.Retention, blobStoragePath, uploadTargets, time.Now())
+	}
+
 	return uploadTargets, nil
 }
 
+// applyRetention records when each object uploaded for the job run
+// expires, so that the reaper can delete it later on.
+func applyRetention(retention *prowapi.Retention, blobStoragePath string, uploadTargets map[string]gcs.UploadFunc, now time.Time) {
+	if retention == nil {
+		return
+	}
+	for destination, upload := range uploadTargets {
+		relPath := strings.TrimPrefix(destination, blobStoragePath+"/")
+		if relPath == destination {
+			// aliases and latest build markers are shared between runs
+			continue
+		}
+		if expiration, ok := gcs.ExpirationForPath(retention, relPath, now); ok {
+			uploadTargets[destination] = gcs.WithExpiration(upload, expiration)
+		}
+	}
+}
+
 // PathsForJob determines the following for a job:
 //  - path in blob storage under the bucket where job artifacts will be uploaded for:
 //     - the job
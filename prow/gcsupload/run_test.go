@@ -24,10 +24,12 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/diff"
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/pod-utils/gcs"
 )
@@ -295,3 +297,50 @@ func TestBuilderForStrategy(t *testing.T) {
 		}
 	}
 }
+
+type metadataWriter struct {
+	opts pkgio.WriterOptions
+}
+
+func (w *metadataWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *metadataWriter) Close() error                { return nil }
+func (w *metadataWriter) ApplyWriterOptions(opts pkgio.WriterOptions) {
+	opts.Apply(&w.opts)
+}
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	uploadTargets := map[string]gcs.UploadFunc{}
+	for _, destination := range []string{
+		"pr-logs/directory/job/1.txt",
+		"pr-logs/pull/org_repo/1/job/latest-build.txt",
+		"pr-logs/pull/org_repo/1/job/1/build-log.txt",
+		"pr-logs/pull/org_repo/1/job/1/finished.json",
+		"pr-logs/pull/org_repo/1/job/1/podinfo.json",
+		"pr-logs/pull/org_repo/1/job/1/artifacts/junit.xml",
+	} {
+		uploadTargets[destination] = gcs.DataUpload(strings.NewReader(destination))
+	}
+
+	applyRetention(&prowapi.Retention{Logs: "90d", Artifacts: "14d"}, "pr-logs/pull/org_repo/1/job/1", uploadTargets, now)
+
+	expected := map[string]string{
+		"pr-logs/directory/job/1.txt":                       "",
+		"pr-logs/pull/org_repo/1/job/latest-build.txt":      "",
+		"pr-logs/pull/org_repo/1/job/1/build-log.txt":       "",
+		"pr-logs/pull/org_repo/1/job/1/finished.json":       "",
+		"pr-logs/pull/org_repo/1/job/1/podinfo.json":        "2021-05-30T00:00:00Z",
+		"pr-logs/pull/org_repo/1/job/1/artifacts/junit.xml": "2021-03-15T00:00:00Z",
+	}
+	actual := map[string]string{}
+	for destination, upload := range uploadTargets {
+		writer := &metadataWriter{}
+		if err := upload(writer); err != nil {
+			t.Fatalf("Failed to upload %s: %v", destination, err)
+		}
+		actual[destination] = writer.opts.Metadata[gcs.ExpirationMetadataKey]
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not apply retention correctly:\n%s\n", diff.ObjectReflectDiff(expected, actual))
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andygrunwald/go-gerrit"
@@ -44,6 +46,7 @@ type prowJobClient interface {
 
 type gerritClient interface {
 	QueryChanges(lastState client.LastSyncState, rateLimit int) map[string][]client.ChangeInfo
+	GetChange(instance, id string) (*client.ChangeInfo, error)
 	GetBranchRevision(instance, project, branch string) (string, error)
 	SetReview(instance, id, revision, message string, labels map[string]string) error
 	Account(instance string) *gerrit.AccountInfo
@@ -59,6 +62,27 @@ type Controller struct {
 	prowJobClient prowJobClient
 	gc            gerritClient
 	tracker       LastSyncTracker
+
+	// lock guards handled and processing, it is never held while
+	// talking to gerrit so that events are not held up by polling.
+	lock sync.Mutex
+	// handled records the changes processed by polling or in response to
+	// an event until the last sync catches up with them, so that neither
+	// processes them again.
+	handled map[handledKey]handledChange
+	// processing holds the changes being processed, so that polling and
+	// events never process the same change at the same time.
+	processing map[handledKey]bool
+}
+
+type handledKey struct {
+	instance string
+	id       string
+}
+
+type handledChange struct {
+	project string
+	updated time.Time
 }
 
 type LastSyncTracker interface {
@@ -73,6 +97,8 @@ func NewController(lastSyncTracker LastSyncTracker, gc gerritClient, prowJobClie
 		config:        cfg,
 		gc:            gc,
 		tracker:       lastSyncTracker,
+		handled:       map[handledKey]handledChange{},
+		processing:    map[handledKey]bool{},
 	}
 }
 
@@ -91,8 +117,16 @@ func (c *Controller) Sync() error {
 				"repo":     change.Project,
 				"revision": change.CurrentRevision,
 			})
-			if err := c.processChange(log, instance, change); err != nil {
-				log.WithError(err).Errorf("Failed to process change")
+			key := handledKey{instance: instance, id: change.ID}
+			if handled, ok := c.handledChange(key); ok && !change.Updated.Time.After(handled.updated) {
+				log.Debug("Change already processed")
+			} else if !c.startProcessing(key) {
+				log.Debug("Change is being processed in response to an event")
+			} else {
+				if err := c.processChange(log, instance, change); err != nil {
+					log.WithError(err).Errorf("Failed to process change")
+				}
+				c.doneProcessing(key, handledChange{project: change.Project, updated: change.Updated.Time})
 			}
 			lastTime, ok := latest[instance][change.Project]
 			if !ok || lastTime.Before(change.Updated.Time) {
@@ -104,7 +138,129 @@ func (c *Controller) Sync() error {
 		log.Infof("Processed %d changes", len(changes))
 	}
 
-	return c.tracker.Update(latest)
+	if err := c.tracker.Update(latest); err != nil {
+		return err
+	}
+
+	// Changes last updated before the latest sync are never returned by the
+	// next query unless they are updated again, so we can forget about them
+	// once the tracker has caught up with them.
+	c.lock.Lock()
+	for key, handled := range c.handled {
+		if lastTime, ok := latest[key.instance][handled.project]; ok && !handled.updated.After(lastTime) {
+			delete(c.handled, key)
+		}
+	}
+	c.lock.Unlock()
+
+	return nil
+}
+
+// HandleEvent processes the change referenced by a gerrit event right away,
+// rather than waiting for the next sync to find it.
+//
+// Only patchset-created and comment-added events for projects we sync are
+// handled, everything else is left to polling. Changes being processed by
+// polling at the same time are left to the next sync as well.
+func (c *Controller) HandleEvent(instance string, event client.Event) error {
+	if event.Type != client.PatchsetCreatedEvent && event.Type != client.CommentAddedEvent {
+		return nil
+	}
+
+	if _, ok := c.tracker.Current()[instance][event.Change.Project]; !ok {
+		return nil
+	}
+
+	change, err := c.gc.GetChange(instance, strconv.Itoa(event.Change.Number))
+	if err != nil {
+		return fmt.Errorf("get change: %w", err)
+	}
+	if change.Status != client.New {
+		return nil
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"host":     instance,
+		"branch":   change.Branch,
+		"change":   change.Number,
+		"repo":     change.Project,
+		"revision": change.CurrentRevision,
+		"event":    event.Type,
+	})
+	key := handledKey{instance: instance, id: change.ID}
+	if handled, ok := c.handledChange(key); ok && !change.Updated.Time.After(handled.updated) {
+		log.Debug("Change already processed")
+		return nil
+	}
+	if !c.startProcessing(key) {
+		log.Debug("Change is being processed already")
+		return nil
+	}
+	err = c.processChange(log, instance, *change)
+	c.doneProcessing(key, handledChange{project: change.Project, updated: change.Updated.Time})
+	return err
+}
+
+// handledChange returns the record of a change that was already processed.
+func (c *Controller) handledChange(key handledKey) (handledChange, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	handled, ok := c.handled[key]
+	return handled, ok
+}
+
+// startProcessing marks the change as being processed, unless it already is.
+func (c *Controller) startProcessing(key handledKey) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.processing[key] {
+		return false
+	}
+	if c.processing == nil {
+		c.processing = map[handledKey]bool{}
+	}
+	c.processing[key] = true
+	return true
+}
+
+// doneProcessing marks the change as processed and records it as handled.
+func (c *Controller) doneProcessing(key handledKey, handled handledChange) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.processing, key)
+	if c.handled == nil {
+		c.handled = map[handledKey]handledChange{}
+	}
+	c.handled[key] = handled
+}
+
+// ProcessEvents handles the events received from the channel until the
+// context is cancelled.
+func (c *Controller) ProcessEvents(ctx context.Context, events <-chan InstanceEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if err := c.HandleEvent(event.Instance, event.Event); err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"host":   event.Instance,
+					"change": event.Event.Change.Number,
+					"event":  event.Event.Type,
+				}).Error("Failed to handle event")
+			}
+		}
+	}
+}
+
+// lastUpdate returns the time since which the change has not been looked at,
+// either by polling its project or in response to an event.
+func (c *Controller) lastUpdate(instance string, change client.ChangeInfo) (time.Time, bool) {
+	lastUpdate, ok := c.tracker.Current()[instance][change.Project]
+	if handled, present := c.handledChange(handledKey{instance: instance, id: change.ID}); present && handled.updated.After(lastUpdate) {
+		return handled.updated, true
+	}
+	return lastUpdate, ok
 }
 
 func makeCloneURI(instance, project string) (*url.URL, error) {
@@ -263,7 +419,7 @@ func (c *Controller) processChange(logger logrus.FieldLogger, instance string, c
 			return errors.New("account not found") // Should not happen, since this means auth failed
 		}
 
-		lastUpdate, ok := c.tracker.Current()[instance][change.Project]
+		lastUpdate, ok := c.lastUpdate(instance, change)
 		if !ok {
 			lastUpdate = time.Now()
 			logger.WithField("lastUpdate", lastUpdate).Warnf("lastUpdate not found, falling back to now")
@@ -17,6 +17,8 @@ limitations under the License.
 package adapter
 
 import (
+	"fmt"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -57,10 +59,21 @@ func (f *fca) Config() *config.Config {
 
 type fgc struct {
 	reviews int
+	// changes maps instances to their changes.
+	changes map[string][]client.ChangeInfo
 }
 
 func (f *fgc) QueryChanges(lastUpdate client.LastSyncState, rateLimit int) map[string][]client.ChangeInfo {
-	return nil
+	return f.changes
+}
+
+func (f *fgc) GetChange(instance, id string) (*client.ChangeInfo, error) {
+	for _, change := range f.changes[instance] {
+		if change.ID == id || strconv.Itoa(change.Number) == id {
+			return &change, nil
+		}
+	}
+	return nil, fmt.Errorf("change %s not found", id)
 }
 
 func (f *fgc) SetReview(instance, id, revision, message string, labels map[string]string) error {
@@ -0,0 +1,242 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/gerrit/client"
+)
+
+// defaultStreamEventsPort is the default port of the gerrit ssh daemon.
+const defaultStreamEventsPort = "29418"
+
+// InstanceEvent is a gerrit event along with the instance that sent it.
+type InstanceEvent struct {
+	Instance string
+	Event    client.Event
+}
+
+// EventSource delivers gerrit events until the context is cancelled.
+type EventSource interface {
+	Run(ctx context.Context, events chan<- InstanceEvent) error
+}
+
+// StreamEventSource reads the JSON lines printed by the gerrit
+// stream-events command, reconnecting whenever the stream ends.
+type StreamEventSource struct {
+	Instance string
+	// Open starts a new stream of events.
+	Open func(ctx context.Context) (io.ReadCloser, error)
+	// Backoff is how long to wait before reconnecting.
+	Backoff time.Duration
+}
+
+// NewSSHStreamEventSource returns an event source running gerrit
+// stream-events over ssh against the target, formatted as [user@]host[:port].
+func NewSSHStreamEventSource(instance, target string) (*StreamEventSource, error) {
+	if target == "" {
+		return nil, fmt.Errorf("no ssh target for %s", instance)
+	}
+	host, port := target, defaultStreamEventsPort
+	if at := strings.LastIndex(target, "@"); strings.Contains(target[at+1:], ":") {
+		var err error
+		if host, port, err = net.SplitHostPort(target); err != nil {
+			return nil, fmt.Errorf("invalid ssh target %q: %v", target, err)
+		}
+	}
+	return &StreamEventSource{
+		Instance: instance,
+		Open: func(ctx context.Context) (io.ReadCloser, error) {
+			cmd := exec.CommandContext(ctx, "ssh", "-p", port, host, "gerrit", "stream-events")
+			out, err := cmd.StdoutPipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := cmd.Start(); err != nil {
+				return nil, fmt.Errorf("start ssh: %v", err)
+			}
+			return &commandReader{ReadCloser: out, cmd: cmd}, nil
+		},
+		Backoff: 10 * time.Second,
+	}, nil
+}
+
+// commandReader waits for the command to exit when the output is closed.
+type commandReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *commandReader) Close() error {
+	r.ReadCloser.Close()
+	return r.cmd.Wait()
+}
+
+// Run streams events until the context is cancelled.
+func (s *StreamEventSource) Run(ctx context.Context, events chan<- InstanceEvent) error {
+	log := logrus.WithField("host", s.Instance)
+	for {
+		if err := s.stream(ctx, events); err != nil {
+			log.WithError(err).Warn("Event stream failed")
+		} else {
+			log.Info("Event stream ended")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.Backoff):
+		}
+	}
+}
+
+func (s *StreamEventSource) stream(ctx context.Context, events chan<- InstanceEvent) error {
+	stream, err := s.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	return readEvents(ctx, s.Instance, stream, events)
+}
+
+// readEvents sends each of the JSON lines in the reader as an event.
+func readEvents(ctx context.Context, instance string, r io.Reader, events chan<- InstanceEvent) error {
+	scanner := bufio.NewScanner(r)
+	// Events include the commit message, so they can get rather long.
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var event client.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			logrus.WithError(err).WithField("host", instance).Warn("Ignoring malformed event")
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case events <- InstanceEvent{Instance: instance, Event: event}:
+		}
+	}
+	return scanner.Err()
+}
+
+// EventHandler receives the events posted by the gerrit webhooks plugin.
+//
+// Requests must carry the shared token, either as a bearer token in the
+// Authorization header or in the token query parameter, since the webhooks
+// plugin cannot set headers. The instance is taken from the instance query
+// parameter when set, otherwise from the URL of the change.
+type EventHandler struct {
+	events chan<- InstanceEvent
+	token  func() []byte
+}
+
+// NewEventHandler returns a handler sending the events it receives to the
+// channel, once they are authenticated with the token.
+func NewEventHandler(events chan<- InstanceEvent, token func() []byte) *EventHandler {
+	return &EventHandler{events: events, token: token}
+}
+
+// authenticated determines whether the request carries the shared token.
+func (h *EventHandler) authenticated(r *http.Request) bool {
+	token := h.token()
+	if len(token) == 0 {
+		return false
+	}
+	provided := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		provided = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), token) == 1
+}
+
+// ServeHTTP implements http.Handler.
+func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticated(r) {
+		http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var event client.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, fmt.Sprintf("400 Bad Request: invalid event: %v", err), http.StatusBadRequest)
+		return
+	}
+	instance := r.URL.Query().Get("instance")
+	if instance == "" {
+		var err error
+		if instance, err = event.Instance(); err != nil {
+			http.Error(w, fmt.Sprintf("400 Bad Request: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "503 Service Unavailable", http.StatusServiceUnavailable)
+	case h.events <- InstanceEvent{Instance: instance, Event: event}:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// FakeEventSource is an EventSource for tests, delivering the events sent to it.
+type FakeEventSource struct {
+	events chan InstanceEvent
+}
+
+// NewFakeEventSource returns a fake event source.
+func NewFakeEventSource() *FakeEventSource {
+	return &FakeEventSource{events: make(chan InstanceEvent)}
+}
+
+// Send delivers the event once the source is running.
+func (f *FakeEventSource) Send(instance string, event client.Event) {
+	f.events <- InstanceEvent{Instance: instance, Event: event}
+}
+
+// Run forwards the events sent to the fake until the context is cancelled.
+func (f *FakeEventSource) Run(ctx context.Context, events chan<- InstanceEvent) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-f.events:
+			select {
+			case <-ctx.Done():
+				return nil
+			case events <- event:
+			}
+		}
+	}
+}
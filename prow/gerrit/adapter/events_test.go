@@ -0,0 +1,456 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	clienttesting "k8s.io/client-go/testing"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	prowfake "k8s.io/test-infra/prow/client/clientset/versioned/fake"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/gerrit/client"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := strings.Join([]string{
+		`{"type":"patchset-created","change":{"project":"test-infra","number":1},"patchSet":{"number":2}}`,
+		``,
+		`not json`,
+		`{"type":"comment-added","change":{"project":"test-infra","number":3},"comment":"/retest"}`,
+	}, "\n")
+	events := make(chan InstanceEvent, 10)
+	if err := readEvents(context.Background(), "https://gerrit", strings.NewReader(stream), events); err != nil {
+		t.Fatalf("readEvents: %v", err)
+	}
+	close(events)
+	var actual []InstanceEvent
+	for event := range events {
+		actual = append(actual, event)
+	}
+	expected := []InstanceEvent{
+		{
+			Instance: "https://gerrit",
+			Event: client.Event{
+				Type:     client.PatchsetCreatedEvent,
+				Change:   client.EventChange{Project: "test-infra", Number: 1},
+				PatchSet: client.EventPatchSet{Number: 2},
+			},
+		},
+		{
+			Instance: "https://gerrit",
+			Event: client.Event{
+				Type:    client.CommentAddedEvent,
+				Change:  client.EventChange{Project: "test-infra", Number: 3},
+				Comment: "/retest",
+			},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected events %+v, got %+v", expected, actual)
+	}
+}
+
+func TestEventHandler(t *testing.T) {
+	testcases := []struct {
+		name             string
+		method           string
+		query            string
+		auth             string
+		body             string
+		expectedCode     int
+		expectedInstance string
+	}{
+		{
+			name:             "instance from change url",
+			method:           http.MethodPost,
+			query:            "?token=s3cr3t",
+			body:             `{"type":"patchset-created","change":{"number":1,"url":"https://gerrit-review.example.com/c/test-infra/+/1"}}`,
+			expectedCode:     http.StatusNoContent,
+			expectedInstance: "https://gerrit-review.example.com",
+		},
+		{
+			name:             "token from authorization header",
+			method:           http.MethodPost,
+			query:            "?instance=https://other",
+			auth:             "Bearer s3cr3t",
+			body:             `{"type":"patchset-created","change":{"number":1}}`,
+			expectedCode:     http.StatusNoContent,
+			expectedInstance: "https://other",
+		},
+		{
+			name:         "missing token",
+			method:       http.MethodPost,
+			query:        "?instance=https://other",
+			body:         `{"type":"patchset-created","change":{"number":1}}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "wrong token",
+			method:       http.MethodPost,
+			query:        "?instance=https://other&token=guess",
+			body:         `{"type":"patchset-created","change":{"number":1}}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "wrong bearer token",
+			method:       http.MethodPost,
+			query:        "?instance=https://other&token=s3cr3t",
+			auth:         "Bearer guess",
+			body:         `{"type":"patchset-created","change":{"number":1}}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "instance from query",
+			method:           http.MethodPost,
+			query:            "?instance=https://other&token=s3cr3t",
+			body:             `{"type":"patchset-created","change":{"number":1,"url":"https://gerrit-review.example.com/c/test-infra/+/1"}}`,
+			expectedCode:     http.StatusNoContent,
+			expectedInstance: "https://other",
+		},
+		{
+			name:         "no instance",
+			method:       http.MethodPost,
+			query:        "?token=s3cr3t",
+			body:         `{"type":"patchset-created","change":{"number":1}}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "malformed event",
+			method:       http.MethodPost,
+			query:        "?token=s3cr3t",
+			body:         `{`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "not a post",
+			method:       http.MethodGet,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := make(chan InstanceEvent, 1)
+			req := httptest.NewRequest(tc.method, "/"+tc.query, strings.NewReader(tc.body))
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			rr := httptest.NewRecorder()
+			NewEventHandler(events, func() []byte { return []byte("s3cr3t") }).ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected code %d, got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tc.expectedInstance == "" {
+				return
+			}
+			event := <-events
+			if event.Instance != tc.expectedInstance {
+				t.Errorf("expected instance %q, got %q", tc.expectedInstance, event.Instance)
+			}
+		})
+	}
+}
+
+func createdProwJobs(clientset *prowfake.Clientset) []*prowapi.ProwJob {
+	var prowjobs []*prowapi.ProwJob
+	for _, action := range clientset.Fake.Actions() {
+		if action, ok := action.(clienttesting.CreateActionImpl); ok {
+			if prowjob, ok := action.Object.(*prowapi.ProwJob); ok {
+				prowjobs = append(prowjobs, prowjob)
+			}
+		}
+	}
+	return prowjobs
+}
+
+func newEventTestController(changes []client.ChangeInfo) (*Controller, *prowfake.Clientset, *fakeSync) {
+	const instance = "https://gerrit"
+	presubmits := []config.Presubmit{
+		{
+			JobBase:   config.JobBase{Name: "test-foo"},
+			AlwaysRun: true,
+			Reporter:  config.Reporter{Context: "test-foo"},
+		},
+	}
+	if err := config.SetPresubmitRegexes(presubmits); err != nil {
+		panic(err)
+	}
+	cfg := &config.Config{
+		JobConfig: config.JobConfig{
+			PresubmitsStatic: map[string][]config.Presubmit{"gerrit/test-infra": presubmits},
+		},
+	}
+	clientset := prowfake.NewSimpleClientset()
+	tracker := &fakeSync{val: client.LastSyncState{instance: {"test-infra": timeNow.Add(-time.Minute)}}}
+	c := NewController(tracker, &fgc{changes: map[string][]client.ChangeInfo{instance: changes}}, clientset.ProwV1().ProwJobs("prowjobs"), func() *config.Config { return cfg })
+	return c, clientset, tracker
+}
+
+func TestHandleEvent(t *testing.T) {
+	change := client.ChangeInfo{
+		ID:              "test-infra~master~I1",
+		Number:          1,
+		CurrentRevision: "1",
+		Project:         "test-infra",
+		Branch:          "master",
+		Status:          client.New,
+		Updated:         stampNow,
+		Revisions: map[string]client.RevisionInfo{
+			"1": {Ref: "refs/changes/00/1/1", Created: stampNow},
+		},
+	}
+	testcases := []struct {
+		name   string
+		event  client.Event
+		numPJ  int
+		errors bool
+	}{
+		{
+			name:  "patchset created triggers jobs",
+			event: client.Event{Type: client.PatchsetCreatedEvent, Change: client.EventChange{Project: "test-infra", Number: 1}},
+			numPJ: 1,
+		},
+		{
+			name:  "other events are ignored",
+			event: client.Event{Type: "ref-updated", Change: client.EventChange{Project: "test-infra", Number: 1}},
+		},
+		{
+			name:  "untracked projects are ignored",
+			event: client.Event{Type: client.PatchsetCreatedEvent, Change: client.EventChange{Project: "woof", Number: 1}},
+		},
+		{
+			name:   "missing change errors",
+			event:  client.Event{Type: client.CommentAddedEvent, Change: client.EventChange{Project: "test-infra", Number: 2}},
+			errors: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, clientset, _ := newEventTestController([]client.ChangeInfo{change})
+			err := c.HandleEvent("https://gerrit", tc.event)
+			if err != nil && !tc.errors {
+				t.Fatalf("unexpected error: %v", err)
+			} else if err == nil && tc.errors {
+				t.Fatal("expected an error, got none")
+			}
+			if n := len(createdProwJobs(clientset)); n != tc.numPJ {
+				t.Errorf("expected %d prowjobs, got %d", tc.numPJ, n)
+			}
+		})
+	}
+}
+
+func TestHandleEventDeduplicatesWithSync(t *testing.T) {
+	change := client.ChangeInfo{
+		ID:              "test-infra~master~I1",
+		Number:          1,
+		CurrentRevision: "1",
+		Project:         "test-infra",
+		Branch:          "master",
+		Status:          client.New,
+		Updated:         stampNow,
+		Revisions: map[string]client.RevisionInfo{
+			"1": {Ref: "refs/changes/00/1/1", Created: stampNow},
+		},
+	}
+	c, clientset, tracker := newEventTestController([]client.ChangeInfo{change})
+	event := client.Event{Type: client.PatchsetCreatedEvent, Change: client.EventChange{Project: "test-infra", Number: 1}}
+	for i := 0; i < 2; i++ {
+		if err := c.HandleEvent("https://gerrit", event); err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	}
+	if n := len(createdProwJobs(clientset)); n != 1 {
+		t.Fatalf("expected a single prowjob after duplicate events, got %d", n)
+	}
+
+	if err := c.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if n := len(createdProwJobs(clientset)); n != 1 {
+		t.Errorf("expected sync to skip the handled change, got %d prowjobs", n)
+	}
+	if !tracker.Current()["https://gerrit"]["test-infra"].Equal(timeNow) {
+		t.Errorf("expected sync to advance to %v, got %v", timeNow, tracker.Current()["https://gerrit"]["test-infra"])
+	}
+	if len(c.handled) != 0 {
+		t.Errorf("expected handled changes to be forgotten once synced, got %v", c.handled)
+	}
+}
+
+// blockingQuery blocks queries until released, like a slow poll would.
+type blockingQuery struct {
+	*fgc
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingQuery) QueryChanges(lastUpdate client.LastSyncState, rateLimit int) map[string][]client.ChangeInfo {
+	close(b.started)
+	<-b.release
+	return b.fgc.QueryChanges(lastUpdate, rateLimit)
+}
+
+func TestHandleEventDuringSync(t *testing.T) {
+	change := client.ChangeInfo{
+		ID:              "test-infra~master~I1",
+		Number:          1,
+		CurrentRevision: "1",
+		Project:         "test-infra",
+		Branch:          "master",
+		Status:          client.New,
+		Updated:         stampNow,
+		Revisions: map[string]client.RevisionInfo{
+			"1": {Ref: "refs/changes/00/1/1", Created: stampNow},
+		},
+	}
+	c, clientset, _ := newEventTestController([]client.ChangeInfo{change})
+	query := &blockingQuery{fgc: c.gc.(*fgc), started: make(chan struct{}), release: make(chan struct{})}
+	c.gc = query
+
+	synced := make(chan error)
+	go func() {
+		synced <- c.Sync()
+	}()
+	<-query.started
+
+	handled := make(chan error)
+	go func() {
+		handled <- c.HandleEvent("https://gerrit", client.Event{Type: client.PatchsetCreatedEvent, Change: client.EventChange{Project: "test-infra", Number: 1}})
+	}()
+	select {
+	case err := <-handled:
+		if err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the event to be handled while polling")
+	}
+	if n := len(createdProwJobs(clientset)); n != 1 {
+		t.Fatalf("expected the event to trigger a prowjob, got %d", n)
+	}
+
+	close(query.release)
+	if err := <-synced; err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if n := len(createdProwJobs(clientset)); n != 1 {
+		t.Errorf("expected sync to skip the handled change, got %d prowjobs", n)
+	}
+}
+
+// blockingTracker blocks updates until released, like a slow write of the
+// last sync state would.
+type blockingTracker struct {
+	*fakeSync
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTracker) Update(t client.LastSyncState) error {
+	close(b.started)
+	<-b.release
+	return b.fakeSync.Update(t)
+}
+
+func TestHandleEventAfterSync(t *testing.T) {
+	change := client.ChangeInfo{
+		ID:              "test-infra~master~I1",
+		Number:          1,
+		CurrentRevision: "1",
+		Project:         "test-infra",
+		Branch:          "master",
+		Status:          client.New,
+		Updated:         stampNow,
+		Revisions: map[string]client.RevisionInfo{
+			"1": {Ref: "refs/changes/00/1/1", Created: stampNow},
+		},
+	}
+	c, clientset, fake := newEventTestController([]client.ChangeInfo{change})
+	tracker := &blockingTracker{fakeSync: fake, started: make(chan struct{}), release: make(chan struct{})}
+	c.tracker = tracker
+
+	synced := make(chan error)
+	go func() {
+		synced <- c.Sync()
+	}()
+	<-tracker.started
+	if n := len(createdProwJobs(clientset)); n != 1 {
+		t.Fatalf("expected sync to trigger a prowjob, got %d", n)
+	}
+
+	// The event arrives after the change was polled but before the last
+	// sync state caught up with it.
+	if err := c.HandleEvent("https://gerrit", client.Event{Type: client.CommentAddedEvent, Change: client.EventChange{Project: "test-infra", Number: 1}}); err != nil {
+		t.Fatalf("HandleEvent: %v", err)
+	}
+	if n := len(createdProwJobs(clientset)); n != 1 {
+		t.Errorf("expected the event to skip the polled change, got %d prowjobs", n)
+	}
+
+	close(tracker.release)
+	if err := <-synced; err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(c.handled) != 0 {
+		t.Errorf("expected handled changes to be forgotten once synced, got %v", c.handled)
+	}
+}
+
+func TestProcessEvents(t *testing.T) {
+	change := client.ChangeInfo{
+		ID:              "test-infra~master~I1",
+		Number:          1,
+		CurrentRevision: "1",
+		Project:         "test-infra",
+		Branch:          "master",
+		Status:          client.New,
+		Updated:         stampNow,
+		Revisions: map[string]client.RevisionInfo{
+			"1": {Ref: "refs/changes/00/1/1", Created: stampNow},
+		},
+	}
+	c, clientset, _ := newEventTestController([]client.ChangeInfo{change})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := NewFakeEventSource()
+	events := make(chan InstanceEvent)
+	go source.Run(ctx, events)
+	done := make(chan struct{})
+	go func() {
+		c.ProcessEvents(ctx, events)
+		close(done)
+	}()
+
+	source.Send("https://gerrit", client.Event{Type: client.PatchsetCreatedEvent, Change: client.EventChange{Project: "test-infra", Number: 1}})
+	// The next send only completes once the first event was picked up.
+	source.Send("https://gerrit", client.Event{Type: "ref-updated"})
+	cancel()
+	<-done
+
+	if n := len(createdProwJobs(clientset)); n != 1 {
+		t.Errorf("expected 1 prowjob, got %d", n)
+	}
+}
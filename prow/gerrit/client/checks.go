@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// Check states of the gerrit checks plugin.
+// See https://gerrit.googlesource.com/plugins/checks/+/master/resources/Documentation/rest-api-checks.md
+const (
+	CheckScheduled   = "SCHEDULED"
+	CheckRunning     = "RUNNING"
+	CheckSuccessful  = "SUCCESSFUL"
+	CheckFailed      = "FAILED"
+	CheckNotRelevant = "NOT_RELEVANT"
+)
+
+// CheckInput creates or updates the check run of a checker on a revision.
+type CheckInput struct {
+	CheckerUUID string            `json:"checker_uuid"`
+	State       string            `json:"state,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	Started     *gerrit.Timestamp `json:"started,omitempty"`
+	Finished    *gerrit.Timestamp `json:"finished,omitempty"`
+}
+
+// CheckerInput creates a checker, which checks are reported for.
+type CheckerInput struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Repository  string `json:"repository"`
+	Status      string `json:"status,omitempty"`
+}
+
+type gerritChecks interface {
+	Call(method, u string, body interface{}, v interface{}) (*gerrit.Response, error)
+}
+
+// SetCheck creates or updates a check on a revision of a change.
+func (c *Client) SetCheck(instance, id, revision string, check CheckInput) error {
+	h, ok := c.handlers[instance]
+	if !ok {
+		return fmt.Errorf("not activated gerrit instance: %s", instance)
+	}
+
+	u := fmt.Sprintf("changes/%s/revisions/%s/checks", url.PathEscape(id), url.PathEscape(revision))
+	if _, err := h.checksService.Call(http.MethodPost, u, check, ioutil.Discard); err != nil {
+		return fmt.Errorf("cannot set check %s: %v", check.CheckerUUID, err)
+	}
+	return nil
+}
+
+// EnsureChecker creates the checker unless it already exists.
+func (c *Client) EnsureChecker(instance string, checker CheckerInput) error {
+	h, ok := c.handlers[instance]
+	if !ok {
+		return fmt.Errorf("not activated gerrit instance: %s", instance)
+	}
+
+	resp, err := h.checksService.Call(http.MethodGet, "plugins/checks/checkers/"+url.PathEscape(checker.UUID), nil, ioutil.Discard)
+	if err == nil {
+		return nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("cannot get checker %s: %v", checker.UUID, err)
+	}
+	if _, err := h.checksService.Call(http.MethodPost, "plugins/checks/checkers/", checker, ioutil.Discard); err != nil {
+		return fmt.Errorf("cannot create checker %s: %v", checker.UUID, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+type fakeChecks struct {
+	// status maps urls to the status code returned for them
+	status map[string]int
+	calls  []string
+}
+
+func (f *fakeChecks) Call(method, u string, body interface{}, v interface{}) (*gerrit.Response, error) {
+	f.calls = append(f.calls, method+" "+u)
+	code, ok := f.status[u]
+	if !ok {
+		code = http.StatusOK
+	}
+	resp := &gerrit.Response{Response: &http.Response{StatusCode: code}}
+	if code >= 300 {
+		return resp, fmt.Errorf("API call to %s failed: %d", u, code)
+	}
+	return resp, nil
+}
+
+func TestSetCheck(t *testing.T) {
+	checks := &fakeChecks{}
+	c := &Client{handlers: map[string]*gerritInstanceHandler{"gerrit": {checksService: checks}}}
+	if err := c.SetCheck("gerrit", "platform/build~master~I1", "abc", CheckInput{CheckerUUID: "prow:1", State: CheckRunning}); err != nil {
+		t.Fatalf("SetCheck: %v", err)
+	}
+	if err := c.SetCheck("other", "1", "abc", CheckInput{}); err == nil {
+		t.Error("expected an error for an unknown instance")
+	}
+	expected := []string{"POST changes/platform%2Fbuild~master~I1/revisions/abc/checks"}
+	if !reflect.DeepEqual(checks.calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, checks.calls)
+	}
+}
+
+func TestEnsureChecker(t *testing.T) {
+	testcases := []struct {
+		name     string
+		status   map[string]int
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "existing checker",
+			expected: []string{"GET plugins/checks/checkers/prow:1"},
+		},
+		{
+			name:     "missing checker is created",
+			status:   map[string]int{"plugins/checks/checkers/prow:1": http.StatusNotFound},
+			expected: []string{"GET plugins/checks/checkers/prow:1", "POST plugins/checks/checkers/"},
+		},
+		{
+			name:     "lookup fails",
+			status:   map[string]int{"plugins/checks/checkers/prow:1": http.StatusForbidden},
+			expected: []string{"GET plugins/checks/checkers/prow:1"},
+			err:      true,
+		},
+		{
+			name: "creation fails",
+			status: map[string]int{
+				"plugins/checks/checkers/prow:1": http.StatusNotFound,
+				"plugins/checks/checkers/":       http.StatusConflict,
+			},
+			expected: []string{"GET plugins/checks/checkers/prow:1", "POST plugins/checks/checkers/"},
+			err:      true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			checks := &fakeChecks{status: tc.status}
+			c := &Client{handlers: map[string]*gerritInstanceHandler{"gerrit": {checksService: checks}}}
+			err := c.EnsureChecker("gerrit", CheckerInput{UUID: "prow:1", Name: "job", Repository: "platform/build"})
+			if err != nil && !tc.err {
+				t.Errorf("unexpected error: %v", err)
+			} else if err == nil && tc.err {
+				t.Error("expected an error, got none")
+			}
+			if !reflect.DeepEqual(checks.calls, tc.expected) {
+				t.Errorf("expected calls %v, got %v", tc.expected, checks.calls)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ package client
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"sort"
 	"strings"
 	"sync"
@@ -86,7 +87,9 @@ type gerritAccount interface {
 
 type gerritChange interface {
 	QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error)
+	GetChange(changeID string, opt *gerrit.ChangeOptions) (*gerrit.ChangeInfo, *gerrit.Response, error)
 	SetReview(changeID, revisionID string, input *gerrit.ReviewInput) (*gerrit.ReviewResult, *gerrit.Response, error)
+	SubmitChange(changeID string, input *gerrit.SubmitInput) (*gerrit.ChangeInfo, *gerrit.Response, error)
 	ListChangeComments(changeID string) (*map[string][]gerrit.CommentInfo, *gerrit.Response, error)
 }
 
@@ -103,6 +106,7 @@ type gerritInstanceHandler struct {
 	accountService gerritAccount
 	changeService  gerritChange
 	projectService gerritProjects
+	checksService  gerritChecks
 
 	log logrus.FieldLogger
 }
@@ -159,6 +163,7 @@ func NewClient(instances map[string][]string) (*Client, error) {
 			accountService: gc.Accounts,
 			changeService:  gc.Changes,
 			projectService: gc.Projects,
+			checksService:  gc,
 			log:            logrus.WithField("host", instance),
 		}
 	}
@@ -285,6 +290,24 @@ func (c *Client) SetReview(instance, id, revision, message string, labels map[st
 	return nil
 }
 
+// GetChange returns the change with the given id, including the fields
+// needed to trigger jobs for it, like those from QueryChanges.
+func (c *Client) GetChange(instance, id string) (*ChangeInfo, error) {
+	h, ok := c.handlers[instance]
+	if !ok {
+		return nil, fmt.Errorf("not activated gerrit instance: %s", instance)
+	}
+
+	change, _, err := h.changeService.GetChange(id, &gerrit.ChangeOptions{AdditionalFields: changeFields})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get change %s: %v", id, err)
+	}
+	if err := h.injectPatchsetMessages(change); err != nil {
+		h.log.WithError(err).WithField("change", change.Number).Error("Failed to inject patchset messages")
+	}
+	return change, nil
+}
+
 // GetBranchRevision returns SHA of HEAD of a branch
 func (c *Client) GetBranchRevision(instance, project, branch string) (string, error) {
 	h, ok := c.handlers[instance]
@@ -300,6 +323,60 @@ func (c *Client) GetBranchRevision(instance, project, branch string) (string, er
 	return res.Revision, nil
 }
 
+// QueryChangesForProject returns all changes of a project matching the query.
+func (c *Client) QueryChangesForProject(instance, project, query string, rateLimit int) ([]ChangeInfo, error) {
+	h, ok := c.handlers[instance]
+	if !ok {
+		return nil, fmt.Errorf("not activated gerrit instance: %s", instance)
+	}
+
+	var opt gerrit.QueryChangeOptions
+	opt.Query = append(opt.Query, strings.TrimSpace("project:"+project+" "+query))
+	opt.AdditionalFields = changeFields
+	opt.Limit = rateLimit
+
+	var result []ChangeInfo
+	for {
+		opt.Start = len(result)
+		changes, _, err := h.changeService.QueryChanges(&opt)
+		if err != nil {
+			return nil, fmt.Errorf("cannot query changes of %s: %v", project, err)
+		}
+		if changes == nil || len(*changes) == 0 {
+			return result, nil
+		}
+		result = append(result, *changes...)
+		// Gerrit flags the last change of a page when there are more.
+		if !(*changes)[len(*changes)-1].MoreChanges {
+			return result, nil
+		}
+	}
+}
+
+// UnsubmittableChangeError is returned when gerrit refuses to submit a change
+// because it does not meet the submit requirements of its project, e.g. when
+// it lacks a vote or conflicts with its branch.
+type UnsubmittableChangeError string
+
+func (e UnsubmittableChangeError) Error() string { return string(e) }
+
+// SubmitChange submits the current revision of a change.
+func (c *Client) SubmitChange(instance, id string) (*ChangeInfo, error) {
+	h, ok := c.handlers[instance]
+	if !ok {
+		return nil, fmt.Errorf("not activated gerrit instance: %s", instance)
+	}
+
+	change, resp, err := h.changeService.SubmitChange(id, &gerrit.SubmitInput{})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusConflict {
+			return nil, UnsubmittableChangeError(fmt.Sprintf("cannot submit change %s: %v", id, err))
+		}
+		return nil, fmt.Errorf("cannot submit change %s: %v", id, err)
+	}
+	return change, nil
+}
+
 // Account returns gerrit account for the given instance
 func (c *Client) Account(instance string) *gerrit.AccountInfo {
 	return c.accounts[instance]
@@ -332,6 +409,9 @@ func (h *gerritInstanceHandler) queryAllChanges(lastState map[string]time.Time,
 	return result
 }
 
+// changeFields are the additional fields requested for each change.
+var changeFields = []string{"CURRENT_REVISION", "CURRENT_COMMIT", "CURRENT_FILES", "MESSAGES"}
+
 func parseStamp(value gerrit.Timestamp) time.Time {
 	return value.Time
 }
@@ -369,7 +449,7 @@ func (h *gerritInstanceHandler) queryChangesForProject(log logrus.FieldLogger, p
 
 	var opt gerrit.QueryChangeOptions
 	opt.Query = append(opt.Query, "project:"+project)
-	opt.AdditionalFields = []string{"CURRENT_REVISION", "CURRENT_COMMIT", "CURRENT_FILES", "MESSAGES"}
+	opt.AdditionalFields = changeFields
 
 	var start int
 
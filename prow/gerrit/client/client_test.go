@@ -17,8 +17,11 @@ limitations under the License.
 package client
 
 import (
+	"fmt"
+	"net/http"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -28,9 +31,10 @@ import (
 )
 
 type fgc struct {
-	instance string
-	changes  map[string][]gerrit.ChangeInfo
-	comments map[string]map[string][]gerrit.CommentInfo
+	instance  string
+	changes   map[string][]gerrit.ChangeInfo
+	comments  map[string]map[string][]gerrit.CommentInfo
+	submitted []string
 }
 
 func (f *fgc) ListChangeComments(id string) (*map[string][]gerrit.CommentInfo, *gerrit.Response, error) {
@@ -51,6 +55,15 @@ func (f *fgc) ListChangeComments(id string) (*map[string][]gerrit.CommentInfo, *
 
 }
 
+func (f *fgc) GetChange(changeID string, opt *gerrit.ChangeOptions) (*gerrit.ChangeInfo, *gerrit.Response, error) {
+	for _, change := range f.changes[f.instance] {
+		if change.ID == changeID || strconv.Itoa(change.Number) == changeID {
+			return &change, nil, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("change %s not found", changeID)
+}
+
 func (f *fgc) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error) {
 	changes := []gerrit.ChangeInfo{}
 
@@ -61,7 +74,7 @@ func (f *fgc) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo
 
 	project := ""
 	for _, query := range opt.Query {
-		for _, q := range strings.Split(query, "+") {
+		for _, q := range strings.FieldsFunc(query, func(r rune) bool { return r == '+' || r == ' ' }) {
 			if strings.HasPrefix(q, "project:") {
 				project = q[8:]
 			}
@@ -83,6 +96,19 @@ func (f *fgc) SetReview(changeID, revisionID string, input *gerrit.ReviewInput)
 	return nil, nil, nil
 }
 
+func (f *fgc) SubmitChange(changeID string, input *gerrit.SubmitInput) (*gerrit.ChangeInfo, *gerrit.Response, error) {
+	change, _, err := f.GetChange(changeID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if change.Status == Merged {
+		return nil, &gerrit.Response{Response: &http.Response{StatusCode: http.StatusConflict}}, fmt.Errorf("change is %s", change.Status)
+	}
+	f.submitted = append(f.submitted, changeID)
+	change.Status = Merged
+	return change, nil, nil
+}
+
 func makeStamp(t time.Time) gerrit.Timestamp {
 	return gerrit.Timestamp{Time: t}
 }
@@ -674,3 +700,84 @@ func TestQueryChange(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryChangesForProject(t *testing.T) {
+	var changes []gerrit.ChangeInfo
+	for i := 0; i < 7; i++ {
+		changes = append(changes, gerrit.ChangeInfo{Project: "bar", Number: i, ID: strconv.Itoa(i)})
+	}
+	changes = append(changes, gerrit.ChangeInfo{Project: "other", Number: 100, ID: "100"})
+	// The fake returns one change more than the limit, flag it like gerrit
+	// flags the last change of a page when there are more.
+	changes[3].MoreChanges = true
+
+	client := &Client{
+		handlers: map[string]*gerritInstanceHandler{
+			"foo": {
+				instance: "foo",
+				projects: []string{"bar"},
+				changeService: &fgc{
+					changes:  map[string][]gerrit.ChangeInfo{"foo": changes},
+					instance: "foo",
+				},
+				log: logrus.WithField("host", "foo"),
+			},
+		},
+	}
+
+	got, err := client.QueryChangesForProject("foo", "bar", "status:open is:submittable", 3)
+	if err != nil {
+		t.Fatalf("QueryChangesForProject: %v", err)
+	}
+	var numbers []int
+	for _, change := range got {
+		numbers = append(numbers, change.Number)
+	}
+	if want := []int{0, 1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("wrong changes: got %v, want %v", numbers, want)
+	}
+
+	if _, err := client.QueryChangesForProject("unknown", "bar", "", 3); err == nil {
+		t.Error("expected an error for an unknown instance")
+	}
+}
+
+func TestSubmitChange(t *testing.T) {
+	fake := &fgc{
+		changes: map[string][]gerrit.ChangeInfo{"foo": {
+			{Project: "bar", Number: 1, ID: "bar~master~I1", Status: New},
+			{Project: "bar", Number: 2, ID: "bar~master~I2", Status: Merged},
+		}},
+		instance: "foo",
+	}
+	client := &Client{
+		handlers: map[string]*gerritInstanceHandler{
+			"foo": {
+				instance:      "foo",
+				changeService: fake,
+				log:           logrus.WithField("host", "foo"),
+			},
+		},
+	}
+
+	change, err := client.SubmitChange("foo", "bar~master~I1")
+	if err != nil {
+		t.Fatalf("SubmitChange: %v", err)
+	}
+	if change.Status != Merged {
+		t.Errorf("change not merged: %s", change.Status)
+	}
+	if want := []string{"bar~master~I1"}; !reflect.DeepEqual(fake.submitted, want) {
+		t.Errorf("wrong submissions: got %v, want %v", fake.submitted, want)
+	}
+	if _, err := client.SubmitChange("foo", "missing"); err == nil {
+		t.Error("expected an error for a missing change")
+	} else if _, ok := err.(UnsubmittableChangeError); ok {
+		t.Errorf("a missing change is not unsubmittable: %v", err)
+	}
+	if _, err := client.SubmitChange("foo", "bar~master~I2"); err == nil {
+		t.Error("expected an error for a merged change")
+	} else if _, ok := err.(UnsubmittableChangeError); !ok {
+		t.Errorf("expected an UnsubmittableChangeError for a merged change, got %T: %v", err, err)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	// PatchsetCreatedEvent is sent when a change or a new patchset is uploaded
+	PatchsetCreatedEvent = "patchset-created"
+	// CommentAddedEvent is sent when a review comment is added to a change
+	CommentAddedEvent = "comment-added"
+)
+
+// Event is a gerrit event, as printed by the stream-events command or
+// posted by the webhooks plugin, which both use the same format.
+// See https://gerrit-review.googlesource.com/Documentation/cmd-stream-events.html
+type Event struct {
+	Type           string        `json:"type"`
+	Change         EventChange   `json:"change"`
+	PatchSet       EventPatchSet `json:"patchSet"`
+	Comment        string        `json:"comment,omitempty"`
+	EventCreatedOn int64         `json:"eventCreatedOn"`
+}
+
+// EventChange describes the change an event refers to.
+type EventChange struct {
+	Project string `json:"project"`
+	Branch  string `json:"branch"`
+	ID      string `json:"id"`
+	Number  int    `json:"number"`
+	URL     string `json:"url"`
+	Status  string `json:"status,omitempty"`
+}
+
+// EventPatchSet describes the patchset an event refers to.
+type EventPatchSet struct {
+	Number   int    `json:"number"`
+	Revision string `json:"revision"`
+	Ref      string `json:"ref"`
+}
+
+// Instance determines the gerrit instance that sent the event from the
+// URL of its change, e.g. https://android-review.googlesource.com
+func (e Event) Instance() (string, error) {
+	u, err := url.Parse(e.Change.URL)
+	if err != nil {
+		return "", fmt.Errorf("change url %q is invalid: %v", e.Change.URL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("change url %q does not include a host", e.Change.URL)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
@@ -108,6 +108,7 @@ type IssueClient interface {
 	CloseIssue(org, repo string, number int) error
 	ReopenIssue(org, repo string, number int) error
 	FindIssues(query, sort string, asc bool) ([]Issue, error)
+	CountIssues(query string) (int, error)
 	ListOpenIssues(org, repo string) ([]Issue, error)
 	GetIssue(org, repo string, number int) (*Issue, error)
 	EditIssue(org, repo string, number int, issue *Issue) (*Issue, error)
@@ -154,6 +155,12 @@ type RepositoryClient interface {
 	GetBranchProtection(org, repo, branch string) (*BranchProtection, error)
 	RemoveBranchProtection(org, repo, branch string) error
 	UpdateBranchProtection(org, repo, branch string, config BranchProtectionRequest) error
+	UpdateRequiredSignatures(org, repo, branch string, required bool) error
+	ListRepoRulesets(org, repo string) ([]Ruleset, error)
+	GetRepoRuleset(org, repo string, id int) (*Ruleset, error)
+	CreateRepoRuleset(org, repo string, ruleset Ruleset) (*Ruleset, error)
+	UpdateRepoRuleset(org, repo string, id int, ruleset Ruleset) (*Ruleset, error)
+	DeleteRepoRuleset(org, repo string, id int) error
 	AddRepoLabel(org, repo, label, description, color string) error
 	UpdateRepoLabel(org, repo, label, newName, description, color string) error
 	DeleteRepoLabel(org, repo, label string) error
@@ -165,6 +172,14 @@ type RepositoryClient interface {
 	GetDirectory(org, repo, dirpath, commit string) ([]DirectoryContent, error)
 	IsCollaborator(org, repo, user string) (bool, error)
 	ListCollaborators(org, repo string) ([]User, error)
+	ListDirectCollaborators(org, repo string) ([]User, error)
+	AddCollaborator(org, repo, user string, permission RepoPermissionLevel) error
+	RemoveCollaborator(org, repo, user string) error
+	ListRepoInvitations(org, repo string) ([]RepoInvitation, error)
+	DeleteRepoInvitation(org, repo string, id int) error
+	ListDeployKeys(org, repo string) ([]DeployKey, error)
+	CreateDeployKey(org, repo string, key DeployKey) error
+	DeleteDeployKey(org, repo string, id int) error
 	CreateFork(owner, repo string) (string, error)
 	EnsureFork(forkingUser, org, repo string) (string, error)
 	ListRepoTeams(org, repo string) ([]Team, error)
@@ -838,7 +853,7 @@ func (c *client) requestRetry(method, path, accept, org string, body interface{}
 				c.logger.WithField("backoff", backoff.String()).Debug("Retrying 404")
 				c.time.Sleep(backoff)
 				backoff *= 2
-			} else if resp.StatusCode == 403 {
+			} else if resp.StatusCode == 403 || resp.StatusCode == http.StatusTooManyRequests {
 				if resp.Header.Get("X-RateLimit-Remaining") == "0" {
 					// If we are out of API tokens, sleep first. The X-RateLimit-Reset
 					// header tells us the time at which we can request again.
@@ -861,8 +876,9 @@ func (c *client) requestRetry(method, path, accept, org string, body interface{}
 						break
 					}
 				} else if rawTime := resp.Header.Get("Retry-After"); rawTime != "" && rawTime != "0" {
-					// If we are getting abuse rate limited, we need to wait or
-					// else we risk continuing to make the situation worse
+					// If we are getting abuse rate limited, or ghproxy is holding
+					// back our requests to reserve its token budget, we need to
+					// wait or else we risk continuing to make the situation worse
 					var t int
 					if t, err = strconv.Atoi(rawTime); err == nil {
 						// Sleep an extra second plus how long GitHub wants us to
@@ -2338,6 +2354,137 @@ func (c *client) UpdateBranchProtection(org, repo, branch string, config BranchP
 	return err
 }
 
+// UpdateRequiredSignatures requires or stops requiring signed commits on the
+// protected org/repo=branch.
+//
+// See https://docs.github.com/en/rest/branches/branch-protection#create-commit-signature-protection
+func (c *client) UpdateRequiredSignatures(org, repo, branch string, required bool) error {
+	durationLogger := c.log("UpdateRequiredSignatures", org, repo, branch, required)
+	defer durationLogger()
+
+	method, exitCode := http.MethodPost, 200
+	if !required {
+		method, exitCode = http.MethodDelete, 204
+	}
+	_, err := c.request(&request{
+		accept:    "application/vnd.github.zzzax-preview+json", // for required_signatures
+		method:    method,
+		path:      fmt.Sprintf("/repos/%s/%s/branches/%s/protection/required_signatures", org, repo, branch),
+		org:       org,
+		exitCodes: []int{exitCode},
+	}, nil)
+	return err
+}
+
+// ListRepoRulesets lists the rulesets of the repo, including those it
+// inherits from its org. Listed rulesets do not include their rules.
+//
+// See https://docs.github.com/en/rest/repos/rules#get-all-repository-rulesets
+func (c *client) ListRepoRulesets(org, repo string) ([]Ruleset, error) {
+	durationLogger := c.log("ListRepoRulesets", org, repo)
+	defer durationLogger()
+
+	if c.fake {
+		return nil, nil
+	}
+	var rulesets []Ruleset
+	err := c.readPaginatedResultsWithValues(
+		fmt.Sprintf("/repos/%s/%s/rulesets", org, repo),
+		url.Values{
+			"includes_parents": []string{"true"},
+			"per_page":         []string{"100"},
+		},
+		acceptNone,
+		org,
+		func() interface{} {
+			return &[]Ruleset{}
+		},
+		func(obj interface{}) {
+			rulesets = append(rulesets, *(obj.(*[]Ruleset))...)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rulesets, nil
+}
+
+// GetRepoRuleset returns the ruleset of the repo with its rules.
+//
+// See https://docs.github.com/en/rest/repos/rules#get-a-repository-ruleset
+func (c *client) GetRepoRuleset(org, repo string, id int) (*Ruleset, error) {
+	durationLogger := c.log("GetRepoRuleset", org, repo, id)
+	defer durationLogger()
+
+	var ruleset Ruleset
+	_, err := c.request(&request{
+		method:    http.MethodGet,
+		path:      fmt.Sprintf("/repos/%s/%s/rulesets/%d", org, repo, id),
+		org:       org,
+		exitCodes: []int{200},
+	}, &ruleset)
+	if err != nil {
+		return nil, err
+	}
+	return &ruleset, nil
+}
+
+// CreateRepoRuleset creates a ruleset in the repo.
+//
+// See https://docs.github.com/en/rest/repos/rules#create-a-repository-ruleset
+func (c *client) CreateRepoRuleset(org, repo string, ruleset Ruleset) (*Ruleset, error) {
+	durationLogger := c.log("CreateRepoRuleset", org, repo, ruleset.Name)
+	defer durationLogger()
+
+	ruleset.ID = 0
+	ruleset.SourceType = ""
+	var created Ruleset
+	_, err := c.request(&request{
+		method:      http.MethodPost,
+		path:        fmt.Sprintf("/repos/%s/%s/rulesets", org, repo),
+		org:         org,
+		requestBody: &ruleset,
+		exitCodes:   []int{201},
+	}, &created)
+	return &created, err
+}
+
+// UpdateRepoRuleset replaces the ruleset of the repo.
+//
+// See https://docs.github.com/en/rest/repos/rules#update-a-repository-ruleset
+func (c *client) UpdateRepoRuleset(org, repo string, id int, ruleset Ruleset) (*Ruleset, error) {
+	durationLogger := c.log("UpdateRepoRuleset", org, repo, id, ruleset.Name)
+	defer durationLogger()
+
+	ruleset.ID = 0
+	ruleset.SourceType = ""
+	var updated Ruleset
+	_, err := c.request(&request{
+		method:      http.MethodPut,
+		path:        fmt.Sprintf("/repos/%s/%s/rulesets/%d", org, repo, id),
+		org:         org,
+		requestBody: &ruleset,
+		exitCodes:   []int{200},
+	}, &updated)
+	return &updated, err
+}
+
+// DeleteRepoRuleset deletes the ruleset of the repo.
+//
+// See https://docs.github.com/en/rest/repos/rules#delete-a-repository-ruleset
+func (c *client) DeleteRepoRuleset(org, repo string, id int) error {
+	durationLogger := c.log("DeleteRepoRuleset", org, repo, id)
+	defer durationLogger()
+
+	_, err := c.request(&request{
+		method:    http.MethodDelete,
+		path:      fmt.Sprintf("/repos/%s/%s/rulesets/%d", org, repo, id),
+		org:       org,
+		exitCodes: []int{204},
+	}, nil)
+	return err
+}
+
 // AddRepoLabel adds a defined label given org/repo
 //
 // See https://developer.github.com/v3/issues/labels/#create-a-label
@@ -2995,6 +3142,23 @@ func (c *client) FindIssues(query, sort string, asc bool) ([]Issue, error) {
 	return issSearchResult.Issues, err
 }
 
+// CountIssues uses the GitHub search API to count the issues and pull requests
+// which match a particular query without listing them.
+//
+// See https://help.github.com/articles/searching-issues-and-pull-requests/ for details.
+func (c *client) CountIssues(query string) (int, error) {
+	durationLogger := c.log("CountIssues", query)
+	defer durationLogger()
+
+	var issSearchResult IssuesSearchResult
+	_, err := c.request(&request{
+		method:    http.MethodGet,
+		path:      fmt.Sprintf("/search/issues?q=%s&per_page=1", url.QueryEscape(query)),
+		exitCodes: []int{200},
+	}, &issSearchResult)
+	return issSearchResult.Total, err
+}
+
 // FileNotFound happens when github cannot find the file requested by GetFile().
 type FileNotFound struct {
 	org, repo, path, commit string
@@ -3543,6 +3707,193 @@ func (c *client) ListCollaborators(org, repo string) ([]User, error) {
 	return users, nil
 }
 
+// ListDirectCollaborators gets the users with direct access to a repo,
+// rather than through their teams or the permissions of the org.
+//
+// See https://developer.github.com/v3/repos/collaborators/#list-collaborators
+func (c *client) ListDirectCollaborators(org, repo string) ([]User, error) {
+	durationLogger := c.log("ListDirectCollaborators", org, repo)
+	defer durationLogger()
+
+	if c.fake {
+		return nil, nil
+	}
+	values := url.Values{
+		"per_page":    []string{"100"},
+		"affiliation": []string{"direct"},
+	}
+	var users []User
+	err := c.readPaginatedResultsWithValues(
+		fmt.Sprintf("/repos/%s/%s/collaborators", org, repo),
+		values,
+		acceptNone,
+		org,
+		func() interface{} {
+			return &[]User{}
+		},
+		func(obj interface{}) {
+			users = append(users, *(obj.(*[]User))...)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// AddCollaborator gives the user the permission on the repo, inviting them
+// when they do not have access yet.
+//
+// See https://developer.github.com/v3/repos/collaborators/#add-a-repository-collaborator
+func (c *client) AddCollaborator(org, repo, user string, permission RepoPermissionLevel) error {
+	durationLogger := c.log("AddCollaborator", org, repo, user, permission)
+	defer durationLogger()
+
+	data := struct {
+		Permission TeamPermission `json:"permission"`
+	}{}
+	switch permission {
+	case Read:
+		data.Permission = RepoPull
+	case Write:
+		data.Permission = RepoPush
+	case Admin:
+		data.Permission = RepoAdmin
+	default:
+		return fmt.Errorf("cannot add collaborator with permission %q", permission)
+	}
+	_, err := c.request(&request{
+		method:      http.MethodPut,
+		path:        fmt.Sprintf("/repos/%s/%s/collaborators/%s", org, repo, user),
+		org:         org,
+		requestBody: &data,
+		exitCodes:   []int{201, 204},
+	}, nil)
+	return err
+}
+
+// RemoveCollaborator removes the direct access of the user to the repo.
+//
+// See https://developer.github.com/v3/repos/collaborators/#remove-a-repository-collaborator
+func (c *client) RemoveCollaborator(org, repo, user string) error {
+	durationLogger := c.log("RemoveCollaborator", org, repo, user)
+	defer durationLogger()
+
+	_, err := c.request(&request{
+		method:    http.MethodDelete,
+		path:      fmt.Sprintf("/repos/%s/%s/collaborators/%s", org, repo, user),
+		org:       org,
+		exitCodes: []int{204},
+	}, nil)
+	return err
+}
+
+// ListRepoInvitations lists the pending invitations to collaborate on the repo.
+//
+// See https://developer.github.com/v3/repos/invitations/#list-repository-invitations
+func (c *client) ListRepoInvitations(org, repo string) ([]RepoInvitation, error) {
+	durationLogger := c.log("ListRepoInvitations", org, repo)
+	defer durationLogger()
+
+	if c.fake {
+		return nil, nil
+	}
+	var invitations []RepoInvitation
+	err := c.readPaginatedResults(
+		fmt.Sprintf("/repos/%s/%s/invitations", org, repo),
+		acceptNone,
+		org,
+		func() interface{} {
+			return &[]RepoInvitation{}
+		},
+		func(obj interface{}) {
+			invitations = append(invitations, *(obj.(*[]RepoInvitation))...)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// DeleteRepoInvitation cancels a pending invitation to collaborate on the repo.
+//
+// See https://developer.github.com/v3/repos/invitations/#delete-a-repository-invitation
+func (c *client) DeleteRepoInvitation(org, repo string, id int) error {
+	durationLogger := c.log("DeleteRepoInvitation", org, repo, id)
+	defer durationLogger()
+
+	_, err := c.request(&request{
+		method:    http.MethodDelete,
+		path:      fmt.Sprintf("/repos/%s/%s/invitations/%d", org, repo, id),
+		org:       org,
+		exitCodes: []int{204},
+	}, nil)
+	return err
+}
+
+// ListDeployKeys lists the deploy keys of the repo.
+//
+// See https://developer.github.com/v3/repos/keys/#list-deploy-keys
+func (c *client) ListDeployKeys(org, repo string) ([]DeployKey, error) {
+	durationLogger := c.log("ListDeployKeys", org, repo)
+	defer durationLogger()
+
+	if c.fake {
+		return nil, nil
+	}
+	var keys []DeployKey
+	err := c.readPaginatedResults(
+		fmt.Sprintf("/repos/%s/%s/keys", org, repo),
+		acceptNone,
+		org,
+		func() interface{} {
+			return &[]DeployKey{}
+		},
+		func(obj interface{}) {
+			keys = append(keys, *(obj.(*[]DeployKey))...)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateDeployKey adds a deploy key to the repo.
+//
+// See https://developer.github.com/v3/repos/keys/#create-a-deploy-key
+func (c *client) CreateDeployKey(org, repo string, key DeployKey) error {
+	durationLogger := c.log("CreateDeployKey", org, repo, key.Title)
+	defer durationLogger()
+
+	key.ID = 0
+	_, err := c.request(&request{
+		method:      http.MethodPost,
+		path:        fmt.Sprintf("/repos/%s/%s/keys", org, repo),
+		org:         org,
+		requestBody: &key,
+		exitCodes:   []int{201},
+	}, nil)
+	return err
+}
+
+// DeleteDeployKey removes a deploy key from the repo.
+//
+// See https://developer.github.com/v3/repos/keys/#delete-a-deploy-key
+func (c *client) DeleteDeployKey(org, repo string, id int) error {
+	durationLogger := c.log("DeleteDeployKey", org, repo, id)
+	defer durationLogger()
+
+	_, err := c.request(&request{
+		method:    http.MethodDelete,
+		path:      fmt.Sprintf("/repos/%s/%s/keys/%d", org, repo, id),
+		org:       org,
+		exitCodes: []int{204},
+	}, nil)
+	return err
+}
+
 // CreateFork creates a fork for the authenticated user. Forking a repository
 // happens asynchronously. Therefore, we may have to wait a short period before
 // accessing the git objects. If this takes longer than 5 minutes, GitHub
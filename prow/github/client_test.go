@@ -131,6 +131,27 @@ func TestAbuseRateLimit(t *testing.T) {
 	}
 }
 
+func TestTooManyRequests(t *testing.T) {
+	tc := &testTime{now: time.Now()}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tc.slept == 0 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "429 Too Many Requests", http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	c.time = tc
+	resp, err := c.requestRetry(http.MethodGet, "/", "", "", nil)
+	if err != nil {
+		t.Errorf("Error from request: %v", err)
+	} else if resp.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", resp.StatusCode)
+	} else if tc.slept < time.Second {
+		t.Errorf("Expected to sleep for at least a second, got %v", tc.slept)
+	}
+}
+
 func TestRetry404(t *testing.T) {
 	tc := &testTime{now: time.Now()}
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1409,6 +1430,37 @@ func TestFindIssues(t *testing.T) {
 	}
 }
 
+func TestCountIssues(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Bad method: %s", r.Method)
+		}
+		if r.URL.Path != "/search/issues" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		if q := r.URL.Query().Get("q"); q != `org:k8s label:"kind/bug"` {
+			t.Errorf("Bad query: %s", q)
+		}
+		if perPage := r.URL.Query().Get("per_page"); perPage != "1" {
+			t.Errorf("Bad per_page: %s", perPage)
+		}
+		b, err := json.Marshal(&IssuesSearchResult{Total: 42, Issues: []Issue{{Number: 1}}})
+		if err != nil {
+			t.Fatalf("Didn't expect error: %v", err)
+		}
+		fmt.Fprint(w, string(b))
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	count, err := c.CountIssues(`org:k8s label:"kind/bug"`)
+	if err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if count != 42 {
+		t.Errorf("Expected 42 issues, got %d", count)
+	}
+}
+
 func TestGetFile(t *testing.T) {
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -1698,6 +1750,145 @@ func TestListCollaborators(t *testing.T) {
 	}
 }
 
+func TestListDirectCollaborators(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/repo/collaborators" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("affiliation"); got != "direct" {
+			t.Errorf("Bad affiliation: %q", got)
+		}
+		b, err := json.Marshal([]User{{Login: "foo", Permissions: RepoPermissions{Pull: true}}})
+		if err != nil {
+			t.Fatalf("Didn't expect error: %v", err)
+		}
+		fmt.Fprint(w, string(b))
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	users, err := c.ListDirectCollaborators("org", "repo")
+	if err != nil {
+		t.Errorf("Didn't expect error: %v", err)
+	} else if len(users) != 1 || users[0].Login != "foo" {
+		t.Errorf("Wrong users: %v", users)
+	}
+}
+
+func TestAddCollaborator(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("Bad method: %s", r.Method)
+		}
+		if r.URL.Path != "/repos/org/repo/collaborators/foo" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Could not read request body: %v", err)
+		}
+		if string(b) != `{"permission":"push"}` {
+			t.Errorf("Bad request body: %s", b)
+		}
+		http.Error(w, "201 Created", http.StatusCreated)
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	if err := c.AddCollaborator("org", "repo", "foo", Write); err != nil {
+		t.Errorf("Didn't expect error: %v", err)
+	}
+	if err := c.AddCollaborator("org", "repo", "foo", None); err == nil {
+		t.Error("Expected an error for permission none")
+	}
+}
+
+func TestCreateDeployKey(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Bad method: %s", r.Method)
+		}
+		if r.URL.Path != "/repos/org/repo/keys" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		var key DeployKey
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			t.Fatalf("Could not decode request body: %v", err)
+		}
+		if key != (DeployKey{Title: "ci", Key: "ssh-ed25519 AAAA", ReadOnly: true}) {
+			t.Errorf("Bad key: %#v", key)
+		}
+		http.Error(w, "201 Created", http.StatusCreated)
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	if err := c.CreateDeployKey("org", "repo", DeployKey{ID: 3, Title: "ci", Key: "ssh-ed25519 AAAA", ReadOnly: true}); err != nil {
+		t.Errorf("Didn't expect error: %v", err)
+	}
+}
+
+func TestUpdateRequiredSignatures(t *testing.T) {
+	for _, required := range []bool{true, false} {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, code := http.MethodPost, http.StatusOK
+			if !required {
+				method, code = http.MethodDelete, http.StatusNoContent
+			}
+			if r.Method != method {
+				t.Errorf("Bad method: %s", r.Method)
+			}
+			if r.URL.Path != "/repos/org/repo/branches/master/protection/required_signatures" {
+				t.Errorf("Bad request path: %s", r.URL.Path)
+			}
+			w.WriteHeader(code)
+		}))
+		c := getClient(ts.URL)
+		if err := c.UpdateRequiredSignatures("org", "repo", "master", required); err != nil {
+			t.Errorf("Didn't expect error: %v", err)
+		}
+		ts.Close()
+	}
+}
+
+func TestCreateRepoRuleset(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Bad method: %s", r.Method)
+		}
+		if r.URL.Path != "/repos/org/repo/rulesets" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Could not read request body: %v", err)
+		}
+		expected := `{"name":"release","target":"branch","enforcement":"active","conditions":{"ref_name":{"include":["refs/heads/release-*"],"exclude":null}},"rules":[{"type":"required_signatures"},{"type":"required_deployments","parameters":{"required_deployment_environments":["staging"]}}]}`
+		if string(b) != expected {
+			t.Errorf("Bad request body: %s", b)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 5, "name": "release"}`)
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	created, err := c.CreateRepoRuleset("org", "repo", Ruleset{
+		ID:          3,
+		Name:        "release",
+		Target:      RulesetTargetBranch,
+		SourceType:  "Repository",
+		Enforcement: RulesetEnforcementActive,
+		Conditions:  &RulesetConditions{RefName: RulesetRefName{Include: []string{"refs/heads/release-*"}}},
+		Rules: []RulesetRule{
+			{Type: RuleRequiredSignatures},
+			{Type: RuleRequiredDeployments, Parameters: &RulesetRuleParameters{RequiredDeploymentEnvironments: []string{"staging"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if created.ID != 5 {
+		t.Errorf("Expected the created ruleset, got %#v", created)
+	}
+}
+
 func TestListRepoTeams(t *testing.T) {
 	expectedTeams := []Team{
 		{ID: 1, Slug: "foo", Permission: RepoPull},
@@ -2557,6 +2748,7 @@ func TestAllMethodsThatDoRequestSetOrgHeader(t *testing.T) {
 		// They fetch the user, which doesn't exist in case of github app.
 		// TODO: Split the search query by org when app auth is used
 		"FindIssues",
+		"CountIssues",
 	)
 
 	for i := 0; i < clientType.NumMethod(); i++ {
@@ -0,0 +1,249 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plan records the changes tools like peribolos and branchprotector
+// make to GitHub, so they can be reviewed as JSON or Markdown before being
+// applied, or reported as drift from the configuration.
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action is what a change does.
+type Action string
+
+const (
+	// Add creates something, like a team or a member.
+	Add Action = "add"
+	// Remove deletes something.
+	Remove Action = "remove"
+	// Update changes the settings of something.
+	Update Action = "update"
+)
+
+// Change is a single change to GitHub.
+type Change struct {
+	Action Action `json:"action"`
+	// Kind is what is changed, like team member or branch protection.
+	Kind string `json:"kind"`
+	Org  string `json:"org"`
+	// Target is the team, repo or branch the change applies to, if any.
+	Target string `json:"target,omitempty"`
+	// Name identifies what is changed within its target, like a login.
+	Name string `json:"name,omitempty"`
+	// Details describe the settings that are added or updated.
+	Details []string `json:"details,omitempty"`
+}
+
+// Plan is the list of changes to GitHub. It is safe for concurrent use.
+type Plan struct {
+	lock    sync.Mutex
+	changes []Change
+}
+
+// Record adds the change to the plan.
+func (p *Plan) Record(change Change) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.changes = append(p.changes, change)
+}
+
+// Changes returns the recorded changes, sorted by org, target, kind and name.
+func (p *Plan) Changes() []Change {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.changes) == 0 {
+		return nil
+	}
+	changes := make([]Change, len(p.changes))
+	copy(changes, p.changes)
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		switch {
+		case a.Org != b.Org:
+			return a.Org < b.Org
+		case a.Target != b.Target:
+			return a.Target < b.Target
+		case a.Kind != b.Kind:
+			return a.Kind < b.Kind
+		default:
+			return a.Name < b.Name
+		}
+	})
+	return changes
+}
+
+// WriteJSON writes the changes as a JSON list.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	changes := p.Changes()
+	if changes == nil {
+		changes = []Change{}
+	}
+	b, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// WriteMarkdown writes a summary of the changes followed by a table of them.
+func (p *Plan) WriteMarkdown(w io.Writer) error {
+	changes := p.Changes()
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+	count := map[Action]int{}
+	for _, change := range changes {
+		count[change.Action]++
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d changes: %d to add, %d to remove, %d to update.\n\n", len(changes), count[Add], count[Remove], count[Update])
+	b.WriteString("| Action | Kind | Org | Target | Name | Details |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, c := range changes {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", c.Action, c.Kind, escape(c.Org), escape(c.Target), escape(c.Name), escape(strings.Join(c.Details, "<br>")))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escape keeps values from breaking the table.
+func escape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// Fields describes the fields of a request, like "description: foo",
+// omitting those that are unset.
+func Fields(v interface{}) []string {
+	fields, err := toMap(v)
+	if err != nil {
+		return []string{fmt.Sprintf("%+v", v)}
+	}
+	var out []string
+	for k, v := range fields {
+		out = append(out, fmt.Sprintf("%s: %s", k, v))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Diff describes the fields which differ between before and after, like
+// "description: foo → bar".
+func Diff(before, after interface{}) []string {
+	b, errB := toMap(before)
+	a, errA := toMap(after)
+	if errB != nil || errA != nil {
+		return []string{fmt.Sprintf("%+v → %+v", before, after)}
+	}
+	var out []string
+	for k, v := range a {
+		if b[k] != v {
+			out = append(out, fmt.Sprintf("%s: %s → %s", k, b[k], v))
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			out = append(out, fmt.Sprintf("%s: %s → ", k, v))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// toMap flattens the top level JSON fields of v into strings.
+func toMap(v interface{}) (map[string]string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(fields))
+	for k, raw := range fields {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			out[k] = s
+			continue
+		}
+		if string(raw) == "null" {
+			continue
+		}
+		out[k] = string(raw)
+	}
+	return out, nil
+}
+
+// Options configures where plans are written and whether changes are drift.
+type Options struct {
+	JSONPath     string
+	MarkdownPath string
+	Drift        bool
+}
+
+// AddFlags injects plan options into the given FlagSet.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.JSONPath, "plan-json", "", "Write the changes to this file as JSON if set")
+	fs.StringVar(&o.MarkdownPath, "plan-markdown", "", "Write the changes to this file as a Markdown table if set, for instance to comment on pull requests")
+	fs.BoolVar(&o.Drift, "drift", false, "Report any change as drift between GitHub and the config, failing when there is one. Cannot be used with --confirm")
+}
+
+// Validate validates plan options.
+func (o *Options) Validate(dryRun bool) error {
+	if o.Drift && !dryRun {
+		return errors.New("--drift cannot be used with --confirm")
+	}
+	return nil
+}
+
+// Write writes the plan to the configured files. In drift mode, it returns
+// an error when the plan has any change.
+func (o *Options) Write(p *Plan) error {
+	for _, output := range []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{path: o.JSONPath, write: p.WriteJSON},
+		{path: o.MarkdownPath, write: p.WriteMarkdown},
+	} {
+		if output.path == "" {
+			continue
+		}
+		var b strings.Builder
+		if err := output.write(&b); err != nil {
+			return fmt.Errorf("failed to render plan for %s: %w", output.path, err)
+		}
+		if err := ioutil.WriteFile(output.path, []byte(b.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write plan: %w", err)
+		}
+	}
+	if n := len(p.Changes()); o.Drift && n > 0 {
+		return fmt.Errorf("found %d changes made outside of the config", n)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	testCases := []struct {
+		name     string
+		changes  []Change
+		expected string
+	}{
+		{
+			name:     "no changes",
+			expected: "No changes.\n",
+		},
+		{
+			name: "changes are sorted and escaped",
+			changes: []Change{
+				{Action: Update, Kind: "team", Org: "org", Target: "b-team", Details: []string{"description: a|b", "privacy: closed"}},
+				{Action: Add, Kind: "team member", Org: "org", Target: "a-team", Name: "alice", Details: []string{"role: member"}},
+				{Action: Remove, Kind: "org member", Org: "org", Name: "bob"},
+			},
+			expected: `3 changes: 1 to add, 1 to remove, 1 to update.
+
+| Action | Kind | Org | Target | Name | Details |
+| --- | --- | --- | --- | --- | --- |
+| remove | org member | org |  | bob |  |
+| add | team member | org | a-team | alice | role: member |
+| update | team | org | b-team |  | description: a\|b<br>privacy: closed |
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Plan{}
+			for _, change := range tc.changes {
+				p.Record(change)
+			}
+			var b strings.Builder
+			if err := p.WriteMarkdown(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, b.String()); diff != "" {
+				t.Errorf("markdown differs from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	p := &Plan{}
+	var b strings.Builder
+	if err := p.WriteJSON(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.String() != "[]\n" {
+		t.Errorf("expected an empty list without changes, got %q", b.String())
+	}
+
+	p.Record(Change{Action: Remove, Kind: "repo", Org: "org", Target: "repo"})
+	b.Reset()
+	if err := p.WriteJSON(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `[
+  {
+    "action": "remove",
+    "kind": "repo",
+    "org": "org",
+    "target": "repo"
+  }
+]
+`
+	if diff := cmp.Diff(expected, b.String()); diff != "" {
+		t.Errorf("json differs from expected (-want +got):\n%s", diff)
+	}
+}
+
+type settings struct {
+	Name        string  `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Private     *bool   `json:"private,omitempty"`
+}
+
+func TestFieldsAndDiff(t *testing.T) {
+	description := "desc"
+	yes := true
+	no := false
+
+	fields := Fields(settings{Name: "foo", Private: &yes})
+	if diff := cmp.Diff([]string{"name: foo", "private: true"}, fields); diff != "" {
+		t.Errorf("fields differ from expected (-want +got):\n%s", diff)
+	}
+
+	changes := Diff(settings{Name: "foo", Description: &description, Private: &yes}, settings{Name: "foo", Private: &no})
+	if diff := cmp.Diff([]string{"description: desc → ", "private: true → false"}, changes); diff != "" {
+		t.Errorf("diff differs from expected (-want +got):\n%s", diff)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		options     Options
+		dryRun      bool
+		changes     []Change
+		expectedErr bool
+	}{
+		{
+			name:    "changes are not drift by default",
+			options: Options{},
+			changes: []Change{{Action: Add, Kind: "team", Org: "org", Target: "team"}},
+		},
+		{
+			name:    "no drift",
+			options: Options{Drift: true},
+			dryRun:  true,
+		},
+		{
+			name:        "drift",
+			options:     Options{Drift: true},
+			dryRun:      true,
+			changes:     []Change{{Action: Add, Kind: "team", Org: "org", Target: "team"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.options.JSONPath = filepath.Join(dir, "plan.json")
+			tc.options.MarkdownPath = filepath.Join(dir, "plan.md")
+			if err := tc.options.Validate(tc.dryRun); err != nil {
+				t.Fatalf("unexpected validation error: %v", err)
+			}
+			p := &Plan{}
+			for _, change := range tc.changes {
+				p.Record(change)
+			}
+			err := tc.options.Write(p)
+			if tc.expectedErr != (err != nil) {
+				t.Errorf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			for _, path := range []string{tc.options.JSONPath, tc.options.MarkdownPath} {
+				if _, err := ioutil.ReadFile(path); err != nil {
+					t.Errorf("plan was not written: %v", err)
+				}
+			}
+		})
+	}
+
+	drift := Options{Drift: true}
+	if err := drift.Validate(false); err == nil {
+		t.Error("expected --drift to be rejected with --confirm")
+	}
+}
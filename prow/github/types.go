@@ -234,6 +234,10 @@ type PullRequestEvent struct {
 	Repo        Repo                   `json:"repository"`
 	Label       Label                  `json:"label"`
 	Sender      User                   `json:"sender"`
+	// Before and After are the head SHAs before and after a push,
+	// only set for the synchronize action.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
 
 	// Changes holds raw change data, which we must inspect
 	// and deserialize later as this is a polymorphic field
@@ -516,6 +520,10 @@ type BranchProtection struct {
 	EnforceAdmins              EnforceAdmins               `json:"enforce_admins"`
 	RequiredPullRequestReviews *RequiredPullRequestReviews `json:"required_pull_request_reviews"`
 	Restrictions               *Restrictions               `json:"restrictions"`
+	// RequiredSignatures and RequiredConversationResolution are nil when
+	// GitHub does not report them.
+	RequiredSignatures             *ProtectionSetting `json:"required_signatures,omitempty"`
+	RequiredConversationResolution *ProtectionSetting `json:"required_conversation_resolution,omitempty"`
 }
 
 // EnforceAdmins specifies whether to enforce the
@@ -524,6 +532,11 @@ type EnforceAdmins struct {
 	Enabled bool `json:"enabled"`
 }
 
+// ProtectionSetting is a branch protection setting which is either enabled or not.
+type ProtectionSetting struct {
+	Enabled bool `json:"enabled"`
+}
+
 // RequiredPullRequestReviews exposes the state of review rights.
 type RequiredPullRequestReviews struct {
 	DismissalRestrictions        *Restrictions `json:"dismissal_restrictions"`
@@ -549,6 +562,8 @@ type BranchProtectionRequest struct {
 	RequiredLinearHistory      bool                               `json:"required_linear_history"`
 	AllowForcePushes           bool                               `json:"allow_force_pushes"`
 	AllowDeletions             bool                               `json:"allow_deletions"`
+	// RequiredConversationResolution requires all conversations on code to be resolved before merging.
+	RequiredConversationResolution bool `json:"required_conversation_resolution"`
 }
 
 func (r BranchProtectionRequest) String() string {
@@ -613,6 +628,107 @@ type HookRequest struct {
 	RemoveEvents []string    `json:"remove_events,omitempty"` // only repo edit
 }
 
+// RepoInvitation is a pending invitation to collaborate on a repository.
+//
+// See https://developer.github.com/v3/repos/invitations/
+type RepoInvitation struct {
+	ID      int        `json:"id"`
+	Invitee TeamMember `json:"invitee"`
+	// Permissions is read, triage, write, maintain or admin.
+	Permissions string `json:"permissions"`
+}
+
+// DeployKey is an SSH key granting access to a single repository.
+//
+// See https://developer.github.com/v3/repos/keys/
+type DeployKey struct {
+	ID       int    `json:"id,omitempty"`
+	Key      string `json:"key"`
+	Title    string `json:"title"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// Ruleset targets
+const (
+	RulesetTargetBranch = "branch"
+	RulesetTargetTag    = "tag"
+)
+
+// Ruleset enforcement levels
+const (
+	RulesetEnforcementActive   = "active"
+	RulesetEnforcementEvaluate = "evaluate"
+	RulesetEnforcementDisabled = "disabled"
+)
+
+// Ruleset rule types
+const (
+	RuleCreation              = "creation"
+	RuleUpdate                = "update"
+	RuleDeletion              = "deletion"
+	RuleNonFastForward        = "non_fast_forward"
+	RuleRequiredLinearHistory = "required_linear_history"
+	RuleRequiredSignatures    = "required_signatures"
+	RulePullRequest           = "pull_request"
+	RuleRequiredStatusChecks  = "required_status_checks"
+	RuleRequiredDeployments   = "required_deployments"
+)
+
+// Ruleset applies rules to the branches or tags of a repository whose names
+// match its conditions.
+//
+// See https://docs.github.com/en/rest/repos/rules
+type Ruleset struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Target is branch or tag.
+	Target string `json:"target,omitempty"`
+	// SourceType is Repository for rulesets of the repository, or
+	// Organization for the rulesets it inherits.
+	SourceType  string             `json:"source_type,omitempty"`
+	Enforcement string             `json:"enforcement"`
+	Conditions  *RulesetConditions `json:"conditions,omitempty"`
+	Rules       []RulesetRule      `json:"rules,omitempty"`
+}
+
+// RulesetConditions select the refs a ruleset applies to.
+type RulesetConditions struct {
+	RefName RulesetRefName `json:"ref_name"`
+}
+
+// RulesetRefName includes and excludes refs by fnmatch patterns, like
+// refs/heads/release-*, or ~DEFAULT_BRANCH and ~ALL.
+type RulesetRefName struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// RulesetRule is a single rule of a ruleset. Only some rules have parameters.
+type RulesetRule struct {
+	Type       string                 `json:"type"`
+	Parameters *RulesetRuleParameters `json:"parameters,omitempty"`
+}
+
+// RulesetRuleParameters holds the parameters of the pull_request,
+// required_status_checks and required_deployments rules.
+type RulesetRuleParameters struct {
+	RequiredApprovingReviewCount   *int  `json:"required_approving_review_count,omitempty"`
+	DismissStaleReviewsOnPush      *bool `json:"dismiss_stale_reviews_on_push,omitempty"`
+	RequireCodeOwnerReview         *bool `json:"require_code_owner_review,omitempty"`
+	RequireLastPushApproval        *bool `json:"require_last_push_approval,omitempty"`
+	RequiredReviewThreadResolution *bool `json:"required_review_thread_resolution,omitempty"`
+
+	RequiredStatusChecks             []RulesetStatusCheck `json:"required_status_checks,omitempty"`
+	StrictRequiredStatusChecksPolicy *bool                `json:"strict_required_status_checks_policy,omitempty"`
+
+	RequiredDeploymentEnvironments []string `json:"required_deployment_environments,omitempty"`
+}
+
+// RulesetStatusCheck is a status check required by a ruleset.
+type RulesetStatusCheck struct {
+	Context string `json:"context"`
+}
+
 // AllHookEvents causes github to send all events.
 // https://developer.github.com/v3/activity/events/types/
 var AllHookEvents = []string{"*"}
@@ -0,0 +1,309 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package adapter turns GitLab webhooks into ProwJobs.
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/gitlab"
+	"k8s.io/test-infra/prow/pjutil"
+)
+
+type prowJobClient interface {
+	Create(context.Context, *prowapi.ProwJob, metav1.CreateOptions) (*prowapi.ProwJob, error)
+}
+
+type gitlabClient interface {
+	BotUser() (*gitlab.User, error)
+	GetBranch(project, branch string) (*gitlab.Branch, error)
+	GetMergeRequestChanges(project string, iid int) ([]string, error)
+	IsTrusted(project string, userID int) (bool, error)
+	ListCommitStatuses(project, sha string) ([]gitlab.CommitStatus, error)
+	Instance() string
+}
+
+// Server triggers presubmits for merge request and note events and
+// postsubmits for push events of GitLab projects.
+//
+// Jobs are configured under the path with namespace of their project,
+// like group/subgroup/project.
+type Server struct {
+	config        config.Getter
+	gc            gitlabClient
+	prowJobClient prowJobClient
+	secret        func() []byte
+
+	// wg tracks the events being handled.
+	wg sync.WaitGroup
+}
+
+// NewServer returns a webhook server authenticating webhooks with the secret.
+func NewServer(cfg config.Getter, gc gitlabClient, prowJobClient prowJobClient, secret func() []byte) *Server {
+	return &Server{
+		config:        cfg,
+		gc:            gc,
+		prowJobClient: prowJobClient,
+		secret:        secret,
+	}
+}
+
+// ServeHTTP validates a webhook and handles it in the background.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	eventType, payload, ok := gitlab.ValidateWebhook(w, r, []byte(strings.TrimSpace(string(s.secret()))))
+	if !ok {
+		return
+	}
+	fmt.Fprint(w, "Event received. Have a nice day.")
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		log := logrus.WithField("event-type", eventType)
+		if err := s.handle(log, eventType, payload); err != nil {
+			log.WithError(err).Error("Error handling event.")
+		}
+	}()
+}
+
+// GracefulShutdown waits for the events being handled.
+func (s *Server) GracefulShutdown() {
+	s.wg.Wait()
+}
+
+func (s *Server) handle(log *logrus.Entry, eventType string, payload []byte) error {
+	switch eventType {
+	case gitlab.MergeRequestHook:
+		var mre gitlab.MergeRequestEvent
+		if err := json.Unmarshal(payload, &mre); err != nil {
+			return fmt.Errorf("unmarshal merge request event: %w", err)
+		}
+		return s.handleMergeRequest(log, mre)
+	case gitlab.NoteHook:
+		var ne gitlab.NoteEvent
+		if err := json.Unmarshal(payload, &ne); err != nil {
+			return fmt.Errorf("unmarshal note event: %w", err)
+		}
+		return s.handleNote(log, ne)
+	case gitlab.PushHook:
+		var pe gitlab.PushEvent
+		if err := json.Unmarshal(payload, &pe); err != nil {
+			return fmt.Errorf("unmarshal push event: %w", err)
+		}
+		return s.handlePush(log, pe)
+	default:
+		log.Debug("Ignoring unhandled event type.")
+		return nil
+	}
+}
+
+func (s *Server) handleMergeRequest(log *logrus.Entry, mre gitlab.MergeRequestEvent) error {
+	mr := mre.ObjectAttributes
+	log = log.WithFields(logrus.Fields{"project": mre.Project.PathWithNamespace, "mr": mr.IID, "action": mr.Action})
+	if mr.State != gitlab.MergeRequestStateOpened {
+		return nil
+	}
+	switch {
+	case mr.Action == gitlab.MergeRequestActionOpen, mr.Action == gitlab.MergeRequestActionReopen:
+	case mr.Action == gitlab.MergeRequestActionUpdate && mr.OldRev != "":
+		// New commits were pushed.
+	default:
+		return nil
+	}
+	trusted, err := s.gc.IsTrusted(mre.Project.PathWithNamespace, mr.AuthorID)
+	if err != nil {
+		return fmt.Errorf("check if author %d is trusted: %w", mr.AuthorID, err)
+	}
+	if !trusted {
+		log.Info("Not testing merge request of untrusted author, a project member may comment /ok-to-test.")
+		return nil
+	}
+	return s.triggerPresubmits(log, mre.Project, mr, pjutil.TestAllFilter())
+}
+
+func (s *Server) handleNote(log *logrus.Entry, ne gitlab.NoteEvent) error {
+	if ne.ObjectAttributes.NoteableType != gitlab.NoteableMergeRequest || ne.MergeRequest == nil {
+		return nil
+	}
+	mr := *ne.MergeRequest
+	body := ne.ObjectAttributes.Note
+	project := ne.Project.PathWithNamespace
+	log = log.WithFields(logrus.Fields{"project": project, "mr": mr.IID, "user": ne.User.Username})
+	if mr.State != gitlab.MergeRequestStateOpened {
+		return nil
+	}
+	if !isCommand(body, s.config().PresubmitsStatic[project]) {
+		return nil
+	}
+	bot, err := s.gc.BotUser()
+	if err != nil {
+		return fmt.Errorf("get bot user: %w", err)
+	}
+	if ne.User.ID == bot.ID {
+		return nil
+	}
+	trusted, err := s.gc.IsTrusted(project, ne.User.ID)
+	if err != nil {
+		return fmt.Errorf("check if %s is trusted: %w", ne.User.Username, err)
+	}
+	if !trusted {
+		log.Info("Ignoring command of untrusted user.")
+		return nil
+	}
+
+	contextGetter := func() (sets.String, sets.String, error) {
+		statuses, err := s.gc.ListCommitStatuses(project, mr.LastCommit.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		failed, all := sets.NewString(), sets.NewString()
+		for _, status := range statuses {
+			if status.Author.ID != bot.ID {
+				continue
+			}
+			all.Insert(status.Name)
+			if status.Status == gitlab.StatusFailed || status.Status == gitlab.StatusCanceled {
+				failed.Insert(status.Name)
+			}
+		}
+		return failed, all, nil
+	}
+	filter, err := pjutil.PresubmitFilter(true, contextGetter, body, log)
+	if err != nil {
+		return fmt.Errorf("create presubmit filter: %w", err)
+	}
+	return s.triggerPresubmits(log, ne.Project, mr, filter)
+}
+
+// isCommand reports whether a note asks to run any presubmits.
+func isCommand(body string, presubmits []config.Presubmit) bool {
+	if pjutil.TestAllRe.MatchString(body) || pjutil.RetestRe.MatchString(body) || pjutil.OkToTestRe.MatchString(body) {
+		return true
+	}
+	for _, presubmit := range presubmits {
+		if presubmit.TriggerMatches(body) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handlePush(log *logrus.Entry, pe gitlab.PushEvent) error {
+	branch := pe.Branch()
+	log = log.WithFields(logrus.Fields{"project": pe.Project.PathWithNamespace, "ref": pe.Ref})
+	if branch == "" || pe.After == gitlab.ZeroSHA {
+		return nil
+	}
+	refs := s.baseRefs(pe.Project, branch, pe.After)
+	changes := func() ([]string, error) { return pe.ChangedFiles(), nil }
+
+	var specs []prowapi.ProwJobSpec
+	var labels []map[string]string
+	for _, postsubmit := range s.config().PostsubmitsStatic[pe.Project.PathWithNamespace] {
+		shouldRun, err := postsubmit.ShouldRun(branch, changes)
+		if err != nil {
+			return fmt.Errorf("determine if postsubmit %q should run: %w", postsubmit.Name, err)
+		}
+		if shouldRun {
+			specs = append(specs, pjutil.PostsubmitSpec(postsubmit, refs))
+			labels = append(labels, postsubmit.Labels)
+		}
+	}
+	return s.createJobs(log, pe.Project, specs, labels)
+}
+
+func (s *Server) triggerPresubmits(log *logrus.Entry, project gitlab.EventProject, mr gitlab.MergeRequest, filter pjutil.Filter) error {
+	path := project.PathWithNamespace
+	changes := func() ([]string, error) { return s.gc.GetMergeRequestChanges(path, mr.IID) }
+	toTrigger, err := pjutil.FilterPresubmits(filter, changes, mr.TargetBranch, s.config().PresubmitsStatic[path], log)
+	if err != nil {
+		return fmt.Errorf("filter presubmits: %w", err)
+	}
+	if len(toTrigger) == 0 {
+		return nil
+	}
+	branch, err := s.gc.GetBranch(path, mr.TargetBranch)
+	if err != nil {
+		return fmt.Errorf("get target branch %s: %w", mr.TargetBranch, err)
+	}
+	refs := s.baseRefs(project, mr.TargetBranch, branch.Commit.ID)
+	refs.Pulls = []prowapi.Pull{{
+		Number:     mr.IID,
+		Author:     mr.LastCommit.Author.Name,
+		SHA:        mr.LastCommit.ID,
+		Title:      mr.Title,
+		Ref:        gitlab.MergeRequestPullRef(mr.IID),
+		Link:       mr.URL,
+		CommitLink: fmt.Sprintf("%s/-/commit/%s", project.WebURL, mr.LastCommit.ID),
+	}}
+
+	var specs []prowapi.ProwJobSpec
+	var labels []map[string]string
+	for _, presubmit := range toTrigger {
+		specs = append(specs, pjutil.PresubmitSpec(presubmit, refs))
+		labels = append(labels, presubmit.Labels)
+	}
+	return s.createJobs(log, project, specs, labels)
+}
+
+// baseRefs returns the refs of a branch, cloned over http like clonerefs
+// does for any other CloneURI.
+func (s *Server) baseRefs(project gitlab.EventProject, branch, sha string) prowapi.Refs {
+	org, repo := gitlab.SplitProject(project.PathWithNamespace)
+	return prowapi.Refs{
+		Org:      org,
+		Repo:     repo,
+		RepoLink: project.WebURL,
+		BaseRef:  branch,
+		BaseSHA:  sha,
+		BaseLink: fmt.Sprintf("%s/-/commit/%s", project.WebURL, sha),
+		CloneURI: project.GitHTTPURL,
+	}
+}
+
+func (s *Server) createJobs(log *logrus.Entry, project gitlab.EventProject, specs []prowapi.ProwJobSpec, labels []map[string]string) error {
+	annotations := map[string]string{
+		gitlab.ProjectAnnotation:  project.PathWithNamespace,
+		gitlab.InstanceAnnotation: s.gc.Instance(),
+	}
+	var errs []string
+	for i, spec := range specs {
+		pj := pjutil.NewProwJob(spec, labels[i], annotations)
+		log := log.WithFields(pjutil.ProwJobFields(&pj))
+		if _, err := s.prowJobClient.Create(context.TODO(), &pj, metav1.CreateOptions{}); err != nil {
+			log.WithError(err).Error("Failed to create ProwJob.")
+			errs = append(errs, fmt.Sprintf("%s: %v", spec.Job, err))
+			continue
+		}
+		log.Info("Triggered new job.")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to create %d jobs: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
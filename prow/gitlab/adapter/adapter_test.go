@@ -0,0 +1,297 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/gitlab"
+	"k8s.io/test-infra/prow/gitlab/fakegitlab"
+)
+
+const (
+	project   = "group/subgroup/project"
+	trusted   = 7
+	untrusted = 8
+	secret    = "webhook-secret"
+)
+
+type fakeProwJobClient struct {
+	sync.Mutex
+	created []prowapi.ProwJob
+}
+
+func (f *fakeProwJobClient) Create(_ context.Context, pj *prowapi.ProwJob, _ metav1.CreateOptions) (*prowapi.ProwJob, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.created = append(f.created, *pj)
+	return pj, nil
+}
+
+func testConfig(t *testing.T) config.Getter {
+	presubmits := []config.Presubmit{
+		{
+			JobBase:   config.JobBase{Name: "unit"},
+			AlwaysRun: true,
+			Reporter:  config.Reporter{Context: "unit"},
+		},
+		{
+			JobBase:             config.JobBase{Name: "docs"},
+			RegexpChangeMatcher: config.RegexpChangeMatcher{RunIfChanged: `^docs/`},
+			Reporter:            config.Reporter{Context: "docs"},
+		},
+		{
+			JobBase:  config.JobBase{Name: "e2e"},
+			Reporter: config.Reporter{Context: "e2e"},
+		},
+	}
+	for i := range presubmits {
+		presubmits[i].Trigger = config.DefaultTriggerFor(presubmits[i].Name)
+		presubmits[i].RerunCommand = config.DefaultRerunCommandFor(presubmits[i].Name)
+	}
+	if err := config.SetPresubmitRegexes(presubmits); err != nil {
+		t.Fatalf("SetPresubmitRegexes: %v", err)
+	}
+	postsubmits := []config.Postsubmit{
+		{
+			JobBase:  config.JobBase{Name: "publish"},
+			Brancher: config.Brancher{Branches: []string{"main"}},
+		},
+	}
+	if err := config.SetPostsubmitRegexes(postsubmits); err != nil {
+		t.Fatalf("SetPostsubmitRegexes: %v", err)
+	}
+	cfg := &config.Config{JobConfig: config.JobConfig{
+		PresubmitsStatic:  map[string][]config.Presubmit{project: presubmits},
+		PostsubmitsStatic: map[string][]config.Postsubmit{project: postsubmits},
+	}}
+	return func() *config.Config { return cfg }
+}
+
+var eventProject = gitlab.EventProject{
+	PathWithNamespace: project,
+	WebURL:            "https://gitlab.example.com/" + project,
+	GitHTTPURL:        "https://gitlab.example.com/" + project + ".git",
+}
+
+func mergeRequest(author int, action string) gitlab.MergeRequest {
+	mr := gitlab.MergeRequest{
+		IID:          3,
+		AuthorID:     author,
+		Title:        "Fix things",
+		TargetBranch: "main",
+		State:        gitlab.MergeRequestStateOpened,
+		URL:          "https://gitlab.example.com/" + project + "/-/merge_requests/3",
+		LastCommit:   gitlab.EventCommit{ID: "head"},
+		Action:       action,
+	}
+	mr.LastCommit.Author.Name = "Alice"
+	return mr
+}
+
+func TestServer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		event    string
+		payload  interface{}
+		token    string
+		code     int
+		expected []string
+	}{
+		{
+			name:     "opening a merge request of a member runs the presubmits that should run",
+			event:    gitlab.MergeRequestHook,
+			payload:  gitlab.MergeRequestEvent{Project: eventProject, ObjectAttributes: mergeRequest(trusted, gitlab.MergeRequestActionOpen)},
+			expected: []string{"docs", "unit"},
+		},
+		{
+			name:    "opening a merge request of an outsider runs nothing",
+			event:   gitlab.MergeRequestHook,
+			payload: gitlab.MergeRequestEvent{Project: eventProject, ObjectAttributes: mergeRequest(untrusted, gitlab.MergeRequestActionOpen)},
+		},
+		{
+			name:    "updating a merge request without new commits runs nothing",
+			event:   gitlab.MergeRequestHook,
+			payload: gitlab.MergeRequestEvent{Project: eventProject, ObjectAttributes: mergeRequest(trusted, gitlab.MergeRequestActionUpdate)},
+		},
+		{
+			name:  "pushing to a merge request runs the presubmits again",
+			event: gitlab.MergeRequestHook,
+			payload: func() gitlab.MergeRequestEvent {
+				mr := mergeRequest(trusted, gitlab.MergeRequestActionUpdate)
+				mr.OldRev = "old"
+				return gitlab.MergeRequestEvent{Project: eventProject, ObjectAttributes: mr}
+			}(),
+			expected: []string{"docs", "unit"},
+		},
+		{
+			name:  "/test by a member runs the job",
+			event: gitlab.NoteHook,
+			payload: gitlab.NoteEvent{
+				User:             gitlab.EventUser{ID: trusted},
+				Project:          eventProject,
+				ObjectAttributes: gitlab.NoteAttributes{Note: "/test e2e", NoteableType: gitlab.NoteableMergeRequest},
+				MergeRequest:     func() *gitlab.MergeRequest { mr := mergeRequest(untrusted, ""); return &mr }(),
+			},
+			expected: []string{"e2e"},
+		},
+		{
+			name:  "/retest by a member reruns failed jobs",
+			event: gitlab.NoteHook,
+			payload: gitlab.NoteEvent{
+				User:             gitlab.EventUser{ID: trusted},
+				Project:          eventProject,
+				ObjectAttributes: gitlab.NoteAttributes{Note: "/retest", NoteableType: gitlab.NoteableMergeRequest},
+				MergeRequest:     func() *gitlab.MergeRequest { mr := mergeRequest(trusted, ""); return &mr }(),
+			},
+			expected: []string{"docs"},
+		},
+		{
+			name:  "/ok-to-test by a member runs the presubmits of an outsider",
+			event: gitlab.NoteHook,
+			payload: gitlab.NoteEvent{
+				User:             gitlab.EventUser{ID: trusted},
+				Project:          eventProject,
+				ObjectAttributes: gitlab.NoteAttributes{Note: "/ok-to-test", NoteableType: gitlab.NoteableMergeRequest},
+				MergeRequest:     func() *gitlab.MergeRequest { mr := mergeRequest(untrusted, ""); return &mr }(),
+			},
+			expected: []string{"docs", "unit"},
+		},
+		{
+			name:  "/test by an outsider runs nothing",
+			event: gitlab.NoteHook,
+			payload: gitlab.NoteEvent{
+				User:             gitlab.EventUser{ID: untrusted},
+				Project:          eventProject,
+				ObjectAttributes: gitlab.NoteAttributes{Note: "/test all", NoteableType: gitlab.NoteableMergeRequest},
+				MergeRequest:     func() *gitlab.MergeRequest { mr := mergeRequest(untrusted, ""); return &mr }(),
+			},
+		},
+		{
+			name:  "pushing to a branch runs the postsubmits",
+			event: gitlab.PushHook,
+			payload: gitlab.PushEvent{
+				Ref:     "refs/heads/main",
+				After:   "pushed",
+				Project: eventProject,
+			},
+			expected: []string{"publish"},
+		},
+		{
+			name:  "deleting a branch runs nothing",
+			event: gitlab.PushHook,
+			payload: gitlab.PushEvent{
+				Ref:     "refs/heads/main",
+				After:   gitlab.ZeroSHA,
+				Project: eventProject,
+			},
+		},
+		{
+			name:    "webhooks with the wrong secret are rejected",
+			event:   gitlab.PushHook,
+			payload: gitlab.PushEvent{Ref: "refs/heads/main", After: "pushed", Project: eventProject},
+			token:   "guess",
+			code:    http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := fakegitlab.NewServer()
+			fake.Branches[project] = map[string]string{"main": "base"}
+			fake.Changes[project] = map[int][]string{3: {"docs/README.md"}}
+			fake.Members[project] = map[int]int{trusted: gitlab.DeveloperAccess, untrusted: 10}
+			fake.Statuses[project] = map[string][]gitlab.CommitStatus{"head": {
+				{Name: "unit", Status: gitlab.StatusSuccess, Author: fake.Bot},
+				{Name: "docs", Status: gitlab.StatusFailed, Author: fake.Bot},
+				{Name: "e2e", Status: gitlab.StatusFailed, Author: gitlab.User{ID: 99}},
+			}}
+			gitlabServer := httptest.NewServer(fake)
+			defer gitlabServer.Close()
+			gc, err := gitlab.NewClient(gitlabServer.URL, nil, false)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+
+			pjc := &fakeProwJobClient{}
+			s := NewServer(testConfig(t), gc, pjc, func() []byte { return []byte(secret + "\n") })
+
+			payload, err := json.Marshal(tc.payload)
+			if err != nil {
+				t.Fatalf("marshal payload: %v", err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(payload))
+			r.Header.Set(gitlab.EventHeader, tc.event)
+			token := tc.token
+			if token == "" {
+				token = secret
+			}
+			r.Header.Set(gitlab.TokenHeader, token)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			s.GracefulShutdown()
+
+			if code := tc.code; code == 0 && w.Code != http.StatusOK || code != 0 && w.Code != code {
+				t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+			}
+			var jobs []string
+			for _, pj := range pjc.created {
+				jobs = append(jobs, pj.Spec.Job)
+				if got := pj.Annotations[gitlab.ProjectAnnotation]; got != project {
+					t.Errorf("%s: project annotation %q, want %q", pj.Spec.Job, got, project)
+				}
+				if got := pj.Annotations[gitlab.InstanceAnnotation]; got != gitlabServer.URL {
+					t.Errorf("%s: instance annotation %q, want %q", pj.Spec.Job, got, gitlabServer.URL)
+				}
+				refs := pj.Spec.Refs
+				if refs.Org != "group/subgroup" || refs.Repo != "project" || refs.CloneURI != eventProject.GitHTTPURL {
+					t.Errorf("%s: wrong refs %#v", pj.Spec.Job, refs)
+				}
+				if pj.Spec.Type == prowapi.PresubmitJob {
+					want := []prowapi.Pull{{
+						Number:     3,
+						Author:     "Alice",
+						SHA:        "head",
+						Title:      "Fix things",
+						Ref:        "refs/merge-requests/3/head",
+						Link:       "https://gitlab.example.com/" + project + "/-/merge_requests/3",
+						CommitLink: eventProject.WebURL + "/-/commit/head",
+					}}
+					if refs.BaseSHA != "base" || !reflect.DeepEqual(refs.Pulls, want) {
+						t.Errorf("%s: wrong base %s or pulls %#v", pj.Spec.Job, refs.BaseSHA, refs.Pulls)
+					}
+				}
+			}
+			sort.Strings(jobs)
+			if !reflect.DeepEqual(jobs, tc.expected) {
+				t.Errorf("triggered %v, want %v", jobs, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gitlab implements a client for the GitLab v4 REST API along with
+// the webhook events prow reacts to.
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ProjectAnnotation is the path with namespace of the GitLab project a job tests.
+	ProjectAnnotation = "prow.k8s.io/gitlab-project"
+	// InstanceAnnotation is the URL of the GitLab instance hosting the project.
+	InstanceAnnotation = "prow.k8s.io/gitlab-instance"
+
+	// DeveloperAccess is the lowest access level allowed to push to a project,
+	// which is what prow requires to trust a user.
+	DeveloperAccess = 30
+
+	// MergeRequestRef is the ref every merge request head is fetchable from,
+	// including merge requests from forks.
+	MergeRequestRef = "refs/merge-requests/%d/head"
+)
+
+// Commit status states.
+const (
+	StatusPending  = "pending"
+	StatusRunning  = "running"
+	StatusSuccess  = "success"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// Client interacts with a GitLab instance.
+type Client interface {
+	BotUser() (*User, error)
+	GetBranch(project, branch string) (*Branch, error)
+	GetMergeRequestChanges(project string, iid int) ([]string, error)
+	IsTrusted(project string, userID int) (bool, error)
+	ListCommitStatuses(project, sha string) ([]CommitStatus, error)
+	SetCommitStatus(project, sha string, status CommitStatus) error
+	ListMergeRequestNotes(project string, iid int) ([]Note, error)
+	CreateMergeRequestNote(project string, iid int, body string) error
+	EditMergeRequestNote(project string, iid, noteID int, body string) error
+	DeleteMergeRequestNote(project string, iid, noteID int) error
+	// Instance is the URL of the GitLab instance, like https://gitlab.example.com.
+	Instance() string
+}
+
+// User is a GitLab user.
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	WebURL   string `json:"web_url,omitempty"`
+}
+
+// Branch is a branch of a project.
+type Branch struct {
+	Name   string `json:"name"`
+	Commit Commit `json:"commit"`
+}
+
+// Commit is a commit of a project.
+type Commit struct {
+	ID     string `json:"id"`
+	WebURL string `json:"web_url,omitempty"`
+}
+
+// CommitStatus is the status of an external job against a commit.
+type CommitStatus struct {
+	// Name is the context of the status, unique per commit.
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Ref         string `json:"ref,omitempty"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      User   `json:"author,omitempty"`
+}
+
+// Note is a comment on a merge request.
+type Note struct {
+	ID     int    `json:"id"`
+	Body   string `json:"body"`
+	Author User   `json:"author"`
+	System bool   `json:"system,omitempty"`
+}
+
+// StatusError is returned for responses other than 2XX.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.Code, e.Body)
+}
+
+// IsNotFound reports whether the error is a 404 from GitLab.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
+
+type client struct {
+	instance string
+	api      string
+	token    func() []byte
+	dryRun   bool
+	http     *http.Client
+	logger   *logrus.Entry
+
+	botLock sync.Mutex
+	bot     *User
+}
+
+// NewClient returns a client for the GitLab instance authenticating with
+// the personal, group or project access token returned by the generator.
+//
+// In dry-run mode, the client only logs what it would have changed.
+func NewClient(instance string, token func() []byte, dryRun bool) (Client, error) {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, fmt.Errorf("parse %q: %w", instance, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", instance)
+	}
+	instance = strings.TrimSuffix(u.String(), "/")
+	logger := logrus.WithFields(logrus.Fields{"client": "gitlab", "instance": instance})
+	retrying := retryablehttp.NewClient()
+	retrying.Logger = nil
+	return &client{
+		instance: instance,
+		api:      instance + "/api/v4",
+		token:    token,
+		dryRun:   dryRun,
+		http:     retrying.StandardClient(),
+		logger:   logger,
+	}, nil
+}
+
+func (c *client) Instance() string {
+	return c.instance
+}
+
+// projectPath returns the API path of a project, identified by its path with namespace.
+func projectPath(project string) string {
+	return "/projects/" + url.PathEscape(project)
+}
+
+func (c *client) do(method, path string, in, out interface{}) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.api+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != nil {
+		req.Header.Set("PRIVATE-TOKEN", strings.TrimSpace(string(c.token())))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: %w", method, path, &StatusError{Code: resp.StatusCode, Body: string(b)})
+	}
+	if out != nil && len(b) > 0 {
+		if err := json.Unmarshal(b, out); err != nil {
+			return nil, fmt.Errorf("%s %s: unmarshal response: %w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// mutate is like do, but does nothing beyond logging in dry-run mode.
+func (c *client) mutate(method, path string, in interface{}) error {
+	if c.dryRun {
+		c.logger.WithFields(logrus.Fields{"method": method, "path": path}).Info("Dry run: skipping request.")
+		return nil
+	}
+	_, err := c.do(method, path, in, nil)
+	return err
+}
+
+// list reads every page of a list, calling add on each one.
+func (c *client) list(path string, add func([]byte) error) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	page := "1"
+	for page != "" {
+		var raw json.RawMessage
+		header, err := c.do(http.MethodGet, path+sep+"per_page=100&page="+page, nil, &raw)
+		if err != nil {
+			return err
+		}
+		if err := add(raw); err != nil {
+			return err
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return nil
+}
+
+func (c *client) BotUser() (*User, error) {
+	c.botLock.Lock()
+	defer c.botLock.Unlock()
+	if c.bot != nil {
+		return c.bot, nil
+	}
+	var user User
+	if _, err := c.do(http.MethodGet, "/user", nil, &user); err != nil {
+		return nil, err
+	}
+	c.bot = &user
+	return c.bot, nil
+}
+
+func (c *client) GetBranch(project, branch string) (*Branch, error) {
+	var b Branch
+	if _, err := c.do(http.MethodGet, projectPath(project)+"/repository/branches/"+url.PathEscape(branch), nil, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (c *client) GetMergeRequestChanges(project string, iid int) ([]string, error) {
+	var mr struct {
+		Changes []struct {
+			OldPath string `json:"old_path"`
+			NewPath string `json:"new_path"`
+		} `json:"changes"`
+	}
+	if _, err := c.do(http.MethodGet, fmt.Sprintf("%s/merge_requests/%d/changes", projectPath(project), iid), nil, &mr); err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, change := range mr.Changes {
+		files = append(files, change.NewPath)
+		if change.OldPath != change.NewPath {
+			files = append(files, change.OldPath)
+		}
+	}
+	return files, nil
+}
+
+func (c *client) IsTrusted(project string, userID int) (bool, error) {
+	var member struct {
+		AccessLevel int `json:"access_level"`
+	}
+	_, err := c.do(http.MethodGet, fmt.Sprintf("%s/members/all/%d", projectPath(project), userID), nil, &member)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return member.AccessLevel >= DeveloperAccess, nil
+}
+
+func (c *client) ListCommitStatuses(project, sha string) ([]CommitStatus, error) {
+	var statuses []CommitStatus
+	err := c.list(fmt.Sprintf("%s/repository/commits/%s/statuses?all=true", projectPath(project), sha), func(b []byte) error {
+		var page []CommitStatus
+		if err := json.Unmarshal(b, &page); err != nil {
+			return err
+		}
+		statuses = append(statuses, page...)
+		return nil
+	})
+	return statuses, err
+}
+
+func (c *client) SetCommitStatus(project, sha string, status CommitStatus) error {
+	in := map[string]string{
+		"state":       status.Status,
+		"name":        status.Name,
+		"target_url":  status.TargetURL,
+		"description": status.Description,
+	}
+	if status.Ref != "" {
+		in["ref"] = status.Ref
+	}
+	err := c.mutate(http.MethodPost, fmt.Sprintf("%s/statuses/%s", projectPath(project), sha), in)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusBadRequest && strings.Contains(statusErr.Body, "Cannot transition status") {
+		// GitLab refuses to set a status to the state it already has.
+		return nil
+	}
+	return err
+}
+
+func (c *client) ListMergeRequestNotes(project string, iid int) ([]Note, error) {
+	var notes []Note
+	err := c.list(fmt.Sprintf("%s/merge_requests/%d/notes?sort=asc&order_by=created_at", projectPath(project), iid), func(b []byte) error {
+		var page []Note
+		if err := json.Unmarshal(b, &page); err != nil {
+			return err
+		}
+		notes = append(notes, page...)
+		return nil
+	})
+	return notes, err
+}
+
+func (c *client) CreateMergeRequestNote(project string, iid int, body string) error {
+	return c.mutate(http.MethodPost, fmt.Sprintf("%s/merge_requests/%d/notes", projectPath(project), iid), map[string]string{"body": body})
+}
+
+func (c *client) EditMergeRequestNote(project string, iid, noteID int, body string) error {
+	return c.mutate(http.MethodPut, fmt.Sprintf("%s/merge_requests/%d/notes/%d", projectPath(project), iid, noteID), map[string]string{"body": body})
+}
+
+func (c *client) DeleteMergeRequestNote(project string, iid, noteID int) error {
+	return c.mutate(http.MethodDelete, fmt.Sprintf("%s/merge_requests/%d/notes/%d", projectPath(project), iid, noteID), nil)
+}
+
+// SplitProject splits the path with namespace of a project into the org and
+// repo prow uses for it, namely the namespace and the path of the project.
+func SplitProject(project string) (org, repo string) {
+	i := strings.LastIndex(project, "/")
+	if i < 0 {
+		return "", project
+	}
+	return project[:i], project[i+1:]
+}
+
+// Project joins an org and repo back into the path with namespace of a project.
+func Project(org, repo string) string {
+	if org == "" {
+		return repo
+	}
+	return org + "/" + repo
+}
+
+// MergeRequestPullRef returns the ref of the head of a merge request.
+func MergeRequestPullRef(iid int) string {
+	return fmt.Sprintf(MergeRequestRef, iid)
+}
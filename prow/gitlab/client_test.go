@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/test-infra/prow/gitlab"
+	"k8s.io/test-infra/prow/gitlab/fakegitlab"
+)
+
+const project = "group/subgroup/project"
+
+func newClient(t *testing.T, fake *fakegitlab.Server, dryRun bool) gitlab.Client {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	c, err := gitlab.NewClient(server.URL+"/", func() []byte { return []byte("secret\n") }, dryRun)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	fake := fakegitlab.NewServer()
+	fake.Token = "secret"
+	fake.Branches[project] = map[string]string{"main": "abc"}
+	fake.Changes[project] = map[int][]string{3: {"a.go", "b/c.go"}}
+	fake.Members[project] = map[int]int{7: gitlab.DeveloperAccess, 8: 20}
+	c := newClient(t, fake, false)
+
+	if got := c.Instance(); strings.HasSuffix(got, "/") {
+		t.Errorf("instance %q has a trailing slash", got)
+	}
+	bot, err := c.BotUser()
+	if err != nil || bot.Username != "prow-bot" {
+		t.Errorf("BotUser() = %v, %v", bot, err)
+	}
+	branch, err := c.GetBranch(project, "main")
+	if err != nil || branch.Commit.ID != "abc" {
+		t.Errorf("GetBranch() = %v, %v", branch, err)
+	}
+	if _, err := c.GetBranch(project, "missing"); !gitlab.IsNotFound(err) {
+		t.Errorf("expected not found for missing branch, got %v", err)
+	}
+	files, err := c.GetMergeRequestChanges(project, 3)
+	if err != nil || !reflect.DeepEqual(files, []string{"a.go", "b/c.go"}) {
+		t.Errorf("GetMergeRequestChanges() = %v, %v", files, err)
+	}
+	for id, want := range map[int]bool{7: true, 8: false, 9: false} {
+		if got, err := c.IsTrusted(project, id); err != nil || got != want {
+			t.Errorf("IsTrusted(%d) = %t, %v, want %t", id, got, err, want)
+		}
+	}
+
+	status := gitlab.CommitStatus{Name: "unit", Status: gitlab.StatusRunning, TargetURL: "https://prow/unit"}
+	if err := c.SetCommitStatus(project, "def", status); err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+	status.Status = gitlab.StatusFailed
+	if err := c.SetCommitStatus(project, "def", status); err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+	statuses, err := c.ListCommitStatuses(project, "def")
+	if err != nil || len(statuses) != 1 || statuses[0].Status != gitlab.StatusFailed || statuses[0].Author.ID != bot.ID {
+		t.Errorf("ListCommitStatuses() = %v, %v", statuses, err)
+	}
+
+	for _, body := range []string{"first", "second"} {
+		if err := c.CreateMergeRequestNote(project, 3, body); err != nil {
+			t.Fatalf("CreateMergeRequestNote: %v", err)
+		}
+	}
+	notes, err := c.ListMergeRequestNotes(project, 3)
+	if err != nil || len(notes) != 2 {
+		t.Fatalf("ListMergeRequestNotes() = %v, %v", notes, err)
+	}
+	if err := c.EditMergeRequestNote(project, 3, notes[0].ID, "edited"); err != nil {
+		t.Fatalf("EditMergeRequestNote: %v", err)
+	}
+	if err := c.DeleteMergeRequestNote(project, 3, notes[1].ID); err != nil {
+		t.Fatalf("DeleteMergeRequestNote: %v", err)
+	}
+	if got, want := fake.NoteBodies(project, 3), []string{"edited"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notes = %v, want %v", got, want)
+	}
+}
+
+func TestClientDryRun(t *testing.T) {
+	fake := fakegitlab.NewServer()
+	c := newClient(t, fake, true)
+	if err := c.CreateMergeRequestNote(project, 1, "hello"); err != nil {
+		t.Fatalf("CreateMergeRequestNote: %v", err)
+	}
+	if err := c.SetCommitStatus(project, "abc", gitlab.CommitStatus{Name: "unit", Status: gitlab.StatusPending}); err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+	if notes := fake.NoteBodies(project, 1); len(notes) != 0 {
+		t.Errorf("dry run created notes: %v", notes)
+	}
+	if statuses := fake.Statuses[project]; len(statuses) != 0 {
+		t.Errorf("dry run set statuses: %v", statuses)
+	}
+}
+
+func TestSplitProject(t *testing.T) {
+	for project, want := range map[string][2]string{
+		"group/subgroup/project": {"group/subgroup", "project"},
+		"group/project":          {"group", "project"},
+		"project":                {"", "project"},
+	} {
+		org, repo := gitlab.SplitProject(project)
+		if org != want[0] || repo != want[1] {
+			t.Errorf("SplitProject(%q) = %q, %q, want %q", project, org, repo, want)
+		}
+		if got := gitlab.Project(org, repo); got != project {
+			t.Errorf("Project(%q, %q) = %q, want %q", org, repo, got, project)
+		}
+	}
+}
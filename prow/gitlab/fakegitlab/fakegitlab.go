@@ -0,0 +1,247 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakegitlab serves the parts of the GitLab v4 API prow uses from
+// memory, for tests and for integration testing like fakeghserver.
+package fakegitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/test-infra/prow/gitlab"
+)
+
+// Server is an in-memory GitLab instance.
+//
+// Projects are keyed by their path with namespace.
+type Server struct {
+	sync.Mutex
+
+	// Token is the access token requests must use, if set.
+	Token string
+	Bot   gitlab.User
+
+	// Branches maps projects to the SHAs of their branches.
+	Branches map[string]map[string]string
+	// Changes maps projects to the files changed by their merge requests.
+	Changes map[string]map[int][]string
+	// Members maps projects to the access levels of their members.
+	Members map[string]map[int]int
+	// Statuses maps projects to the statuses of their commits.
+	Statuses map[string]map[string][]gitlab.CommitStatus
+	// Notes maps projects to the notes on their merge requests.
+	Notes map[string]map[int][]gitlab.Note
+
+	nextNoteID int
+}
+
+// NewServer returns an empty fake instance.
+func NewServer() *Server {
+	return &Server{
+		Bot:      gitlab.User{ID: 1, Username: "prow-bot", Name: "Prow Bot"},
+		Branches: map[string]map[string]string{},
+		Changes:  map[string]map[int][]string{},
+		Members:  map[string]map[int]int{},
+		Statuses: map[string]map[string][]gitlab.CommitStatus{},
+		Notes:    map[string]map[int][]gitlab.Note{},
+	}
+}
+
+// NoteBodies returns the bodies of the notes on a merge request.
+func (s *Server) NoteBodies(project string, iid int) []string {
+	s.Lock()
+	defer s.Unlock()
+	var bodies []string
+	for _, note := range s.Notes[project][iid] {
+		bodies = append(bodies, note.Body)
+	}
+	return bodies
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && r.Header.Get("PRIVATE-TOKEN") != s.Token {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/"), "/")
+	for i := range parts {
+		part, err := url.PathUnescape(parts[i])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts[i] = part
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	out, code := s.serve(r, parts)
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if msg, ok := out.(error); ok {
+		http.Error(w, fmt.Sprintf(`{"message":%q}`, msg.Error()), code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if out != nil {
+		json.NewEncoder(w).Encode(out)
+	}
+}
+
+func (s *Server) serve(r *http.Request, parts []string) (interface{}, int) {
+	route := func(method string, pattern ...string) bool {
+		if r.Method != method || len(parts) != len(pattern) {
+			return false
+		}
+		for i, p := range pattern {
+			if p != "*" && p != parts[i] {
+				return false
+			}
+		}
+		return true
+	}
+	notFound := fmt.Errorf("404 %s Not Found", r.URL.Path)
+
+	switch {
+	case route(http.MethodGet, "user"):
+		return s.Bot, 0
+
+	case route(http.MethodGet, "projects", "*", "repository", "branches", "*"):
+		sha, ok := s.Branches[parts[1]][parts[4]]
+		if !ok {
+			return notFound, http.StatusNotFound
+		}
+		return gitlab.Branch{Name: parts[4], Commit: gitlab.Commit{ID: sha}}, 0
+
+	case route(http.MethodGet, "projects", "*", "merge_requests", "*", "changes"):
+		iid, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		type change struct {
+			OldPath string `json:"old_path"`
+			NewPath string `json:"new_path"`
+		}
+		var changes []change
+		for _, file := range s.Changes[parts[1]][iid] {
+			changes = append(changes, change{OldPath: file, NewPath: file})
+		}
+		return map[string]interface{}{"iid": iid, "changes": changes}, 0
+
+	case route(http.MethodGet, "projects", "*", "members", "all", "*"):
+		id, err := strconv.Atoi(parts[4])
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		level, ok := s.Members[parts[1]][id]
+		if !ok {
+			return notFound, http.StatusNotFound
+		}
+		return map[string]int{"id": id, "access_level": level}, 0
+
+	case route(http.MethodGet, "projects", "*", "repository", "commits", "*", "statuses"):
+		return s.Statuses[parts[1]][parts[4]], 0
+
+	case route(http.MethodPost, "projects", "*", "statuses", "*"):
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return err, http.StatusBadRequest
+		}
+		project, sha := parts[1], parts[3]
+		status := gitlab.CommitStatus{
+			Name:        in["name"],
+			Status:      in["state"],
+			Ref:         in["ref"],
+			TargetURL:   in["target_url"],
+			Description: in["description"],
+			Author:      s.Bot,
+		}
+		if s.Statuses[project] == nil {
+			s.Statuses[project] = map[string][]gitlab.CommitStatus{}
+		}
+		statuses := s.Statuses[project][sha]
+		for i := range statuses {
+			if statuses[i].Name == status.Name {
+				statuses[i] = status
+				return status, http.StatusCreated
+			}
+		}
+		s.Statuses[project][sha] = append(statuses, status)
+		return status, http.StatusCreated
+
+	case route(http.MethodGet, "projects", "*", "merge_requests", "*", "notes"):
+		iid, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		return s.Notes[parts[1]][iid], 0
+
+	case route(http.MethodPost, "projects", "*", "merge_requests", "*", "notes"):
+		iid, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return err, http.StatusBadRequest
+		}
+		s.nextNoteID++
+		note := gitlab.Note{ID: s.nextNoteID, Body: in["body"], Author: s.Bot}
+		if s.Notes[parts[1]] == nil {
+			s.Notes[parts[1]] = map[int][]gitlab.Note{}
+		}
+		s.Notes[parts[1]][iid] = append(s.Notes[parts[1]][iid], note)
+		return note, http.StatusCreated
+
+	case route(http.MethodPut, "projects", "*", "merge_requests", "*", "notes", "*"),
+		route(http.MethodDelete, "projects", "*", "merge_requests", "*", "notes", "*"):
+		iid, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		id, err := strconv.Atoi(parts[5])
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		notes := s.Notes[parts[1]][iid]
+		for i := range notes {
+			if notes[i].ID != id {
+				continue
+			}
+			if r.Method == http.MethodDelete {
+				s.Notes[parts[1]][iid] = append(notes[:i], notes[i+1:]...)
+				return nil, http.StatusNoContent
+			}
+			var in map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				return err, http.StatusBadRequest
+			}
+			notes[i].Body = in["body"]
+			return notes[i], 0
+		}
+		return notFound, http.StatusNotFound
+	}
+	return fmt.Errorf("%s %s is not supported", r.Method, r.URL.Path), http.StatusNotFound
+}
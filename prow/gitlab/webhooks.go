@@ -0,0 +1,190 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Headers and values of the webhooks GitLab sends.
+const (
+	EventHeader = "X-Gitlab-Event"
+	TokenHeader = "X-Gitlab-Token"
+
+	MergeRequestHook = "Merge Request Hook"
+	NoteHook         = "Note Hook"
+	PushHook         = "Push Hook"
+)
+
+// Merge request actions of merge request events.
+const (
+	MergeRequestActionOpen   = "open"
+	MergeRequestActionReopen = "reopen"
+	MergeRequestActionUpdate = "update"
+	MergeRequestActionClose  = "close"
+	MergeRequestActionMerge  = "merge"
+)
+
+// MergeRequestStateOpened is the state of merge requests that are open.
+const MergeRequestStateOpened = "opened"
+
+// NoteableMergeRequest is the noteable type of notes on merge requests.
+const NoteableMergeRequest = "MergeRequest"
+
+// ZeroSHA is the before or after SHA of pushes creating or deleting a branch.
+const ZeroSHA = "0000000000000000000000000000000000000000"
+
+// EventUser is the user who caused an event.
+type EventUser struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+// EventProject is the project an event happened in.
+type EventProject struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+	GitHTTPURL        string `json:"git_http_url"`
+	DefaultBranch     string `json:"default_branch"`
+}
+
+// EventCommit is a commit in an event.
+type EventCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Author  struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+	Added    []string `json:"added,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+}
+
+// MergeRequest is the merge request of an event.
+type MergeRequest struct {
+	ID           int         `json:"id"`
+	IID          int         `json:"iid"`
+	AuthorID     int         `json:"author_id"`
+	Title        string      `json:"title"`
+	TargetBranch string      `json:"target_branch"`
+	SourceBranch string      `json:"source_branch"`
+	State        string      `json:"state"`
+	URL          string      `json:"url"`
+	LastCommit   EventCommit `json:"last_commit"`
+	// Action and OldRev are only set for merge request events, where OldRev
+	// is only set when an update pushed new commits.
+	Action string `json:"action,omitempty"`
+	OldRev string `json:"oldrev,omitempty"`
+}
+
+// MergeRequestEvent is sent when a merge request is opened, updated or closed.
+type MergeRequestEvent struct {
+	ObjectKind       string       `json:"object_kind"`
+	User             EventUser    `json:"user"`
+	Project          EventProject `json:"project"`
+	ObjectAttributes MergeRequest `json:"object_attributes"`
+}
+
+// NoteAttributes are the attributes of a note event.
+type NoteAttributes struct {
+	ID           int    `json:"id"`
+	Note         string `json:"note"`
+	NoteableType string `json:"noteable_type"`
+	URL          string `json:"url"`
+}
+
+// NoteEvent is sent when a comment is made.
+type NoteEvent struct {
+	ObjectKind       string         `json:"object_kind"`
+	User             EventUser      `json:"user"`
+	Project          EventProject   `json:"project"`
+	ObjectAttributes NoteAttributes `json:"object_attributes"`
+	// MergeRequest is set for notes on merge requests.
+	MergeRequest *MergeRequest `json:"merge_request,omitempty"`
+}
+
+// PushEvent is sent when commits are pushed to a branch.
+type PushEvent struct {
+	ObjectKind   string        `json:"object_kind"`
+	Before       string        `json:"before"`
+	After        string        `json:"after"`
+	Ref          string        `json:"ref"`
+	UserID       int           `json:"user_id"`
+	UserName     string        `json:"user_name"`
+	UserUsername string        `json:"user_username"`
+	Project      EventProject  `json:"project"`
+	Commits      []EventCommit `json:"commits"`
+}
+
+// Branch returns the branch pushed to, or an empty string for tags.
+func (pe PushEvent) Branch() string {
+	if !strings.HasPrefix(pe.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(pe.Ref, "refs/heads/")
+}
+
+// ChangedFiles returns the files the pushed commits touched.
+func (pe PushEvent) ChangedFiles() []string {
+	seen := map[string]bool{}
+	var files []string
+	for _, commit := range pe.Commits {
+		for _, list := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, file := range list {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files
+}
+
+// ValidateWebhook checks the secret token of a webhook request and returns
+// its event type and payload, or the status code and message to respond with.
+func ValidateWebhook(w http.ResponseWriter, r *http.Request, secret []byte) (string, []byte, bool) {
+	fail := func(code int, format string, args ...interface{}) (string, []byte, bool) {
+		http.Error(w, fmt.Sprintf("%d %s: %s", code, http.StatusText(code), fmt.Sprintf(format, args...)), code)
+		return "", nil, false
+	}
+	if r.Method != http.MethodPost {
+		return fail(http.StatusMethodNotAllowed, "only POST is allowed")
+	}
+	eventType := r.Header.Get(EventHeader)
+	if eventType == "" {
+		return fail(http.StatusBadRequest, "missing %s header", EventHeader)
+	}
+	token := []byte(r.Header.Get(TokenHeader))
+	if len(secret) == 0 || subtle.ConstantTimeCompare(token, secret) != 1 {
+		return fail(http.StatusForbidden, "invalid %s", TokenHeader)
+	}
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fail(http.StatusInternalServerError, "could not read body: %v", err)
+	}
+	return eventType, payload, true
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateWebhook(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		event  string
+		token  string
+		code   int
+	}{
+		{
+			name:   "valid webhook",
+			method: http.MethodPost,
+			event:  PushHook,
+			token:  "secret",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodGet,
+			event:  PushHook,
+			token:  "secret",
+			code:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "missing event",
+			method: http.MethodPost,
+			token:  "secret",
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "wrong token",
+			method: http.MethodPost,
+			event:  PushHook,
+			token:  "guess",
+			code:   http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/hook", strings.NewReader("{}"))
+			r.Header.Set(EventHeader, tc.event)
+			r.Header.Set(TokenHeader, tc.token)
+			w := httptest.NewRecorder()
+			event, payload, ok := ValidateWebhook(w, r, []byte("secret"))
+			if ok != (tc.code == http.StatusOK) {
+				t.Fatalf("ok = %t with response %d: %s", ok, w.Code, w.Body.String())
+			}
+			if !ok {
+				if w.Code != tc.code {
+					t.Errorf("code = %d, want %d", w.Code, tc.code)
+				}
+				return
+			}
+			if event != tc.event || string(payload) != "{}" {
+				t.Errorf("got event %q with payload %q", event, payload)
+			}
+		})
+	}
+}
+
+func TestPushEvent(t *testing.T) {
+	pe := PushEvent{
+		Ref: "refs/heads/release/1.0",
+		Commits: []EventCommit{
+			{Added: []string{"a"}, Modified: []string{"b"}},
+			{Modified: []string{"a"}, Removed: []string{"c"}},
+		},
+	}
+	if got, want := pe.Branch(), "release/1.0"; got != want {
+		t.Errorf("Branch() = %q, want %q", got, want)
+	}
+	if got, want := pe.ChangedFiles(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFiles() = %v, want %v", got, want)
+	}
+	pe.Ref = "refs/tags/v1.0"
+	if got := pe.Branch(); got != "" {
+		t.Errorf("Branch() of a tag = %q, want none", got)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"io"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"gocloud.dev/blob"
@@ -33,6 +34,14 @@ type ObjectAttributes struct {
 	ObjName string
 	// IsDir is true if the object is a directory
 	IsDir bool
+	// Updated is the time the object was last modified
+	Updated time.Time
+	// Metadata holds the user-provided key/value pairs of the object,
+	// it is only set if MetadataListed is true
+	Metadata map[string]string
+	// MetadataListed is true if the provider lists metadata along with
+	// the objects, otherwise it has to be fetched with Attributes
+	MetadataListed bool
 }
 
 // ObjectIterator iterates through storage objects
@@ -49,7 +58,7 @@ type gcsObjectIterator struct {
 
 func (g gcsObjectIterator) Next(_ context.Context) (ObjectAttributes, error) {
 	oAttrs, err := g.Iterator.Next()
-	// oAttrs object has only 'Name' or 'Prefix' field set.
+	// oAttrs object has only 'Name', 'Updated' and 'Metadata' or 'Prefix' fields set.
 	if err == iterator.Done {
 		return ObjectAttributes{}, io.EOF
 	}
@@ -62,6 +71,9 @@ func (g gcsObjectIterator) Next(_ context.Context) (ObjectAttributes, error) {
 		attr.Name = oAttrs.Name
 		nameSplit := strings.Split(oAttrs.Name, "/")
 		attr.ObjName = nameSplit[len(nameSplit)-1]
+		attr.Updated = oAttrs.Updated
+		attr.Metadata = oAttrs.Metadata
+		attr.MetadataListed = true
 	} else {
 		// directory
 		attr.Name = oAttrs.Prefix
@@ -91,6 +103,7 @@ func (g openerObjectIterator) Next(ctx context.Context) (ObjectAttributes, error
 		// object
 		nameSplit := strings.Split(oAttrs.Key, "/")
 		attr.ObjName = nameSplit[len(nameSplit)-1]
+		attr.Updated = oAttrs.ModTime
 	}
 	return attr, nil
 }
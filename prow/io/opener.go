@@ -61,6 +61,8 @@ type Attributes struct {
 	ContentEncoding string
 	// Size is the size of the blob's content in bytes.
 	Size int64
+	// Metadata holds the user-provided key/value pairs stored with the blob.
+	Metadata map[string]string
 }
 
 // Opener has methods to read and write paths
@@ -71,6 +73,7 @@ type Opener interface {
 	Attributes(ctx context.Context, path string) (Attributes, error)
 	SignedURL(ctx context.Context, path string, opts SignedURLOptions) (string, error)
 	Iterator(ctx context.Context, prefix, delimiter string) (ObjectIterator, error)
+	Delete(ctx context.Context, path string) error
 }
 
 type opener struct {
@@ -316,6 +319,7 @@ func (o *opener) Attributes(ctx context.Context, path string) (Attributes, error
 		return Attributes{
 			ContentEncoding: attr.ContentEncoding,
 			Size:            attr.Size,
+			Metadata:        attr.Metadata,
 		}, nil
 	}
 
@@ -331,6 +335,7 @@ func (o *opener) Attributes(ctx context.Context, path string) (Attributes, error
 	return Attributes{
 		ContentEncoding: attr.ContentEncoding,
 		Size:            attr.Size,
+		Metadata:        attr.Metadata,
 	}, nil
 }
 
@@ -421,7 +426,7 @@ func (o *opener) Iterator(ctx context.Context, prefix, delimiter string) (Object
 		}
 		if delimiter == "" {
 			// query.SetAttrSelection cannot be used in directory-like mode (when delimiter != "").
-			if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+			if err := query.SetAttrSelection([]string{"Name", "Updated", "Metadata"}); err != nil {
 				return nil, err
 			}
 		}
@@ -434,7 +439,7 @@ func (o *opener) Iterator(ctx context.Context, prefix, delimiter string) (Object
 	if err != nil {
 		return nil, err
 	}
-	if !strings.HasSuffix(relativePath, "/") {
+	if relativePath != "" && !strings.HasSuffix(relativePath, "/") {
 		relativePath += "/"
 	}
 	return openerObjectIterator{
@@ -444,3 +449,23 @@ func (o *opener) Iterator(ctx context.Context, prefix, delimiter string) (Object
 		}),
 	}, nil
 }
+
+// Delete removes the object at path.
+func (o *opener) Delete(ctx context.Context, path string) error {
+	if strings.HasPrefix(path, providers.GS+"://") {
+		g, err := o.openGCS(path)
+		if err != nil {
+			return fmt.Errorf("bad gcs path: %v", err)
+		}
+		return g.Delete(ctx)
+	}
+	if strings.HasPrefix(path, "/") {
+		return os.Remove(path)
+	}
+
+	bucket, relativePath, err := o.getBucket(ctx, path)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(ctx, relativePath)
+}
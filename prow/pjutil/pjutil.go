@@ -194,6 +194,12 @@ func CompletePrimaryRefs(refs prowapi.Refs, jb config.JobBase) *prowapi.Refs {
 	if jb.SkipFetchHead {
 		refs.SkipFetchHead = jb.SkipFetchHead
 	}
+	if jb.Filter != "" {
+		refs.Filter = jb.Filter
+	}
+	if len(jb.SparseCheckout) > 0 {
+		refs.SparseCheckout = jb.SparseCheckout
+	}
 	return &refs
 }
 
@@ -345,6 +345,23 @@ func TestCompletePrimaryRefs(t *testing.T) {
 				CloneDepth: 2,
 			},
 		},
+		{
+			name: "use partial and sparse clone settings from job base",
+			refs: prowapi.Refs{
+				Filter:         "tree:0",
+				SparseCheckout: []string{"docs"},
+			},
+			jobBase: config.JobBase{
+				UtilityConfig: config.UtilityConfig{
+					Filter:         "blob:none",
+					SparseCheckout: []string{"cmd", "pkg"},
+				},
+			},
+			expected: prowapi.Refs{
+				Filter:         "blob:none",
+				SparseCheckout: []string{"cmd", "pkg"},
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
@@ -68,6 +69,8 @@ type githubClient interface {
 	AddLabel(org, repo string, number int, label string) error
 	RemoveLabel(org, repo string, number int, label string) error
 	ListIssueEvents(org, repo string, num int) ([]github.ListedIssueEvent, error)
+	ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error)
+	GetSingleCommit(org, repo, SHA string) (github.RepositoryCommit, error)
 }
 
 type ownersClient interface {
@@ -86,6 +89,24 @@ type state struct {
 	htmlURL   string
 }
 
+// approvalPolicyRepo applies the approval requirements of the approve
+// plugin config on top of the ones set in OWNERS files.
+type approvalPolicyRepo struct {
+	approvers.Repo
+	opts *plugins.Approve
+}
+
+func (r approvalPolicyRepo) RequiredApproverCount(path string) int {
+	if required := r.Repo.RequiredApproverCount(path); required > r.opts.RequiredApprovers {
+		return required
+	}
+	return r.opts.RequiredApprovers
+}
+
+func (r approvalPolicyRepo) InvalidateOnPush(path string) bool {
+	return r.opts.InvalidateOnPush || r.Repo.InvalidateOnPush(path)
+}
+
 func init() {
 	plugins.RegisterGenericCommentHandler(PluginName, handleGenericCommentEvent, helpProvider)
 	plugins.RegisterReviewEventHandler(PluginName, handleReviewEvent, helpProvider)
@@ -110,6 +131,12 @@ func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo)
 	for _, repo := range enabledRepos {
 		opts := config.ApproveFor(repo.Org, repo.Repo)
 		approveConfig[repo.String()] = fmt.Sprintf("Pull requests %s require an associated issue.<br>Pull request authors %s implicitly approve their own PRs.<br>The /lgtm [cancel] command(s) %s act as approval.<br>A GitHub approved or changes requested review %s act as approval or cancel respectively.", doNot(opts.IssueRequired), doNot(opts.HasSelfApproval()), willNot(opts.LgtmActsAsApprove), willNot(opts.ConsiderReviewState()))
+		if opts.RequiredApprovers > 1 {
+			approveConfig[repo.String()] += fmt.Sprintf("<br>Every OWNERS file needs approval from %d distinct approvers.", opts.RequiredApprovers)
+		}
+		if opts.InvalidateOnPush {
+			approveConfig[repo.String()] += "<br>Approvals are invalidated when new commits touch the approved files."
+		}
 	}
 
 	yamlSnippet, err := plugins.CommentMap.GenYaml(&plugins.Configuration{
@@ -132,6 +159,7 @@ func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo)
 		Description: `The approve plugin implements a pull request approval process that manages the '` + labels.Approved + `' label and an approval notification comment. Approval is achieved when the set of users that have approved the PR is capable of approving every file changed by the PR. A user is able to approve a file if their username or an alias they belong to is listed in the 'approvers' section of an OWNERS file in the directory of the file or higher in the directory tree.
 <br>
 <br>Per-repo configuration may be used to require that PRs link to an associated issue before approval is granted. It may also be used to specify that the PR authors implicitly approve their own PRs.
+<br>Per-repo configuration and the 'required_approvers' and 'invalidate_on_push' options of OWNERS files may be used to require several distinct approvers, and to invalidate approvals when new commits touch the approved files.
 <br>For more information see <a href="https://git.k8s.io/test-infra/prow/plugins/approve/approvers/README.md">here</a>.`,
 		Config:  approveConfig,
 		Snippet: yamlSnippet,
@@ -368,6 +396,10 @@ func findAssociatedIssue(body, org string) (int, error) {
 //   - An approver of a file is defined as:
 //     - Someone listed as an "approver" in an OWNERS file in the files directory OR
 //     - in one of the file's parent directories
+// - OWNERS files may require several distinct approvers, in which case that many of
+//   their approvers must be in approverSet
+// - If commits touch an OWNERS file that invalidates approvals on push, approvals
+//   given before they were committed no longer count for that OWNERS file
 // - Iff all files have been approved, the bot will add the "approved" label.
 // - Iff a cancel command is found, that reviewer will be removed from the approverSet
 // 	and the munger will remove the approved label if it has been applied
@@ -419,14 +451,13 @@ func handle(log *logrus.Entry, ghc githubClient, repo approvers.Repo, githubConf
 	log.WithField("duration", time.Since(start).String()).Debug("Completed github functions in handle")
 
 	start = time.Now()
-	approversHandler := approvers.NewApprovers(
-		approvers.NewOwners(
-			log,
-			filenames,
-			repo,
-			int64(pr.number),
-		),
+	owners := approvers.NewOwners(
+		log,
+		filenames,
+		approvalPolicyRepo{Repo: repo, opts: opts},
+		int64(pr.number),
 	)
+	approversHandler := approvers.NewApprovers(owners)
 	approversHandler.AssociatedIssue, err = findAssociatedIssue(pr.body, pr.org)
 	if err != nil {
 		log.WithError(err).Errorf("Failed to find associated issue from PR body: %v", err)
@@ -461,6 +492,10 @@ func handle(log *logrus.Entry, ghc githubClient, repo approvers.Repo, githubConf
 	start = time.Now()
 	notifications := filterComments(commentsFromIssueComments, notificationMatcher(botUserChecker))
 	latestNotification := getLast(notifications)
+	if err := findInvalidations(ghc, &approversHandler, owners, approveComments, pr); err != nil {
+		return fetchErr("commits invalidating approvals", err)
+	}
+	invalidateApprovals(&approversHandler, approveComments, pr.author)
 	newMessage := updateNotification(githubConfig.LinkURL, opts.CommandHelpLink, opts.PrProcessLink, pr.org, pr.repo, pr.branch, latestNotification, approversHandler)
 	log.WithField("duration", time.Since(start).String()).Debug("Completed getting notifications in handle")
 	start = time.Now()
@@ -492,6 +527,85 @@ func handle(log *logrus.Entry, ghc githubClient, repo approvers.Repo, githubConf
 	return nil
 }
 
+// findInvalidations sets when commits of the PR last touched the OWNERS files
+// which invalidate approvals on push. It is derived from the commits on every
+// run, using their committer date since GitHub does not tell when they were
+// pushed. Rebased commits get a new committer date, so a force push touches
+// every file of the rebased commits.
+//
+// Only commits more recent than the earliest approval may invalidate it, so
+// the files of older commits are never fetched.
+func findInvalidations(ghc githubClient, ap *approvers.Approvers, owners approvers.Owners, approveComments []*comment, pr *state) error {
+	invalidating := sets.NewString()
+	for file := range owners.GetOwnersSet() {
+		if owners.InvalidateOnPush(file) {
+			invalidating.Insert(file)
+		}
+	}
+	if invalidating.Len() == 0 {
+		return nil
+	}
+
+	var earliest time.Time
+	for _, c := range approveComments {
+		if strings.EqualFold(c.Author, pr.author) {
+			continue
+		}
+		if earliest.IsZero() || c.CreatedAt.Before(earliest) {
+			earliest = c.CreatedAt
+		}
+	}
+	if earliest.IsZero() {
+		return nil
+	}
+
+	commits, err := ghc.ListPRCommits(pr.org, pr.repo, pr.number)
+	if err != nil {
+		return err
+	}
+	for _, commit := range commits {
+		committedAt := commit.Commit.Committer.Date
+		if !committedAt.After(earliest) {
+			continue
+		}
+		full, err := ghc.GetSingleCommit(pr.org, pr.repo, commit.SHA)
+		if err != nil {
+			return err
+		}
+		var touched []string
+		for _, file := range full.Files {
+			touched = append(touched, file.Filename)
+		}
+		for file := range owners.GetOwnersForFiles(touched).Intersection(invalidating) {
+			if committedAt.After(ap.InvalidatedAt[file]) {
+				ap.InvalidatedAt[file] = committedAt
+			}
+		}
+	}
+	return nil
+}
+
+// invalidateApprovals discards the approvals given before new commits touched
+// OWNERS files which invalidate approvals on push. The approval of the author
+// is kept since they pushed the commits.
+func invalidateApprovals(ap *approvers.Approvers, approveComments []*comment, author string) {
+	if len(ap.InvalidatedAt) == 0 {
+		return
+	}
+	// approveComments are sorted by creation time, so this keeps the latest.
+	approvedAt := map[string]time.Time{}
+	for _, c := range approveComments {
+		approvedAt[strings.ToLower(c.Author)] = c.CreatedAt
+	}
+	for file, invalidatedAt := range ap.InvalidatedAt {
+		for login, at := range approvedAt {
+			if login != strings.ToLower(author) && at.Before(invalidatedAt) {
+				ap.InvalidateApproval(file, login)
+			}
+		}
+	}
+}
+
 func humanAddedApproved(ghc githubClient, log *logrus.Entry, org, repo string, number int, isBot func(string) bool, hasLabel bool) func() bool {
 	findOut := func() bool {
 		if !hasLabel {
@@ -129,10 +129,12 @@ func newFakeGitHubClient(hasLabel, humanApproved bool, files []string, comments
 }
 
 type fakeRepo struct {
-	approvers      map[string]layeredsets.String
-	leafApprovers  map[string]sets.String
-	approverOwners map[string]string
-	dirBlacklist   []*regexp.Regexp
+	approvers         map[string]layeredsets.String
+	leafApprovers     map[string]sets.String
+	approverOwners    map[string]string
+	requiredApprovers map[string]int
+	invalidateOnPush  map[string]bool
+	dirBlacklist      []*regexp.Regexp
 }
 
 func (fr fakeRepo) Filenames() ownersconfig.Filenames {
@@ -154,6 +156,15 @@ func (fr fakeRepo) IsNoParentOwners(path string) bool {
 func (fr fakeRepo) TopLevelApprovers() sets.String {
 	return nil
 }
+func (fr fakeRepo) RequiredApproverCount(path string) int {
+	if required, ok := fr.requiredApprovers[path]; ok {
+		return required
+	}
+	return 1
+}
+func (fr fakeRepo) InvalidateOnPush(path string) bool {
+	return fr.invalidateOnPush[path]
+}
 
 func (fr fakeRepo) ParseSimpleConfig(path string) (repoowners.SimpleConfig, error) {
 	dir := filepath.Dir(path)
@@ -1183,6 +1194,141 @@ Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a commen
 	}
 }
 
+func TestHandleInvalidateOnPush(t *testing.T) {
+	approvedAt := time.Now().Add(-time.Hour)
+	comments := []github.IssueComment{
+		newTestCommentTime(approvedAt, "alice", "/approve"),
+		newTestCommentTime(approvedAt, "cblecker", "/approve"),
+	}
+	commit := func(sha string, at time.Time, files ...string) github.RepositoryCommit {
+		commit := github.RepositoryCommit{SHA: sha, Commit: github.GitCommit{Committer: github.CommitAuthor{Date: at}}}
+		for _, file := range files {
+			commit.Files = append(commit.Files, github.CommitFile{Filename: file})
+		}
+		return commit
+	}
+	before := approvedAt.Add(-time.Minute)
+	after := approvedAt.Add(30 * time.Minute)
+
+	tests := []struct {
+		name             string
+		commits          []github.RepositoryCommit
+		comments         []github.IssueComment
+		invalidateOnPush map[string]bool
+		repoWide         bool
+
+		expectApproved bool
+		expectComment  string
+	}{
+		{
+			name:             "commit touching a file invalidates its approval",
+			commits:          []github.RepositoryCommit{commit("first", before, "a/a.go", "c/c.go"), commit("second", after, "c/c.go")},
+			comments:         comments,
+			invalidateOnPush: map[string]bool{"c": true},
+			expectComment:    "(approval by cblecker invalidated by new commits)",
+		},
+		{
+			name:             "commit touching other files keeps approvals",
+			commits:          []github.RepositoryCommit{commit("first", before, "c/c.go"), commit("second", after, "a/a.go")},
+			comments:         comments,
+			invalidateOnPush: map[string]bool{"c": true},
+			expectApproved:   true,
+		},
+		{
+			name:             "commits before the approval keep it",
+			commits:          []github.RepositoryCommit{commit("first", before, "a/a.go", "c/c.go")},
+			comments:         comments,
+			invalidateOnPush: map[string]bool{"c": true},
+			expectApproved:   true,
+		},
+		{
+			name:           "commit without invalidation keeps approvals",
+			commits:        []github.RepositoryCommit{commit("first", after, "a/a.go", "c/c.go")},
+			comments:       comments,
+			expectApproved: true,
+		},
+		{
+			name:          "repo wide invalidation applies to every OWNERS file",
+			commits:       []github.RepositoryCommit{commit("first", after, "a/a.go")},
+			comments:      comments,
+			repoWide:      true,
+			expectComment: "(approval by alice invalidated by new commits)",
+		},
+		{
+			name:             "approval after the commit counts",
+			commits:          []github.RepositoryCommit{commit("first", after, "c/c.go")},
+			comments:         append(comments, newTestCommentTime(after.Add(time.Minute), "cblecker", "/approve")),
+			invalidateOnPush: map[string]bool{"c": true},
+			expectApproved:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fr := fakeRepo{
+				approvers: map[string]layeredsets.String{
+					"a": layeredsets.NewString("alice"),
+					"c": layeredsets.NewString("cblecker", "cjwagner"),
+				},
+				leafApprovers: map[string]sets.String{
+					"a": sets.NewString("alice"),
+					"c": sets.NewString("cblecker", "cjwagner"),
+				},
+				approverOwners: map[string]string{
+					"a/a.go": "a",
+					"c/c.go": "c",
+				},
+				invalidateOnPush: test.invalidateOnPush,
+			}
+			fghc := newFakeGitHubClient(false, false, []string{"a/a.go", "c/c.go"}, test.comments, nil)
+			fghc.Commits = map[string]github.RepositoryCommit{}
+			for _, commit := range test.commits {
+				fghc.Commits[commit.SHA] = commit
+				commit.Files = nil
+				fghc.CommitMap[fmt.Sprintf("org/repo#%d", prNumber)] = append(fghc.CommitMap[fmt.Sprintf("org/repo#%d", prNumber)], commit)
+			}
+
+			rsa := false
+			if err := handle(
+				logrus.WithField("plugin", "approve"),
+				fghc,
+				fr,
+				config.GitHubOptions{LinkURL: &url.URL{Scheme: "https", Host: "github.com"}},
+				&plugins.Approve{
+					Repos:               []string{"org/repo"},
+					RequireSelfApproval: &rsa,
+					InvalidateOnPush:    test.repoWide,
+				},
+				&state{
+					org:    "org",
+					repo:   "repo",
+					branch: "master",
+					number: prNumber,
+					author: "someone",
+				},
+			); err != nil {
+				t.Fatalf("Unexpected error handling event: %v.", err)
+			}
+
+			approved := false
+			for _, l := range fghc.IssueLabelsAdded {
+				if l == fmt.Sprintf("org/repo#%v:approved", prNumber) {
+					approved = true
+				}
+			}
+			if approved != test.expectApproved {
+				t.Errorf("Expected approved: %t, but got %t.", test.expectApproved, approved)
+			}
+			if len(fghc.IssueCommentsAdded) != 1 {
+				t.Fatalf("Expected 1 notification to be added but %d notifications were added.", len(fghc.IssueCommentsAdded))
+			}
+			if notification := fghc.IssueCommentsAdded[0]; !strings.Contains(notification, test.expectComment) {
+				t.Errorf("Expected the notification to contain %q, but got:\n%s", test.expectComment, notification)
+			}
+		})
+	}
+}
+
 // TODO: cache approvers 'GetFilesApprovers' and 'GetCCs' since these are called repeatedly and are
 // expensive.
 
@@ -985,3 +985,120 @@ Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a commen
 		t.Errorf("GetMessage() = %+v, want = %+v", *got, want)
 	}
 }
+
+func TestRequiredApprovers(t *testing.T) {
+	repo := createFakeRepo(map[string]sets.String{
+		"":  sets.NewString("Alice"),
+		"a": sets.NewString("Art", "Anne", "Amy"),
+		"b": sets.NewString("Bill"),
+	})
+	repo.requiredApproversMap = map[string]int{"a": 2}
+	tests := []struct {
+		testName           string
+		filenames          []string
+		currentlyApproved  sets.String
+		invalidated        map[string]sets.String
+		expectedUnapproved sets.String
+	}{
+		{
+			testName:           "Single approver is not enough",
+			filenames:          []string{"a/a.go"},
+			currentlyApproved:  sets.NewString("Art"),
+			expectedUnapproved: sets.NewString("a"),
+		},
+		{
+			testName:           "Two approvers are enough",
+			filenames:          []string{"a/a.go"},
+			currentlyApproved:  sets.NewString("Art", "Anne"),
+			expectedUnapproved: sets.NewString(),
+		},
+		{
+			testName:           "Parent approver counts towards the requirement",
+			filenames:          []string{"a/a.go"},
+			currentlyApproved:  sets.NewString("Art", "Alice"),
+			expectedUnapproved: sets.NewString(),
+		},
+		{
+			testName:           "Stricter subdir is not covered by root approval",
+			filenames:          []string{"a/a.go", "kubernetes.go"},
+			currentlyApproved:  sets.NewString("Alice"),
+			expectedUnapproved: sets.NewString("a"),
+		},
+		{
+			testName:           "Invalidated approval does not count",
+			filenames:          []string{"a/a.go", "b/b.go"},
+			currentlyApproved:  sets.NewString("Art", "Anne", "Bill"),
+			invalidated:        map[string]sets.String{"a": sets.NewString("Anne")},
+			expectedUnapproved: sets.NewString("a"),
+		},
+		{
+			testName:           "Invalidation is scoped to the OWNERS file",
+			filenames:          []string{"a/a.go", "b/b.go"},
+			currentlyApproved:  sets.NewString("Art", "Anne", "Bill"),
+			invalidated:        map[string]sets.String{"b": sets.NewString("Art")},
+			expectedUnapproved: sets.NewString(),
+		},
+	}
+
+	for _, test := range tests {
+		testApprovers := NewApprovers(Owners{filenames: test.filenames, repo: repo, seed: TestSeed, log: logrus.WithField("plugin", "some_plugin")})
+		for approver := range test.currentlyApproved {
+			testApprovers.AddApprover(approver, "REFERENCE", false)
+		}
+		for ownersFile, logins := range test.invalidated {
+			for login := range logins {
+				testApprovers.InvalidateApproval(ownersFile, login)
+			}
+		}
+		calculated := testApprovers.UnapprovedFiles()
+		if !test.expectedUnapproved.Equal(calculated) {
+			t.Errorf("Failed for test %v.  Expected unapproved files: %v. Found %v", test.testName, test.expectedUnapproved, calculated)
+		}
+	}
+}
+
+func TestGetMessagePartiallyApproved(t *testing.T) {
+	repo := createFakeRepo(map[string]sets.String{
+		"a": sets.NewString("Alice", "Anne"),
+		"b": sets.NewString("Bill", "Ben"),
+	})
+	repo.requiredApproversMap = map[string]int{"a": 2}
+	repo.invalidateOnPushMap = map[string]bool{"b": true}
+	ap := NewApprovers(
+		Owners{
+			filenames: []string{"a/a.go", "b/b.go"},
+			repo:      repo,
+			log:       logrus.WithField("plugin", "some_plugin"),
+		},
+	)
+	ap.AddApprover("Alice", "REFERENCE", false)
+	ap.AddApprover("Bill", "REFERENCE", false)
+	ap.InvalidateApproval("b", "bill")
+
+	want := `[APPROVALNOTIFIER] This PR is **NOT APPROVED**
+
+This pull-request has been approved by: *<a href="REFERENCE" title="Approved">Alice</a>*, *<a href="REFERENCE" title="Approved">Bill</a>*
+To complete the [pull request process](https://git.k8s.io/community/contributors/guide/owners.md#the-code-review-process), please assign **anne**, **ben** after the PR has been reviewed.
+You can assign the PR to them by writing ` + "`/assign @anne @ben`" + ` in a comment when ready.
+
+The full list of commands accepted by this bot can be found [here](https://go.k8s.io/bot-commands?repo=org%2Frepo).
+
+<details open>
+Needs approval from an approver in each of these files:
+Files showing a number of approvals need that many distinct approvers.
+
+- **[a/OWNERS](https://github.com/org/repo/blob/dev/a/OWNERS)** [Alice] (1/2 approvals)
+- **[b/OWNERS](https://github.com/org/repo/blob/dev/b/OWNERS)** (approval by Bill invalidated by new commits)
+
+Approvers can indicate their approval by writing ` + "`/approve`" + ` in a comment
+Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a comment
+</details>
+<!-- META={"approvers":["anne","ben"]} -->`
+	got := GetMessage(ap, &url.URL{Scheme: "https", Host: "github.com"}, "https://go.k8s.io/bot-commands", "https://git.k8s.io/community/contributors/guide/owners.md#the-code-review-process", "org", "repo", "dev")
+	if got == nil {
+		t.Fatal("GetMessage() failed")
+	}
+	if *got != want {
+		t.Errorf("GetMessage() = %+v, want = %+v", *got, want)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -46,6 +47,8 @@ type Repo interface {
 	LeafApprovers(path string) sets.String
 	FindApproverOwnersForFile(file string) string
 	IsNoParentOwners(path string) bool
+	RequiredApproverCount(path string) int
+	InvalidateOnPush(path string) bool
 	Filenames() ownersconfig.Filenames
 }
 
@@ -149,6 +152,11 @@ func (o Owners) KeepCoveringApprovers(reverseMap map[string]sets.String, knownAp
 	unapproved := o.temporaryUnapprovedFiles(knownApprovers)
 
 	for _, suggestedApprover := range o.GetSuggestedApprovers(reverseMap, potentialApprovers).List() {
+		// Files needing several approvers may still be unapproved after
+		// a known approver approved them.
+		if knownApprovers.Has(suggestedApprover) {
+			continue
+		}
 		if reverseMap[suggestedApprover].Intersection(unapproved).Len() != 0 {
 			keptApprovers.Insert(suggestedApprover)
 		}
@@ -162,7 +170,16 @@ func (o Owners) KeepCoveringApprovers(reverseMap map[string]sets.String, knownAp
 func (o Owners) GetSuggestedApprovers(reverseMap map[string]sets.String, potentialApprovers []string) sets.String {
 	ap := NewApprovers(o)
 	for !ap.RequirementsMet() {
-		newApprover := findMostCoveringApprover(potentialApprovers, reverseMap, ap.UnapprovedFiles())
+		// OWNERS files may need several approvers, so only consider people
+		// who have not been suggested yet.
+		current := ap.GetCurrentApproversSet()
+		var candidates []string
+		for _, approver := range potentialApprovers {
+			if !current.Has(approver) {
+				candidates = append(candidates, approver)
+			}
+		}
+		newApprover := findMostCoveringApprover(candidates, reverseMap, ap.UnapprovedFiles())
 		if newApprover == "" {
 			o.log.Debugf("Couldn't find/suggest approvers for each files. Unapproved: %q", ap.UnapprovedFiles().List())
 			return ap.GetCurrentApproversSet()
@@ -199,7 +216,8 @@ func (o Owners) GetShuffledApprovers() []string {
 // E.g. [a, a/b/c, d/e, d/e/f] -> [a, d/e]
 // Subdirs will not be removed if they are configured to have no parent OWNERS files or if any
 // OWNERS file in the relative path between the subdir and the higher level dir is configured to
-// have no parent OWNERS files.
+// have no parent OWNERS files. Subdirs with stricter approval requirements than the higher level
+// dir are not removed either.
 func (o Owners) removeSubdirs(dirs sets.String) {
 	canonicalize := func(p string) string {
 		if p == "." {
@@ -215,11 +233,59 @@ func (o Owners) removeSubdirs(dirs sets.String) {
 			}
 			path = filepath.Dir(path)
 			if dirs.Has(canonicalize(path)) {
-				dirs.Delete(dir)
+				if !o.stricterThan(dir, canonicalize(path)) {
+					dirs.Delete(dir)
+				}
+				break
+			}
+		}
+	}
+}
+
+// RequiredApprovers returns the number of distinct approvers needed to
+// approve the given OWNERS file.
+func (o Owners) RequiredApprovers(ownersFile string) int {
+	if required := o.repo.RequiredApproverCount(ownersFile); required > 1 {
+		return required
+	}
+	return 1
+}
+
+// stricterThan checks if the OWNERS file dir has approval requirements that
+// its parent OWNERS file does not have.
+func (o Owners) stricterThan(dir, parent string) bool {
+	return o.RequiredApprovers(dir) > o.RequiredApprovers(parent) ||
+		(o.repo.InvalidateOnPush(dir) && !o.repo.InvalidateOnPush(parent))
+}
+
+// GetOwnersForFiles returns the OWNERS files of the PR which are responsible
+// for approving the given files.
+func (o Owners) GetOwnersForFiles(filenames []string) sets.String {
+	ownersSet := o.GetOwnersSet()
+	owners := sets.NewString()
+	for _, fn := range filenames {
+		path := o.repo.FindApproverOwnersForFile(fn)
+		for {
+			if ownersSet.Has(path) {
+				owners.Insert(path)
+				break
+			}
+			if path == "" || path == "." {
 				break
 			}
+			path = filepath.Dir(path)
+			if path == "." {
+				path = ""
+			}
 		}
 	}
+	return owners
+}
+
+// InvalidateOnPush checks if new commits touching the given OWNERS file
+// invalidate its approvals.
+func (o Owners) InvalidateOnPush(ownersFile string) bool {
+	return o.repo.InvalidateOnPush(ownersFile)
 }
 
 // Approval has the information about each approval on a PR
@@ -248,6 +314,12 @@ type Approvers struct {
 	assignees       sets.String
 	AssociatedIssue int
 	RequireIssue    bool
+	// InvalidatedAt maps OWNERS files to the last time commits of the PR
+	// touched them, for OWNERS files which invalidate approvals on push.
+	InvalidatedAt map[string]time.Time
+	// invalidated maps OWNERS files to the approvers whose approval no
+	// longer counts for them. The logins are normalized to lowercase.
+	invalidated map[string]sets.String
 
 	ManuallyApproved func() bool
 }
@@ -276,6 +348,9 @@ func NewApprovers(owners Owners) Approvers {
 		approvers: map[string]Approval{},
 		assignees: sets.NewString(),
 
+		InvalidatedAt: map[string]time.Time{},
+		invalidated:   map[string]sets.String{},
+
 		ManuallyApproved: func() bool {
 			return false
 		},
@@ -338,6 +413,30 @@ func (ap *Approvers) RemoveApprover(login string) {
 	delete(ap.approvers, strings.ToLower(login))
 }
 
+// InvalidateApproval discards the approval of login for the given OWNERS
+// file, while keeping it for the other OWNERS files.
+func (ap *Approvers) InvalidateApproval(ownersFile, login string) {
+	if ap.invalidated[ownersFile] == nil {
+		ap.invalidated[ownersFile] = sets.NewString()
+	}
+	ap.invalidated[ownersFile].Insert(strings.ToLower(login))
+}
+
+// getInvalidatedApprovers returns the logins, with their original case, of
+// the current approvers of the OWNERS file whose approval was invalidated.
+func (ap Approvers) getInvalidatedApprovers(ownersFile string) sets.String {
+	invalidated := sets.NewString()
+	for login := range ap.invalidated[ownersFile] {
+		if approval, ok := ap.approvers[login]; ok {
+			invalidated.Insert(approval.Login)
+		}
+	}
+	if invalidated.Len() == 0 {
+		return invalidated
+	}
+	return IntersectSetsCase(invalidated, ap.owners.GetApprovers()[ownersFile])
+}
+
 // AddAssignees adds assignees to the list
 func (ap *Approvers) AddAssignees(logins ...string) {
 	for _, login := range logins {
@@ -392,7 +491,7 @@ func (ap Approvers) GetFilesApprovers() map[string]sets.String {
 		// We want to keep the syntax of the github handle
 		// rather than the potential mis-cased username found in
 		// the OWNERS file, that's why it's the first parameter.
-		filesApprovers[fn] = IntersectSetsCase(currentApprovers, potentialApprovers)
+		filesApprovers[fn] = IntersectSetsCase(currentApprovers, potentialApprovers).Difference(ap.getInvalidatedApprovers(fn))
 	}
 
 	return filesApprovers
@@ -424,7 +523,7 @@ func (ap Approvers) NoIssueApprovers() map[string]Approval {
 func (ap Approvers) UnapprovedFiles() sets.String {
 	unapproved := sets.NewString()
 	for fn, approvers := range ap.GetFilesApprovers() {
-		if len(approvers) == 0 {
+		if len(approvers) < ap.owners.RequiredApprovers(fn) {
 			unapproved.Insert(fn)
 		}
 	}
@@ -436,7 +535,19 @@ func (ap Approvers) GetFiles(baseURL *url.URL, branch string) []File {
 	allOwnersFiles := []File{}
 	filesApprovers := ap.GetFilesApprovers()
 	for _, file := range ap.owners.GetOwnersSet().List() {
-		if len(filesApprovers[file]) == 0 {
+		required := ap.owners.RequiredApprovers(file)
+		invalidated := ap.getInvalidatedApprovers(file)
+		if len(filesApprovers[file]) < required && (required > 1 || invalidated.Len() > 0) {
+			allOwnersFiles = append(allOwnersFiles, PartiallyApprovedFile{
+				baseURL:        baseURL,
+				filepath:       file,
+				ownersFilename: ap.owners.repo.Filenames().Owners,
+				approvers:      filesApprovers[file],
+				invalidated:    invalidated,
+				required:       required,
+				branch:         branch,
+			})
+		} else if len(filesApprovers[file]) < required {
 			allOwnersFiles = append(allOwnersFiles, UnapprovedFile{
 				baseURL:        baseURL,
 				filepath:       file,
@@ -457,6 +568,17 @@ func (ap Approvers) GetFiles(baseURL *url.URL, branch string) []File {
 	return allOwnersFiles
 }
 
+// RequiresMultipleApprovers checks if any OWNERS file of the PR needs more
+// than one approver.
+func (ap Approvers) RequiresMultipleApprovers() bool {
+	for file := range ap.owners.GetOwnersSet() {
+		if ap.owners.RequiredApprovers(file) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
 // GetCCs gets the list of suggested approvers for a pull-request.  It
 // now considers current assignees as potential approvers. Here is how
 // it works:
@@ -476,7 +598,12 @@ func (ap Approvers) GetFiles(baseURL *url.URL, branch string) []File {
 func (ap Approvers) GetCCs() []string {
 	randomizedApprovers := ap.owners.GetShuffledApprovers()
 
+	// Approvers whose approval was invalidated need to approve again, so
+	// they may be suggested.
 	currentApprovers := ap.GetCurrentApproversSet()
+	for _, invalidated := range ap.invalidated {
+		currentApprovers = currentApprovers.Difference(invalidated)
+	}
 	approversAndAssignees := currentApprovers.Union(ap.assignees)
 	leafReverseMap := ap.owners.GetReverseMap(ap.owners.GetLeafApprovers())
 	suggested := ap.owners.KeepCoveringApprovers(leafReverseMap, approversAndAssignees, randomizedApprovers)
@@ -561,6 +688,22 @@ type UnapprovedFile struct {
 	branch         string
 }
 
+// PartiallyApprovedFile contains the information of a file which needs more
+// approvals, because it requires several approvers or because new commits
+// invalidated its approvals.
+type PartiallyApprovedFile struct {
+	baseURL        *url.URL
+	filepath       string
+	ownersFilename string
+	// approvers is the set of users whose approval of this file change counts.
+	approvers sets.String
+	// invalidated is the set of users whose approval was invalidated by new commits.
+	invalidated sets.String
+	// required is the number of distinct approvers needed.
+	required int
+	branch   string
+}
+
 func (a ApprovedFile) String() string {
 	fullOwnersPath := filepath.Join(a.filepath, a.ownersFilename)
 	if strings.HasSuffix(a.filepath, ".md") {
@@ -587,6 +730,30 @@ func (ua UnapprovedFile) String() string {
 	return fmt.Sprintf("- **[%s](%s)**\n", fullOwnersPath, link)
 }
 
+func (pa PartiallyApprovedFile) String() string {
+	fullOwnersPath := filepath.Join(pa.filepath, pa.ownersFilename)
+	if strings.HasSuffix(pa.filepath, ".md") {
+		fullOwnersPath = pa.filepath
+	}
+	link := fmt.Sprintf("%s/blob/%s/%v",
+		pa.baseURL.String(),
+		pa.branch,
+		fullOwnersPath,
+	)
+	str := fmt.Sprintf("- **[%s](%s)**", fullOwnersPath, link)
+	if pa.approvers.Len() > 0 {
+		str += fmt.Sprintf(" [%v]", strings.Join(pa.approvers.List(), ","))
+	}
+	var requirements []string
+	if pa.required > 1 {
+		requirements = append(requirements, fmt.Sprintf("%d/%d approvals", pa.approvers.Len(), pa.required))
+	}
+	if pa.invalidated.Len() > 0 {
+		requirements = append(requirements, fmt.Sprintf("approval by %s invalidated by new commits", strings.Join(pa.invalidated.List(), ",")))
+	}
+	return fmt.Sprintf("%s (%s)\n", str, strings.Join(requirements, ", "))
+}
+
 // GenerateTemplate takes a template, name and data, and generates
 // the corresponding string.
 func GenerateTemplate(templ, name string, data interface{}) (string, error) {
@@ -642,6 +809,9 @@ The pull request process is described [here]({{ .prProcessLink }})
 {{ end -}}
 <details {{if (and (not .ap.AreFilesApproved) (not (call .ap.ManuallyApproved))) }}open{{end}}>
 Needs approval from an approver in each of these files:
+{{- if .ap.RequiresMultipleApprovers}}
+Files showing a number of approvals need that many distinct approvers.
+{{- end}}
 
 {{range .ap.GetFiles .baseURL .branch}}{{.}}{{end}}
 Approvers can indicate their approval by writing `+"`/approve`"+` in a comment
@@ -35,9 +35,11 @@ const (
 )
 
 type FakeRepo struct {
-	approversMap      map[string]layeredsets.String
-	leafApproversMap  map[string]sets.String
-	noParentOwnersMap map[string]bool
+	approversMap         map[string]layeredsets.String
+	leafApproversMap     map[string]sets.String
+	noParentOwnersMap    map[string]bool
+	requiredApproversMap map[string]int
+	invalidateOnPushMap  map[string]bool
 }
 
 func (f FakeRepo) Filenames() ownersconfig.Filenames {
@@ -65,6 +67,17 @@ func (f FakeRepo) IsNoParentOwners(path string) bool {
 	return f.noParentOwnersMap[path]
 }
 
+func (f FakeRepo) RequiredApproverCount(path string) int {
+	if required, ok := f.requiredApproversMap[path]; ok {
+		return required
+	}
+	return 1
+}
+
+func (f FakeRepo) InvalidateOnPush(path string) bool {
+	return f.invalidateOnPushMap[path]
+}
+
 type dir struct {
 	fullPath  string
 	approvers sets.String
@@ -691,9 +704,11 @@ func TestGetShuffledApprovers(t *testing.T) {
 
 func TestRemoveSubdirs(t *testing.T) {
 	tests := []struct {
-		testName       string
-		directories    sets.String
-		noParentOwners map[string]bool
+		testName          string
+		directories       sets.String
+		noParentOwners    map[string]bool
+		requiredApprovers map[string]int
+		invalidateOnPush  map[string]bool
 
 		expected sets.String
 	}{
@@ -735,13 +750,35 @@ func TestRemoveSubdirs(t *testing.T) {
 			noParentOwners: map[string]bool{"a/b": true},
 			expected:       sets.NewString("a", "a/b"),
 		},
+		{
+			testName:          "Subdir requiring more approvers",
+			directories:       sets.NewString("a", "a/b", "a/c"),
+			requiredApprovers: map[string]int{"a/b": 2},
+			expected:          sets.NewString("a", "a/b"),
+		},
+		{
+			testName:          "Subdir requiring as many approvers as its parent",
+			directories:       sets.NewString("a", "a/b"),
+			requiredApprovers: map[string]int{"a": 2, "a/b": 2},
+			expected:          sets.NewString("a"),
+		},
+		{
+			testName:         "Subdir invalidating approvals on push",
+			directories:      sets.NewString("a", "a/b"),
+			invalidateOnPush: map[string]bool{"a/b": true},
+			expected:         sets.NewString("a", "a/b"),
+		},
 	}
 
 	for _, test := range tests {
 		if test.noParentOwners == nil {
 			test.noParentOwners = map[string]bool{}
 		}
-		o := &Owners{repo: FakeRepo{noParentOwnersMap: test.noParentOwners}}
+		o := &Owners{repo: FakeRepo{
+			noParentOwnersMap:    test.noParentOwners,
+			requiredApproversMap: test.requiredApprovers,
+			invalidateOnPushMap:  test.invalidateOnPush,
+		}}
 		o.removeSubdirs(test.directories)
 		if !reflect.DeepEqual(test.expected, test.directories) {
 			t.Errorf("Failed to remove subdirectories for test %v.  Expected files: %q. Found %q", test.testName, test.expected.List(), test.directories.List())
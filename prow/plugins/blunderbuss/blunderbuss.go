@@ -19,7 +19,10 @@ package blunderbuss
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"regexp"
+	"sync"
+	"time"
 
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
@@ -37,6 +40,10 @@ import (
 const (
 	// PluginName defines this plugin's registered name.
 	PluginName = "blunderbuss"
+
+	// pendingReviewsTTL is how long the pending review request count of a
+	// reviewer is reused before it is searched again.
+	pendingReviewsTTL = 10 * time.Minute
 )
 
 var (
@@ -68,13 +75,20 @@ func helpProvider(config *plugins.Configuration, _ []config.OrgRepo) (*pluginhel
 			MaxReviewerCount:      3,
 			ExcludeApprovers:      true,
 			UseStatusAvailability: true,
+			LoadBalancing: &plugins.BlunderbussLoadBalancing{
+				MaxPendingReviews: 10,
+				AwayReviewers:     []string{"vacationing-user"},
+				HistoryWindow:     "24h",
+			},
 		},
 	})
 	if err != nil {
 		logrus.WithError(err).Warnf("cannot generate comments for %s plugin", PluginName)
 	}
 	pluginHelp := &pluginhelp.PluginHelp{
-		Description: "The blunderbuss plugin automatically requests reviews from reviewers when a new PR is created. The reviewers are selected based on the reviewers specified in the OWNERS files that apply to the files modified by the PR.",
+		Description: fmt.Sprintf("The blunderbuss plugin automatically requests reviews from reviewers when a new PR is created. The reviewers are selected based on the reviewers specified in the OWNERS files that apply to the files modified by the PR. "+
+			"When load balancing is enabled, the pending review requests of each candidate are counted with a GitHub search whose result is reused for %s, "+
+			"and the reviews requested by blunderbuss are only remembered in memory: they are forgotten when hook restarts and are not shared between hook replicas, so the balancing is approximate.", pendingReviewsTTL),
 		Config: map[string]string{
 			"": configString(reviewCount),
 		},
@@ -125,6 +139,7 @@ type githubClient interface {
 	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	Query(context.Context, interface{}, map[string]interface{}) error
+	CountIssues(query string) (int, error)
 }
 
 type repoownersClient interface {
@@ -165,6 +180,7 @@ func handlePullRequest(ghc githubClient, roc repoownersClient, log *logrus.Entry
 		config.MaxReviewerCount,
 		config.ExcludeApprovers,
 		config.UseStatusAvailability,
+		newLoadBalancer(ghc, repo.Owner.Login, config.LoadBalancing, history),
 		repo,
 		pr,
 	)
@@ -207,12 +223,13 @@ func handleGenericComment(ghc githubClient, roc repoownersClient, log *logrus.En
 		config.MaxReviewerCount,
 		config.ExcludeApprovers,
 		config.UseStatusAvailability,
+		newLoadBalancer(ghc, repo.Owner.Login, config.LoadBalancing, history),
 		repo,
 		pr,
 	)
 }
 
-func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerCount *int, maxReviewers int, excludeApprovers bool, useStatusAvailability bool, repo *github.Repo, pr *github.PullRequest) error {
+func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerCount *int, maxReviewers int, excludeApprovers bool, useStatusAvailability bool, lb *loadBalancer, repo *github.Repo, pr *github.PullRequest) error {
 	oc, err := roc.LoadRepoOwners(repo.Owner.Login, repo.Name, pr.Base.Ref)
 	if err != nil {
 		return fmt.Errorf("error loading RepoOwners: %v", err)
@@ -226,7 +243,7 @@ func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerC
 	var reviewers []string
 	var requiredReviewers []string
 	if reviewerCount != nil {
-		reviewers, requiredReviewers, err = getReviewers(oc, ghc, log, pr.User.Login, changes, *reviewerCount, useStatusAvailability)
+		reviewers, requiredReviewers, err = getReviewers(oc, ghc, log, pr.User.Login, changes, *reviewerCount, useStatusAvailability, lb)
 		if err != nil {
 			return err
 		}
@@ -237,7 +254,7 @@ func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerC
 				// and approvers and the search might stop too early if it finds
 				// duplicates.
 				frc := fallbackReviewersClient{ownersClient: oc}
-				approvers, _, err := getReviewers(frc, ghc, log, pr.User.Login, changes, *reviewerCount, useStatusAvailability)
+				approvers, _, err := getReviewers(frc, ghc, log, pr.User.Login, changes, *reviewerCount, useStatusAvailability, lb)
 				if err != nil {
 					return err
 				}
@@ -268,12 +285,15 @@ func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerC
 
 	if len(reviewers) > 0 {
 		log.Infof("Requesting reviews from users %s.", reviewers)
-		return ghc.RequestReview(repo.Owner.Login, repo.Name, pr.Number, reviewers)
+		if err := ghc.RequestReview(repo.Owner.Login, repo.Name, pr.Number, reviewers); err != nil {
+			return err
+		}
+		lb.record(reviewers)
 	}
 	return nil
 }
 
-func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, author string, files []github.PullRequestChange, minReviewers int, useStatusAvailability bool) ([]string, []string, error) {
+func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, author string, files []github.PullRequestChange, minReviewers int, useStatusAvailability bool, lb *loadBalancer) ([]string, []string, error) {
 	authorSet := sets.NewString(github.NormLogin(author))
 	reviewers := layeredsets.NewString()
 	requiredReviewers := sets.NewString()
@@ -296,14 +316,14 @@ func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, autho
 			continue
 		}
 		leafReviewers = leafReviewers.Union(fileUnusedLeafs)
-		if r := findReviewer(ghc, log, useStatusAvailability, &busyReviewers, &fileUnusedLeafs); r != "" {
+		if r := findReviewer(ghc, log, useStatusAvailability, lb, &busyReviewers, &fileUnusedLeafs); r != "" {
 			reviewers.Insert(0, r)
 		}
 	}
 	// now ensure that we request review from at least minReviewers reviewers. Favor leaf reviewers.
 	unusedLeafs := leafReviewers.Difference(reviewers.Set())
 	for reviewers.Len() < minReviewers && unusedLeafs.Len() > 0 {
-		if r := findReviewer(ghc, log, useStatusAvailability, &busyReviewers, &unusedLeafs); r != "" {
+		if r := findReviewer(ghc, log, useStatusAvailability, lb, &busyReviewers, &unusedLeafs); r != "" {
 			reviewers.Insert(1, r)
 		}
 	}
@@ -313,7 +333,7 @@ func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, autho
 		}
 		fileReviewers := rc.Reviewers(file.Filename).Difference(authorSet)
 		for reviewers.Len() < minReviewers && fileReviewers.Len() > 0 {
-			if r := findReviewer(ghc, log, useStatusAvailability, &busyReviewers, &fileReviewers); r != "" {
+			if r := findReviewer(ghc, log, useStatusAvailability, lb, &busyReviewers, &fileReviewers); r != "" {
 				reviewers.Insert(2, r)
 			}
 		}
@@ -322,10 +342,10 @@ func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, autho
 }
 
 // findReviewer finds a reviewer from a set, potentially using status
-// availability.
-func findReviewer(ghc githubClient, log *logrus.Entry, useStatusAvailability bool, busyReviewers *sets.String, targetSet *layeredsets.String) string {
-	// if we don't care about status availability, just pop a target from the set
-	if !useStatusAvailability {
+// availability and load balancing.
+func findReviewer(ghc githubClient, log *logrus.Entry, useStatusAvailability bool, lb *loadBalancer, busyReviewers *sets.String, targetSet *layeredsets.String) string {
+	// if we don't care about status availability or load, just pop a target from the set
+	if !useStatusAvailability && lb == nil {
 		return targetSet.PopRandom()
 	}
 
@@ -335,7 +355,14 @@ func findReviewer(ghc githubClient, log *logrus.Entry, useStatusAvailability boo
 			// if there are no candidates left, then break
 			break
 		}
-		candidate := targetSet.PopRandom()
+		candidate := lb.pop(log, targetSet)
+		if candidate == "" {
+			// every remaining candidate is away or over capacity
+			break
+		}
+		if !useStatusAvailability {
+			return candidate
+		}
 		if busyReviewers.Has(candidate) {
 			// we've already verified this reviewer is busy
 			continue
@@ -371,3 +398,186 @@ func isUserBusy(ghc githubClient, user string) (bool, error) {
 	err := ghc.Query(ctx, &query, vars)
 	return bool(query.User.Status.IndicatesLimitedAvailability), err
 }
+
+// history remembers the reviews requested by blunderbuss across events so
+// that assignments are balanced over time. It lives in memory only, so it is
+// lost on restart and each hook replica keeps its own.
+var history = newAssignmentHistory()
+
+// assignmentHistory records when reviews were requested from each reviewer
+// and caches their pending review request counts.
+type assignmentHistory struct {
+	lock      sync.Mutex
+	requested map[string][]time.Time
+	pending   map[string]pendingReviews
+}
+
+// pendingReviews is the result of a search for the pending review requests
+// of a reviewer.
+type pendingReviews struct {
+	count   int
+	fetched time.Time
+}
+
+func newAssignmentHistory() *assignmentHistory {
+	return &assignmentHistory{
+		requested: map[string][]time.Time{},
+		pending:   map[string]pendingReviews{},
+	}
+}
+
+// pendingReviews returns the cached result of the query if it was fetched
+// less than pendingReviewsTTL before now.
+func (h *assignmentHistory) pendingReviews(query string, now time.Time) (int, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	pending, ok := h.pending[query]
+	if !ok || now.Sub(pending.fetched) >= pendingReviewsTTL {
+		return 0, false
+	}
+	return pending.count, true
+}
+
+// cachePendingReviews stores the result of the query, forgetting expired
+// results.
+func (h *assignmentHistory) cachePendingReviews(query string, count int, now time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for q, pending := range h.pending {
+		if now.Sub(pending.fetched) >= pendingReviewsTTL {
+			delete(h.pending, q)
+		}
+	}
+	h.pending[query] = pendingReviews{count: count, fetched: now}
+}
+
+// record notes that reviews were requested from the reviewers at now.
+func (h *assignmentHistory) record(reviewers []string, now time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, reviewer := range reviewers {
+		reviewer = github.NormLogin(reviewer)
+		h.requested[reviewer] = append(h.requested[reviewer], now)
+	}
+}
+
+// count returns how many reviews were requested from the reviewer since the
+// given time, forgetting older requests.
+func (h *assignmentHistory) count(reviewer string, since time.Time) int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	reviewer = github.NormLogin(reviewer)
+	var recent []time.Time
+	for _, t := range h.requested[reviewer] {
+		if t.After(since) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(h.requested, reviewer)
+		return 0
+	}
+	h.requested[reviewer] = recent
+	return len(recent)
+}
+
+// loadBalancer selects the least loaded reviewers, skipping those who are
+// away or already have too many pending review requests. A nil loadBalancer
+// selects reviewers at random.
+type loadBalancer struct {
+	ghc               githubClient
+	org               string
+	maxPendingReviews int
+	away              sets.String
+	window            time.Duration
+	history           *assignmentHistory
+	now               func() time.Time
+}
+
+func newLoadBalancer(ghc githubClient, org string, config *plugins.BlunderbussLoadBalancing, history *assignmentHistory) *loadBalancer {
+	if config == nil {
+		return nil
+	}
+	away := sets.NewString()
+	for _, login := range config.AwayReviewers {
+		away.Insert(github.NormLogin(login))
+	}
+	return &loadBalancer{
+		ghc:               ghc,
+		org:               org,
+		maxPendingReviews: config.MaxPendingReviews,
+		away:              away,
+		window:            config.HistoryWindowDuration,
+		history:           history,
+		now:               time.Now,
+	}
+}
+
+// pendingReviews returns the number of open pull requests in the org on
+// which a review is requested from the reviewer. Search results are cached
+// for pendingReviewsTTL so that each event does not search for every
+// candidate again.
+func (lb *loadBalancer) pendingReviews(reviewer string) (int, error) {
+	query := fmt.Sprintf("org:%s is:pr is:open review-requested:%s", lb.org, github.NormLogin(reviewer))
+	now := lb.now()
+	if count, ok := lb.history.pendingReviews(query, now); ok {
+		return count, nil
+	}
+	count, err := lb.ghc.CountIssues(query)
+	if err != nil {
+		return 0, err
+	}
+	lb.history.cachePendingReviews(query, count, now)
+	return count, nil
+}
+
+// pop removes and returns the least loaded candidate from the first layer
+// of the set that has an available candidate. Candidates who are away or
+// over capacity are dropped from the set. Ties are broken at random.
+func (lb *loadBalancer) pop(log *logrus.Entry, targetSet *layeredsets.String) string {
+	if lb == nil {
+		return targetSet.PopRandom()
+	}
+	since := lb.now().Add(-lb.window)
+	for _, layer := range *targetSet {
+		var best []string
+		bestLoad := -1
+		for _, candidate := range layer.List() {
+			if lb.away.Has(github.NormLogin(candidate)) {
+				log.Debugf("Skipping reviewer %s who is away.", candidate)
+				targetSet.Delete(candidate)
+				continue
+			}
+			pending, err := lb.pendingReviews(candidate)
+			if err != nil {
+				log.WithError(err).Errorf("error counting pending review requests for %s", candidate)
+			}
+			if lb.maxPendingReviews > 0 && pending >= lb.maxPendingReviews {
+				log.Debugf("Skipping reviewer %s with %d pending review requests.", candidate, pending)
+				targetSet.Delete(candidate)
+				continue
+			}
+			load := pending + lb.history.count(candidate, since)
+			switch {
+			case bestLoad == -1 || load < bestLoad:
+				best, bestLoad = []string{candidate}, load
+			case load == bestLoad:
+				best = append(best, candidate)
+			}
+		}
+		if len(best) > 0 {
+			sel := best[rand.Intn(len(best))]
+			targetSet.Delete(sel)
+			return sel
+		}
+	}
+	return ""
+}
+
+// record remembers that reviews were requested from the reviewers.
+func (lb *loadBalancer) record(reviewers []string) {
+	if lb == nil {
+		return
+	}
+	lb.history.record(reviewers, lb.now())
+}
@@ -26,6 +26,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
@@ -44,6 +45,8 @@ type fakeGitHubClient struct {
 	pr        *github.PullRequest
 	changes   []github.PullRequestChange
 	requested []string
+	pending   map[string]int
+	queries   []string
 }
 
 func newFakeGitHubClient(pr *github.PullRequest, filesChanged []string) *fakeGitHubClient {
@@ -97,6 +100,11 @@ func (c *fakeGitHubClient) Query(ctx context.Context, q interface{}, vars map[st
 	return nil
 }
 
+func (c *fakeGitHubClient) CountIssues(query string) (int, error) {
+	c.queries = append(c.queries, query)
+	return c.pending[query], nil
+}
+
 type fakeRepoownersClient struct {
 	foc *fakeOwnersClient
 }
@@ -155,6 +163,14 @@ func (foc *fakeOwnersClient) IsNoParentOwners(path string) bool {
 	return false
 }
 
+func (foc *fakeOwnersClient) RequiredApproverCount(path string) int {
+	return 1
+}
+
+func (foc *fakeOwnersClient) InvalidateOnPush(path string) bool {
+	return false
+}
+
 func (foc *fakeOwnersClient) ParseSimpleConfig(path string) (repoowners.SimpleConfig, error) {
 	dir := filepath.Dir(path)
 	for _, re := range foc.dirBlacklist {
@@ -334,7 +350,7 @@ func TestHandleWithExcludeApproversOnlyReviewers(t *testing.T) {
 
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, true, false, &repo, &pr,
+			&tc.reviewerCount, tc.maxReviewerCount, true, false, nil, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -376,7 +392,7 @@ func TestHandleWithoutExcludeApproversNoReviewers(t *testing.T) {
 
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, false, false, &repo, &pr,
+			&tc.reviewerCount, tc.maxReviewerCount, false, false, nil, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -516,7 +532,7 @@ func TestHandleWithoutExcludeApproversMixed(t *testing.T) {
 		fghc := newFakeGitHubClient(&pr, tc.filesChanged)
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, false, false, &repo, &pr,
+			&tc.reviewerCount, tc.maxReviewerCount, false, false, nil, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -809,7 +825,7 @@ func TestPopActiveReviewer(t *testing.T) {
 		fghc := newFakeGitHubClient(&pr, tc.filesChanged)
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, false, true, &repo, &pr,
+			&tc.reviewerCount, tc.maxReviewerCount, false, true, nil, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -829,3 +845,194 @@ func TestPopActiveReviewer(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadBalancerPop(t *testing.T) {
+	pendingQuery := func(user string) string {
+		return "org:org is:pr is:open review-requested:" + user
+	}
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	var testcases = []struct {
+		name      string
+		config    plugins.BlunderbussLoadBalancing
+		pending   map[string]int
+		history   map[string][]time.Time
+		targetSet layeredsets.String
+		expected  []string
+	}{
+		{
+			name:      "least pending reviews wins",
+			pending:   map[string]int{pendingQuery("alice"): 3, pendingQuery("bob"): 1, pendingQuery("carol"): 2},
+			targetSet: layeredsets.NewString("alice", "bob", "carol"),
+			expected:  []string{"bob", "carol", "alice"},
+		},
+		{
+			name:    "recent assignments count towards load",
+			pending: map[string]int{pendingQuery("alice"): 1, pendingQuery("bob"): 1},
+			history: map[string][]time.Time{
+				"bob":   {now.Add(-time.Hour)},
+				"alice": {now.Add(-48 * time.Hour), now.Add(-72 * time.Hour)},
+			},
+			targetSet: layeredsets.NewString("alice", "bob"),
+			expected:  []string{"alice", "bob"},
+		},
+		{
+			name:      "away reviewers are skipped",
+			config:    plugins.BlunderbussLoadBalancing{AwayReviewers: []string{"Alice"}},
+			targetSet: layeredsets.NewString("alice", "bob"),
+			expected:  []string{"bob"},
+		},
+		{
+			name:      "reviewers at capacity are skipped",
+			config:    plugins.BlunderbussLoadBalancing{MaxPendingReviews: 2},
+			pending:   map[string]int{pendingQuery("alice"): 2, pendingQuery("bob"): 1},
+			targetSet: layeredsets.NewString("alice", "bob"),
+			expected:  []string{"bob"},
+		},
+		{
+			name:      "layers are respected before load",
+			pending:   map[string]int{pendingQuery("alice"): 5},
+			targetSet: layeredsets.NewStringFromSlices([]string{"alice"}, []string{"bob"}),
+			expected:  []string{"alice", "bob"},
+		},
+		{
+			name:      "unavailable first layer falls through",
+			config:    plugins.BlunderbussLoadBalancing{AwayReviewers: []string{"alice"}},
+			targetSet: layeredsets.NewStringFromSlices([]string{"alice"}, []string{"bob"}),
+			expected:  []string{"bob"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.HistoryWindowDuration = 24 * time.Hour
+			h := newAssignmentHistory()
+			for user, times := range tc.history {
+				for _, when := range times {
+					h.record([]string{user}, when)
+				}
+			}
+			fghc := &fakeGitHubClient{pending: tc.pending}
+			lb := newLoadBalancer(fghc, "org", &tc.config, h)
+			lb.now = func() time.Time { return now }
+
+			var popped []string
+			for {
+				r := lb.pop(logrus.WithField("plugin", PluginName), &tc.targetSet)
+				if r == "" {
+					break
+				}
+				popped = append(popped, r)
+			}
+			if !reflect.DeepEqual(tc.expected, popped) {
+				t.Errorf("expected reviewers %v, got %v", tc.expected, popped)
+			}
+			if tc.targetSet.Len() != 0 {
+				t.Errorf("expected every candidate to be removed from the set, got %v", tc.targetSet.List())
+			}
+			queried := sets.NewString(fghc.queries...)
+			if queried.Len() != len(fghc.queries) {
+				t.Errorf("expected pending review requests to be looked up once per reviewer, got %v", fghc.queries)
+			}
+		})
+	}
+}
+
+func TestAssignmentHistory(t *testing.T) {
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	h := newAssignmentHistory()
+	h.record([]string{"Alice", "bob"}, now.Add(-2*time.Hour))
+	h.record([]string{"alice"}, now.Add(-30*time.Minute))
+
+	if count := h.count("alice", now.Add(-time.Hour)); count != 1 {
+		t.Errorf("expected 1 recent assignment for alice, got %d", count)
+	}
+	if count := h.count("ALICE", now.Add(-3*time.Hour)); count != 1 {
+		t.Errorf("expected older assignments to be forgotten, got %d", count)
+	}
+	if count := h.count("bob", now.Add(-time.Hour)); count != 0 {
+		t.Errorf("expected no recent assignment for bob, got %d", count)
+	}
+	if _, ok := h.requested["bob"]; ok {
+		t.Error("expected bob to be forgotten")
+	}
+}
+
+func TestPendingReviewsAreCached(t *testing.T) {
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	query := "org:org is:pr is:open review-requested:alice"
+	fghc := &fakeGitHubClient{pending: map[string]int{query: 2}}
+	h := newAssignmentHistory()
+	newLB := func(at time.Time) *loadBalancer {
+		lb := newLoadBalancer(fghc, "org", &plugins.BlunderbussLoadBalancing{HistoryWindowDuration: time.Hour}, h)
+		lb.now = func() time.Time { return at }
+		return lb
+	}
+
+	for _, at := range []time.Time{now, now.Add(pendingReviewsTTL - time.Second)} {
+		count, err := newLB(at).pendingReviews("Alice")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("expected 2 pending reviews, got %d", count)
+		}
+	}
+	if len(fghc.queries) != 1 {
+		t.Errorf("expected the search to be reused across events, got %v", fghc.queries)
+	}
+
+	fghc.pending[query] = 3
+	count, err := newLB(now.Add(pendingReviewsTTL)).pendingReviews("alice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 || len(fghc.queries) != 2 {
+		t.Errorf("expected an expired count to be searched again, got %d after %v", count, fghc.queries)
+	}
+}
+
+func TestHandleWithLoadBalancing(t *testing.T) {
+	froc := &fakeRepoownersClient{
+		foc: &fakeOwnersClient{
+			owners: map[string]string{
+				"a.go": "1",
+				"b.go": "1",
+			},
+			reviewers: map[string]layeredsets.String{
+				"a.go": layeredsets.NewString("alice", "bob", "carol", "dave"),
+				"b.go": layeredsets.NewString("alice", "bob", "carol", "dave"),
+			},
+			leafReviewers: map[string]sets.String{
+				"a.go": sets.NewString("alice", "bob", "carol", "dave"),
+				"b.go": sets.NewString("alice", "bob", "carol", "dave"),
+			},
+		},
+	}
+	pr := github.PullRequest{Number: 5, User: github.User{Login: "author"}}
+	repo := github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+	fghc := newFakeGitHubClient(&pr, []string{"a.go", "b.go"})
+	fghc.pending = map[string]int{
+		"org:org is:pr is:open review-requested:alice": 4,
+		"org:org is:pr is:open review-requested:bob":   1,
+		"org:org is:pr is:open review-requested:carol": 0,
+	}
+	h := newAssignmentHistory()
+	lb := newLoadBalancer(fghc, "org", &plugins.BlunderbussLoadBalancing{
+		MaxPendingReviews:     3,
+		AwayReviewers:         []string{"dave"},
+		HistoryWindowDuration: time.Hour,
+	}, h)
+
+	reviewerCount := 2
+	if err := handle(fghc, froc, logrus.WithField("plugin", PluginName), &reviewerCount, 0, true, false, lb, &repo, &pr); err != nil {
+		t.Fatalf("unexpected error from handle: %v", err)
+	}
+	sort.Strings(fghc.requested)
+	if expected := []string{"bob", "carol"}; !reflect.DeepEqual(expected, fghc.requested) {
+		t.Errorf("expected the requested reviewers to be %q, but got %q", expected, fghc.requested)
+	}
+	for _, reviewer := range []string{"bob", "carol"} {
+		if count := h.count(reviewer, time.Now().Add(-time.Hour)); count != 1 {
+			t.Errorf("expected one recorded assignment for %s, got %d", reviewer, count)
+		}
+	}
+}
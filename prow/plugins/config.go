@@ -140,6 +140,27 @@ type Blunderbuss struct {
 	// additional token per successful reviewer (and potentially more depending on
 	// how many busy reviewers it had to pass over).
 	UseStatusAvailability bool `json:"use_status_availability,omitempty"`
+	// LoadBalancing enables load-aware reviewer selection. When set, the
+	// candidates with the fewest pending review requests and recent
+	// assignments are preferred instead of picking reviewers at random.
+	LoadBalancing *BlunderbussLoadBalancing `json:"load_balancing,omitempty"`
+}
+
+// BlunderbussLoadBalancing configures how blunderbuss balances review load
+// across reviewers.
+type BlunderbussLoadBalancing struct {
+	// MaxPendingReviews is the maximum number of open pull requests in the
+	// org a reviewer may have pending review requests on before blunderbuss
+	// stops requesting reviews from them. Defaults to 0 meaning no limit.
+	MaxPendingReviews int `json:"max_pending_reviews,omitempty"`
+	// AwayReviewers lists the GitHub logins of reviewers who are away or have
+	// opted out of automatic review requests. They are never requested by
+	// blunderbuss, although required reviewers are still requested.
+	AwayReviewers []string `json:"away_reviewers,omitempty"`
+	// HistoryWindow is how long the reviews requested by blunderbuss count
+	// towards a reviewer's load. Defaults to "24h".
+	HistoryWindow         string        `json:"history_window,omitempty"`
+	HistoryWindowDuration time.Duration `json:"-"`
 }
 
 // Owners contains configuration related to handling OWNERS files.
@@ -289,6 +310,13 @@ type Approve struct {
 	// PrProcessLink is the link to the help page which explains the code review process.
 	// The default value is "https://git.k8s.io/community/contributors/guide/owners.md#the-code-review-process".
 	PrProcessLink string `json:"pr_process_link,omitempty"`
+	// RequiredApprovers is the number of distinct approvers needed for every
+	// OWNERS file. OWNERS files may require more with their required_approvers
+	// option. Defaults to 1.
+	RequiredApprovers int `json:"required_approvers,omitempty"`
+	// InvalidateOnPush discards approvals when new commits touch the approved
+	// files. OWNERS files may also enable this with their invalidate_on_push option.
+	InvalidateOnPush bool `json:"invalidate_on_push,omitempty"`
 }
 
 var (
@@ -883,6 +911,9 @@ func (c *Configuration) setDefaults() {
 		c.Blunderbuss.ReviewerCount = new(int)
 		*c.Blunderbuss.ReviewerCount = defaultBlunderbussReviewerCount
 	}
+	if c.Blunderbuss.LoadBalancing != nil && c.Blunderbuss.LoadBalancing.HistoryWindow == "" {
+		c.Blunderbuss.LoadBalancing.HistoryWindow = "24h"
+	}
 	for i := range c.Triggers {
 		c.Triggers[i].SetDefaults()
 	}
@@ -996,6 +1027,18 @@ func validateBlunderbuss(b *Blunderbuss) error {
 	if b.ReviewerCount != nil && *b.ReviewerCount < 1 {
 		return fmt.Errorf("invalid request_count: %v (needs to be positive)", *b.ReviewerCount)
 	}
+	if b.LoadBalancing != nil && b.LoadBalancing.MaxPendingReviews < 0 {
+		return fmt.Errorf("invalid load_balancing.max_pending_reviews: %v (cannot be negative)", b.LoadBalancing.MaxPendingReviews)
+	}
+	return nil
+}
+
+func validateApprove(approves []Approve) error {
+	for _, a := range approves {
+		if a.RequiredApprovers < 0 {
+			return fmt.Errorf("invalid required_approvers for %v: %v (cannot be negative)", a.Repos, a.RequiredApprovers)
+		}
+	}
 	return nil
 }
 
@@ -1129,6 +1172,14 @@ func compileRegexpsAndDurations(pc *Configuration) error {
 		}
 		rs[i].GracePeriodDuration = dur
 	}
+
+	if lb := pc.Blunderbuss.LoadBalancing; lb != nil {
+		dur, err := time.ParseDuration(lb.HistoryWindow)
+		if err != nil {
+			return fmt.Errorf("failed to compile blunderbuss history window duration: %q, error: %v", lb.HistoryWindow, err)
+		}
+		lb.HistoryWindowDuration = dur
+	}
 	return nil
 }
 
@@ -1153,6 +1204,9 @@ func (c *Configuration) Validate() error {
 	if err := validateBlunderbuss(&c.Blunderbuss); err != nil {
 		return err
 	}
+	if err := validateApprove(c.Approve); err != nil {
+		return err
+	}
 	if err := validateConfigUpdater(&c.ConfigUpdater); err != nil {
 		return err
 	}
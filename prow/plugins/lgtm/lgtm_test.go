@@ -92,6 +92,8 @@ func (f *fakeRepoOwners) FindApproverOwnersForFile(path string) string  { return
 func (f *fakeRepoOwners) FindReviewersOwnersForFile(path string) string { return "" }
 func (f *fakeRepoOwners) FindLabelsForFile(path string) sets.String     { return nil }
 func (f *fakeRepoOwners) IsNoParentOwners(path string) bool             { return false }
+func (f *fakeRepoOwners) RequiredApproverCount(path string) int         { return 1 }
+func (f *fakeRepoOwners) InvalidateOnPush(path string) bool             { return false }
 func (f *fakeRepoOwners) LeafApprovers(path string) sets.String         { return nil }
 func (f *fakeRepoOwners) Approvers(path string) layeredsets.String      { return f.approvers[path] }
 func (f *fakeRepoOwners) LeafReviewers(path string) sets.String         { return nil }
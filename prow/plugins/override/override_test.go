@@ -102,6 +102,14 @@ func (foc *fakeOwnersClient) IsNoParentOwners(path string) bool {
 	return false
 }
 
+func (foc *fakeOwnersClient) RequiredApproverCount(path string) int {
+	return 1
+}
+
+func (foc *fakeOwnersClient) InvalidateOnPush(path string) bool {
+	return false
+}
+
 func (foc *fakeOwnersClient) ParseSimpleConfig(path string) (repoowners.SimpleConfig, error) {
 	return repoowners.SimpleConfig{}, nil
 }
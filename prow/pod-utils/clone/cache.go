@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clone
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+// CachePathForRefs determines the path of the bare repository
+// that caches objects for refs under the cache directory. The path
+// depends on the repository being cloned, not on where it is checked
+// out, so that jobs using a path alias share the cache filled for
+// their org and repo.
+func CachePathForRefs(cacheDir string, refs prowapi.Refs) string {
+	var rel string
+	if refs.RepoLink != "" {
+		// Drop the protocol from the RepoLink
+		parts := strings.Split(refs.RepoLink, "://")
+		rel = parts[len(parts)-1]
+	} else {
+		rel = fmt.Sprintf("github.com/%s/%s", refs.Org, refs.Repo)
+	}
+	return filepath.Join(cacheDir, rel+".git")
+}
+
+// alternatesCommand points the objects of a freshly initialized
+// repository at a cached bare repository, if one exists, so that
+// subsequent fetches only transfer objects missing from the cache.
+type alternatesCommand struct {
+	cloneDir string
+	cacheDir string
+}
+
+func (c alternatesCommand) run() (string, string, error) {
+	objects := filepath.Join(c.cacheDir, "objects")
+	command := fmt.Sprintf("golang: use %q as alternate object store", objects)
+	if _, err := os.Stat(objects); err != nil {
+		// A missing cache is never fatal, we just fetch everything.
+		return command, fmt.Sprintf("skipped: %v", err), nil
+	}
+	alternates := filepath.Join(c.cloneDir, ".git", "objects", "info", "alternates")
+	if err := os.MkdirAll(filepath.Dir(alternates), 0755); err != nil {
+		return command, "", err
+	}
+	return command, "", ioutil.WriteFile(alternates, []byte(objects+"\n"), 0644)
+}
+
+// dissociateCommand copies any objects borrowed from the cache into
+// the repository and then removes the alternates file, so that the
+// checkout no longer depends on the cache once cloning is done.
+type dissociateCommand struct {
+	cloneDir string
+	env      []string
+}
+
+func (c dissociateCommand) run() (string, string, error) {
+	alternates := filepath.Join(c.cloneDir, ".git", "objects", "info", "alternates")
+	command := fmt.Sprintf("golang: dissociate %q from alternate object store", c.cloneDir)
+	if _, err := os.Stat(alternates); os.IsNotExist(err) {
+		return command, "skipped: no alternates", nil
+	}
+	repack := cloneCommand{dir: c.cloneDir, env: c.env, command: "git", args: []string{"repack", "-a", "-d"}}
+	_, output, err := repack.run()
+	if err != nil {
+		return command, output, err
+	}
+	return command, output, os.Remove(alternates)
+}
+
+// UpdateCache creates or refreshes the cached bare repository for refs
+// under cacheDir, fetching all branches and tags and then letting git
+// garbage collect as needed. An exclusive lock on the repository is held
+// while updating so that several maintainers sharing the same cache do
+// not write to it concurrently. Readers are safe throughout, since git
+// writes objects before refs and gc keeps recent unreachable objects.
+func UpdateCache(refs prowapi.Refs, cacheDir string, env []string, oauthToken string) Record {
+	record := Record{Refs: refs}
+	g := gitCtxForRefs(refs, "", env, oauthToken)
+	g.cloneDir = CachePathForRefs(cacheDir, refs)
+
+	log := logrus.WithField("cache", g.cloneDir)
+	if err := os.MkdirAll(g.cloneDir, 0755); err != nil {
+		log.WithError(err).Error("Failed to create cache directory")
+		record.Failed = true
+		return record
+	}
+	unlock, err := lockCache(g.cloneDir)
+	if err != nil {
+		log.WithError(err).Error("Failed to lock cache")
+		record.Failed = true
+		return record
+	}
+	defer unlock()
+
+	for _, command := range g.commandsForCache() {
+		formattedCommand, output, err := command.run()
+		log.WithFields(logrus.Fields{"command": formattedCommand, "output": output}).Info("Ran command")
+		message := ""
+		if err != nil {
+			message = err.Error()
+			record.Failed = true
+		}
+		record.Commands = append(record.Commands, Command{Command: censorToken(formattedCommand, oauthToken), Output: censorToken(output, oauthToken), Error: censorToken(message, oauthToken)})
+		if err != nil {
+			break
+		}
+	}
+	return record
+}
+
+// commandsForCache returns the list of commands needed to initialize
+// and refresh a cached bare repository.
+func (g *gitCtx) commandsForCache() []runnable {
+	return []runnable{
+		g.gitCommand("init", "--bare"),
+		g.gitFetch(g.repositoryURI, "--prune", "--tags", "+refs/heads/*:refs/heads/*"),
+		g.gitCommand("gc", "--auto"),
+	}
+}
+
+// lockCache takes an exclusive advisory lock next to the cached repository.
+func lockCache(dir string) (func(), error) {
+	f, err := os.OpenFile(dir+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+			logrus.WithError(err).WithField("cache", dir).Warn("Failed to unlock cache")
+		}
+		f.Close()
+	}, nil
+}
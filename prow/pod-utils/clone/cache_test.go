@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clone
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestRunWithCache(t *testing.T) {
+	upstream, err := makeFakeGitRepo(987654321)
+	defer os.RemoveAll(upstream)
+	if err != nil {
+		t.Fatalf("error creating fake git dir: %v", err)
+	}
+	branch, err := exec.Command("git", "-C", upstream, "symbolic-ref", "--short", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("error resolving upstream branch: %v", err)
+	}
+
+	cacheDir, err := ioutil.TempDir("", "clonecache")
+	if err != nil {
+		t.Fatalf("error creating cache dir: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	srcDir, err := ioutil.TempDir("", "clonesrc")
+	if err != nil {
+		t.Fatalf("error creating src dir: %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	refs := prowapi.Refs{
+		Org:            "org",
+		Repo:           "repo",
+		BaseRef:        strings.TrimSpace(string(branch)),
+		CloneURI:       upstream,
+		SkipSubmodules: true,
+	}
+
+	if record := UpdateCache(refs, cacheDir, nil, ""); record.Failed {
+		t.Fatalf("failed to update cache: %#v", record.Commands)
+	}
+	if _, err := os.Stat(filepath.Join(CachePathForRefs(cacheDir, refs), "objects")); err != nil {
+		t.Fatalf("cache was not populated: %v", err)
+	}
+	// refreshing an existing cache must work as well
+	if record := UpdateCache(refs, cacheDir, nil, ""); record.Failed {
+		t.Fatalf("failed to refresh cache: %#v", record.Commands)
+	}
+
+	record := Run(refs, srcDir, "", "", "", nil, "", cacheDir)
+	if record.Failed {
+		t.Fatalf("failed to clone with cache: %#v", record.Commands)
+	}
+	if record.FinalSHA == "" {
+		t.Error("expected a final SHA")
+	}
+	alternates := filepath.Join(PathForRefs(srcDir, refs), ".git", "objects", "info", "alternates")
+	if _, err := os.Stat(alternates); !os.IsNotExist(err) {
+		t.Errorf("expected clone to be dissociated from the cache, stat returned %v", err)
+	}
+}
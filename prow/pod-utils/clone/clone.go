@@ -40,7 +40,9 @@ type runnable interface {
 
 // Run clones the refs under the prescribed directory and optionally
 // configures the git username and email in the repository as well.
-func Run(refs prowapi.Refs, dir, gitUserName, gitUserEmail, cookiePath string, env []string, oauthToken string) Record {
+// If cacheDir holds a cached repository for the refs, its objects are
+// borrowed while fetching and copied into the clone afterwards.
+func Run(refs prowapi.Refs, dir, gitUserName, gitUserEmail, cookiePath string, env []string, oauthToken, cacheDir string) Record {
 	if len(oauthToken) > 0 {
 		logrus.SetFormatter(logrusutil.NewCensoringFormatter(logrus.StandardLogger().Formatter, func() sets.String {
 			return sets.NewString(oauthToken)
@@ -73,6 +75,9 @@ func Run(refs prowapi.Refs, dir, gitUserName, gitUserEmail, cookiePath string, e
 	}
 
 	g := gitCtxForRefs(refs, dir, env, oauthToken)
+	if cacheDir != "" {
+		g.cacheDir = CachePathForRefs(cacheDir, refs)
+	}
 	if err := runCommands(g.commandsForBaseRef(refs, gitUserName, gitUserEmail, cookiePath)); err != nil {
 		return record
 	}
@@ -124,6 +129,10 @@ type gitCtx struct {
 	cloneDir      string
 	env           []string
 	repositoryURI string
+	// cacheDir is the cached bare repository to borrow objects from, if any.
+	cacheDir string
+	// remoteURI is repositoryURI without any credentials embedded in it.
+	remoteURI string
 }
 
 // gitCtxForRefs creates a gitCtx based on the provide refs and baseDir.
@@ -143,6 +152,7 @@ func gitCtxForRefs(refs prowapi.Refs, baseDir string, env []string, oauthToken s
 	if refs.CloneURI != "" {
 		g.repositoryURI = refs.CloneURI
 	}
+	g.remoteURI = g.repositoryURI
 
 	if len(oauthToken) > 0 {
 		u, _ := url.Parse(g.repositoryURI)
@@ -177,6 +187,15 @@ func (g *gitCtx) gitFetch(fetchArgs ...string) retryCommand {
 	}
 }
 
+// fetchRemote returns what to fetch refs from: the promisor remote for
+// partial clones and the repository URI otherwise.
+func (g *gitCtx) fetchRemote(refs prowapi.Refs) string {
+	if refs.Filter != "" {
+		return "origin"
+	}
+	return g.repositoryURI
+}
+
 // commandsForBaseRef returns the list of commands needed to initialize and
 // configure a local git directory, as well as fetch and check out the provided
 // base ref.
@@ -185,6 +204,9 @@ func (g *gitCtx) commandsForBaseRef(refs prowapi.Refs, gitUserName, gitUserEmail
 	commands = append(commands, cloneCommand{dir: "/", env: g.env, command: "mkdir", args: []string{"-p", g.cloneDir}})
 
 	commands = append(commands, g.gitCommand("init"))
+	if g.cacheDir != "" {
+		commands = append(commands, alternatesCommand{cloneDir: g.cloneDir, cacheDir: g.cacheDir})
+	}
 	if gitUserName != "" {
 		commands = append(commands, g.gitCommand("config", "user.name", gitUserName))
 	}
@@ -195,20 +217,36 @@ func (g *gitCtx) commandsForBaseRef(refs prowapi.Refs, gitUserName, gitUserEmail
 		commands = append(commands, g.gitCommand("config", "http.cookiefile", cookiePath))
 	}
 
+	if refs.Filter != "" {
+		// partial clones fetch filtered objects lazily from their promisor
+		// remote, so we need a named remote rather than a bare URI
+		commands = append(commands, g.gitCommand("config", "remote.origin.url", g.repositoryURI))
+		commands = append(commands, g.gitCommand("config", "remote.origin.promisor", "true"))
+		commands = append(commands, g.gitCommand("config", "remote.origin.partialclonefilter", refs.Filter))
+	}
+	if len(refs.SparseCheckout) > 0 {
+		commands = append(commands, g.gitCommand("sparse-checkout", "init", "--cone"))
+		commands = append(commands, g.gitCommand(append([]string{"sparse-checkout", "set"}, refs.SparseCheckout...)...))
+	}
+
 	var depthArgs []string
 	if d := refs.CloneDepth; d > 0 {
 		depthArgs = append(depthArgs, "--depth", strconv.Itoa(d))
 	}
+	if refs.Filter != "" {
+		depthArgs = append(depthArgs, "--filter="+refs.Filter)
+	}
+	remote := g.fetchRemote(refs)
 
 	if !refs.SkipFetchHead {
-		fetchArgs := []string{g.repositoryURI, "--tags", "--prune"}
+		fetchArgs := []string{remote, "--tags", "--prune"}
 		fetchArgs = append(fetchArgs, depthArgs...)
 		commands = append(commands, g.gitFetch(fetchArgs...))
 	}
 
 	{
 		fetchArgs := append([]string{}, depthArgs...)
-		fetchArgs = append(fetchArgs, g.repositoryURI, refs.BaseRef)
+		fetchArgs = append(fetchArgs, remote, refs.BaseRef)
 		commands = append(commands, g.gitFetch(fetchArgs...))
 	}
 
@@ -287,7 +325,11 @@ func (g *gitCtx) commandsForPullRefs(refs prowapi.Refs, fakeTimestamp int) []run
 		if prRef.Ref != "" {
 			ref = prRef.Ref
 		}
-		commands = append(commands, g.gitFetch(g.repositoryURI, ref))
+		fetchArgs := []string{g.fetchRemote(refs), ref}
+		if refs.Filter != "" {
+			fetchArgs = append(fetchArgs, "--filter="+refs.Filter)
+		}
+		commands = append(commands, g.gitFetch(fetchArgs...))
 		var prCheckout string
 		if prRef.SHA != "" {
 			prCheckout = prRef.SHA
@@ -300,6 +342,16 @@ func (g *gitCtx) commandsForPullRefs(refs prowapi.Refs, fakeTimestamp int) []run
 		commands = append(commands, gitMergeCommand)
 	}
 
+	// stop depending on the cache before anything else reads the checkout
+	if g.cacheDir != "" {
+		commands = append(commands, dissociateCommand{cloneDir: g.cloneDir, env: g.env})
+	}
+
+	// the promisor remote outlives cloning, so do not leave credentials in it
+	if refs.Filter != "" && g.remoteURI != g.repositoryURI {
+		commands = append(commands, g.gitCommand("config", "remote.origin.url", g.remoteURI))
+	}
+
 	// unless the user specifically asks us not to, init submodules
 	if !refs.SkipSubmodules {
 		commands = append(commands, g.gitCommand("submodule", "update", "--init", "--recursive"))
@@ -60,6 +60,9 @@ const (
 	outputMountName         = "output"
 	outputMountPath         = "/output"
 	oauthTokenFilename      = "oauth-token"
+	cloneCacheMountName     = "clone-cache"
+	cloneCacheMountPath     = "/clone-cache"
+	censoringMountPath      = "/secrets/censoring"
 )
 
 // Labels returns a string slice with label consts from kube.
@@ -295,6 +298,37 @@ func oauthVolume(secret, key string) (coreapi.Volume, coreapi.VolumeMount) {
 		}
 }
 
+// cloneCacheVolume converts the clone cache config into the corresponding volume and mount.
+//
+// The cache is mounted read-only, as it is only written by clonerefs in cache maintenance mode.
+func cloneCacheVolume(cache prowapi.CloneCache) (coreapi.Volume, coreapi.VolumeMount) {
+	v := coreapi.Volume{Name: cloneCacheMountName}
+	if cache.PersistentVolumeClaim != "" {
+		v.VolumeSource = coreapi.VolumeSource{
+			PersistentVolumeClaim: &coreapi.PersistentVolumeClaimVolumeSource{
+				ClaimName: cache.PersistentVolumeClaim,
+				ReadOnly:  true,
+			},
+		}
+	} else {
+		hostPathType := coreapi.HostPathDirectoryOrCreate
+		v.VolumeSource = coreapi.VolumeSource{
+			HostPath: &coreapi.HostPathVolumeSource{
+				Path: cache.HostPath,
+				Type: &hostPathType,
+			},
+		}
+	}
+
+	vm := coreapi.VolumeMount{
+		Name:      cloneCacheMountName,
+		MountPath: cloneCacheMountPath,
+		ReadOnly:  true,
+	}
+
+	return v, vm
+}
+
 // sshVolume converts a secret holding ssh keys into the corresponding volume and mount.
 //
 // This is used by CloneRefs to attach the mount to the clonerefs container.
@@ -406,6 +440,14 @@ func CloneRefs(pj prowapi.ProwJob, codeMount, logMount coreapi.VolumeMount) (*co
 		cloneVolumes = append(cloneVolumes, oauthVolume)
 	}
 
+	var cacheDir string
+	if pj.Spec.DecorationConfig.CloneCache != nil {
+		cacheVolume, cacheMount := cloneCacheVolume(*pj.Spec.DecorationConfig.CloneCache)
+		cloneMounts = append(cloneMounts, cacheMount)
+		cacheDir = cacheMount.MountPath
+		cloneVolumes = append(cloneVolumes, cacheVolume)
+	}
+
 	volume, mount := tmpVolume("clonerefs-tmp")
 	cloneMounts = append(cloneMounts, mount)
 	cloneVolumes = append(cloneVolumes, volume)
@@ -431,6 +473,7 @@ func CloneRefs(pj prowapi.ProwJob, codeMount, logMount coreapi.VolumeMount) (*co
 		Log:              CloneLogPath(logMount),
 		SrcRoot:          codeMount.MountPath,
 		OauthTokenFile:   oauthMountPath,
+		CacheDir:         cacheDir,
 	})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("clone env: %v", err)
@@ -477,12 +520,42 @@ func artifactsDir(log coreapi.VolumeMount) string {
 	return filepath.Join(log.MountPath, "artifacts")
 }
 
+func stepArtifactsDir(log coreapi.VolumeMount, step string) string {
+	return filepath.Join(artifactsDir(log), step)
+}
+
 func entrypointLocation(tools coreapi.VolumeMount) string {
 	return filepath.Join(tools.MountPath, "entrypoint")
 }
 
 // InjectEntrypoint will make the entrypoint binary in the tools volume the container's entrypoint, which will output to the log volume.
 func InjectEntrypoint(c *coreapi.Container, timeout, gracePeriod time.Duration, prefix, previousMarker string, exitZero bool, log, tools coreapi.VolumeMount) (*wrapper.Options, error) {
+	return injectEntrypoint(c, entrypoint.Options{
+		ArtifactDir:    artifactsDir(log),
+		GracePeriod:    gracePeriod,
+		Timeout:        timeout,
+		AlwaysZero:     exitZero,
+		PreviousMarker: previousMarker,
+	}, prefix, log, tools)
+}
+
+// injectStepEntrypoint wraps a test container that runs as a named step,
+// writing its artifacts to a directory of its own and waiting for the steps
+// it depends on.
+func injectStepEntrypoint(c *coreapi.Container, timeout, gracePeriod time.Duration, dependsOn []string, log, tools coreapi.VolumeMount) (*wrapper.Options, error) {
+	var previousMarkers []string
+	for _, dep := range dependsOn {
+		previousMarkers = append(previousMarkers, markerFile(log, dep))
+	}
+	return injectEntrypoint(c, entrypoint.Options{
+		ArtifactDir:     stepArtifactsDir(log, c.Name),
+		GracePeriod:     gracePeriod,
+		Timeout:         timeout,
+		PreviousMarkers: previousMarkers,
+	}, c.Name, log, tools)
+}
+
+func injectEntrypoint(c *coreapi.Container, opts entrypoint.Options, prefix string, log, tools coreapi.VolumeMount) (*wrapper.Options, error) {
 	wrapperOptions := &wrapper.Options{
 		Args:          append(c.Command, c.Args...),
 		ContainerName: c.Name,
@@ -490,15 +563,9 @@ func InjectEntrypoint(c *coreapi.Container, timeout, gracePeriod time.Duration,
 		MarkerFile:    markerFile(log, prefix),
 		MetadataFile:  metadataFile(log, prefix),
 	}
+	opts.Options = wrapperOptions
 	// TODO(fejta): use flags
-	entrypointConfigEnv, err := entrypoint.Encode(entrypoint.Options{
-		ArtifactDir:    artifactsDir(log),
-		GracePeriod:    gracePeriod,
-		Options:        wrapperOptions,
-		Timeout:        timeout,
-		AlwaysZero:     exitZero,
-		PreviousMarker: previousMarker,
-	})
+	entrypointConfigEnv, err := entrypoint.Encode(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -698,8 +765,20 @@ func decorate(spec *coreapi.PodSpec, pj *prowapi.ProwJob, rawEnv map[string]stri
 		*initUpload,
 		PlaceEntrypoint(pj.Spec.DecorationConfig, toolsMount),
 	)
+	steps := map[string]prowapi.Step{}
+	for _, step := range pj.Spec.DecorationConfig.Steps {
+		steps[step.Name] = step
+	}
 	for i, container := range spec.Containers {
-		spec.Containers[i].Env = append(container.Env, KubeEnv(rawEnv)...)
+		env := rawEnv
+		if _, isStep := steps[container.Name]; isStep {
+			env = map[string]string{}
+			for k, v := range rawEnv {
+				env[k] = v
+			}
+			env[artifactsEnv] = stepArtifactsDir(logMount, container.Name)
+		}
+		spec.Containers[i].Env = append(container.Env, KubeEnv(env)...)
 	}
 
 	const (
@@ -709,24 +788,41 @@ func decorate(spec *coreapi.PodSpec, pj *prowapi.ProwJob, rawEnv map[string]stri
 	var wrappers []wrapper.Options
 
 	for i, container := range spec.Containers {
-		prefix := container.Name
-		if len(spec.Containers) == 1 {
-			prefix = ""
+		var wrapperOptions *wrapper.Options
+		var err error
+		if step, isStep := steps[container.Name]; isStep {
+			wrapperOptions, err = injectStepEntrypoint(&spec.Containers[i], pj.Spec.DecorationConfig.Timeout.Get(), pj.Spec.DecorationConfig.GracePeriod.Get(), step.DependsOn, logMount, toolsMount)
+		} else {
+			prefix := container.Name
+			if len(spec.Containers) == 1 {
+				prefix = ""
+			}
+			wrapperOptions, err = InjectEntrypoint(&spec.Containers[i], pj.Spec.DecorationConfig.Timeout.Get(), pj.Spec.DecorationConfig.GracePeriod.Get(), prefix, previous, exitZero, logMount, toolsMount)
 		}
-		wrapperOptions, err := InjectEntrypoint(&spec.Containers[i], pj.Spec.DecorationConfig.Timeout.Get(), pj.Spec.DecorationConfig.GracePeriod.Get(), prefix, previous, exitZero, logMount, toolsMount)
 		if err != nil {
 			return fmt.Errorf("wrap container: %v", err)
 		}
 		wrappers = append(wrappers, *wrapperOptions)
 	}
 
-	sidecar, err := Sidecar(pj.Spec.DecorationConfig, blobStorageOptions, blobStorageMounts, logMount, outputMount, encodedJobSpec, !RequirePassingEntries, !IgnoreInterrupts, wrappers...)
+	var censoringVolumes []coreapi.Volume
+	var secretVolumeMounts []coreapi.VolumeMount
+	if pj.Spec.DecorationConfig.CensorSecrets != nil && *pj.Spec.DecorationConfig.CensorSecrets {
+		decorationVolumes := blobStorageVolumes
+		if len(refs) > 0 {
+			decorationVolumes = append(decorationVolumes[:len(decorationVolumes):len(decorationVolumes)], cloneVolumes...)
+		}
+		censoringVolumes, secretVolumeMounts = censoringMounts(spec, decorationVolumes)
+	}
+
+	sidecar, err := Sidecar(pj.Spec.DecorationConfig, blobStorageOptions, blobStorageMounts, logMount, outputMount, secretVolumeMounts, encodedJobSpec, !RequirePassingEntries, !IgnoreInterrupts, wrappers...)
 	if err != nil {
 		return fmt.Errorf("create sidecar: %v", err)
 	}
 
 	spec.Volumes = append(spec.Volumes, logVolume, toolsVolume)
 	spec.Volumes = append(spec.Volumes, blobStorageVolumes...)
+	spec.Volumes = append(spec.Volumes, censoringVolumes...)
 	if outputVolume != nil {
 		spec.Volumes = append(spec.Volumes, *outputVolume)
 	}
@@ -771,14 +867,111 @@ const (
 	IgnoreInterrupts = true
 )
 
-func Sidecar(config *prowapi.DecorationConfig, gcsOptions gcsupload.Options, blobStorageMounts []coreapi.VolumeMount, logMount coreapi.VolumeMount, outputMount *coreapi.VolumeMount, encodedJobSpec string, requirePassingEntries, ignoreInterrupts bool, wrappers ...wrapper.Options) (*coreapi.Container, error) {
+// censoringMounts mounts every secret used by the test containers, through
+// secret volumes, projected volumes or the environment, along with the secret
+// volumes added for the decoration config itself, into the sidecar, so that
+// it can censor their values from the output. It returns the volumes that
+// must be added to the pod for secrets that are not already mounted as a
+// volume of their own.
+func censoringMounts(spec *coreapi.PodSpec, decorationVolumes []coreapi.Volume) ([]coreapi.Volume, []coreapi.VolumeMount) {
+	used := sets.NewString()
+	envSecrets := sets.NewString()
+	for _, container := range spec.Containers {
+		for _, mount := range container.VolumeMounts {
+			used.Insert(mount.Name)
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				envSecrets.Insert(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				envSecrets.Insert(envFrom.SecretRef.Name)
+			}
+		}
+	}
+
+	var added []coreapi.Volume
+	var volumes []coreapi.Volume
+	for _, volume := range spec.Volumes {
+		if !used.Has(volume.Name) {
+			continue
+		}
+		if volume.Projected == nil {
+			volumes = append(volumes, volume)
+			continue
+		}
+		// Only the secrets of a projected volume are censored, so they are
+		// projected again on their own.
+		var sources []coreapi.VolumeProjection
+		for _, source := range volume.Projected.Sources {
+			if source.Secret != nil {
+				sources = append(sources, coreapi.VolumeProjection{Secret: source.Secret})
+			}
+		}
+		if len(sources) == 0 {
+			continue
+		}
+		added = append(added, coreapi.Volume{
+			Name: fmt.Sprintf("censoring-projected-%d", len(added)),
+			VolumeSource: coreapi.VolumeSource{
+				Projected: &coreapi.ProjectedVolumeSource{Sources: sources},
+			},
+		})
+	}
+	// Secrets are optional here, the test containers already require
+	// the ones they don't allow to be missing.
+	optional := true
+	for _, name := range envSecrets.List() {
+		added = append(added, coreapi.Volume{
+			Name: fmt.Sprintf("censoring-env-%d", len(added)),
+			VolumeSource: coreapi.VolumeSource{
+				Secret: &coreapi.SecretVolumeSource{SecretName: name, Optional: &optional},
+			},
+		})
+	}
+	volumes = append(volumes, decorationVolumes...)
+	volumes = append(volumes, added...)
+
+	mounted := sets.NewString()
+	var mounts []coreapi.VolumeMount
+	for _, volume := range volumes {
+		if (volume.Secret == nil && volume.Projected == nil) || mounted.Has(volume.Name) {
+			continue
+		}
+		mounted.Insert(volume.Name)
+		mounts = append(mounts, coreapi.VolumeMount{
+			Name:      volume.Name,
+			MountPath: path.Join(censoringMountPath, volume.Name),
+			ReadOnly:  true,
+		})
+	}
+	return added, mounts
+}
+
+// Sidecar creates the container that uploads the logs and artifacts of the
+// test containers. If any secret volume mounts are given, their values are
+// censored from the output before uploading.
+func Sidecar(config *prowapi.DecorationConfig, gcsOptions gcsupload.Options, blobStorageMounts []coreapi.VolumeMount, logMount coreapi.VolumeMount, outputMount *coreapi.VolumeMount, secretVolumeMounts []coreapi.VolumeMount, encodedJobSpec string, requirePassingEntries, ignoreInterrupts bool, wrappers ...wrapper.Options) (*coreapi.Container, error) {
 	gcsOptions.Items = append(gcsOptions.Items, artifactsDir(logMount))
-	sidecarConfigEnv, err := sidecar.Encode(sidecar.Options{
+	options := sidecar.Options{
 		GcsOptions:       &gcsOptions,
 		Entries:          wrappers,
 		EntryError:       requirePassingEntries,
 		IgnoreInterrupts: ignoreInterrupts,
-	})
+	}
+	if len(secretVolumeMounts) > 0 {
+		options.CensoringOptions = &sidecar.CensoringOptions{}
+		for _, mount := range secretVolumeMounts {
+			options.CensoringOptions.SecretDirectories = append(options.CensoringOptions.SecretDirectories, mount.MountPath)
+		}
+		if config.CensoringOptions != nil {
+			options.CensoringOptions.IncludeDirectories = config.CensoringOptions.IncludeDirectories
+			options.CensoringOptions.ExcludeDirectories = config.CensoringOptions.ExcludeDirectories
+		}
+	}
+	sidecarConfigEnv, err := sidecar.Encode(options)
 	if err != nil {
 		return nil, err
 	}
@@ -787,6 +980,7 @@ func Sidecar(config *prowapi.DecorationConfig, gcsOptions gcsupload.Options, blo
 	if outputMount != nil {
 		mounts = append(mounts, *outputMount)
 	}
+	mounts = append(mounts, secretVolumeMounts...)
 
 	container := &coreapi.Container{
 		Name:    sidecarName,
@@ -388,6 +388,47 @@ func TestCloneRefs(t *testing.T) {
 				tmpVolume,
 			},
 		},
+		{
+			name: "include clone cache when set",
+			pj: prowapi.ProwJob{
+				Spec: prowapi.ProwJobSpec{
+					ExtraRefs: []prowapi.Refs{{}},
+					DecorationConfig: &prowapi.DecorationConfig{
+						UtilityImages: &prowapi.UtilityImages{},
+						CloneCache: &prowapi.CloneCache{
+							PersistentVolumeClaim: "git-cache",
+						},
+					},
+				},
+			},
+			expected: &coreapi.Container{
+				Name:    cloneRefsName,
+				Command: []string{cloneRefsCommand},
+				Env: envOrDie(clonerefs.Options{
+					GitRefs:      []prowapi.Refs{{}},
+					GitUserEmail: clonerefs.DefaultGitUserEmail,
+					GitUserName:  clonerefs.DefaultGitUserName,
+					SrcRoot:      codeMount.MountPath,
+					Log:          CloneLogPath(logMount),
+					CacheDir:     "/clone-cache",
+				}),
+				VolumeMounts: []coreapi.VolumeMount{logMount, codeMount,
+					{Name: "clone-cache", ReadOnly: true, MountPath: "/clone-cache"}, tmpMount,
+				},
+			},
+			volumes: []coreapi.Volume{
+				{
+					Name: "clone-cache",
+					VolumeSource: coreapi.VolumeSource{
+						PersistentVolumeClaim: &coreapi.PersistentVolumeClaimVolumeSource{
+							ClaimName: "git-cache",
+							ReadOnly:  true,
+						},
+					},
+				},
+				tmpVolume,
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -909,6 +950,114 @@ func TestProwJobToPod(t *testing.T) {
 				},
 			},
 		},
+		{
+			podName: "pod",
+			buildID: "blabla",
+			labels:  map[string]string{"needstobe": "inherited"},
+			pjSpec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "job-name",
+				DecorationConfig: &prowapi.DecorationConfig{
+					Timeout:     &prowapi.Duration{Duration: 120 * time.Minute},
+					GracePeriod: &prowapi.Duration{Duration: 10 * time.Second},
+					UtilityImages: &prowapi.UtilityImages{
+						CloneRefs:  "clonerefs:tag",
+						InitUpload: "initupload:tag",
+						Entrypoint: "entrypoint:tag",
+						Sidecar:    "sidecar:tag",
+					},
+					GCSConfiguration: &prowapi.GCSConfiguration{
+						Bucket:       "my-bucket",
+						PathStrategy: "legacy",
+						DefaultOrg:   "kubernetes",
+						DefaultRepo:  "kubernetes",
+					},
+					GCSCredentialsSecret: pStr("secret-name"),
+					Steps: []prowapi.Step{
+						{Name: "build"},
+						{Name: "test", DependsOn: []string{"build"}},
+					},
+				},
+				Agent: prowapi.KubernetesAgent,
+				PodSpec: &coreapi.PodSpec{
+					Containers: []coreapi.Container{
+						{
+							Name:    "build",
+							Image:   "builder",
+							Command: []string{"/bin/build"},
+						},
+						{
+							Name:    "test",
+							Image:   "tester",
+							Command: []string{"/bin/test"},
+						},
+					},
+				},
+			},
+		},
+		{
+			podName: "pod",
+			buildID: "blabla",
+			labels:  map[string]string{"needstobe": "inherited"},
+			pjSpec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "job-name",
+				DecorationConfig: &prowapi.DecorationConfig{
+					Timeout:     &prowapi.Duration{Duration: 120 * time.Minute},
+					GracePeriod: &prowapi.Duration{Duration: 10 * time.Second},
+					UtilityImages: &prowapi.UtilityImages{
+						CloneRefs:  "clonerefs:tag",
+						InitUpload: "initupload:tag",
+						Entrypoint: "entrypoint:tag",
+						Sidecar:    "sidecar:tag",
+					},
+					GCSConfiguration: &prowapi.GCSConfiguration{
+						Bucket:       "my-bucket",
+						PathStrategy: "legacy",
+						DefaultOrg:   "kubernetes",
+						DefaultRepo:  "kubernetes",
+					},
+					GCSCredentialsSecret: pStr("secret-name"),
+					CensorSecrets:        &truth,
+					CensoringOptions: &prowapi.CensoringOptions{
+						ExcludeDirectories: []string{"bundles"},
+					},
+				},
+				Agent: prowapi.KubernetesAgent,
+				PodSpec: &coreapi.PodSpec{
+					Containers: []coreapi.Container{
+						{
+							Image:   "tester",
+							Command: []string{"/bin/thing"},
+							VolumeMounts: []coreapi.VolumeMount{
+								{Name: "service-account", MountPath: "/etc/service-account", ReadOnly: true},
+								{Name: "cache", MountPath: "/cache"},
+							},
+						},
+					},
+					Volumes: []coreapi.Volume{
+						{
+							Name: "service-account",
+							VolumeSource: coreapi.VolumeSource{
+								Secret: &coreapi.SecretVolumeSource{SecretName: "service-account"},
+							},
+						},
+						{
+							Name: "unused",
+							VolumeSource: coreapi.VolumeSource{
+								Secret: &coreapi.SecretVolumeSource{SecretName: "unused"},
+							},
+						},
+						{
+							Name: "cache",
+							VolumeSource: coreapi.VolumeSource{
+								EmptyDir: &coreapi.EmptyDirVolumeSource{},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	findContainer := func(name string, pod coreapi.Pod) *coreapi.Container {
@@ -1050,3 +1199,62 @@ func TestProwJobToPod_setsTerminationGracePeriodSeconds(t *testing.T) {
 		})
 	}
 }
+
+func TestCensoringMounts(t *testing.T) {
+	secretVolume := func(name string) coreapi.Volume {
+		return coreapi.Volume{Name: name, VolumeSource: coreapi.VolumeSource{Secret: &coreapi.SecretVolumeSource{SecretName: name}}}
+	}
+	projected := func(name string, sources ...coreapi.VolumeProjection) coreapi.Volume {
+		return coreapi.Volume{Name: name, VolumeSource: coreapi.VolumeSource{Projected: &coreapi.ProjectedVolumeSource{Sources: sources}}}
+	}
+	secretProjection := coreapi.VolumeProjection{Secret: &coreapi.SecretProjection{LocalObjectReference: coreapi.LocalObjectReference{Name: "projected-secret"}}}
+	configMapProjection := coreapi.VolumeProjection{ConfigMap: &coreapi.ConfigMapProjection{LocalObjectReference: coreapi.LocalObjectReference{Name: "config"}}}
+	spec := &coreapi.PodSpec{
+		Containers: []coreapi.Container{{
+			Name:         "test",
+			VolumeMounts: []coreapi.VolumeMount{{Name: "service-account"}, {Name: "cache"}, {Name: "projected"}, {Name: "config"}},
+			Env: []coreapi.EnvVar{
+				{Name: "PLAIN", Value: "value"},
+				{Name: "TOKEN", ValueFrom: &coreapi.EnvVarSource{SecretKeyRef: &coreapi.SecretKeySelector{LocalObjectReference: coreapi.LocalObjectReference{Name: "token"}, Key: "token"}}},
+			},
+			EnvFrom: []coreapi.EnvFromSource{
+				{SecretRef: &coreapi.SecretEnvSource{LocalObjectReference: coreapi.LocalObjectReference{Name: "env"}}},
+				{ConfigMapRef: &coreapi.ConfigMapEnvSource{LocalObjectReference: coreapi.LocalObjectReference{Name: "config"}}},
+			},
+		}},
+		Volumes: []coreapi.Volume{
+			secretVolume("service-account"),
+			secretVolume("unused"),
+			{Name: "cache", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}},
+			projected("projected", secretProjection, configMapProjection),
+			projected("config", configMapProjection),
+		},
+	}
+	oauth, _ := oauthVolume("oauth-secret", "oauth")
+	ssh, _ := sshVolume("ssh-secret")
+	tmp, _ := tmpVolume("clonerefs-tmp")
+	decorationVolumes := []coreapi.Volume{secretVolume("gcs-credentials"), oauth, ssh, tmp, secretVolume("service-account")}
+
+	optional := true
+	expectedVolumes := []coreapi.Volume{
+		projected("censoring-projected-0", secretProjection),
+		{Name: "censoring-env-1", VolumeSource: coreapi.VolumeSource{Secret: &coreapi.SecretVolumeSource{SecretName: "env", Optional: &optional}}},
+		{Name: "censoring-env-2", VolumeSource: coreapi.VolumeSource{Secret: &coreapi.SecretVolumeSource{SecretName: "token", Optional: &optional}}},
+	}
+	expectedMounts := []coreapi.VolumeMount{
+		{Name: "service-account", MountPath: "/secrets/censoring/service-account", ReadOnly: true},
+		{Name: "gcs-credentials", MountPath: "/secrets/censoring/gcs-credentials", ReadOnly: true},
+		{Name: "oauth-secret", MountPath: "/secrets/censoring/oauth-secret", ReadOnly: true},
+		{Name: "ssh-keys-ssh-secret", MountPath: "/secrets/censoring/ssh-keys-ssh-secret", ReadOnly: true},
+		{Name: "censoring-projected-0", MountPath: "/secrets/censoring/censoring-projected-0", ReadOnly: true},
+		{Name: "censoring-env-1", MountPath: "/secrets/censoring/censoring-env-1", ReadOnly: true},
+		{Name: "censoring-env-2", MountPath: "/secrets/censoring/censoring-env-2", ReadOnly: true},
+	}
+	volumes, mounts := censoringMounts(spec, decorationVolumes)
+	if !equality.Semantic.DeepEqual(expectedVolumes, volumes) {
+		t.Errorf("unexpected censoring volumes: %s", diff.ObjectReflectDiff(expectedVolumes, volumes))
+	}
+	if !equality.Semantic.DeepEqual(expectedMounts, mounts) {
+		t.Errorf("unexpected censoring mounts: %s", diff.ObjectReflectDiff(expectedMounts, mounts))
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
+)
+
+// ExpirationMetadataKey is the object metadata key holding the time,
+// in RFC 3339 format, after which the reaper may delete the object.
+const ExpirationMetadataKey = "prow-expiration"
+
+const (
+	// RetentionClassLogs holds logs and metadata uploaded for a job
+	RetentionClassLogs = "logs"
+	// RetentionClassArtifacts holds files from the artifacts directory
+	RetentionClassArtifacts = "artifacts"
+)
+
+// PreservedFromRetention determines if an object is part of the job
+// history and must never expire, regardless of the retention policy.
+func PreservedFromRetention(name string) bool {
+	switch base := path.Base(name); base {
+	case prowapi.StartedStatusFile, prowapi.FinishedStatusFile, "build-log.txt":
+		return true
+	default:
+		return strings.HasSuffix(base, "-build-log.txt")
+	}
+}
+
+// RetentionClassForPath determines the retention class of an object from
+// its path relative to the directory of the job run, returning an empty
+// class for objects that are never deleted.
+func RetentionClassForPath(relPath string) string {
+	if PreservedFromRetention(relPath) {
+		return ""
+	}
+	if strings.HasPrefix(relPath, "artifacts/") {
+		return RetentionClassArtifacts
+	}
+	return RetentionClassLogs
+}
+
+// ExpirationForPath determines when an object uploaded at the given time
+// to relPath under the directory of the job run expires, if ever.
+func ExpirationForPath(retention *prowapi.Retention, relPath string, uploaded time.Time) (time.Time, bool) {
+	if retention == nil {
+		return time.Time{}, false
+	}
+	var value string
+	switch RetentionClassForPath(relPath) {
+	case RetentionClassLogs:
+		value = retention.Logs
+	case RetentionClassArtifacts:
+		value = retention.Artifacts
+	}
+	if value == "" {
+		return time.Time{}, false
+	}
+	duration, err := prowapi.ParseRetention(value)
+	if err != nil {
+		logrus.WithError(err).WithField("path", relPath).Warn("Ignoring invalid retention, object will be kept")
+		return time.Time{}, false
+	}
+	return uploaded.Add(duration), true
+}
+
+// ExpirationFromMetadata reads the expiration recorded in object metadata.
+func ExpirationFromMetadata(metadata map[string]string) (time.Time, bool) {
+	value, ok := metadata[ExpirationMetadataKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	expiration, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiration, true
+}
+
+// WithExpiration returns an UploadFunc which records the expiration
+// in the metadata of the object written by upload, along with any
+// metadata upload sets itself.
+func WithExpiration(upload UploadFunc, expiration time.Time) UploadFunc {
+	return func(writer dataWriter) error {
+		w := &expiringWriter{dataWriter: writer, expiration: expiration.UTC().Format(time.RFC3339)}
+		w.ApplyWriterOptions(pkgio.WriterOptions{})
+		return upload(w)
+	}
+}
+
+// expiringWriter adds the expiration to the metadata of every set of
+// options applied to the writer, since later options replace the
+// metadata of earlier ones.
+type expiringWriter struct {
+	dataWriter
+	expiration string
+}
+
+func (w *expiringWriter) ApplyWriterOptions(opts pkgio.WriterOptions) {
+	metadata := map[string]string{ExpirationMetadataKey: w.expiration}
+	for key, value := range opts.Metadata {
+		if key != ExpirationMetadataKey {
+			metadata[key] = value
+		}
+	}
+	opts.Metadata = metadata
+	w.dataWriter.ApplyWriterOptions(opts)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/io"
+)
+
+func TestExpirationForPath(t *testing.T) {
+	uploaded := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	retention := &prowapi.Retention{Logs: "90d", Artifacts: "14d"}
+
+	var testCases = []struct {
+		name       string
+		retention  *prowapi.Retention
+		relPath    string
+		expected   time.Time
+		expectedOK bool
+	}{
+		{
+			name:    "no retention",
+			relPath: "artifacts/junit.xml",
+		},
+		{
+			name:       "artifact",
+			retention:  retention,
+			relPath:    "artifacts/junit.xml",
+			expected:   uploaded.Add(14 * 24 * time.Hour),
+			expectedOK: true,
+		},
+		{
+			name:       "log",
+			retention:  retention,
+			relPath:    "clone-log.txt",
+			expected:   uploaded.Add(90 * 24 * time.Hour),
+			expectedOK: true,
+		},
+		{
+			name:      "artifacts kept forever",
+			retention: &prowapi.Retention{Logs: "90d"},
+			relPath:   "artifacts/junit.xml",
+		},
+		{
+			name:      "finished.json is preserved",
+			retention: retention,
+			relPath:   "finished.json",
+		},
+		{
+			name:      "build log is preserved",
+			retention: retention,
+			relPath:   "build-log.txt",
+		},
+		{
+			name:      "step build log is preserved",
+			retention: retention,
+			relPath:   "unit-build-log.txt",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := ExpirationForPath(tc.retention, tc.relPath, uploaded)
+			if ok != tc.expectedOK {
+				t.Fatalf("Expected ok %v, got %v", tc.expectedOK, ok)
+			}
+			if !actual.Equal(tc.expected) {
+				t.Errorf("Expected expiration %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithExpiration(t *testing.T) {
+	fakeBucket := "test-bucket"
+	fakeGCSServer := fakestorage.NewServer([]fakestorage.Object{})
+	fakeGCSServer.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: fakeBucket})
+	defer fakeGCSServer.Stop()
+	opener := io.NewGCSOpener(fakeGCSServer.Client())
+
+	expiration := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	upload := WithExpiration(DataUploadWithMetadata(strings.NewReader("data"), map[string]string{"link": "somewhere"}), expiration)
+	writer := &openerObjectWriter{Opener: opener, Context: context.Background(), Bucket: "gs://" + fakeBucket, Dest: "podinfo.json"}
+	if err := upload(writer); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+
+	attrs, err := opener.Attributes(context.Background(), "gs://"+fakeBucket+"/podinfo.json")
+	if err != nil {
+		t.Fatalf("Failed to get attributes: %v", err)
+	}
+	expected := map[string]string{"link": "somewhere", ExpirationMetadataKey: "2021-03-01T00:00:00Z"}
+	if diff := cmp.Diff(expected, attrs.Metadata); diff != "" {
+		t.Errorf("Unexpected metadata (-want +got):\n%s", diff)
+	}
+	if actual, ok := ExpirationFromMetadata(attrs.Metadata); !ok || !actual.Equal(expiration) {
+		t.Errorf("Expected expiration %v, got %v (%v)", expiration, actual, ok)
+	}
+}
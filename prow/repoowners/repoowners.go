@@ -46,6 +46,12 @@ const (
 
 type dirOptions struct {
 	NoParentOwners bool `json:"no_parent_owners,omitempty"`
+	// RequiredApprovers is the number of distinct approvers needed to
+	// approve changes under the directory.
+	RequiredApprovers int `json:"required_approvers,omitempty"`
+	// InvalidateOnPush discards approvals for changes under the directory
+	// when new commits touch them.
+	InvalidateOnPush bool `json:"invalidate_on_push,omitempty"`
 }
 
 // Config holds roles+usernames and labels for a directory considered as a unit of independent code
@@ -219,6 +225,8 @@ type RepoOwner interface {
 	FindReviewersOwnersForFile(path string) string
 	FindLabelsForFile(path string) sets.String
 	IsNoParentOwners(path string) bool
+	RequiredApproverCount(path string) int
+	InvalidateOnPush(path string) bool
 	LeafApprovers(path string) sets.String
 	Approvers(path string) layeredsets.String
 	LeafReviewers(path string) sets.String
@@ -796,6 +804,44 @@ func (o *RepoOwners) IsNoParentOwners(path string) bool {
 	return o.options[path].NoParentOwners
 }
 
+// RequiredApproverCount returns the number of distinct approvers needed for
+// the OWNERS file at path. This is the highest required_approvers option set
+// by the OWNERS file or its parents, and at least one.
+func (o *RepoOwners) RequiredApproverCount(path string) int {
+	required := 1
+	o.walkOptions(path, func(opts dirOptions) {
+		if opts.RequiredApprovers > required {
+			required = opts.RequiredApprovers
+		}
+	})
+	return required
+}
+
+// InvalidateOnPush checks if approvals for the OWNERS file at path are
+// discarded when new commits touch it, because the OWNERS file or one of
+// its parents sets the invalidate_on_push option.
+func (o *RepoOwners) InvalidateOnPush(path string) bool {
+	var invalidate bool
+	o.walkOptions(path, func(opts dirOptions) {
+		invalidate = invalidate || opts.InvalidateOnPush
+	})
+	return invalidate
+}
+
+// walkOptions calls fn with the options of the OWNERS file at path and of its
+// parents, stopping at the root or at an OWNERS file with NoParentOwners.
+func (o *RepoOwners) walkOptions(path string, fn func(dirOptions)) {
+	d := canonicalize(path)
+	for {
+		opts := o.options[d]
+		fn(opts)
+		if opts.NoParentOwners || d == baseDirConvention {
+			break
+		}
+		d = canonicalize(filepath.Dir(d))
+	}
+}
+
 // entriesForFile returns a set of users who are assignees to the
 // requested file. The path variable should be a full path to a filename
 // and not directory as the final directory will be discounted if enableMDYAML is true
@@ -1086,6 +1086,54 @@ func TestGetApprovers(t *testing.T) {
 	}
 }
 
+func TestApprovalOptions(t *testing.T) {
+	ro := &RepoOwners{
+		options: map[string]dirOptions{
+			"a": {
+				RequiredApprovers: 2,
+			},
+			"a/b/c": {
+				InvalidateOnPush: true,
+			},
+			"a/b/c/d": {
+				RequiredApprovers: 3,
+			},
+			noParentsDir: {
+				NoParentOwners: true,
+			},
+			baseDir: {
+				InvalidateOnPush: true,
+			},
+			"e": {
+				NoParentOwners:    true,
+				RequiredApprovers: 2,
+				InvalidateOnPush:  false,
+			},
+		},
+	}
+	tests := []struct {
+		path               string
+		expectedRequired   int
+		expectedInvalidate bool
+	}{
+		{path: baseDir, expectedRequired: 1, expectedInvalidate: true},
+		{path: "a", expectedRequired: 2, expectedInvalidate: true},
+		{path: "a/b/c", expectedRequired: 2, expectedInvalidate: true},
+		{path: "a/b/c/d", expectedRequired: 3, expectedInvalidate: true},
+		{path: noParentsDir, expectedRequired: 1, expectedInvalidate: false},
+		{path: "e/f", expectedRequired: 2, expectedInvalidate: false},
+		{path: nonExistentDir, expectedRequired: 1, expectedInvalidate: true},
+	}
+	for _, test := range tests {
+		if required := ro.RequiredApproverCount(test.path); required != test.expectedRequired {
+			t.Errorf("%q: expected %d required approvers, got %d", test.path, test.expectedRequired, required)
+		}
+		if invalidate := ro.InvalidateOnPush(test.path); invalidate != test.expectedInvalidate {
+			t.Errorf("%q: expected invalidate on push to be %t, got %t", test.path, test.expectedInvalidate, invalidate)
+		}
+	}
+}
+
 func TestFindLabelsForPath(t *testing.T) {
 	tests := []struct {
 		name           string
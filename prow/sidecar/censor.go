@@ -0,0 +1,320 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/pod-utils/wrapper"
+)
+
+// censoredText replaces every secret found in logs and artifacts,
+// matching what clonerefs does for the OAuth token.
+const censoredText = "CENSORED"
+
+// redactionsKey is the finished.json metadata key holding the
+// number of secrets censored from the uploaded logs and artifacts.
+const redactionsKey = "redactions"
+
+// minSecretLength is the length of the shortest secret value censored.
+// Shorter values would censor every occurrence of common characters.
+const minSecretLength = 4
+
+// censorBufferSize is how much of a file is censored at a time.
+var censorBufferSize = 1024 * 1024
+
+// censor censors secrets from the build logs of all entries and from the
+// selected text artifacts in place, returning the number of redactions.
+func (o Options) censor(entries []wrapper.Options) (int, error) {
+	secrets, err := loadSecrets(o.CensoringOptions.SecretDirectories)
+	if err != nil {
+		return 0, fmt.Errorf("load secrets: %w", err)
+	}
+	c := newCensorer(secrets)
+
+	var redactions int
+	for _, entry := range entries {
+		count, err := c.censorFile(entry.ProcessLog)
+		if err != nil && !os.IsNotExist(err) {
+			return redactions, fmt.Errorf("censor %s: %w", entry.ProcessLog, err)
+		}
+		redactions += count
+	}
+
+	for _, item := range o.GcsOptions.Items {
+		err := filepath.Walk(item, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			relPath, err := filepath.Rel(item, path)
+			if err != nil {
+				return err
+			}
+			if relPath == "." {
+				relPath = info.Name()
+			}
+			if !o.CensoringOptions.shouldCensor(relPath) {
+				return nil
+			}
+			if text, err := isText(path); err != nil || !text {
+				return err
+			}
+			count, err := c.censorFile(path)
+			if err != nil {
+				return fmt.Errorf("censor %s: %w", path, err)
+			}
+			redactions += count
+			return nil
+		})
+		if err != nil {
+			return redactions, err
+		}
+	}
+	return redactions, nil
+}
+
+// shouldCensor determines if an artifact, given its path relative to
+// the artifacts directory, is selected for censoring.
+func (o *CensoringOptions) shouldCensor(relPath string) bool {
+	if matchesAny(o.ExcludeDirectories, relPath) {
+		return false
+	}
+	return len(o.IncludeDirectories) == 0 || matchesAny(o.IncludeDirectories, relPath)
+}
+
+// matchesAny determines if any glob matches the path or one of its parents.
+func matchesAny(globs []string, relPath string) bool {
+	for _, glob := range globs {
+		for path := relPath; path != "." && path != "/"; path = filepath.Dir(path) {
+			if matched, _ := filepath.Match(glob, path); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isText sniffs the content of a file to determine whether it holds text.
+func isText(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return strings.HasPrefix(http.DetectContentType(head[:n]), "text/"), nil
+}
+
+// loadSecrets reads the value of every secret in the directories. Each
+// value is censored as is, without surrounding whitespace and in its
+// base64 encoding. Values shorter than minSecretLength are skipped.
+func loadSecrets(dirs []string) ([][]byte, error) {
+	values := map[string]bool{}
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// Kubernetes secret volumes link each key to a file in a
+			// hidden timestamped directory, so only read regular files.
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			raw, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if trimmed := strings.TrimSpace(string(raw)); len(trimmed) < minSecretLength {
+				if trimmed != "" {
+					logrus.WithField("path", path).Warnf("Not censoring secret value shorter than %d characters.", minSecretLength)
+				}
+				return nil
+			}
+			for _, value := range []string{string(raw), strings.TrimSpace(string(raw))} {
+				values[value] = true
+				values[base64.StdEncoding.EncodeToString([]byte(value))] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var secrets [][]byte
+	for value := range values {
+		secrets = append(secrets, []byte(value))
+	}
+	// Prefer the longest secret when several match at the same place.
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return bytes.Compare(secrets[i], secrets[j]) < 0
+	})
+	return secrets, nil
+}
+
+// censorer replaces secrets in streams of data.
+type censorer struct {
+	secrets   [][]byte
+	maxLength int
+}
+
+func newCensorer(secrets [][]byte) *censorer {
+	c := &censorer{secrets: secrets}
+	for _, secret := range secrets {
+		if len(secret) > c.maxLength {
+			c.maxLength = len(secret)
+		}
+	}
+	return c
+}
+
+// censorFile censors a file in place, returning the number of redactions.
+func (c *censorer) censorFile(path string) (int, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	dst, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".censoring")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := os.Remove(dst.Name()); err != nil && !os.IsNotExist(err) {
+			logrus.WithError(err).Warnf("Failed to remove %s", dst.Name())
+		}
+	}()
+	count, err := c.censor(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || count == 0 {
+		return count, err
+	}
+	if err := os.Chmod(dst.Name(), info.Mode()); err != nil {
+		return count, err
+	}
+	return count, os.Rename(dst.Name(), path)
+}
+
+// censor copies src to dst with every secret replaced, returning the
+// number of redactions. Data is processed a buffer at a time, holding
+// back enough of the end of each buffer to find secrets that span it.
+func (c *censorer) censor(dst io.Writer, src io.Reader) (int, error) {
+	var count int
+	var carry []byte
+	buffer := make([]byte, censorBufferSize)
+	for {
+		n, readErr := io.ReadFull(src, buffer)
+		eof := readErr == io.EOF || readErr == io.ErrUnexpectedEOF
+		if readErr != nil && !eof {
+			return count, readErr
+		}
+		data := append(carry, buffer[:n]...)
+		limit := len(data)
+		if !eof && c.maxLength > 0 {
+			limit -= c.maxLength - 1
+			if limit < 0 {
+				limit = 0
+			}
+		}
+		censored, cut, replaced := c.replace(data, limit)
+		count += replaced
+		if _, err := dst.Write(censored); err != nil {
+			return count, err
+		}
+		if eof {
+			return count, nil
+		}
+		carry = append([]byte(nil), data[cut:]...)
+	}
+}
+
+// replace censors secrets which start before limit in data. It returns
+// the censored form of data[:cut], where cut is at least limit and the
+// remainder of data is left to be censored along with what follows it.
+func (c *censorer) replace(data []byte, limit int) ([]byte, int, int) {
+	var censored []byte
+	var pos, count int
+	// next caches where each secret next occurs at or after pos,
+	// with -1 meaning it does not occur and -2 meaning unknown.
+	next := make([]int, len(c.secrets))
+	for i := range next {
+		next[i] = -2
+	}
+	for {
+		match := -1
+		for i, secret := range c.secrets {
+			if next[i] == -1 {
+				continue
+			}
+			if next[i] < pos {
+				index := bytes.Index(data[pos:], secret)
+				if index == -1 {
+					next[i] = -1
+					continue
+				}
+				next[i] = pos + index
+			}
+			if match == -1 || next[i] < next[match] {
+				match = i
+			}
+		}
+		if match == -1 || next[match] >= limit {
+			break
+		}
+		censored = append(censored, data[pos:next[match]]...)
+		censored = append(censored, censoredText...)
+		count++
+		pos = next[match] + len(c.secrets[match])
+	}
+	cut := pos
+	if cut < limit {
+		cut = limit
+	}
+	censored = append(censored, data[pos:cut]...)
+	return censored, cut, count
+}
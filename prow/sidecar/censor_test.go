@@ -0,0 +1,289 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/gcsupload"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/prow/pod-utils/wrapper"
+)
+
+func TestCensor(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		secrets    []string
+		bufferSize int
+		input      string
+		expected   string
+		count      int
+	}{
+		{
+			name:       "no secrets",
+			bufferSize: 4,
+			input:      "nothing to see here",
+			expected:   "nothing to see here",
+		},
+		{
+			name:       "secrets in one buffer",
+			secrets:    []string{"hunter2"},
+			bufferSize: 1024,
+			input:      "password is hunter2, I repeat hunter2",
+			expected:   "password is CENSORED, I repeat CENSORED",
+			count:      2,
+		},
+		{
+			name:       "secret spanning buffers",
+			secrets:    []string{"hunter2"},
+			bufferSize: 4,
+			input:      "password is hunter2, I repeat hunter2",
+			expected:   "password is CENSORED, I repeat CENSORED",
+			count:      2,
+		},
+		{
+			name:       "adjacent secrets",
+			secrets:    []string{"abc"},
+			bufferSize: 2,
+			input:      "abcabcab",
+			expected:   "CENSOREDCENSOREDab",
+			count:      2,
+		},
+		{
+			name:       "longest secret wins",
+			secrets:    []string{"token-long", "token"},
+			bufferSize: 3,
+			input:      "token-long token",
+			expected:   "CENSORED CENSORED",
+			count:      2,
+		},
+		{
+			name:       "secret at end",
+			secrets:    []string{"hunter2"},
+			bufferSize: 5,
+			input:      "hunter",
+			expected:   "hunter",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := censorBufferSize
+			censorBufferSize = tc.bufferSize
+			defer func() { censorBufferSize = original }()
+
+			var secrets [][]byte
+			for _, secret := range tc.secrets {
+				secrets = append(secrets, []byte(secret))
+			}
+			var output bytes.Buffer
+			count, err := newCensorer(secrets).censor(&output, strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("Failed to censor: %v", err)
+			}
+			if actual := output.String(); actual != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, actual)
+			}
+			if count != tc.count {
+				t.Errorf("Expected %d redactions, got %d", tc.count, count)
+			}
+		})
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Lay the secret out like a Kubernetes secret volume does.
+	data := filepath.Join(dir, "..2021_01_01_00_00_00.000")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(data, "token"), []byte("hunter2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	// Too short to be censored without censoring everything else.
+	if err := ioutil.WriteFile(filepath.Join(data, "short"), []byte("yes\n"), 0644); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	if err := os.Symlink(data, filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Failed to link data dir: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "token"), filepath.Join(dir, "token")); err != nil {
+		t.Fatalf("Failed to link secret: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "short"), filepath.Join(dir, "short")); err != nil {
+		t.Fatalf("Failed to link secret: %v", err)
+	}
+
+	secrets, err := loadSecrets([]string{dir})
+	if err != nil {
+		t.Fatalf("Failed to load secrets: %v", err)
+	}
+	var actual []string
+	for _, secret := range secrets {
+		actual = append(actual, string(secret))
+	}
+	expected := []string{"aHVudGVyMg==", "aHVudGVyMgo=", "hunter2\n", "hunter2"}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Unexpected secrets (-want +got):\n%s", diff)
+	}
+}
+
+func TestCensorEntriesAndArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "censor")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"secrets/token":              "hunter2",
+		"process-log.txt":            "logging in with hunter2\n",
+		"artifacts/junit.xml":        "<failure>aHVudGVyMg==</failure>",
+		"artifacts/skipped/dump.txt": "hunter2",
+		"artifacts/core.bin":         "\x00\x01hunter2",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	o := Options{
+		GcsOptions: &gcsupload.Options{Items: []string{filepath.Join(dir, "artifacts")}},
+		CensoringOptions: &CensoringOptions{
+			SecretDirectories:  []string{filepath.Join(dir, "secrets")},
+			ExcludeDirectories: []string{"skipped"},
+		},
+	}
+	redactions, err := o.censor([]wrapper.Options{{ProcessLog: filepath.Join(dir, "process-log.txt")}})
+	if err != nil {
+		t.Fatalf("Failed to censor: %v", err)
+	}
+	if redactions != 2 {
+		t.Errorf("Expected 2 redactions, got %d", redactions)
+	}
+
+	expected := map[string]string{
+		"process-log.txt":            "logging in with CENSORED\n",
+		"artifacts/junit.xml":        "<failure>CENSORED</failure>",
+		"artifacts/skipped/dump.txt": "hunter2",
+		"artifacts/core.bin":         "\x00\x01hunter2",
+	}
+	for name, content := range expected {
+		actual, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(actual) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, string(actual))
+		}
+	}
+}
+
+func TestRunReportsFailureWhenCensoringFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "censor")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"process-log.txt":     "logging in with hunter2\n",
+		"marker-file.txt":     "0",
+		"artifacts/dump.txt":  "hunter2",
+		"output/.placeholder": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	os.Setenv(downwardapi.JobSpecEnv, `{"type":"periodic","job":"job","buildid":"1"}`)
+	defer os.Unsetenv(downwardapi.JobSpecEnv)
+
+	output := filepath.Join(dir, "output")
+	o := Options{
+		GcsOptions: &gcsupload.Options{
+			Items: []string{filepath.Join(dir, "artifacts")},
+			GCSConfiguration: &prowapi.GCSConfiguration{
+				Bucket:         "bucket",
+				PathStrategy:   prowapi.PathStrategyExplicit,
+				LocalOutputDir: output,
+			},
+		},
+		Entries: []wrapper.Options{{
+			ProcessLog: filepath.Join(dir, "process-log.txt"),
+			MarkerFile: filepath.Join(dir, "marker-file.txt"),
+		}},
+		// Secrets that can't be read can't be censored.
+		CensoringOptions: &CensoringOptions{SecretDirectories: []string{filepath.Join(dir, "missing")}},
+	}
+	if _, err := o.Run(context.Background()); err == nil {
+		t.Error("Expected an error when censoring fails")
+	}
+
+	var uploaded []string
+	var finished gcs.Finished
+	err = filepath.Walk(output, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() == ".placeholder" {
+			return err
+		}
+		uploaded = append(uploaded, info.Name())
+		if info.Name() == prowapi.FinishedStatusFile {
+			raw, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(raw, &finished)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read uploads: %v", err)
+	}
+	if diff := cmp.Diff([]string{prowapi.FinishedStatusFile}, uploaded); diff != "" {
+		t.Errorf("Expected only finished.json to be uploaded (-want +got):\n%s", diff)
+	}
+	if finished.Passed == nil || *finished.Passed || finished.Result != "FAILURE" {
+		t.Errorf("Expected the job to be reported as failed, got %+v", finished)
+	}
+}
@@ -75,6 +75,25 @@ type Options struct {
 	// longer than the `entrypoint` grace period for the test process and the time
 	// taken by `sidecar` to upload all relevant artifacts.
 	IgnoreInterrupts bool `json:"ignore_interrupts,omitempty"`
+
+	// CensoringOptions, if set, enables censoring secrets from
+	// the build logs and artifacts before they are uploaded.
+	CensoringOptions *CensoringOptions `json:"censoring_options,omitempty"`
+}
+
+// CensoringOptions configures which secrets are censored and
+// which artifacts are censored of them.
+type CensoringOptions struct {
+	// SecretDirectories are paths to directories holding secrets,
+	// e.g. Kubernetes secret volumes. The contents of every file
+	// in these directories are censored.
+	SecretDirectories []string `json:"secret_directories,omitempty"`
+	// IncludeDirectories are globs matched against paths relative to
+	// the artifacts directory. If set, only matching artifacts are censored.
+	IncludeDirectories []string `json:"include_directories,omitempty"`
+	// ExcludeDirectories are globs matched against paths relative to
+	// the artifacts directory. Matching artifacts are never censored.
+	ExcludeDirectories []string `json:"exclude_directories,omitempty"`
 }
 
 func (o Options) entries() []wrapper.Options {
@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/entrypoint"
@@ -43,6 +44,10 @@ func nameEntry(idx int, opt wrapper.Options) string {
 }
 
 func wait(ctx context.Context, entries []wrapper.Options) (bool, bool, int) {
+	return summarize(waitForEntries(ctx, entries))
+}
+
+func waitForEntries(ctx context.Context, entries []wrapper.Options) map[string]wrapper.MarkerResult {
 
 	var paths []string
 
@@ -50,8 +55,10 @@ func wait(ctx context.Context, entries []wrapper.Options) (bool, bool, int) {
 		paths = append(paths, opt.MarkerFile)
 	}
 
-	results := wrapper.WaitForMarkers(ctx, paths...)
+	return wrapper.WaitForMarkers(ctx, paths...)
+}
 
+func summarize(results map[string]wrapper.MarkerResult) (bool, bool, int) {
 	passed := true
 	var aborted bool
 	var failures int
@@ -68,6 +75,37 @@ func wait(ctx context.Context, entries []wrapper.Options) (bool, bool, int) {
 
 }
 
+// stepsKey is the finished.json metadata key holding the result of each
+// test container when a job runs more than one.
+const stepsKey = "steps"
+
+// stepResult is the outcome of a single test container.
+type stepResult struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Skipped  bool   `json:"skipped,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+func stepResults(entries []wrapper.Options, results map[string]wrapper.MarkerResult) []stepResult {
+	var steps []stepResult
+	for _, opt := range entries {
+		res := results[opt.MarkerFile]
+		step := stepResult{
+			Name:     opt.ContainerName,
+			Passed:   res.Err == nil && res.ReturnCode == 0,
+			Skipped:  res.ReturnCode == entrypoint.PreviousErrorCode,
+			ExitCode: res.ReturnCode,
+		}
+		if res.Err != nil {
+			step.Error = res.Err.Error()
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
 // Run will watch for the process being wrapped to exit
 // and then post the status of that process and any artifacts
 // to cloud storage.
@@ -105,7 +143,8 @@ func (o Options) Run(ctx context.Context) (int, error) {
 		logrus.Warnf("Using deprecated wrapper_options instead of entries. Please update prow/pod-utils/decorate before June 2019")
 	}
 	entries := o.entries()
-	passed, aborted, failures := wait(ctx, entries)
+	results := waitForEntries(ctx, entries)
+	passed, aborted, failures := summarize(results)
 
 	cancel()
 	// If we are being asked to terminate by the kubelet but we have
@@ -115,8 +154,24 @@ func (o Options) Run(ctx context.Context) (int, error) {
 	// uploading, so we ignore the signals.
 	signal.Ignore(os.Interrupt, syscall.SIGTERM)
 
+	var redactions int
+	if o.CensoringOptions != nil {
+		if redactions, err = o.censor(entries); err != nil {
+			// Refuse to upload the logs and artifacts rather than risk leaking
+			// secrets, but still report that the job finished and failed.
+			censorErr := fmt.Errorf("failed to censor secrets: %v", err)
+			return failures, utilerrors.NewAggregate([]error{censorErr, o.doUploadUncensored(spec, censorErr)})
+		}
+	}
+
 	buildLogs := logReaders(entries)
 	metadata := combineMetadata(entries)
+	if o.CensoringOptions != nil {
+		metadata[redactionsKey] = redactions
+	}
+	if len(entries) > 1 {
+		metadata[stepsKey] = stepResults(entries, results)
+	}
 	return failures, o.doUpload(spec, passed, aborted, metadata, buildLogs)
 }
 
@@ -177,6 +232,16 @@ func combineMetadata(entries []wrapper.Options) map[string]interface{} {
 	return metadata
 }
 
+// doUploadUncensored uploads finished.json alone, marking the job as failed
+// because its logs and artifacts could not be censored.
+func (o Options) doUploadUncensored(spec *downwardapi.JobSpec, censorErr error) error {
+	gcsOptions := *o.GcsOptions
+	gcsOptions.Items = nil
+	o.GcsOptions = &gcsOptions
+	metadata := map[string]interface{}{errorKey: censorErr.Error()}
+	return o.doUpload(spec, false, false, metadata, nil)
+}
+
 func (o Options) doUpload(spec *downwardapi.JobSpec, passed, aborted bool, metadata map[string]interface{}, logReaders map[string]io.Reader) error {
 	uploadTargets := make(map[string]gcs.UploadFunc)
 
@@ -304,6 +304,30 @@ func TestWaitParallelContainers(t *testing.T) {
 	}
 }
 
+func TestStepResults(t *testing.T) {
+	entries := []wrapper.Options{
+		{ContainerName: "build", MarkerFile: "build-marker.txt"},
+		{ContainerName: "test", MarkerFile: "test-marker.txt"},
+		{ContainerName: "publish", MarkerFile: "publish-marker.txt"},
+		{ContainerName: "lint", MarkerFile: "lint-marker.txt"},
+	}
+	results := map[string]wrapper.MarkerResult{
+		"build-marker.txt":   {ReturnCode: 0},
+		"test-marker.txt":    {ReturnCode: 2},
+		"publish-marker.txt": {ReturnCode: entrypoint.PreviousErrorCode},
+		"lint-marker.txt":    {ReturnCode: -1, Err: errors.New("cancelled")},
+	}
+	expected := []stepResult{
+		{Name: "build", Passed: true},
+		{Name: "test", ExitCode: 2},
+		{Name: "publish", Skipped: true, ExitCode: entrypoint.PreviousErrorCode},
+		{Name: "lint", ExitCode: -1, Error: "cancelled"},
+	}
+	if actual := stepResults(entries, results); !equality.Semantic.DeepEqual(expected, actual) {
+		t.Errorf("step results do not match:\n%s", diff.ObjectReflectDiff(expected, actual))
+	}
+}
+
 func TestCombineMetadata(t *testing.T) {
 	cases := []struct {
 		name     string
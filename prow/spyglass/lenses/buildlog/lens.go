@@ -124,6 +124,9 @@ type LogArtifactView struct {
 	LineGroups   []LineGroup
 	ViewAll      bool
 	ShowRawLog   bool
+	// StepName is the name of the test container that wrote the log,
+	// if the job ran more than one.
+	StepName string
 }
 
 // BuildLogsView holds each log file view
@@ -173,6 +176,7 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 		av := LogArtifactView{
 			ArtifactName: a.JobPath(),
 			ArtifactLink: a.CanonicalLink(),
+			StepName:     stepName(a.JobPath()),
 			ShowRawLog:   conf.showRawLog,
 		}
 		lines, err := logLinesAll(a)
@@ -188,6 +192,19 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 	return executeTemplate(resourceDir, "body", buildLogsView)
 }
 
+// stepLogSuffix is how sidecar names the build log of each test
+// container when a job runs several of them.
+const stepLogSuffix = "-build-log.txt"
+
+// stepName returns the step that wrote the log at path, if any.
+func stepName(path string) string {
+	base := filepath.Base(path)
+	if !strings.HasSuffix(base, stepLogSuffix) {
+		return ""
+	}
+	return strings.TrimSuffix(base, stepLogSuffix)
+}
+
 // Callback is used to retrieve new log segments
 func (lens Lens) Callback(artifacts []api.Artifact, resourceDir string, data string, rawConfig json.RawMessage) string {
 	var request LineRequest
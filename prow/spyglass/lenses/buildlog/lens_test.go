@@ -20,6 +20,24 @@ import (
 	"testing"
 )
 
+func TestStepName(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "build-log.txt"},
+		{path: "artifacts/build-log.txt"},
+		{path: "test-build-log.txt", expected: "test"},
+		{path: "unit-tests-build-log.txt", expected: "unit-tests"},
+		{path: "artifacts/junit.xml"},
+	}
+	for _, test := range tests {
+		if actual := stepName(test.path); actual != test.expected {
+			t.Errorf("stepName(%q) = %q, expected %q", test.path, actual, test.expected)
+		}
+	}
+}
+
 func TestGroupLines(t *testing.T) {
 	lorem := []string{
 		"Lorem ipsum dolor sit amet",
@@ -24,11 +24,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/tools/cover"
 
+	"k8s.io/test-infra/gopherage/pkg/cov"
 	"k8s.io/test-infra/prow/spyglass/api"
 	"k8s.io/test-infra/prow/spyglass/lenses"
 )
@@ -102,6 +105,11 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 		logrus.WithError(err).Warn("Couldn't read a coverage file that should exist.")
 		return fmt.Sprintf("Faiiled to read the coverage file: %v", err)
 	}
+	content, err = toGoProfile(content)
+	if err != nil {
+		logrus.WithError(err).Warn("Couldn't convert a coverage file.")
+		return fmt.Sprintf("Failed to convert the coverage file: %v", err)
+	}
 
 	coverageTemplate, err := template.ParseFiles(filepath.Join(resourceDir, "template.html"))
 	if err != nil {
@@ -140,3 +148,26 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 
 	return buf.String()
 }
+
+// toGoProfile converts LCOV and Cobertura coverage files to Go profiles, which are all the viewer
+// understands. Go profiles are returned unchanged.
+func toGoProfile(content []byte) ([]byte, error) {
+	var parse func(io.Reader) ([]*cover.Profile, error)
+	switch cov.DetectFormat(content) {
+	case cov.FormatLCOV:
+		parse = cov.ParseLCOV
+	case cov.FormatCobertura:
+		parse = cov.ParseCobertura
+	default:
+		return content, nil
+	}
+	profiles, err := parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := cov.DumpProfile(profiles, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
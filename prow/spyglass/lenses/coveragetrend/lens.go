@@ -0,0 +1,278 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package coveragetrend charts the coverage trend index written by `coverage-robot trend`.
+package coveragetrend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/spyglass/api"
+	"k8s.io/test-infra/prow/spyglass/lenses"
+	"k8s.io/test-infra/robots/coverage/trend"
+)
+
+const (
+	name     = "coveragetrend"
+	title    = "Coverage Trend"
+	priority = 7
+
+	chartWidth       = 600
+	chartHeight      = 120
+	sparklineWidth   = 160
+	sparklineHeight  = 24
+	shortCommitChars = 8
+)
+
+// Lens is the implementation of a coverage trend Spyglass lens.
+type Lens struct{}
+
+func init() {
+	lenses.RegisterLens(Lens{})
+}
+
+// Config returns the lens's configuration.
+func (lens Lens) Config() lenses.LensConfig {
+	return lenses.LensConfig{
+		Title:    title,
+		Name:     name,
+		Priority: priority,
+	}
+}
+
+// Header renders the content of <head> from template.html.
+func (lens Lens) Header(artifacts []api.Artifact, resourceDir string, config json.RawMessage) string {
+	t, err := template.ParseFiles(filepath.Join(resourceDir, "template.html"))
+	if err != nil {
+		return fmt.Sprintf("<!-- FAILED LOADING HEADER: %v -->", err)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "header", nil); err != nil {
+		return fmt.Sprintf("<!-- FAILED EXECUTING HEADER TEMPLATE: %v -->", err)
+	}
+	return buf.String()
+}
+
+// Callback does nothing.
+func (lens Lens) Callback(artifacts []api.Artifact, resourceDir string, data string, config json.RawMessage) string {
+	return ""
+}
+
+// Body renders the coverage of the whole job and of each package over time.
+func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string, config json.RawMessage) string {
+	if len(artifacts) != 1 {
+		logrus.Errorf("Invalid artifacts: %v", artifacts)
+		return "Expected exactly one coverage trend index."
+	}
+	content, err := artifacts[0].ReadAll()
+	if err != nil {
+		logrus.WithError(err).Warn("Couldn't read a coverage trend index that should exist.")
+		return fmt.Sprintf("Failed to read the coverage trend index: %v", err)
+	}
+	index := trend.Index{}
+	if err := json.Unmarshal(content, &index); err != nil {
+		logrus.WithError(err).Warn("Couldn't parse a coverage trend index.")
+		return fmt.Sprintf("Failed to parse the coverage trend index: %v", err)
+	}
+
+	t, err := template.ParseFiles(filepath.Join(resourceDir, "template.html"))
+	if err != nil {
+		logrus.WithError(err).Error("Error executing template.")
+		return fmt.Sprintf("Failed to load template file: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "body", newView(index)); err != nil {
+		logrus.WithError(err).Error("Error executing template.")
+		return fmt.Sprintf("Failed to execute template: %v", err)
+	}
+	return buf.String()
+}
+
+// point is a position in an SVG chart.
+type point struct {
+	X, Y float64
+}
+
+// chart is a line chart of coverage over builds, drawn as SVG.
+type chart struct {
+	Width, Height int
+	// Lines hold the points attribute of a polyline for each run of builds with coverage.
+	Lines []string
+	// Drops mark the builds where coverage dropped significantly.
+	Drops []point
+}
+
+type packageTrend struct {
+	Name    string
+	Latest  string
+	Change  string
+	Dropped bool
+	Chart   chart
+}
+
+type drop struct {
+	Build   string
+	Commit  string
+	Package string
+	Before  string
+	After   string
+}
+
+type view struct {
+	Job      string
+	Builds   int
+	Latest   string
+	Chart    chart
+	Packages []packageTrend
+	// Drops are sorted from newest to oldest.
+	Drops []drop
+}
+
+func newView(index trend.Index) view {
+	v := view{Job: index.Job, Builds: len(index.Builds)}
+	position := map[string]int{}
+	total := make([]*float32, len(index.Builds))
+	for i, b := range index.Builds {
+		position[b.ID] = i
+		ratio := b.Coverage
+		total[i] = &ratio
+	}
+
+	// Drops of the whole job are stored without a package.
+	dropped := map[string]map[int]bool{}
+	for _, d := range index.Drops {
+		i, ok := position[d.Build]
+		if !ok {
+			continue
+		}
+		if dropped[d.Package] == nil {
+			dropped[d.Package] = map[int]bool{}
+		}
+		dropped[d.Package][i] = true
+		pkg := d.Package
+		if pkg == "" {
+			pkg = "(total)"
+		}
+		v.Drops = append(v.Drops, drop{
+			Build:   d.Build,
+			Commit:  shortCommit(d.Commit),
+			Package: pkg,
+			Before:  percent(&d.Before),
+			After:   percent(&d.After),
+		})
+	}
+	for i, j := 0, len(v.Drops)-1; i < j; i, j = i+1, j-1 {
+		v.Drops[i], v.Drops[j] = v.Drops[j], v.Drops[i]
+	}
+
+	if len(total) > 0 {
+		v.Latest = percent(total[len(total)-1])
+	}
+	v.Chart = plot(total, dropped[""], chartWidth, chartHeight)
+
+	names := make([]string, 0, len(index.Packages))
+	for name := range index.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		series := index.Packages[name]
+		first, last := firstAndLast(series)
+		p := packageTrend{
+			Name:    name,
+			Latest:  "-",
+			Dropped: len(dropped[name]) > 0,
+			Chart:   plot(series, dropped[name], sparklineWidth, sparklineHeight),
+		}
+		if last != nil {
+			p.Latest = percent(last)
+			p.Change = fmt.Sprintf("%+.1f", (*last-*first)*100)
+		}
+		v.Packages = append(v.Packages, p)
+	}
+	return v
+}
+
+// plot draws the series in a width by height box, leaving gaps for builds without coverage.
+func plot(series []*float32, dropped map[int]bool, width, height int) chart {
+	c := chart{Width: width, Height: height}
+	x := func(i int) float64 {
+		if len(series) < 2 {
+			return float64(width) / 2
+		}
+		return float64(i) * float64(width) / float64(len(series)-1)
+	}
+	y := func(ratio float32) float64 {
+		return float64(height) * (1 - float64(ratio))
+	}
+
+	var line []string
+	for i, ratio := range series {
+		if ratio == nil {
+			if len(line) > 0 {
+				c.Lines = append(c.Lines, strings.Join(line, " "))
+				line = nil
+			}
+			continue
+		}
+		p := point{X: x(i), Y: y(*ratio)}
+		line = append(line, formatFloat(p.X)+","+formatFloat(p.Y))
+		if dropped[i] {
+			c.Drops = append(c.Drops, p)
+		}
+	}
+	if len(line) > 0 {
+		c.Lines = append(c.Lines, strings.Join(line, " "))
+	}
+	return c
+}
+
+func firstAndLast(series []*float32) (first, last *float32) {
+	for _, ratio := range series {
+		if ratio == nil {
+			continue
+		}
+		if first == nil {
+			first = ratio
+		}
+		last = ratio
+	}
+	return first, last
+}
+
+func percent(ratio *float32) string {
+	return fmt.Sprintf("%.1f%%", *ratio*100)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > shortCommitChars {
+		return commit[:shortCommitChars]
+	}
+	return commit
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package coveragetrend
+
+import (
+	"bytes"
+	"html/template"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/test-infra/robots/coverage/trend"
+)
+
+func ratio(r float32) *float32 {
+	return &r
+}
+
+func TestPlot(t *testing.T) {
+	tests := []struct {
+		name     string
+		series   []*float32
+		dropped  map[int]bool
+		expected chart
+	}{
+		{
+			name:     "gaps split the line",
+			series:   []*float32{ratio(1), ratio(0.5), nil, ratio(0.25), ratio(0)},
+			dropped:  map[int]bool{1: true},
+			expected: chart{Width: 100, Height: 10, Lines: []string{"0.0,0.0 25.0,5.0", "75.0,7.5 100.0,10.0"}, Drops: []point{{X: 25, Y: 5}}},
+		},
+		{
+			name:     "a single build is centered",
+			series:   []*float32{ratio(0.5)},
+			expected: chart{Width: 100, Height: 10, Lines: []string{"50.0,5.0"}},
+		},
+		{
+			name:     "no coverage",
+			series:   []*float32{nil, nil},
+			expected: chart{Width: 100, Height: 10},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if c := plot(tc.series, tc.dropped, 100, 10); !reflect.DeepEqual(c, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, c)
+			}
+		})
+	}
+}
+
+var testIndex = trend.Index{
+	Job: "gs://bucket/logs/post-repo-coverage",
+	Builds: []trend.Build{
+		{ID: "1", Commit: "0123456789abcdef", Coverage: 0.75},
+		{ID: "3", Commit: "fedcba9876543210", Coverage: 0.5},
+		{ID: "10", Commit: "abc", Coverage: 0.6},
+	},
+	Packages: map[string][]*float32{
+		"k8s.io/repo/pkg/b": {ratio(0.5), ratio(0.5), ratio(1)},
+		"k8s.io/repo/pkg/a": {ratio(1), ratio(0.5), ratio(0.5)},
+		"k8s.io/repo/pkg/c": {nil, nil, ratio(0)},
+	},
+	Drops: []trend.Drop{
+		{Build: "3", Commit: "fedcba9876543210", Before: 0.75, After: 0.5},
+		{Package: "k8s.io/repo/pkg/a", Build: "3", Commit: "fedcba9876543210", Before: 1, After: 0.5},
+		{Package: "k8s.io/repo/pkg/c", Build: "10", Commit: "abc", Before: 0.1, After: 0},
+	},
+}
+
+func TestNewView(t *testing.T) {
+	v := newView(testIndex)
+
+	if v.Latest != "60.0%" || v.Builds != 3 {
+		t.Errorf("expected 3 builds at 60.0%%, got %d at %s", v.Builds, v.Latest)
+	}
+	if expected := []point{{X: 300, Y: 60}}; !reflect.DeepEqual(v.Chart.Drops, expected) {
+		t.Errorf("expected total drops at %v, got %v", expected, v.Chart.Drops)
+	}
+
+	expectedDrops := []drop{
+		{Build: "10", Commit: "abc", Package: "k8s.io/repo/pkg/c", Before: "10.0%", After: "0.0%"},
+		{Build: "3", Commit: "fedcba98", Package: "k8s.io/repo/pkg/a", Before: "100.0%", After: "50.0%"},
+		{Build: "3", Commit: "fedcba98", Package: "(total)", Before: "75.0%", After: "50.0%"},
+	}
+	if !reflect.DeepEqual(v.Drops, expectedDrops) {
+		t.Errorf("expected drops %+v, got %+v", expectedDrops, v.Drops)
+	}
+
+	var summaries []string
+	for _, p := range v.Packages {
+		summaries = append(summaries, strings.Join([]string{p.Name, p.Latest, p.Change}, " "))
+		if p.Dropped != (p.Name != "k8s.io/repo/pkg/b") {
+			t.Errorf("unexpected dropped flag %t for %s", p.Dropped, p.Name)
+		}
+	}
+	expectedSummaries := []string{
+		"k8s.io/repo/pkg/a 50.0% -50.0",
+		"k8s.io/repo/pkg/b 100.0% +50.0",
+		"k8s.io/repo/pkg/c 0.0% +0.0",
+	}
+	if !reflect.DeepEqual(summaries, expectedSummaries) {
+		t.Errorf("expected packages %v, got %v", expectedSummaries, summaries)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	tmpl, err := template.ParseFiles("template.html")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	for _, index := range []trend.Index{testIndex, {Job: "gs://bucket/logs/empty"}} {
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, "body", newView(index)); err != nil {
+			t.Errorf("failed to execute template for %s: %v", index.Job, err)
+		}
+		if !strings.Contains(buf.String(), index.Job) {
+			t.Errorf("expected the body to mention %s", index.Job)
+		}
+	}
+}
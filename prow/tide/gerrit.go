@@ -0,0 +1,427 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	gerritclient "k8s.io/test-infra/prow/gerrit/client"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/tide/blockers"
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+type gerritClient interface {
+	QueryChangesForProject(instance, project, query string, rateLimit int) ([]gerritclient.ChangeInfo, error)
+	GetBranchRevision(instance, project, branch string) (string, error)
+	SubmitChange(instance, id string) (*gerritclient.ChangeInfo, error)
+}
+
+// NewGerritController makes a Controller that merges the submittable changes
+// of the given gerrit projects, keyed by instance URL. Use
+// NewGerritGitClientFactory to clone the projects. In dry-run mode changes
+// are never submitted.
+func NewGerritController(mgr manager, cfg config.Getter, gc gerritClient, gitClient git.ClientFactory, projects map[string][]string, dryRun bool, maxRecordsPerPool int, opener io.Opener, historyURI string, logger *logrus.Entry) (*Controller, error) {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	hist, err := history.New(maxRecordsPerPool, opener, historyURI)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing history client from %q: %v", historyURI, err)
+	}
+	provider, err := newGerritProvider(logger, gc, cfg, projects, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gerrit changes have no status contexts for a status controller to update.
+	return newSyncController(context.Background(), logger, provider, mgr, cfg, gitClient, nil, hist)
+}
+
+// gerritProvider implements provider for Gerrit changes. The host of the
+// gerrit instance takes the place of the org and the project that of the repo,
+// like in the refs of jobs triggered by the gerrit adapter.
+type gerritProvider struct {
+	cfg    config.Getter
+	gc     gerritClient
+	logger *logrus.Entry
+	dryRun bool
+
+	projects map[string][]string
+	// instances maps hosts to the URLs of their gerrit instance.
+	instances map[string]string
+
+	sync.RWMutex
+	// changes holds the changes of the last query by prKey.
+	changes map[string]gerritclient.ChangeInfo
+}
+
+func newGerritProvider(logger *logrus.Entry, gc gerritClient, cfg config.Getter, projects map[string][]string, dryRun bool) (*gerritProvider, error) {
+	instances := map[string]string{}
+	for instance := range projects {
+		u, err := url.Parse(instance)
+		if err != nil {
+			return nil, fmt.Errorf("instance %s is not a url: %v", instance, err)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("instance %s does not set host", instance)
+		}
+		instances[u.Host] = instance
+	}
+	return &gerritProvider{
+		logger:    logger,
+		gc:        gc,
+		cfg:       cfg,
+		dryRun:    dryRun,
+		projects:  projects,
+		instances: instances,
+		changes:   map[string]gerritclient.ChangeInfo{},
+	}, nil
+}
+
+func (p *gerritProvider) Query() (map[string]PullRequest, error) {
+	query := p.cfg().Tide.Gerrit.Query
+	rateLimit := p.cfg().Gerrit.RateLimit
+	changes := map[string]gerritclient.ChangeInfo{}
+	prs := make(map[string]PullRequest)
+	var errs []error
+	for instance, projects := range p.projects {
+		u, _ := url.Parse(instance) // Validated by newGerritProvider
+		for _, project := range projects {
+			results, err := p.gc.QueryChangesForProject(instance, project, query, rateLimit)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("query %q for %s: %v", query, project, err))
+				continue
+			}
+			for _, change := range results {
+				pr, err := changeToPullRequest(u.Host, change)
+				if err != nil {
+					p.logger.WithError(err).WithField("change", change.Number).Warning("Ignoring change.")
+					continue
+				}
+				key := prKey(&pr)
+				prs[key] = pr
+				changes[key] = change
+			}
+		}
+	}
+
+	p.Lock()
+	p.changes = changes
+	p.Unlock()
+	return prs, utilerrors.NewAggregate(errs)
+}
+
+// changeToPullRequest fills the fields of a PullRequest the pool needs from
+// the current revision of a change.
+func changeToPullRequest(host string, change gerritclient.ChangeInfo) (PullRequest, error) {
+	var pr PullRequest
+	rev, ok := change.Revisions[change.CurrentRevision]
+	if !ok {
+		return pr, fmt.Errorf("cannot find current revision for change %v", change.ID)
+	}
+	pr.Number = githubql.Int(change.Number)
+	pr.Author.Login = githubql.String(rev.Commit.Author.Name)
+	pr.BaseRef.Name = githubql.String(change.Branch)
+	pr.BaseRef.Prefix = "refs/heads/"
+	pr.HeadRefName = githubql.String(rev.Ref)
+	pr.HeadRefOID = githubql.String(change.CurrentRevision)
+	pr.Mergeable = githubql.MergeableStateUnknown
+	if change.Mergeable {
+		pr.Mergeable = githubql.MergeableStateMergeable
+	}
+	pr.Repository.Name = githubql.String(change.Project)
+	pr.Repository.NameWithOwner = githubql.String(host + "/" + change.Project)
+	pr.Repository.Owner.Login = githubql.String(host)
+	pr.Title = githubql.String(change.Subject)
+	pr.Body = githubql.String(rev.Commit.Message)
+	pr.UpdatedAt = githubql.DateTime{Time: change.Updated.Time}
+	pr.Commits.Nodes = append(pr.Commits.Nodes, struct{ Commit Commit }{
+		Commit: Commit{OID: pr.HeadRefOID},
+	})
+	return pr, nil
+}
+
+func (p *gerritProvider) change(org, repo string, number int) (gerritclient.ChangeInfo, error) {
+	p.RLock()
+	defer p.RUnlock()
+	change, ok := p.changes[fmt.Sprintf("%s/%s#%d", org, repo, number)]
+	if !ok {
+		return change, fmt.Errorf("change %s/%s#%d is not in the pool", org, repo, number)
+	}
+	return change, nil
+}
+
+func (p *gerritProvider) instance(host string) (string, error) {
+	instance, ok := p.instances[host]
+	if !ok {
+		return "", fmt.Errorf("no gerrit instance for host %s", host)
+	}
+	return instance, nil
+}
+
+// blockers returns no blockers, Gerrit has no issues to block merges with.
+func (p *gerritProvider) blockers() (blockers.Blockers, error) {
+	return blockers.Blockers{}, nil
+}
+
+// isAllowedToMerge allows all changes, Query only returns the changes Gerrit
+// considers submittable.
+func (p *gerritProvider) isAllowedToMerge(*PullRequest) (string, error) {
+	return "", nil
+}
+
+func (p *gerritProvider) GetRef(org, repo, ref string) (string, error) {
+	instance, err := p.instance(org)
+	if err != nil {
+		return "", err
+	}
+	return p.gc.GetBranchRevision(instance, repo, strings.TrimPrefix(ref, "heads/"))
+}
+
+// headContexts returns no contexts, the submit requirements of a change are
+// checked by Gerrit rather than reported as contexts.
+func (p *gerritProvider) headContexts(*logrus.Entry, *PullRequest) ([]Context, error) {
+	return nil, nil
+}
+
+// GetPresubmits returns the presubmits of a project configured for its clone
+// URI or its host and project, like the gerrit adapter does.
+func (p *gerritProvider) GetPresubmits(org, repo string, _ config.RefGetter, _ ...config.RefGetter) ([]config.Presubmit, error) {
+	instance, err := p.instance(org)
+	if err != nil {
+		return nil, err
+	}
+	presubmits := p.cfg().PresubmitsStatic[instance+"/"+repo]
+	return append(presubmits, p.cfg().PresubmitsStatic[org+"/"+repo]...), nil
+}
+
+// GetTideContextPolicy requires no contexts, see headContexts.
+func (p *gerritProvider) GetTideContextPolicy(string, string, string, config.RefGetter, string) (contextChecker, error) {
+	return &config.TideContextPolicy{}, nil
+}
+
+func (p *gerritProvider) GetChangedFiles(org, repo string, number int) ([]string, error) {
+	change, err := p.change(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for file := range change.Revisions[change.CurrentRevision].Files {
+		changed = append(changed, file)
+	}
+	return changed, nil
+}
+
+func (p *gerritProvider) refsForJob(sp subpool, prs []PullRequest) prowapi.Refs {
+	refs := prowapi.Refs{
+		Org:     sp.org,
+		Repo:    sp.repo,
+		BaseRef: sp.branch,
+		BaseSHA: sp.sha,
+	}
+	instance, err := p.instance(sp.org)
+	if err != nil {
+		// Cannot happen for subpools of changes returned by Query.
+		sp.log.WithError(err).Error("Failed to determine gerrit instance.")
+	} else {
+		refs.CloneURI = instance + "/" + sp.repo
+	}
+	for _, pr := range prs {
+		refs.Pulls = append(
+			refs.Pulls,
+			prowapi.Pull{
+				Number: int(pr.Number),
+				Author: string(pr.Author.Login),
+				SHA:    string(pr.HeadRefOID),
+				Ref:    string(pr.HeadRefName),
+				Link:   fmt.Sprintf("%s/c/%s/+/%d", instance, sp.repo, int(pr.Number)),
+			},
+		)
+	}
+	return refs
+}
+
+// labelsAndAnnotations identifies the change a job tests for crier to report
+// on, like for jobs triggered by the gerrit adapter. Batch jobs test several
+// changes and are not reported.
+func (p *gerritProvider) labelsAndAnnotations(jobLabels, jobAnnotations map[string]string, prs ...PullRequest) (map[string]string, map[string]string) {
+	if len(prs) != 1 {
+		return jobLabels, jobAnnotations
+	}
+	pr := prs[0]
+	org, repo := string(pr.Repository.Owner.Login), string(pr.Repository.Name)
+	change, err := p.change(org, repo, int(pr.Number))
+	if err != nil {
+		p.logger.WithError(err).Error("Failed to find change to report on.")
+		return jobLabels, jobAnnotations
+	}
+	instance, err := p.instance(org)
+	if err != nil {
+		p.logger.WithError(err).Error("Failed to find change to report on.")
+		return jobLabels, jobAnnotations
+	}
+
+	labels := map[string]string{}
+	for k, v := range jobLabels {
+		labels[k] = v
+	}
+	labels[gerritclient.GerritRevision] = change.CurrentRevision
+	if _, ok := labels[gerritclient.GerritReportLabel]; !ok {
+		labels[gerritclient.GerritReportLabel] = gerritclient.CodeReview
+	}
+	annotations := map[string]string{}
+	for k, v := range jobAnnotations {
+		annotations[k] = v
+	}
+	annotations[gerritclient.GerritID] = change.ID
+	annotations[gerritclient.GerritInstance] = instance
+	return labels, annotations
+}
+
+func (p *gerritProvider) mergePRs(sp subpool, prs []PullRequest) ([]PullRequest, error) {
+	instance, err := p.instance(sp.org)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged []PullRequest
+	var failed []int
+	var errs []error
+	log := sp.log.WithField("merge-targets", prNumbers(prs))
+	for _, pr := range prs {
+		log := log.WithFields(pr.logFields())
+		change, err := p.change(sp.org, sp.repo, int(pr.Number))
+		if err != nil {
+			log.WithError(err).Error("Failed to find change to submit.")
+			errs = append(errs, err)
+			failed = append(failed, int(pr.Number))
+			continue
+		}
+		if p.dryRun {
+			log.WithField("change", change.ID).Info("Not submitting change in dry-run mode.")
+			merged = append(merged, pr)
+			continue
+		}
+		keepTrying, err := tryMerge(func() error {
+			_, err := p.gc.SubmitChange(instance, change.ID)
+			return err
+		})
+		switch err.(type) {
+		case nil:
+			log.Info("Submitted.")
+			merged = append(merged, pr)
+		case gerritclient.UnsubmittableChangeError:
+			// These are user errors, shouldn't be printed as tide errors
+			log.WithError(err).Debug("Submit failed.")
+		default:
+			log.WithError(err).Error("Submit failed.")
+			errs = append(errs, err)
+			failed = append(failed, int(pr.Number))
+		}
+		if !keepTrying {
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return merged, nil
+	}
+
+	// Construct a more informative error.
+	var batch string
+	if len(prs) > 1 {
+		batch = fmt.Sprintf(" from batch %v", prNumbers(prs))
+		if len(merged) > 0 {
+			batch = fmt.Sprintf("%s, partial merge %v", batch, prNumbers(merged))
+		}
+	}
+	return merged, fmt.Errorf("failed submitting %v%s: %v", failed, batch, utilerrors.NewAggregate(errs))
+}
+
+// gerritGitClientFactory clones gerrit projects, whose org is the host of
+// their gerrit instance, with one git client factory per host.
+type gerritGitClientFactory struct {
+	sync.Mutex
+	factories map[string]git.ClientFactory
+}
+
+// NewGerritGitClientFactory returns a git client factory for the orgs and
+// repos of gerrit changes in the pool.
+func NewGerritGitClientFactory() git.ClientFactory {
+	return &gerritGitClientFactory{factories: map[string]git.ClientFactory{}}
+}
+
+func (f *gerritGitClientFactory) factory(host string) (git.ClientFactory, error) {
+	f.Lock()
+	defer f.Unlock()
+	if factory, ok := f.factories[host]; ok {
+		return factory, nil
+	}
+	factory, err := git.NewClientFactory(func(o *git.ClientFactoryOpts) {
+		o.Host = host
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.factories[host] = factory
+	return factory, nil
+}
+
+// ClientFromDir creates a client for a project that has already been cloned
+// to the given directory.
+func (f *gerritGitClientFactory) ClientFromDir(org, repo, dir string) (git.RepoClient, error) {
+	factory, err := f.factory(org)
+	if err != nil {
+		return nil, err
+	}
+	// The project is the whole path of the remote, https://<host>/<project>.
+	return factory.ClientFromDir("", repo, dir)
+}
+
+// ClientFor creates a client for a new clone of the project.
+func (f *gerritGitClientFactory) ClientFor(org, repo string) (git.RepoClient, error) {
+	factory, err := f.factory(org)
+	if err != nil {
+		return nil, err
+	}
+	return factory.ClientFor("", repo)
+}
+
+// Clean removes the caches of all hosts.
+func (f *gerritGitClientFactory) Clean() error {
+	f.Lock()
+	defer f.Unlock()
+	var errs []error
+	for _, factory := range f.factories {
+		if err := factory.Clean(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,350 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	gerritclient "k8s.io/test-infra/prow/gerrit/client"
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+const (
+	testGerritInstance = "https://gerrit-review.example.com"
+	testGerritHost     = "gerrit-review.example.com"
+)
+
+type fakeGerritClient struct {
+	changes    map[string][]gerritclient.ChangeInfo
+	branches   map[string]string
+	submitErrs map[string]error
+	queries    []string
+	submitted  []string
+}
+
+func (f *fakeGerritClient) QueryChangesForProject(instance, project, query string, rateLimit int) ([]gerritclient.ChangeInfo, error) {
+	if instance != testGerritInstance {
+		return nil, fmt.Errorf("unexpected instance %s", instance)
+	}
+	f.queries = append(f.queries, query)
+	return f.changes[project], nil
+}
+
+func (f *fakeGerritClient) GetBranchRevision(instance, project, branch string) (string, error) {
+	revision, ok := f.branches[project+":"+branch]
+	if !ok {
+		return "", fmt.Errorf("no branch %s in %s", branch, project)
+	}
+	return revision, nil
+}
+
+func (f *fakeGerritClient) SubmitChange(instance, id string) (*gerritclient.ChangeInfo, error) {
+	if err := f.submitErrs[id]; err != nil {
+		return nil, err
+	}
+	f.submitted = append(f.submitted, id)
+	return &gerritclient.ChangeInfo{ID: id, Status: gerritclient.Merged}, nil
+}
+
+func makeGerritChange(project string, number int, revision string) gerritclient.ChangeInfo {
+	return gerritclient.ChangeInfo{
+		ID:              fmt.Sprintf("%s~master~I%d", project, number),
+		Project:         project,
+		Branch:          "master",
+		Number:          number,
+		Subject:         fmt.Sprintf("Change %d", number),
+		Status:          gerritclient.New,
+		Mergeable:       true,
+		CurrentRevision: revision,
+		Revisions: map[string]gerritclient.RevisionInfo{
+			revision: {
+				Ref:   fmt.Sprintf("refs/changes/%02d/%d/1", number%100, number),
+				Files: map[string]gerritclient.FileInfo{"README.md": {}},
+				Commit: gerrit.CommitInfo{
+					Author: gerrit.GitPersonInfo{Name: "Alice"},
+				},
+			},
+		},
+	}
+}
+
+func TestChangeToPullRequest(t *testing.T) {
+	change := makeGerritChange("platform/build", 42, "abc")
+	pr, err := changeToPullRequest(testGerritHost, change)
+	if err != nil {
+		t.Fatalf("changeToPullRequest: %v", err)
+	}
+	if got, want := prKey(&pr), testGerritHost+"/platform/build#42"; got != want {
+		t.Errorf("wrong key: got %s, want %s", got, want)
+	}
+	if got, want := pr.logFields(), (logrus.Fields{"org": testGerritHost, "repo": "platform/build", "pr": 42, "branch": "master", "sha": "abc"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong fields: got %v, want %v", got, want)
+	}
+	if pr.HeadRefName != "refs/changes/42/42/1" || pr.Author.Login != "Alice" {
+		t.Errorf("wrong head ref %q or author %q", pr.HeadRefName, pr.Author.Login)
+	}
+
+	change.CurrentRevision = "missing"
+	if _, err := changeToPullRequest(testGerritHost, change); err == nil {
+		t.Error("expected an error for a change without its current revision")
+	}
+}
+
+func TestGerritSync(t *testing.T) {
+	const project = "platform/build"
+	presubmits := []config.Presubmit{{
+		JobBase:   config.JobBase{Name: "pull-build"},
+		AlwaysRun: true,
+		Reporter:  config.Reporter{Context: "pull-build"},
+	}}
+	if err := config.SetPresubmitRegexes(presubmits); err != nil {
+		t.Fatalf("failed to set presubmit regexes: %v", err)
+	}
+	cfg := func() *config.Config {
+		return &config.Config{
+			JobConfig: config.JobConfig{
+				PresubmitsStatic: map[string][]config.Presubmit{
+					testGerritInstance + "/" + project: presubmits,
+				},
+			},
+			ProwConfig: config.ProwConfig{
+				ProwJobNamespace: "default",
+				Tide: config.Tide{
+					MaxGoroutines: 1,
+					Gerrit:        config.TideGerritConfig{Query: "is:submittable"},
+				},
+				Gerrit: config.Gerrit{RateLimit: 5},
+			},
+		}
+	}
+
+	passing := func(number int, sha, baseSHA string) runtime.Object {
+		return &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pj-%d", number), Namespace: "default"},
+			Spec: prowapi.ProwJobSpec{
+				Type:    prowapi.PresubmitJob,
+				Job:     "pull-build",
+				Context: "pull-build",
+				Refs: &prowapi.Refs{
+					Org:     testGerritHost,
+					Repo:    project,
+					BaseRef: "master",
+					BaseSHA: baseSHA,
+					Pulls:   []prowapi.Pull{{Number: number, SHA: sha}},
+				},
+			},
+			Status: prowapi.ProwJobStatus{State: prowapi.SuccessState},
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		prowJobs    []runtime.Object
+		dryRun      bool
+		action      Action
+		submitted   []string
+		triggeredOn string
+	}{
+		{
+			name:        "tests the change on the tip of the branch",
+			action:      Trigger,
+			triggeredOn: "base",
+		},
+		{
+			name:        "retests a change that passed on an older tip",
+			prowJobs:    []runtime.Object{passing(1, "abc", "old-base")},
+			action:      Trigger,
+			triggeredOn: "base",
+		},
+		{
+			name:      "submits a change that passed on the tip",
+			prowJobs:  []runtime.Object{passing(1, "abc", "base")},
+			action:    Merge,
+			submitted: []string{project + "~master~I1"},
+		},
+		{
+			name:     "does not submit in dry-run mode",
+			prowJobs: []runtime.Object{passing(1, "abc", "base")},
+			dryRun:   true,
+			action:   Merge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gc := &fakeGerritClient{
+				changes:  map[string][]gerritclient.ChangeInfo{project: {makeGerritChange(project, 1, "abc")}},
+				branches: map[string]string{project + ":master": "base"},
+			}
+			hist, err := history.New(10, nil, "")
+			if err != nil {
+				t.Fatalf("failed to create history client: %v", err)
+			}
+			log := logrus.WithField("test", tc.name)
+			provider, err := newGerritProvider(log, gc, cfg, map[string][]string{testGerritInstance: {project}}, tc.dryRun)
+			if err != nil {
+				t.Fatalf("newGerritProvider: %v", err)
+			}
+			mgr := newFakeManager(tc.prowJobs...)
+			c, err := newSyncController(context.Background(), log, provider, mgr, cfg, nil, nil, hist)
+			if err != nil {
+				t.Fatalf("failed to construct sync controller: %v", err)
+			}
+
+			if err := c.Sync(); err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+			if len(c.pools) != 1 {
+				t.Fatalf("expected one pool, got %d", len(c.pools))
+			}
+			if got := c.pools[0].Action; got != tc.action {
+				t.Errorf("wrong action: got %s, want %s", got, tc.action)
+			}
+			if want := []string{"is:submittable"}; !reflect.DeepEqual(gc.queries, want) {
+				t.Errorf("wrong queries: got %v, want %v", gc.queries, want)
+			}
+			if !reflect.DeepEqual(gc.submitted, tc.submitted) {
+				t.Errorf("wrong submissions: got %v, want %v", gc.submitted, tc.submitted)
+			}
+
+			var pjs prowapi.ProwJobList
+			if err := mgr.GetClient().List(context.Background(), &pjs); err != nil {
+				t.Fatalf("failed to list prowjobs: %v", err)
+			}
+			var triggered []prowapi.ProwJob
+			for _, pj := range pjs.Items {
+				if pj.Status.State == prowapi.TriggeredState {
+					triggered = append(triggered, pj)
+				}
+			}
+			if tc.triggeredOn == "" {
+				if len(triggered) != 0 {
+					t.Errorf("expected no jobs to be triggered, got %d", len(triggered))
+				}
+				return
+			}
+			if len(triggered) != 1 {
+				t.Fatalf("expected one triggered job, got %d", len(triggered))
+			}
+			pj := triggered[0]
+			if pj.Spec.Refs.BaseSHA != tc.triggeredOn {
+				t.Errorf("job tests base %s, want %s", pj.Spec.Refs.BaseSHA, tc.triggeredOn)
+			}
+			if got, want := pj.Spec.Refs.CloneURI, testGerritInstance+"/"+project; got != want {
+				t.Errorf("wrong clone URI: got %s, want %s", got, want)
+			}
+			if got, want := pj.Spec.Refs.Pulls[0].Ref, "refs/changes/01/1/1"; got != want {
+				t.Errorf("wrong pull ref: got %s, want %s", got, want)
+			}
+			if got, want := pj.Annotations[gerritclient.GerritID], project+"~master~I1"; got != want {
+				t.Errorf("wrong gerrit id: got %s, want %s", got, want)
+			}
+			if got := pj.Annotations[gerritclient.GerritInstance]; got != testGerritInstance {
+				t.Errorf("wrong gerrit instance: got %s, want %s", got, testGerritInstance)
+			}
+			if got := pj.Labels[gerritclient.GerritRevision]; got != "abc" {
+				t.Errorf("wrong gerrit revision: got %s, want abc", got)
+			}
+		})
+	}
+}
+
+func TestGerritMergePRs(t *testing.T) {
+	const project = "platform/build"
+	testCases := []struct {
+		name       string
+		submitErrs map[string]error
+		missing    bool
+		merged     []int
+		submitted  []string
+		expectErr  bool
+	}{
+		{
+			name:      "submits all changes",
+			merged:    []int{1, 2},
+			submitted: []string{project + "~master~I1", project + "~master~I2"},
+		},
+		{
+			name:       "unsubmittable changes are not tide errors",
+			submitErrs: map[string]error{project + "~master~I1": gerritclient.UnsubmittableChangeError("needs Code-Review")},
+			merged:     []int{2},
+			submitted:  []string{project + "~master~I2"},
+		},
+		{
+			name:       "other submit errors are returned",
+			submitErrs: map[string]error{project + "~master~I1": errors.New("injected error")},
+			merged:     []int{2},
+			submitted:  []string{project + "~master~I2"},
+			expectErr:  true,
+		},
+		{
+			name:      "changes missing from the pool are returned as errors",
+			missing:   true,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := []gerritclient.ChangeInfo{makeGerritChange(project, 1, "abc"), makeGerritChange(project, 2, "def")}
+			gc := &fakeGerritClient{submitErrs: tc.submitErrs}
+			if !tc.missing {
+				gc.changes = map[string][]gerritclient.ChangeInfo{project: changes}
+			}
+			cfg := func() *config.Config { return &config.Config{} }
+			log := logrus.WithField("test", tc.name)
+			provider, err := newGerritProvider(log, gc, cfg, map[string][]string{testGerritInstance: {project}}, false)
+			if err != nil {
+				t.Fatalf("newGerritProvider: %v", err)
+			}
+			if _, err := provider.Query(); err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+
+			var prs []PullRequest
+			for _, change := range changes {
+				pr, err := changeToPullRequest(testGerritHost, change)
+				if err != nil {
+					t.Fatalf("changeToPullRequest: %v", err)
+				}
+				prs = append(prs, pr)
+			}
+			sp := subpool{log: log, org: testGerritHost, repo: project, branch: "master"}
+			merged, err := provider.mergePRs(sp, prs)
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if got := prNumbers(merged); !reflect.DeepEqual(got, tc.merged) {
+				t.Errorf("wrong merged changes: got %v, want %v", got, tc.merged)
+			}
+			if !reflect.DeepEqual(gc.submitted, tc.submitted) {
+				t.Errorf("wrong submissions: got %v, want %v", gc.submitted, tc.submitted)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/tide/blockers"
+)
+
+// githubProvider implements provider for GitHub pull requests.
+type githubProvider struct {
+	cfg                config.Getter
+	ghc                githubClient
+	gc                 git.ClientFactory
+	mergeChecker       *mergeChecker
+	usesGitHubAppsAuth bool
+	logger             *logrus.Entry
+}
+
+func newGitHubProvider(
+	logger *logrus.Entry,
+	ghc githubClient,
+	gc git.ClientFactory,
+	cfg config.Getter,
+	mergeChecker *mergeChecker,
+	usesGitHubAppsAuth bool,
+) *githubProvider {
+	return &githubProvider{
+		logger:             logger,
+		ghc:                ghc,
+		gc:                 gc,
+		cfg:                cfg,
+		mergeChecker:       mergeChecker,
+		usesGitHubAppsAuth: usesGitHubAppsAuth,
+	}
+}
+
+func (gi *githubProvider) Query() (map[string]PullRequest, error) {
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	prs := make(map[string]PullRequest)
+	var errs []error
+	for _, query := range gi.cfg().Tide.Queries {
+
+		// Use org-sharded queries only when GitHub apps auth is in use
+		var queries map[string]string
+		if gi.usesGitHubAppsAuth {
+			queries = query.OrgQueries()
+		} else {
+			queries = map[string]string{"": query.Query()}
+		}
+
+		for org, q := range queries {
+			org, q := org, q
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results, err := search(gi.ghc.QueryWithGitHubAppsSupport, gi.logger, q, time.Time{}, time.Now(), org)
+				lock.Lock()
+				defer lock.Unlock()
+
+				if err != nil && len(results) == 0 {
+					errs = append(errs, fmt.Errorf("query %q, err: %v", q, err))
+					return
+				}
+				if err != nil {
+					gi.logger.WithError(err).WithField("query", q).Warning("found partial results")
+				}
+
+				for _, pr := range results {
+					prs[prKey(&pr)] = pr
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	return prs, utilerrors.NewAggregate(errs)
+}
+
+func (gi *githubProvider) blockers() (blockers.Blockers, error) {
+	label := gi.cfg().Tide.BlockerLabel
+	if label == "" {
+		return blockers.Blockers{}, nil
+	}
+
+	gi.logger.Debugf("Searching for blocking issues (label %q).", label)
+	orgExcepts, repos := gi.cfg().Tide.Queries.OrgExceptionsAndRepos()
+	orgs := make([]string, 0, len(orgExcepts))
+	for org := range orgExcepts {
+		orgs = append(orgs, org)
+	}
+	orgRepoQuery := orgRepoQueryString(orgs, repos.UnsortedList(), orgExcepts)
+	return blockers.FindAll(gi.ghc, gi.logger, label, orgRepoQuery)
+}
+
+func (gi *githubProvider) isAllowedToMerge(pr *PullRequest) (string, error) {
+	return gi.mergeChecker.isAllowed(pr)
+}
+
+func (gi *githubProvider) GetRef(org, repo, ref string) (string, error) {
+	return gi.ghc.GetRef(org, repo, ref)
+}
+
+func (gi *githubProvider) headContexts(log *logrus.Entry, pr *PullRequest) ([]Context, error) {
+	return headContexts(log, gi.ghc, pr)
+}
+
+func (gi *githubProvider) GetPresubmits(org, repo string, baseSHAGetter config.RefGetter, headSHAGetters ...config.RefGetter) ([]config.Presubmit, error) {
+	return gi.cfg().GetPresubmits(gi.gc, org+"/"+repo, baseSHAGetter, headSHAGetters...)
+}
+
+func (gi *githubProvider) GetTideContextPolicy(org, repo, branch string, baseSHAGetter config.RefGetter, headSHA string) (contextChecker, error) {
+	return gi.cfg().GetTideContextPolicy(gi.gc, org, repo, branch, baseSHAGetter, headSHA)
+}
+
+func (gi *githubProvider) GetChangedFiles(org, repo string, number int) ([]string, error) {
+	changes, err := gi.ghc.GetPullRequestChanges(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	changedFiles := make([]string, 0, len(changes))
+	for _, change := range changes {
+		changedFiles = append(changedFiles, change.Filename)
+	}
+	return changedFiles, nil
+}
+
+func (gi *githubProvider) refsForJob(sp subpool, prs []PullRequest) prowapi.Refs {
+	refs := prowapi.Refs{
+		Org:     sp.org,
+		Repo:    sp.repo,
+		BaseRef: sp.branch,
+		BaseSHA: sp.sha,
+	}
+	for _, pr := range prs {
+		refs.Pulls = append(
+			refs.Pulls,
+			prowapi.Pull{
+				Number: int(pr.Number),
+				Author: string(pr.Author.Login),
+				SHA:    string(pr.HeadRefOID),
+			},
+		)
+	}
+	return refs
+}
+
+func (gi *githubProvider) labelsAndAnnotations(jobLabels, jobAnnotations map[string]string, _ ...PullRequest) (map[string]string, map[string]string) {
+	return jobLabels, jobAnnotations
+}
+
+func (gi *githubProvider) prepareMergeDetails(commitTemplates config.TideMergeCommitTemplate, pr PullRequest, mergeMethod github.PullRequestMergeType) github.MergeDetails {
+	ghMergeDetails := github.MergeDetails{
+		SHA:         string(pr.HeadRefOID),
+		MergeMethod: string(mergeMethod),
+	}
+
+	if commitTemplates.Title != nil {
+		var b bytes.Buffer
+
+		if err := commitTemplates.Title.Execute(&b, pr); err != nil {
+			gi.logger.Errorf("error executing commit title template: %v", err)
+		} else {
+			ghMergeDetails.CommitTitle = b.String()
+		}
+	}
+
+	if commitTemplates.Body != nil {
+		var b bytes.Buffer
+
+		if err := commitTemplates.Body.Execute(&b, pr); err != nil {
+			gi.logger.Errorf("error executing commit body template: %v", err)
+		} else {
+			ghMergeDetails.CommitMessage = b.String()
+		}
+	}
+
+	return ghMergeDetails
+}
+
+func (gi *githubProvider) mergePRs(sp subpool, prs []PullRequest) ([]PullRequest, error) {
+	var merged []PullRequest
+	var failed []int
+	var errs []error
+	log := sp.log.WithField("merge-targets", prNumbers(prs))
+	tideConfig := gi.cfg().Tide
+	for i, pr := range prs {
+		log := log.WithFields(pr.logFields())
+		mergeMethod, err := prMergeMethod(tideConfig, &pr)
+		if err != nil {
+			log.WithError(err).Error("Failed to determine merge method.")
+			errs = append(errs, err)
+			failed = append(failed, int(pr.Number))
+			continue
+		}
+
+		commitTemplates := tideConfig.MergeCommitTemplate(config.OrgRepo{Org: sp.org, Repo: sp.repo})
+		keepTrying, err := tryMerge(func() error {
+			ghMergeDetails := gi.prepareMergeDetails(commitTemplates, pr, mergeMethod)
+			return gi.ghc.Merge(sp.org, sp.repo, int(pr.Number), ghMergeDetails)
+		})
+		if err != nil {
+			// These are user errors, shouldn't be printed as tide errors
+			log.WithError(err).Debug("Merge failed.")
+		} else {
+			log.Info("Merged.")
+			merged = append(merged, pr)
+		}
+		if !keepTrying {
+			break
+		}
+		// If we successfully merged this PR and have more to merge, sleep to give
+		// GitHub time to recalculate mergeability.
+		if err == nil && i+1 < len(prs) {
+			sleep(time.Second * 5)
+		}
+	}
+
+	if len(errs) == 0 {
+		return merged, nil
+	}
+
+	// Construct a more informative error.
+	var batch string
+	if len(prs) > 1 {
+		batch = fmt.Sprintf(" from batch %v", prNumbers(prs))
+		if len(merged) > 0 {
+			batch = fmt.Sprintf("%s, partial merge %v", batch, prNumbers(merged))
+		}
+	}
+	return merged, fmt.Errorf("failed merging %v%s: %v", failed, batch, utilerrors.NewAggregate(errs))
+}
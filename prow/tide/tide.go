@@ -20,7 +20,6 @@ limitations under the License.
 package tide
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -37,7 +36,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
-	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/git/v2"
@@ -70,16 +68,47 @@ type contextChecker interface {
 	MissingRequiredContexts([]string) []string
 }
 
+// provider is the code-review host whose changes the sync controller pools.
+// Dividing the pool into subpools, accumulating job results, batching and
+// picking an action are shared between hosts, everything that talks to the
+// host goes through the provider.
+type provider interface {
+	// Query returns the PRs that meet merge requirements, keyed by prKey.
+	Query() (map[string]PullRequest, error)
+	// blockers returns the issues blocking merges in the pool.
+	blockers() (blockers.Blockers, error)
+	// isAllowedToMerge explains why a PR cannot be merged, or returns "" if
+	// it can.
+	isAllowedToMerge(pr *PullRequest) (string, error)
+	// GetRef returns the SHA a ref of the repo points to.
+	GetRef(org, repo, ref string) (string, error)
+	// headContexts returns the status contexts of the head commit of a PR.
+	headContexts(log *logrus.Entry, pr *PullRequest) ([]Context, error)
+	// GetPresubmits returns the presubmits configured for a repo.
+	GetPresubmits(org, repo string, baseSHAGetter config.RefGetter, headSHAGetters ...config.RefGetter) ([]config.Presubmit, error)
+	// GetTideContextPolicy returns the contexts required to merge a PR.
+	GetTideContextPolicy(org, repo, branch string, baseSHAGetter config.RefGetter, headSHA string) (contextChecker, error)
+	// GetChangedFiles returns the files changed by a PR.
+	GetChangedFiles(org, repo string, number int) ([]string, error)
+	// refsForJob returns the refs to test the PRs with.
+	refsForJob(sp subpool, prs []PullRequest) prowapi.Refs
+	// labelsAndAnnotations adds what the host needs to report on a job
+	// testing the PRs to the labels and annotations of the job.
+	labelsAndAnnotations(jobLabels, jobAnnotations map[string]string, prs ...PullRequest) (labels, annotations map[string]string)
+	// mergePRs merges the PRs in order and returns those that merged.
+	mergePRs(sp subpool, prs []PullRequest) ([]PullRequest, error)
+}
+
 // Controller knows how to sync PRs and PJs.
 type Controller struct {
-	ctx                context.Context
-	logger             *logrus.Entry
-	config             config.Getter
-	ghc                githubClient
-	prowJobClient      ctrlruntimeclient.Client
-	gc                 git.ClientFactory
-	usesGitHubAppsAuth bool
-
+	ctx           context.Context
+	logger        *logrus.Entry
+	config        config.Getter
+	provider      provider
+	prowJobClient ctrlruntimeclient.Client
+	gc            git.ClientFactory
+
+	// sc is nil for providers without status contexts to update.
 	sc *statusController
 
 	m     sync.Mutex
@@ -89,8 +118,6 @@ type Controller struct {
 	// Cache entries expire if they are not used during a sync loop.
 	changedFiles *changedFilesAgent
 
-	mergeChecker *mergeChecker
-
 	History *history.History
 }
 
@@ -247,7 +274,8 @@ func NewController(ghcSync, ghcStatus github.Client, mgr manager, cfg config.Get
 	}
 	go sc.run()
 
-	return newSyncController(ctx, logger, ghcSync, mgr, cfg, gc, sc, hist, mergeChecker, usesGitHubAppsAuth)
+	provider := newGitHubProvider(logger, ghcSync, gc, cfg, mergeChecker, usesGitHubAppsAuth)
+	return newSyncController(ctx, logger, provider, mgr, cfg, gc, sc, hist)
 }
 
 func newStatusController(ctx context.Context, logger *logrus.Entry, ghc githubClient, mgr manager, gc git.ClientFactory, cfg config.Getter, opener io.Opener, statusURI string, mergeChecker *mergeChecker) (*statusController, error) {
@@ -271,14 +299,12 @@ func newStatusController(ctx context.Context, logger *logrus.Entry, ghc githubCl
 func newSyncController(
 	ctx context.Context,
 	logger *logrus.Entry,
-	ghcSync githubClient,
+	provider provider,
 	mgr manager,
 	cfg config.Getter,
 	gc git.ClientFactory,
 	sc *statusController,
 	hist *history.History,
-	mergeChecker *mergeChecker,
-	usesGitHubAppsAuth bool,
 ) (*Controller, error) {
 	if err := mgr.GetFieldIndexer().IndexField(
 		ctx,
@@ -297,20 +323,18 @@ func newSyncController(
 		return nil, fmt.Errorf("failed to add index for non failed batches: %w", err)
 	}
 	return &Controller{
-		ctx:                ctx,
-		logger:             logger.WithField("controller", "sync"),
-		ghc:                ghcSync,
-		prowJobClient:      mgr.GetClient(),
-		config:             cfg,
-		gc:                 gc,
-		usesGitHubAppsAuth: usesGitHubAppsAuth,
-		sc:                 sc,
+		ctx:           ctx,
+		logger:        logger.WithField("controller", "sync"),
+		provider:      provider,
+		prowJobClient: mgr.GetClient(),
+		config:        cfg,
+		gc:            gc,
+		sc:            sc,
 		changedFiles: &changedFilesAgent{
-			ghc:             ghcSync,
+			provider:        provider,
 			nextChangeCache: make(map[changeCacheKey][]string),
 		},
-		mergeChecker: mergeChecker,
-		History:      hist,
+		History: hist,
 	}, nil
 }
 
@@ -319,7 +343,9 @@ func newSyncController(
 // Controller.Sync() should not be used after this function is called.
 func (c *Controller) Shutdown() {
 	c.History.Flush()
-	c.sc.shutdown()
+	if c.sc != nil {
+		c.sc.shutdown()
+	}
 }
 
 func prKey(pr *PullRequest) string {
@@ -368,9 +394,9 @@ func (c *Controller) Sync() error {
 	c.config().BranchProtectionWarnings(c.logger, c.config().PresubmitsStatic)
 
 	c.logger.Debug("Building tide pool.")
-	prs, err := c.query()
+	prs, err := c.provider.Query()
 	if err != nil {
-		return fmt.Errorf("failed to query GitHub for prs: %w", err)
+		return fmt.Errorf("failed to query for prs: %w", err)
 	}
 	c.logger.WithFields(logrus.Fields{
 		"duration":       time.Since(start).String(),
@@ -379,18 +405,9 @@ func (c *Controller) Sync() error {
 
 	var blocks blockers.Blockers
 	if len(prs) > 0 {
-		if label := c.config().Tide.BlockerLabel; label != "" {
-			c.logger.Debugf("Searching for blocking issues (label %q).", label)
-			orgExcepts, repos := c.config().Tide.Queries.OrgExceptionsAndRepos()
-			orgs := make([]string, 0, len(orgExcepts))
-			for org := range orgExcepts {
-				orgs = append(orgs, org)
-			}
-			orgRepoQuery := orgRepoQueryString(orgs, repos.UnsortedList(), orgExcepts)
-			blocks, err = blockers.FindAll(c.ghc, c.logger, label, orgRepoQuery)
-			if err != nil {
-				return err
-			}
+		blocks, err = c.provider.blockers()
+		if err != nil {
+			return err
 		}
 	}
 	// Partition PRs into subpools and filter out non-pool PRs.
@@ -398,19 +415,21 @@ func (c *Controller) Sync() error {
 	if err != nil {
 		return err
 	}
-	filteredPools := c.filterSubpools(c.mergeChecker.isAllowed, rawPools)
+	filteredPools := c.filterSubpools(c.provider.isAllowedToMerge, rawPools)
 
 	// Notify statusController about the new pool.
-	c.sc.Lock()
-	c.sc.blocks = blocks
-	c.sc.poolPRs = poolPRMap(filteredPools)
-	c.sc.baseSHAs = baseSHAMap(filteredPools)
-	c.sc.requiredContexts = requiredContextsMap(filteredPools)
-	select {
-	case c.sc.newPoolPending <- true:
-	default:
+	if c.sc != nil {
+		c.sc.Lock()
+		c.sc.blocks = blocks
+		c.sc.poolPRs = poolPRMap(filteredPools)
+		c.sc.baseSHAs = baseSHAMap(filteredPools)
+		c.sc.requiredContexts = requiredContextsMap(filteredPools)
+		select {
+		case c.sc.newPoolPending <- true:
+		default:
+		}
+		c.sc.Unlock()
 	}
-	c.sc.Unlock()
 
 	// Sync subpools in parallel.
 	poolChan := make(chan Pool, len(filteredPools))
@@ -441,49 +460,6 @@ func (c *Controller) Sync() error {
 	return nil
 }
 
-func (c *Controller) query() (map[string]PullRequest, error) {
-	lock := sync.Mutex{}
-	wg := sync.WaitGroup{}
-	prs := make(map[string]PullRequest)
-	var errs []error
-	for _, query := range c.config().Tide.Queries {
-
-		// Use org-sharded queries only when GitHub apps auth is in use
-		var queries map[string]string
-		if c.usesGitHubAppsAuth {
-			queries = query.OrgQueries()
-		} else {
-			queries = map[string]string{"": query.Query()}
-		}
-
-		for org, q := range queries {
-			org, q := org, q
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				results, err := search(c.ghc.QueryWithGitHubAppsSupport, c.logger, q, time.Time{}, time.Now(), org)
-				lock.Lock()
-				defer lock.Unlock()
-
-				if err != nil && len(results) == 0 {
-					errs = append(errs, fmt.Errorf("query %q, err: %v", q, err))
-					return
-				}
-				if err != nil {
-					c.logger.WithError(err).WithField("query", q).Warning("found partial results")
-				}
-
-				for _, pr := range results {
-					prs[prKey(&pr)] = pr
-				}
-			}()
-		}
-	}
-	wg.Wait()
-
-	return prs, utilerrors.NewAggregate(errs)
-}
-
 func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c.m.Lock()
 	defer c.m.Unlock()
@@ -537,7 +513,7 @@ func (c *Controller) filterSubpools(mergeAllowed func(*PullRequest) (string, err
 				return
 			}
 			key := poolKey(sp.org, sp.repo, sp.branch)
-			if spFiltered := filterSubpool(c.ghc, mergeAllowed, sp); spFiltered != nil {
+			if spFiltered := filterSubpool(c.provider, mergeAllowed, sp); spFiltered != nil {
 				sp.log.WithField("key", key).WithField("pool", spFiltered).Debug("filtered sub-pool")
 
 				lock.Lock()
@@ -559,7 +535,7 @@ func (c *Controller) initSubpoolData(sp *subpool) error {
 	}
 	sp.cc = make(map[int]contextChecker, len(sp.prs))
 	for _, pr := range sp.prs {
-		sp.cc[int(pr.Number)], err = c.config().GetTideContextPolicy(c.gc, sp.org, sp.repo, sp.branch, refGetterFactory(string(sp.sha)), string(pr.HeadRefOID))
+		sp.cc[int(pr.Number)], err = c.provider.GetTideContextPolicy(sp.org, sp.repo, sp.branch, refGetterFactory(string(sp.sha)), string(pr.HeadRefOID))
 		if err != nil {
 			return fmt.Errorf("error setting up context checker for pr %d: %v", int(pr.Number), err)
 		}
@@ -571,10 +547,10 @@ func (c *Controller) initSubpoolData(sp *subpool) error {
 // filtered subpool.
 // If the subpool becomes empty 'nil' is returned to indicate that the subpool
 // should be deleted.
-func filterSubpool(ghc githubClient, mergeAllowed func(*PullRequest) (string, error), sp *subpool) *subpool {
+func filterSubpool(provider provider, mergeAllowed func(*PullRequest) (string, error), sp *subpool) *subpool {
 	var toKeep []PullRequest
 	for _, pr := range sp.prs {
-		if !filterPR(ghc, mergeAllowed, sp, &pr) {
+		if !filterPR(provider, mergeAllowed, sp, &pr) {
 			toKeep = append(toKeep, pr)
 		}
 	}
@@ -594,7 +570,7 @@ func filterSubpool(ghc githubClient, mergeAllowed func(*PullRequest) (string, er
 //   status is preventing merge. Required ProwJob statuses are allowed to be
 //   'pending' because this prevents kicking PRs from the pool when Tide is
 //   retesting them.)
-func filterPR(ghc githubClient, mergeAllowed func(*PullRequest) (string, error), sp *subpool, pr *PullRequest) bool {
+func filterPR(provider provider, mergeAllowed func(*PullRequest) (string, error), sp *subpool, pr *PullRequest) bool {
 	log := sp.log.WithFields(pr.logFields())
 	// Skip PRs that are known to be unmergeable.
 	if reason, err := mergeAllowed(pr); err != nil {
@@ -607,7 +583,7 @@ func filterPR(ghc githubClient, mergeAllowed func(*PullRequest) (string, error),
 
 	// Filter out PRs with unsuccessful contexts unless the only unsuccessful
 	// contexts are pending required prowjobs.
-	contexts, err := headContexts(log, ghc, pr)
+	contexts, err := provider.headContexts(log, pr)
 	if err != nil {
 		log.WithError(err).Error("Getting head contexts.")
 		return true
@@ -766,9 +742,9 @@ func toSimpleState(s prowapi.ProwJobState) simpleState {
 
 // isPassingTests returns whether or not all contexts set on the PR except for
 // the tide pool context are passing.
-func isPassingTests(log *logrus.Entry, ghc githubClient, pr PullRequest, cc contextChecker) bool {
+func isPassingTests(log *logrus.Entry, provider provider, pr PullRequest, cc contextChecker) bool {
 	log = log.WithFields(pr.logFields())
-	contexts, err := headContexts(log, ghc, &pr)
+	contexts, err := provider.headContexts(log, &pr)
 	if err != nil {
 		log.WithError(err).Error("Getting head commit status contexts.")
 		// If we can't get the status of the commit, assume that it is failing.
@@ -816,7 +792,7 @@ func hasAllLabels(pr PullRequest, labels []string) bool {
 	return prLabels.Intersection(requiredLabels).Equal(requiredLabels)
 }
 
-func pickHighestPriorityPR(log *logrus.Entry, ghc githubClient, prs []PullRequest, cc map[int]contextChecker, isPassingTestsFunc func(*logrus.Entry, githubClient, PullRequest, contextChecker) bool, priorities []config.TidePriority) (bool, PullRequest) {
+func pickHighestPriorityPR(log *logrus.Entry, provider provider, prs []PullRequest, cc map[int]contextChecker, isPassingTestsFunc func(*logrus.Entry, provider, PullRequest, contextChecker) bool, priorities []config.TidePriority) (bool, PullRequest) {
 	smallestNumber := -1
 	var smallestPR PullRequest
 	for _, p := range append(priorities, config.TidePriority{}) {
@@ -830,7 +806,7 @@ func pickHighestPriorityPR(log *logrus.Entry, ghc githubClient, prs []PullReques
 			if len(pr.Commits.Nodes) < 1 {
 				continue
 			}
-			if !isPassingTestsFunc(log, ghc, pr, cc[int(pr.Number)]) {
+			if !isPassingTestsFunc(log, provider, pr, cc[int(pr.Number)]) {
 				continue
 			}
 			smallestNumber = int(pr.Number)
@@ -1013,7 +989,7 @@ func (c *Controller) pickBatch(sp subpool, cc map[int]contextChecker) ([]PullReq
 
 	var candidates []PullRequest
 	for _, pr := range sp.prs {
-		if isPassingTests(sp.log, c.ghc, pr, cc[int(pr.Number)]) {
+		if isPassingTests(sp.log, c.provider, pr, cc[int(pr.Number)]) {
 			candidates = append(candidates, pr)
 		}
 	}
@@ -1065,35 +1041,6 @@ func (c *Controller) pickBatch(sp subpool, cc map[int]contextChecker) ([]PullReq
 	return res, presubmits, nil
 }
 
-func (c *Controller) prepareMergeDetails(commitTemplates config.TideMergeCommitTemplate, pr PullRequest, mergeMethod github.PullRequestMergeType) github.MergeDetails {
-	ghMergeDetails := github.MergeDetails{
-		SHA:         string(pr.HeadRefOID),
-		MergeMethod: string(mergeMethod),
-	}
-
-	if commitTemplates.Title != nil {
-		var b bytes.Buffer
-
-		if err := commitTemplates.Title.Execute(&b, pr); err != nil {
-			c.logger.Errorf("error executing commit title template: %v", err)
-		} else {
-			ghMergeDetails.CommitTitle = b.String()
-		}
-	}
-
-	if commitTemplates.Body != nil {
-		var b bytes.Buffer
-
-		if err := commitTemplates.Body.Execute(&b, pr); err != nil {
-			c.logger.Errorf("error executing commit body template: %v", err)
-		} else {
-			ghMergeDetails.CommitMessage = b.String()
-		}
-	}
-
-	return ghMergeDetails
-}
-
 func prMergeMethod(c config.Tide, pr *PullRequest) (github.PullRequestMergeType, error) {
 	repo := config.OrgRepo{Org: string(pr.Repository.Owner.Login), Repo: string(pr.Repository.Name)}
 	method := c.MergeMethod(repo)
@@ -1125,62 +1072,11 @@ func prMergeMethod(c config.Tide, pr *PullRequest) (github.PullRequestMergeType,
 }
 
 func (c *Controller) mergePRs(sp subpool, prs []PullRequest) error {
-	var merged, failed []int
-	defer func() {
-		if len(merged) == 0 {
-			return
-		}
+	merged, err := c.provider.mergePRs(sp, prs)
+	if len(merged) > 0 {
 		tideMetrics.merges.WithLabelValues(sp.org, sp.repo, sp.branch).Observe(float64(len(merged)))
-	}()
-
-	var errs []error
-	log := sp.log.WithField("merge-targets", prNumbers(prs))
-	tideConfig := c.config().Tide
-	for i, pr := range prs {
-		log := log.WithFields(pr.logFields())
-		mergeMethod, err := prMergeMethod(tideConfig, &pr)
-		if err != nil {
-			log.WithError(err).Error("Failed to determine merge method.")
-			errs = append(errs, err)
-			failed = append(failed, int(pr.Number))
-			continue
-		}
-
-		commitTemplates := tideConfig.MergeCommitTemplate(config.OrgRepo{Org: sp.org, Repo: sp.repo})
-		keepTrying, err := tryMerge(func() error {
-			ghMergeDetails := c.prepareMergeDetails(commitTemplates, pr, mergeMethod)
-			return c.ghc.Merge(sp.org, sp.repo, int(pr.Number), ghMergeDetails)
-		})
-		if err != nil {
-			// These are user errors, shouldn't be printed as tide errors
-			log.WithError(err).Debug("Merge failed.")
-		} else {
-			log.Info("Merged.")
-			merged = append(merged, int(pr.Number))
-		}
-		if !keepTrying {
-			break
-		}
-		// If we successfully merged this PR and have more to merge, sleep to give
-		// GitHub time to recalculate mergeability.
-		if err == nil && i+1 < len(prs) {
-			sleep(time.Second * 5)
-		}
-	}
-
-	if len(errs) == 0 {
-		return nil
-	}
-
-	// Construct a more informative error.
-	var batch string
-	if len(prs) > 1 {
-		batch = fmt.Sprintf(" from batch %v", prNumbers(prs))
-		if len(merged) > 0 {
-			batch = fmt.Sprintf("%s, partial merge %v", batch, merged)
-		}
 	}
-	return fmt.Errorf("failed merging %v%s: %v", failed, batch, utilerrors.NewAggregate(errs))
+	return err
 }
 
 // tryMerge attempts 1 merge and returns a bool indicating if we should try
@@ -1246,22 +1142,7 @@ func tryMerge(mergeFunc func() error) (bool, error) {
 }
 
 func (c *Controller) trigger(sp subpool, presubmits []config.Presubmit, prs []PullRequest) error {
-	refs := prowapi.Refs{
-		Org:     sp.org,
-		Repo:    sp.repo,
-		BaseRef: sp.branch,
-		BaseSHA: sp.sha,
-	}
-	for _, pr := range prs {
-		refs.Pulls = append(
-			refs.Pulls,
-			prowapi.Pull{
-				Number: int(pr.Number),
-				Author: string(pr.Author.Login),
-				SHA:    string(pr.HeadRefOID),
-			},
-		)
-	}
+	refs := c.provider.refsForJob(sp, prs)
 
 	// If PRs require the same job, we only want to trigger it once.
 	// If multiple required jobs have the same context, we assume the
@@ -1281,7 +1162,8 @@ func (c *Controller) trigger(sp subpool, presubmits []config.Presubmit, prs []Pu
 			}
 			spec = pjutil.BatchSpec(ps, refs)
 		}
-		pj := pjutil.NewProwJob(spec, ps.Labels, ps.Annotations)
+		labels, annotations := c.provider.labelsAndAnnotations(ps.Labels, ps.Annotations, prs...)
+		pj := pjutil.NewProwJob(spec, labels, annotations)
 		pj.Namespace = c.config().ProwJobNamespace
 		log := c.logger.WithFields(pjutil.ProwJobFields(&pj))
 		start := time.Now()
@@ -1316,7 +1198,7 @@ func (c *Controller) takeAction(sp subpool, batchPending, successes, pendings, m
 	// Do not merge PRs while waiting for a batch to complete. We don't want to
 	// invalidate the old batch result.
 	if len(successes) > 0 && len(batchPending) == 0 {
-		if ok, pr := pickHighestPriorityPR(sp.log, c.ghc, successes, sp.cc, isPassingTests, c.config().Tide.Priority); ok {
+		if ok, pr := pickHighestPriorityPR(sp.log, c.provider, successes, sp.cc, isPassingTests, c.config().Tide.Priority); ok {
 			return Merge, []PullRequest{pr}, c.mergePRs(sp, []PullRequest{pr})
 		}
 	}
@@ -1336,7 +1218,7 @@ func (c *Controller) takeAction(sp subpool, batchPending, successes, pendings, m
 	}
 	// If we have no serial jobs pending or successful, trigger one.
 	if len(missings) > 0 && len(pendings) == 0 && len(successes) == 0 {
-		if ok, pr := pickHighestPriorityPR(sp.log, c.ghc, missings, sp.cc, isPassingTests, c.config().Tide.Priority); ok {
+		if ok, pr := pickHighestPriorityPR(sp.log, c.provider, missings, sp.cc, isPassingTests, c.config().Tide.Priority); ok {
 			return Trigger, []PullRequest{pr}, c.trigger(sp, missingSerialTests[int(pr.Number)], []PullRequest{pr})
 		}
 	}
@@ -1346,7 +1228,7 @@ func (c *Controller) takeAction(sp subpool, batchPending, successes, pendings, m
 // changedFilesAgent queries and caches the names of files changed by PRs.
 // Cache entries expire if they are not used during a sync loop.
 type changedFilesAgent struct {
-	ghc         githubClient
+	provider    provider
 	changeCache map[changeCacheKey][]string
 	// nextChangeCache caches file change info that is relevant this sync for use next sync.
 	// This becomes the new changeCache when prune() is called at the end of each sync.
@@ -1361,7 +1243,7 @@ type changeCacheKey struct {
 }
 
 // prChanges gets the files changed by the PR, either from the cache or by
-// querying GitHub.
+// querying the provider.
 func (c *changedFilesAgent) prChanges(pr *PullRequest) config.ChangedFilesProvider {
 	return func() ([]string, error) {
 		cacheKey := changeCacheKey{
@@ -1386,8 +1268,8 @@ func (c *changedFilesAgent) prChanges(pr *PullRequest) config.ChangedFilesProvid
 		}
 		c.RUnlock()
 
-		// We need to query the changes from GitHub.
-		changes, err := c.ghc.GetPullRequestChanges(
+		// We need to query the changes from the provider.
+		changedFiles, err := c.provider.GetChangedFiles(
 			string(pr.Repository.Owner.Login),
 			string(pr.Repository.Name),
 			int(pr.Number),
@@ -1395,10 +1277,6 @@ func (c *changedFilesAgent) prChanges(pr *PullRequest) config.ChangedFilesProvid
 		if err != nil {
 			return nil, fmt.Errorf("error getting PR changes for #%d: %v", int(pr.Number), err)
 		}
-		changedFiles = make([]string, 0, len(changes))
-		for _, change := range changes {
-			changedFiles = append(changedFiles, change.Filename)
-		}
 
 		c.Lock()
 		c.nextChangeCache[cacheKey] = changedFiles
@@ -1452,7 +1330,7 @@ func (c *Controller) presubmitsByPull(sp *subpool) (map[int][]config.Presubmit,
 
 	for _, pr := range sp.prs {
 		log := c.logger.WithField("base-sha", sp.sha).WithFields(pr.logFields())
-		presubmitsForPull, err := c.config().GetPresubmits(c.gc, sp.org+"/"+sp.repo, refGetterFactory(sp.sha), refGetterFactory(string(pr.HeadRefOID)))
+		presubmitsForPull, err := c.provider.GetPresubmits(sp.org, sp.repo, refGetterFactory(sp.sha), refGetterFactory(string(pr.HeadRefOID)))
 		if err != nil {
 			c.logger.WithError(err).Debug("Failed to get presubmits for PR, excluding from subpool")
 			continue
@@ -1490,7 +1368,7 @@ func (c *Controller) presubmitsForBatch(prs []PullRequest, org, repo, baseSHA, b
 		headRefGetters = append(headRefGetters, refGetterFactory(string(pr.HeadRefOID)))
 	}
 
-	presubmits, err := c.config().GetPresubmits(c.gc, org+"/"+repo, refGetterFactory(baseSHA), headRefGetters...)
+	presubmits, err := c.provider.GetPresubmits(org, repo, refGetterFactory(baseSHA), headRefGetters...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get presubmits for batch: %v", err)
 	}
@@ -1646,7 +1524,7 @@ func (c *Controller) dividePool(pool map[string]PullRequest) (map[string]*subpoo
 		branchRef := string(pr.BaseRef.Prefix) + string(pr.BaseRef.Name)
 		fn := poolKey(org, repo, branch)
 		if sps[fn] == nil {
-			sha, err := c.ghc.GetRef(org, repo, strings.TrimPrefix(branchRef, "refs/"))
+			sha, err := c.provider.GetRef(org, repo, strings.TrimPrefix(branchRef, "refs/"))
 			if err != nil {
 				return nil, err
 			}
@@ -269,22 +269,25 @@ func TestAccumulateBatch(t *testing.T) {
 			if test.prowYAMLGetter != nil {
 				inrepoconfig.Enabled = map[string]*bool{"*": utilpointer.BoolPtr(true)}
 			}
-			c := &Controller{
-				config: func() *config.Config {
-					return &config.Config{
-						JobConfig: config.JobConfig{
-							PresubmitsStatic: map[string][]config.Presubmit{
-								"org/repo": test.presubmits,
-							},
-							ProwYAMLGetter: test.prowYAMLGetter,
+			cfg := func() *config.Config {
+				return &config.Config{
+					JobConfig: config.JobConfig{
+						PresubmitsStatic: map[string][]config.Presubmit{
+							"org/repo": test.presubmits,
 						},
-						ProwConfig: config.ProwConfig{
-							InRepoConfig: inrepoconfig,
-						},
-					}
-				},
+						ProwYAMLGetter: test.prowYAMLGetter,
+					},
+					ProwConfig: config.ProwConfig{
+						InRepoConfig: inrepoconfig,
+					},
+				}
+			}
+			log := logrus.WithField("test", test.name)
+			c := &Controller{
+				config:       cfg,
+				provider:     newGitHubProvider(log, &fgc{}, nil, cfg, nil, false),
 				changedFiles: &changedFilesAgent{},
-				logger:       logrus.WithField("test", test.name),
+				logger:       log,
 			}
 			merges, pending := c.accumulateBatch(subpool{org: "org", repo: "repo", prs: pulls, pjs: pjs, log: logrus.WithField("test", test.name)})
 			if (len(pending) > 0) != test.pending {
@@ -737,7 +740,7 @@ func TestDividePool(t *testing.T) {
 	mmc := newMergeChecker(configGetter, fc)
 	mgr := newFakeManager()
 	c, err := newSyncController(
-		context.Background(), log, fc, mgr, configGetter, nil, nil, nil, mmc, false,
+		context.Background(), log, newGitHubProvider(log, fc, nil, configGetter, mmc, false), mgr, configGetter, nil, nil, nil,
 	)
 	if err != nil {
 		t.Fatalf("failed to construct sync controller: %v", err)
@@ -940,10 +943,12 @@ func testPickBatch(clients localgit.Clients, t *testing.T) {
 			},
 		},
 	})
+	log := logrus.WithField("component", "tide")
 	c := &Controller{
-		logger: logrus.WithField("component", "tide"),
-		gc:     gc,
-		config: ca.Config,
+		logger:   log,
+		gc:       gc,
+		config:   ca.Config,
+		provider: newGitHubProvider(log, &fgc{}, gc, ca.Config, nil, false),
 	}
 	prs, presubmits, err := c.pickBatch(sp, map[int]contextChecker{
 		0: &config.TideContextPolicy{},
@@ -1659,23 +1664,23 @@ func testTakeAction(clients localgit.Clients, t *testing.T) {
 				return prs
 			}
 			fgc := fgc{mergeErrs: tc.mergeErrs}
+			log := logrus.WithField("controller", "tide")
+			provider := newGitHubProvider(log, &fgc, gc, ca.Config, nil, false)
 			c, err := newSyncController(
 				context.Background(),
-				logrus.WithField("controller", "tide"),
-				&fgc,
+				log,
+				provider,
 				newFakeManager(tc.preExistingJobs...),
 				ca.Config,
 				gc,
 				nil,
 				nil,
-				nil,
-				false,
 			)
 			if err != nil {
 				t.Fatalf("failed to construct sync controller: %v", err)
 			}
 			c.changedFiles = &changedFilesAgent{
-				ghc:             &fgc,
+				provider:        provider,
 				nextChangeCache: make(map[changeCacheKey][]string),
 			}
 			var batchPending []PullRequest
@@ -1754,8 +1759,8 @@ func TestServeHTTP(t *testing.T) {
 				Action:     Merge,
 			},
 		},
-		mergeChecker: newMergeChecker(cfg, &fgc{}),
-		History:      hist,
+		provider: newGitHubProvider(logrus.WithField("component", "tide"), &fgc{}, nil, cfg, newMergeChecker(cfg, &fgc{}), false),
+		History:  hist,
 	}
 	s := httptest.NewServer(c)
 	defer s.Close()
@@ -2027,19 +2032,19 @@ func TestSync(t *testing.T) {
 		}
 		go sc.run()
 		defer sc.shutdown()
+		provider := newGitHubProvider(logrus.WithField("controller", "sync"), fgc, nil, ca.Config, mergeChecker, false)
 		c := &Controller{
 			config:        ca.Config,
-			ghc:           fgc,
+			provider:      provider,
 			gc:            nil,
 			prowJobClient: fakectrlruntimeclient.NewFakeClient(),
 			logger:        logrus.WithField("controller", "sync"),
 			sc:            sc,
 			changedFiles: &changedFilesAgent{
-				ghc:             fgc,
+				provider:        provider,
 				nextChangeCache: make(map[changeCacheKey][]string),
 			},
-			mergeChecker: mergeChecker,
-			History:      hist,
+			History: hist,
 		}
 
 		if err := c.Sync(); err != nil {
@@ -2492,7 +2497,7 @@ func TestFilterSubpool(t *testing.T) {
 
 			configGetter := func() *config.Config { return &config.Config{} }
 			mmc := newMergeChecker(configGetter, &fgc{})
-			filtered := filterSubpool(nil, mmc.isAllowed, sp)
+			filtered := filterSubpool(&githubProvider{logger: sp.log}, mmc.isAllowed, sp)
 			if len(tc.expectedPRs) == 0 {
 				if filtered != nil {
 					t.Fatalf("Expected subpool to be pruned, but got: %v", filtered)
@@ -2619,7 +2624,7 @@ func TestIsPassing(t *testing.T) {
 			t.Fatalf("Failed to get log output before testing: %v", err)
 		}
 		pr := PullRequest{HeadRefOID: githubql.String(headSHA)}
-		passing := isPassingTests(log, ghc, pr, &tc.config)
+		passing := isPassingTests(log, &githubProvider{ghc: ghc, logger: log}, pr, &tc.config)
 		if passing != tc.passing {
 			t.Errorf("%s: Expected %t got %t", tc.name, tc.passing, passing)
 		}
@@ -2905,17 +2910,17 @@ func TestPresubmitsByPull(t *testing.T) {
 			sha:    "master-sha",
 			prs:    append(tc.prs, samplePR),
 		}
+		provider := newGitHubProvider(logrus.WithField("test", tc.name), &fgc{}, nil, cfgAgent.Config, newMergeChecker(cfgAgent.Config, &fgc{}), false)
 		c := &Controller{
-			config: cfgAgent.Config,
-			ghc:    &fgc{},
-			gc:     nil,
+			config:   cfgAgent.Config,
+			provider: provider,
+			gc:       nil,
 			changedFiles: &changedFilesAgent{
-				ghc:             &fgc{},
+				provider:        provider,
 				changeCache:     tc.initialChangeCache,
 				nextChangeCache: make(map[changeCacheKey][]string),
 			},
-			mergeChecker: newMergeChecker(cfgAgent.Config, &fgc{}),
-			logger:       logrus.WithField("test", tc.name),
+			logger: logrus.WithField("test", tc.name),
 		}
 		presubmits, err := c.presubmitsByPull(sp)
 		if err != nil {
@@ -3022,13 +3027,9 @@ func TestPrepareMergeDetails(t *testing.T) {
 		cfg := &config.Config{}
 		cfgAgent := &config.Agent{}
 		cfgAgent.Set(cfg)
-		c := &Controller{
-			config: cfgAgent.Config,
-			ghc:    &fgc{},
-			logger: logrus.WithField("component", "tide"),
-		}
+		provider := newGitHubProvider(logrus.WithField("component", "tide"), &fgc{}, nil, cfgAgent.Config, nil, false)
 
-		actual := c.prepareMergeDetails(test.tpl, test.pr, test.mergeMethod)
+		actual := provider.prepareMergeDetails(test.tpl, test.pr, test.mergeMethod)
 
 		if !reflect.DeepEqual(actual, test.expected) {
 			t.Errorf("Case %s failed: expected %+v, got %+v", test.name, test.expected, actual)
@@ -3458,22 +3459,25 @@ func TestPresubmitsForBatch(t *testing.T) {
 			if tc.prowYAMLGetter != nil {
 				inrepoconfig.Enabled = map[string]*bool{"*": utilpointer.BoolPtr(true)}
 			}
+			cfg := func() *config.Config {
+				return &config.Config{
+					JobConfig: config.JobConfig{
+						PresubmitsStatic: map[string][]config.Presubmit{
+							"org/repo": tc.jobs,
+						},
+						ProwYAMLGetter: tc.prowYAMLGetter,
+					},
+					ProwConfig: config.ProwConfig{
+						InRepoConfig: inrepoconfig,
+					},
+				}
+			}
+			log := logrus.WithField("test", tc.name)
 			c := &Controller{
 				changedFiles: tc.changedFiles,
-				config: func() *config.Config {
-					return &config.Config{
-						JobConfig: config.JobConfig{
-							PresubmitsStatic: map[string][]config.Presubmit{
-								"org/repo": tc.jobs,
-							},
-							ProwYAMLGetter: tc.prowYAMLGetter,
-						},
-						ProwConfig: config.ProwConfig{
-							InRepoConfig: inrepoconfig,
-						},
-					}
-				},
-				logger: logrus.WithField("test", tc.name),
+				config:       cfg,
+				provider:     newGitHubProvider(log, &fgc{}, nil, cfg, nil, false),
+				logger:       log,
 			}
 
 			presubmits, err := c.presubmitsForBatch(tc.prs, "org", "repo", "baseSHA", "master")
@@ -4022,7 +4026,7 @@ func TestPickSmallestPassingNumber(t *testing.T) {
 			expected: 9,
 		},
 	}
-	alwaysTrue := func(*logrus.Entry, githubClient, PullRequest, contextChecker) bool { return true }
+	alwaysTrue := func(*logrus.Entry, provider, PullRequest, contextChecker) bool { return true }
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			_, got := pickHighestPriorityPR(nil, nil, tc.prs, nil, alwaysTrue, priorities)
@@ -4064,23 +4068,20 @@ func TestQueryShardsByOrgWhenAppsAuthIsEnabledOnly(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			c := &Controller{
-				logger: logrus.WithField("test", tc.name),
-				config: func() *config.Config {
-					return &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{Queries: []config.TideQuery{{Orgs: []string{"org", "other-org"}}}}}}
-				},
-				ghc:                &fgc{prs: tc.prs},
-				usesGitHubAppsAuth: tc.usesGitHubAppsAuth,
+			cfg := func() *config.Config {
+				return &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{Queries: []config.TideQuery{{Orgs: []string{"org", "other-org"}}}}}}
 			}
+			ghc := &fgc{prs: tc.prs}
+			provider := newGitHubProvider(logrus.WithField("test", tc.name), ghc, nil, cfg, nil, tc.usesGitHubAppsAuth)
 
-			prs, err := c.query()
+			prs, err := provider.Query()
 			if err != nil {
 				t.Fatalf("query() failed: %v", err)
 			}
 			if n := len(prs); n != 2 {
 				t.Errorf("expected to get two prs back, got %d", n)
 			}
-			if diff := cmp.Diff(tc.expectedNumberOfApiCalls, c.ghc.(*fgc).queryCalls); diff != "" {
+			if diff := cmp.Diff(tc.expectedNumberOfApiCalls, ghc.queryCalls); diff != "" {
 				t.Errorf("expectedNumberOfApiCallsByOrg differs from actual: %s", diff)
 			}
 		})
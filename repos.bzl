@@ -113,6 +113,14 @@ def go_repositories():
         sum = "h1:3B/ZE1a6eEJ/4Jf/M6RM2KBouN8yKCUcMmXzSyWqa3g=",
         version = "v0.0.0-20190917191645-69366ca98f89",
     )
+    go_repository(
+        name = "com_github_bazelbuild_remote_apis",
+        build_file_generation = "on",
+        build_file_proto_mode = "disable",
+        importpath = "github.com/bazelbuild/remote-apis",
+        sum = "h1:cEFRynjrFOjUj9ZQj/ubiVbKPUcMG2kpMIbQkKGYlcI=",
+        version = "v0.0.0-20200708200203-1252343900d9",
+    )
     go_repository(
         name = "com_github_beorn7_perks",
         build_file_generation = "on",
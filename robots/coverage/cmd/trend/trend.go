@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/robots/coverage/trend"
+)
+
+type flags struct {
+	outputFile         string
+	profilePath        string
+	maxBuilds          int
+	gcsCredentialsFile string
+	s3CredentialsFile  string
+}
+
+// MakeCommand returns a `trend` command.
+func MakeCommand() *cobra.Command {
+	flags := &flags{}
+	cmd := &cobra.Command{
+		Use:   "trend [job-dir]",
+		Short: "Tracks the coverage of each package over the postsubmit runs of a job",
+		Long: `Reads the coverage profiles of the successful runs of a postsubmit job stored under job-dir,
+e.g. gs://bucket/logs/job, and writes the coverage of each package over time as a JSON index,
+flagging the runs where coverage dropped significantly.
+
+If the output already exists, it is updated: runs already in it aren't read again.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			run(flags, cmd, args)
+		},
+	}
+	cmd.Flags().StringVarP(&flags.outputFile, "output", "o", "-", "output file or storage path, e.g. gs://bucket/coverage-trend.json")
+	cmd.Flags().StringVarP(&flags.profilePath, "profile", "p", "artifacts/coverage-profile", "path of the coverage profile relative to each run's directory")
+	cmd.Flags().IntVar(&flags.maxBuilds, "max-builds", 100, "number of most recent runs to look at, or 0 for all of them")
+	cmd.Flags().StringVar(&flags.gcsCredentialsFile, "gcs-credentials-file", "", "file where GCS credentials are stored")
+	cmd.Flags().StringVar(&flags.s3CredentialsFile, "s3-credentials-file", "", "file where S3 credentials are stored")
+	return cmd
+}
+
+func run(flags *flags, cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Expected exactly one argument: job-dir")
+		cmd.Usage()
+		os.Exit(2)
+	}
+	jobDir := args[0]
+	if !strings.Contains(jobDir, "://") {
+		fmt.Fprintln(os.Stderr, "job-dir must be a storage path, e.g. gs://bucket/logs/job")
+		os.Exit(2)
+	}
+	if flags.maxBuilds < 0 {
+		fmt.Fprintln(os.Stderr, "--max-builds must not be negative")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	opener, err := io.NewOpener(ctx, flags.gcsCredentialsFile, flags.s3CredentialsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create opener: %v.\n", err)
+		os.Exit(1)
+	}
+
+	output := flags.outputFile
+	var previous *trend.Index
+	if output != "-" {
+		// The opener only treats absolute paths as local files.
+		if !strings.Contains(output, "://") {
+			if output, err = filepath.Abs(output); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to resolve output path: %v.\n", err)
+				os.Exit(1)
+			}
+		}
+		if previous, err = trend.ReadIndex(ctx, opener, output); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read existing index: %v.\n", err)
+			os.Exit(1)
+		}
+	}
+
+	index, err := trend.Collect(ctx, opener, jobDir, previous, trend.Options{
+		ProfilePath: flags.profilePath,
+		MaxBuilds:   flags.maxBuilds,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to collect coverage: %v.\n", err)
+		os.Exit(1)
+	}
+
+	if output == "-" {
+		err = json.NewEncoder(os.Stdout).Encode(index)
+	} else {
+		err = trend.WriteIndex(ctx, opener, output, index)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write index: %v.\n", err)
+		os.Exit(1)
+	}
+}
@@ -31,7 +31,9 @@ type coverageChange struct {
 	newRatio  float32
 }
 
-func isChangeSignificant(baseRatio, newRatio float32) bool {
+// IsChangeSignificant returns whether the difference between two coverage ratios is large enough to
+// show, given that coverage is displayed with one decimal place.
+func IsChangeSignificant(baseRatio, newRatio float32) bool {
 	diff := newRatio - baseRatio
 	if diff < 0 {
 		diff = -diff
@@ -62,7 +64,7 @@ func findChanges(baseList *calculation.CoverageList, newList *calculation.Covera
 			baseRatio = baseCov.Ratio()
 		}
 		newRatio := newCov.Ratio()
-		if isChangeSignificant(baseRatio, newRatio) {
+		if IsChangeSignificant(baseRatio, newRatio) {
 			changes = append(changes, &coverageChange{
 				name:      newCov.Name,
 				baseRatio: baseRatio,
@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/test-infra/robots/coverage/cmd/diff"
 	"k8s.io/test-infra/robots/coverage/cmd/downloader"
+	"k8s.io/test-infra/robots/coverage/cmd/trend"
 )
 
 var rootCommand = &cobra.Command{
@@ -32,6 +33,7 @@ var rootCommand = &cobra.Command{
 func run() error {
 	rootCommand.AddCommand(diff.MakeCommand())
 	rootCommand.AddCommand(downloader.MakeCommand())
+	rootCommand.AddCommand(trend.MakeCommand())
 
 	return rootCommand.Execute()
 }
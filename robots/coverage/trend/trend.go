@@ -0,0 +1,402 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trend tracks the coverage of each package over the postsubmit runs of a job, and flags
+// the runs where coverage dropped significantly.
+package trend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/tools/cover"
+
+	"k8s.io/test-infra/gopherage/pkg/cov/junit/calculation"
+	"k8s.io/test-infra/gopherage/pkg/util"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/robots/coverage/diff"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+// Build is a run of the job whose coverage was collected.
+type Build struct {
+	ID string `json:"id"`
+	// Started is when the build started, in epoch seconds.
+	Started int64 `json:"started"`
+	// Commit is the commit that was tested, if known.
+	Commit string `json:"commit,omitempty"`
+	// Coverage is the statement coverage of the whole profile.
+	Coverage float32 `json:"coverage"`
+}
+
+// Drop is a significant drop in the coverage of a package since the previous build covering it.
+type Drop struct {
+	// Package is empty for drops in the coverage of the whole profile.
+	Package string  `json:"package,omitempty"`
+	Build   string  `json:"build"`
+	Commit  string  `json:"commit,omitempty"`
+	Before  float32 `json:"before"`
+	After   float32 `json:"after"`
+}
+
+// Index is the coverage trend of a job.
+type Index struct {
+	// Job is the storage path of the job's builds, e.g. gs://bucket/logs/job.
+	Job string `json:"job"`
+	// Builds are sorted from oldest to newest.
+	Builds []Build `json:"builds"`
+	// Packages maps each package to its coverage in every build, aligned with Builds. The coverage
+	// is null for builds where the package had no files.
+	Packages map[string][]*float32 `json:"packages"`
+	// Drops are sorted by build, with the drop of the whole profile first.
+	Drops []Drop `json:"drops,omitempty"`
+	// Skipped are the IDs of the finished builds left out because they failed or have no valid
+	// coverage profile, in ascending order, so that they aren't read again.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Options configure how Collect reads the builds of a job.
+type Options struct {
+	// ProfilePath is the path of the coverage profile relative to the build directory.
+	ProfilePath string
+	// MaxBuilds is the number of most recent builds to look at, or 0 for all of them.
+	MaxBuilds int
+}
+
+// buildCoverage is the coverage of a single build.
+type buildCoverage struct {
+	Build
+	packages map[string]float32
+}
+
+// Collect returns the coverage trend of the job whose builds are stored under jobDir, e.g.
+// gs://bucket/logs/job. Builds found in previous, or skipped by it, aren't read again. Builds that
+// are still running, failed, or have no coverage profile are left out.
+func Collect(ctx context.Context, opener pkgio.Opener, jobDir string, previous *Index, opts Options) (*Index, error) {
+	jobDir = strings.TrimSuffix(jobDir, "/")
+	ids, err := listBuildIDs(ctx, opener, jobDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builds of %s: %v", jobDir, err)
+	}
+	if opts.MaxBuilds > 0 && len(ids) > opts.MaxBuilds {
+		ids = ids[len(ids)-opts.MaxBuilds:]
+	}
+
+	known := map[string]buildCoverage{}
+	skippedBefore := map[string]bool{}
+	if previous != nil && previous.Job == jobDir {
+		for i, b := range previous.Builds {
+			known[b.ID] = previous.buildCoverage(i)
+		}
+		for _, id := range previous.Skipped {
+			skippedBefore[id] = true
+		}
+	}
+
+	var builds []buildCoverage
+	var skipped []string
+	for _, id := range ids {
+		if b, ok := known[id]; ok {
+			builds = append(builds, b)
+			continue
+		}
+		if skippedBefore[id] {
+			skipped = append(skipped, id)
+			continue
+		}
+		b, finished, err := readBuild(ctx, opener, jobDir, id, opts.ProfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build %s: %v", id, err)
+		}
+		if b != nil {
+			builds = append(builds, *b)
+		} else if finished {
+			skipped = append(skipped, id)
+		}
+	}
+	idx := newIndex(jobDir, builds)
+	idx.Skipped = skipped
+	return idx, nil
+}
+
+// listBuildIDs returns the IDs of the builds under jobDir, in ascending order.
+func listBuildIDs(ctx context.Context, opener pkgio.Opener, jobDir string) ([]string, error) {
+	it, err := opener.Iterator(ctx, jobDir+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for {
+		attrs, err := it.Next(ctx)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if !attrs.IsDir {
+			continue
+		}
+		leaf := path.Base(attrs.Name)
+		id, err := strconv.ParseInt(leaf, 10, 64)
+		if err != nil {
+			logrus.WithField("job", jobDir).Warnf("unrecognized directory name (expected int64): %s", leaf)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, strconv.FormatInt(id, 10))
+	}
+	return result, nil
+}
+
+// readBuild returns the coverage of a build, or nil if the build didn't succeed or has no valid
+// profile. It also returns whether the build finished, since builds still running may have
+// coverage later.
+func readBuild(ctx context.Context, opener pkgio.Opener, jobDir, id, profilePath string) (*buildCoverage, bool, error) {
+	buildDir := jobDir + "/" + id
+	finished := gcs.Finished{}
+	if found, err := readJSON(ctx, opener, buildDir+"/"+prowv1.FinishedStatusFile, &finished); err != nil || !found {
+		return nil, false, err
+	}
+	if finished.Passed == nil || !*finished.Passed {
+		return nil, true, nil
+	}
+	started := gcs.Started{}
+	if _, err := readJSON(ctx, opener, buildDir+"/"+prowv1.StartedStatusFile, &started); err != nil {
+		return nil, true, err
+	}
+
+	content, err := readAll(ctx, opener, buildDir+"/"+profilePath)
+	if pkgio.IsNotExist(err) {
+		logrus.WithField("build", buildDir).Info("Build has no coverage profile.")
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	profiles, err := loadProfile(content)
+	if err != nil {
+		// Don't let a single broken profile stop the trend from being updated.
+		logrus.WithError(err).WithField("build", buildDir).Warn("Failed to parse coverage profile.")
+		return nil, true, nil
+	}
+
+	b := summarize(profiles)
+	b.ID = id
+	b.Started = started.Timestamp
+	b.Commit = started.RepoCommit
+	return &b, true, nil
+}
+
+// readJSON unmarshals the JSON file at path into data, and returns false if the file doesn't exist.
+func readJSON(ctx context.Context, opener pkgio.Opener, path string, data interface{}) (bool, error) {
+	content, err := readAll(ctx, opener, path)
+	if pkgio.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(content, data); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return true, nil
+}
+
+func readAll(ctx context.Context, opener pkgio.Opener, path string) ([]byte, error) {
+	r, err := opener.Reader(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// loadProfile parses a coverage file in any of the formats gopherage supports.
+func loadProfile(content []byte) ([]*cover.Profile, error) {
+	// Go profiles can only be parsed from a file.
+	f, err := ioutil.TempFile("", "coverage-trend")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %v", err)
+	}
+	return util.LoadProfile(f.Name())
+}
+
+// summarize returns the coverage of the profiles and of each package in them.
+func summarize(profiles []*cover.Profile) buildCoverage {
+	covList := calculation.ProduceCovList(profiles)
+	packages := map[string]*calculation.Coverage{}
+	for _, file := range covList.Group {
+		name := path.Dir(file.Name)
+		pkg, ok := packages[name]
+		if !ok {
+			pkg = &calculation.Coverage{Name: name}
+			packages[name] = pkg
+		}
+		pkg.NumCoveredStmts += file.NumCoveredStmts
+		pkg.NumAllStmts += file.NumAllStmts
+	}
+
+	b := buildCoverage{
+		Build:    Build{Coverage: round(covList.Ratio())},
+		packages: map[string]float32{},
+	}
+	for name, pkg := range packages {
+		b.packages[name] = round(pkg.Ratio())
+	}
+	return b
+}
+
+// round rounds a ratio to four decimal places, i.e. hundredths of a percent, to keep the index
+// compact.
+func round(ratio float32) float32 {
+	return float32(math.Round(float64(ratio)*1e4) / 1e4)
+}
+
+// buildCoverage returns the coverage of the i-th build of the index.
+func (idx *Index) buildCoverage(i int) buildCoverage {
+	b := buildCoverage{Build: idx.Builds[i], packages: map[string]float32{}}
+	for name, series := range idx.Packages {
+		if i < len(series) && series[i] != nil {
+			b.packages[name] = *series[i]
+		}
+	}
+	return b
+}
+
+// newIndex builds the index of builds, which must be sorted from oldest to newest.
+func newIndex(jobDir string, builds []buildCoverage) *Index {
+	idx := &Index{
+		Job:      jobDir,
+		Builds:   []Build{},
+		Packages: map[string][]*float32{},
+	}
+	for i, b := range builds {
+		idx.Builds = append(idx.Builds, b.Build)
+		for name, ratio := range b.packages {
+			series, ok := idx.Packages[name]
+			if !ok {
+				series = make([]*float32, len(builds))
+				idx.Packages[name] = series
+			}
+			ratio := ratio
+			series[i] = &ratio
+		}
+	}
+	idx.Drops = findDrops(idx)
+	return idx
+}
+
+// findDrops returns the builds where the coverage of the whole profile or of a package decreased
+// significantly since the previous build covering it.
+func findDrops(idx *Index) []Drop {
+	var drops []Drop
+	for i := 1; i < len(idx.Builds); i++ {
+		before, after := idx.Builds[i-1].Coverage, idx.Builds[i].Coverage
+		if after < before && diff.IsChangeSignificant(before, after) {
+			drops = append(drops, idx.drop("", i, before, after))
+		}
+	}
+
+	names := make([]string, 0, len(idx.Packages))
+	for name := range idx.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var last *float32
+		for i, ratio := range idx.Packages[name] {
+			if ratio == nil {
+				continue
+			}
+			if last != nil && *ratio < *last && diff.IsChangeSignificant(*last, *ratio) {
+				drops = append(drops, idx.drop(name, i, *last, *ratio))
+			}
+			last = ratio
+		}
+	}
+
+	// Keep the packages sorted within each build, with the whole profile first.
+	position := map[string]int{}
+	for i, b := range idx.Builds {
+		position[b.ID] = i
+	}
+	sort.SliceStable(drops, func(i, j int) bool {
+		return position[drops[i].Build] < position[drops[j].Build]
+	})
+	return drops
+}
+
+func (idx *Index) drop(pkg string, i int, before, after float32) Drop {
+	return Drop{
+		Package: pkg,
+		Build:   idx.Builds[i].ID,
+		Commit:  idx.Builds[i].Commit,
+		Before:  before,
+		After:   after,
+	}
+}
+
+// ReadIndex reads the index at path, and returns nil if it doesn't exist.
+func ReadIndex(ctx context.Context, opener pkgio.Opener, path string) (*Index, error) {
+	idx := &Index{}
+	found, err := readJSON(ctx, opener, path, idx)
+	if err != nil || !found {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// WriteIndex writes the index to path.
+func WriteIndex(ctx context.Context, opener pkgio.Opener, path string, idx *Index) error {
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	w, err := opener.Writer(ctx, path, pkgio.WriterOptions{ContentType: utilpointer.StringPtr("application/json")})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
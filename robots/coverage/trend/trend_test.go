@@ -0,0 +1,242 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	pkgio "k8s.io/test-infra/prow/io"
+)
+
+// fakeOpener serves files from memory and records which ones were read.
+type fakeOpener struct {
+	pkgio.Opener
+	files map[string]string
+	reads []string
+}
+
+func (fo *fakeOpener) Reader(_ context.Context, path string) (pkgio.ReadCloser, error) {
+	fo.reads = append(fo.reads, path)
+	content, ok := fo.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (fo *fakeOpener) Iterator(_ context.Context, prefix, delimiter string) (pkgio.ObjectIterator, error) {
+	seen := map[string]bool{}
+	var attrs []pkgio.ObjectAttributes
+	for path := range fo.files {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(path, prefix)
+		if i := strings.Index(rest, delimiter); i >= 0 {
+			dir := prefix + rest[:i+1]
+			if !seen[dir] {
+				seen[dir] = true
+				attrs = append(attrs, pkgio.ObjectAttributes{Name: dir, IsDir: true})
+			}
+			continue
+		}
+		attrs = append(attrs, pkgio.ObjectAttributes{Name: path, ObjName: rest})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+type fakeIterator struct {
+	attrs []pkgio.ObjectAttributes
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (pkgio.ObjectAttributes, error) {
+	if len(fi.attrs) == 0 {
+		return pkgio.ObjectAttributes{}, io.EOF
+	}
+	next := fi.attrs[0]
+	fi.attrs = fi.attrs[1:]
+	return next, nil
+}
+
+type fakeWriter struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (fw *fakeWriter) Close() error {
+	fw.closed = true
+	return nil
+}
+
+type fakeWriterOpener struct {
+	pkgio.Opener
+	writers map[string]*fakeWriter
+}
+
+func (fo *fakeWriterOpener) Writer(_ context.Context, path string, _ ...pkgio.WriterOptions) (pkgio.WriteCloser, error) {
+	fo.writers[path] = &fakeWriter{}
+	return fo.writers[path], nil
+}
+
+const jobDir = "gs://bucket/logs/post-repo-coverage"
+
+func addBuild(files map[string]string, id string, passed bool, commit, profile string) {
+	dir := jobDir + "/" + id + "/"
+	files[dir+"started.json"] = `{"timestamp": 100` + id + `, "repo-commit": "` + commit + `"}`
+	if passed {
+		files[dir+"finished.json"] = `{"timestamp": 200, "passed": true}`
+	} else {
+		files[dir+"finished.json"] = `{"timestamp": 200, "passed": false}`
+	}
+	if profile != "" {
+		files[dir+"artifacts/coverage-profile"] = "mode: set\n" + profile
+	}
+}
+
+func testFiles() map[string]string {
+	files := map[string]string{
+		jobDir + "/latest-build.txt":  "10",
+		jobDir + "/junk/started.json": "{}",
+	}
+	addBuild(files, "1", true, "aaa", "k8s.io/repo/pkg/a/a.go:1.1,2.2 2 1\nk8s.io/repo/pkg/b/b.go:1.1,2.2 1 1\nk8s.io/repo/pkg/b/b.go:3.1,4.2 1 0\n")
+	addBuild(files, "2", false, "bbb", "k8s.io/repo/pkg/a/a.go:1.1,2.2 2 0\n")
+	addBuild(files, "3", true, "ccc", "k8s.io/repo/pkg/a/a.go:1.1,2.2 1 1\nk8s.io/repo/pkg/a/a.go:3.1,4.2 1 0\nk8s.io/repo/pkg/b/b.go:1.1,2.2 1 1\nk8s.io/repo/pkg/b/b.go:3.1,4.2 1 0\n")
+	addBuild(files, "4", true, "ddd", "")
+	addBuild(files, "10", true, "eee", "k8s.io/repo/pkg/a/a.go:1.1,2.2 1 1\nk8s.io/repo/pkg/a/a.go:3.1,4.2 1 0\nk8s.io/repo/pkg/b/b.go:1.1,4.2 2 1\nk8s.io/repo/pkg/c/c.go:1.1,2.2 1 0\n")
+	// Not finished yet.
+	files[jobDir+"/11/started.json"] = `{"timestamp": 10011}`
+	return files
+}
+
+func ratio(r float32) *float32 {
+	return &r
+}
+
+func TestCollect(t *testing.T) {
+	opener := &fakeOpener{files: testFiles()}
+	idx, err := Collect(context.Background(), opener, jobDir+"/", nil, Options{ProfilePath: "artifacts/coverage-profile"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	expected := &Index{
+		Job: jobDir,
+		Builds: []Build{
+			{ID: "1", Started: 1001, Commit: "aaa", Coverage: 0.75},
+			{ID: "3", Started: 1003, Commit: "ccc", Coverage: 0.5},
+			{ID: "10", Started: 10010, Commit: "eee", Coverage: 0.6},
+		},
+		Packages: map[string][]*float32{
+			"k8s.io/repo/pkg/a": {ratio(1), ratio(0.5), ratio(0.5)},
+			"k8s.io/repo/pkg/b": {ratio(0.5), ratio(0.5), ratio(1)},
+			"k8s.io/repo/pkg/c": {nil, nil, ratio(0)},
+		},
+		Drops: []Drop{
+			{Build: "3", Commit: "ccc", Before: 0.75, After: 0.5},
+			{Package: "k8s.io/repo/pkg/a", Build: "3", Commit: "ccc", Before: 1, After: 0.5},
+		},
+		Skipped: []string{"2", "4"},
+	}
+	if !reflect.DeepEqual(idx, expected) {
+		t.Errorf("expected %+v, got %+v", expected, idx)
+	}
+
+	// A second run only reads the builds that weren't collected or skipped.
+	opener.reads = nil
+	again, err := Collect(context.Background(), opener, jobDir, idx, Options{ProfilePath: "artifacts/coverage-profile"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if !reflect.DeepEqual(again, expected) {
+		t.Errorf("expected %+v on the second run, got %+v", expected, again)
+	}
+	for _, read := range opener.reads {
+		for _, id := range []string{"/1/", "/2/", "/3/", "/4/", "/10/"} {
+			if strings.Contains(read, id) {
+				t.Errorf("expected build %s not to be read again, but read %s", strings.Trim(id, "/"), read)
+			}
+		}
+	}
+}
+
+func TestCollectMaxBuilds(t *testing.T) {
+	opener := &fakeOpener{files: testFiles()}
+	idx, err := Collect(context.Background(), opener, jobDir, nil, Options{ProfilePath: "artifacts/coverage-profile", MaxBuilds: 3})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	var ids []string
+	for _, b := range idx.Builds {
+		ids = append(ids, b.ID)
+	}
+	if expected := []string{"10"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected builds %v, got %v", expected, ids)
+	}
+	if len(idx.Drops) != 0 {
+		t.Errorf("expected no drops with a single build, got %+v", idx.Drops)
+	}
+}
+
+func TestCollectSkipsBrokenProfiles(t *testing.T) {
+	files := testFiles()
+	files[jobDir+"/3/artifacts/coverage-profile"] = "mode: set\nnot a profile\n"
+	idx, err := Collect(context.Background(), &fakeOpener{files: files}, jobDir, nil, Options{ProfilePath: "artifacts/coverage-profile"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(idx.Builds) != 2 {
+		t.Errorf("expected the build with a broken profile to be skipped, got %+v", idx.Builds)
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	idx, err := Collect(context.Background(), &fakeOpener{files: testFiles()}, jobDir, nil, Options{ProfilePath: "artifacts/coverage-profile"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	writer := &fakeWriterOpener{writers: map[string]*fakeWriter{}}
+	if err := WriteIndex(context.Background(), writer, "gs://bucket/trend.json", idx); err != nil {
+		t.Fatalf("WriteIndex failed: %v", err)
+	}
+	written := writer.writers["gs://bucket/trend.json"]
+	if !written.closed {
+		t.Error("expected the index writer to be closed")
+	}
+
+	reader := &fakeOpener{files: map[string]string{"gs://bucket/trend.json": written.String()}}
+	read, err := ReadIndex(context.Background(), reader, "gs://bucket/trend.json")
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %v", err)
+	}
+	if !reflect.DeepEqual(read, idx) {
+		t.Errorf("expected %+v, got %+v", idx, read)
+	}
+
+	missing, err := ReadIndex(context.Background(), reader, "gs://bucket/missing.json")
+	if err != nil || missing != nil {
+		t.Errorf("expected a missing index to be nil, got %+v, %v", missing, err)
+	}
+}
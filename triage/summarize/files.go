@@ -247,7 +247,22 @@ func loadBuilds(filepath string, memoize bool) (map[string]build, error) {
 		return nil, fmt.Errorf("Could not get builds JSON: %s", err)
 	}
 
-	// Convert the build information to internal build objects and store them in the builds map
+	builds, err = convertBuilds(jsonBuilds)
+	if err != nil {
+		return nil, err
+	}
+
+	if memoize {
+		memoizeResults(memoPath, memoMessage, builds)
+	}
+
+	return builds, nil
+}
+
+// convertBuilds converts builds as reported by the JSON to build objects, and returns a map from
+// build paths to build objects.
+func convertBuilds(jsonBuilds []jsonBuild) (map[string]build, error) {
+	builds := make(map[string]build)
 	for _, jBuild := range jsonBuilds {
 		// Skip builds without a start time or build number
 		if jBuild.Started == "" || jBuild.Number == "" {
@@ -266,11 +281,6 @@ func loadBuilds(filepath string, memoize bool) (map[string]build, error) {
 
 		builds[bld.Path] = bld
 	}
-
-	if memoize {
-		memoizeResults(memoPath, memoMessage, builds)
-	}
-
 	return builds, nil
 }
 
@@ -346,32 +356,38 @@ func loadTests(testsFilepaths []string, memoize bool) (map[string][]failure, err
 			}
 			jsonFailures = append(jsonFailures, jf)
 		}
+	}
 
-		// Convert the failure information to internal failure objects and store them in tests
-		for _, jf := range jsonFailures {
-			// Check if tests of this type are already in the map
-			if _, ok := tests[jf.Name]; !ok {
-				tests[jf.Name] = make([]failure, 0)
-			}
+	// Convert the failures of all files at once, so that each is only converted once
+	tests, err := convertFailures(jsonFailures)
+	if err != nil {
+		return nil, err
+	}
 
-			test, err := jf.asFailure()
-			if err != nil {
-				return nil, fmt.Errorf("Could not create failure object from jsonFailure object: %s", err)
-			}
+	if memoize {
+		memoizeResults(memoPath, memoMessage, tests)
+	}
+
+	return tests, nil
+}
 
-			tests[jf.Name] = append(tests[jf.Name], test)
+// convertFailures converts test failures as reported by the JSON to failure objects. It returns a
+// map from test names to failure objects, sorted by build.
+func convertFailures(jsonFailures []jsonFailure) (map[string][]failure, error) {
+	tests := make(map[string][]failure)
+	for _, jf := range jsonFailures {
+		test, err := jf.asFailure()
+		if err != nil {
+			return nil, fmt.Errorf("Could not create failure object from jsonFailure object: %s", err)
 		}
+
+		tests[jf.Name] = append(tests[jf.Name], test)
 	}
 
 	// Sort the failures within each test by build
 	for _, testSlice := range tests {
 		sort.Slice(testSlice, func(i, j int) bool { return testSlice[i].Build < testSlice[j].Build })
 	}
-
-	if memoize {
-		memoizeResults(memoPath, memoMessage, tests)
-	}
-
 	return tests, nil
 }
 
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package summarize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadTestsFromSeveralFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triage")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"tests1.json": `{"started": "100", "build": "gs://logs/job/2", "name": "job test-a", "failure_text": "boom"}
+{"started": "90", "build": "gs://logs/job/1", "name": "job test-a", "failure_text": "bang"}
+`,
+		"tests2.json": `{"started": "110", "build": "gs://logs/job/3", "name": "job test-b", "failure_text": "oops"}
+`,
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	tests, err := loadTests(paths, false)
+	if err != nil {
+		t.Fatalf("loadTests failed: %v", err)
+	}
+	expected := map[string][]failure{
+		"job test-a": {
+			{Started: 90, Build: "gs://logs/job/1", Name: "job test-a", FailureText: "bang"},
+			{Started: 100, Build: "gs://logs/job/2", Name: "job test-a", FailureText: "boom"},
+		},
+		"job test-b": {
+			{Started: 110, Build: "gs://logs/job/3", Name: "job test-b", FailureText: "oops"},
+		},
+	}
+	if !reflect.DeepEqual(tests, expected) {
+		t.Errorf("Expected each failure once, sorted by build: %+v, got %+v", expected, tests)
+	}
+}
@@ -0,0 +1,408 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Contains functions that ingest builds and test failures straight from the job artifacts in a bucket,
+for Prow installations that don't export them to BigQuery with kettle. The results are the same
+jsonBuild and jsonFailure objects that would be read from the BigQuery export.
+*/
+
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/testgrid/metadata/junit"
+	"k8s.io/klog/v2"
+
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+)
+
+// junitRegex matches the names of the junit files in a build's artifacts, as kettle does.
+var junitRegex = regexp.MustCompile(`^junit.*\.xml$`)
+
+// ingestOptions configure which builds are ingested from job artifacts.
+type ingestOptions struct {
+	// prefixes are storage paths that job directories start with, e.g. gs://bucket/logs/ for all
+	// the jobs in a bucket, or gs://bucket/logs/ci-kubernetes- for some of them.
+	prefixes []string
+	// window is how far back builds are ingested, based on when they started.
+	window time.Duration
+	// statePath is the path of the local file that records the builds already ingested.
+	statePath string
+	// numWorkers is the number of jobs ingested at the same time.
+	numWorkers int
+	// now returns the current time.
+	now func() time.Time
+}
+
+// ingestedBuild is a build ingested from job artifacts, along with its test failures.
+type ingestedBuild struct {
+	Build    jsonBuild     `json:"build"`
+	Failures []jsonFailure `json:"failures,omitempty"`
+}
+
+// ingestState records the finished builds that were already ingested, so that their artifacts
+// aren't read again.
+type ingestState struct {
+	// Builds maps build paths to the ingested builds.
+	Builds map[string]ingestedBuild `json:"builds"`
+}
+
+// loadIngestState loads the state file, or returns an empty state if it doesn't exist yet.
+func loadIngestState(filepath string) (*ingestState, error) {
+	state := &ingestState{}
+	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+		state.Builds = make(map[string]ingestedBuild)
+		return state, nil
+	}
+	if err := getJSON(filepath, state); err != nil {
+		return nil, fmt.Errorf("Could not get ingestion state JSON: %s", err)
+	}
+	if state.Builds == nil {
+		state.Builds = make(map[string]ingestedBuild)
+	}
+	return state, nil
+}
+
+// loadFailuresFromArtifacts ingests the builds in the window from job artifacts, updates the state
+// file, and returns the same maps as loadFailures.
+func loadFailuresFromArtifacts(ctx context.Context, opener pkgio.Opener, opts ingestOptions) (map[string]build, map[string][]failure, error) {
+	state, err := loadIngestState(opts.statePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ingestArtifacts(ctx, opener, opts, state); err != nil {
+		return nil, nil, fmt.Errorf("Could not ingest artifacts: %s", err)
+	}
+
+	if err := writeJSON(opts.statePath, state); err != nil {
+		klog.Warningf("Could not save ingestion state, all builds will be read again next time: %s", err)
+	}
+
+	jsonBuilds := make([]jsonBuild, 0, len(state.Builds))
+	jsonFailures := make([]jsonFailure, 0)
+	for _, ingested := range state.Builds {
+		jsonBuilds = append(jsonBuilds, ingested.Build)
+		jsonFailures = append(jsonFailures, ingested.Failures...)
+	}
+
+	builds, err := convertBuilds(jsonBuilds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not retrieve builds: %s", err)
+	}
+	tests, err := convertFailures(jsonFailures)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not retrieve tests: %s", err)
+	}
+	return builds, tests, nil
+}
+
+// ingestArtifacts adds the finished builds in the window that aren't in state yet, and drops the
+// builds that fell out of the window.
+func ingestArtifacts(ctx context.Context, opener pkgio.Opener, opts ingestOptions, state *ingestState) error {
+	cutoff := opts.now().Add(-opts.window).Unix()
+
+	var jobDirs []string
+	for _, prefix := range opts.prefixes {
+		dirs, err := listDirs(ctx, opener, prefix)
+		if err != nil {
+			return fmt.Errorf("Could not list jobs under '%s': %s", prefix, err)
+		}
+		jobDirs = append(jobDirs, dirs...)
+	}
+	klog.V(2).Infof("Ingesting builds of %d jobs...", len(jobDirs))
+
+	// The workers only read this copy of the state, and the state is updated once they are done.
+	known := make(map[string]bool, len(state.Builds))
+	for p, b := range state.Builds {
+		if started, err := strconv.ParseInt(b.Build.Started, 10, 64); err != nil || started < cutoff {
+			delete(state.Builds, p)
+			continue
+		}
+		known[p] = true
+	}
+
+	numWorkers := opts.numWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	jobs := make(chan string)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var ingested []ingestedBuild
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for jobDir := range jobs {
+				builds, err := ingestJob(ctx, opener, jobDir, cutoff, known)
+				if err != nil {
+					klog.Warningf("Could not list builds of '%s': %s", jobDir, err)
+					continue
+				}
+				lock.Lock()
+				ingested = append(ingested, builds...)
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, jobDir := range jobDirs {
+		jobs <- jobDir
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, b := range ingested {
+		state.Builds[b.Build.Path] = b
+	}
+	klog.V(2).Infof("Ingested %d new builds, %d builds in the window", len(ingested), len(state.Builds))
+	return nil
+}
+
+// ingestJob returns the finished builds of the job that started after cutoff and aren't known yet.
+// known holds the paths of the builds already ingested. Builds are read from
+// newest to oldest, and reading stops at the first build that started before cutoff, since build
+// numbers grow over time.
+func ingestJob(ctx context.Context, opener pkgio.Opener, jobDir string, cutoff int64, known map[string]bool) ([]ingestedBuild, error) {
+	dirs, err := listDirs(ctx, opener, jobDir+"/")
+	if err != nil {
+		return nil, err
+	}
+	type buildDir struct {
+		dir    string
+		number int64
+	}
+	var buildDirs []buildDir
+	for _, dir := range dirs {
+		number, err := strconv.ParseInt(path.Base(dir), 10, 64)
+		if err != nil {
+			continue
+		}
+		buildDirs = append(buildDirs, buildDir{dir: dir, number: number})
+	}
+	sort.Slice(buildDirs, func(i, j int) bool { return buildDirs[i].number > buildDirs[j].number })
+
+	var ingested []ingestedBuild
+	for _, bd := range buildDirs {
+		if known[bd.dir] {
+			continue
+		}
+		b, started, err := ingestBuild(ctx, opener, bd.dir)
+		if err != nil {
+			// Try again next time.
+			klog.Warningf("Could not ingest build '%s': %s", bd.dir, err)
+			continue
+		}
+		if started != 0 && started < cutoff {
+			break
+		}
+		if b != nil {
+			ingested = append(ingested, *b)
+		}
+	}
+	return ingested, nil
+}
+
+// ingestBuild reads the build in buildDir. It returns nil if the build hasn't finished yet, along
+// with when the build started, if known.
+func ingestBuild(ctx context.Context, opener pkgio.Opener, buildDir string) (*ingestedBuild, int64, error) {
+	var started gcs.Started
+	if found, err := readArtifactJSON(ctx, opener, buildDir+"/"+prowv1.StartedStatusFile, &started); err != nil || !found {
+		return nil, 0, err
+	}
+	var finished gcs.Finished
+	found, err := readArtifactJSON(ctx, opener, buildDir+"/"+prowv1.FinishedStatusFile, &finished)
+	if err != nil || !found || finished.Timestamp == nil {
+		return nil, started.Timestamp, err
+	}
+
+	result := finished.Result
+	if finished.Passed != nil {
+		if *finished.Passed {
+			result = "SUCCESS"
+		} else {
+			result = "FAILURE"
+		}
+	}
+	b := ingestedBuild{
+		Build: jsonBuild{
+			Path:     buildDir,
+			Started:  strconv.FormatInt(started.Timestamp, 10),
+			Elapsed:  strconv.FormatInt(*finished.Timestamp-started.Timestamp, 10),
+			Result:   result,
+			Executor: started.Node,
+			Job:      path.Base(path.Dir(buildDir)),
+			Number:   path.Base(buildDir),
+			PR:       started.Pull,
+		},
+	}
+
+	testsRun, testsFailed := 0, 0
+	artifacts, err := listFiles(ctx, opener, buildDir+"/artifacts/")
+	if err != nil {
+		return nil, started.Timestamp, fmt.Errorf("Could not list artifacts: %s", err)
+	}
+	for _, artifact := range artifacts {
+		if !junitRegex.MatchString(path.Base(artifact)) {
+			continue
+		}
+		content, err := readArtifact(ctx, opener, artifact)
+		if err != nil {
+			return nil, started.Timestamp, err
+		}
+		suites, err := junit.Parse(content)
+		if err != nil {
+			klog.Warningf("Could not parse junit file '%s': %s", artifact, err)
+			continue
+		}
+		for _, suite := range suites.Suites {
+			run, failures := suiteFailures(suite)
+			testsRun += run
+			for _, f := range failures {
+				f.Started = b.Build.Started
+				f.Build = buildDir
+				b.Failures = append(b.Failures, f)
+			}
+			testsFailed += len(failures)
+		}
+	}
+	b.Build.TestsRun = strconv.Itoa(testsRun)
+	b.Build.TestsFailed = strconv.Itoa(testsFailed)
+	return &b, started.Timestamp, nil
+}
+
+// suiteFailures returns the number of tests that ran in the suite and its nested suites, and their
+// failures, without the build information.
+func suiteFailures(suite junit.Suite) (int, []jsonFailure) {
+	run := 0
+	var failures []jsonFailure
+	for _, child := range suite.Suites {
+		childRun, childFailures := suiteFailures(child)
+		run += childRun
+		failures = append(failures, childFailures...)
+	}
+	for _, result := range suite.Results {
+		if result.Skipped != nil {
+			continue
+		}
+		run++
+		if result.Failure != nil {
+			failures = append(failures, jsonFailure{Name: result.Name, FailureText: *result.Failure})
+		}
+	}
+	return run, failures
+}
+
+// listDirs returns the storage paths of the directories whose path starts with prefix, without a
+// trailing slash.
+func listDirs(ctx context.Context, opener pkgio.Opener, prefix string) ([]string, error) {
+	attrs, err := list(ctx, opener, prefix, "/")
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, attr := range attrs {
+		if attr.IsDir {
+			dirs = append(dirs, storagePath(prefix, strings.TrimSuffix(attr.Name, "/")))
+		}
+	}
+	return dirs, nil
+}
+
+// listFiles returns the storage paths of all the files under prefix.
+func listFiles(ctx context.Context, opener pkgio.Opener, prefix string) ([]string, error) {
+	attrs, err := list(ctx, opener, prefix, "")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, attr := range attrs {
+		if !attr.IsDir {
+			files = append(files, storagePath(prefix, attr.Name))
+		}
+	}
+	return files, nil
+}
+
+func list(ctx context.Context, opener pkgio.Opener, prefix, delimiter string) ([]pkgio.ObjectAttributes, error) {
+	it, err := opener.Iterator(ctx, prefix, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	var attrs []pkgio.ObjectAttributes
+	for {
+		attr, err := it.Next(ctx)
+		if err == io.EOF {
+			return attrs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+}
+
+// storagePath turns the name of an object, relative to its bucket, into a storage path in the same
+// bucket as prefix.
+func storagePath(prefix, name string) string {
+	i := strings.Index(prefix, "://")
+	bucket := prefix[i+len("://"):]
+	if j := strings.Index(bucket, "/"); j >= 0 {
+		bucket = bucket[:j]
+	}
+	return prefix[:i+len("://")] + bucket + "/" + name
+}
+
+// readArtifactJSON unmarshals the JSON artifact at p into v. It returns false if the artifact
+// doesn't exist.
+func readArtifactJSON(ctx context.Context, opener pkgio.Opener, p string, v interface{}) (bool, error) {
+	content, err := readArtifact(ctx, opener, p)
+	if pkgio.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return false, fmt.Errorf("Could not unmarshal '%s': %s", p, err)
+	}
+	return true, nil
+}
+
+func readArtifact(ctx context.Context, opener pkgio.Opener, p string) ([]byte, error) {
+	r, err := opener.Reader(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package summarize
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pkgio "k8s.io/test-infra/prow/io"
+)
+
+const testBucket = "gs://bucket/"
+
+// fakeOpener serves the files of a single bucket from memory, keyed by storage path.
+type fakeOpener struct {
+	pkgio.Opener
+	files map[string]string
+
+	lock  sync.Mutex
+	reads []string
+}
+
+func (fo *fakeOpener) Reader(_ context.Context, path string) (pkgio.ReadCloser, error) {
+	fo.lock.Lock()
+	fo.reads = append(fo.reads, path)
+	fo.lock.Unlock()
+	content, ok := fo.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (fo *fakeOpener) Iterator(_ context.Context, prefix, delimiter string) (pkgio.ObjectIterator, error) {
+	seen := map[string]bool{}
+	var attrs []pkgio.ObjectAttributes
+	for path := range fo.files {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		// Names are relative to the bucket.
+		name := strings.TrimPrefix(path, testBucket)
+		rest := strings.TrimPrefix(path, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			dir := strings.TrimPrefix(prefix, testBucket) + rest[:i+1]
+			if !seen[dir] {
+				seen[dir] = true
+				attrs = append(attrs, pkgio.ObjectAttributes{Name: dir, IsDir: true})
+			}
+			continue
+		}
+		attrs = append(attrs, pkgio.ObjectAttributes{Name: name})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+func (fo *fakeOpener) readsOf(dir string) []string {
+	var reads []string
+	for _, read := range fo.reads {
+		if strings.HasPrefix(read, dir+"/") {
+			reads = append(reads, read)
+		}
+	}
+	return reads
+}
+
+type fakeIterator struct {
+	attrs []pkgio.ObjectAttributes
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (pkgio.ObjectAttributes, error) {
+	if len(fi.attrs) == 0 {
+		return pkgio.ObjectAttributes{}, io.EOF
+	}
+	next := fi.attrs[0]
+	fi.attrs = fi.attrs[1:]
+	return next, nil
+}
+
+const testJunit = `<testsuites>
+  <testsuite name="e2e">
+    <testcase name="passes"></testcase>
+    <testcase name="is skipped"><skipped/></testcase>
+    <testcase name="fails"><failure>timed out</failure></testcase>
+    <testsuite name="nested">
+      <testcase name="also fails"><failure>connection refused</failure></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+
+func testArtifacts() map[string]string {
+	return map[string]string{
+		// Too old, so older builds aren't read.
+		testBucket + "logs/ci-a/1/started.json":  `{"timestamp": 998000}`,
+		testBucket + "logs/ci-a/1/finished.json": `{"timestamp": 998100, "passed": false}`,
+		testBucket + "logs/ci-a/0/started.json":  `{"timestamp": 999900}`,
+
+		testBucket + "logs/ci-a/2/started.json":               `{"timestamp": 999500, "node": "node-1"}`,
+		testBucket + "logs/ci-a/2/finished.json":              `{"timestamp": 999560, "passed": true}`,
+		testBucket + "logs/ci-a/2/artifacts/junit_runner.xml": `<testsuite><testcase name="passes"/><testcase name="passes too"/></testsuite>`,
+		testBucket + "logs/ci-a/3/started.json":               `{"timestamp": 999600}`,
+		testBucket + "logs/ci-a/3/finished.json":              `{"timestamp": 999700, "passed": false}`,
+		testBucket + "logs/ci-a/3/artifacts/e2e/junit_01.xml": testJunit,
+		testBucket + "logs/ci-a/3/artifacts/e2e/results.xml":  testJunit,
+		testBucket + "logs/ci-a/3/artifacts/junit_broken.xml": `<testsuite`,
+		testBucket + "logs/ci-a/latest-build.txt":             "4",
+		// Still running.
+		testBucket + "logs/ci-a/4/started.json": `{"timestamp": 999800}`,
+
+		testBucket + "logs/ci-b/7/started.json":  `{"timestamp": 999650, "pull": "123"}`,
+		testBucket + "logs/ci-b/7/finished.json": `{"timestamp": 999660, "result": "ABORTED"}`,
+
+		// Doesn't match the prefix.
+		testBucket + "logs/post-c/1/started.json":  `{"timestamp": 999650}`,
+		testBucket + "logs/post-c/1/finished.json": `{"timestamp": 999660, "passed": true}`,
+	}
+}
+
+func TestIngestArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triage")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	opener := &fakeOpener{files: testArtifacts()}
+	opts := ingestOptions{
+		prefixes:   []string{testBucket + "logs/ci-"},
+		window:     1000 * time.Second,
+		statePath:  filepath.Join(dir, "state.json"),
+		numWorkers: 2,
+		now:        func() time.Time { return time.Unix(1000000, 0) },
+	}
+
+	builds, tests, err := loadFailuresFromArtifacts(context.Background(), opener, opts)
+	if err != nil {
+		t.Fatalf("loadFailuresFromArtifacts failed: %s", err)
+	}
+
+	expectedBuilds := map[string]build{
+		testBucket + "logs/ci-a/2": {Path: testBucket + "logs/ci-a/2", Started: 999500, Elapsed: 60, TestsRun: 2, Result: "SUCCESS", Executor: "node-1", Job: "ci-a", Number: 2},
+		testBucket + "logs/ci-a/3": {Path: testBucket + "logs/ci-a/3", Started: 999600, Elapsed: 100, TestsRun: 3, TestsFailed: 2, Result: "FAILURE", Job: "ci-a", Number: 3},
+		testBucket + "logs/ci-b/7": {Path: testBucket + "logs/ci-b/7", Started: 999650, Elapsed: 10, Result: "ABORTED", Job: "ci-b", Number: 7, PR: "123"},
+	}
+	if !reflect.DeepEqual(builds, expectedBuilds) {
+		t.Errorf("Expected builds %#v, got %#v", expectedBuilds, builds)
+	}
+	expectedTests := map[string][]failure{
+		"fails":      {{Started: 999600, Build: testBucket + "logs/ci-a/3", Name: "fails", FailureText: "timed out"}},
+		"also fails": {{Started: 999600, Build: testBucket + "logs/ci-a/3", Name: "also fails", FailureText: "connection refused"}},
+	}
+	if !reflect.DeepEqual(tests, expectedTests) {
+		t.Errorf("Expected tests %#v, got %#v", expectedTests, tests)
+	}
+	if reads := opener.readsOf(testBucket + "logs/ci-a/0"); len(reads) != 0 {
+		t.Errorf("Expected builds before the first one out of the window not to be read, read %v", reads)
+	}
+
+	// Finished builds are only read once.
+	opener.reads = nil
+	again, _, err := loadFailuresFromArtifacts(context.Background(), opener, opts)
+	if err != nil {
+		t.Fatalf("loadFailuresFromArtifacts failed: %s", err)
+	}
+	if !reflect.DeepEqual(again, expectedBuilds) {
+		t.Errorf("Expected builds %#v on the second run, got %#v", expectedBuilds, again)
+	}
+	for _, b := range []string{"logs/ci-a/2", "logs/ci-a/3", "logs/ci-b/7"} {
+		if reads := opener.readsOf(testBucket + b); len(reads) != 0 {
+			t.Errorf("Expected %s not to be read again, read %v", b, reads)
+		}
+	}
+	if reads := opener.readsOf(testBucket + "logs/ci-a/4"); len(reads) == 0 {
+		t.Error("Expected the running build to be read again")
+	}
+
+	// Builds that fall out of the window are forgotten.
+	opts.now = func() time.Time { return time.Unix(1000550, 0) }
+	later, _, err := loadFailuresFromArtifacts(context.Background(), opener, opts)
+	if err != nil {
+		t.Fatalf("loadFailuresFromArtifacts failed: %s", err)
+	}
+	var paths []string
+	for p := range later {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if expected := []string{testBucket + "logs/ci-a/3", testBucket + "logs/ci-b/7"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected builds %v once the window moved, got %v", expected, paths)
+	}
+	state, err := loadIngestState(opts.statePath)
+	if err != nil {
+		t.Fatalf("Could not load state: %s", err)
+	}
+	if len(state.Builds) != 2 {
+		t.Errorf("Expected the state to only hold the builds in the window, got %d builds", len(state.Builds))
+	}
+}
@@ -19,6 +19,7 @@ limitations under the License.
 package summarize
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"runtime"
@@ -26,6 +27,8 @@ import (
 	"time"
 
 	"k8s.io/klog/v2"
+
+	pkgio "k8s.io/test-infra/prow/io"
 )
 
 const longOutputLen = 10000
@@ -42,11 +45,19 @@ type summarizeFlags struct {
 	outputSlices string
 	numWorkers   int
 	memoize      bool
+
+	// Ingestion straight from job artifacts, instead of the BigQuery export.
+	artifacts          []string
+	artifactsWindow    time.Duration
+	artifactsState     string
+	gcsCredentialsFile string
+	s3CredentialsFile  string
 }
 
 // parseFlags parses command-line arguments and returns them as a summarizeFlags object.
 func parseFlags() summarizeFlags {
 	var flags summarizeFlags
+	var artifacts string
 
 	flag.StringVar(&flags.builds, "builds", "", "path to builds.json file from BigQuery")
 	flag.StringVar(&flags.previous, "previous", "", "path to previous output")
@@ -55,12 +66,31 @@ func parseFlags() summarizeFlags {
 	flag.StringVar(&flags.outputSlices, "output_slices", "", "path to slices output (must include PREFIX in template)")
 	flag.IntVar(&flags.numWorkers, "num_workers", 2*runtime.NumCPU()-1, "number of worker goroutines to spawn for parallelized functions") // This has shown to be a sensible number of workers
 	flag.BoolVar(&flags.memoize, "memoize", false, "whether to memoize certain function results to JSON (and use previously memoized results if they exist)")
+	flag.StringVar(&artifacts, "artifacts", "", "comma-separated storage path prefixes of job directories to read builds and junit files from instead of BigQuery exports, e.g. gs://bucket/logs/")
+	flag.DurationVar(&flags.artifactsWindow, "artifacts_window", 14*24*time.Hour, "how far back builds are read from job artifacts")
+	flag.StringVar(&flags.artifactsState, "artifacts_state", "artifacts_state.json", "path to the file recording the builds already read from job artifacts")
+	flag.StringVar(&flags.gcsCredentialsFile, "gcs_credentials_file", "", "path to GCS credentials, used to read job artifacts")
+	flag.StringVar(&flags.s3CredentialsFile, "s3_credentials_file", "", "path to S3 credentials, used to read job artifacts")
 
 	flag.Parse()
 	// list of tests files comes from arguments
 	flags.tests = flag.Args()
 
+	if artifacts != "" {
+		flags.artifacts = strings.Split(artifacts, ",")
+	}
+
 	// Do some checks on the flags
+	if len(flags.artifacts) > 0 {
+		if flags.builds != "" || len(flags.tests) > 0 {
+			klog.Fatalf("builds and tests files can't be used with the artifacts flag")
+		}
+		for _, prefix := range flags.artifacts {
+			if !strings.Contains(prefix, "://") {
+				klog.Fatalf("'%s' in artifacts flag is not a storage path, e.g. gs://bucket/logs/", prefix)
+			}
+		}
+	}
 	if !(strings.Contains(flags.outputSlices, "PREFIX")) {
 		klog.Fatalf("'PREFIX' not in output_slices flag")
 	}
@@ -92,7 +122,14 @@ func summarize(flags summarizeFlags) {
 	// Log flag info
 	klog.V(1).Infof("Running with %d workers (%d detected CPUs)", flags.numWorkers, runtime.NumCPU())
 
-	builds, failedTests, err := loadFailures(flags.builds, flags.tests, flags.memoize)
+	var builds map[string]build
+	var failedTests map[string][]failure
+	var err error
+	if len(flags.artifacts) > 0 {
+		builds, failedTests, err = loadFailuresFromBuckets(flags)
+	} else {
+		builds, failedTests, err = loadFailures(flags.builds, flags.tests, flags.memoize)
+	}
 	if err != nil {
 		klog.Fatalf("Could not load failures: %s", err)
 	}
@@ -162,6 +199,22 @@ func summarize(flags summarizeFlags) {
 	klog.V(0).Infof("Finished rendering results in %s", time.Since(start).String())
 }
 
+// loadFailuresFromBuckets reads builds and test failures from the job artifacts given by the flags.
+func loadFailuresFromBuckets(flags summarizeFlags) (map[string]build, map[string][]failure, error) {
+	ctx := context.Background()
+	opener, err := pkgio.NewOpener(ctx, flags.gcsCredentialsFile, flags.s3CredentialsFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not create opener: %s", err)
+	}
+	return loadFailuresFromArtifacts(ctx, opener, ingestOptions{
+		prefixes:   flags.artifacts,
+		window:     flags.artifactsWindow,
+		statePath:  flags.artifactsState,
+		numWorkers: flags.numWorkers,
+		now:        time.Now,
+	})
+}
+
 func Main() {
 	summarize(parseFlags())
 }